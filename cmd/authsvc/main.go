@@ -2,16 +2,27 @@ package main
 
 import (
 	"context"
+	"errors"
+	"net/url"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/dhawalhost/wardseal/internal/auth"
+	"github.com/dhawalhost/wardseal/internal/featureflag"
 	"github.com/dhawalhost/wardseal/internal/license"
 	"github.com/dhawalhost/wardseal/internal/oauthclient"
+	"github.com/dhawalhost/wardseal/internal/rbac"
 	"github.com/dhawalhost/wardseal/internal/saml"
+	"github.com/dhawalhost/wardseal/internal/scopecatalog"
+	"github.com/dhawalhost/wardseal/internal/tenant"
+	"github.com/dhawalhost/wardseal/pkg/apierr"
+	"github.com/dhawalhost/wardseal/pkg/config"
 	"github.com/dhawalhost/wardseal/pkg/database"
 	"github.com/dhawalhost/wardseal/pkg/logger"
 	"github.com/dhawalhost/wardseal/pkg/middleware"
 	"github.com/dhawalhost/wardseal/pkg/observability"
+	"github.com/dhawalhost/wardseal/pkg/server"
 	"github.com/gin-gonic/gin"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 	"go.uber.org/zap"
@@ -23,66 +34,53 @@ func main() {
 	defer func() { _ = log.Sync() }()
 
 	// Enterprise License Verification
-	if os.Getenv("REQUIRE_LICENSE") == "true" {
-		log.Info("Checking Enterprise License...")
-
-		pubKeyPath := os.Getenv("LICENSE_PUBLIC_KEY_PATH")
-		if pubKeyPath == "" {
-			pubKeyPath = "/etc/wardseal/license_public.pem"
-		}
-
-		pubKey, err := os.ReadFile(pubKeyPath) //nolint:gosec // G304: path is from trusted env var
-		if err != nil {
-			log.Fatal("Failed to read license public key", zap.Error(err))
-		}
-
-		mgr, err := license.NewManager(pubKey)
-		if err != nil {
-			log.Fatal("Failed to initialize license manager", zap.Error(err))
-		}
-
-		licenseKey := os.Getenv("LICENSE_KEY")
-		if licenseKey == "" {
-			log.Fatal("LICENSE_KEY environment variable is required for enterprise edition")
-		}
-
-		lic, err := mgr.Verify(licenseKey)
-		if err != nil {
-			log.Fatal("Invalid license key", zap.Error(err))
-		}
-
+	lic, err := license.FromEnv()
+	if err != nil {
+		log.Fatal("Invalid license", zap.Error(err))
+	}
+	if lic != nil {
 		log.Info("Enterprise License Verified",
 			zap.String("customer", lic.CustomerName),
 			zap.Time("expires_at", lic.ExpiresAt),
-			zap.String("plan", lic.Plan))
+			zap.String("plan", lic.Plan),
+			zap.Strings("features", lic.Features))
 	}
 
-	directoryServiceURL := os.Getenv("DIRECTORY_SERVICE_URL")
-	if directoryServiceURL == "" {
-		directoryServiceURL = "http://dirsvc:8081" // Use service name in docker-compose
+	defaults := config.Defaults()
+	defaults.Services.Directory = "http://dirsvc:8081" // Use service name in docker-compose
+	cfg, err := config.Load(defaults, config.Options{RequireDB: true})
+	if err != nil {
+		log.Error("Failed to load configuration", zap.Error(err))
+		os.Exit(1)
 	}
+	tlsConfig, err := server.NewTLSConfig(cfg.HTTP.TLS.Options())
+	if err != nil {
+		log.Error("Failed to configure TLS", zap.Error(err))
+		os.Exit(1)
+	}
+	directoryServiceURL := cfg.Services.Directory
 
-	serviceToken := os.Getenv("SERVICE_AUTH_TOKEN")
+	serviceToken := cfg.ServiceAuth.Token
 	if serviceToken == "" {
 		serviceToken = "dev-internal-token" //nolint:gosec // G101: dev-only fallback, not production credentials
 		log.Warn("SERVICE_AUTH_TOKEN not set, using development default")
 	}
-	serviceHeader := os.Getenv("SERVICE_AUTH_HEADER")
+	serviceHeader := cfg.ServiceAuth.Header
 
-	dbHost := os.Getenv("DB_HOST")
-	if dbHost == "" {
-		dbHost = "localhost"
+	// An ephemeral key leaves stored TOTP secrets unreadable after a restart
+	// or on another instance, locking out every enrolled user.
+	if len(cfg.Keys.MFAEncryption) == 0 {
+		if cfg.Environment != "development" {
+			log.Error("keys.mfa_encryption (MFA_ENCRYPTION_KEY) is required outside development")
+			os.Exit(1)
+		}
+		log.Warn("MFA_ENCRYPTION_KEY not set, using an ephemeral key; TOTP enrollments will not survive restarts")
 	}
-	dbConfig := database.Config{
-		Host:     dbHost,
-		Port:     5432,
-		User:     envOr("DB_USER", "user"),
-		Password: envOr("DB_PASSWORD", "password"),
-		DBName:   envOr("DB_NAME", "identity_platform"),
-		SSLMode:  envOr("DB_SSLMODE", "disable"),
+	if len(cfg.Keys.SigningKeyEncryption) == 0 {
+		log.Warn("SIGNING_KEY_ENCRYPTION_KEY not set, JWT signing keys are stored unencrypted")
 	}
 
-	db, err := database.NewConnection(dbConfig)
+	db, err := database.NewConnection(cfg.DB.Connection())
 	if err != nil {
 		log.Error("Failed to connect to database", zap.Error(err))
 		os.Exit(1)
@@ -95,34 +93,86 @@ func main() {
 	brandingStore := auth.NewBrandingStore(db)
 	ssoProviderStore := auth.NewSQLSSOProviderStore(db)
 
-	authServiceURL := os.Getenv("AUTH_SERVICE_URL")
-	if authServiceURL == "" {
-		authServiceURL = "http://localhost:8080"
-	}
+	authServiceURL := cfg.Services.Auth
 
 	// Initialize persistent stores for production durability
 	codeStore := auth.NewSQLAuthorizationCodeStore(db)
 	refreshStore := auth.NewSQLRefreshTokenStore(db)
+	sessionStore := auth.NewSessionStore(db)
 	revocationStore := auth.NewSQLRevocationStore(db)
 	totpStore := auth.NewTOTPStore(db)
+	recoveryCodeStore := auth.NewRecoveryCodeStore(db)
+	authAuditStore := auth.NewAuthAuditStore(db)
+	consentStore := auth.NewConsentStore(db)
+	deviceCodeStore := auth.NewSQLDeviceCodeStore(db)
+	socialStateStore := auth.NewSQLSocialStateStore(db)
+	emailVerificationStore := auth.NewEmailVerificationStore(db)
+	passwordResetStore := auth.NewPasswordResetStore(db)
+	impersonationStore := auth.NewImpersonationStore(db)
+	// Role checks for impersonation and the administration routes; the
+	// statements are closed on shutdown ahead of the pool.
+	stmts := database.NewStmtCache(db, cfg.DB.QueryLimits(log))
+	permissions := rbac.NewService(rbac.NewStore(stmts), rbac.ServiceConfig{})
+
+	if cfg.SAML.CertFile == "" {
+		log.Error("saml.cert_file and saml.key_file (SAML_CERT_FILE, SAML_KEY_FILE) are required")
+		os.Exit(1)
+	}
+	samlCert, samlKey, err := saml.LoadKeyPair(cfg.SAML.CertFile, cfg.SAML.KeyFile)
+	if err != nil {
+		log.Error("Failed to load the SAML key pair", zap.Error(err))
+		os.Exit(1)
+	}
+
+	mailer, err := accountMailer(cfg)
+	if err != nil {
+		log.Error("Failed to configure mail", zap.Error(err))
+		os.Exit(1)
+	}
 
 	svc, err := auth.NewService(auth.Config{
 		DirectoryServiceURL: directoryServiceURL,
 		ServiceAuthToken:    serviceToken,
 		ServiceAuthHeader:   serviceHeader,
+		ServiceName:         "authsvc",
+		ServiceSigningKey:   []byte(cfg.ServiceAuth.SigningKey),
 		ClientStore:         clientStore,
 		SAMLStore:           samlStore,
+		SAMLCertificate:     samlCert,
+		SAMLPrivateKey:      samlKey,
 		DeviceStore:         deviceStore,
 		SignalStore:         signalStore,
 		WebAuthnStore:       webauthnStore,
 		BrandingStore:       brandingStore,
 		BaseURL:             authServiceURL,
 		// Use SQL stores for persistence
-		CodeStore:        codeStore,
-		RefreshStore:     refreshStore,
-		RevocationStore:  revocationStore,
-		TOTPStore:        totpStore,
-		SSOProviderStore: ssoProviderStore,
+		CodeStore:              codeStore,
+		RefreshStore:           refreshStore,
+		SessionStore:           sessionStore,
+		RevocationStore:        revocationStore,
+		TOTPStore:              totpStore,
+		RecoveryCodeStore:      recoveryCodeStore,
+		SSOProviderStore:       ssoProviderStore,
+		SocialStateStore:       socialStateStore,
+		AuthAuditStore:         authAuditStore,
+		ConsentStore:           consentStore,
+		DeviceCodeStore:        deviceCodeStore,
+		EmailVerificationStore: emailVerificationStore,
+		PasswordResetStore:     passwordResetStore,
+		Mailer:                 mailer,
+		ImpersonationStore:     impersonationStore,
+		Permissions:            permissions,
+		ScopePolicy:            cfg.Auth.ScopePolicy,
+		ScopeCatalog:           scopecatalog.NewStore(db),
+		AccessTokenFormat:      cfg.Auth.AccessTokenFormat,
+		AccessTokenStore:       auth.NewAccessTokenStore(db),
+		DPoPReplayStore:        auth.NewDPoPReplayStore(db),
+		DPoPNonceKey:           cfg.Keys.DPoPNonce,
+		MFAEncryptionKey:       cfg.Keys.MFAEncryption,
+		SSOEncryptionKey:       cfg.Keys.SSOEncryption,
+		// JWT signing keys are shared by all instances and survive restarts.
+		SigningKeyStore:         auth.NewSQLSigningKeyStore(db),
+		SigningKeyEncryptionKey: cfg.Keys.SigningKeyEncryption,
 	})
 	if err != nil {
 		log.Error("Failed to create auth service", zap.Error(err))
@@ -130,12 +180,14 @@ func main() {
 	}
 
 	router := gin.Default()
+	// Unknown routes and methods answer in the standard JSON error body.
+	apierr.RegisterFallbacks(router)
 
 	// Initialize OpenTelemetry tracing
 	shutdownTracer, err := observability.InitTracer(context.Background(), observability.TracerConfig{
 		ServiceName:    "authsvc",
 		ServiceVersion: "1.0.0",
-		Environment:    envOr("ENVIRONMENT", "development"),
+		Environment:    cfg.Environment,
 	}, log)
 	if err != nil {
 		log.Error("Failed to initialize tracer", zap.Error(err))
@@ -145,18 +197,47 @@ func main() {
 	// Initialize and apply observability middleware
 	metrics := observability.NewMetrics()
 	router.Use(otelgin.Middleware("authsvc"))
-	router.Use(observability.PrometheusMiddleware(metrics))
+	router.Use(observability.PrometheusMiddleware(metrics, cfg.Metrics.Options()))
 	router.Use(logger.RequestLogger(log))
 
 	// Security Middleware
-	router.Use(middleware.SecurityHeadersMiddleware())
+	router.Use(middleware.SecurityHeaders(middleware.SecurityHeadersConfig{HSTS: cfg.HTTP.HSTSEnabled}))
+	// CORS: globally allowed origins plus, per tenant, the origins of its OAuth clients' redirect URIs.
+	// Only the global origins may send cookies.
+	router.Use(middleware.CORS(middleware.CORSConfig{
+		AllowedOrigins:   cfg.HTTP.CORSAllowedOrigins,
+		TenantOrigins:    clientOrigins(clientStore),
+		AllowedHeaders:   []string{"Origin", "Content-Type", "Authorization", "X-Tenant-ID", "X-Device-ID", "X-OS-Version", auth.DPoPHeader},
+		ExposedHeaders:   []string{auth.DPoPNonceHeader},
+		AllowCredentials: true,
+		MaxAge:           12 * time.Hour,
+	}))
 	// Rate limit: 20 requests/second, burst of 40
 	router.Use(middleware.RateLimitMiddleware(rate.Limit(20), 40))
+	// Reject request bodies over MAX_REQUEST_BODY_BYTES (default 1 MiB).
+	router.Use(middleware.BodyLimit(cfg.HTTP.MaxBodyBytes))
 
 	// Initialize login attempt store for brute-force protection
 	loginAttemptStore := auth.NewLoginAttemptStore(db)
+	loginThrottle := auth.NewLoginThrottle(loginAttemptStore, auth.LockoutConfig{
+		MaxFailedAttempts:      cfg.Auth.Login.MaxFailedAttempts,
+		MaxFailedAttemptsPerIP: cfg.Auth.Login.MaxFailedAttemptsPerIP,
+		LockoutDuration:        cfg.Auth.Login.LockoutDuration,
+	})
 
-	authHandlers := auth.NewHTTPHandler(svc, log, loginAttemptStore)
+	authHandlers := auth.NewHTTPHandler(svc, log, loginThrottle)
+	authHandlers.UseTenantLookup(tenant.Lookup(tenant.NewStore(db)))
+	authHandlers.UseFeatureFlags(featureflag.NewService(featureflag.NewStore(db), lic))
+	authHandlers.UsePermissions(permissions)
+	// Tighter limit for credential and token endpoints, bucketed per client
+	// IP and client_id. Callers cycling client_ids stay bound by the per-IP
+	// limit above.
+	authHandlers.UseCredentialRateLimiter(middleware.RateLimiter(middleware.RateLimiterConfig{
+		Name:    "credentials",
+		Limit:   rate.Limit(cfg.Auth.CredentialRateLimit),
+		Burst:   cfg.Auth.CredentialRateBurst,
+		KeyFunc: middleware.KeyByClient,
+	}))
 	authHandlers.RegisterRoutes(router)
 	authHandlers.RegisterBrandingRoutes(router.Group("/"))
 
@@ -175,16 +256,71 @@ func main() {
 
 	// Register IdP-initiated endpoint logic is handled inside authHandlers.RegisterRoutes -> svc.SAML()
 
-	log.Info("Auth service starting", zap.String("addr", ":8080"))
-	if err := router.Run(":8080"); err != nil {
+	// Reload the shared signing keys and rotate them every
+	// auth.signing_key_rotation_interval (never when 0).
+	ctx, stop := server.ShutdownContext()
+	defer stop()
+	var background sync.WaitGroup
+	background.Go(func() { svc.RunSigningKeyRotation(ctx, cfg.Auth.SigningKeyRotationInterval) })
+
+	log.Info("Auth service starting", zap.String("addr", cfg.HTTP.Addr), zap.Bool("tls", tlsConfig != nil))
+	// Report ready on /readyz only once the schema is current, migrating first
+	// when DB_AUTO_MIGRATE is set, and the signing keys are loaded.
+	gate := server.NewGate(router,
+		database.SchemaCheck(db, database.RequiredSchemaVersion, cfg.DB.MigrationSource()),
+		svc.LoadSigningKeys)
+	gate.OnFailure = func(err error) { log.Warn("Service not ready", zap.Error(err)) }
+	// Stop the key rotation before the database closes.
+	waitForWorkers := server.CloserFunc(func() error {
+		stop()
+		background.Wait()
+		return nil
+	})
+	if err := server.RunTLSContext(ctx, gate, cfg.HTTP.Addr, tlsConfig, waitForWorkers, stmts, db); err != nil {
 		log.Error("Auth service failed", zap.Error(err))
 		os.Exit(1)
 	}
 }
 
-func envOr(key, fallback string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// accountMailer returns the SMTP mailer for verification and password reset
+// links. Without a relay the links are only logged, where anyone reading the
+// logs could use them, so that is refused outside development.
+func accountMailer(cfg config.Config) (auth.Mailer, error) {
+	if cfg.Mail.SMTPHost == "" {
+		if cfg.Environment != "development" {
+			return nil, errors.New("mail.smtp_host (SMTP_HOST) is required outside development")
+		}
+		return nil, nil
+	}
+	mailer, err := auth.NewSMTPMailer(auth.SMTPConfig{
+		Host:     cfg.Mail.SMTPHost,
+		Port:     cfg.Mail.SMTPPort,
+		Username: cfg.Mail.SMTPUsername,
+		Password: cfg.Mail.SMTPPassword,
+		From:     cfg.Mail.From,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mailer, nil
+}
+
+// clientOrigins allows a tenant's SPAs to call the auth service by deriving
+// origins from the redirect URIs of its registered OAuth clients.
+func clientOrigins(store oauthclient.Store) func(ctx context.Context, tenantID string) ([]string, error) {
+	return func(ctx context.Context, tenantID string) ([]string, error) {
+		clients, err := store.ListClientsByTenant(ctx, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		var origins []string
+		for _, client := range clients {
+			for _, redirectURI := range client.RedirectURIs {
+				if u, err := url.Parse(redirectURI); err == nil && u.Scheme != "" && u.Host != "" {
+					origins = append(origins, u.Scheme+"://"+u.Host)
+				}
+			}
+		}
+		return origins, nil
 	}
-	return fallback
 }
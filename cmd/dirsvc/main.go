@@ -6,10 +6,14 @@ import (
 
 	"github.com/dhawalhost/wardseal/internal/directory"
 	"github.com/dhawalhost/wardseal/internal/scim"
+	"github.com/dhawalhost/wardseal/internal/tenant"
+	"github.com/dhawalhost/wardseal/pkg/apierr"
+	"github.com/dhawalhost/wardseal/pkg/config"
 	"github.com/dhawalhost/wardseal/pkg/database"
 	"github.com/dhawalhost/wardseal/pkg/logger"
 	"github.com/dhawalhost/wardseal/pkg/middleware"
 	"github.com/dhawalhost/wardseal/pkg/observability"
+	"github.com/dhawalhost/wardseal/pkg/server"
 	"github.com/gin-gonic/gin"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 	"go.uber.org/zap"
@@ -20,43 +24,49 @@ func main() {
 	log := logger.NewFromEnv()
 	defer func() { _ = log.Sync() }()
 
-	dbHost := os.Getenv("DB_HOST")
-	if dbHost == "" {
-		dbHost = "localhost"
+	defaults := config.Defaults()
+	defaults.HTTP.Addr = ":8081"
+	cfg, err := config.Load(defaults, config.Options{RequireDB: true})
+	if err != nil {
+		log.Error("Failed to load configuration", zap.Error(err))
+		os.Exit(1)
 	}
-
-	// Database connection
-	dbConfig := database.Config{
-		Host:     dbHost,
-		Port:     5432,
-		User:     "user",
-		Password: "password",
-		DBName:   "identity_platform",
-		SSLMode:  "disable",
+	tlsConfig, err := server.NewTLSConfig(cfg.HTTP.TLS.Options())
+	if err != nil {
+		log.Error("Failed to configure TLS", zap.Error(err))
+		os.Exit(1)
 	}
 
-	db, err := database.NewConnection(dbConfig)
+	db, err := database.NewConnection(cfg.DB.Connection())
 	if err != nil {
 		log.Error("Failed to connect to database", zap.Error(err))
 		os.Exit(1)
 	}
 
-	svc := directory.NewService(db)
+	passwordPolicy := directory.DefaultPasswordPolicy()
+	if cfg.Directory.PasswordBreachCheck {
+		passwordPolicy.BreachChecker = directory.NewHIBPChecker()
+	}
+	svc := directory.NewService(db, directory.ServiceConfig{
+		PasswordPolicy: passwordPolicy,
+		BcryptCost:     cfg.Directory.BcryptCost,
+	})
 
-	serviceToken := os.Getenv("SERVICE_AUTH_TOKEN")
+	serviceToken := cfg.ServiceAuth.Token
 	if serviceToken == "" {
 		serviceToken = "dev-internal-token" //nolint:gosec // G101: dev-only fallback, not production credentials
 		log.Warn("SERVICE_AUTH_TOKEN not set, using development default")
 	}
-	serviceHeader := os.Getenv("SERVICE_AUTH_HEADER")
 
 	router := gin.Default()
+	// Unknown routes and methods answer in the standard JSON error body.
+	apierr.RegisterFallbacks(router)
 
 	// Initialize OpenTelemetry tracing
 	shutdownTracer, err := observability.InitTracer(context.Background(), observability.TracerConfig{
 		ServiceName:    "dirsvc",
 		ServiceVersion: "1.0.0",
-		Environment:    envOr("ENVIRONMENT", "development"),
+		Environment:    cfg.Environment,
 	}, log)
 	if err != nil {
 		log.Error("Failed to initialize tracer", zap.Error(err))
@@ -66,19 +76,17 @@ func main() {
 	// Initialize and apply observability middleware
 	metrics := observability.NewMetrics()
 	router.Use(otelgin.Middleware("dirsvc"))
-	router.Use(observability.PrometheusMiddleware(metrics))
-	router.Use(observability.PrometheusMiddleware(metrics))
+	router.Use(observability.PrometheusMiddleware(metrics, cfg.Metrics.Options()))
 	router.Use(logger.RequestLogger(log))
 
 	// Security Middleware
-	router.Use(middleware.SecurityHeadersMiddleware())
-	// Rate limit: 20 req/s, burst 40
-	router.Use(middleware.RateLimitMiddleware(rate.Limit(20), 40))
-
-	// Security Middleware
-	router.Use(middleware.SecurityHeadersMiddleware())
+	router.Use(middleware.SecurityHeaders(middleware.SecurityHeadersConfig{HSTS: cfg.HTTP.HSTSEnabled}))
 	// Rate limit: 20 req/s, burst 40 (adjust as needed for bulk SCIM ops)
 	router.Use(middleware.RateLimitMiddleware(rate.Limit(20), 40))
+	// Reject request bodies over MAX_REQUEST_BODY_BYTES (default 1 MiB).
+	router.Use(middleware.BodyLimit(cfg.HTTP.MaxBodyBytes))
+	// Render errors handlers attach with c.Error.
+	router.Use(apierr.Handler(log))
 
 	// Register Prometheus metrics handler
 	router.GET("/metrics", gin.WrapH(observability.PrometheusHandler()))
@@ -86,25 +94,25 @@ func main() {
 	// Register service routes
 	api := directory.NewHTTPHandler(svc, log, directory.HTTPHandlerConfig{
 		ServiceAuthToken:  serviceToken,
-		ServiceAuthHeader: serviceHeader,
+		ServiceAuthHeader: cfg.ServiceAuth.Header,
+		ServiceKeys:       cfg.ServiceAuth.Keys(),
+		Photos:            directory.NewPhotoStore(db),
+		TenantLookup:      tenant.Lookup(tenant.NewStore(db)),
 	})
 	api.RegisterRoutes(router)
 
 	// Register SCIM routes
-	scimSvc := scim.NewService(svc)
+	scimSvc := scim.NewService(svc, scim.NewExtensionStore(db))
 	scimHandlers := scim.NewHTTPHandler(scimSvc, log)
 	scimHandlers.RegisterRoutes(router)
 
-	log.Info("HTTP server starting", zap.String("addr", ":8081"))
-	if err := router.Run(":8081"); err != nil {
+	log.Info("HTTP server starting", zap.String("addr", cfg.HTTP.Addr), zap.Bool("tls", tlsConfig != nil))
+	// Report ready on /readyz only once the schema is current, migrating first
+	// when DB_AUTO_MIGRATE is set.
+	gate := server.NewGate(router, database.SchemaCheck(db, database.RequiredSchemaVersion, cfg.DB.MigrationSource()))
+	gate.OnFailure = func(err error) { log.Warn("Service not ready", zap.Error(err)) }
+	if err := server.RunTLS(gate, cfg.HTTP.Addr, tlsConfig, db); err != nil {
 		log.Error("HTTP server failed", zap.Error(err))
 		os.Exit(1)
 	}
 }
-
-func envOr(key, fallback string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return fallback
-}
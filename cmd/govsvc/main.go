@@ -3,7 +3,7 @@ package main
 import (
 	"context"
 	"os"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/dhawalhost/wardseal/internal/audit"
@@ -12,16 +12,26 @@ import (
 	"github.com/dhawalhost/wardseal/internal/connector/google"
 	"github.com/dhawalhost/wardseal/internal/connector/ldap"
 	"github.com/dhawalhost/wardseal/internal/connector/scim"
+	"github.com/dhawalhost/wardseal/internal/directory"
+	"github.com/dhawalhost/wardseal/internal/featureflag"
 	"github.com/dhawalhost/wardseal/internal/governance"
+	"github.com/dhawalhost/wardseal/internal/license"
 	"github.com/dhawalhost/wardseal/internal/oauthclient"
+	"github.com/dhawalhost/wardseal/internal/outbox"
 	"github.com/dhawalhost/wardseal/internal/policy"
 	"github.com/dhawalhost/wardseal/internal/rbac"
+	"github.com/dhawalhost/wardseal/internal/scopecatalog"
 	"github.com/dhawalhost/wardseal/internal/sso"
+	"github.com/dhawalhost/wardseal/internal/tenant"
 	"github.com/dhawalhost/wardseal/internal/webhook"
+	"github.com/dhawalhost/wardseal/pkg/apierr"
+	"github.com/dhawalhost/wardseal/pkg/config"
 	"github.com/dhawalhost/wardseal/pkg/database"
 	"github.com/dhawalhost/wardseal/pkg/logger"
 	"github.com/dhawalhost/wardseal/pkg/middleware"
 	"github.com/dhawalhost/wardseal/pkg/observability"
+	"github.com/dhawalhost/wardseal/pkg/secretbox"
+	"github.com/dhawalhost/wardseal/pkg/server"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
@@ -33,40 +43,58 @@ func main() {
 	log := logger.NewFromEnv()
 	defer func() { _ = log.Sync() }()
 
-	dbHost := envOr("DB_HOST", "localhost")
-	dbConfig := database.Config{
-		Host:     dbHost,
-		Port:     5432,
-		User:     envOr("DB_USER", "user"),
-		Password: envOr("DB_PASSWORD", "password"),
-		DBName:   envOr("DB_NAME", "identity_platform"),
-		SSLMode:  envOr("DB_SSLMODE", "disable"),
+	// Enterprise features are bounded by the license when one is required.
+	lic, err := license.FromEnv()
+	if err != nil {
+		log.Fatal("Invalid license", zap.Error(err))
 	}
 
-	db, err := database.NewConnection(dbConfig)
+	defaults := config.Defaults()
+	defaults.HTTP.Addr = ":8082"
+	cfg, err := config.Load(defaults, config.Options{RequireDB: true})
+	if err != nil {
+		log.Error("Failed to load configuration", zap.Error(err))
+		os.Exit(1)
+	}
+	tlsConfig, err := server.NewTLSConfig(cfg.HTTP.TLS.Options())
+	if err != nil {
+		log.Error("Failed to configure TLS", zap.Error(err))
+		os.Exit(1)
+	}
+
+	db, err := database.NewConnection(cfg.DB.Connection())
 	if err != nil {
 		log.Error("Failed to connect to database", zap.Error(err))
 		os.Exit(1)
 	}
+	// Prepared statements for the hot RBAC and access request queries; closed
+	// on shutdown ahead of the pool.
+	stmts := database.NewStmtCache(db, cfg.DB.QueryLimits(log))
 	clientRepo := oauthclient.NewRepository(db)
-	reqStore := governance.NewStore(db)
+	reqStore := governance.NewStore(stmts)
 
-	dirSvcURL := envOr("DIRSVC_URL", "http://localhost:8081")
-	dirClient := governance.NewDirectoryClient(dirSvcURL)
-
-	policyEngine := policy.NewSimpleEngine()
-	svc := governance.NewService(clientRepo, reqStore, dirClient, policyEngine)
+	dirClient := governance.NewDirectoryClient(cfg.Services.Directory)
 
 	// Initialize metrics
 	metrics := observability.NewMetrics()
 
+	policyEngine := policy.NewSimpleEngine()
+	// Every governance service call is timed in
+	// governance_operation_duration_seconds.
+	svc := governance.NewInstrumentedService(
+		governance.NewService(clientRepo, reqStore, policyEngine),
+		metrics.RecordGovernanceOperation,
+	)
+
 	router := gin.Default()
+	// Unknown routes and methods answer in the standard JSON error body.
+	apierr.RegisterFallbacks(router)
 
 	// Initialize OpenTelemetry tracing
 	shutdownTracer, err := observability.InitTracer(context.Background(), observability.TracerConfig{
 		ServiceName:    "govsvc",
 		ServiceVersion: "1.0.0",
-		Environment:    envOr("ENVIRONMENT", "development"),
+		Environment:    cfg.Environment,
 	}, log)
 	if err != nil {
 		log.Error("Failed to initialize tracer", zap.Error(err))
@@ -75,19 +103,23 @@ func main() {
 
 	// Add observability middleware
 	router.Use(otelgin.Middleware("govsvc"))
-	router.Use(observability.PrometheusMiddleware(metrics))
+	router.Use(observability.PrometheusMiddleware(metrics, cfg.Metrics.Options()))
 	router.Use(logger.RequestLogger(log))
 
 	// Security Middleware
-	router.Use(middleware.SecurityHeadersMiddleware())
+	router.Use(middleware.SecurityHeaders(middleware.SecurityHeadersConfig{HSTS: cfg.HTTP.HSTSEnabled}))
 	// Rate limit: 20 req/s, burst 40
 	router.Use(middleware.RateLimitMiddleware(rate.Limit(20), 40))
+	// Reject request bodies over MAX_REQUEST_BODY_BYTES (default 1 MiB).
+	router.Use(middleware.BodyLimit(cfg.HTTP.MaxBodyBytes))
+	// Render errors handlers attach with c.Error.
+	router.Use(apierr.Handler(log))
 
-	corsOrigins := parseCSV(envOr("CORS_ALLOWED_ORIGINS", "http://localhost:5173,http://127.0.0.1:5173"))
+	corsOrigins := cfg.HTTP.CORSAllowedOrigins
 	corsConfig := cors.Config{
 		AllowMethods:  []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:  []string{"Origin", "Content-Type", "X-Tenant-ID"},
-		ExposeHeaders: []string{"Content-Length"},
+		AllowHeaders:  []string{"Origin", "Content-Type", "X-Tenant-ID", middleware.IdempotencyKeyHeader},
+		ExposeHeaders: []string{"Content-Length", middleware.IdempotentReplayHeader, "Link", middleware.TotalCountHeader},
 		MaxAge:        12 * time.Hour,
 	}
 	if allowsAllOrigins(corsOrigins) {
@@ -101,6 +133,7 @@ func main() {
 	router.GET("/metrics", gin.WrapH(observability.PrometheusHandler()))
 
 	govHandlers := governance.NewHTTPHandler(svc, log)
+	govHandlers.UseIdempotencyStore(governance.NewIdempotencyStore(db))
 	govHandlers.RegisterRoutes(router)
 
 	// Campaign handlers
@@ -108,16 +141,58 @@ func main() {
 	campaignSvc := governance.NewCampaignService(campaignStore, dirClient)
 	campaignHandlers := governance.NewCampaignHTTPHandler(campaignSvc, log)
 
+	// Suspended tenants are rejected on every tenant-scoped route.
+	tenantStore := tenant.NewStore(db)
 	apiGroup := router.Group("/api/v1")
-	apiGroup.Use(middleware.TenantExtractor(middleware.TenantConfig{}))
-	campaignHandlers.RegisterRoutes(apiGroup)
+	apiGroup.Use(middleware.TenantExtractor(middleware.TenantConfig{Lookup: tenant.Lookup(tenantStore)}))
+
+	// Feature flags turn campaigns and SSO on and off per tenant.
+	featureSvc := featureflag.NewService(featureflag.NewStore(db), lic)
+	featureHandlers := featureflag.NewHTTPHandler(featureSvc, log)
+	featureHandlers.RegisterRoutes(apiGroup)
+
+	// Scope catalog entries describe scopes on the consent screen; clients may
+	// only be registered with, and granted, catalogued scopes.
+	scopeHandlers := scopecatalog.NewHTTPHandler(scopecatalog.NewService(scopecatalog.NewStore(db)), log)
+	scopeHandlers.RegisterRoutes(apiGroup)
+
+	campaignGroup := apiGroup.Group("")
+	campaignGroup.Use(featureflag.Require(featureSvc, featureflag.FeatureCampaigns))
+	campaignHandlers.RegisterRoutes(campaignGroup)
 
 	// RBAC handlers
-	rbacStore := rbac.NewStore(db)
-	rbacSvc := rbac.NewService(rbacStore)
+	var rbacStore rbac.Store = rbac.NewStore(stmts)
+	// rbac.permission_cache_ttl caches effective permissions in memory; 0
+	// queries the database on every check.
+	if ttl := cfg.RBAC.PermissionCacheTTL; ttl > 0 {
+		rbacStore = rbac.NewPermissionCache(rbacStore, rbac.PermissionCacheConfig{
+			TTL:      ttl,
+			OnLookup: metrics.RecordPermissionCacheLookup,
+		})
+	}
+	var rbacConfig rbac.ServiceConfig
+	if path := cfg.RBAC.DefaultRolesFile; path != "" {
+		rbacConfig.DefaultRoles, err = rbac.LoadRoleTemplates(path)
+		if err != nil {
+			log.Error("Invalid RBAC_DEFAULT_ROLES_FILE", zap.Error(err))
+			os.Exit(1)
+		}
+	}
+	rbacSvc := rbac.NewService(rbacStore, rbacConfig)
 	rbacHandlers := rbac.NewHTTPHandler(rbacSvc, log)
 	rbacHandlers.RegisterRoutes(apiGroup)
 
+	// Tenant management is not tenant-scoped; platform administrators call it
+	// with a service token.
+	tenantSvc := tenant.NewService(tenantStore, rbacSvc)
+	tenantHandlers := tenant.NewHTTPHandler(tenantSvc, middleware.ServiceAuthConfig{
+		HeaderName: cfg.ServiceAuth.Header,
+		Token:      cfg.ServiceAuth.Token,
+		Audience:   "govsvc",
+		Keys:       cfg.ServiceAuth.Keys(),
+	}, log)
+	tenantHandlers.RegisterRoutes(router.Group("/api/v1"))
+
 	// Audit handlers
 	auditStore := audit.NewStore(db)
 	auditSvc := audit.NewService(auditStore)
@@ -134,61 +209,98 @@ func main() {
 	domainVerifyHandler.RegisterRoutes(apiGroup)
 
 	// SSO handlers
+	var ssoCipher *secretbox.Cipher
+	if ssoKey := cfg.Keys.SSOEncryption; len(ssoKey) > 0 {
+		if ssoCipher, err = secretbox.New(ssoKey); err != nil {
+			log.Error("Invalid SSO_ENCRYPTION_KEY", zap.Error(err))
+			os.Exit(1)
+		}
+	} else {
+		log.Warn("SSO_ENCRYPTION_KEY not set, SSO client secrets will be stored unencrypted")
+	}
 	ssoStore := sso.NewStore(db)
-	ssoSvc := sso.NewService(ssoStore)
+	ssoSvc := sso.NewService(ssoStore, ssoCipher)
 	ssoHandlers := sso.NewHTTPHandler(ssoSvc, log)
-	ssoHandlers.RegisterRoutes(apiGroup)
+	ssoGroup := apiGroup.Group("")
+	ssoGroup.Use(featureflag.Require(featureSvc, featureflag.FeatureSSO))
+	ssoHandlers.RegisterRoutes(ssoGroup)
 
 	// Connector Framework
 	connRegistry := connector.NewRegistry()
-	connRegistry.Register("scim", scim.New)
-	connRegistry.Register("ldap", ldap.New)
-	connRegistry.Register("azure-ad", azuread.New)
-	connRegistry.Register("google", google.New)
+	connRegistry.Register("scim", scim.New, scim.Schema)
+	connRegistry.Register("ldap", ldap.New, ldap.Schema)
+	connRegistry.Register("azure-ad", azuread.New, azuread.Schema)
+	connRegistry.Register("google", google.New, google.Schema)
 
 	connStore := connector.NewStore(db)
 	connSvc := connector.NewService(connStore, connRegistry)
 	connHandlers := connector.NewHTTPHandler(connSvc, log)
 	connHandlers.RegisterRoutes(apiGroup)
 
+	// Background workers run until shutdown starts.
+	ctx, stop := server.ShutdownContext()
+	defer stop()
+	var background sync.WaitGroup
+
+	provSvc := connector.NewProvisioningService(db, connRegistry, log)
+	provHandlers := connector.NewProvisioningHTTPHandler(provSvc, log)
+	provHandlers.RegisterRoutes(apiGroup)
+	provWorker := connector.NewProvisioningWorker(connector.ProvisioningWorkerConfig{
+		Queue:   provSvc,
+		Configs: connStore,
+		Logger:  log,
+	})
+	background.Go(func() { provWorker.Start(ctx) })
+
+	// Publish committed cross-service events, such as provisioning for
+	// approved access requests.
+	relay := outbox.NewRelay(outbox.RelayConfig{
+		Store: outbox.NewStore(db),
+		Handlers: map[string]outbox.Handler{
+			governance.EventAccessRequestApproved: governance.ProvisionApprovedAccess(dirClient),
+		},
+		Logger: log,
+	})
+	background.Go(func() { relay.Start(ctx) })
+
 	// Webhooks
 	webhookSvc := webhook.NewService(db)
 	webhookHandlers := governance.NewWebhookHTTPHandler(webhookSvc, log)
 	webhookHandlers.RegisterRoutes(apiGroup)
 
-	// Initialize active connectors from DB
-	// In a real app, this should be done more robustly
-	// ctx := context.Background()
-	// configs, _ := connStore.List(ctx, "") // Empty tenant ID for all? No, we need per tenant.
-	// For now, let's skip auto-loading on startup as we don't have multi-tenant iteration logic here easily.
-	// We rely on "lazy" loading or manual toggle in UI for this MVP.
+	// Inbound sync from connectors with sync_enabled set. The directory
+	// shares this database, so synced objects are written through its service.
+	if syncInterval := cfg.Connectors.SyncInterval; syncInterval > 0 {
+		dirSvc := directory.NewService(db, directory.ServiceConfig{PasswordPolicy: directory.DefaultPasswordPolicy()})
+		syncScheduler := connector.NewSyncScheduler(connector.SyncSchedulerConfig{
+			Sources:  connStore,
+			Registry: connRegistry,
+			Sink:     connector.NewDirectorySink(dirSvc),
+			States:   connector.NewSyncStateStore(db),
+			Interval: syncInterval,
+			Logger:   log,
+		})
+		background.Go(func() { syncScheduler.Start(ctx) })
+	}
 
-	log.Info("Governance service starting", zap.String("addr", ":8082"))
-	if err := router.Run(":8082"); err != nil {
+	log.Info("Governance service starting", zap.String("addr", cfg.HTTP.Addr), zap.Bool("tls", tlsConfig != nil))
+	// Report ready on /readyz only once the schema is current, migrating first
+	// when DB_AUTO_MIGRATE is set.
+	gate := server.NewGate(router, database.SchemaCheck(db, database.RequiredSchemaVersion, cfg.DB.MigrationSource()))
+	gate.OnFailure = func(err error) { log.Warn("Service not ready", zap.Error(err)) }
+	// The workers stop with the server and finish their current tasks before
+	// the statements and pool they use are closed.
+	waitForWorkers := server.CloserFunc(func() error {
+		stop()
+		background.Wait()
+		return nil
+	})
+	if err := server.RunTLSContext(ctx, gate, cfg.HTTP.Addr, tlsConfig, waitForWorkers, stmts, db); err != nil {
 		log.Error("Governance service failed", zap.Error(err))
 		os.Exit(1)
 	}
 }
 
-func envOr(key, fallback string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return fallback
-}
-
-func parseCSV(value string) []string {
-	parts := strings.Split(value, ",")
-	result := make([]string, 0, len(parts))
-	for _, part := range parts {
-		trimmed := strings.TrimSpace(part)
-		if trimmed != "" {
-			result = append(result, trimmed)
-		}
-	}
-	return result
-}
-
 func allowsAllOrigins(origins []string) bool {
 	for _, origin := range origins {
 		if origin == "*" {
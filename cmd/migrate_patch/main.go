@@ -7,25 +7,19 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/dhawalhost/wardseal/pkg/config"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 )
 
 func main() {
-	dbHost := os.Getenv("DB_HOST")
-	if dbHost == "" {
-		dbHost = "localhost"
+	cfg, err := config.Load(config.Defaults(), config.Options{RequireDB: true})
+	if err != nil {
+		log.Fatalln(err)
 	}
-	connStr := fmt.Sprintf("postgres://%s:%s@%s:5432/%s?sslmode=%s",
-		envOr("DB_USER", "user"),
-		envOr("DB_PASSWORD", "password"),
-		dbHost,
-		envOr("DB_NAME", "identity_platform"),
-		envOr("DB_SSLMODE", "disable"),
-	)
 
-	fmt.Printf("Connecting to %s...\n", connStr)
-	db, err := sqlx.Connect("postgres", connStr)
+	fmt.Printf("Connecting to %s:%d/%s...\n", cfg.DB.Host, cfg.DB.Port, cfg.DB.Name)
+	db, err := sqlx.Connect("postgres", cfg.DB.Connection().DSN())
 	if err != nil {
 		log.Fatalln(err)
 	}
@@ -63,10 +57,3 @@ func main() {
 
 	fmt.Println("All migrations processed!")
 }
-
-func envOr(key, fallback string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return fallback
-}
@@ -1,27 +1,89 @@
 package main
 
 import (
+	"context"
 	"os"
 
 	"github.com/dhawalhost/wardseal/internal/policy"
+	"github.com/dhawalhost/wardseal/pkg/apierr"
+	"github.com/dhawalhost/wardseal/pkg/config"
+	"github.com/dhawalhost/wardseal/pkg/database"
 	"github.com/dhawalhost/wardseal/pkg/logger"
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/dhawalhost/wardseal/pkg/observability"
+	"github.com/dhawalhost/wardseal/pkg/server"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 	"go.uber.org/zap"
-	"go.uber.org/zap/zapcore"
+	"golang.org/x/time/rate"
 )
 
 func main() {
-	log := logger.New(zapcore.DebugLevel)
+	log := logger.NewFromEnv()
 	defer func() { _ = log.Sync() }()
 
-	svc := policy.NewService()
+	defaults := config.Defaults()
+	defaults.HTTP.Addr = ":8083"
+	cfg, err := config.Load(defaults, config.Options{RequireDB: true})
+	if err != nil {
+		log.Error("Failed to load configuration", zap.Error(err))
+		os.Exit(1)
+	}
+	tlsConfig, err := server.NewTLSConfig(cfg.HTTP.TLS.Options())
+	if err != nil {
+		log.Error("Failed to configure TLS", zap.Error(err))
+		os.Exit(1)
+	}
+
+	db, err := database.NewConnection(cfg.DB.Connection())
+	if err != nil {
+		log.Error("Failed to connect to database", zap.Error(err))
+		os.Exit(1)
+	}
+	svc := policy.NewService(policy.NewStore(db))
+
+	// Initialize metrics
+	metrics := observability.NewMetrics()
 
 	router := gin.Default()
+	// Unknown routes and methods answer in the standard JSON error body.
+	apierr.RegisterFallbacks(router)
+
+	// Initialize OpenTelemetry tracing
+	shutdownTracer, err := observability.InitTracer(context.Background(), observability.TracerConfig{
+		ServiceName:    "policysvc",
+		ServiceVersion: "1.0.0",
+		Environment:    cfg.Environment,
+	}, log)
+	if err != nil {
+		log.Error("Failed to initialize tracer", zap.Error(err))
+	}
+	defer func() { _ = shutdownTracer(context.Background()) }()
+
+	// Add observability middleware
+	router.Use(otelgin.Middleware("policysvc"))
+	router.Use(observability.PrometheusMiddleware(metrics, cfg.Metrics.Options()))
+	router.Use(logger.RequestLogger(log))
+
+	// Security Middleware
+	router.Use(middleware.SecurityHeaders(middleware.SecurityHeadersConfig{HSTS: cfg.HTTP.HSTSEnabled}))
+	// Rate limit: 20 req/s, burst 40
+	router.Use(middleware.RateLimitMiddleware(rate.Limit(20), 40))
+	// Reject request bodies over MAX_REQUEST_BODY_BYTES (default 1 MiB).
+	router.Use(middleware.BodyLimit(cfg.HTTP.MaxBodyBytes))
+
+	// Add metrics endpoint
+	router.GET("/metrics", gin.WrapH(observability.PrometheusHandler()))
+
 	policyHandlers := policy.NewHTTPHandler(svc, log)
 	policyHandlers.RegisterRoutes(router)
 
-	log.Info("Policy service starting", zap.String("addr", ":8083"))
-	if err := router.Run(":8083"); err != nil {
+	log.Info("Policy service starting", zap.String("addr", cfg.HTTP.Addr), zap.Bool("tls", tlsConfig != nil))
+	// Report ready on /readyz only once the schema is current, migrating first
+	// when DB_AUTO_MIGRATE is set.
+	gate := server.NewGate(router, database.SchemaCheck(db, database.RequiredSchemaVersion, cfg.DB.MigrationSource()))
+	gate.OnFailure = func(err error) { log.Warn("Service not ready", zap.Error(err)) }
+	if err := server.RunTLS(gate, cfg.HTTP.Addr, tlsConfig, db); err != nil {
 		log.Error("Policy service failed", zap.Error(err))
 		os.Exit(1)
 	}
@@ -4,7 +4,11 @@ import (
 	"os"
 
 	"github.com/dhawalhost/wardseal/internal/provisioning"
+	"github.com/dhawalhost/wardseal/pkg/apierr"
+	"github.com/dhawalhost/wardseal/pkg/config"
 	"github.com/dhawalhost/wardseal/pkg/logger"
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/dhawalhost/wardseal/pkg/server"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -14,14 +18,30 @@ func main() {
 	log := logger.New(zapcore.DebugLevel)
 	defer func() { _ = log.Sync() }()
 
+	defaults := config.Defaults()
+	defaults.HTTP.Addr = ":8084"
+	cfg, err := config.Load(defaults, config.Options{})
+	if err != nil {
+		log.Error("Failed to load configuration", zap.Error(err))
+		os.Exit(1)
+	}
+	tlsConfig, err := server.NewTLSConfig(cfg.HTTP.TLS.Options())
+	if err != nil {
+		log.Error("Failed to configure TLS", zap.Error(err))
+		os.Exit(1)
+	}
+
 	svc := provisioning.NewService()
 
 	router := gin.Default()
+	// Unknown routes and methods answer in the standard JSON error body.
+	apierr.RegisterFallbacks(router)
+	router.Use(middleware.SecurityHeaders(middleware.SecurityHeadersConfig{HSTS: cfg.HTTP.HSTSEnabled}))
 	provHandlers := provisioning.NewHTTPHandler(svc, log)
 	provHandlers.RegisterRoutes(router)
 
-	log.Info("Provisioning service starting", zap.String("addr", ":8084"))
-	if err := router.Run(":8084"); err != nil {
+	log.Info("Provisioning service starting", zap.String("addr", cfg.HTTP.Addr), zap.Bool("tls", tlsConfig != nil))
+	if err := server.RunTLS(server.NewGate(router), cfg.HTTP.Addr, tlsConfig); err != nil {
 		log.Error("Provisioning service failed", zap.Error(err))
 		os.Exit(1)
 	}
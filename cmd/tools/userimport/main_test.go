@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dhawalhost/wardseal/internal/directory"
+)
+
+const sampleCSV = `email,first,last,status
+ada@wardseal.com,Ada,Lovelace,active
+"broken@wardseal.com,Bro"ken,User,active
+grace@wardseal.com,Grace,Hopper
+not-an-email,No,Email,active
+linus@wardseal.com,Linus,Torvalds,retired
+alan@wardseal.com,Alan,Turing,suspended,extra
+`
+
+func TestParseCSVReportsMalformedRowsAndContinues(t *testing.T) {
+	records, rowErrs, err := parseCSV(strings.NewReader(sampleCSV))
+	if err != nil {
+		t.Fatalf("parseCSV returned error: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 valid records, got %+v", records)
+	}
+	if records[0].Email != "ada@wardseal.com" || records[0].Line != 2 {
+		t.Fatalf("unexpected first record: %+v", records[0])
+	}
+	if records[1].Email != "grace@wardseal.com" || records[1].Status != "active" {
+		t.Fatalf("expected missing status to default to active, got %+v", records[1])
+	}
+
+	wantLines := []int{3, 5, 6, 7}
+	if len(rowErrs) != len(wantLines) {
+		t.Fatalf("expected %d row errors, got %v", len(wantLines), rowErrs)
+	}
+	for i, line := range wantLines {
+		if rowErrs[i].Line != line {
+			t.Fatalf("expected row error %d on line %d, got %s", i, line, rowErrs[i])
+		}
+	}
+}
+
+func TestParseLDIF(t *testing.T) {
+	input := `version: 1
+
+# first entry
+dn: uid=ada,ou=people,dc=wardseal,dc=com
+objectClass: inetOrgPerson
+givenName: Ada
+sn: Lovelace
+mail: ada@wardseal.c
+ om
+mail: ada.secondary@wardseal.com
+
+dn: uid=nomail,ou=people,dc=wardseal,dc=com
+givenName: No
+
+dn: uid=grace,ou=people,dc=wardseal,dc=com
+mail:: Z3JhY2VAd2FyZHNlYWwuY29t
+status: Inactive
+`
+	records, rowErrs, err := parseLDIF(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parseLDIF returned error: %v", err)
+	}
+	if len(records) != 2 || len(rowErrs) != 1 {
+		t.Fatalf("expected 2 records and 1 error, got %+v and %v", records, rowErrs)
+	}
+	if records[0].Email != "ada@wardseal.com" || records[0].First != "Ada" || records[0].Last != "Lovelace" {
+		t.Fatalf("unexpected first record: %+v", records[0])
+	}
+	if records[1].Email != "grace@wardseal.com" || records[1].Status != "inactive" {
+		t.Fatalf("unexpected second record: %+v", records[1])
+	}
+	if rowErrs[0].Line != 12 {
+		t.Fatalf("expected the entry without mail to be reported at line 12, got %s", rowErrs[0])
+	}
+}
+
+func TestImporterSkipsExistingAndReportsFailures(t *testing.T) {
+	var batched []directory.User
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/users":
+			if r.URL.Query().Get("email") == "existing@wardseal.com" {
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{"user": map[string]string{"id": "user-1"}})
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == http.MethodPost && r.URL.Path == "/users/batch":
+			var req directory.BatchCreateUsersRequest
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			batched = append(batched, req.Users...)
+			resp := directory.BatchCreateUsersResponse{}
+			for i, u := range req.Users {
+				if u.Email == "taken@wardseal.com" {
+					resp.Results = append(resp.Results, directory.BatchCreateResult{Index: i, Error: "login already exists"})
+					continue
+				}
+				resp.Results = append(resp.Results, directory.BatchCreateResult{Index: i, UserID: "new"})
+			}
+			_ = json.NewEncoder(w).Encode(resp)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	records := []record{
+		{Line: 2, Email: "existing@wardseal.com", Status: "active"},
+		{Line: 3, Email: "new@wardseal.com", Status: "active"},
+		{Line: 4, Email: "taken@wardseal.com", Status: "active"},
+		{Line: 5, Email: "NEW@wardseal.com", Status: "active"},
+		{Line: 6, Email: "suspended@wardseal.com", Status: "suspended"},
+	}
+	imp := &importer{baseURL: srv.URL, tenantID: defaultTenantID, httpClient: srv.Client()}
+	var rep report
+	imp.run(records, 1, true, &rep)
+
+	if len(rep.Skipped) != 1 || rep.Skipped[0] != "existing@wardseal.com" {
+		t.Fatalf("expected existing user to be skipped, got %v", rep.Skipped)
+	}
+	if len(rep.Created) != 2 || rep.Created[0] != "new@wardseal.com" {
+		t.Fatalf("expected two users created, got %v", rep.Created)
+	}
+	if len(rep.Failed) != 2 {
+		t.Fatalf("expected duplicate and rejected rows to fail, got %v", rep.Failed)
+	}
+	if len(batched) != 3 || batched[0].Password == "" {
+		t.Fatalf("expected three users sent with generated passwords, got %+v", batched)
+	}
+	if batched[2].Status != "suspended" {
+		t.Fatalf("expected the row's status to be sent, got %q", batched[2].Status)
+	}
+}
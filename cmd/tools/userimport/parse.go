@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"strings"
+)
+
+// record is one user read from an import file. The directory does not store
+// names yet, so First and Last are parsed but not sent.
+type record struct {
+	Line   int
+	Email  string
+	First  string
+	Last   string
+	Status string
+}
+
+// rowError reports an input row that was skipped.
+type rowError struct {
+	Line int
+	Err  string
+}
+
+func (e rowError) String() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+var validStatuses = map[string]bool{"active": true, "inactive": true, "suspended": true}
+
+// parseCSV reads rows of email, first, last, status. A header row naming
+// those columns is optional; when present the columns may be in any order
+// and status may be omitted. Malformed rows are reported and skipped.
+func parseCSV(r io.Reader) ([]record, []rowError, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	columns := map[string]int{"email": 0, "first": 1, "last": 2, "status": 3}
+	var records []record
+	var rowErrs []rowError
+	first := true
+	for {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				rowErrs = append(rowErrs, rowError{Line: parseErr.StartLine, Err: parseErr.Err.Error()})
+				first = false
+				continue
+			}
+			return nil, nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		if first {
+			first = false
+			if header, ok := csvHeader(fields); ok {
+				columns = header
+				continue
+			}
+		}
+
+		rec := record{Line: line}
+		if len(fields) > len(columns) {
+			rowErrs = append(rowErrs, rowError{Line: line, Err: fmt.Sprintf("expected at most %d fields, got %d", len(columns), len(fields))})
+			continue
+		}
+		for name, idx := range columns {
+			if idx >= len(fields) {
+				continue
+			}
+			value := strings.TrimSpace(fields[idx])
+			switch name {
+			case "email":
+				rec.Email = value
+			case "first":
+				rec.First = value
+			case "last":
+				rec.Last = value
+			case "status":
+				rec.Status = value
+			}
+		}
+		if err := validateRecord(&rec); err != nil {
+			rowErrs = append(rowErrs, rowError{Line: line, Err: err.Error()})
+			continue
+		}
+		records = append(records, rec)
+	}
+	return records, rowErrs, nil
+}
+
+// csvHeader reports whether the row is a header and maps its columns.
+func csvHeader(fields []string) (map[string]int, bool) {
+	columns := make(map[string]int, len(fields))
+	for i, field := range fields {
+		name := strings.ToLower(strings.TrimSpace(field))
+		switch name {
+		case "email", "first", "last", "status":
+			columns[name] = i
+		default:
+			return nil, false
+		}
+	}
+	_, hasEmail := columns["email"]
+	return columns, hasEmail
+}
+
+// parseLDIF reads LDIF content records. It maps mail, givenName, sn and an
+// optional status attribute; other attributes are ignored. Entries without a
+// usable mail attribute are reported and skipped.
+func parseLDIF(r io.Reader) ([]record, []rowError, error) {
+	var records []record
+	var rowErrs []rowError
+
+	var entry map[string]string
+	entryLine := 0
+	var lastAttr string
+	flush := func() {
+		if entry == nil {
+			return
+		}
+		rec := record{
+			Line:   entryLine,
+			Email:  entry["mail"],
+			First:  entry["givenname"],
+			Last:   entry["sn"],
+			Status: entry["status"],
+		}
+		if err := validateRecord(&rec); err != nil {
+			rowErrs = append(rowErrs, rowError{Line: entryLine, Err: err.Error()})
+		} else {
+			records = append(records, rec)
+		}
+		entry = nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		switch {
+		case strings.TrimSpace(text) == "":
+			flush()
+			continue
+		case strings.HasPrefix(text, "#"):
+			continue
+		case strings.HasPrefix(text, " "):
+			// Folded line: continues the previous attribute value.
+			if entry != nil && lastAttr != "" {
+				entry[lastAttr] += text[1:]
+			}
+			continue
+		}
+
+		name, value, ok := strings.Cut(text, ":")
+		if !ok {
+			rowErrs = append(rowErrs, rowError{Line: line, Err: fmt.Sprintf("malformed LDIF line %q", text)})
+			continue
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "version" && entry == nil {
+			continue
+		}
+		if strings.HasPrefix(value, ":") {
+			decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value[1:]))
+			if err != nil {
+				rowErrs = append(rowErrs, rowError{Line: line, Err: fmt.Sprintf("invalid base64 value for %s", name)})
+				continue
+			}
+			value = string(decoded)
+		}
+		if entry == nil {
+			entry = make(map[string]string)
+			entryLine = line
+		}
+		// Keep the first value of multi-valued attributes such as mail.
+		lastAttr = ""
+		if _, exists := entry[name]; !exists {
+			entry[name] = strings.TrimSpace(value)
+			lastAttr = name
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	flush()
+	return records, rowErrs, nil
+}
+
+// validateRecord normalizes a record and checks it against the directory's
+// create-user rules that can be checked offline.
+func validateRecord(rec *record) error {
+	if rec.Email == "" {
+		return errors.New("email is required")
+	}
+	addr, err := mail.ParseAddress(rec.Email)
+	if err != nil || addr.Address != rec.Email {
+		return fmt.Errorf("invalid email %q", rec.Email)
+	}
+	if rec.Status == "" {
+		rec.Status = "active"
+	}
+	rec.Status = strings.ToLower(rec.Status)
+	if !validStatuses[rec.Status] {
+		return fmt.Errorf("invalid status %q for %s", rec.Status, rec.Email)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// opaqueAccessToken is a stored opaque access token. Only a hash of the token
+// is kept, alongside the JSON claims a JWT access token would carry.
+type opaqueAccessToken struct {
+	TokenHash string    `db:"token_hash"`
+	TenantID  string    `db:"tenant_id"`
+	Claims    []byte    `db:"claims"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+// AccessTokenStore persists opaque access tokens.
+type AccessTokenStore interface {
+	Save(ctx context.Context, token opaqueAccessToken) error
+	// Get looks a token up by hash, reporting whether it exists.
+	Get(ctx context.Context, tokenHash string) (opaqueAccessToken, bool, error)
+}
+
+type accessTokenRepo struct {
+	db *sqlx.DB
+}
+
+// NewAccessTokenStore creates a new SQL-backed opaque access token store.
+func NewAccessTokenStore(db *sqlx.DB) AccessTokenStore {
+	return &accessTokenRepo{db: db}
+}
+
+func (r *accessTokenRepo) Save(ctx context.Context, token opaqueAccessToken) error {
+	query := `INSERT INTO access_tokens (token_hash, tenant_id, claims, expires_at) VALUES ($1, $2, $3, $4)`
+	_, err := r.db.ExecContext(ctx, query, token.TokenHash, token.TenantID, token.Claims, token.ExpiresAt)
+	return err
+}
+
+func (r *accessTokenRepo) Get(ctx context.Context, tokenHash string) (opaqueAccessToken, bool, error) {
+	var token opaqueAccessToken
+	query := `SELECT token_hash, tenant_id, claims, expires_at FROM access_tokens WHERE token_hash = $1`
+	if err := r.db.GetContext(ctx, &token, query, tokenHash); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return opaqueAccessToken{}, false, nil
+		}
+		return opaqueAccessToken{}, false, err
+	}
+	return token, true, nil
+}
+
+// accessTokenMemoryStore is an in-memory AccessTokenStore used when no database is configured.
+type accessTokenMemoryStore struct {
+	mu     sync.RWMutex
+	tokens map[string]opaqueAccessToken
+}
+
+func newAccessTokenMemoryStore() *accessTokenMemoryStore {
+	return &accessTokenMemoryStore{tokens: make(map[string]opaqueAccessToken)}
+}
+
+func (s *accessTokenMemoryStore) Save(ctx context.Context, token opaqueAccessToken) error {
+	s.mu.Lock()
+	s.tokens[token.TokenHash] = token
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *accessTokenMemoryStore) Get(ctx context.Context, tokenHash string) (opaqueAccessToken, bool, error) {
+	s.mu.RLock()
+	token, ok := s.tokens[tokenHash]
+	s.mu.RUnlock()
+	return token, ok, nil
+}
@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+	"gopkg.in/go-jose/go-jose.v2"
+)
+
+// issueAccessToken runs the authorization code flow for test-client.
+func issueAccessToken(t *testing.T, as *authService, ctx context.Context) TokenResponse {
+	t.Helper()
+	verifier := "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNO1234567890abcd"
+	authResp, err := as.Authorize(ctx, AuthorizeRequest{
+		ResponseType:  "code",
+		ClientID:      "test-client",
+		RedirectURI:   "https://app.wardseal.com/callback",
+		Scope:         "openid profile",
+		CodeChallenge: pkceChallenge(verifier),
+	})
+	if err != nil {
+		t.Fatalf("authorize error: %v", err)
+	}
+	tokenResp, err := as.Token(ctx, TokenRequest{
+		GrantType:    "authorization_code",
+		Code:         extractCode(t, authResp.RedirectURI),
+		RedirectURI:  "https://app.wardseal.com/callback",
+		ClientID:     "test-client",
+		CodeVerifier: verifier,
+	})
+	if err != nil {
+		t.Fatalf("token error: %v", err)
+	}
+	return tokenResp
+}
+
+func TestJWTAccessTokenVerifiesAgainstJWKS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTestService(t)
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+	tokenResp := issueAccessToken(t, as, ctx)
+
+	// Verify the token the way a resource server would, with the published keys only.
+	router := gin.New()
+	NewHTTPHandler(as, zap.NewNop(), nil).RegisterRoutes(router)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+	var jwks jose.JSONWebKeySet
+	if err := json.Unmarshal(w.Body.Bytes(), &jwks); err != nil {
+		t.Fatalf("failed to decode JWKS: %v", err)
+	}
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(tokenResp.AccessToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		keys := jwks.Key(kid)
+		if len(keys) == 0 {
+			return nil, fmt.Errorf("kid %q is not published", kid)
+		}
+		return keys[0].Key, nil
+	}, jwt.WithValidMethods([]string{"RS256"}), jwt.WithAudience("client-app"))
+	if err != nil || !token.Valid {
+		t.Fatalf("expected the access token to verify against the JWKS: %v", err)
+	}
+	for _, claim := range []string{"sub", "scope", "aud", "tenant", "exp", "iat"} {
+		if _, ok := claims[claim]; !ok {
+			t.Fatalf("expected claim %s in %v", claim, claims)
+		}
+	}
+	if claims["tenant"] != "11111111-1111-1111-1111-111111111111" || claims["scope"] != "openid profile" {
+		t.Fatalf("unexpected claims %v", claims)
+	}
+
+	resp, err := as.Introspect(ctx, IntrospectRequest{Token: tokenResp.AccessToken})
+	if err != nil {
+		t.Fatalf("introspect error: %v", err)
+	}
+	if !resp.Active || resp.TokenType != "access_token" || resp.Scope != "openid profile" {
+		t.Fatalf("expected an active access token, got %+v", resp)
+	}
+}
+
+func TestOpaqueAccessTokenIntrospects(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTestService(t)
+	as.accessTokenFormat = AccessTokenFormatOpaque
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+	tokenResp := issueAccessToken(t, as, ctx)
+
+	if strings.Count(tokenResp.AccessToken, ".") != 0 {
+		t.Fatalf("expected an opaque access token, got %q", tokenResp.AccessToken)
+	}
+
+	resp, err := as.Introspect(ctx, IntrospectRequest{Token: tokenResp.AccessToken})
+	if err != nil {
+		t.Fatalf("introspect error: %v", err)
+	}
+	if !resp.Active || resp.TokenType != "access_token" || resp.Scope != "openid profile" ||
+		resp.Sub != "test-client" || resp.Aud != "client-app" ||
+		resp.TenantID != "11111111-1111-1111-1111-111111111111" || resp.Exp == 0 || resp.Iat == 0 {
+		t.Fatalf("expected an active access token, got %+v", resp)
+	}
+
+	// The refresh token is still told apart from the access token.
+	if resp, _ := as.Introspect(ctx, IntrospectRequest{Token: tokenResp.RefreshToken}); !resp.Active || resp.TokenType != "refresh_token" {
+		t.Fatalf("expected an active refresh token, got %+v", resp)
+	}
+
+	if err := as.Revoke(ctx, RevokeRequest{Token: tokenResp.AccessToken}); err != nil {
+		t.Fatalf("revoke error: %v", err)
+	}
+	if resp, _ := as.Introspect(ctx, IntrospectRequest{Token: tokenResp.AccessToken}); resp.Active {
+		t.Fatalf("expected a revoked opaque token to be inactive")
+	}
+	if resp, _ := as.Introspect(ctx, IntrospectRequest{Token: "not-a-token"}); resp.Active {
+		t.Fatalf("expected an unknown opaque token to be inactive")
+	}
+}
+
+func TestUnknownAccessTokenFormatRejected(t *testing.T) {
+	_, err := NewService(Config{
+		BaseURL:             "http://wardseal.com",
+		DirectoryServiceURL: "http://dirsvc",
+		AccessTokenFormat:   "paseto",
+	})
+	if err == nil {
+		t.Fatalf("expected an unknown access token format to be rejected")
+	}
+}
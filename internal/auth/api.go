@@ -4,12 +4,14 @@ import (
 	"errors"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/dhawalhost/wardseal/internal/featureflag"
 	"github.com/dhawalhost/wardseal/pkg/middleware"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
-	"github.com/pquerna/otp/totp"
 	"go.uber.org/zap"
 )
 
@@ -19,8 +21,15 @@ const (
 	RefreshTokenCookie  = "wardseal_refresh_token"
 	CookieMaxAge        = 3600          // 1 hour for access token
 	RefreshCookieMaxAge = 7 * 24 * 3600 // 7 days for refresh token
+	// AuthorizeSessionCookie carries the session to the authorize and
+	// consent pages. Unlike AccessTokenCookie it is SameSite=Lax, so it is
+	// sent when a client on another site navigates the browser there.
+	AuthorizeSessionCookie = "wardseal_authorize_session"
 )
 
+// authorizeSessionCookiePaths are the pages AuthorizeSessionCookie is sent to.
+var authorizeSessionCookiePaths = []string{"/oauth2/authorize", "/oauth/consent"}
+
 // setAuthCookies sets httpOnly secure cookies for authentication tokens
 func setAuthCookies(c *gin.Context, accessToken, refreshToken string) {
 	secure := os.Getenv("ENVIRONMENT") == "production"
@@ -34,16 +43,36 @@ func setAuthCookies(c *gin.Context, accessToken, refreshToken string) {
 	if refreshToken != "" {
 		c.SetCookie(RefreshTokenCookie, refreshToken, RefreshCookieMaxAge, "/oauth2/token", "", secure, true)
 	}
+
+	// Authorize session cookie (1 hour), sent on cross-site navigations
+	c.SetSameSite(http.SameSiteLaxMode)
+	for _, path := range authorizeSessionCookiePaths {
+		c.SetCookie(AuthorizeSessionCookie, accessToken, CookieMaxAge, path, "", secure, true)
+	}
 }
 
 // clearAuthCookies removes authentication cookies
 func clearAuthCookies(c *gin.Context) {
 	c.SetCookie(AccessTokenCookie, "", -1, "/", "", false, true)
 	c.SetCookie(RefreshTokenCookie, "", -1, "/oauth2/token", "", false, true)
+	for _, path := range authorizeSessionCookiePaths {
+		c.SetCookie(AuthorizeSessionCookie, "", -1, path, "", false, true)
+	}
+}
+
+// authorizeSession returns the session for the authorize and consent pages.
+// A client on another site sends the browser there with a cross-site
+// navigation, which carries AuthorizeSessionCookie but not the SameSite=Strict
+// AccessTokenCookie.
+func authorizeSession(c *gin.Context) string {
+	if token, err := c.Cookie(AuthorizeSessionCookie); err == nil && token != "" {
+		return token
+	}
+	token, _ := c.Cookie(AccessTokenCookie)
+	return token
 }
 
 // getTokenFromCookieOrHeader tries to get token from cookie first, then header
-// nolint:unused // Reserved for future use
 func getTokenFromCookieOrHeader(c *gin.Context) string {
 	// Try cookie first
 	if token, err := c.Cookie(AccessTokenCookie); err == nil && token != "" {
@@ -59,34 +88,166 @@ func getTokenFromCookieOrHeader(c *gin.Context) string {
 
 // HTTPHandler represents the HTTP API handlers for the auth service.
 type HTTPHandler struct {
-	svc               Service
-	logger            *zap.Logger
-	validate          *validator.Validate
-	loginAttemptStore LoginAttemptStore
+	svc           Service
+	logger        *zap.Logger
+	validate      *validator.Validate
+	loginThrottle *LoginThrottle
+	// credentialLimiter guards endpoints that accept credentials or tokens.
+	credentialLimiter gin.HandlerFunc
+	// tenantLookup, when set, rejects requests for unknown or suspended tenants.
+	tenantLookup middleware.TenantLookup
+	// features, when set, gates MFA enrollment on the tenant's mfa feature.
+	features featureflag.Checker
+	// authorizer guards the tenant administration routes. They are refused
+	// when it is nil.
+	authorizer *middleware.Authorizer
+}
+
+// NewHTTPHandler creates a new HTTPHandler. loginThrottle may be nil to disable
+// brute-force protection (e.g. in tests).
+func NewHTTPHandler(svc Service, logger *zap.Logger, loginThrottle *LoginThrottle) *HTTPHandler {
+	return &HTTPHandler{svc: svc, logger: logger, validate: validator.New(), loginThrottle: loginThrottle}
+}
+
+// UseCredentialRateLimiter rate limits the login, token and introspection
+// endpoints with mw (typically middleware.RateLimiter). Call before RegisterRoutes.
+func (h *HTTPHandler) UseCredentialRateLimiter(mw gin.HandlerFunc) {
+	h.credentialLimiter = mw
+}
+
+// UseTenantLookup makes the tenant-scoped routes reject tenants lookup
+// reports as not active, such as suspended ones. Call before RegisterRoutes.
+func (h *HTTPHandler) UseTenantLookup(lookup middleware.TenantLookup) {
+	h.tenantLookup = lookup
+}
+
+// UseFeatureFlags makes MFA enrollment require the tenant's mfa feature.
+// Logins still complete MFA for users who already enrolled. Call before
+// RegisterRoutes.
+func (h *HTTPHandler) UseFeatureFlags(features featureflag.Checker) {
+	h.features = features
+}
+
+// UsePermissions makes the tenant administration routes, such as session
+// management, check the signed-in user's permissions with checker (typically
+// the rbac service). Without it those routes answer 403. Call before
+// RegisterRoutes.
+func (h *HTTPHandler) UsePermissions(checker middleware.PermissionChecker) {
+	h.authorizer = middleware.NewAuthorizer(checker)
 }
 
-// NewHTTPHandler creates a new HTTPHandler.
-func NewHTTPHandler(svc Service, logger *zap.Logger, loginAttemptStore LoginAttemptStore) *HTTPHandler {
-	return &HTTPHandler{svc: svc, logger: logger, validate: validator.New(), loginAttemptStore: loginAttemptStore}
+// requirePermission returns the middleware letting only signed-in users
+// holding resource:action through. It must run after requireSession.
+func (h *HTTPHandler) requirePermission(resource, action string) gin.HandlerFunc {
+	if h.authorizer == nil {
+		return func(c *gin.Context) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "permission denied"})
+		}
+	}
+	return h.authorizer.RequirePermission(resource, action)
+}
+
+// requireFeature returns the middleware gating routes on feature, a no-op
+// when no feature flags are configured.
+func (h *HTTPHandler) requireFeature(feature string) gin.HandlerFunc {
+	if h.features == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return featureflag.Require(h.features, feature)
+}
+
+// requireSession rejects requests without a valid session token for the
+// tenant, taken from the session cookie or a bearer header, and records the
+// signed-in user with middleware.SetSubject. It must run after the tenant
+// extractor.
+func (h *HTTPHandler) requireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenantID, err := middleware.TenantIDFromGinContext(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "missing tenant context"})
+			return
+		}
+		subject, err := h.svc.SessionSubject(c.Request.Context(), tenantID, getTokenFromCookieOrHeader(c))
+		if err != nil {
+			svcErr := &Error{}
+			if errors.As(err, &svcErr) {
+				h.respondOAuthError(c, svcErr)
+			} else {
+				h.logger.Error("Failed to check session", zap.Error(err))
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check session"})
+			}
+			c.Abort()
+			return
+		}
+		middleware.SetSubject(c, subject)
+		c.Next()
+	}
+}
+
+// tenantExtractor returns the tenant middleware for tenant-scoped routes.
+func (h *HTTPHandler) tenantExtractor() gin.HandlerFunc {
+	return middleware.TenantExtractor(middleware.TenantConfig{Lookup: h.tenantLookup})
 }
 
 // RegisterRoutes registers the authentication routes.
 func (h *HTTPHandler) RegisterRoutes(router *gin.Engine) {
 	tenantProtected := router.Group("/")
-	tenantProtected.Use(middleware.TenantExtractor(middleware.TenantConfig{}))
+	tenantProtected.Use(h.tenantExtractor())
 
 	// Public routes (but still tenant-aware)
 	router.POST("/api/v1/signup", h.signup)
 	router.POST("/login/lookup", h.lookupUser) // Public lookup for tenant discovery
+	router.GET("/auth/verify-email", h.verifyEmailPage)
+	router.POST("/auth/verify-email", h.verifyEmail)
+	router.GET("/auth/password/reset", h.resetPasswordPage)
+	router.POST("/auth/password/reset", h.resetPassword)
+
+	limited := tenantProtected.Group("/")
+	if h.credentialLimiter != nil {
+		limited.Use(h.credentialLimiter)
+	}
 
-	tenantProtected.POST("/login", h.login)
-	tenantProtected.POST("/login/mfa", h.completeMFALogin)
+	limited.POST("/login", h.login)
+	limited.POST("/login/mfa", h.completeMFALogin)
+	limited.POST("/auth/password/forgot", h.forgotPassword)
 	tenantProtected.POST("/logout", h.logout)
+	tenantProtected.GET("/oauth/logout", h.endSession)
+	tenantProtected.GET("/connect/endsession", h.endSession)
 	tenantProtected.GET("/oauth2/authorize", h.authorize)
-	tenantProtected.POST("/oauth2/token", h.token)
-	tenantProtected.POST("/oauth2/introspect", h.introspect)
+	tenantProtected.GET("/oauth/consent", h.consentPage)
+	tenantProtected.POST("/oauth/consent", h.submitConsent)
+	limited.POST("/oauth2/token", h.token)
+	limited.POST("/oauth/device_authorization", h.deviceAuthorization)
+	tenantProtected.GET("/device", h.devicePage)
+	tenantProtected.POST("/device", h.submitDeviceApproval)
+	limited.POST("/oauth2/introspect", h.introspect)
 	tenantProtected.POST("/oauth2/revoke", h.revoke)
 	router.GET("/.well-known/jwks.json", h.jwks)
+	tenantProtected.GET("/api/v1/auth/events", h.requireSession(), h.requirePermission("auth_events", "read"), h.listAuthEvents)
+	emailVerificationPolicy := tenantProtected.Group("/api/v1/email-verification/policy", h.requireSession())
+	{
+		emailVerificationPolicy.GET("", h.requirePermission("email_verification_policy", "read"), h.getEmailVerificationPolicy)
+		emailVerificationPolicy.PUT("", h.requirePermission("email_verification_policy", "update"), h.updateEmailVerificationPolicy)
+	}
+
+	// Impersonation routes
+	impersonationGroup := limited.Group("/api/v1/impersonation")
+	{
+		impersonationGroup.POST("", h.startImpersonation)
+		impersonationGroup.POST("/stop", h.stopImpersonation)
+	}
+	impersonationPolicy := tenantProtected.Group("/api/v1/impersonation/policy", h.requireSession())
+	{
+		impersonationPolicy.GET("", h.requirePermission("impersonation_policy", "read"), h.getImpersonationPolicy)
+		impersonationPolicy.PUT("", h.requirePermission("impersonation_policy", "update"), h.updateImpersonationPolicy)
+	}
+
+	// Session routes
+	sessionGroup := tenantProtected.Group("/api/v1/sessions", h.requireSession())
+	{
+		sessionGroup.GET("", h.requirePermission("sessions", "read"), h.listSessions)
+		sessionGroup.DELETE("/:id", h.requirePermission("sessions", "delete"), h.revokeSession)
+	}
 
 	// Device routes
 	deviceGroup := tenantProtected.Group("/api/v1/devices")
@@ -105,6 +266,8 @@ func (h *HTTPHandler) RegisterRoutes(router *gin.Engine) {
 
 	// Social Login
 	tenantProtected.POST("/social/login", h.socialLogin)
+	tenantProtected.GET("/auth/sso/:provider/start", h.startSocialLogin)
+	router.GET("/auth/sso/:provider/callback", h.socialLoginCallback) // Tenant comes from the state
 
 	// MFA WebAuthn
 	h.registerWebAuthnRoutes(tenantProtected)
@@ -112,9 +275,15 @@ func (h *HTTPHandler) RegisterRoutes(router *gin.Engine) {
 	// MFA TOTP
 	h.RegisterTOTPRoutes(tenantProtected.Group("/api/v1"))
 
+	// SAML Service Provider
+	router.GET("/saml/metadata", h.samlMetadata)
+	tenantProtected.GET("/saml/login", h.samlLogin)
+	router.POST("/saml/acs", h.samlACS)
+
 	if samlProvider := h.svc.SAML(); samlProvider != nil {
 		samlHandler := gin.WrapH(samlProvider)
-		router.GET("/saml/metadata", samlHandler)
+		// The IdP serves its metadata at "/metadata" relative to its own mount point.
+		router.GET("/saml/idp/metadata", gin.WrapH(http.StripPrefix("/saml/idp", samlProvider)))
 		router.POST("/saml/sso", samlHandler)
 		router.GET("/saml/idp-init", samlHandler) // IdP Initiated endpoint
 	}
@@ -137,16 +306,24 @@ func (h *HTTPHandler) login(c *gin.Context) {
 	deviceID := c.Request.Header.Get("X-Device-ID")
 	ip := c.ClientIP()
 	tenantID := c.GetHeader("X-Tenant-ID")
-
-	// Check account lockout
-	if h.loginAttemptStore != nil && tenantID != "" {
-		locked, lockedUntil, _ := h.loginAttemptStore.IsLocked(c.Request.Context(), tenantID, req.Username)
+	throttled := h.loginThrottle != nil && tenantID != ""
+	start := time.Now()
+
+	// Check account and source IP lockout. The response is the same whether
+	// or not the account exists, so it cannot be used to enumerate users.
+	if throttled {
+		locked, lockedUntil, err := h.loginThrottle.Check(c.Request.Context(), tenantID, req.Username, ip)
+		if err != nil {
+			h.logger.Error("Failed to check login lockout", zap.Error(err))
+		}
 		if locked {
-			h.logger.Warn("Login attempt for locked account", zap.String("username", req.Username))
+			h.logger.Warn("Login attempt while locked out", zap.String("username", req.Username), zap.String("ip", ip))
+			h.recordAuthEvent(c, AuthEvent{EventType: AuthEventLoginLocked, Subject: req.Username, Outcome: AuthOutcomeFailure, Reason: "account_locked"})
+			c.Header("Retry-After", strconv.Itoa(int(time.Until(lockedUntil).Seconds())+1))
 			c.JSON(http.StatusTooManyRequests, gin.H{
 				"error":             "account_locked",
 				"error_description": "Too many failed login attempts. Please try again later.",
-				"locked_until":      lockedUntil.Format("2006-01-02T15:04:05Z07:00"),
+				"locked_until":      lockedUntil.Format(time.RFC3339),
 			})
 			return
 		}
@@ -157,44 +334,54 @@ func (h *HTTPHandler) login(c *gin.Context) {
 	token, err := h.svc.Login(c.Request.Context(), req.Username, req.Password, deviceID, userAgent, ip, clientOSVersion)
 	if err != nil {
 		h.logger.Error("Login failed", zap.Error(err))
-
-		// Record failed attempt
-		if h.loginAttemptStore != nil && tenantID != "" {
-			_ = h.loginAttemptStore.RecordAttempt(c.Request.Context(), tenantID, req.Username, ip, false)
-			failures, _ := h.loginAttemptStore.GetRecentFailures(c.Request.Context(), tenantID, req.Username)
-			if failures >= MaxFailedAttempts {
-				_ = h.loginAttemptStore.LockAccount(c.Request.Context(), tenantID, req.Username)
-				h.logger.Warn("Account locked due to too many failed attempts", zap.String("username", req.Username))
+		h.recordAuthEvent(c, AuthEvent{EventType: AuthEventLoginFailed, Subject: req.Username, Outcome: AuthOutcomeFailure, Reason: authFailureReason(err)})
+
+		// Record failed attempt and slow the caller down. A correct password
+		// on an unverified account is not a guessing attempt.
+		if throttled && !errors.Is(err, ErrEmailNotVerified) {
+			delay, recordErr := h.loginThrottle.RecordFailure(c.Request.Context(), tenantID, req.Username, ip)
+			if recordErr != nil {
+				h.logger.Error("Failed to record login attempt", zap.Error(recordErr))
 			}
+			h.loginThrottle.Delay(c.Request.Context(), start, delay)
 		}
 
 		if errors.Is(err, ErrInvalidCredentials) {
 			h.respondOAuthError(c, ErrInvalidCredentials)
+		} else if errors.Is(err, ErrEmailNotVerified) {
+			c.JSON(http.StatusForbidden, gin.H{"error": ErrEmailNotVerified.Code, "error_description": ErrEmailNotVerified.Message})
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		}
 		return
 	}
 
-	// Record successful attempt and clear any lockout
-	if h.loginAttemptStore != nil && tenantID != "" {
-		_ = h.loginAttemptStore.RecordAttempt(c.Request.Context(), tenantID, req.Username, ip, true)
-		_ = h.loginAttemptStore.UnlockAccount(c.Request.Context(), tenantID, req.Username)
+	// Require the second factor before handing out a session token. The
+	// account's failures are only reset once it is passed, so fresh
+	// challenges do not buy more guesses.
+	challenge, err := h.svc.MFAChallenge(c.Request.Context(), req.Username, token)
+	if err != nil {
+		h.logger.Error("Failed to check MFA enrollment", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check MFA enrollment"})
+		return
 	}
-
-	// Check if user has TOTP enabled
-	if h.svc.TOTP() != nil && tenantID != "" {
-		totpSecret, _ := h.svc.TOTP().GetByIdentity(c.Request.Context(), tenantID, req.Username)
-		if totpSecret != nil && totpSecret.Verified {
-			// MFA required - return pending token and mfa_required flag
-			c.JSON(http.StatusOK, gin.H{
-				"mfa_required":  true,
-				"pending_token": token,
-				"user_id":       req.Username,
-			})
-			return
+	if challenge == "" && throttled {
+		if err := h.loginThrottle.RecordSuccess(c.Request.Context(), tenantID, req.Username, ip); err != nil {
+			h.logger.Error("Failed to record login attempt", zap.Error(err))
 		}
 	}
+	if challenge != "" {
+		h.recordAuthEvent(c, AuthEvent{EventType: AuthEventLoginSucceeded, Subject: req.Username, Reason: "mfa_required"})
+		// MFA required - return a challenge token to be exchanged at /login/mfa
+		c.JSON(http.StatusOK, gin.H{
+			"mfa_required":  true,
+			"pending_token": challenge,
+			"user_id":       req.Username,
+		})
+		return
+	}
+
+	h.recordAuthEvent(c, AuthEvent{EventType: AuthEventLoginSucceeded, Subject: req.Username})
 
 	// Set httpOnly cookies for session security
 	setAuthCookies(c, token, "")
@@ -218,6 +405,10 @@ func (h *HTTPHandler) lookupUser(c *gin.Context) {
 
 	result, err := h.svc.LookupUser(c.Request.Context(), tenantID, req.Email)
 	if err != nil {
+		if errors.Is(err, ErrTenantSelectionRequired) {
+			c.JSON(http.StatusConflict, gin.H{"error": ErrTenantSelectionRequired.Code, "error_description": ErrTenantSelectionRequired.Message})
+			return
+		}
 		// Avoid enumerating users aggressively if preferred, but for enterprise login, exact errors are often helpful.
 		// For security, we might want generic "not found" or similar if we want to hide existence.
 		// But here we return 404 if not found.
@@ -233,7 +424,8 @@ func (h *HTTPHandler) lookupUser(c *gin.Context) {
 type MFALoginRequest struct {
 	PendingToken string `json:"pending_token" binding:"required"`
 	TOTPCode     string `json:"totp_code" binding:"required"`
-	UserID       string `json:"user_id" binding:"required"`
+	// UserID is accepted for backwards compatibility; the account is taken from the pending token.
+	UserID string `json:"user_id"`
 }
 
 func (h *HTTPHandler) completeMFALogin(c *gin.Context) {
@@ -243,29 +435,75 @@ func (h *HTTPHandler) completeMFALogin(c *gin.Context) {
 		return
 	}
 
+	ctx := c.Request.Context()
 	tenantID := c.GetHeader("X-Tenant-ID")
-	if tenantID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Tenant-ID header required"})
+	ip := c.ClientIP()
+	start := time.Now()
+	pending, err := h.svc.ParseMFAChallenge(ctx, req.PendingToken)
+	if err != nil {
+		h.mfaLoginFailed(c, "", err)
 		return
 	}
+	throttled := h.loginThrottle != nil && tenantID != ""
+	if throttled {
+		locked, lockedUntil, err := h.loginThrottle.Check(ctx, tenantID, pending.Login, ip)
+		if err != nil {
+			h.logger.Error("Failed to check login lockout", zap.Error(err))
+		}
+		if locked {
+			h.recordAuthEvent(c, AuthEvent{EventType: AuthEventMFAFailed, Subject: pending.Login, Outcome: AuthOutcomeFailure, Reason: "account_locked"})
+			c.Header("Retry-After", strconv.Itoa(int(time.Until(lockedUntil).Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":             "account_locked",
+				"error_description": "Too many failed login attempts. Please try again later.",
+				"locked_until":      lockedUntil.Format(time.RFC3339),
+			})
+			return
+		}
+	}
 
-	// Validate TOTP
-	totpSecret, err := h.svc.TOTP().GetByIdentity(c.Request.Context(), tenantID, req.UserID)
-	if err != nil || totpSecret == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "TOTP not configured"})
+	token, err := h.svc.CompleteMFALogin(ctx, req.PendingToken, req.TOTPCode)
+	if err != nil {
+		// A wrong code counts against the account like a wrong password, and
+		// enough of them end the challenge.
+		if throttled && errors.Is(err, ErrInvalidTOTPCode) {
+			delay, failures, recordErr := h.loginThrottle.RecordMFAFailure(ctx, tenantID, pending.Login, ip)
+			if recordErr != nil {
+				h.logger.Error("Failed to record login attempt", zap.Error(recordErr))
+			}
+			if failures >= MaxMFAChallengeFailures {
+				if err := h.svc.RevokeMFAChallenge(ctx, pending.ChallengeID); err != nil {
+					h.logger.Error("Failed to revoke MFA challenge", zap.Error(err))
+				}
+			}
+			h.loginThrottle.Delay(ctx, start, delay)
+		}
+		h.mfaLoginFailed(c, pending.Login, err)
 		return
 	}
 
-	if !totp.Validate(req.TOTPCode, totpSecret.Secret) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid TOTP code"})
-		return
+	if throttled {
+		if err := h.loginThrottle.RecordSuccess(ctx, tenantID, pending.Login, ip); err != nil {
+			h.logger.Error("Failed to record login attempt", zap.Error(err))
+		}
 	}
+	h.recordAuthEvent(c, AuthEvent{EventType: AuthEventMFASucceeded, Subject: pending.Login})
 
 	// Set httpOnly cookies for session security
-	setAuthCookies(c, req.PendingToken, "")
+	setAuthCookies(c, token, "")
 
-	// TOTP verified, return the pending token as the final token
-	c.JSON(http.StatusOK, LoginResponse{Token: req.PendingToken})
+	c.JSON(http.StatusOK, LoginResponse{Token: token})
+}
+
+func (h *HTTPHandler) mfaLoginFailed(c *gin.Context, login string, err error) {
+	h.logger.Warn("MFA login failed", zap.Error(err))
+	h.recordAuthEvent(c, AuthEvent{EventType: AuthEventMFAFailed, Subject: login, Outcome: AuthOutcomeFailure, Reason: authFailureReason(err)})
+	svcErr := &Error{}
+	if errors.As(err, &svcErr) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": svcErr.Code, "error_description": svcErr.Message})
+	} else {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to complete MFA login"})
+	}
 }
 
 func (h *HTTPHandler) logout(c *gin.Context) {
@@ -274,6 +512,38 @@ func (h *HTTPHandler) logout(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "logged out successfully"})
 }
 
+// endSession handles RP-initiated logout (GET /oauth/logout, /connect/endsession).
+func (h *HTTPHandler) endSession(c *gin.Context) {
+	var req EndSessionRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	redirectURI, err := h.svc.EndSession(c.Request.Context(), req)
+	if err != nil {
+		h.logger.Error("End session failed", zap.Error(err))
+		svcErr := &Error{}
+		if errors.As(err, &svcErr) {
+			h.respondOAuthError(c, svcErr)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	clearAuthCookies(c)
+	if redirectURI != "" {
+		c.Redirect(http.StatusFound, redirectURI)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "logged out successfully"})
+}
+
 func (h *HTTPHandler) authorize(c *gin.Context) {
 	var req AuthorizeRequest
 	if err := c.ShouldBindQuery(&req); err != nil {
@@ -288,6 +558,8 @@ func (h *HTTPHandler) authorize(c *gin.Context) {
 		return
 	}
 
+	req.SessionToken = authorizeSession(c)
+
 	resp, err := h.svc.Authorize(c.Request.Context(), req)
 	if err != nil {
 		h.logger.Error("Authorize failed", zap.Error(err))
@@ -300,6 +572,10 @@ func (h *HTTPHandler) authorize(c *gin.Context) {
 		return
 	}
 
+	if resp.ConsentRequired {
+		c.Redirect(http.StatusFound, "/oauth/consent?"+c.Request.URL.RawQuery)
+		return
+	}
 	c.Redirect(http.StatusFound, resp.RedirectURI)
 }
 
@@ -318,9 +594,17 @@ func (h *HTTPHandler) token(c *gin.Context) {
 		return
 	}
 
+	req.ClientIP = c.ClientIP()
+	req.UserAgent = c.Request.UserAgent()
+	var ok bool
+	if req.DPoPProof, ok = h.dpopProof(c); !ok {
+		return
+	}
+
 	resp, err := h.svc.Token(c.Request.Context(), req)
 	if err != nil {
 		h.logger.Error("Token generation failed", zap.Error(err))
+		h.recordAuthEvent(c, AuthEvent{EventType: AuthEventTokenFailed, ClientID: req.ClientID, Outcome: AuthOutcomeFailure, Reason: authFailureReason(err)})
 		svcErr := &Error{}
 		if errors.As(err, &svcErr) {
 			h.respondOAuthError(c, svcErr)
@@ -330,6 +614,8 @@ func (h *HTTPHandler) token(c *gin.Context) {
 		return
 	}
 
+	h.recordAuthEvent(c, AuthEvent{EventType: AuthEventTokenIssued, ClientID: req.ClientID})
+
 	c.JSON(http.StatusOK, resp)
 }
 
@@ -353,13 +639,49 @@ func (h *HTTPHandler) introspect(c *gin.Context) {
 		return
 	}
 
+	if err := h.svc.AuthenticateIntrospectionCaller(c.Request.Context(), h.introspectionCaller(c)); err != nil {
+		h.recordAuthEvent(c, AuthEvent{EventType: AuthEventTokenIntrospected, Outcome: AuthOutcomeFailure, Reason: authFailureReason(err)})
+		svcErr := &Error{}
+		if errors.As(err, &svcErr) {
+			c.Header("WWW-Authenticate", `Basic realm="introspection"`)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": svcErr.Code, "error_description": svcErr.Message})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.PresenterIP == "" {
+		req.PresenterIP = c.ClientIP()
+	}
+	if req.PresenterUserAgent == "" {
+		req.PresenterUserAgent = c.Request.UserAgent()
+	}
+	var ok bool
+	if req.DPoPProof, ok = h.dpopProof(c); !ok {
+		return
+	}
+
 	resp, err := h.svc.Introspect(c.Request.Context(), req)
 	if err != nil {
 		h.logger.Error("Token introspection failed", zap.Error(err))
+		h.recordAuthEvent(c, AuthEvent{EventType: AuthEventTokenIntrospected, Outcome: AuthOutcomeFailure, Reason: authFailureReason(err)})
+		svcErr := &Error{}
+		if errors.As(err, &svcErr) {
+			h.respondOAuthError(c, svcErr)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	introspected := AuthEvent{EventType: AuthEventTokenIntrospected, Subject: resp.Sub, ClientID: resp.ClientID}
+	if !resp.Active {
+		introspected.Outcome = AuthOutcomeFailure
+		introspected.Reason = "inactive_token"
+	}
+	h.recordAuthEvent(c, introspected)
+
 	c.JSON(http.StatusOK, resp)
 }
 
@@ -379,17 +701,38 @@ func (h *HTTPHandler) revoke(c *gin.Context) {
 
 	if err := h.svc.Revoke(c.Request.Context(), req); err != nil {
 		h.logger.Error("Token revocation failed", zap.Error(err))
+		h.recordAuthEvent(c, AuthEvent{EventType: AuthEventTokenRevoked, Outcome: AuthOutcomeFailure, Reason: authFailureReason(err)})
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	h.recordAuthEvent(c, AuthEvent{EventType: AuthEventTokenRevoked})
+
 	// RFC 7009: The authorization server responds with HTTP status code 200 for success
 	c.Status(http.StatusOK)
 }
 
+// dpopProof returns the request's DPoP proof, if any, and sends the nonce
+// proofs must carry when nonces are required. Requests with more than one
+// proof are rejected.
+func (h *HTTPHandler) dpopProof(c *gin.Context) (string, bool) {
+	if nonce := h.svc.DPoPNonce(); nonce != "" {
+		c.Header(DPoPNonceHeader, nonce)
+	}
+	proofs := c.Request.Header.Values(DPoPHeader)
+	if len(proofs) > 1 {
+		h.respondOAuthError(c, newInvalidDPoPProofError("only one DPoP proof may be sent"))
+		return "", false
+	}
+	if len(proofs) == 0 {
+		return "", true
+	}
+	return proofs[0], true
+}
+
 func (h *HTTPHandler) respondOAuthError(c *gin.Context, err *Error) {
 	status := http.StatusBadRequest
-	if err.Code == ErrInvalidCredentials.Code {
+	if err.Code == ErrInvalidCredentials.Code || err.Code == ErrTokenBindingMismatch.Code || err.Code == ErrLoginRequired.Code {
 		status = http.StatusUnauthorized
 	}
 	c.JSON(status, gin.H{
@@ -416,6 +759,11 @@ func (h *HTTPHandler) signup(c *gin.Context) {
 	token, tenantID, err := h.svc.SignUp(c.Request.Context(), req.Email, req.Password, req.CompanyName)
 	if err != nil {
 		h.logger.Error("Signup failed", zap.Error(err))
+		svcErr := &Error{}
+		if errors.As(err, &svcErr) {
+			h.respondOAuthError(c, svcErr)
+			return
+		}
 		if strings.Contains(err.Error(), "conflict") || strings.Contains(err.Error(), "exists") {
 			// If user/tenant exists (unlikely given UUID, but maybe email collision in global sense?)
 			// Currently our logic creates new tenant always. Directory service `CreateUser` might fail if email exists in that tenant?
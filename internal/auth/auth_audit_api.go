@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// recordAuthEvent stamps the event with the request's tenant, IP and user
+// agent and stores it. Audit failures are logged but never fail the request.
+func (h *HTTPHandler) recordAuthEvent(c *gin.Context, event AuthEvent) {
+	tenantID, err := middleware.TenantIDFromGinContext(c)
+	if err != nil {
+		return
+	}
+	event.TenantID = tenantID
+	event.IPAddress = c.ClientIP()
+	event.UserAgent = c.Request.UserAgent()
+	event.CreatedAt = time.Now().UTC()
+	if event.Outcome == "" {
+		event.Outcome = AuthOutcomeSuccess
+	}
+	if err := h.svc.AuthAudit().RecordAuthEvent(c.Request.Context(), event); err != nil {
+		h.logger.Error("Failed to record auth audit event", zap.String("event_type", event.EventType), zap.Error(err))
+	}
+}
+
+// authFailureReason maps an error to a code safe to persist in the audit trail.
+func authFailureReason(err error) string {
+	svcErr := &Error{}
+	if errors.As(err, &svcErr) {
+		return svcErr.Code
+	}
+	return "server_error"
+}
+
+// listAuthEvents handles GET /api/v1/auth/events?subject=&since=&until=&limit=
+func (h *HTTPHandler) listAuthEvents(c *gin.Context) {
+	tenantID, err := middleware.TenantIDFromGinContext(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing tenant context"})
+		return
+	}
+
+	query := AuthEventQuery{TenantID: tenantID, Subject: c.Query("subject")}
+	if v := c.Query("since"); v != "" {
+		if query.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be an RFC 3339 timestamp"})
+			return
+		}
+	}
+	if v := c.Query("until"); v != "" {
+		if query.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "until must be an RFC 3339 timestamp"})
+			return
+		}
+	}
+	if v := c.Query("limit"); v != "" {
+		if query.Limit, err = strconv.Atoi(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be an integer"})
+			return
+		}
+	}
+
+	events, err := h.svc.AuthAudit().ListAuthEvents(c.Request.Context(), query)
+	if err != nil {
+		h.logger.Error("Failed to list auth audit events", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list auth events"})
+		return
+	}
+	if events == nil {
+		events = []AuthEvent{}
+	}
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// Authentication audit event types.
+const (
+	AuthEventLoginSucceeded    = "login_succeeded"
+	AuthEventLoginFailed       = "login_failed"
+	AuthEventLoginLocked       = "login_locked"
+	AuthEventMFASucceeded      = "mfa_succeeded"
+	AuthEventMFAFailed         = "mfa_failed"
+	AuthEventTokenIssued       = "token_issued"
+	AuthEventTokenFailed       = "token_failed"
+	AuthEventTokenIntrospected = "token_introspected"
+	AuthEventTokenRevoked      = "token_revoked"
+	AuthEventSessionRevoked    = "session_revoked"
+	// Impersonation events name the impersonated user as Subject and the
+	// administrator as Actor.
+	AuthEventImpersonationStarted = "impersonation_started"
+	AuthEventImpersonationStopped = "impersonation_stopped"
+	AuthEventImpersonationDenied  = "impersonation_denied"
+)
+
+// Authentication audit outcomes.
+const (
+	AuthOutcomeSuccess = "success"
+	AuthOutcomeFailure = "failure"
+)
+
+// DefaultAuthEventLimit caps ListAuthEvents results when no limit is given.
+const DefaultAuthEventLimit = 100
+
+// AuthEvent is an authentication audit record. It must never carry passwords,
+// codes or tokens; Reason holds a short machine-readable code (e.g. the OAuth
+// error code), never a free-form error message.
+type AuthEvent struct {
+	ID        string    `json:"id" db:"id"`
+	TenantID  string    `json:"tenant_id" db:"tenant_id"`
+	EventType string    `json:"event_type" db:"event_type"`
+	Subject   string    `json:"subject,omitempty" db:"subject"`
+	Actor     string    `json:"actor,omitempty" db:"actor"`
+	ClientID  string    `json:"client_id,omitempty" db:"client_id"`
+	IPAddress string    `json:"ip_address,omitempty" db:"ip_address"`
+	UserAgent string    `json:"user_agent,omitempty" db:"user_agent"`
+	Outcome   string    `json:"outcome" db:"outcome"`
+	Reason    string    `json:"reason,omitempty" db:"reason"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// AuthEventQuery filters authentication audit events. Zero values are ignored.
+type AuthEventQuery struct {
+	TenantID string
+	Subject  string
+	Since    time.Time
+	Until    time.Time
+	Limit    int
+}
+
+// AuthAuditStore persists authentication audit events.
+type AuthAuditStore interface {
+	RecordAuthEvent(ctx context.Context, event AuthEvent) error
+	// ListAuthEvents returns matching events, newest first.
+	ListAuthEvents(ctx context.Context, query AuthEventQuery) ([]AuthEvent, error)
+}
+
+type authAuditRepo struct {
+	db *sqlx.DB
+}
+
+// NewAuthAuditStore creates a new authentication audit store.
+func NewAuthAuditStore(db *sqlx.DB) AuthAuditStore {
+	return &authAuditRepo{db: db}
+}
+
+func (r *authAuditRepo) RecordAuthEvent(ctx context.Context, e AuthEvent) error {
+	query := `
+		INSERT INTO auth_audit_events (tenant_id, event_type, subject, actor, client_id, ip_address, user_agent, outcome, reason, created_at)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''), $8, NULLIF($9, ''), $10)
+	`
+	_, err := r.db.ExecContext(ctx, query, e.TenantID, e.EventType, e.Subject, e.Actor, e.ClientID, e.IPAddress, e.UserAgent, e.Outcome, e.Reason, e.CreatedAt)
+	return err
+}
+
+func (r *authAuditRepo) ListAuthEvents(ctx context.Context, q AuthEventQuery) ([]AuthEvent, error) {
+	query := `
+		SELECT id, tenant_id, event_type, COALESCE(subject, '') AS subject, COALESCE(actor, '') AS actor,
+			COALESCE(client_id, '') AS client_id,
+			COALESCE(ip_address, '') AS ip_address, COALESCE(user_agent, '') AS user_agent, outcome,
+			COALESCE(reason, '') AS reason, created_at
+		FROM auth_audit_events WHERE tenant_id = $1`
+	args := []interface{}{q.TenantID}
+	if q.Subject != "" {
+		args = append(args, q.Subject)
+		query += fmt.Sprintf(" AND subject = $%d", len(args))
+	}
+	if !q.Since.IsZero() {
+		args = append(args, q.Since)
+		query += fmt.Sprintf(" AND created_at >= $%d", len(args))
+	}
+	if !q.Until.IsZero() {
+		args = append(args, q.Until)
+		query += fmt.Sprintf(" AND created_at <= $%d", len(args))
+	}
+	args = append(args, authEventLimit(q.Limit))
+	query += fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d", len(args))
+
+	var events []AuthEvent
+	if err := r.db.SelectContext(ctx, &events, query, args...); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func authEventLimit(limit int) int {
+	if limit <= 0 || limit > 1000 {
+		return DefaultAuthEventLimit
+	}
+	return limit
+}
+
+// authAuditMemoryStore is an in-memory AuthAuditStore used when no database is configured.
+type authAuditMemoryStore struct {
+	mu     sync.Mutex
+	events []AuthEvent
+}
+
+func newAuthAuditMemoryStore() *authAuditMemoryStore {
+	return &authAuditMemoryStore{}
+}
+
+func (s *authAuditMemoryStore) RecordAuthEvent(ctx context.Context, e AuthEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e.ID = uuid.New().String()
+	s.events = append(s.events, e)
+	return nil
+}
+
+func (s *authAuditMemoryStore) ListAuthEvents(ctx context.Context, q AuthEventQuery) ([]AuthEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []AuthEvent
+	for _, e := range s.events {
+		if e.TenantID != q.TenantID {
+			continue
+		}
+		if q.Subject != "" && e.Subject != q.Subject {
+			continue
+		}
+		if !q.Since.IsZero() && e.CreatedAt.Before(q.Since) {
+			continue
+		}
+		if !q.Until.IsZero() && e.CreatedAt.After(q.Until) {
+			continue
+		}
+		out = append(out, e)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	if limit := authEventLimit(q.Limit); len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+func TestFailedLoginWritesAuditEvent(t *testing.T) {
+	router, _, as := newLoginTestRouter(t, LockoutConfig{})
+
+	if w := postLogin(router, "alice@example.com", "wrong-password", "10.0.0.1"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", w.Code)
+	}
+
+	events, raw := listAuthEvents(t, router, auditorSession(t, as), "alice@example.com")
+	if len(events) != 1 {
+		t.Fatalf("expected 1 audit event, got %d", len(events))
+	}
+	event := events[0]
+	if event.EventType != AuthEventLoginFailed || event.Outcome != AuthOutcomeFailure {
+		t.Fatalf("expected failed login_failed event, got %s/%s", event.EventType, event.Outcome)
+	}
+	if event.Reason != ErrInvalidCredentials.Code {
+		t.Errorf("expected reason %q, got %q", ErrInvalidCredentials.Code, event.Reason)
+	}
+	if event.IPAddress != "10.0.0.1" || event.TenantID != "11111111-1111-1111-1111-111111111111" {
+		t.Errorf("unexpected request metadata: ip=%q tenant=%q", event.IPAddress, event.TenantID)
+	}
+	if strings.Contains(raw, "wrong-password") {
+		t.Fatalf("audit events must not contain credentials: %s", raw)
+	}
+}
+
+func TestSuccessfulLoginWritesAuditEvent(t *testing.T) {
+	router, _, as := newLoginTestRouter(t, LockoutConfig{})
+
+	postLogin(router, "alice@example.com", "correct-password", "10.0.0.1")
+	postLogin(router, "bob@example.com", "wrong-password", "10.0.0.1")
+
+	events, raw := listAuthEvents(t, router, auditorSession(t, as), "alice@example.com")
+	if len(events) != 1 || events[0].EventType != AuthEventLoginSucceeded || events[0].Outcome != AuthOutcomeSuccess {
+		t.Fatalf("expected a single login_succeeded event for alice, got %+v", events)
+	}
+	if strings.Contains(raw, "correct-password") || strings.Contains(raw, "eyJ") {
+		t.Fatalf("audit events must not contain credentials or tokens: %s", raw)
+	}
+}
+
+func TestAuthEventsRequirePermission(t *testing.T) {
+	router, _, as := newLoginTestRouter(t, LockoutConfig{})
+	member, _ := as.generateUserToken("11111111-1111-1111-1111-111111111111", "user-1")
+
+	for bearer, want := range map[string]int{"": http.StatusUnauthorized, member: http.StatusForbidden} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/events", nil)
+		req.Header.Set(middleware.DefaultTenantHeader, "11111111-1111-1111-1111-111111111111")
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Fatalf("expected %d, got %d", want, w.Code)
+		}
+	}
+}
+
+// auditorSession returns a session for auditor-1, who may read auth events.
+func auditorSession(t *testing.T, as *authService) string {
+	t.Helper()
+	session, err := as.generateUserToken("11111111-1111-1111-1111-111111111111", "auditor-1")
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	return session
+}
+
+func listAuthEvents(t *testing.T, router *gin.Engine, session, subject string) ([]AuthEvent, string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/events?subject="+subject, nil)
+	req.Header.Set(middleware.DefaultTenantHeader, "11111111-1111-1111-1111-111111111111")
+	req.Header.Set("Authorization", "Bearer "+session)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 listing auth events, got %d", w.Code)
+	}
+	var body struct {
+		Events []AuthEvent `json:"events"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode auth events: %v", err)
+	}
+	return body.Events, w.Body.String()
+}
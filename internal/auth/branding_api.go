@@ -16,7 +16,7 @@ func (h *HTTPHandler) RegisterBrandingRoutes(router *gin.RouterGroup) {
 
 	// Protected management endpoints
 	mgmt := router.Group("/api/v1/branding")
-	mgmt.Use(middleware.TenantExtractor(middleware.TenantConfig{}))
+	mgmt.Use(h.tenantExtractor())
 	mgmt.GET("", h.getBranding)
 	mgmt.PUT("", h.updateBranding)
 }
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDecodeUserInfoOIDCDefaults(t *testing.T) {
+	const body = `{"sub":"10769150350006150715113082367","email":" ada@example.com ","name":"Ada Lovelace","picture":"https://example.com/ada.png","email_verified":true}`
+
+	p := &SSOProvider{Name: "google"}
+	claims, err := p.ClaimMap()
+	if err != nil {
+		t.Fatalf("ClaimMap failed: %v", err)
+	}
+	if claims != DefaultClaimMap {
+		t.Fatalf("expected default claims, got %+v", claims)
+	}
+
+	profile, err := decodeUserInfo(strings.NewReader(body), claims)
+	if err != nil {
+		t.Fatalf("decodeUserInfo failed: %v", err)
+	}
+	want := FederatedProfile{
+		Subject:       "10769150350006150715113082367",
+		Email:         "ada@example.com",
+		Name:          "Ada Lovelace",
+		Picture:       "https://example.com/ada.png",
+		EmailVerified: true,
+	}
+	if profile != want {
+		t.Fatalf("expected %+v, got %+v", want, profile)
+	}
+}
+
+func TestDecodeUserInfoGitHubStyle(t *testing.T) {
+	// GitHub has no sub claim; the stable id is a number and the display
+	// name is often null.
+	const body = `{"login":"octocat","id":583231,"email":"octocat@github.com","name":null}`
+
+	p := &SSOProvider{
+		Name:              "github",
+		AttributeMappings: JSON(`{"external_id_claim":"id","name_claim":"login"}`),
+	}
+	claims, err := p.ClaimMap()
+	if err != nil {
+		t.Fatalf("ClaimMap failed: %v", err)
+	}
+	if claims.EmailClaim != "email" {
+		t.Fatalf("expected email claim to default, got %q", claims.EmailClaim)
+	}
+
+	profile, err := decodeUserInfo(strings.NewReader(body), claims)
+	if err != nil {
+		t.Fatalf("decodeUserInfo failed: %v", err)
+	}
+	want := FederatedProfile{Subject: "583231", Email: "octocat@github.com", Name: "octocat"}
+	if profile != want {
+		t.Fatalf("expected %+v, got %+v", want, profile)
+	}
+}
+
+func TestDecodeUserInfoMissingExternalID(t *testing.T) {
+	const body = `{"login":"octocat","email":"octocat@github.com"}`
+
+	_, err := decodeUserInfo(strings.NewReader(body), ClaimMap{ExternalIDClaim: "id"})
+	var authErr *Error
+	if !errors.As(err, &authErr) || authErr.Code != "invalid_request" {
+		t.Fatalf("expected invalid_request error, got %v", err)
+	}
+	if !strings.Contains(authErr.Message, `"id"`) {
+		t.Fatalf("expected the error to name the claim, got %q", authErr.Message)
+	}
+}
+
+func TestClaimMapRejectsMalformedMappings(t *testing.T) {
+	p := &SSOProvider{Name: "github", AttributeMappings: JSON(`["id"]`)}
+	if _, err := p.ClaimMap(); err == nil {
+		t.Fatal("expected an error for malformed attribute mappings")
+	}
+}
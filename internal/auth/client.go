@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+
+	"github.com/dhawalhost/wardseal/internal/scopecatalog"
 )
 
 // ClientConfig represents a registered OAuth client.
@@ -16,6 +18,15 @@ type ClientConfig struct {
 	ClientType    string   `json:"client_type"`
 	RedirectURIs  []string `json:"redirect_uris"`
 	AllowedScopes []string `json:"allowed_scopes"`
+	// BindTokens binds issued access tokens to the caller's IP subnet and user agent.
+	BindTokens bool `json:"bind_tokens,omitempty"`
+	// FirstParty clients are trusted applications that skip the consent screen.
+	FirstParty bool `json:"first_party,omitempty"`
+	// PostLogoutRedirectURIs are where the end-session endpoint may redirect after logout.
+	PostLogoutRedirectURIs []string `json:"post_logout_redirect_uris,omitempty"`
+	// AllowedResources are the resource indicators (RFC 8707) the client may
+	// request tokens for; the chosen one becomes the access token's audience.
+	AllowedResources []string `json:"allowed_resources,omitempty"`
 }
 
 func (c ClientConfig) validate() error {
@@ -33,6 +44,11 @@ func (c ClientConfig) validate() error {
 			return fmt.Errorf("client %s has invalid redirect URI %s: %w", c.ID, uri, err)
 		}
 	}
+	for _, resource := range c.AllowedResources {
+		if !validResourceIndicator(resource) {
+			return fmt.Errorf("client %s has invalid allowed resource %s", c.ID, resource)
+		}
+	}
 	if len(c.AllowedScopes) == 0 {
 		return fmt.Errorf("client %s must declare at least one scope", c.ID)
 	}
@@ -51,6 +67,31 @@ func (c ClientConfig) allowsRedirect(redirect string) bool {
 	return false
 }
 
+func (c ClientConfig) allowsPostLogoutRedirect(redirect string) bool {
+	for _, uri := range c.PostLogoutRedirectURIs {
+		if uri == redirect {
+			return true
+		}
+	}
+	return false
+}
+
+func (c ClientConfig) allowsResource(resource string) bool {
+	for _, allowed := range c.AllowedResources {
+		if allowed == resource {
+			return true
+		}
+	}
+	return false
+}
+
+// validResourceIndicator reports whether resource is an absolute URI without
+// a fragment, as RFC 8707 requires.
+func validResourceIndicator(resource string) bool {
+	u, err := url.Parse(resource)
+	return err == nil && u.IsAbs() && u.Fragment == ""
+}
+
 func (c ClientConfig) validateScopes(requested string) error {
 	req := strings.Fields(requested)
 	allowed := make(map[string]struct{}, len(c.AllowedScopes))
@@ -65,6 +106,45 @@ func (c ClientConfig) validateScopes(requested string) error {
 	return nil
 }
 
+// filterScopes splits the requested scopes into those the client may be granted
+// and those outside its AllowedScopes, preserving request order and dropping duplicates.
+func (c ClientConfig) filterScopes(requested string) (granted, disallowed []string) {
+	allowed := make(map[string]struct{}, len(c.AllowedScopes))
+	for _, scope := range c.AllowedScopes {
+		allowed[scope] = struct{}{}
+	}
+	seen := make(map[string]struct{})
+	for _, scope := range strings.Fields(requested) {
+		if _, dup := seen[scope]; dup {
+			continue
+		}
+		seen[scope] = struct{}{}
+		if _, ok := allowed[scope]; ok {
+			granted = append(granted, scope)
+		} else {
+			disallowed = append(disallowed, scope)
+		}
+	}
+	return granted, disallowed
+}
+
+// cataloguedScopes splits scopes into those in catalog and those missing
+// from it, preserving order.
+func cataloguedScopes(scopes []string, catalog []scopecatalog.Scope) (catalogued, missing []string) {
+	known := make(map[string]struct{}, len(catalog))
+	for _, entry := range catalog {
+		known[entry.ID] = struct{}{}
+	}
+	for _, scope := range scopes {
+		if _, ok := known[scope]; ok {
+			catalogued = append(catalogued, scope)
+		} else {
+			missing = append(missing, scope)
+		}
+	}
+	return catalogued, missing
+}
+
 func (c ClientConfig) withDefaults() ClientConfig {
 	if c.ClientType == "" {
 		c.ClientType = "public"
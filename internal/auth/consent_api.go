@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+var consentPageTemplate = template.Must(template.New("consent").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Authorize {{.Prompt.ClientName}}</title></head>
+<body>
+<h1>{{.Prompt.ClientName}} wants to access your account</h1>
+<form method="POST" action="/oauth/consent">
+<input type="hidden" name="response_type" value="{{.Request.ResponseType}}">
+<input type="hidden" name="client_id" value="{{.Request.ClientID}}">
+<input type="hidden" name="redirect_uri" value="{{.Request.RedirectURI}}">
+<input type="hidden" name="scope" value="{{.Request.Scope}}">
+<input type="hidden" name="state" value="{{.Request.State}}">
+<input type="hidden" name="code_challenge" value="{{.Request.CodeChallenge}}">
+<input type="hidden" name="code_challenge_method" value="{{.Request.CodeChallengeMethod}}">
+<input type="hidden" name="resource" value="{{.Request.Resource}}">
+<ul>
+{{range .Prompt.Scopes}}<li><label><input type="checkbox" name="granted_scope" value="{{.Scope}}" checked> <strong>{{.DisplayName}}</strong>{{if .Sensitive}} (sensitive){{end}}{{if .Description}}: {{.Description}}{{end}}</label></li>
+{{end}}</ul>
+<button type="submit" name="decision" value="approve">Allow</button>
+<button type="submit" name="decision" value="deny">Deny</button>
+</form>
+</body>
+</html>
+`))
+
+// consentPage handles GET /oauth/consent, rendering the scopes the client requested.
+func (h *HTTPHandler) consentPage(c *gin.Context) {
+	var req AuthorizeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.SessionToken = authorizeSession(c)
+
+	prompt, err := h.svc.ConsentPrompt(c.Request.Context(), req)
+	if err != nil {
+		h.respondConsentError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := consentPageTemplate.Execute(c.Writer, gin.H{"Prompt": prompt, "Request": req}); err != nil {
+		h.logger.Error("Failed to render consent page", zap.Error(err))
+	}
+}
+
+// submitConsent handles POST /oauth/consent and redirects back to the client.
+func (h *HTTPHandler) submitConsent(c *gin.Context) {
+	var req ConsentRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// The form is posted from the consent page itself, a same-site request
+	// that carries the Strict cookie. A cross-site POST carries neither.
+	if token, err := c.Cookie(AccessTokenCookie); err == nil {
+		req.SessionToken = token
+	}
+
+	resp, err := h.svc.Consent(c.Request.Context(), req)
+	if err != nil {
+		h.respondConsentError(c, err)
+		return
+	}
+	c.Redirect(http.StatusFound, resp.RedirectURI)
+}
+
+func (h *HTTPHandler) respondConsentError(c *gin.Context, err error) {
+	h.logger.Error("Consent failed", zap.Error(err))
+	svcErr := &Error{}
+	if errors.As(err, &svcErr) {
+		h.respondOAuthError(c, svcErr)
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
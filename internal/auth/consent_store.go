@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ConsentStore persists the scopes a user has granted to a client.
+type ConsentStore interface {
+	// GetConsent returns the granted scopes, or nil if the user never consented.
+	GetConsent(ctx context.Context, tenantID, subject, clientID string) ([]string, error)
+	// SaveConsent replaces the granted scopes for the user and client.
+	SaveConsent(ctx context.Context, tenantID, subject, clientID string, scopes []string) error
+}
+
+type consentRepo struct {
+	db *sqlx.DB
+}
+
+// NewConsentStore creates a new consent store.
+func NewConsentStore(db *sqlx.DB) ConsentStore {
+	return &consentRepo{db: db}
+}
+
+func (r *consentRepo) GetConsent(ctx context.Context, tenantID, subject, clientID string) ([]string, error) {
+	var scopes pq.StringArray
+	query := `SELECT scopes FROM oauth_consents WHERE tenant_id = $1 AND subject = $2 AND client_id = $3`
+	if err := r.db.GetContext(ctx, &scopes, query, tenantID, subject, clientID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return scopes, nil
+}
+
+func (r *consentRepo) SaveConsent(ctx context.Context, tenantID, subject, clientID string, scopes []string) error {
+	query := `
+		INSERT INTO oauth_consents (tenant_id, subject, client_id, scopes)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (tenant_id, subject, client_id)
+		DO UPDATE SET scopes = EXCLUDED.scopes, updated_at = NOW()
+	`
+	_, err := r.db.ExecContext(ctx, query, tenantID, subject, clientID, pq.StringArray(scopes))
+	return err
+}
+
+// consentMemoryStore is an in-memory ConsentStore used when no database is configured.
+type consentMemoryStore struct {
+	mu       sync.Mutex
+	consents map[string][]string
+}
+
+func newConsentMemoryStore() *consentMemoryStore {
+	return &consentMemoryStore{consents: make(map[string][]string)}
+}
+
+func (s *consentMemoryStore) key(tenantID, subject, clientID string) string {
+	return tenantID + "::" + subject + "::" + clientID
+}
+
+func (s *consentMemoryStore) GetConsent(ctx context.Context, tenantID, subject, clientID string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scopes, ok := s.consents[s.key(tenantID, subject, clientID)]
+	if !ok {
+		return nil, nil
+	}
+	return append([]string(nil), scopes...), nil
+}
+
+func (s *consentMemoryStore) SaveConsent(ctx context.Context, tenantID, subject, clientID string, scopes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.consents[s.key(tenantID, subject, clientID)] = append([]string(nil), scopes...)
+	return nil
+}
@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func partnerAuthorizeRequest(t *testing.T, as *authService, scope string) AuthorizeRequest {
+	t.Helper()
+	session, err := as.generateUserToken("11111111-1111-1111-1111-111111111111", "user-1")
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	return AuthorizeRequest{
+		ResponseType:        "code",
+		ClientID:            "partner-client",
+		RedirectURI:         "https://partner.example.com/callback",
+		Scope:               scope,
+		State:               "xyz",
+		CodeChallenge:       pkceChallenge("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNO1234567890abcd"),
+		CodeChallengeMethod: "S256",
+		SessionToken:        session,
+	}
+}
+
+func TestAuthorizeRequiresConsentOnce(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTestService(t)
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+	req := partnerAuthorizeRequest(t, as, "openid profile")
+
+	resp, err := as.Authorize(ctx, req)
+	if err != nil {
+		t.Fatalf("authorize error: %v", err)
+	}
+	if !resp.ConsentRequired || resp.RedirectURI != "" {
+		t.Fatalf("expected consent to be required, got %+v", resp)
+	}
+
+	consented, err := as.Consent(ctx, ConsentRequest{
+		AuthorizeRequest: req,
+		Decision:         "approve",
+		GrantedScopes:    []string{"openid", "profile"},
+	})
+	if err != nil {
+		t.Fatalf("consent error: %v", err)
+	}
+	extractCode(t, consented.RedirectURI)
+
+	resp, err = as.Authorize(ctx, req)
+	if err != nil {
+		t.Fatalf("authorize error: %v", err)
+	}
+	if resp.ConsentRequired {
+		t.Fatalf("expected stored consent to skip the consent screen")
+	}
+	extractCode(t, resp.RedirectURI)
+}
+
+func TestConsentDowngradesScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTestService(t)
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+	req := partnerAuthorizeRequest(t, as, "openid profile")
+
+	consented, err := as.Consent(ctx, ConsentRequest{
+		AuthorizeRequest: req,
+		Decision:         "approve",
+		GrantedScopes:    []string{"openid"},
+	})
+	if err != nil {
+		t.Fatalf("consent error: %v", err)
+	}
+	tokenResp, err := as.Token(ctx, TokenRequest{
+		GrantType:    "authorization_code",
+		Code:         extractCode(t, consented.RedirectURI),
+		RedirectURI:  req.RedirectURI,
+		ClientID:     req.ClientID,
+		CodeVerifier: "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNO1234567890abcd",
+	})
+	if err != nil {
+		t.Fatalf("token error: %v", err)
+	}
+	if tokenResp.Scope != "openid" {
+		t.Fatalf("expected downgraded scope openid, got %q", tokenResp.Scope)
+	}
+
+	// Only openid was granted, so asking for profile again needs consent.
+	resp, err := as.Authorize(ctx, req)
+	if err != nil {
+		t.Fatalf("authorize error: %v", err)
+	}
+	if !resp.ConsentRequired {
+		t.Fatalf("expected consent for the ungranted profile scope")
+	}
+}
+
+func TestConsentDenyRedirectsWithAccessDenied(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTestService(t)
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+	req := partnerAuthorizeRequest(t, as, "openid")
+
+	resp, err := as.Consent(ctx, ConsentRequest{AuthorizeRequest: req, Decision: "deny"})
+	if err != nil {
+		t.Fatalf("consent error: %v", err)
+	}
+	parsed, err := url.Parse(resp.RedirectURI)
+	if err != nil {
+		t.Fatalf("invalid redirect uri: %v", err)
+	}
+	if got := parsed.Query().Get("error"); got != "access_denied" {
+		t.Fatalf("expected access_denied, got %q", got)
+	}
+	if got := parsed.Query().Get("state"); got != "xyz" {
+		t.Fatalf("expected state to be echoed, got %q", got)
+	}
+}
+
+func TestAuthorizeThirdPartyRequiresSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTestService(t)
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+	req := partnerAuthorizeRequest(t, as, "openid")
+	req.SessionToken = ""
+
+	if _, err := as.Authorize(ctx, req); !errors.Is(err, ErrLoginRequired) {
+		t.Fatalf("expected ErrLoginRequired, got %v", err)
+	}
+}
+
+func TestSetAuthCookiesSendsSessionToAuthorizeCrossSite(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	setAuthCookies(c, "session-token", "")
+
+	lax := map[string]bool{}
+	for _, cookie := range w.Result().Cookies() {
+		switch cookie.Name {
+		case AccessTokenCookie:
+			if cookie.SameSite != http.SameSiteStrictMode {
+				t.Fatalf("expected a Strict access token cookie, got %v", cookie.SameSite)
+			}
+		case AuthorizeSessionCookie:
+			if cookie.SameSite != http.SameSiteLaxMode || !cookie.HttpOnly || cookie.Value != "session-token" {
+				t.Fatalf("expected an HttpOnly Lax session cookie, got %+v", cookie)
+			}
+			lax[cookie.Path] = true
+		}
+	}
+	if !lax["/oauth2/authorize"] || !lax["/oauth/consent"] || len(lax) != 2 {
+		t.Fatalf("expected the session cookie on the authorize and consent paths only, got %v", lax)
+	}
+}
+
+func TestAuthorizeAcceptsCrossSiteSessionCookie(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTestService(t)
+	router := gin.New()
+	NewHTTPHandler(as, zap.NewNop(), nil).RegisterRoutes(router)
+	req := partnerAuthorizeRequest(t, as, "openid")
+	query := url.Values{
+		"response_type":         {req.ResponseType},
+		"client_id":             {req.ClientID},
+		"redirect_uri":          {req.RedirectURI},
+		"scope":                 {req.Scope},
+		"state":                 {req.State},
+		"code_challenge":        {req.CodeChallenge},
+		"code_challenge_method": {req.CodeChallengeMethod},
+	}.Encode()
+
+	// A cross-site navigation drops the Strict access token cookie and
+	// carries only the Lax one.
+	get := func(path string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path+"?"+query, nil)
+		r.Header.Set(middleware.DefaultTenantHeader, "11111111-1111-1111-1111-111111111111")
+		r.AddCookie(&http.Cookie{Name: AuthorizeSessionCookie, Value: req.SessionToken})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	w := get("/oauth2/authorize")
+	if w.Code != http.StatusFound || !strings.HasPrefix(w.Header().Get("Location"), "/oauth/consent?") {
+		t.Fatalf("expected a redirect to the consent page, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	if w := get("/oauth/consent"); w.Code != http.StatusOK {
+		t.Fatalf("expected the consent page, got %d: %s", w.Code, w.Body.String())
+	}
+}
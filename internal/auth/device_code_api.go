@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+var devicePageTemplate = template.Must(template.New("device").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Connect a device</title></head>
+<body>
+{{if .Prompt}}
+<h1>{{.Prompt.ClientName}} wants to access your account</h1>
+<ul>
+{{range .Prompt.Scopes}}<li><strong>{{.DisplayName}}</strong>{{if .Sensitive}} (sensitive){{end}}{{if .Description}}: {{.Description}}{{end}}</li>
+{{end}}</ul>
+<form method="POST" action="/device">
+<input type="hidden" name="user_code" value="{{.UserCode}}">
+<button type="submit" name="decision" value="approve">Allow</button>
+<button type="submit" name="decision" value="deny">Deny</button>
+</form>
+{{else if .Done}}
+<h1>{{.Done}}</h1>
+<p>You can return to your device.</p>
+{{else}}
+<h1>Connect a device</h1>
+{{if .Error}}<p>{{.Error}}</p>{{end}}
+<form method="GET" action="/device">
+<label>Enter the code shown on your device <input type="text" name="user_code" value="{{.UserCode}}" autofocus></label>
+<button type="submit">Continue</button>
+</form>
+{{end}}
+</body>
+</html>
+`))
+
+// deviceAuthorization handles POST /oauth/device_authorization.
+func (h *HTTPHandler) deviceAuthorization(c *gin.Context) {
+	var req DeviceAuthorizationRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := h.svc.DeviceAuthorization(c.Request.Context(), req)
+	if err != nil {
+		h.logger.Error("Device authorization failed", zap.Error(err))
+		svcErr := &Error{}
+		if errors.As(err, &svcErr) {
+			h.respondOAuthError(c, svcErr)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// devicePage handles GET /device, prompting for a user code and then for approval.
+func (h *HTTPHandler) devicePage(c *gin.Context) {
+	userCode := c.Query("user_code")
+	data := gin.H{"UserCode": userCode}
+	if userCode != "" {
+		session, _ := c.Cookie(AccessTokenCookie)
+		prompt, err := h.svc.DeviceVerification(c.Request.Context(), userCode, session)
+		switch {
+		case err == nil:
+			data["Prompt"] = prompt
+		case errors.Is(err, ErrLoginRequired):
+			h.respondOAuthError(c, ErrLoginRequired)
+			return
+		case errors.Is(err, ErrInvalidUserCode):
+			data["Error"] = ErrInvalidUserCode.Message
+		default:
+			h.logger.Error("Device verification failed", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	h.renderDevicePage(c, data)
+}
+
+// submitDeviceApproval handles POST /device.
+func (h *HTTPHandler) submitDeviceApproval(c *gin.Context) {
+	var req DeviceApprovalRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if token, err := c.Cookie(AccessTokenCookie); err == nil {
+		req.SessionToken = token
+	}
+
+	if err := h.svc.DeviceApproval(c.Request.Context(), req); err != nil {
+		h.logger.Error("Device approval failed", zap.Error(err))
+		svcErr := &Error{}
+		if errors.As(err, &svcErr) {
+			h.respondOAuthError(c, svcErr)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	done := "Device connected"
+	if req.Decision != "approve" {
+		done = "Request denied"
+	}
+	h.renderDevicePage(c, gin.H{"Done": done})
+}
+
+func (h *HTTPHandler) renderDevicePage(c *gin.Context, data gin.H) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := devicePageTemplate.Execute(c.Writer, data); err != nil {
+		h.logger.Error("Failed to render device page", zap.Error(err))
+	}
+}
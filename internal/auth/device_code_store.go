@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Device authorization statuses.
+const (
+	DeviceCodePending  = "pending"
+	DeviceCodeApproved = "approved"
+	DeviceCodeDenied   = "denied"
+)
+
+// deviceAuthorization is an outstanding RFC 8628 device authorization.
+type deviceAuthorization struct {
+	DeviceCode   string    `db:"device_code"`
+	UserCode     string    `db:"user_code"`
+	TenantID     string    `db:"tenant_id"`
+	ClientID     string    `db:"client_id"`
+	Scope        string    `db:"scope"`
+	Status       string    `db:"status"`
+	Subject      string    `db:"subject"`
+	Interval     int       `db:"interval_seconds"`
+	ExpiresAt    time.Time `db:"expires_at"`
+	LastPolledAt time.Time `db:"last_polled_at"`
+}
+
+// DeviceCodeStore persists device authorizations until they are redeemed or expire.
+type DeviceCodeStore interface {
+	Save(ctx context.Context, entry deviceAuthorization) error
+	GetByDeviceCode(ctx context.Context, deviceCode string) (deviceAuthorization, bool, error)
+	GetByUserCode(ctx context.Context, userCode string) (deviceAuthorization, bool, error)
+	// Consume deletes the authorization if it still has the given status. It
+	// reports false when it is gone or its status changed, so concurrent
+	// polls cannot both redeem one approval.
+	Consume(ctx context.Context, deviceCode, status string) (bool, error)
+	Delete(ctx context.Context, deviceCode string) error
+}
+
+// SQLDeviceCodeStore implements persistent storage for device authorizations.
+type SQLDeviceCodeStore struct {
+	db *sqlx.DB
+}
+
+// NewSQLDeviceCodeStore creates a new SQL-backed device code store.
+func NewSQLDeviceCodeStore(db *sqlx.DB) *SQLDeviceCodeStore {
+	return &SQLDeviceCodeStore{db: db}
+}
+
+// Save inserts the authorization or updates its status and polling state. A
+// decided status is kept, so a poll that read the authorization while it was
+// pending cannot undo the user's decision.
+func (s *SQLDeviceCodeStore) Save(ctx context.Context, e deviceAuthorization) error {
+	query := `
+		INSERT INTO device_authorizations (device_code, user_code, tenant_id, client_id, scope, status, subject, interval_seconds, expires_at, last_polled_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9, $10)
+		ON CONFLICT (device_code)
+		DO UPDATE SET
+			status = CASE WHEN device_authorizations.status = 'pending' THEN EXCLUDED.status ELSE device_authorizations.status END,
+			subject = CASE WHEN device_authorizations.status = 'pending' THEN EXCLUDED.subject ELSE device_authorizations.subject END,
+			interval_seconds = EXCLUDED.interval_seconds, last_polled_at = EXCLUDED.last_polled_at
+	`
+	var lastPolled sql.NullTime
+	if !e.LastPolledAt.IsZero() {
+		lastPolled = sql.NullTime{Time: e.LastPolledAt, Valid: true}
+	}
+	_, err := s.db.ExecContext(ctx, query, e.DeviceCode, e.UserCode, e.TenantID, e.ClientID, e.Scope, e.Status,
+		e.Subject, e.Interval, e.ExpiresAt, lastPolled)
+	return err
+}
+
+func (s *SQLDeviceCodeStore) GetByDeviceCode(ctx context.Context, deviceCode string) (deviceAuthorization, bool, error) {
+	return s.get(ctx, "device_code", deviceCode)
+}
+
+func (s *SQLDeviceCodeStore) GetByUserCode(ctx context.Context, userCode string) (deviceAuthorization, bool, error) {
+	return s.get(ctx, "user_code", userCode)
+}
+
+func (s *SQLDeviceCodeStore) get(ctx context.Context, column, value string) (deviceAuthorization, bool, error) {
+	var entry deviceAuthorization
+	query := `
+		SELECT device_code, user_code, tenant_id, client_id, scope, status, COALESCE(subject, '') AS subject,
+			interval_seconds, expires_at, COALESCE(last_polled_at, 'epoch'::timestamptz) AS last_polled_at
+		FROM device_authorizations WHERE ` + column + ` = $1`
+	if err := s.db.GetContext(ctx, &entry, query, value); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return deviceAuthorization{}, false, nil
+		}
+		return deviceAuthorization{}, false, err
+	}
+	if entry.LastPolledAt.Unix() == 0 {
+		entry.LastPolledAt = time.Time{}
+	}
+	return entry, true, nil
+}
+
+func (s *SQLDeviceCodeStore) Consume(ctx context.Context, deviceCode, status string) (bool, error) {
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM device_authorizations WHERE device_code = $1 AND status = $2`, deviceCode, status)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (s *SQLDeviceCodeStore) Delete(ctx context.Context, deviceCode string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM device_authorizations WHERE device_code = $1`, deviceCode)
+	return err
+}
+
+// CleanupExpired removes expired device authorizations (can be run periodically).
+func (s *SQLDeviceCodeStore) CleanupExpired(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM device_authorizations WHERE expires_at < $1`, time.Now())
+	return err
+}
+
+// deviceCodeMemoryStore is an in-memory DeviceCodeStore used when no database is configured.
+type deviceCodeMemoryStore struct {
+	mu      sync.RWMutex
+	entries map[string]deviceAuthorization
+}
+
+func newDeviceCodeMemoryStore() *deviceCodeMemoryStore {
+	return &deviceCodeMemoryStore{entries: make(map[string]deviceAuthorization)}
+}
+
+func (s *deviceCodeMemoryStore) Save(ctx context.Context, e deviceAuthorization) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if prev, ok := s.entries[e.DeviceCode]; ok && prev.Status != DeviceCodePending {
+		e.Status, e.Subject = prev.Status, prev.Subject
+	}
+	s.entries[e.DeviceCode] = e
+	return nil
+}
+
+func (s *deviceCodeMemoryStore) GetByDeviceCode(ctx context.Context, deviceCode string) (deviceAuthorization, bool, error) {
+	s.mu.RLock()
+	entry, ok := s.entries[deviceCode]
+	s.mu.RUnlock()
+	return entry, ok, nil
+}
+
+func (s *deviceCodeMemoryStore) GetByUserCode(ctx context.Context, userCode string) (deviceAuthorization, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, entry := range s.entries {
+		if entry.UserCode == userCode {
+			return entry, true, nil
+		}
+	}
+	return deviceAuthorization{}, false, nil
+}
+
+func (s *deviceCodeMemoryStore) Consume(ctx context.Context, deviceCode, status string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[deviceCode]
+	if !ok || entry.Status != status {
+		return false, nil
+	}
+	delete(s.entries, deviceCode)
+	return true, nil
+}
+
+func (s *deviceCodeMemoryStore) Delete(ctx context.Context, deviceCode string) error {
+	s.mu.Lock()
+	delete(s.entries, deviceCode)
+	s.mu.Unlock()
+	return nil
+}
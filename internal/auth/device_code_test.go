@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDeviceCodeGrantPendingToApproved(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTestService(t)
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+
+	authz, err := as.DeviceAuthorization(ctx, DeviceAuthorizationRequest{ClientID: "test-client", Scope: "openid"})
+	if err != nil {
+		t.Fatalf("device authorization error: %v", err)
+	}
+	if authz.DeviceCode == "" || len(authz.UserCode) != 9 || authz.VerificationURI != "http://wardseal.com/device" {
+		t.Fatalf("unexpected device authorization response: %+v", authz)
+	}
+
+	poll := TokenRequest{GrantType: DeviceCodeGrantType, ClientID: "test-client", DeviceCode: authz.DeviceCode}
+	if _, err := as.Token(ctx, poll); !errors.Is(err, ErrAuthorizationPending) {
+		t.Fatalf("expected authorization_pending, got %v", err)
+	}
+	if _, err := as.Token(ctx, poll); !errors.Is(err, ErrSlowDown) {
+		t.Fatalf("expected slow_down for an immediate re-poll, got %v", err)
+	}
+
+	session, err := as.generateUserToken("11111111-1111-1111-1111-111111111111", "user-1")
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	prompt, err := as.DeviceVerification(ctx, "  "+authz.UserCode[:4]+authz.UserCode[5:], session)
+	if err != nil {
+		t.Fatalf("device verification error: %v", err)
+	}
+	if prompt.ClientName != "Test Client" {
+		t.Fatalf("expected prompt for Test Client, got %+v", prompt)
+	}
+	if err := as.DeviceApproval(ctx, DeviceApprovalRequest{UserCode: authz.UserCode, Decision: "approve", SessionToken: session}); err != nil {
+		t.Fatalf("device approval error: %v", err)
+	}
+
+	tokenResp, err := as.Token(ctx, poll)
+	if err != nil {
+		t.Fatalf("token error after approval: %v", err)
+	}
+	if tokenResp.AccessToken == "" || tokenResp.Scope != "openid" {
+		t.Fatalf("unexpected token response: %+v", tokenResp)
+	}
+	if _, err := as.Token(ctx, poll); !errors.Is(err, ErrInvalidDeviceCode) {
+		t.Fatalf("expected device code to be single use, got %v", err)
+	}
+}
+
+func TestDeviceCodeGrantExpired(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTestService(t)
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+
+	authz, err := as.DeviceAuthorization(ctx, DeviceAuthorizationRequest{ClientID: "test-client", Scope: "openid"})
+	if err != nil {
+		t.Fatalf("device authorization error: %v", err)
+	}
+	entry, _, _ := as.deviceCodeStore.GetByDeviceCode(ctx, authz.DeviceCode)
+	entry.ExpiresAt = time.Now().Add(-time.Second)
+	_ = as.deviceCodeStore.Save(ctx, entry)
+
+	poll := TokenRequest{GrantType: DeviceCodeGrantType, ClientID: "test-client", DeviceCode: authz.DeviceCode}
+	if _, err := as.Token(ctx, poll); !errors.Is(err, ErrExpiredToken) {
+		t.Fatalf("expected expired_token, got %v", err)
+	}
+}
+
+func TestDeviceCodeGrantDenied(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTestService(t)
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+
+	authz, err := as.DeviceAuthorization(ctx, DeviceAuthorizationRequest{ClientID: "test-client", Scope: "openid"})
+	if err != nil {
+		t.Fatalf("device authorization error: %v", err)
+	}
+	session, _ := as.generateUserToken("11111111-1111-1111-1111-111111111111", "user-1")
+	if err := as.DeviceApproval(ctx, DeviceApprovalRequest{UserCode: authz.UserCode, Decision: "deny", SessionToken: session}); err != nil {
+		t.Fatalf("device approval error: %v", err)
+	}
+
+	poll := TokenRequest{GrantType: DeviceCodeGrantType, ClientID: "test-client", DeviceCode: authz.DeviceCode}
+	if _, err := as.Token(ctx, poll); !errors.Is(err, ErrAccessDenied) {
+		t.Fatalf("expected access_denied, got %v", err)
+	}
+}
+
+// staleDeviceCodeStore keeps returning the first entry it read, like a poll
+// that read the authorization just before a concurrent poll redeemed it.
+type staleDeviceCodeStore struct {
+	DeviceCodeStore
+	read *deviceAuthorization
+}
+
+func (s *staleDeviceCodeStore) GetByDeviceCode(ctx context.Context, deviceCode string) (deviceAuthorization, bool, error) {
+	if s.read == nil {
+		entry, found, err := s.DeviceCodeStore.GetByDeviceCode(ctx, deviceCode)
+		if !found || err != nil {
+			return entry, found, err
+		}
+		s.read = &entry
+	}
+	return *s.read, true, nil
+}
+
+func TestDeviceCodeGrantRedeemsApprovalOnce(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTestService(t)
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+
+	authz, err := as.DeviceAuthorization(ctx, DeviceAuthorizationRequest{ClientID: "test-client", Scope: "openid"})
+	if err != nil {
+		t.Fatalf("device authorization error: %v", err)
+	}
+	session, _ := as.generateUserToken("11111111-1111-1111-1111-111111111111", "user-1")
+	if err := as.DeviceApproval(ctx, DeviceApprovalRequest{UserCode: authz.UserCode, Decision: "approve", SessionToken: session}); err != nil {
+		t.Fatalf("device approval error: %v", err)
+	}
+	as.deviceCodeStore = &staleDeviceCodeStore{DeviceCodeStore: as.deviceCodeStore}
+
+	poll := TokenRequest{GrantType: DeviceCodeGrantType, ClientID: "test-client", DeviceCode: authz.DeviceCode}
+	if _, err := as.Token(ctx, poll); err != nil {
+		t.Fatalf("token error after approval: %v", err)
+	}
+	if resp, err := as.Token(ctx, poll); !errors.Is(err, ErrInvalidDeviceCode) {
+		t.Fatalf("expected a stale approved read to be refused, got %+v, %v", resp, err)
+	}
+}
+
+func TestDeviceCodePollKeepsApproval(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTestService(t)
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+
+	authz, err := as.DeviceAuthorization(ctx, DeviceAuthorizationRequest{ClientID: "test-client", Scope: "openid"})
+	if err != nil {
+		t.Fatalf("device authorization error: %v", err)
+	}
+	pending, _, _ := as.deviceCodeStore.GetByDeviceCode(ctx, authz.DeviceCode)
+	session, _ := as.generateUserToken("11111111-1111-1111-1111-111111111111", "user-1")
+	if err := as.DeviceApproval(ctx, DeviceApprovalRequest{UserCode: authz.UserCode, Decision: "approve", SessionToken: session}); err != nil {
+		t.Fatalf("device approval error: %v", err)
+	}
+
+	// A poll that read the entry before the approval saves its polling state.
+	pending.LastPolledAt = time.Now()
+	if err := as.deviceCodeStore.Save(ctx, pending); err != nil {
+		t.Fatal(err)
+	}
+	poll := TokenRequest{GrantType: DeviceCodeGrantType, ClientID: "test-client", DeviceCode: authz.DeviceCode}
+	if _, err := as.Token(ctx, poll); err != nil {
+		t.Fatalf("expected the approval to survive a stale poll, got %v", err)
+	}
+}
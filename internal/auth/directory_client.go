@@ -0,0 +1,263 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+)
+
+// ErrDirectoryUserNotFound is returned when the directory has no matching user.
+var ErrDirectoryUserNotFound = errors.New("directory user not found")
+
+// DirectoryUser is the part of a directory user the auth service needs.
+type DirectoryUser struct {
+	ID    string `json:"id"`
+	Email string `json:"email"`
+}
+
+// DirectoryClient provides methods to interact with the Directory Service.
+type DirectoryClient interface {
+	// FindUserByEmail returns nil when no user in the tenant has the email.
+	FindUserByEmail(ctx context.Context, tenantID, email string) (*DirectoryUser, error)
+	CreateUser(ctx context.Context, tenantID, email, password string) (*DirectoryUser, error)
+	// VerifyCredentials returns ErrInvalidCredentials when the directory
+	// rejects the email and password.
+	VerifyCredentials(ctx context.Context, tenantID, email, password string) (*DirectoryUser, error)
+	SetPassword(ctx context.Context, tenantID, userID, password string) error
+	// RecordLogin records a login that did not go through VerifyCredentials,
+	// such as a federated one, as the user's last login.
+	RecordLogin(ctx context.Context, tenantID, userID string) error
+	// DiscoverTenant returns the only tenant with a user for email, or
+	// ErrTenantSelectionRequired when there are several.
+	DiscoverTenant(ctx context.Context, email string) (string, error)
+}
+
+// directoryAudience is the service name the directory expects in signed
+// service tokens.
+const directoryAudience = "dirsvc"
+
+// DirectoryClientConfig locates the Directory Service and says how to
+// authenticate to it.
+type DirectoryClientConfig struct {
+	BaseURL string
+	// ServiceAuthHeader defaults to middleware.DefaultServiceAuthHeader.
+	ServiceAuthHeader string
+	ServiceAuthToken  string
+	// ServiceName and SigningKey, when both set, replace the static token
+	// with a signed service token minted for each request.
+	ServiceName string
+	SigningKey  []byte
+}
+
+type directoryHTTPClient struct {
+	cfg        DirectoryClientConfig
+	httpClient *http.Client
+}
+
+// NewDirectoryClient creates a new client for the Directory Service.
+func NewDirectoryClient(cfg DirectoryClientConfig) DirectoryClient {
+	if cfg.ServiceAuthHeader == "" {
+		cfg.ServiceAuthHeader = middleware.DefaultServiceAuthHeader
+	}
+	return &directoryHTTPClient{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (c *directoryHTTPClient) FindUserByEmail(ctx context.Context, tenantID, email string) (*DirectoryUser, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/users", tenantID, url.Values{"email": {email}}, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, directoryError(resp)
+	}
+	var body struct {
+		User DirectoryUser `json:"user"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	return &body.User, nil
+}
+
+func (c *directoryHTTPClient) CreateUser(ctx context.Context, tenantID, email, password string) (*DirectoryUser, error) {
+	payload := map[string]any{
+		"user": map[string]string{
+			"email":    email,
+			"password": password,
+			"status":   "active",
+		},
+	}
+	resp, err := c.do(ctx, http.MethodPost, "/users", tenantID, nil, payload)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusCreated {
+		return nil, directoryError(resp)
+	}
+	var body struct {
+		UserID string `json:"user_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	return &DirectoryUser{ID: body.UserID, Email: email}, nil
+}
+
+func (c *directoryHTTPClient) VerifyCredentials(ctx context.Context, tenantID, email, password string) (*DirectoryUser, error) {
+	payload := map[string]string{"email": email, "password": password}
+	resp, err := c.do(ctx, http.MethodPost, "/internal/credentials/verify", tenantID, nil, payload)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, ErrInvalidCredentials
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, directoryError(resp)
+	}
+	var body struct {
+		User DirectoryUser `json:"user"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	return &body.User, nil
+}
+
+func (c *directoryHTTPClient) SetPassword(ctx context.Context, tenantID, userID, password string) error {
+	payload := map[string]string{"user_id": userID, "password": password}
+	resp, err := c.do(ctx, http.MethodPost, "/internal/credentials/password", tenantID, nil, payload)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrDirectoryUserNotFound
+	}
+	if resp.StatusCode != http.StatusNoContent {
+		return directoryError(resp)
+	}
+	return nil
+}
+
+func (c *directoryHTTPClient) RecordLogin(ctx context.Context, tenantID, userID string) error {
+	payload := map[string]string{"user_id": userID}
+	resp, err := c.do(ctx, http.MethodPost, "/internal/credentials/login", tenantID, nil, payload)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return directoryError(resp)
+	}
+	return nil
+}
+
+func (c *directoryHTTPClient) DiscoverTenant(ctx context.Context, email string) (string, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/internal/discover", "", url.Values{"email": {email}}, nil)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", ErrDirectoryUserNotFound
+	case http.StatusConflict:
+		return "", ErrTenantSelectionRequired
+	default:
+		return "", directoryError(resp)
+	}
+	var body struct {
+		TenantID string `json:"tenant_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode discovery response: %w", err)
+	}
+	return body.TenantID, nil
+}
+
+// do sends a request to the directory with the tenant and service auth
+// headers set. An empty tenantID sends no tenant header.
+func (c *directoryHTTPClient) do(ctx context.Context, method, path, tenantID string, query url.Values, payload any) (*http.Response, error) {
+	target := c.cfg.BaseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if tenantID != "" {
+		req.Header.Set(middleware.DefaultTenantHeader, tenantID)
+	}
+	token, err := c.serviceToken()
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set(c.cfg.ServiceAuthHeader, token)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request to directory service failed: %w", err)
+	}
+	return resp, nil
+}
+
+// serviceToken returns a freshly signed service token when a signing key is
+// configured and the static token otherwise.
+func (c *directoryHTTPClient) serviceToken() (string, error) {
+	if c.cfg.ServiceName == "" || len(c.cfg.SigningKey) == 0 {
+		return c.cfg.ServiceAuthToken, nil
+	}
+	return middleware.SignServiceToken(c.cfg.ServiceName, directoryAudience, c.cfg.SigningKey, 0)
+}
+
+// directoryError turns an unexpected directory response into an error. A 400
+// carries the directory's message, e.g. a password policy violation, back to
+// the caller as invalid_request.
+func directoryError(resp *http.Response) error {
+	var body struct {
+		Error string `json:"error"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&body)
+	if resp.StatusCode == http.StatusBadRequest {
+		return &Error{"invalid_request", body.Error}
+	}
+	return fmt.Errorf("directory service returned status %d", resp.StatusCode)
+}
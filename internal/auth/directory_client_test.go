@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// newDirectoryTestClient points a client with service token svc-token at handler.
+func newDirectoryTestClient(t *testing.T, handler http.HandlerFunc) DirectoryClient {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return NewDirectoryClient(DirectoryClientConfig{BaseURL: srv.URL, ServiceAuthToken: "svc-token"})
+}
+
+func TestDirectoryClientSendsTenantAndServiceToken(t *testing.T) {
+	var gotTenant, gotToken string
+	client := newDirectoryTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		gotTenant = r.Header.Get(middleware.DefaultTenantHeader)
+		gotToken = r.Header.Get(middleware.DefaultServiceAuthHeader)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	if err := client.SetPassword(context.Background(), socialTenant, "user-1", "pw"); err != nil {
+		t.Fatalf("SetPassword failed: %v", err)
+	}
+	if gotTenant != socialTenant || gotToken != "svc-token" {
+		t.Fatalf("expected tenant and service token headers, got %q and %q", gotTenant, gotToken)
+	}
+}
+
+func TestDirectoryClientFindUserByEmail(t *testing.T) {
+	client := newDirectoryTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/users" || r.URL.Query().Get("email") != "alice@example.com" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"user":{"id":"user-1","email":"alice@example.com"}}`))
+	})
+
+	user, err := client.FindUserByEmail(context.Background(), socialTenant, "alice@example.com")
+	if err != nil || user == nil || user.ID != "user-1" {
+		t.Fatalf("expected user-1, got %+v, %v", user, err)
+	}
+	user, err = client.FindUserByEmail(context.Background(), socialTenant, "bob@example.com")
+	if err != nil || user != nil {
+		t.Fatalf("expected no user for an unknown email, got %+v, %v", user, err)
+	}
+}
+
+func TestDirectoryClientCreateUser(t *testing.T) {
+	client := newDirectoryTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			User struct {
+				Email    string `json:"email"`
+				Password string `json:"password"`
+			} `json:"user"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.User.Password == "short" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"password is too short","code":"invalid_request"}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"user_id":"user-2"}`))
+	})
+
+	user, err := client.CreateUser(context.Background(), socialTenant, "carol@example.com", "long-enough")
+	if err != nil || user.ID != "user-2" || user.Email != "carol@example.com" {
+		t.Fatalf("expected user-2, got %+v, %v", user, err)
+	}
+	_, err = client.CreateUser(context.Background(), socialTenant, "carol@example.com", "short")
+	var authErr *Error
+	if !errors.As(err, &authErr) || authErr.Code != "invalid_request" || authErr.Message != "password is too short" {
+		t.Fatalf("expected the directory's invalid_request, got %v", err)
+	}
+}
+
+func TestDirectoryClientVerifyCredentials(t *testing.T) {
+	client := newDirectoryTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Email    string `json:"email"`
+			Password string `json:"password"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		switch {
+		case r.URL.Path != "/internal/credentials/verify":
+			w.WriteHeader(http.StatusNotFound)
+		case req.Password != "correct":
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			_, _ = w.Write([]byte(`{"user":{"id":"user-1","email":"` + req.Email + `"}}`))
+		}
+	})
+
+	user, err := client.VerifyCredentials(context.Background(), socialTenant, "alice@example.com", "correct")
+	if err != nil || user.ID != "user-1" {
+		t.Fatalf("expected user-1, got %+v, %v", user, err)
+	}
+	if _, err := client.VerifyCredentials(context.Background(), socialTenant, "alice@example.com", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+	}
+}
+
+func TestDirectoryClientRecordLogin(t *testing.T) {
+	var gotUser string
+	client := newDirectoryTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			UserID string `json:"user_id"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if r.Method != http.MethodPost || r.URL.Path != "/internal/credentials/login" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		gotUser = req.UserID
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	if err := client.RecordLogin(context.Background(), socialTenant, "user-1"); err != nil {
+		t.Fatalf("RecordLogin failed: %v", err)
+	}
+	if gotUser != "user-1" {
+		t.Fatalf("expected user-1 to be recorded, got %q", gotUser)
+	}
+}
+
+func TestDirectoryClientDiscoverTenant(t *testing.T) {
+	client := newDirectoryTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(middleware.DefaultTenantHeader) != "" {
+			t.Errorf("discovery must not send a tenant header")
+		}
+		switch r.URL.Query().Get("email") {
+		case "alice@example.com":
+			_, _ = w.Write([]byte(`{"tenant_id":"` + socialTenant + `"}`))
+		case "shared@example.com":
+			w.WriteHeader(http.StatusConflict)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	tenantID, err := client.DiscoverTenant(context.Background(), "alice@example.com")
+	if err != nil || tenantID != socialTenant {
+		t.Fatalf("expected %s, got %q, %v", socialTenant, tenantID, err)
+	}
+	if _, err := client.DiscoverTenant(context.Background(), "shared@example.com"); !errors.Is(err, ErrTenantSelectionRequired) {
+		t.Fatalf("expected ErrTenantSelectionRequired, got %v", err)
+	}
+	if _, err := client.DiscoverTenant(context.Background(), "nobody@example.com"); !errors.Is(err, ErrDirectoryUserNotFound) {
+		t.Fatalf("expected ErrDirectoryUserNotFound, got %v", err)
+	}
+}
+
+func TestDirectoryClientSignsServiceTokens(t *testing.T) {
+	key := []byte("authsvc-signing-key-0123456789abcdef")
+	var status int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rec)
+		c.Request = r
+		middleware.ServiceAuth(middleware.ServiceAuthConfig{
+			Audience: "dirsvc",
+			Keys:     map[string][]byte{"authsvc": key},
+		})(c)
+		status = rec.Code
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+	client := NewDirectoryClient(DirectoryClientConfig{BaseURL: srv.URL, ServiceName: "authsvc", SigningKey: key})
+
+	if err := client.SetPassword(context.Background(), socialTenant, "user-1", "pw"); err != nil {
+		t.Fatalf("SetPassword failed: %v", err)
+	}
+	if status != http.StatusOK {
+		t.Fatalf("expected the directory to accept the signed token, got %d", status)
+	}
+}
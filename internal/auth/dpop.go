@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gopkg.in/go-jose/go-jose.v2"
+)
+
+// DPoP (RFC 9449) headers. Clients send a proof in DPoPHeader; when nonces
+// are required the server sends the nonce to use in DPoPNonceHeader.
+const (
+	DPoPHeader      = "DPoP"
+	DPoPNonceHeader = "DPoP-Nonce"
+	// DPoPTokenType is the token_type of DPoP-bound access tokens.
+	DPoPTokenType = "DPoP"
+)
+
+const (
+	// DPoPProofLifetime is how far a proof's iat may be from the current time.
+	DPoPProofLifetime = 5 * time.Minute
+	// DPoPNonceLifetime is how long a server-provided nonce is valid for, at
+	// least. Nonces are accepted for up to twice as long.
+	DPoPNonceLifetime = 5 * time.Minute
+)
+
+// ErrUseDPoPNonce is returned when nonces are required and a proof lacks the
+// current one; the response carries the nonce to retry with.
+var ErrUseDPoPNonce = &Error{"use_dpop_nonce", "DPoP proof must include the nonce from the DPoP-Nonce header"}
+
+// ErrDPoPKeyMismatch is returned when a DPoP-bound token is presented with a
+// proof for a different key.
+var ErrDPoPKeyMismatch = &Error{"invalid_token", "token is bound to a different DPoP key"}
+
+// dpopSigningMethods are the asymmetric algorithms accepted for proofs.
+var dpopSigningMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+func newInvalidDPoPProofError(detail string) *Error {
+	return &Error{"invalid_dpop_proof", detail}
+}
+
+// verifyDPoPProof checks a DPoP proof for a request to the endpoint at
+// method and uri, and returns the JWK SHA-256 thumbprint of the key it was
+// signed with. A non-empty accessToken must match the proof's ath claim.
+// Each proof is accepted once.
+func (s *authService) verifyDPoPProof(ctx context.Context, proof, method, uri, accessToken string) (string, error) {
+	var key jose.JSONWebKey
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(proof, claims, func(token *jwt.Token) (interface{}, error) {
+		if typ, _ := token.Header["typ"].(string); typ != "dpop+jwt" {
+			return nil, errors.New("typ must be dpop+jwt")
+		}
+		raw, err := json.Marshal(token.Header["jwk"])
+		if err != nil {
+			return nil, err
+		}
+		if err := key.UnmarshalJSON(raw); err != nil {
+			return nil, fmt.Errorf("invalid jwk: %w", err)
+		}
+		if !key.IsPublic() {
+			return nil, errors.New("jwk must be a public key")
+		}
+		return key.Key, nil
+	}, jwt.WithValidMethods(dpopSigningMethods))
+	if err != nil {
+		return "", newInvalidDPoPProofError(fmt.Sprintf("DPoP proof is invalid: %v", err))
+	}
+
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return "", newInvalidDPoPProofError("DPoP proof has no jti")
+	}
+	if htm, _ := claims["htm"].(string); htm != method {
+		return "", newInvalidDPoPProofError("DPoP proof htm does not match the request method")
+	}
+	if htu, _ := claims["htu"].(string); !dpopURIMatches(htu, uri) {
+		return "", newInvalidDPoPProofError("DPoP proof htu does not match the request URI")
+	}
+	iat, ok := claims["iat"].(float64)
+	if !ok {
+		return "", newInvalidDPoPProofError("DPoP proof has no iat")
+	}
+	issuedAt := time.Unix(int64(iat), 0)
+	if math.Abs(time.Since(issuedAt).Seconds()) > DPoPProofLifetime.Seconds() {
+		return "", newInvalidDPoPProofError("DPoP proof is expired or not yet valid")
+	}
+	if accessToken != "" {
+		sum := sha256.Sum256([]byte(accessToken))
+		if ath, _ := claims["ath"].(string); ath != base64.RawURLEncoding.EncodeToString(sum[:]) {
+			return "", newInvalidDPoPProofError("DPoP proof ath does not match the access token")
+		}
+	}
+	if len(s.dpopNonceKey) > 0 {
+		if nonce, _ := claims["nonce"].(string); !s.validDPoPNonce(nonce) {
+			return "", ErrUseDPoPNonce
+		}
+	}
+
+	thumbprint, err := key.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", newInvalidDPoPProofError(fmt.Sprintf("DPoP proof key has no thumbprint: %v", err))
+	}
+	jkt := base64.RawURLEncoding.EncodeToString(thumbprint)
+
+	replayed, err := s.dpopReplayStore.Use(ctx, hashToken(jkt+"|"+jti), issuedAt.Add(DPoPProofLifetime))
+	if err != nil {
+		return "", err
+	}
+	if replayed {
+		return "", newInvalidDPoPProofError("DPoP proof has already been used")
+	}
+	return jkt, nil
+}
+
+// dpopThumbprint returns the DPoP key thumbprint an access token is bound
+// to, or "" for tokens without one.
+func dpopThumbprint(claims map[string]interface{}) string {
+	cnf, _ := claims["cnf"].(map[string]interface{})
+	jkt, _ := cnf["jkt"].(string)
+	return jkt
+}
+
+// dpopURIMatches compares a proof's htu with the endpoint URI, ignoring the
+// query and fragment as RFC 9449 section 4.3 requires.
+func dpopURIMatches(htu, uri string) bool {
+	got, err := url.Parse(htu)
+	if err != nil || htu == "" {
+		return false
+	}
+	want, err := url.Parse(uri)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(got.Scheme, want.Scheme) && strings.EqualFold(got.Host, want.Host) &&
+		got.EscapedPath() == want.EscapedPath()
+}
+
+// DPoPNonce returns the nonce clients must put in DPoP proofs, or "" when
+// nonces are not required. Nonces are an HMAC of the current time window, so
+// every instance sharing the key accepts them.
+func (s *authService) DPoPNonce() string {
+	if len(s.dpopNonceKey) == 0 {
+		return ""
+	}
+	return s.dpopNonce(time.Now().Unix() / int64(DPoPNonceLifetime.Seconds()))
+}
+
+func (s *authService) dpopNonce(window int64) string {
+	mac := hmac.New(sha256.New, s.dpopNonceKey)
+	_ = binary.Write(mac, binary.BigEndian, window)
+	return strconv.FormatInt(window, 36) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// validDPoPNonce accepts nonces from the current and previous time window.
+func (s *authService) validDPoPNonce(nonce string) bool {
+	window := time.Now().Unix() / int64(DPoPNonceLifetime.Seconds())
+	for _, w := range []int64{window, window - 1} {
+		if hmac.Equal([]byte(nonce), []byte(s.dpopNonce(w))) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// DPoPReplayStore remembers accepted DPoP proofs so each is used only once.
+type DPoPReplayStore interface {
+	// Use records a proof until expiresAt and reports whether it was
+	// already recorded.
+	Use(ctx context.Context, proofHash string, expiresAt time.Time) (bool, error)
+}
+
+type dpopReplayRepo struct {
+	db *sqlx.DB
+}
+
+// NewDPoPReplayStore creates a new SQL-backed DPoP replay store.
+func NewDPoPReplayStore(db *sqlx.DB) DPoPReplayStore {
+	return &dpopReplayRepo{db: db}
+}
+
+func (r *dpopReplayRepo) Use(ctx context.Context, proofHash string, expiresAt time.Time) (bool, error) {
+	// An expired row is reused rather than reported as a replay.
+	query := `INSERT INTO dpop_proofs (proof_hash, expires_at) VALUES ($1, $2)
+		ON CONFLICT (proof_hash) DO UPDATE SET expires_at = EXCLUDED.expires_at
+		WHERE dpop_proofs.expires_at < NOW()`
+	result, err := r.db.ExecContext(ctx, query, proofHash, expiresAt)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 0, nil
+}
+
+// CleanupExpired removes proofs that can no longer be replayed (can be run periodically).
+func (r *dpopReplayRepo) CleanupExpired(ctx context.Context) error {
+	query := `DELETE FROM dpop_proofs WHERE expires_at < $1`
+	_, err := r.db.ExecContext(ctx, query, time.Now())
+	return err
+}
+
+// dpopReplayMemoryStore is an in-memory DPoPReplayStore used when no database is configured.
+type dpopReplayMemoryStore struct {
+	mu     sync.Mutex
+	proofs map[string]time.Time
+}
+
+func newDPoPReplayMemoryStore() *dpopReplayMemoryStore {
+	return &dpopReplayMemoryStore{proofs: make(map[string]time.Time)}
+}
+
+func (s *dpopReplayMemoryStore) Use(ctx context.Context, proofHash string, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for hash, expiry := range s.proofs {
+		if now.After(expiry) {
+			delete(s.proofs, hash)
+		}
+	}
+	if _, ok := s.proofs[proofHash]; ok {
+		return true, nil
+	}
+	s.proofs[proofHash] = expiresAt
+	return false, nil
+}
@@ -0,0 +1,220 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gopkg.in/go-jose/go-jose.v2"
+)
+
+// dpopKey is a client's DPoP key pair.
+type dpopKey struct {
+	t    *testing.T
+	priv *ecdsa.PrivateKey
+}
+
+func newDPoPKey(t *testing.T) dpopKey {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate DPoP key: %v", err)
+	}
+	return dpopKey{t: t, priv: priv}
+}
+
+func (k dpopKey) thumbprint() string {
+	jwk := jose.JSONWebKey{Key: &k.priv.PublicKey}
+	sum, err := jwk.Thumbprint(crypto.SHA256)
+	if err != nil {
+		k.t.Fatalf("failed to compute thumbprint: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(sum)
+}
+
+// proof signs a DPoP proof for uri. A non-empty accessToken sets ath and a
+// non-empty nonce sets nonce.
+func (k dpopKey) proof(uri, accessToken, nonce string) string {
+	claims := jwt.MapClaims{"jti": uuid.NewString(), "htm": http.MethodPost, "htu": uri, "iat": time.Now().Unix()}
+	if accessToken != "" {
+		sum := sha256.Sum256([]byte(accessToken))
+		claims["ath"] = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["typ"] = "dpop+jwt"
+	token.Header["jwk"] = jose.JSONWebKey{Key: &k.priv.PublicKey}
+	signed, err := token.SignedString(k.priv)
+	if err != nil {
+		k.t.Fatalf("failed to sign DPoP proof: %v", err)
+	}
+	return signed
+}
+
+// issueDPoPTokens runs the authorization code flow for test-client with a
+// DPoP proof at the token endpoint.
+func issueDPoPTokens(t *testing.T, as *authService, ctx context.Context, proof string) (TokenResponse, error) {
+	t.Helper()
+	verifier := "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNO1234567890abcd"
+	authResp, err := as.Authorize(ctx, AuthorizeRequest{
+		ResponseType:  "code",
+		ClientID:      "test-client",
+		RedirectURI:   "https://app.wardseal.com/callback",
+		Scope:         "openid",
+		CodeChallenge: pkceChallenge(verifier),
+	})
+	if err != nil {
+		t.Fatalf("authorize error: %v", err)
+	}
+	return as.Token(ctx, TokenRequest{
+		GrantType:    "authorization_code",
+		Code:         extractCode(t, authResp.RedirectURI),
+		RedirectURI:  "https://app.wardseal.com/callback",
+		ClientID:     "test-client",
+		CodeVerifier: verifier,
+		DPoPProof:    proof,
+	})
+}
+
+const (
+	testTokenURI      = "http://wardseal.com/oauth2/token"
+	testIntrospectURI = "http://wardseal.com/oauth2/introspect"
+)
+
+func TestDPoPProofBindsTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTestService(t)
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+	key := newDPoPKey(t)
+
+	tokenResp, err := issueDPoPTokens(t, as, ctx, key.proof(testTokenURI, "", ""))
+	if err != nil {
+		t.Fatalf("token error: %v", err)
+	}
+	if tokenResp.TokenType != DPoPTokenType {
+		t.Fatalf("expected token_type DPoP, got %q", tokenResp.TokenType)
+	}
+	claims, err := as.parseSignedToken(tokenResp.AccessToken)
+	if err != nil || dpopThumbprint(claims) != key.thumbprint() {
+		t.Fatalf("expected cnf.jkt %s, got %v", key.thumbprint(), claims["cnf"])
+	}
+
+	// Resource servers get the thumbprint to check proofs against.
+	resp, err := as.Introspect(ctx, IntrospectRequest{Token: tokenResp.AccessToken})
+	if err != nil || !resp.Active || resp.Cnf["jkt"] != key.thumbprint() {
+		t.Fatalf("expected an active token with cnf.jkt, got %+v err=%v", resp, err)
+	}
+	// A proof sent to introspection must be signed with the bound key.
+	resp, err = as.Introspect(ctx, IntrospectRequest{Token: tokenResp.AccessToken, DPoPProof: key.proof(testIntrospectURI, tokenResp.AccessToken, "")})
+	if err != nil || !resp.Active {
+		t.Fatalf("expected the bound key's proof to be accepted, got %+v err=%v", resp, err)
+	}
+	other := newDPoPKey(t)
+	_, err = as.Introspect(ctx, IntrospectRequest{Token: tokenResp.AccessToken, DPoPProof: other.proof(testIntrospectURI, tokenResp.AccessToken, "")})
+	if !errors.Is(err, ErrDPoPKeyMismatch) {
+		t.Fatalf("expected ErrDPoPKeyMismatch for another key, got %v", err)
+	}
+
+	// The refresh token only rotates with a proof for the same key.
+	refresh := TokenRequest{GrantType: "refresh_token", RefreshToken: tokenResp.RefreshToken}
+	var svcErr *Error
+	refresh.DPoPProof = other.proof(testTokenURI, "", "")
+	if _, err := as.Token(ctx, refresh); !errors.As(err, &svcErr) || svcErr.Code != "invalid_grant" {
+		t.Fatalf("expected invalid_grant for another key, got %v", err)
+	}
+	refresh.DPoPProof = key.proof(testTokenURI, "", "")
+	refreshed, err := as.Token(ctx, refresh)
+	if err != nil || refreshed.TokenType != DPoPTokenType {
+		t.Fatalf("expected a DPoP-bound refresh, got %+v err=%v", refreshed, err)
+	}
+}
+
+func TestDPoPReplayedProofRejected(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTestService(t)
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+	key := newDPoPKey(t)
+	proof := key.proof(testTokenURI, "", "")
+
+	if _, err := issueDPoPTokens(t, as, ctx, proof); err != nil {
+		t.Fatalf("token error: %v", err)
+	}
+	_, err := issueDPoPTokens(t, as, ctx, proof)
+	var svcErr *Error
+	if !errors.As(err, &svcErr) || svcErr.Code != "invalid_dpop_proof" {
+		t.Fatalf("expected invalid_dpop_proof for a replayed proof, got %v", err)
+	}
+
+	// Proofs for another endpoint or signed with a different key than the
+	// one they carry are rejected too.
+	if _, err := issueDPoPTokens(t, as, ctx, key.proof(testIntrospectURI, "", "")); !errors.As(err, &svcErr) || svcErr.Code != "invalid_dpop_proof" {
+		t.Fatalf("expected invalid_dpop_proof for the wrong htu, got %v", err)
+	}
+	forged := newDPoPKey(t).proof(testTokenURI, "", "")
+	parts := strings.Split(forged, ".")
+	parts[2] = strings.Split(key.proof(testTokenURI, "", ""), ".")[2]
+	if _, err := issueDPoPTokens(t, as, ctx, strings.Join(parts, ".")); !errors.As(err, &svcErr) || svcErr.Code != "invalid_dpop_proof" {
+		t.Fatalf("expected invalid_dpop_proof for a bad signature, got %v", err)
+	}
+}
+
+func TestDPoPNonceRequired(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTestService(t)
+	as.dpopNonceKey = []byte("0123456789abcdef0123456789abcdef")
+	router := gin.New()
+	NewHTTPHandler(as, zap.NewNop(), nil).RegisterRoutes(router)
+	key := newDPoPKey(t)
+
+	token := func(proof string) *httptest.ResponseRecorder {
+		form := url.Values{"grant_type": {"client_credentials"}, "client_id": {"test-client"}}
+		req := httptest.NewRequest(http.MethodPost, "/oauth2/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set(middleware.DefaultTenantHeader, "11111111-1111-1111-1111-111111111111")
+		req.Header.Set(DPoPHeader, proof)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := token(key.proof(testTokenURI, "", ""))
+	var body map[string]string
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+	if w.Code != http.StatusBadRequest || body["error"] != "use_dpop_nonce" {
+		t.Fatalf("expected use_dpop_nonce, got %d %s", w.Code, w.Body)
+	}
+	nonce := w.Header().Get(DPoPNonceHeader)
+	if nonce == "" {
+		t.Fatalf("expected a DPoP-Nonce header")
+	}
+
+	// With the nonce the proof passes; test-client is public, so the grant
+	// itself is then refused.
+	w = token(key.proof(testTokenURI, "", nonce))
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+	if body["error"] != "unauthorized_client" {
+		t.Fatalf("expected the proof to be accepted, got %d %s", w.Code, w.Body)
+	}
+	if w := token(key.proof(testTokenURI, "", "not-a-nonce")); !strings.Contains(w.Body.String(), "use_dpop_nonce") {
+		t.Fatalf("expected a forged nonce to be rejected, got %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"go.uber.org/zap"
+)
+
+var verifyEmailPageTemplate = template.Must(template.New("verify-email").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Verify your email</title></head>
+<body>
+{{if .Done}}
+<h1>{{.Done}}</h1>
+<p>You can close this page and sign in.</p>
+{{else}}
+<h1>Verify your email</h1>
+{{if .Error}}<p>{{.Error}}</p>{{else}}
+<form method="POST" action="/auth/verify-email">
+<input type="hidden" name="token" value="{{.Token}}">
+<button type="submit">Verify email</button>
+</form>
+{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+// verifyEmailPage handles GET /auth/verify-email, the page the emailed link
+// opens. Verification waits for the button so mail scanners that prefetch
+// links do not use up the token.
+func (h *HTTPHandler) verifyEmailPage(c *gin.Context) {
+	h.renderVerifyEmailPage(c, http.StatusOK, gin.H{"Token": c.Query("token")})
+}
+
+// verifyEmail handles POST /auth/verify-email. The token carries its own
+// tenant, so the route does not require the tenant header. Form posts from
+// the verification page get the page back; JSON callers get JSON.
+func (h *HTTPHandler) verifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Token == "" {
+		req.Token = c.Query("token")
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	fromPage := c.ContentType() == binding.MIMEPOSTForm
+	if err := h.svc.VerifyEmail(c.Request.Context(), req.Token); err != nil {
+		svcErr := &Error{}
+		if errors.As(err, &svcErr) {
+			if fromPage {
+				h.renderVerifyEmailPage(c, http.StatusBadRequest, gin.H{"Error": svcErr.Message})
+				return
+			}
+			h.respondOAuthError(c, svcErr)
+			return
+		}
+		h.logger.Error("Email verification failed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify email"})
+		return
+	}
+	if fromPage {
+		h.renderVerifyEmailPage(c, http.StatusOK, gin.H{"Done": "Email verified"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"verified": true})
+}
+
+func (h *HTTPHandler) renderVerifyEmailPage(c *gin.Context, status int, data gin.H) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(status)
+	if err := verifyEmailPageTemplate.Execute(c.Writer, data); err != nil {
+		h.logger.Error("Failed to render email verification page", zap.Error(err))
+	}
+}
+
+// getEmailVerificationPolicy handles GET /api/v1/email-verification/policy.
+func (h *HTTPHandler) getEmailVerificationPolicy(c *gin.Context) {
+	tenantID, err := middleware.TenantIDFromGinContext(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tenant ID required"})
+		return
+	}
+	policy, err := h.svc.EmailVerificationPolicy(c.Request.Context(), tenantID)
+	if err != nil {
+		h.logger.Error("Failed to fetch email verification policy", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch email verification policy"})
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
+
+// updateEmailVerificationPolicy handles PUT /api/v1/email-verification/policy.
+func (h *HTTPHandler) updateEmailVerificationPolicy(c *gin.Context) {
+	tenantID, err := middleware.TenantIDFromGinContext(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tenant ID required"})
+		return
+	}
+	var req EmailVerificationPolicy
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.svc.UpdateEmailVerificationPolicy(c.Request.Context(), tenantID, req); err != nil {
+		h.logger.Error("Failed to update email verification policy", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update email verification policy"})
+		return
+	}
+	c.JSON(http.StatusOK, req)
+}
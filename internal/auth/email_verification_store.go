@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// EmailVerificationStore tracks issued verification tokens, which accounts
+// have verified their email address, and each tenant's login policy.
+type EmailVerificationStore interface {
+	// SaveToken records an issued token by its ID (the JWT "jti").
+	SaveToken(ctx context.Context, tokenID, tenantID, userID string, expiresAt time.Time) error
+	// ConsumeToken marks the token used. It reports false when the token is
+	// unknown or was already used.
+	ConsumeToken(ctx context.Context, tokenID string) (bool, error)
+	MarkVerified(ctx context.Context, tenantID, userID, email string) error
+	IsVerified(ctx context.Context, tenantID, userID string) (bool, error)
+	// RequireVerifiedLogin reports whether the tenant blocks password login
+	// until the account's email address is verified.
+	RequireVerifiedLogin(ctx context.Context, tenantID string) (bool, error)
+	SetRequireVerifiedLogin(ctx context.Context, tenantID string, required bool) error
+}
+
+type emailVerificationRepo struct {
+	db *sqlx.DB
+}
+
+// NewEmailVerificationStore creates a new SQL-backed email verification store.
+func NewEmailVerificationStore(db *sqlx.DB) EmailVerificationStore {
+	return &emailVerificationRepo{db: db}
+}
+
+func (r *emailVerificationRepo) SaveToken(ctx context.Context, tokenID, tenantID, userID string, expiresAt time.Time) error {
+	query := `
+		INSERT INTO email_verification_tokens (id, tenant_id, user_id, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`
+	_, err := r.db.ExecContext(ctx, query, tokenID, tenantID, userID, expiresAt)
+	return err
+}
+
+func (r *emailVerificationRepo) ConsumeToken(ctx context.Context, tokenID string) (bool, error) {
+	query := `UPDATE email_verification_tokens SET used_at = NOW() WHERE id = $1 AND used_at IS NULL`
+	res, err := r.db.ExecContext(ctx, query, tokenID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (r *emailVerificationRepo) MarkVerified(ctx context.Context, tenantID, userID, email string) error {
+	query := `
+		INSERT INTO email_verifications (tenant_id, user_id, email)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (tenant_id, user_id)
+		DO UPDATE SET email = EXCLUDED.email, verified_at = NOW()
+	`
+	_, err := r.db.ExecContext(ctx, query, tenantID, userID, email)
+	return err
+}
+
+func (r *emailVerificationRepo) IsVerified(ctx context.Context, tenantID, userID string) (bool, error) {
+	var verified bool
+	query := `SELECT EXISTS (SELECT 1 FROM email_verifications WHERE tenant_id = $1 AND user_id = $2)`
+	if err := r.db.GetContext(ctx, &verified, query, tenantID, userID); err != nil {
+		return false, err
+	}
+	return verified, nil
+}
+
+func (r *emailVerificationRepo) RequireVerifiedLogin(ctx context.Context, tenantID string) (bool, error) {
+	var required bool
+	query := `SELECT require_verified_login FROM email_verification_policies WHERE tenant_id = $1`
+	if err := r.db.GetContext(ctx, &required, query, tenantID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return required, nil
+}
+
+func (r *emailVerificationRepo) SetRequireVerifiedLogin(ctx context.Context, tenantID string, required bool) error {
+	query := `
+		INSERT INTO email_verification_policies (tenant_id, require_verified_login)
+		VALUES ($1, $2)
+		ON CONFLICT (tenant_id)
+		DO UPDATE SET require_verified_login = EXCLUDED.require_verified_login, updated_at = NOW()
+	`
+	_, err := r.db.ExecContext(ctx, query, tenantID, required)
+	return err
+}
+
+// CleanupExpired removes expired verification tokens (can be run periodically).
+func (r *emailVerificationRepo) CleanupExpired(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM email_verification_tokens WHERE expires_at < $1`, time.Now())
+	return err
+}
+
+// emailVerificationMemoryStore is an in-memory EmailVerificationStore used when no database is configured.
+type emailVerificationMemoryStore struct {
+	mu       sync.Mutex
+	tokens   map[string]bool // token ID -> used
+	verified map[string]string
+	policies map[string]bool
+}
+
+func newEmailVerificationMemoryStore() *emailVerificationMemoryStore {
+	return &emailVerificationMemoryStore{
+		tokens:   make(map[string]bool),
+		verified: make(map[string]string),
+		policies: make(map[string]bool),
+	}
+}
+
+func (s *emailVerificationMemoryStore) SaveToken(ctx context.Context, tokenID, tenantID, userID string, expiresAt time.Time) error {
+	s.mu.Lock()
+	s.tokens[tokenID] = false
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *emailVerificationMemoryStore) ConsumeToken(ctx context.Context, tokenID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	used, ok := s.tokens[tokenID]
+	if !ok || used {
+		return false, nil
+	}
+	s.tokens[tokenID] = true
+	return true, nil
+}
+
+func (s *emailVerificationMemoryStore) MarkVerified(ctx context.Context, tenantID, userID, email string) error {
+	s.mu.Lock()
+	s.verified[tenantID+"::"+userID] = email
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *emailVerificationMemoryStore) IsVerified(ctx context.Context, tenantID, userID string) (bool, error) {
+	s.mu.Lock()
+	_, ok := s.verified[tenantID+"::"+userID]
+	s.mu.Unlock()
+	return ok, nil
+}
+
+func (s *emailVerificationMemoryStore) RequireVerifiedLogin(ctx context.Context, tenantID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.policies[tenantID], nil
+}
+
+func (s *emailVerificationMemoryStore) SetRequireVerifiedLogin(ctx context.Context, tenantID string, required bool) error {
+	s.mu.Lock()
+	s.policies[tenantID] = required
+	s.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type captureMailer struct {
+	links      []string
+	resetLinks []string
+}
+
+func (m *captureMailer) SendVerificationEmail(ctx context.Context, tenantID, email, link string) error {
+	m.links = append(m.links, link)
+	return nil
+}
+
+func (m *captureMailer) SendPasswordResetEmail(ctx context.Context, tenantID, email, link string) error {
+	m.resetLinks = append(m.resetLinks, link)
+	return nil
+}
+
+func sendTestVerification(t *testing.T, as *authService) string {
+	t.Helper()
+	mailer := &captureMailer{}
+	as.mailer = mailer
+	if err := as.SendEmailVerification(context.Background(), "11111111-1111-1111-1111-111111111111", "user-1", "user@example.com"); err != nil {
+		t.Fatalf("send verification error: %v", err)
+	}
+	if len(mailer.links) != 1 {
+		t.Fatalf("expected one verification link, got %d", len(mailer.links))
+	}
+	link, err := url.Parse(mailer.links[0])
+	if err != nil || link.Path != "/auth/verify-email" {
+		t.Fatalf("unexpected verification link %q", mailer.links[0])
+	}
+	return link.Query().Get("token")
+}
+
+func TestVerifyEmailSuccess(t *testing.T) {
+	as := newTestService(t)
+	ctx := context.Background()
+	tenantID := "11111111-1111-1111-1111-111111111111"
+	if err := as.UpdateEmailVerificationPolicy(ctx, tenantID, EmailVerificationPolicy{RequireVerifiedLogin: true}); err != nil {
+		t.Fatalf("update policy error: %v", err)
+	}
+
+	token := sendTestVerification(t, as)
+	if err := as.checkEmailVerified(ctx, tenantID, "user-1"); !errors.Is(err, ErrEmailNotVerified) {
+		t.Fatalf("expected login to be blocked before verification, got %v", err)
+	}
+	if err := as.VerifyEmail(ctx, token); err != nil {
+		t.Fatalf("verify email error: %v", err)
+	}
+	if err := as.checkEmailVerified(ctx, tenantID, "user-1"); err != nil {
+		t.Fatalf("expected login to be allowed after verification, got %v", err)
+	}
+}
+
+func TestVerifyEmailExpiredToken(t *testing.T) {
+	as := newTestService(t)
+	ctx := context.Background()
+
+	token, err := as.emailVerificationToken(ctx, "11111111-1111-1111-1111-111111111111", "user-1", "user@example.com", time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("failed to issue token: %v", err)
+	}
+	if err := as.VerifyEmail(ctx, token); !errors.Is(err, ErrVerificationTokenExpired) {
+		t.Fatalf("expected expired token error, got %v", err)
+	}
+}
+
+func TestVerifyEmailReusedToken(t *testing.T) {
+	as := newTestService(t)
+	ctx := context.Background()
+
+	token := sendTestVerification(t, as)
+	if err := as.VerifyEmail(ctx, token); err != nil {
+		t.Fatalf("verify email error: %v", err)
+	}
+	if err := as.VerifyEmail(ctx, token); !errors.Is(err, ErrVerificationTokenUsed) {
+		t.Fatalf("expected reused token error, got %v", err)
+	}
+}
+
+func TestEmailVerificationPolicyUpdateRequiresPermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTestService(t)
+	const tenantID = "11111111-1111-1111-1111-111111111111"
+	admin, _ := as.generateUserToken(tenantID, "admin-1")
+	member, _ := as.generateUserToken(tenantID, "user-1")
+
+	router := gin.New()
+	handler := NewHTTPHandler(as, zap.NewNop(), nil)
+	handler.UsePermissions(permissionGrants{"admin-1": {"email_verification_policy:update"}})
+	handler.RegisterRoutes(router)
+	put := func(bearer string) int {
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/email-verification/policy", strings.NewReader(`{"require_verified_login":true}`))
+		req.Header.Set(middleware.DefaultTenantHeader, tenantID)
+		req.Header.Set("Content-Type", "application/json")
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp.Code
+	}
+
+	if code := put(""); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a session, got %d", code)
+	}
+	if code := put(member); code != http.StatusForbidden {
+		t.Fatalf("expected 403 without email_verification_policy:update, got %d", code)
+	}
+	if policy, _ := as.EmailVerificationPolicy(context.Background(), tenantID); policy.RequireVerifiedLogin {
+		t.Fatal("expected a refused update to leave the policy unchanged")
+	}
+	if code := put(admin); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if policy, _ := as.EmailVerificationPolicy(context.Background(), tenantID); !policy.RequireVerifiedLogin {
+		t.Fatal("expected the policy to be updated")
+	}
+}
+
+func TestVerifyEmailLinkOpensConfirmationPage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTestService(t)
+	router := gin.New()
+	NewHTTPHandler(as, zap.NewNop(), nil).RegisterRoutes(router)
+	ctx := context.Background()
+	tenantID := "11111111-1111-1111-1111-111111111111"
+	if err := as.UpdateEmailVerificationPolicy(ctx, tenantID, EmailVerificationPolicy{RequireVerifiedLogin: true}); err != nil {
+		t.Fatalf("update policy error: %v", err)
+	}
+	token := sendTestVerification(t, as)
+
+	// Opening the link only shows the page.
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/auth/verify-email?token="+url.QueryEscape(token), nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `value="`+token+`"`) {
+		t.Fatalf("expected a confirmation form carrying the token, got %d %q", w.Code, w.Body)
+	}
+	if err := as.checkEmailVerified(ctx, tenantID, "user-1"); !errors.Is(err, ErrEmailNotVerified) {
+		t.Fatalf("expected GET to leave the email unverified, got %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/verify-email", strings.NewReader(url.Values{"token": {token}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Email verified") {
+		t.Fatalf("expected the confirmation to verify the email, got %d %q", w.Code, w.Body)
+	}
+	if err := as.checkEmailVerified(ctx, tenantID, "user-1"); err != nil {
+		t.Fatalf("expected login to be allowed after verification, got %v", err)
+	}
+}
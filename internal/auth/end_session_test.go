@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func issueTestTokens(t *testing.T, as *authService) TokenResponse {
+	t.Helper()
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+	verifier := "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNO1234567890abcd"
+	authResp, err := as.Authorize(ctx, AuthorizeRequest{
+		ResponseType:  "code",
+		ClientID:      "test-client",
+		RedirectURI:   "https://app.wardseal.com/callback",
+		Scope:         "openid profile",
+		CodeChallenge: pkceChallenge(verifier),
+	})
+	if err != nil {
+		t.Fatalf("authorize error: %v", err)
+	}
+	tokens, err := as.Token(ctx, TokenRequest{
+		GrantType:    "authorization_code",
+		Code:         extractCode(t, authResp.RedirectURI),
+		RedirectURI:  "https://app.wardseal.com/callback",
+		ClientID:     "test-client",
+		CodeVerifier: verifier,
+	})
+	if err != nil {
+		t.Fatalf("token error: %v", err)
+	}
+	if tokens.IDToken == "" {
+		t.Fatalf("expected an id_token for the openid scope")
+	}
+	return tokens
+}
+
+func TestEndSessionRevokesRefreshTokenFamily(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTestService(t)
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+	tokens := issueTestTokens(t, as)
+
+	// Rotate once so the family holds a token other than the original.
+	rotated, err := as.Token(ctx, TokenRequest{GrantType: "refresh_token", RefreshToken: tokens.RefreshToken})
+	if err != nil {
+		t.Fatalf("refresh error: %v", err)
+	}
+
+	redirect, err := as.EndSession(ctx, EndSessionRequest{
+		IDTokenHint:           tokens.IDToken,
+		PostLogoutRedirectURI: "https://app.wardseal.com/logged-out",
+		State:                 "bye",
+	})
+	if err != nil {
+		t.Fatalf("end session error: %v", err)
+	}
+	parsed, err := url.Parse(redirect)
+	if err != nil || parsed.Host != "app.wardseal.com" || parsed.Query().Get("state") != "bye" {
+		t.Fatalf("unexpected post-logout redirect %q", redirect)
+	}
+
+	if _, err := as.Token(ctx, TokenRequest{GrantType: "refresh_token", RefreshToken: rotated.RefreshToken}); err == nil {
+		t.Fatalf("expected refresh token family to be revoked")
+	}
+}
+
+func TestEndSessionRejectsUnregisteredRedirect(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTestService(t)
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+	tokens := issueTestTokens(t, as)
+
+	_, err := as.EndSession(ctx, EndSessionRequest{
+		IDTokenHint:           tokens.IDToken,
+		PostLogoutRedirectURI: "https://evil.example.com/",
+	})
+	if !errors.Is(err, ErrInvalidPostLogoutRedirectURI) {
+		t.Fatalf("expected ErrInvalidPostLogoutRedirectURI, got %v", err)
+	}
+
+	// A rejected logout must not revoke anything.
+	if _, err := as.Token(ctx, TokenRequest{GrantType: "refresh_token", RefreshToken: tokens.RefreshToken}); err != nil {
+		t.Fatalf("expected refresh token to remain valid, got %v", err)
+	}
+}
+
+func TestEndSessionRejectsInvalidHint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTestService(t)
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+
+	if _, err := as.EndSession(ctx, EndSessionRequest{IDTokenHint: "not-a-token"}); !errors.Is(err, ErrInvalidIDTokenHint) {
+		t.Fatalf("expected ErrInvalidIDTokenHint, got %v", err)
+	}
+}
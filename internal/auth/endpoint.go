@@ -20,17 +20,51 @@ type AuthorizeRequest struct {
 	State               string `form:"state" json:"state"`
 	CodeChallenge       string `form:"code_challenge" json:"code_challenge" validate:"required"`
 	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method" validate:"omitempty,oneof=S256"`
+	// Resource is the resource indicator (RFC 8707) the tokens are for. It
+	// must be one of the client's AllowedResources.
+	Resource string `form:"resource" json:"resource"`
+
+	// SessionToken is the signed-in user's session, set by the HTTP handler from
+	// the session cookie; never read from the request parameters.
+	SessionToken string `form:"-" json:"-"`
 }
 
 // AuthorizeResponse holds the response values for the Authorize endpoint.
 type AuthorizeResponse struct {
 	RedirectURI string `json:"redirect_uri"`
+	// ConsentRequired is set instead of RedirectURI when the user must first
+	// approve the requested scopes on the consent screen.
+	ConsentRequired bool `json:"consent_required,omitempty"`
+}
+
+// ConsentRequest is the consent screen submission. It repeats the original
+// authorize parameters along with the user's decision and the scopes granted,
+// which may be a subset of those requested.
+type ConsentRequest struct {
+	AuthorizeRequest
+	Decision      string   `form:"decision" json:"decision" validate:"required,oneof=approve deny"`
+	GrantedScopes []string `form:"granted_scope" json:"granted_scopes"`
+}
+
+// ConsentPrompt describes what the consent screen asks the user to approve.
+type ConsentPrompt struct {
+	ClientID   string         `json:"client_id"`
+	ClientName string         `json:"client_name"`
+	Scopes     []ConsentScope `json:"scopes"`
+}
+
+// ConsentScope is a requested scope as described by the tenant's scope catalog.
+type ConsentScope struct {
+	Scope       string `json:"scope"`
+	DisplayName string `json:"display_name"`
+	Description string `json:"description,omitempty"`
+	Sensitive   bool   `json:"sensitive"`
 }
 
 // TokenRequest holds the request parameters for the Token endpoint.
 // Fields are conditionally required based on grant_type.
 type TokenRequest struct {
-	GrantType string `form:"grant_type" json:"grant_type" validate:"required,oneof=authorization_code client_credentials refresh_token"`
+	GrantType string `form:"grant_type" json:"grant_type" validate:"required,oneof=authorization_code client_credentials refresh_token urn:ietf:params:oauth:grant-type:device_code urn:ietf:params:oauth:grant-type:token-exchange"`
 
 	// For authorization_code grant
 	Code         string `form:"code" json:"code"`
@@ -41,9 +75,31 @@ type TokenRequest struct {
 	ClientID     string `form:"client_id" json:"client_id"`
 	ClientSecret string `form:"client_secret" json:"client_secret"`
 	Scope        string `form:"scope" json:"scope"`
+	// Resource is the resource indicator (RFC 8707) the access token is for.
+	// With a code or refresh token it must match the resource they were
+	// issued for, if any.
+	Resource string `form:"resource" json:"resource"`
 
 	// For refresh_token grant
 	RefreshToken string `form:"refresh_token" json:"refresh_token"`
+
+	// For the device_code grant (RFC 8628)
+	DeviceCode string `form:"device_code" json:"device_code"`
+
+	// For the token-exchange grant (RFC 8693)
+	SubjectToken       string `form:"subject_token" json:"subject_token"`
+	SubjectTokenType   string `form:"subject_token_type" json:"subject_token_type"`
+	RequestedTokenType string `form:"requested_token_type" json:"requested_token_type"`
+	Audience           string `form:"audience" json:"audience"`
+
+	// Caller metadata set by the HTTP handler for token binding; never read from the request body.
+	ClientIP  string `form:"-" json:"-"`
+	UserAgent string `form:"-" json:"-"`
+	// DPoPProof is the DPoP header (RFC 9449), set by the HTTP handler.
+	DPoPProof string `form:"-" json:"-"`
+
+	// dpopJKT is the thumbprint of the key DPoPProof was verified against.
+	dpopJKT string
 }
 
 // TokenResponse holds the response values for the Token endpoint.
@@ -54,12 +110,24 @@ type TokenResponse struct {
 	RefreshToken string `json:"refresh_token,omitempty"`
 	IDToken      string `json:"id_token,omitempty"`
 	Scope        string `json:"scope,omitempty"`
+	// IssuedTokenType is set for token exchange responses (RFC 8693).
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
 }
 
 // IntrospectRequest holds the request parameters for the Introspect endpoint.
 type IntrospectRequest struct {
 	Token         string `form:"token" json:"token" validate:"required"`
 	TokenTypeHint string `form:"token_type_hint" json:"token_type_hint"`
+	// PresenterIP and PresenterUserAgent identify who presented a bound token.
+	// Resource servers should forward them; they default to the introspection caller.
+	PresenterIP        string `form:"presenter_ip" json:"presenter_ip"`
+	PresenterUserAgent string `form:"presenter_user_agent" json:"presenter_user_agent"`
+	// Resource, when set, is the resource server the token was presented to.
+	// Access tokens whose audience is a different resource are inactive.
+	Resource string `form:"resource" json:"resource"`
+	// DPoPProof, when set, is a DPoP proof for the introspection request
+	// signed with the key a DPoP-bound token is bound to.
+	DPoPProof string `form:"-" json:"-"`
 }
 
 // IntrospectResponse holds the response values for the Introspect endpoint.
@@ -72,9 +140,15 @@ type IntrospectResponse struct {
 	Exp       int64  `json:"exp,omitempty"`
 	Iat       int64  `json:"iat,omitempty"`
 	Sub       string `json:"sub,omitempty"`
-	Aud       string `json:"aud,omitempty"`
-	Iss       string `json:"iss,omitempty"`
-	TenantID  string `json:"tenant_id,omitempty"`
+	// Aud is a string, or an array for tokens with several audiences.
+	Aud      interface{} `json:"aud,omitempty"`
+	Iss      string      `json:"iss,omitempty"`
+	TenantID string      `json:"tenant_id,omitempty"`
+	// Act identifies the party acting on the subject's behalf for exchanged tokens (RFC 8693).
+	Act map[string]interface{} `json:"act,omitempty"`
+	// Cnf holds the jkt thumbprint of a DPoP-bound token's key, which
+	// resource servers check DPoP proofs against.
+	Cnf map[string]string `json:"cnf,omitempty"`
 }
 
 // RevokeRequest holds the request parameters for the Revoke endpoint.
@@ -82,3 +156,88 @@ type RevokeRequest struct {
 	Token         string `form:"token" json:"token" validate:"required"`
 	TokenTypeHint string `form:"token_type_hint" json:"token_type_hint"`
 }
+
+// DeviceAuthorizationRequest starts the device authorization grant (RFC 8628).
+type DeviceAuthorizationRequest struct {
+	ClientID string `form:"client_id" json:"client_id" validate:"required"`
+	Scope    string `form:"scope" json:"scope" validate:"required"`
+}
+
+// DeviceAuthorizationResponse tells the device which code to display and how
+// often it may poll the token endpoint.
+type DeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// DeviceApprovalRequest is the user's decision on the device verification page.
+type DeviceApprovalRequest struct {
+	UserCode string `form:"user_code" json:"user_code" validate:"required"`
+	Decision string `form:"decision" json:"decision" validate:"required,oneof=approve deny"`
+
+	// SessionToken is set by the HTTP handler from the session cookie.
+	SessionToken string `form:"-" json:"-"`
+}
+
+// EndSessionRequest holds the parameters of the RP-initiated logout endpoint.
+type EndSessionRequest struct {
+	IDTokenHint           string `form:"id_token_hint" json:"id_token_hint" validate:"required"`
+	ClientID              string `form:"client_id" json:"client_id"`
+	PostLogoutRedirectURI string `form:"post_logout_redirect_uri" json:"post_logout_redirect_uri"`
+	State                 string `form:"state" json:"state"`
+}
+
+// VerifyEmailRequest redeems an email verification link.
+type VerifyEmailRequest struct {
+	Token string `form:"token" json:"token" validate:"required"`
+}
+
+// EmailVerificationPolicy is a tenant's email verification setting.
+type EmailVerificationPolicy struct {
+	RequireVerifiedLogin bool `json:"require_verified_login"`
+}
+
+// ImpersonationPolicy is a tenant's impersonation setting. MaxMinutes caps
+// the lifetime of impersonation tokens; zero means DefaultImpersonationMinutes.
+type ImpersonationPolicy struct {
+	Disabled   bool `json:"disabled" db:"disabled"`
+	MaxMinutes int  `json:"max_minutes" db:"max_minutes" validate:"min=0,max=60"`
+}
+
+// ImpersonationRequest asks for a token acting as another user of the tenant.
+type ImpersonationRequest struct {
+	UserID string `json:"user_id" validate:"required"`
+	// Minutes shortens the token's lifetime below the tenant's maximum.
+	Minutes int `json:"minutes" validate:"min=0,max=60"`
+
+	// ActorToken is set by the HTTP handler from the administrator's session.
+	ActorToken string `json:"-"`
+}
+
+// StopImpersonationRequest ends an impersonation by revoking its token.
+type StopImpersonationRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// Impersonation is an impersonation token together with the impersonated
+// user (Subject) and the administrator acting as them (Actor).
+type Impersonation struct {
+	TokenResponse
+	Subject string `json:"subject"`
+	Actor   string `json:"actor"`
+}
+
+// ForgotPasswordRequest asks for a password reset link.
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResetPasswordRequest sets a new password using a reset link's token.
+type ResetPasswordRequest struct {
+	Token    string `form:"token" json:"token" validate:"required"`
+	Password string `form:"password" json:"password" validate:"required,min=8"`
+}
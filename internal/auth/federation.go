@@ -3,7 +3,9 @@ package auth
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/jmoiron/sqlx"
@@ -11,19 +13,39 @@ import (
 
 // FederatedIdentity represents a link between a local user and an external identity provider.
 type FederatedIdentity struct {
-	ID          string    `db:"id"`
-	IdentityID  string    `db:"identity_id"`
-	TenantID    string    `db:"tenant_id"`
-	Provider    string    `db:"provider"`
-	ExternalID  string    `db:"external_id"`
-	ProfileData JSON      `db:"profile_data"`
-	CreatedAt   time.Time `db:"created_at"`
-	UpdatedAt   time.Time `db:"updated_at"`
+	ID          string `db:"id"`
+	IdentityID  string `db:"identity_id"`
+	TenantID    string `db:"tenant_id"`
+	Provider    string `db:"provider"`
+	ExternalID  string `db:"external_id"`
+	ProfileData JSON   `db:"profile_data"`
+	// RefreshToken is the provider's refresh token, encrypted. It is only
+	// set when the user granted offline_access.
+	RefreshToken *string   `db:"refresh_token"`
+	CreatedAt    time.Time `db:"created_at"`
+	UpdatedAt    time.Time `db:"updated_at"`
+}
+
+// Profile decodes the profile recorded when the identity was linked. Links
+// made before full profiles were stored only carry the email.
+func (f FederatedIdentity) Profile() (FederatedProfile, error) {
+	var p FederatedProfile
+	if len(f.ProfileData) == 0 || string(f.ProfileData) == "null" {
+		return p, nil
+	}
+	if err := json.Unmarshal(f.ProfileData, &p); err != nil {
+		return FederatedProfile{}, fmt.Errorf("decode federated profile: %w", err)
+	}
+	return p, nil
 }
 
 type FederationStore interface {
 	Get(ctx context.Context, tenantID, provider, externalID string) (*FederatedIdentity, error)
+	// GetByUser returns the user's link to provider, or nil.
+	GetByUser(ctx context.Context, tenantID, provider, identityID string) (*FederatedIdentity, error)
 	Create(ctx context.Context, identity FederatedIdentity) error
+	// SetRefreshToken replaces the link's stored refresh token; nil clears it.
+	SetRefreshToken(ctx context.Context, id string, refreshToken *string) error
 	List(ctx context.Context, identityID string) ([]FederatedIdentity, error)
 	Delete(ctx context.Context, id string) error
 }
@@ -51,6 +73,22 @@ func (s *sqlFederationStore) Get(ctx context.Context, tenantID, provider, extern
 	return &f, nil
 }
 
+func (s *sqlFederationStore) GetByUser(ctx context.Context, tenantID, provider, identityID string) (*FederatedIdentity, error) {
+	var f FederatedIdentity
+	err := s.db.GetContext(ctx, &f, `
+		SELECT * FROM federated_identities
+		WHERE tenant_id = $1 AND provider = $2 AND identity_id = $3
+		ORDER BY created_at LIMIT 1
+	`, tenantID, provider, identityID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &f, nil
+}
+
 func (s *sqlFederationStore) Create(ctx context.Context, identity FederatedIdentity) error {
 	query := `
 		INSERT INTO federated_identities (tenant_id, identity_id, provider, external_id, profile_data, created_at, updated_at)
@@ -68,6 +106,12 @@ func (s *sqlFederationStore) Create(ctx context.Context, identity FederatedIdent
 	return nil
 }
 
+func (s *sqlFederationStore) SetRefreshToken(ctx context.Context, id string, refreshToken *string) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE federated_identities SET refresh_token = $1, updated_at = NOW() WHERE id = $2`, refreshToken, id)
+	return err
+}
+
 func (s *sqlFederationStore) List(ctx context.Context, identityID string) ([]FederatedIdentity, error) {
 	var identities []FederatedIdentity
 	err := s.db.SelectContext(ctx, &identities, `SELECT * FROM federated_identities WHERE identity_id = $1`, identityID)
@@ -1,13 +1,71 @@
 package auth
 
 import (
+	"crypto/subtle"
 	"errors"
 	"net/http"
+	"os"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
+// SocialStateCookie binds a social login to the browser that started it, so
+// a callback URL carrying someone else's state cannot sign this browser in.
+const SocialStateCookie = "wardseal_social_state"
+
+// socialStateCookiePath covers the start and callback routes of every
+// provider.
+const socialStateCookiePath = "/auth/sso/"
+
+// startSocialLogin redirects the browser to the provider's authorize
+// endpoint with a fresh state, nonce and PKCE challenge, and sets the state
+// in a short-lived cookie for the callback to check.
+func (h *HTTPHandler) startSocialLogin(c *gin.Context) {
+	authorizeURL, state, err := h.svc.StartSocialLogin(c.Request.Context(), c.Param("provider"), c.Query("redirect_uri"))
+	if err != nil {
+		h.respondSocialError(c, err)
+		return
+	}
+	// Lax, as the provider redirects back with a cross-site navigation.
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(SocialStateCookie, state, int(socialLoginStateTTL.Seconds()), socialStateCookiePath, "",
+		os.Getenv("ENVIRONMENT") == "production", true)
+	c.Redirect(http.StatusFound, authorizeURL)
+}
+
+// socialLoginCallback receives the provider's redirect. The state must match
+// the browser's cookie and is checked before the code is exchanged.
+func (h *HTTPHandler) socialLoginCallback(c *gin.Context) {
+	bound, _ := c.Cookie(SocialStateCookie)
+	c.SetCookie(SocialStateCookie, "", -1, socialStateCookiePath, "", false, true)
+	if upstreamErr := c.Query("error"); upstreamErr != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": upstreamErr, "error_description": c.Query("error_description")})
+		return
+	}
+	state := c.Query("state")
+	if bound == "" || subtle.ConstantTimeCompare([]byte(bound), []byte(state)) != 1 {
+		h.respondSocialError(c, errInvalidSocialState)
+		return
+	}
+	resp, err := h.svc.SocialLoginCallback(c.Request.Context(), c.Param("provider"), state, c.Query("code"))
+	if err != nil {
+		h.respondSocialError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *HTTPHandler) respondSocialError(c *gin.Context, err error) {
+	h.logger.Error("Social login failed", zap.Error(err))
+	svcErr := &Error{}
+	if errors.As(err, &svcErr) {
+		h.respondOAuthError(c, svcErr)
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "social login failed"})
+}
+
 func (h *HTTPHandler) socialLogin(c *gin.Context) {
 	var req SocialLoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -22,20 +80,9 @@ func (h *HTTPHandler) socialLogin(c *gin.Context) {
 		return
 	}
 
-	// For MVP/Simulated flow, we assume the client might send email/external_id directly
-	// In production, `Code` would be exchanged.
-
 	resp, err := h.svc.SocialLogin(c.Request.Context(), req)
 	if err != nil {
-		h.logger.Error("Social login failed", zap.Error(err))
-		svcErr := &Error{}
-		if errors.As(err, &svcErr) {
-			h.respondOAuthError(c, svcErr)
-		} else {
-			// Handle specific errors like "linking required" if we strictly enforced it
-			// But we implemented auto-provision so generic error is fine.
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		}
+		h.respondSocialError(c, err)
 		return
 	}
 
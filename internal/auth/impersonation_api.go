@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// startImpersonation handles POST /api/v1/impersonation. The administrator
+// authenticates with their session cookie or a bearer session token.
+func (h *HTTPHandler) startImpersonation(c *gin.Context) {
+	tenantID, err := middleware.TenantIDFromGinContext(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing tenant context"})
+		return
+	}
+	var req ImpersonationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.ActorToken = getTokenFromCookieOrHeader(c)
+
+	impersonation, err := h.svc.StartImpersonation(c.Request.Context(), tenantID, req)
+	if err != nil {
+		status, reason := impersonationErrorStatus(err)
+		if impersonation.Actor != "" {
+			h.recordAuthEvent(c, AuthEvent{
+				EventType: AuthEventImpersonationDenied,
+				Subject:   impersonation.Subject,
+				Actor:     impersonation.Actor,
+				Outcome:   AuthOutcomeFailure,
+				Reason:    reason,
+			})
+		}
+		if status == http.StatusInternalServerError {
+			h.logger.Error("Failed to start impersonation", zap.Error(err))
+			c.JSON(status, gin.H{"error": "failed to start impersonation"})
+			return
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.recordAuthEvent(c, AuthEvent{
+		EventType: AuthEventImpersonationStarted,
+		Subject:   impersonation.Subject,
+		Actor:     impersonation.Actor,
+	})
+	c.JSON(http.StatusOK, impersonation)
+}
+
+// stopImpersonation handles POST /api/v1/impersonation/stop.
+func (h *HTTPHandler) stopImpersonation(c *gin.Context) {
+	tenantID, err := middleware.TenantIDFromGinContext(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing tenant context"})
+		return
+	}
+	var req StopImpersonationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	impersonation, err := h.svc.StopImpersonation(c.Request.Context(), tenantID, req.Token)
+	if err != nil {
+		if errors.Is(err, ErrInvalidImpersonationToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("Failed to stop impersonation", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to stop impersonation"})
+		return
+	}
+
+	h.recordAuthEvent(c, AuthEvent{
+		EventType: AuthEventImpersonationStopped,
+		Subject:   impersonation.Subject,
+		Actor:     impersonation.Actor,
+	})
+	c.Status(http.StatusNoContent)
+}
+
+// impersonationErrorStatus maps a StartImpersonation error to an HTTP status
+// and the reason code recorded in the audit trail.
+func impersonationErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, ErrLoginRequired), errors.Is(err, ErrImpersonationSession):
+		return http.StatusUnauthorized, ErrLoginRequired.Code
+	case errors.Is(err, ErrImpersonationForbidden):
+		return http.StatusForbidden, "forbidden"
+	case errors.Is(err, ErrImpersonationDisabled):
+		return http.StatusForbidden, "disabled_by_policy"
+	case errors.Is(err, ErrImpersonationTarget):
+		return http.StatusForbidden, "privileged_target"
+	default:
+		return http.StatusInternalServerError, "server_error"
+	}
+}
+
+// getImpersonationPolicy handles GET /api/v1/impersonation/policy.
+func (h *HTTPHandler) getImpersonationPolicy(c *gin.Context) {
+	tenantID, err := middleware.TenantIDFromGinContext(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tenant ID required"})
+		return
+	}
+	policy, err := h.svc.ImpersonationPolicy(c.Request.Context(), tenantID)
+	if err != nil {
+		h.logger.Error("Failed to fetch impersonation policy", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch impersonation policy"})
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
+
+// updateImpersonationPolicy handles PUT /api/v1/impersonation/policy.
+func (h *HTTPHandler) updateImpersonationPolicy(c *gin.Context) {
+	tenantID, err := middleware.TenantIDFromGinContext(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tenant ID required"})
+		return
+	}
+	var req ImpersonationPolicy
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.svc.UpdateImpersonationPolicy(c.Request.Context(), tenantID, req); err != nil {
+		h.logger.Error("Failed to update impersonation policy", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update impersonation policy"})
+		return
+	}
+	c.JSON(http.StatusOK, req)
+}
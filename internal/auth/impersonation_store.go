@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ImpersonationStore persists each tenant's impersonation policy.
+type ImpersonationStore interface {
+	// Policy returns the tenant's policy, the zero policy when none is set.
+	Policy(ctx context.Context, tenantID string) (ImpersonationPolicy, error)
+	SetPolicy(ctx context.Context, tenantID string, policy ImpersonationPolicy) error
+}
+
+type impersonationRepo struct {
+	db *sqlx.DB
+}
+
+// NewImpersonationStore creates a new SQL-backed impersonation policy store.
+func NewImpersonationStore(db *sqlx.DB) ImpersonationStore {
+	return &impersonationRepo{db: db}
+}
+
+func (r *impersonationRepo) Policy(ctx context.Context, tenantID string) (ImpersonationPolicy, error) {
+	var policy ImpersonationPolicy
+	query := `SELECT disabled, max_minutes FROM impersonation_policies WHERE tenant_id = $1`
+	if err := r.db.GetContext(ctx, &policy, query, tenantID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ImpersonationPolicy{}, nil
+		}
+		return ImpersonationPolicy{}, err
+	}
+	return policy, nil
+}
+
+func (r *impersonationRepo) SetPolicy(ctx context.Context, tenantID string, policy ImpersonationPolicy) error {
+	query := `
+		INSERT INTO impersonation_policies (tenant_id, disabled, max_minutes)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (tenant_id)
+		DO UPDATE SET disabled = EXCLUDED.disabled, max_minutes = EXCLUDED.max_minutes, updated_at = NOW()
+	`
+	_, err := r.db.ExecContext(ctx, query, tenantID, policy.Disabled, policy.MaxMinutes)
+	return err
+}
+
+// impersonationMemoryStore is an in-memory ImpersonationStore used when no database is configured.
+type impersonationMemoryStore struct {
+	mu       sync.Mutex
+	policies map[string]ImpersonationPolicy
+}
+
+func newImpersonationMemoryStore() *impersonationMemoryStore {
+	return &impersonationMemoryStore{policies: make(map[string]ImpersonationPolicy)}
+}
+
+func (s *impersonationMemoryStore) Policy(ctx context.Context, tenantID string) (ImpersonationPolicy, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.policies[tenantID], nil
+}
+
+func (s *impersonationMemoryStore) SetPolicy(ctx context.Context, tenantID string, policy ImpersonationPolicy) error {
+	s.mu.Lock()
+	s.policies[tenantID] = policy
+	s.mu.Unlock()
+	return nil
+}
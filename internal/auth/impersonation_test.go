@@ -0,0 +1,264 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const impersonationTestTenant = "11111111-1111-1111-1111-111111111111"
+
+// impersonators grants users:impersonate to the listed users only.
+type impersonators map[string]bool
+
+func (p impersonators) HasPermission(ctx context.Context, tenantID, userID, resource, action string) (bool, error) {
+	return p[userID] && resource == impersonationResource && action == impersonationAction, nil
+}
+
+func newImpersonationTestService(t *testing.T) *authService {
+	t.Helper()
+	as := newTestService(t)
+	as.permissions = impersonators{"admin-1": true, "admin-2": true}
+	return as
+}
+
+func TestStartImpersonationIssuesActToken(t *testing.T) {
+	as := newImpersonationTestService(t)
+	ctx := contextWithTenant(t, impersonationTestTenant)
+	adminSession, _ := as.generateUserToken(impersonationTestTenant, "admin-1")
+
+	result, err := as.StartImpersonation(ctx, impersonationTestTenant, ImpersonationRequest{UserID: "user-1", ActorToken: adminSession})
+	if err != nil {
+		t.Fatalf("start impersonation error: %v", err)
+	}
+	if result.Subject != "user-1" || result.Actor != "admin-1" || result.RefreshToken != "" {
+		t.Fatalf("unexpected impersonation %+v", result)
+	}
+	if result.ExpiresIn <= 0 || result.ExpiresIn > DefaultImpersonationMinutes*60 {
+		t.Fatalf("expected a token living at most %d minutes, got %ds", DefaultImpersonationMinutes, result.ExpiresIn)
+	}
+
+	introspection, err := as.Introspect(ctx, IntrospectRequest{Token: result.AccessToken})
+	if err != nil || !introspection.Active {
+		t.Fatalf("expected an active token, got %+v (%v)", introspection, err)
+	}
+	if introspection.Sub != "user-1" || introspection.Act["sub"] != "admin-1" {
+		t.Fatalf("expected sub user-1 acted on by admin-1, got sub %q act %v", introspection.Sub, introspection.Act)
+	}
+
+	// The token cannot start a session, so it cannot be turned into refresh tokens.
+	if _, err := as.Authorize(ctx, AuthorizeRequest{
+		ResponseType:  "code",
+		ClientID:      "test-client",
+		RedirectURI:   "https://app.wardseal.com/callback",
+		Scope:         "openid",
+		CodeChallenge: pkceChallenge("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNO1234567890abcd"),
+		SessionToken:  result.AccessToken,
+	}); !errors.Is(err, ErrImpersonationSession) {
+		t.Fatalf("expected the impersonation token to be refused as a session, got %v", err)
+	}
+	if _, err := as.StartImpersonation(ctx, impersonationTestTenant, ImpersonationRequest{UserID: "user-2", ActorToken: result.AccessToken}); !errors.Is(err, ErrImpersonationSession) {
+		t.Fatalf("expected impersonation tokens not to start impersonations, got %v", err)
+	}
+
+	stopped, err := as.StopImpersonation(ctx, impersonationTestTenant, result.AccessToken)
+	if err != nil || stopped.Subject != "user-1" || stopped.Actor != "admin-1" {
+		t.Fatalf("unexpected stop result %+v (%v)", stopped, err)
+	}
+	if introspection, _ := as.Introspect(ctx, IntrospectRequest{Token: result.AccessToken}); introspection.Active {
+		t.Fatalf("expected the stopped token to be inactive")
+	}
+	if _, err := as.StopImpersonation(ctx, impersonationTestTenant, result.AccessToken); !errors.Is(err, ErrInvalidImpersonationToken) {
+		t.Fatalf("expected a stopped token to be rejected, got %v", err)
+	}
+	if _, err := as.StopImpersonation(ctx, impersonationTestTenant, adminSession); !errors.Is(err, ErrInvalidImpersonationToken) {
+		t.Fatalf("expected a plain session token to be rejected, got %v", err)
+	}
+}
+
+func TestStartImpersonationRefusals(t *testing.T) {
+	as := newImpersonationTestService(t)
+	ctx := contextWithTenant(t, impersonationTestTenant)
+	adminSession, _ := as.generateUserToken(impersonationTestTenant, "admin-1")
+	memberSession, _ := as.generateUserToken(impersonationTestTenant, "user-1")
+
+	tests := []struct {
+		name string
+		req  ImpersonationRequest
+		want error
+	}{
+		{"unauthenticated", ImpersonationRequest{UserID: "user-2"}, ErrLoginRequired},
+		{"non-admin", ImpersonationRequest{UserID: "user-2", ActorToken: memberSession}, ErrImpersonationForbidden},
+		{"self", ImpersonationRequest{UserID: "admin-1", ActorToken: adminSession}, ErrImpersonationTarget},
+		{"another admin", ImpersonationRequest{UserID: "admin-2", ActorToken: adminSession}, ErrImpersonationTarget},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := as.StartImpersonation(ctx, impersonationTestTenant, tt.req)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+			if result.AccessToken != "" {
+				t.Fatalf("expected no token, got %+v", result)
+			}
+		})
+	}
+
+	// Without a permission checker nobody may impersonate.
+	as.permissions = nil
+	if _, err := as.StartImpersonation(ctx, impersonationTestTenant, ImpersonationRequest{UserID: "user-1", ActorToken: adminSession}); !errors.Is(err, ErrImpersonationForbidden) {
+		t.Fatalf("expected ErrImpersonationForbidden without a permission checker, got %v", err)
+	}
+}
+
+func TestImpersonationPolicy(t *testing.T) {
+	as := newImpersonationTestService(t)
+	ctx := contextWithTenant(t, impersonationTestTenant)
+	adminSession, _ := as.generateUserToken(impersonationTestTenant, "admin-1")
+	req := ImpersonationRequest{UserID: "user-1", ActorToken: adminSession}
+
+	if err := as.UpdateImpersonationPolicy(ctx, impersonationTestTenant, ImpersonationPolicy{MaxMinutes: 5}); err != nil {
+		t.Fatalf("update policy error: %v", err)
+	}
+	result, err := as.StartImpersonation(ctx, impersonationTestTenant, ImpersonationRequest{UserID: "user-1", Minutes: 30, ActorToken: adminSession})
+	if err != nil || result.ExpiresIn > 5*60 {
+		t.Fatalf("expected the policy to cap the token at 5 minutes, got %ds (%v)", result.ExpiresIn, err)
+	}
+
+	if err := as.UpdateImpersonationPolicy(ctx, impersonationTestTenant, ImpersonationPolicy{Disabled: true}); err != nil {
+		t.Fatalf("update policy error: %v", err)
+	}
+	if _, err := as.StartImpersonation(ctx, impersonationTestTenant, req); !errors.Is(err, ErrImpersonationDisabled) {
+		t.Fatalf("expected ErrImpersonationDisabled, got %v", err)
+	}
+	if _, err := as.StartImpersonation(ctx, "22222222-2222-2222-2222-222222222222", req); !errors.Is(err, ErrLoginRequired) {
+		t.Fatalf("expected the session to be bound to its tenant, got %v", err)
+	}
+}
+
+func TestImpersonationEndpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newImpersonationTestService(t)
+	adminSession, _ := as.generateUserToken(impersonationTestTenant, "admin-1")
+	memberSession, _ := as.generateUserToken(impersonationTestTenant, "user-1")
+
+	router := gin.New()
+	NewHTTPHandler(as, zap.NewNop(), nil).RegisterRoutes(router)
+	serve := func(path, bearer string, body interface{}) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(payload))
+		req.Header.Set(middleware.DefaultTenantHeader, impersonationTestTenant)
+		req.Header.Set("Content-Type", "application/json")
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	if resp := serve("/api/v1/impersonation", memberSession, gin.H{"user_id": "user-2"}); resp.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a non-admin, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if resp := serve("/api/v1/impersonation", "", gin.H{"user_id": "user-2"}); resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a session, got %d", resp.Code)
+	}
+
+	resp := serve("/api/v1/impersonation", adminSession, gin.H{"user_id": "user-2"})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var started Impersonation
+	if err := json.Unmarshal(resp.Body.Bytes(), &started); err != nil || started.AccessToken == "" || started.Actor != "admin-1" {
+		t.Fatalf("unexpected response %s (%v)", resp.Body.String(), err)
+	}
+	claims, err := as.parseSignedToken(started.AccessToken)
+	if err != nil {
+		t.Fatalf("parse token error: %v", err)
+	}
+	act, _ := claims["act"].(map[string]interface{})
+	if claims["sub"] != "user-2" || act["sub"] != "admin-1" {
+		t.Fatalf("expected sub user-2 and act.sub admin-1, got %v", claims)
+	}
+
+	if resp := serve("/api/v1/impersonation/stop", "", gin.H{"token": started.AccessToken}); resp.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if resp := serve("/api/v1/impersonation/stop", "", gin.H{"token": started.AccessToken}); resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a stopped token, got %d", resp.Code)
+	}
+
+	events, err := as.AuthAudit().ListAuthEvents(context.Background(), AuthEventQuery{TenantID: impersonationTestTenant})
+	if err != nil {
+		t.Fatalf("list events error: %v", err)
+	}
+	var types []string
+	for _, event := range events {
+		types = append(types, event.EventType)
+		if event.Actor == "" {
+			t.Fatalf("expected every impersonation event to name its actor, got %+v", event)
+		}
+	}
+	want := []string{AuthEventImpersonationStopped, AuthEventImpersonationStarted, AuthEventImpersonationDenied}
+	if len(types) != len(want) {
+		t.Fatalf("expected events %v, got %v", want, types)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("expected events %v, got %v", want, types)
+		}
+	}
+	if events[2].Actor != "user-1" || events[2].Outcome != AuthOutcomeFailure || events[2].Reason != "forbidden" {
+		t.Fatalf("unexpected denial event %+v", events[2])
+	}
+}
+
+func TestImpersonationPolicyEndpointsRequirePermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newImpersonationTestService(t)
+	adminSession, _ := as.generateUserToken(impersonationTestTenant, "admin-1")
+	memberSession, _ := as.generateUserToken(impersonationTestTenant, "user-1")
+
+	router := gin.New()
+	handler := NewHTTPHandler(as, zap.NewNop(), nil)
+	handler.UsePermissions(permissionGrants{
+		"admin-1": {"impersonation_policy:read", "impersonation_policy:update"},
+		"user-1":  {"impersonation_policy:read"},
+	})
+	handler.RegisterRoutes(router)
+	put := func(bearer string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/impersonation/policy", bytes.NewReader([]byte(`{"disabled":true}`)))
+		req.Header.Set(middleware.DefaultTenantHeader, impersonationTestTenant)
+		req.Header.Set("Content-Type", "application/json")
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	if resp := put(""); resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a session, got %d", resp.Code)
+	}
+	if resp := put(memberSession); resp.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 without impersonation_policy:update, got %d", resp.Code)
+	}
+	if policy, _ := as.ImpersonationPolicy(context.Background(), impersonationTestTenant); policy.Disabled {
+		t.Fatal("expected a refused update to leave the policy unchanged")
+	}
+	if resp := put(adminSession); resp.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if policy, _ := as.ImpersonationPolicy(context.Background(), impersonationTestTenant); !policy.Disabled {
+		t.Fatal("expected the policy to be updated")
+	}
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/url"
+
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// ErrIntrospectionUnauthorized is returned when the introspection caller is
+// neither an authenticated confidential client nor a trusted internal service.
+var ErrIntrospectionUnauthorized = &Error{"invalid_client", "introspection requires client authentication"}
+
+// IntrospectionCaller carries the credentials presented to the introspection endpoint.
+type IntrospectionCaller struct {
+	ClientID     string
+	ClientSecret string
+	// ServiceToken is the internal service-to-service token, if presented.
+	ServiceToken string
+}
+
+// AuthenticateIntrospectionCaller implements the RFC 7662 requirement that
+// introspection callers authenticate, so arbitrary parties cannot probe
+// whether a token is valid.
+func (s *authService) AuthenticateIntrospectionCaller(ctx context.Context, caller IntrospectionCaller) error {
+	if caller.ServiceToken != "" && s.serviceAuthToken != "" &&
+		subtle.ConstantTimeCompare([]byte(caller.ServiceToken), []byte(s.serviceAuthToken)) == 1 {
+		return nil
+	}
+	if caller.ClientID == "" || caller.ClientSecret == "" {
+		return ErrIntrospectionUnauthorized
+	}
+	tenantID, err := middleware.TenantIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	client, err := s.resolveClient(ctx, tenantID, caller.ClientID)
+	if err != nil {
+		return ErrIntrospectionUnauthorized
+	}
+	if err := s.authenticateConfidentialClient(ctx, tenantID, client, caller.ClientSecret, "introspection"); err != nil {
+		return ErrIntrospectionUnauthorized
+	}
+	return nil
+}
+
+// introspectionCaller reads client credentials from HTTP Basic auth (RFC 6749
+// section 2.3.1) or the form body, and the internal service token header.
+func (h *HTTPHandler) introspectionCaller(c *gin.Context) IntrospectionCaller {
+	caller := IntrospectionCaller{ServiceToken: c.GetHeader(h.svc.ServiceAuthHeader())}
+	if id, secret, ok := c.Request.BasicAuth(); ok {
+		caller.ClientID, caller.ClientSecret = id, secret
+		if unescaped, err := url.QueryUnescape(id); err == nil {
+			caller.ClientID = unescaped
+		}
+		if unescaped, err := url.QueryUnescape(secret); err == nil {
+			caller.ClientSecret = unescaped
+		}
+		return caller
+	}
+	caller.ClientID = c.PostForm("client_id")
+	caller.ClientSecret = c.PostForm("client_secret")
+	return caller
+}
@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+func newIntrospectionRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	as := newTokenExchangeService(t)
+	as.serviceAuthToken = "internal-token"
+	router := gin.New()
+	NewHTTPHandler(as, zap.NewNop(), nil).RegisterRoutes(router)
+	return router
+}
+
+func postIntrospect(router *gin.Engine, form url.Values, setup func(*http.Request)) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/oauth2/introspect", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set(middleware.DefaultTenantHeader, "11111111-1111-1111-1111-111111111111")
+	if setup != nil {
+		setup(req)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIntrospectRejectsUnauthenticatedCaller(t *testing.T) {
+	router := newIntrospectionRouter(t)
+
+	w := postIntrospect(router, url.Values{"token": {"invalid-token"}}, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without credentials, got %d", w.Code)
+	}
+	if w.Header().Get("WWW-Authenticate") == "" {
+		t.Fatalf("expected a WWW-Authenticate challenge")
+	}
+
+	w = postIntrospect(router, url.Values{"token": {"invalid-token"}}, func(r *http.Request) {
+		r.SetBasicAuth("orders-service", "wrong-secret")
+	})
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 with a wrong secret, got %d", w.Code)
+	}
+}
+
+func TestIntrospectAuthenticatedCallerSeesInactiveToken(t *testing.T) {
+	router := newIntrospectionRouter(t)
+
+	callers := map[string]func(*http.Request){
+		"basic": func(r *http.Request) { r.SetBasicAuth("orders-service", "orders-secret") },
+		"service token": func(r *http.Request) {
+			r.Header.Set(middleware.DefaultServiceAuthHeader, "internal-token")
+		},
+	}
+	for name, setup := range callers {
+		w := postIntrospect(router, url.Values{"token": {"invalid-token"}}, setup)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", name, w.Code, w.Body.String())
+		}
+		var resp IntrospectResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: failed to decode response: %v", name, err)
+		}
+		if resp.Active {
+			t.Fatalf("%s: expected active=false for an invalid token", name)
+		}
+	}
+
+	form := url.Values{"token": {"invalid-token"}, "client_id": {"orders-service"}, "client_secret": {"orders-secret"}}
+	if w := postIntrospect(router, form, nil); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 with form credentials, got %d", w.Code)
+	}
+}
+
+func TestIntrospectHidesOtherTenantsTokens(t *testing.T) {
+	as := newTestService(t)
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+	other := contextWithTenant(t, "22222222-2222-2222-2222-222222222222")
+
+	tokenResp, err := authorizeForResource(t, as, ctx, "", "")
+	if err != nil {
+		t.Fatalf("token error: %v", err)
+	}
+	for name, token := range map[string]string{"access": tokenResp.AccessToken, "refresh": tokenResp.RefreshToken} {
+		if resp, err := as.Introspect(ctx, IntrospectRequest{Token: token}); err != nil || !resp.Active {
+			t.Fatalf("expected the %s token to be active in its tenant, got %+v (%v)", name, resp, err)
+		}
+		resp, err := as.Introspect(other, IntrospectRequest{Token: token})
+		if err != nil || resp.Active || resp.Sub != "" || resp.ClientID != "" {
+			t.Fatalf("expected the %s token to be inactive to another tenant, got %+v (%v)", name, resp, err)
+		}
+	}
+}
+
+func TestIntrospectMatchesResourceInAudienceArray(t *testing.T) {
+	as := newTestService(t)
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+
+	token, err := as.signingKeys.sign(jwt.MapClaims{
+		"sub":    "user-1",
+		"iss":    "identity-platform",
+		"aud":    []string{ordersResource, "https://billing.wardseal.com"},
+		"exp":    time.Now().Add(time.Hour).Unix(),
+		"iat":    time.Now().Unix(),
+		"tenant": "11111111-1111-1111-1111-111111111111",
+	})
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	if resp, err := as.Introspect(ctx, IntrospectRequest{Token: token, Resource: "https://billing.wardseal.com"}); err != nil || !resp.Active {
+		t.Fatalf("expected the token to be active at a listed audience, got %+v (%v)", resp, err)
+	}
+	if resp, _ := as.Introspect(ctx, IntrospectRequest{Token: token, Resource: "https://hr.wardseal.com"}); resp.Active {
+		t.Fatalf("expected the token to be inactive at an unlisted resource")
+	}
+}
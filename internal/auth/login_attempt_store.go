@@ -4,23 +4,30 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"sync"
 	"time"
 
 	"github.com/jmoiron/sqlx"
 )
 
+// Default brute-force protection settings; see LockoutConfig.
 const (
-	MaxFailedAttempts = 5
-	LockoutDuration   = 15 * time.Minute
-	AttemptWindow     = 15 * time.Minute
+	MaxFailedAttempts      = 5
+	MaxFailedAttemptsPerIP = 20
+	LockoutDuration        = 15 * time.Minute
+	AttemptWindow          = 15 * time.Minute
 )
 
 // LoginAttemptStore handles tracking login attempts and lockouts.
 type LoginAttemptStore interface {
 	RecordAttempt(ctx context.Context, tenantID, username, ip string, success bool) error
-	GetRecentFailures(ctx context.Context, tenantID, username string) (int, error)
+	// GetRecentFailures counts failures for the account since the given time,
+	// ignoring any that happened before the account's last successful login.
+	GetRecentFailures(ctx context.Context, tenantID, username string, since time.Time) (int, error)
+	// GetRecentIPFailures counts failures from the source IP since the given time, across all accounts.
+	GetRecentIPFailures(ctx context.Context, tenantID, ip string, since time.Time) (int, error)
 	IsLocked(ctx context.Context, tenantID, username string) (bool, time.Time, error)
-	LockAccount(ctx context.Context, tenantID, username string) error
+	LockAccount(ctx context.Context, tenantID, username string, until time.Time) error
 	UnlockAccount(ctx context.Context, tenantID, username string) error
 }
 
@@ -39,14 +46,27 @@ func (r *loginAttemptRepo) RecordAttempt(ctx context.Context, tenantID, username
 	return err
 }
 
-func (r *loginAttemptRepo) GetRecentFailures(ctx context.Context, tenantID, username string) (int, error) {
+func (r *loginAttemptRepo) GetRecentFailures(ctx context.Context, tenantID, username string, since time.Time) (int, error) {
 	var count int
 	query := `
-		SELECT COUNT(*) FROM login_attempts 
-		WHERE tenant_id = $1 AND username = $2 AND success = FALSE 
-		AND attempted_at > NOW() - INTERVAL '15 minutes'
+		SELECT COUNT(*) FROM login_attempts
+		WHERE tenant_id = $1 AND username = $2 AND success = FALSE
+		AND attempted_at > GREATEST($3, COALESCE((
+			SELECT MAX(attempted_at) FROM login_attempts
+			WHERE tenant_id = $1 AND username = $2 AND success = TRUE
+		), $3))
 	`
-	err := r.db.GetContext(ctx, &count, query, tenantID, username)
+	err := r.db.GetContext(ctx, &count, query, tenantID, username, since)
+	return count, err
+}
+
+func (r *loginAttemptRepo) GetRecentIPFailures(ctx context.Context, tenantID, ip string, since time.Time) (int, error) {
+	var count int
+	query := `
+		SELECT COUNT(*) FROM login_attempts
+		WHERE tenant_id = $1 AND ip_address = $2 AND success = FALSE AND attempted_at > $3
+	`
+	err := r.db.GetContext(ctx, &count, query, tenantID, ip, since)
 	return count, err
 }
 
@@ -70,15 +90,14 @@ func (r *loginAttemptRepo) IsLocked(ctx context.Context, tenantID, username stri
 	return true, lockedUntil, nil
 }
 
-func (r *loginAttemptRepo) LockAccount(ctx context.Context, tenantID, username string) error {
-	lockedUntil := time.Now().Add(LockoutDuration)
+func (r *loginAttemptRepo) LockAccount(ctx context.Context, tenantID, username string, until time.Time) error {
 	query := `
 		INSERT INTO account_lockouts (tenant_id, username, locked_until)
 		VALUES ($1, $2, $3)
 		ON CONFLICT (tenant_id, username) 
 		DO UPDATE SET locked_at = NOW(), locked_until = EXCLUDED.locked_until
 	`
-	_, err := r.db.ExecContext(ctx, query, tenantID, username, lockedUntil)
+	_, err := r.db.ExecContext(ctx, query, tenantID, username, until)
 	return err
 }
 
@@ -87,3 +106,82 @@ func (r *loginAttemptRepo) UnlockAccount(ctx context.Context, tenantID, username
 	_, err := r.db.ExecContext(ctx, query, tenantID, username)
 	return err
 }
+
+type loginAttempt struct {
+	tenantID    string
+	username    string
+	ip          string
+	success     bool
+	attemptedAt time.Time
+}
+
+// loginAttemptMemoryStore is an in-memory LoginAttemptStore for tests and single-node development.
+type loginAttemptMemoryStore struct {
+	mu       sync.Mutex
+	attempts []loginAttempt
+	lockouts map[string]time.Time
+}
+
+func newLoginAttemptMemoryStore() *loginAttemptMemoryStore {
+	return &loginAttemptMemoryStore{lockouts: make(map[string]time.Time)}
+}
+
+func (s *loginAttemptMemoryStore) RecordAttempt(ctx context.Context, tenantID, username, ip string, success bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts = append(s.attempts, loginAttempt{tenantID, username, ip, success, time.Now()})
+	return nil
+}
+
+func (s *loginAttemptMemoryStore) GetRecentFailures(ctx context.Context, tenantID, username string, since time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for _, a := range s.attempts {
+		if a.tenantID != tenantID || a.username != username || !a.attemptedAt.After(since) {
+			continue
+		}
+		if a.success {
+			count = 0
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (s *loginAttemptMemoryStore) GetRecentIPFailures(ctx context.Context, tenantID, ip string, since time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for _, a := range s.attempts {
+		if a.tenantID == tenantID && a.ip == ip && !a.success && a.attemptedAt.After(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *loginAttemptMemoryStore) IsLocked(ctx context.Context, tenantID, username string) (bool, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	until, ok := s.lockouts[tenantID+"::"+username]
+	if !ok || time.Now().After(until) {
+		return false, time.Time{}, nil
+	}
+	return true, until, nil
+}
+
+func (s *loginAttemptMemoryStore) LockAccount(ctx context.Context, tenantID, username string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lockouts[tenantID+"::"+username] = until
+	return nil
+}
+
+func (s *loginAttemptMemoryStore) UnlockAccount(ctx context.Context, tenantID, username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.lockouts, tenantID+"::"+username)
+	return nil
+}
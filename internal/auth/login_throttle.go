@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"context"
+	"time"
+)
+
+// LockoutConfig tunes brute-force protection on the login endpoint.
+// Zero values fall back to the package defaults.
+type LockoutConfig struct {
+	// MaxFailedAttempts locks an account after this many consecutive failures.
+	MaxFailedAttempts int
+	// MaxFailedAttemptsPerIP blocks a source IP after this many failures across all accounts.
+	MaxFailedAttemptsPerIP int
+	// AttemptWindow is how far back failures are counted.
+	AttemptWindow time.Duration
+	// LockoutDuration is how long an account stays locked.
+	LockoutDuration time.Duration
+	// BaseDelay is applied after the first failure and doubles with each further one, up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// MinFailureDuration pads every failed login so that unknown accounts and
+	// wrong passwords take the same time to answer.
+	MinFailureDuration time.Duration
+}
+
+func (c LockoutConfig) withDefaults() LockoutConfig {
+	if c.MaxFailedAttempts <= 0 {
+		c.MaxFailedAttempts = MaxFailedAttempts
+	}
+	if c.MaxFailedAttemptsPerIP <= 0 {
+		c.MaxFailedAttemptsPerIP = MaxFailedAttemptsPerIP
+	}
+	if c.AttemptWindow <= 0 {
+		c.AttemptWindow = AttemptWindow
+	}
+	if c.LockoutDuration <= 0 {
+		c.LockoutDuration = LockoutDuration
+	}
+	if c.BaseDelay <= 0 {
+		c.BaseDelay = 250 * time.Millisecond
+	}
+	if c.MaxDelay <= 0 {
+		c.MaxDelay = 5 * time.Second
+	}
+	if c.MinFailureDuration <= 0 {
+		c.MinFailureDuration = 500 * time.Millisecond
+	}
+	return c
+}
+
+// LoginThrottle applies per-account and per-IP brute-force protection.
+type LoginThrottle struct {
+	store LoginAttemptStore
+	cfg   LockoutConfig
+	sleep func(ctx context.Context, d time.Duration)
+}
+
+// NewLoginThrottle creates a LoginThrottle backed by store.
+func NewLoginThrottle(store LoginAttemptStore, cfg LockoutConfig) *LoginThrottle {
+	return &LoginThrottle{store: store, cfg: cfg.withDefaults(), sleep: sleepContext}
+}
+
+// Check reports whether the account or source IP is currently blocked and until when.
+func (t *LoginThrottle) Check(ctx context.Context, tenantID, username, ip string) (bool, time.Time, error) {
+	locked, until, err := t.store.IsLocked(ctx, tenantID, username)
+	if err != nil || locked {
+		return locked, until, err
+	}
+	if ip == "" {
+		return false, time.Time{}, nil
+	}
+	failures, err := t.store.GetRecentIPFailures(ctx, tenantID, ip, time.Now().Add(-t.cfg.AttemptWindow))
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	if failures >= t.cfg.MaxFailedAttemptsPerIP {
+		return true, time.Now().Add(t.cfg.AttemptWindow), nil
+	}
+	return false, time.Time{}, nil
+}
+
+// RecordFailure records a failed attempt, locks the account once the threshold
+// is reached and returns the backoff delay to apply before responding.
+func (t *LoginThrottle) RecordFailure(ctx context.Context, tenantID, username, ip string) (time.Duration, error) {
+	delay, _, err := t.recordFailure(ctx, tenantID, username, ip)
+	return delay, err
+}
+
+// RecordMFAFailure records a wrong second factor like a wrong password and
+// also returns the account's recent failures, which include the wrong
+// passwords entered before the login waiting on it.
+func (t *LoginThrottle) RecordMFAFailure(ctx context.Context, tenantID, username, ip string) (time.Duration, int, error) {
+	return t.recordFailure(ctx, tenantID, username, ip)
+}
+
+func (t *LoginThrottle) recordFailure(ctx context.Context, tenantID, username, ip string) (time.Duration, int, error) {
+	if err := t.store.RecordAttempt(ctx, tenantID, username, ip, false); err != nil {
+		return t.cfg.BaseDelay, 0, err
+	}
+	failures, err := t.store.GetRecentFailures(ctx, tenantID, username, time.Now().Add(-t.cfg.AttemptWindow))
+	if err != nil {
+		return t.cfg.BaseDelay, 0, err
+	}
+	if failures >= t.cfg.MaxFailedAttempts {
+		if err := t.store.LockAccount(ctx, tenantID, username, time.Now().Add(t.cfg.LockoutDuration)); err != nil {
+			return t.cfg.MaxDelay, failures, err
+		}
+	}
+	return t.backoff(failures), failures, nil
+}
+
+// RecordSuccess records a successful attempt, which resets the account's failure count.
+func (t *LoginThrottle) RecordSuccess(ctx context.Context, tenantID, username, ip string) error {
+	if err := t.store.RecordAttempt(ctx, tenantID, username, ip, true); err != nil {
+		return err
+	}
+	return t.store.UnlockAccount(ctx, tenantID, username)
+}
+
+// Delay blocks until at least delay (and MinFailureDuration) has passed since start.
+func (t *LoginThrottle) Delay(ctx context.Context, start time.Time, delay time.Duration) {
+	if delay < t.cfg.MinFailureDuration {
+		delay = t.cfg.MinFailureDuration
+	}
+	if remaining := delay - time.Since(start); remaining > 0 {
+		t.sleep(ctx, remaining)
+	}
+}
+
+func (t *LoginThrottle) backoff(failures int) time.Duration {
+	delay := t.cfg.BaseDelay
+	for i := 1; i < failures && delay < t.cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > t.cfg.MaxDelay {
+		delay = t.cfg.MaxDelay
+	}
+	return delay
+}
+
+func sleepContext(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
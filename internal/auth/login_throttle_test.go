@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dhawalhost/wardseal/internal/saml"
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/pquerna/otp/totp"
+	"go.uber.org/zap"
+)
+
+func TestLoginLocksAccountAfterRepeatedFailures(t *testing.T) {
+	router, _ := newThrottledLoginRouter(t, LockoutConfig{MaxFailedAttempts: 3})
+
+	for i := 0; i < 3; i++ {
+		if w := postLogin(router, "alice@example.com", "wrong-password", "10.0.0.1"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: expected 401, got %d", i+1, w.Code)
+		}
+	}
+
+	w := postLogin(router, "alice@example.com", "correct-password", "10.0.0.1")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected correct password to be blocked during lockout, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected Retry-After header on lockout")
+	}
+}
+
+func TestLoginLockoutResponseDoesNotRevealAccountExistence(t *testing.T) {
+	router, _ := newThrottledLoginRouter(t, LockoutConfig{MaxFailedAttempts: 2})
+
+	var bodies []map[string]interface{}
+	for _, username := range []string{"alice@example.com", "nobody@example.com"} {
+		for i := 0; i < 2; i++ {
+			postLogin(router, username, "wrong-password", "10.0.0.1")
+		}
+		w := postLogin(router, username, "wrong-password", "10.0.0.1")
+		if w.Code != http.StatusTooManyRequests {
+			t.Fatalf("%s: expected 429, got %d", username, w.Code)
+		}
+		var body map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &body)
+		bodies = append(bodies, body)
+	}
+	if bodies[0]["error"] != bodies[1]["error"] || bodies[0]["error_description"] != bodies[1]["error_description"] {
+		t.Fatalf("expected identical lockout responses, got %v and %v", bodies[0], bodies[1])
+	}
+}
+
+func TestLoginBlocksSourceIPAcrossAccounts(t *testing.T) {
+	router, _ := newThrottledLoginRouter(t, LockoutConfig{MaxFailedAttempts: 10, MaxFailedAttemptsPerIP: 3})
+
+	for _, username := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		postLogin(router, username, "wrong-password", "10.0.0.9")
+	}
+	if w := postLogin(router, "alice@example.com", "correct-password", "10.0.0.9"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected source IP to be blocked, got %d", w.Code)
+	}
+	if w := postLogin(router, "alice@example.com", "correct-password", "10.0.0.10"); w.Code != http.StatusOK {
+		t.Fatalf("expected other IPs to be unaffected, got %d", w.Code)
+	}
+}
+
+func TestLoginSuccessClearsFailureCount(t *testing.T) {
+	router, _ := newThrottledLoginRouter(t, LockoutConfig{MaxFailedAttempts: 3})
+
+	postLogin(router, "alice@example.com", "wrong-password", "10.0.0.1")
+	postLogin(router, "alice@example.com", "wrong-password", "10.0.0.1")
+	if w := postLogin(router, "alice@example.com", "correct-password", "10.0.0.1"); w.Code != http.StatusOK {
+		t.Fatalf("expected login to succeed, got %d", w.Code)
+	}
+	postLogin(router, "alice@example.com", "wrong-password", "10.0.0.1")
+	if w := postLogin(router, "alice@example.com", "correct-password", "10.0.0.1"); w.Code != http.StatusOK {
+		t.Fatalf("expected counter to reset after success, got %d", w.Code)
+	}
+}
+
+func TestMFAChallengeEndsAfterRepeatedBadCodes(t *testing.T) {
+	router, _, as := newLoginTestRouter(t, LockoutConfig{MaxFailedAttempts: 10})
+	enrollment := enrollVerifiedTOTP(t, as, "user-1")
+	challenge := loginPendingMFA(t, router)
+
+	for i := 0; i < MaxMFAChallengeFailures; i++ {
+		if w := postMFALogin(router, challenge, "000000"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: expected 401, got %d", i+1, w.Code)
+		}
+	}
+	// The previous period's code is still within the skew and unused.
+	code, _ := totp.GenerateCode(enrollment.Key.Secret(), time.Now().Add(-totpPeriod*time.Second))
+	w := postMFALogin(router, challenge, code)
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), ErrInvalidMFAChallenge.Message) {
+		t.Fatalf("expected the challenge to be revoked, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestMFAFailuresLockAccountAcrossChallenges(t *testing.T) {
+	router, _, as := newLoginTestRouter(t, LockoutConfig{MaxFailedAttempts: 3})
+	enrollVerifiedTOTP(t, as, "user-1")
+
+	// A fresh challenge does not reset the failures of the previous one.
+	for _, bad := range []int{2, 1} {
+		challenge := loginPendingMFA(t, router)
+		for i := 0; i < bad; i++ {
+			postMFALogin(router, challenge, "000000")
+		}
+	}
+	if w := postLogin(router, "alice@example.com", "correct-password", "10.0.0.1"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the account to be locked, got %d", w.Code)
+	}
+}
+
+func TestLoginThrottleBackoffIsExponentialAndCapped(t *testing.T) {
+	throttle := NewLoginThrottle(newLoginAttemptMemoryStore(), LockoutConfig{BaseDelay: time.Second, MaxDelay: 5 * time.Second})
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, expected := range want {
+		if got := throttle.backoff(i + 1); got != expected {
+			t.Fatalf("failure %d: expected %v, got %v", i+1, expected, got)
+		}
+	}
+}
+
+// newThrottledLoginRouter wires the login handler to a stub directory that
+// accepts only the password "correct-password" for alice@example.com.
+func newThrottledLoginRouter(t *testing.T, cfg LockoutConfig) (*gin.Engine, *LoginThrottle) {
+	t.Helper()
+	router, throttle, _ := newLoginTestRouter(t, cfg)
+	return router, throttle
+}
+
+// newLoginTestRouter is newThrottledLoginRouter also returning its service,
+// where auditor-1 may read the auth events.
+func newLoginTestRouter(t *testing.T, cfg LockoutConfig) (*gin.Engine, *LoginThrottle, *authService) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	directory := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Email    string `json:"email"`
+			Password string `json:"password"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.Email != "alice@example.com" || req.Password != "correct-password" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"user": map[string]string{"id": "user-1", "email": req.Email},
+		})
+	}))
+	t.Cleanup(directory.Close)
+
+	samlCert, samlKey := testSAMLKeyPair()
+	svc, err := NewService(Config{
+		BaseURL:             "http://wardseal.com",
+		DirectoryServiceURL: directory.URL,
+		SAMLStore:           saml.NewStore(nil),
+		SAMLCertificate:     samlCert,
+		SAMLPrivateKey:      samlKey,
+		Clients: []ClientConfig{{
+			ID:            "test-client",
+			TenantID:      "11111111-1111-1111-1111-111111111111",
+			Name:          "Test Client",
+			RedirectURIs:  []string{"https://app.wardseal.com/callback"},
+			AllowedScopes: []string{"openid"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+
+	throttle := NewLoginThrottle(newLoginAttemptMemoryStore(), cfg)
+	throttle.sleep = func(context.Context, time.Duration) {}
+
+	router := gin.New()
+	handler := NewHTTPHandler(svc, zap.NewNop(), throttle)
+	handler.UsePermissions(permissionGrants{"auditor-1": {"auth_events:read"}})
+	handler.RegisterRoutes(router)
+	return router, throttle, svc.(*authService)
+}
+
+// loginPendingMFA logs alice in and returns her MFA challenge.
+func loginPendingMFA(t *testing.T, router *gin.Engine) string {
+	t.Helper()
+	w := postLogin(router, "alice@example.com", "correct-password", "10.0.0.1")
+	var body struct {
+		PendingToken string `json:"pending_token"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.PendingToken == "" {
+		t.Fatalf("expected an MFA challenge, got %d: %s", w.Code, w.Body.String())
+	}
+	return body.PendingToken
+}
+
+func postMFALogin(router *gin.Engine, challenge, code string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(map[string]string{"pending_token": challenge, "totp_code": code})
+	req := httptest.NewRequest(http.MethodPost, "/login/mfa", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(middleware.DefaultTenantHeader, "11111111-1111-1111-1111-111111111111")
+	req.RemoteAddr = "10.0.0.1:12345"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func postLogin(router *gin.Engine, username, password, ip string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(map[string]string{"username": username, "password": password})
+	req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(middleware.DefaultTenantHeader, "11111111-1111-1111-1111-111111111111")
+	req.RemoteAddr = ip + ":12345"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
@@ -3,6 +3,7 @@ package auth
 import (
 	"net/http"
 
+	"github.com/dhawalhost/wardseal/internal/featureflag"
 	"github.com/gin-gonic/gin"
 	"github.com/go-webauthn/webauthn/webauthn"
 	"go.uber.org/zap"
@@ -12,8 +13,8 @@ import (
 var webAuthnSessions = make(map[string]webauthn.SessionData)
 
 func (h *HTTPHandler) registerWebAuthnRoutes(rg *gin.RouterGroup) {
-	rg.POST("/mfa/webauthn/register/begin", h.beginWebAuthnRegistration)
-	rg.POST("/mfa/webauthn/register/finish", h.finishWebAuthnRegistration)
+	rg.POST("/mfa/webauthn/register/begin", h.requireFeature(featureflag.FeatureMFA), h.beginWebAuthnRegistration)
+	rg.POST("/mfa/webauthn/register/finish", h.requireFeature(featureflag.FeatureMFA), h.finishWebAuthnRegistration)
 	rg.POST("/mfa/webauthn/login/begin", h.beginWebAuthnLogin)
 	rg.POST("/mfa/webauthn/login/finish", h.finishWebAuthnLogin)
 }
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"go.uber.org/zap"
+)
+
+var passwordResetPageTemplate = template.Must(template.New("password-reset").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Reset your password</title></head>
+<body>
+{{if .Done}}
+<h1>{{.Done}}</h1>
+<p>You can now sign in with your new password.</p>
+{{else}}
+<h1>Reset your password</h1>
+{{if .Error}}<p>{{.Error}}</p>{{end}}
+<form method="POST" action="/auth/password/reset">
+<input type="hidden" name="token" value="{{.Token}}">
+<label>New password <input type="password" name="password" minlength="8" required autofocus></label>
+<button type="submit">Reset password</button>
+</form>
+{{end}}
+</body>
+</html>
+`))
+
+// forgotPassword handles POST /auth/password/forgot. The response is the same
+// whether or not the email is registered.
+func (h *HTTPHandler) forgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.svc.ForgotPassword(c.Request.Context(), req.Email); err != nil {
+		h.logger.Error("Forgot password failed", zap.Error(err))
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the account exists, a password reset link has been sent."})
+}
+
+// resetPasswordPage handles GET /auth/password/reset, the page the emailed
+// link opens. It posts the token and the new password back as a form.
+func (h *HTTPHandler) resetPasswordPage(c *gin.Context) {
+	h.renderPasswordResetPage(c, http.StatusOK, gin.H{"Token": c.Query("token")})
+}
+
+// resetPassword handles POST /auth/password/reset. The token carries its own
+// tenant, so the route does not require the tenant header. Form posts from
+// the reset page get the page back; JSON callers get JSON.
+func (h *HTTPHandler) resetPassword(c *gin.Context) {
+	fromPage := c.ContentType() == binding.MIMEPOSTForm
+	var req ResetPasswordRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		if fromPage {
+			h.renderPasswordResetPage(c, http.StatusBadRequest, gin.H{"Token": req.Token, "Error": "Choose a password of at least 8 characters."})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.svc.ResetPassword(c.Request.Context(), req.Token, req.Password); err != nil {
+		svcErr := &Error{}
+		if errors.As(err, &svcErr) {
+			if fromPage {
+				h.renderPasswordResetPage(c, http.StatusBadRequest, gin.H{"Token": req.Token, "Error": svcErr.Message})
+				return
+			}
+			h.respondOAuthError(c, svcErr)
+			return
+		}
+		h.logger.Error("Password reset failed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset password"})
+		return
+	}
+
+	// Any session cookie on this browser predates the reset.
+	clearAuthCookies(c)
+	if fromPage {
+		h.renderPasswordResetPage(c, http.StatusOK, gin.H{"Done": "Password has been reset"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset"})
+}
+
+func (h *HTTPHandler) renderPasswordResetPage(c *gin.Context, status int, data gin.H) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(status)
+	if err := passwordResetPageTemplate.Execute(c.Writer, data); err != nil {
+		h.logger.Error("Failed to render password reset page", zap.Error(err))
+	}
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// PasswordResetStore tracks issued password reset tokens so each can be redeemed once.
+type PasswordResetStore interface {
+	// SaveToken records an issued token by its ID (the JWT "jti").
+	SaveToken(ctx context.Context, tokenID, tenantID, userID string, expiresAt time.Time) error
+	// ConsumeToken marks the token used. It reports false when the token is
+	// unknown or was already used.
+	ConsumeToken(ctx context.Context, tokenID string) (bool, error)
+	// ReleaseToken makes a consumed token redeemable again, for when the
+	// password it was redeemed with was rejected.
+	ReleaseToken(ctx context.Context, tokenID string) error
+}
+
+type passwordResetRepo struct {
+	db *sqlx.DB
+}
+
+// NewPasswordResetStore creates a new SQL-backed password reset store.
+func NewPasswordResetStore(db *sqlx.DB) PasswordResetStore {
+	return &passwordResetRepo{db: db}
+}
+
+func (r *passwordResetRepo) SaveToken(ctx context.Context, tokenID, tenantID, userID string, expiresAt time.Time) error {
+	query := `
+		INSERT INTO password_reset_tokens (id, tenant_id, user_id, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`
+	_, err := r.db.ExecContext(ctx, query, tokenID, tenantID, userID, expiresAt)
+	return err
+}
+
+func (r *passwordResetRepo) ConsumeToken(ctx context.Context, tokenID string) (bool, error) {
+	query := `UPDATE password_reset_tokens SET used_at = NOW() WHERE id = $1 AND used_at IS NULL`
+	res, err := r.db.ExecContext(ctx, query, tokenID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (r *passwordResetRepo) ReleaseToken(ctx context.Context, tokenID string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE password_reset_tokens SET used_at = NULL WHERE id = $1`, tokenID)
+	return err
+}
+
+// CleanupExpired removes expired reset tokens (can be run periodically).
+func (r *passwordResetRepo) CleanupExpired(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM password_reset_tokens WHERE expires_at < $1`, time.Now())
+	return err
+}
+
+// passwordResetMemoryStore is an in-memory PasswordResetStore used when no database is configured.
+type passwordResetMemoryStore struct {
+	mu     sync.Mutex
+	tokens map[string]bool // token ID -> used
+}
+
+func newPasswordResetMemoryStore() *passwordResetMemoryStore {
+	return &passwordResetMemoryStore{tokens: make(map[string]bool)}
+}
+
+func (s *passwordResetMemoryStore) SaveToken(ctx context.Context, tokenID, tenantID, userID string, expiresAt time.Time) error {
+	s.mu.Lock()
+	s.tokens[tokenID] = false
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *passwordResetMemoryStore) ConsumeToken(ctx context.Context, tokenID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	used, ok := s.tokens[tokenID]
+	if !ok || used {
+		return false, nil
+	}
+	s.tokens[tokenID] = true
+	return true, nil
+}
+
+func (s *passwordResetMemoryStore) ReleaseToken(ctx context.Context, tokenID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tokens[tokenID]; ok {
+		s.tokens[tokenID] = false
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// stubResetDirectory serves the directory endpoints used by the reset flow.
+// Only alice@example.com (user-1) exists; password updates are recorded and
+// passwords under 8 characters are rejected.
+func stubResetDirectory(t *testing.T, as *authService) *[]string {
+	t.Helper()
+	var passwords []string
+	directory := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/users":
+			if r.URL.Query().Get("email") != "alice@example.com" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"user": map[string]string{"id": "user-1", "email": "alice@example.com"},
+			})
+		case "/internal/credentials/password":
+			var req struct {
+				UserID   string `json:"user_id"`
+				Password string `json:"password"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			if len(req.Password) < 8 {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(map[string]string{"error": "password does not meet policy: too short", "code": "weak_password"})
+				return
+			}
+			passwords = append(passwords, req.UserID+":"+req.Password)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(directory.Close)
+	as.directory = NewDirectoryClient(DirectoryClientConfig{BaseURL: directory.URL})
+	return &passwords
+}
+
+func TestPasswordResetHappyPath(t *testing.T) {
+	as := newTestService(t)
+	passwords := stubResetDirectory(t, as)
+	mailer := &captureMailer{}
+	as.mailer = mailer
+	tenantID := "11111111-1111-1111-1111-111111111111"
+	ctx := contextWithTenant(t, tenantID)
+
+	session, err := as.generateUserToken(tenantID, "user-1")
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	if _, err := as.sessionSubject(ctx, tenantID, session); err != nil {
+		t.Fatalf("expected session to be valid before reset, got %v", err)
+	}
+
+	if err := as.ForgotPassword(ctx, "alice@example.com"); err != nil {
+		t.Fatalf("forgot password error: %v", err)
+	}
+	if len(mailer.resetLinks) != 1 {
+		t.Fatalf("expected one reset link, got %d", len(mailer.resetLinks))
+	}
+	link, _ := url.Parse(mailer.resetLinks[0])
+	token := link.Query().Get("token")
+
+	// A password the policy rejects does not use up the link.
+	var svcErr *Error
+	if err := as.ResetPassword(ctx, token, "short"); !errors.As(err, &svcErr) || svcErr.Code != "invalid_request" {
+		t.Fatalf("expected the weak password to be rejected, got %v", err)
+	}
+	if err := as.ResetPassword(ctx, token, "new-password-123"); err != nil {
+		t.Fatalf("reset password error: %v", err)
+	}
+	if len(*passwords) != 1 || (*passwords)[0] != "user-1:new-password-123" {
+		t.Fatalf("expected directory password update, got %v", *passwords)
+	}
+
+	if _, err := as.sessionSubject(ctx, tenantID, session); !errors.Is(err, ErrLoginRequired) {
+		t.Fatalf("expected session issued before reset to be rejected, got %v", err)
+	}
+	introspection, err := as.Introspect(ctx, IntrospectRequest{Token: session})
+	if err != nil {
+		t.Fatalf("introspect error: %v", err)
+	}
+	if introspection.Active {
+		t.Fatal("expected session issued before reset to be inactive")
+	}
+	if err := as.ResetPassword(ctx, token, "another-password"); !errors.Is(err, ErrResetTokenUsed) {
+		t.Fatalf("expected reset token to be single use, got %v", err)
+	}
+}
+
+func TestForgotPasswordUnknownEmailSameResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTestService(t)
+	stubResetDirectory(t, as)
+	mailer := &captureMailer{}
+	as.mailer = mailer
+	router := gin.New()
+	NewHTTPHandler(as, zap.NewNop(), nil).RegisterRoutes(router)
+
+	forgot := func(email string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(ForgotPasswordRequest{Email: email})
+		req := httptest.NewRequest(http.MethodPost, "/auth/password/forgot", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Tenant-ID", "11111111-1111-1111-1111-111111111111")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	known := forgot("alice@example.com")
+	unknown := forgot("nobody@example.com")
+	if known.Code != http.StatusAccepted || unknown.Code != known.Code || unknown.Body.String() != known.Body.String() {
+		t.Fatalf("expected identical responses, got %d %q and %d %q", known.Code, known.Body, unknown.Code, unknown.Body)
+	}
+	if len(mailer.resetLinks) != 1 {
+		t.Fatalf("expected a reset link only for the known email, got %d", len(mailer.resetLinks))
+	}
+}
+
+func TestResetPasswordExpiredToken(t *testing.T) {
+	as := newTestService(t)
+	passwords := stubResetDirectory(t, as)
+	tenantID := "11111111-1111-1111-1111-111111111111"
+	ctx := contextWithTenant(t, tenantID)
+
+	token, err := as.passwordResetToken(ctx, tenantID, "user-1", time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("failed to issue token: %v", err)
+	}
+	if err := as.ResetPassword(ctx, token, "new-password-123"); !errors.Is(err, ErrResetTokenExpired) {
+		t.Fatalf("expected expired token error, got %v", err)
+	}
+	if len(*passwords) != 0 {
+		t.Fatalf("expected no password update, got %v", *passwords)
+	}
+}
+
+func TestPasswordResetLinkOpensResetPage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTestService(t)
+	passwords := stubResetDirectory(t, as)
+	mailer := &captureMailer{}
+	as.mailer = mailer
+	router := gin.New()
+	NewHTTPHandler(as, zap.NewNop(), nil).RegisterRoutes(router)
+
+	if err := as.ForgotPassword(contextWithTenant(t, "11111111-1111-1111-1111-111111111111"), "alice@example.com"); err != nil {
+		t.Fatalf("forgot password error: %v", err)
+	}
+	link, _ := url.Parse(mailer.resetLinks[0])
+	token := link.Query().Get("token")
+
+	// Clicking the link is a GET.
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, link.RequestURI(), nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `value="`+token+`"`) {
+		t.Fatalf("expected a reset form carrying the token, got %d %q", w.Code, w.Body)
+	}
+
+	submit := func(password string) *httptest.ResponseRecorder {
+		form := url.Values{"token": {token}, "password": {password}}
+		req := httptest.NewRequest(http.MethodPost, "/auth/password/reset", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	if w := submit("short"); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "<form") {
+		t.Fatalf("expected the form back for a short password, got %d %q", w.Code, w.Body)
+	}
+	if w := submit("new-password-123"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Password has been reset") {
+		t.Fatalf("expected the reset to succeed, got %d %q", w.Code, w.Body)
+	}
+	if len(*passwords) != 1 || (*passwords)[0] != "user-1:new-password-123" {
+		t.Fatalf("expected directory password update, got %v", *passwords)
+	}
+}
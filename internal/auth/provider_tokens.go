@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// offlineAccessScope is the OIDC scope that asks the provider for a refresh
+// token.
+const offlineAccessScope = "offline_access"
+
+var (
+	// ErrNoProviderRefreshToken means the user's link has no refresh token,
+	// because offline_access was not granted or the token was revoked.
+	ErrNoProviderRefreshToken = &Error{"invalid_request", "no provider refresh token is stored for this user"}
+	// ErrProviderRefreshRevoked means the provider rejected the stored
+	// refresh token. It is cleared; the user must sign in again.
+	ErrProviderRefreshRevoked = &Error{"invalid_grant", "the provider refresh token has been revoked"}
+)
+
+// grantsOfflineAccess reports whether token was issued for offline_access.
+// Providers that omit the granted scope are taken to grant what was asked.
+func grantsOfflineAccess(token *oauth2.Token, requested []string) bool {
+	if granted, ok := token.Extra("scope").(string); ok && granted != "" {
+		return slices.Contains(strings.Fields(granted), offlineAccessScope)
+	}
+	return slices.Contains(requested, offlineAccessScope)
+}
+
+// refreshTokenAdditionalData binds an encrypted refresh token to its link.
+func refreshTokenAdditionalData(tenantID, provider, identityID string) string {
+	return "federated_identity:" + tenantID + ":" + provider + ":" + identityID
+}
+
+// saveProviderRefreshToken stores refreshToken, encrypted, on link.
+func (s *authService) saveProviderRefreshToken(ctx context.Context, link *FederatedIdentity, refreshToken string) error {
+	sealed, err := s.ssoCipher.Encrypt(refreshToken, refreshTokenAdditionalData(link.TenantID, link.Provider, link.IdentityID))
+	if err != nil {
+		return err
+	}
+	return s.federationStore.SetRefreshToken(ctx, link.ID, &sealed)
+}
+
+// RefreshProviderToken obtains a new access token from provider for the
+// user, using the refresh token stored when they signed in. A refresh token
+// the provider rotates is stored in place of the old one.
+func (s *authService) RefreshProviderToken(ctx context.Context, tenantID, provider, userID string) (*oauth2.Token, error) {
+	link, err := s.federationStore.GetByUser(ctx, tenantID, provider, userID)
+	if err != nil {
+		return nil, err
+	}
+	if link == nil || link.RefreshToken == nil || *link.RefreshToken == "" {
+		return nil, ErrNoProviderRefreshToken
+	}
+	refreshToken, err := s.ssoCipher.Decrypt(*link.RefreshToken, refreshTokenAdditionalData(tenantID, provider, userID))
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := s.socialClientFor(ctx, tenantID, provider)
+	if err != nil {
+		return nil, err
+	}
+	token, err := client.config.TokenSource(ctx, &oauth2.Token{RefreshToken: refreshToken}).Token()
+	if err != nil {
+		var retrieveErr *oauth2.RetrieveError
+		if errors.As(err, &retrieveErr) && retrieveErr.ErrorCode == "invalid_grant" {
+			if clearErr := s.federationStore.SetRefreshToken(ctx, link.ID, nil); clearErr != nil {
+				return nil, clearErr
+			}
+			return nil, ErrProviderRefreshRevoked
+		}
+		return nil, err
+	}
+
+	if token.RefreshToken != "" && token.RefreshToken != refreshToken {
+		if err := s.saveProviderRefreshToken(ctx, link, token.RefreshToken); err != nil {
+			return nil, err
+		}
+	}
+	return token, nil
+}
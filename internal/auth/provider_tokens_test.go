@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dhawalhost/wardseal/internal/sso"
+	"github.com/dhawalhost/wardseal/pkg/secretbox"
+)
+
+// memoryFederationStore is an in-memory FederationStore.
+type memoryFederationStore struct {
+	links map[string]*FederatedIdentity
+}
+
+func (m *memoryFederationStore) Get(ctx context.Context, tenantID, provider, externalID string) (*FederatedIdentity, error) {
+	for _, l := range m.links {
+		if l.TenantID == tenantID && l.Provider == provider && l.ExternalID == externalID {
+			return l, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryFederationStore) GetByUser(ctx context.Context, tenantID, provider, identityID string) (*FederatedIdentity, error) {
+	for _, l := range m.links {
+		if l.TenantID == tenantID && l.Provider == provider && l.IdentityID == identityID {
+			return l, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryFederationStore) Create(ctx context.Context, identity FederatedIdentity) error {
+	m.links[identity.ID] = &identity
+	return nil
+}
+
+func (m *memoryFederationStore) SetRefreshToken(ctx context.Context, id string, refreshToken *string) error {
+	m.links[id].RefreshToken = refreshToken
+	return nil
+}
+
+func (m *memoryFederationStore) List(ctx context.Context, identityID string) ([]FederatedIdentity, error) {
+	return nil, nil
+}
+
+func (m *memoryFederationStore) Delete(ctx context.Context, id string) error {
+	delete(m.links, id)
+	return nil
+}
+
+// newRefreshTestService links user-1 to github with refresh token rt-1 and
+// points github at tokenEndpoint.
+func newRefreshTestService(t *testing.T, tokenEndpoint http.HandlerFunc) (*authService, *memoryFederationStore) {
+	t.Helper()
+	upstream := httptest.NewServer(tokenEndpoint)
+	t.Cleanup(upstream.Close)
+
+	as := newSocialTestService(t, upstream.URL)
+	cipher, err := secretbox.New(bytes.Repeat([]byte{3}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	as.ssoCipher = cipher
+
+	store := &memoryFederationStore{links: make(map[string]*FederatedIdentity)}
+	as.federationStore = store
+	link := &FederatedIdentity{ID: "link-1", IdentityID: "user-1", TenantID: socialTenant, Provider: "github", ExternalID: "583231"}
+	store.links[link.ID] = link
+	if err := as.saveProviderRefreshToken(context.Background(), link, "rt-1"); err != nil {
+		t.Fatal(err)
+	}
+	if *link.RefreshToken == "rt-1" {
+		t.Fatal("expected the refresh token to be stored encrypted")
+	}
+	return as, store
+}
+
+func TestRefreshProviderTokenRotates(t *testing.T) {
+	as, store := newRefreshTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/token" || r.FormValue("grant_type") != "refresh_token" || r.FormValue("refresh_token") != "rt-1" {
+			http.Error(w, `{"error":"invalid_request"}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"at-2","token_type":"Bearer","expires_in":3600,"refresh_token":"rt-2"}`))
+	})
+
+	token, err := as.RefreshProviderToken(context.Background(), socialTenant, "github", "user-1")
+	if err != nil {
+		t.Fatalf("RefreshProviderToken failed: %v", err)
+	}
+	if token.AccessToken != "at-2" {
+		t.Fatalf("expected access token at-2, got %q", token.AccessToken)
+	}
+	link := store.links["link-1"]
+	stored, err := as.ssoCipher.Decrypt(*link.RefreshToken, refreshTokenAdditionalData(socialTenant, "github", "user-1"))
+	if err != nil || stored != "rt-2" {
+		t.Fatalf("expected the rotated refresh token to be stored, got %q, %v", stored, err)
+	}
+}
+
+func TestRefreshProviderTokenRevoked(t *testing.T) {
+	as, store := newRefreshTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"invalid_grant","error_description":"Token has been expired or revoked."}`))
+	})
+
+	_, err := as.RefreshProviderToken(context.Background(), socialTenant, "github", "user-1")
+	if !errors.Is(err, ErrProviderRefreshRevoked) {
+		t.Fatalf("expected ErrProviderRefreshRevoked, got %v", err)
+	}
+	if store.links["link-1"].RefreshToken != nil {
+		t.Fatal("expected the revoked refresh token to be cleared")
+	}
+	if _, err := as.RefreshProviderToken(context.Background(), socialTenant, "github", "user-1"); !errors.Is(err, ErrNoProviderRefreshToken) {
+		t.Fatalf("expected ErrNoProviderRefreshToken after revocation, got %v", err)
+	}
+}
+
+func TestResolveFederatedUserRequiresVerifiedEmail(t *testing.T) {
+	as := newTestService(t)
+	store := &memoryFederationStore{links: make(map[string]*FederatedIdentity)}
+	as.federationStore = store
+	as.directory = newDirectoryTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"user":{"id":"user-1","email":"alice@example.com"}}`))
+	})
+	profile := FederatedProfile{Subject: "583231", Email: "alice@example.com"}
+
+	_, err := as.resolveFederatedUser(context.Background(), socialTenant, "github", sso.LinkPolicyEmail, profile)
+	var authErr *Error
+	if !errors.As(err, &authErr) || authErr.Code != "access_denied" {
+		t.Fatalf("expected access_denied for an unverified email, got %v", err)
+	}
+	if len(store.links) != 0 {
+		t.Fatal("expected no link for an unverified email")
+	}
+
+	profile.EmailVerified = true
+	userID, err := as.resolveFederatedUser(context.Background(), socialTenant, "github", sso.LinkPolicyEmail, profile)
+	if err != nil || userID != "user-1" {
+		t.Fatalf("expected the verified email to link user-1, got %q, %v", userID, err)
+	}
+}
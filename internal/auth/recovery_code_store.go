@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// RecoveryCode is a hashed one-time MFA recovery code.
+type RecoveryCode struct {
+	ID         string     `db:"id"`
+	TenantID   string     `db:"tenant_id"`
+	IdentityID string     `db:"identity_id"`
+	CodeHash   string     `db:"code_hash"`
+	CreatedAt  time.Time  `db:"created_at"`
+	UsedAt     *time.Time `db:"used_at"`
+}
+
+// RecoveryCodeStore defines the interface for MFA recovery code storage.
+type RecoveryCodeStore interface {
+	// Replace atomically discards all existing codes for the identity and stores the new hashes.
+	Replace(ctx context.Context, tenantID, identityID string, hashes []string) error
+	ListUnused(ctx context.Context, tenantID, identityID string) ([]RecoveryCode, error)
+	// MarkUsed consumes the code, returning false if it was already used.
+	MarkUsed(ctx context.Context, id string) (bool, error)
+}
+
+type recoveryCodeRepo struct {
+	db *sqlx.DB
+}
+
+// NewRecoveryCodeStore creates a new recovery code store.
+func NewRecoveryCodeStore(db *sqlx.DB) RecoveryCodeStore {
+	return &recoveryCodeRepo{db: db}
+}
+
+func (r *recoveryCodeRepo) Replace(ctx context.Context, tenantID, identityID string, hashes []string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE tenant_id = $1 AND identity_id = $2`, tenantID, identityID); err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO mfa_recovery_codes (tenant_id, identity_id, code_hash) VALUES ($1, $2, $3)`,
+			tenantID, identityID, hash,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *recoveryCodeRepo) ListUnused(ctx context.Context, tenantID, identityID string) ([]RecoveryCode, error) {
+	var codes []RecoveryCode
+	query := `SELECT id, tenant_id, identity_id, code_hash, created_at, used_at FROM mfa_recovery_codes WHERE tenant_id = $1 AND identity_id = $2 AND used_at IS NULL`
+	if err := r.db.SelectContext(ctx, &codes, query, tenantID, identityID); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (r *recoveryCodeRepo) MarkUsed(ctx context.Context, id string) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `UPDATE mfa_recovery_codes SET used_at = NOW() WHERE id = $1 AND used_at IS NULL`, id)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+// recoveryCodeMemoryStore is an in-memory RecoveryCodeStore used when no database is configured.
+type recoveryCodeMemoryStore struct {
+	mu    sync.Mutex
+	codes map[string][]*RecoveryCode
+}
+
+func newRecoveryCodeMemoryStore() *recoveryCodeMemoryStore {
+	return &recoveryCodeMemoryStore{codes: make(map[string][]*RecoveryCode)}
+}
+
+func (s *recoveryCodeMemoryStore) key(tenantID, identityID string) string {
+	return tenantID + "::" + identityID
+}
+
+func (s *recoveryCodeMemoryStore) Replace(ctx context.Context, tenantID, identityID string, hashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	codes := make([]*RecoveryCode, 0, len(hashes))
+	for _, hash := range hashes {
+		codes = append(codes, &RecoveryCode{
+			ID:         uuid.New().String(),
+			TenantID:   tenantID,
+			IdentityID: identityID,
+			CodeHash:   hash,
+			CreatedAt:  time.Now(),
+		})
+	}
+	s.codes[s.key(tenantID, identityID)] = codes
+	return nil
+}
+
+func (s *recoveryCodeMemoryStore) ListUnused(ctx context.Context, tenantID, identityID string) ([]RecoveryCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []RecoveryCode
+	for _, code := range s.codes[s.key(tenantID, identityID)] {
+		if code.UsedAt == nil {
+			out = append(out, *code)
+		}
+	}
+	return out, nil
+}
+
+func (s *recoveryCodeMemoryStore) MarkUsed(ctx context.Context, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, codes := range s.codes {
+		for _, code := range codes {
+			if code.ID == id {
+				if code.UsedAt != nil {
+					return false, nil
+				}
+				now := time.Now()
+				code.UsedAt = &now
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
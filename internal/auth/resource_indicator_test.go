@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+const ordersResource = "https://orders.wardseal.com"
+
+// authorizeForResource runs the authorization code flow for test-client,
+// requesting resource at authorize and tokenResource at the token endpoint.
+func authorizeForResource(t *testing.T, as *authService, ctx context.Context, resource, tokenResource string) (TokenResponse, error) {
+	t.Helper()
+	verifier := "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNO1234567890abcd"
+	authResp, err := as.Authorize(ctx, AuthorizeRequest{
+		ResponseType:  "code",
+		ClientID:      "test-client",
+		RedirectURI:   "https://app.wardseal.com/callback",
+		Scope:         "openid",
+		CodeChallenge: pkceChallenge(verifier),
+		Resource:      resource,
+	})
+	if err != nil {
+		return TokenResponse{}, err
+	}
+	return as.Token(ctx, TokenRequest{
+		GrantType:    "authorization_code",
+		Code:         extractCode(t, authResp.RedirectURI),
+		RedirectURI:  "https://app.wardseal.com/callback",
+		ClientID:     "test-client",
+		CodeVerifier: verifier,
+		Resource:     tokenResource,
+	})
+}
+
+func TestResourceIndicatorScopesAudience(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTestService(t)
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+
+	tokenResp, err := authorizeForResource(t, as, ctx, ordersResource, "")
+	if err != nil {
+		t.Fatalf("token error: %v", err)
+	}
+	claims, err := as.parseSignedToken(tokenResp.AccessToken)
+	if err != nil || claims["aud"] != ordersResource {
+		t.Fatalf("expected aud %s, got %v (%v)", ordersResource, claims["aud"], err)
+	}
+
+	resp, err := as.Introspect(ctx, IntrospectRequest{Token: tokenResp.AccessToken, Resource: ordersResource})
+	if err != nil {
+		t.Fatalf("introspect error: %v", err)
+	}
+	if !resp.Active || resp.Aud != ordersResource {
+		t.Fatalf("expected the token to be active for its resource, got %+v", resp)
+	}
+	if resp, _ := as.Introspect(ctx, IntrospectRequest{Token: tokenResp.AccessToken, Resource: "https://billing.wardseal.com"}); resp.Active {
+		t.Fatalf("expected the token to be inactive at another resource")
+	}
+
+	// Refreshed access tokens keep the resource, which cannot be swapped.
+	refreshed, err := as.Token(ctx, TokenRequest{GrantType: "refresh_token", RefreshToken: tokenResp.RefreshToken})
+	if err != nil {
+		t.Fatalf("refresh error: %v", err)
+	}
+	if claims, _ := as.parseSignedToken(refreshed.AccessToken); claims["aud"] != ordersResource {
+		t.Fatalf("expected the refreshed token to keep aud %s, got %v", ordersResource, claims["aud"])
+	}
+	_, err = as.Token(ctx, TokenRequest{GrantType: "refresh_token", RefreshToken: refreshed.RefreshToken, Resource: "https://billing.wardseal.com"})
+	if !errors.Is(err, ErrInvalidResource) {
+		t.Fatalf("expected invalid_target when refreshing for another resource, got %v", err)
+	}
+
+	// Tokens issued without a resource keep the default audience, which no
+	// resource server accepts once it names itself.
+	plain, err := authorizeForResource(t, as, ctx, "", "")
+	if err != nil {
+		t.Fatalf("token error: %v", err)
+	}
+	if resp, _ := as.Introspect(ctx, IntrospectRequest{Token: plain.AccessToken}); !resp.Active || resp.Aud != "client-app" {
+		t.Fatalf("expected an active client-app token, got %+v", resp)
+	}
+	if resp, _ := as.Introspect(ctx, IntrospectRequest{Token: plain.AccessToken, Resource: ordersResource}); resp.Active {
+		t.Fatalf("expected a token without a resource to be inactive at %s", ordersResource)
+	}
+}
+
+func TestResourceIndicatorRejectsDisallowedResource(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTestService(t)
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+
+	if _, err := authorizeForResource(t, as, ctx, "https://evil.example.com", ""); !errors.Is(err, ErrInvalidResource) {
+		t.Fatalf("expected invalid_target at authorize, got %v", err)
+	}
+	if _, err := authorizeForResource(t, as, ctx, "", "https://evil.example.com"); !errors.Is(err, ErrInvalidResource) {
+		t.Fatalf("expected invalid_target at the token endpoint, got %v", err)
+	}
+	if _, err := authorizeForResource(t, as, ctx, ordersResource, "https://billing.wardseal.com"); !errors.Is(err, ErrInvalidResource) {
+		t.Fatalf("expected invalid_target for a resource other than the authorized one, got %v", err)
+	}
+}
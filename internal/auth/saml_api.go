@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dhawalhost/wardseal/internal/saml"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// SAMLRequestCookie binds an SP-initiated SAML login to the browser that
+// started it, so a response to someone else's AuthnRequest cannot sign this
+// browser in.
+const SAMLRequestCookie = "wardseal_saml_request"
+
+// samlRequestCookiePath limits SAMLRequestCookie to the ACS.
+const samlRequestCookiePath = "/saml/acs"
+
+// samlLogin redirects the browser to the tenant's SAML IdP (SP-initiated SSO).
+func (h *HTTPHandler) samlLogin(c *gin.Context) {
+	provider := c.Query("provider")
+	if provider == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "provider is required"})
+		return
+	}
+
+	redirectURL, requestID, err := h.svc.BeginSAMLLogin(c.Request.Context(), provider, c.Query("relay_state"))
+	if err != nil {
+		h.logger.Error("SAML login failed", zap.Error(err))
+		svcErr := &Error{}
+		if errors.As(err, &svcErr) {
+			h.respondOAuthError(c, svcErr)
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	// The IdP returns the browser with a cross-site POST, which only carries
+	// SameSite=None cookies, and browsers require those to be Secure.
+	c.SetSameSite(http.SameSiteNoneMode)
+	c.SetCookie(SAMLRequestCookie, requestID, int(saml.DefaultRequestTTL.Seconds()), samlRequestCookiePath, "", true, true)
+	c.Redirect(http.StatusFound, redirectURL)
+}
+
+// samlACS is the Assertion Consumer Service receiving HTTP-POST bound responses.
+// The tenant is taken from the outstanding AuthnRequest rather than a header,
+// since the browser POST from the IdP carries none. The response must answer
+// the request in the browser's SAMLRequestCookie.
+func (h *HTTPHandler) samlACS(c *gin.Context) {
+	requestID, _ := c.Cookie(SAMLRequestCookie)
+	c.SetSameSite(http.SameSiteNoneMode)
+	c.SetCookie(SAMLRequestCookie, "", -1, samlRequestCookiePath, "", true, true)
+	samlResponse := c.PostForm("SAMLResponse")
+	if samlResponse == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "SAMLResponse is required"})
+		return
+	}
+
+	token, err := h.svc.ConsumeSAMLResponse(c.Request.Context(), samlResponse, requestID)
+	if err != nil {
+		h.logger.Warn("SAML assertion rejected", zap.Error(err))
+		svcErr := &Error{}
+		if errors.As(err, &svcErr) {
+			h.respondOAuthError(c, svcErr)
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	setAuthCookies(c, token, "")
+
+	c.JSON(http.StatusOK, LoginResponse{Token: token})
+}
+
+// samlMetadata serves the SP metadata document.
+func (h *HTTPHandler) samlMetadata(c *gin.Context) {
+	metadata, err := h.svc.SAMLMetadata()
+	if err != nil {
+		h.logger.Error("Failed to render SAML metadata", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to render metadata"})
+		return
+	}
+	c.Data(http.StatusOK, "application/samlmetadata+xml", metadata)
+}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dhawalhost/wardseal/internal/scopecatalog"
+	"github.com/gin-gonic/gin"
+)
+
+// testScopeCatalog serves the same catalog to every tenant.
+type testScopeCatalog []scopecatalog.Scope
+
+func (c testScopeCatalog) List(ctx context.Context, tenantID string) ([]scopecatalog.Scope, error) {
+	return c, nil
+}
+
+func TestAuthorizeRejectsScopeMissingFromCatalog(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTestService(t)
+	// test-client is allowed openid and profile, but the tenant no longer
+	// catalogues profile.
+	as.scopeCatalog = testScopeCatalog{{ID: "openid"}}
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+	req := AuthorizeRequest{
+		ResponseType:  "code",
+		ClientID:      "test-client",
+		RedirectURI:   "https://app.wardseal.com/callback",
+		Scope:         "openid profile",
+		CodeChallenge: pkceChallenge("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNO1234567890abcd"),
+	}
+
+	_, err := as.Authorize(ctx, req)
+	var svcErr *Error
+	if !errors.As(err, &svcErr) || svcErr.Code != "invalid_scope" {
+		t.Fatalf("expected invalid_scope for an uncatalogued scope, got %v", err)
+	}
+
+	// The drop policy grants the catalogued scopes only.
+	as.scopePolicy = ScopePolicyDrop
+	if _, _, err := as.validateAuthorizeRequest(ctx, &req); err != nil {
+		t.Fatalf("authorize error: %v", err)
+	}
+	if req.Scope != "openid" {
+		t.Fatalf("expected only openid to be granted, got %q", req.Scope)
+	}
+}
+
+func TestConsentPromptDescribesScopes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTestService(t)
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+
+	prompt, err := as.ConsentPrompt(ctx, partnerAuthorizeRequest(t, as, "openid profile"))
+	if err != nil {
+		t.Fatalf("consent prompt error: %v", err)
+	}
+	want := []ConsentScope{
+		{Scope: "openid", DisplayName: "OpenID", Description: "Sign you in with your account"},
+		{Scope: "profile", DisplayName: "Profile", Description: "Read your name and basic profile information"},
+	}
+	if len(prompt.Scopes) != len(want) {
+		t.Fatalf("expected %d scopes, got %+v", len(want), prompt.Scopes)
+	}
+	for i := range want {
+		if prompt.Scopes[i] != want[i] {
+			t.Fatalf("scope %d: expected %+v, got %+v", i, want[i], prompt.Scopes[i])
+		}
+	}
+
+	// A tenant's own entry replaces the default description.
+	as.scopeCatalog = testScopeCatalog{
+		{ID: "openid", DisplayName: "Sign in"},
+		{ID: "profile", DisplayName: "Employee profile", Description: "Read your HR record", Sensitive: true},
+	}
+	prompt, err = as.ConsentPrompt(ctx, partnerAuthorizeRequest(t, as, "profile"))
+	if err != nil {
+		t.Fatalf("consent prompt error: %v", err)
+	}
+	if len(prompt.Scopes) != 1 || prompt.Scopes[0] != (ConsentScope{Scope: "profile", DisplayName: "Employee profile", Description: "Read your HR record", Sensitive: true}) {
+		t.Fatalf("expected the tenant's description, got %+v", prompt.Scopes)
+	}
+}
@@ -1,35 +1,35 @@
 package auth
 
 import (
-	"bytes"
 	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/subtle"
 	"crypto/x509"
-	"crypto/x509/pkix"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"math/big"
 	"net/http"
 	"net/url"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/dhawalhost/wardseal/internal/oauthclient"
 	"github.com/dhawalhost/wardseal/internal/saml"
+	"github.com/dhawalhost/wardseal/internal/scopecatalog"
 	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/dhawalhost/wardseal/pkg/secretbox"
 	"github.com/go-webauthn/webauthn/protocol"
 	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
-	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/oauth2"
 	"gopkg.in/go-jose/go-jose.v2"
 )
 
@@ -37,11 +37,51 @@ import (
 type Service interface {
 	Login(ctx context.Context, username, password, deviceID, userAgent, ip, clientOSVersion string) (string, error)
 	Authorize(ctx context.Context, req AuthorizeRequest) (AuthorizeResponse, error)
+	ConsentPrompt(ctx context.Context, req AuthorizeRequest) (ConsentPrompt, error)
+	Consent(ctx context.Context, req ConsentRequest) (AuthorizeResponse, error)
+	AuthenticateIntrospectionCaller(ctx context.Context, caller IntrospectionCaller) error
+	ServiceAuthHeader() string
+	// DPoPNonce returns the nonce DPoP proofs must carry, or "" when nonces
+	// are not required.
+	DPoPNonce() string
+	EndSession(ctx context.Context, req EndSessionRequest) (string, error)
+	DeviceAuthorization(ctx context.Context, req DeviceAuthorizationRequest) (DeviceAuthorizationResponse, error)
+	DeviceVerification(ctx context.Context, userCode, sessionToken string) (ConsentPrompt, error)
+	DeviceApproval(ctx context.Context, req DeviceApprovalRequest) error
+	// Email verification and password reset
+	VerifyEmail(ctx context.Context, token string) error
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, password string) error
+	EmailVerificationPolicy(ctx context.Context, tenantID string) (EmailVerificationPolicy, error)
+	UpdateEmailVerificationPolicy(ctx context.Context, tenantID string, policy EmailVerificationPolicy) error
+	// Sessions
+	// SessionSubject returns the user ID of a session token issued for the
+	// tenant, or ErrLoginRequired when it is missing, invalid or revoked.
+	SessionSubject(ctx context.Context, tenantID, sessionToken string) (string, error)
+	// ListSessions returns the tenant's active sessions, only the subject's
+	// when subject is set.
+	ListSessions(ctx context.Context, tenantID, subject string) ([]Session, error)
+	// RevokeSession ends a session by revoking its refresh token family and
+	// returns the session it ended.
+	RevokeSession(ctx context.Context, tenantID, id string) (Session, error)
+	// Impersonation
+	StartImpersonation(ctx context.Context, tenantID string, req ImpersonationRequest) (Impersonation, error)
+	StopImpersonation(ctx context.Context, tenantID, token string) (Impersonation, error)
+	ImpersonationPolicy(ctx context.Context, tenantID string) (ImpersonationPolicy, error)
+	UpdateImpersonationPolicy(ctx context.Context, tenantID string, policy ImpersonationPolicy) error
 	Token(ctx context.Context, req TokenRequest) (TokenResponse, error)
 	Introspect(ctx context.Context, req IntrospectRequest) (IntrospectResponse, error)
 	Revoke(ctx context.Context, req RevokeRequest) error
 	SAML() *saml.Provider
 	JWKS() jose.JSONWebKeySet
+	RotateSigningKey(ctx context.Context) (string, error)
+	// LoadSigningKeys reads the keys from the SigningKeyStore, storing the
+	// current key when there are none yet. It does nothing without a store.
+	LoadSigningKeys(ctx context.Context) error
+	// RunSigningKeyRotation keeps the signing keys current until ctx is
+	// done: it reloads stored keys every minute and rotates once the newest
+	// key is older than every (never when every <= 0).
+	RunSigningKeyRotation(ctx context.Context, every time.Duration)
 	Device() DeviceStore
 	Signal() SignalStore
 	WebAuthn() *webauthn.WebAuthn
@@ -51,12 +91,29 @@ type Service interface {
 	BeginWebAuthnLogin(ctx context.Context, userID string) (*protocol.CredentialAssertion, *webauthn.SessionData, error)
 	FinishWebAuthnLogin(ctx context.Context, userID string, session webauthn.SessionData, req *http.Request) (string, error)
 	// Social Login
+	StartSocialLogin(ctx context.Context, provider, redirectURI string) (authorizeURL, state string, err error)
 	SocialLogin(ctx context.Context, req SocialLoginRequest) (TokenResponse, error)
+	SocialLoginCallback(ctx context.Context, provider, state, code string) (TokenResponse, error)
+	RefreshProviderToken(ctx context.Context, tenantID, provider, userID string) (*oauth2.Token, error)
 	// Branding
 	GetBranding(ctx context.Context, tenantID string) (BrandingConfig, error)
 	UpdateBranding(ctx context.Context, config BrandingConfig) error
 	// TOTP MFA
 	TOTP() TOTPStore
+	// AuthAudit records authentication events for audit/SIEM ingestion.
+	AuthAudit() AuthAuditStore
+	EnrollTOTP(ctx context.Context, accountID string) (*TOTPEnrollment, error)
+	VerifyTOTP(ctx context.Context, accountID, code string) error
+	DeleteTOTP(ctx context.Context, accountID, code string) error
+	MFAChallenge(ctx context.Context, login, sessionToken string) (string, error)
+	CompleteMFALogin(ctx context.Context, challenge, code string) (string, error)
+	ParseMFAChallenge(ctx context.Context, challenge string) (*PendingMFALogin, error)
+	RevokeMFAChallenge(ctx context.Context, challengeID string) error
+	RegenerateRecoveryCodes(ctx context.Context, accountID, code string) ([]string, error)
+	// SAML Service Provider
+	BeginSAMLLogin(ctx context.Context, provider, relayState string) (string, string, error)
+	ConsumeSAMLResponse(ctx context.Context, samlResponse, requestID string) (string, error)
+	SAMLMetadata() ([]byte, error)
 
 	// User Lookup
 	LookupUser(ctx context.Context, tenantID, email string) (LookupResult, error)
@@ -71,27 +128,47 @@ type LookupResult struct {
 }
 
 type authService struct {
-	directoryServiceURL string
-	httpClient          *http.Client
-	privateKey          *rsa.PrivateKey
-	serviceAuthHeader   string
-	serviceAuthToken    string
-	codeStore           AuthorizationCodeStore
-	refreshTokenStore   RefreshTokenStore
-	revokedTokens       RevocationStore
-	clients             map[clientKey]ClientConfig
-	clientStore         oauthclient.Store
-	samlProvider        *saml.Provider
-	deviceStore         DeviceStore
-	signalStore         SignalStore
-	riskEngine          *RiskEngine
-	webAuthn            *webauthn.WebAuthn
-	webAuthnStore       WebAuthnRepository
-	brandingStore       BrandingStore
-	federationStore     FederationStore
-	totpStore           TOTPStore
-	ssoProviderStore    SSOProviderStore
-	keyID               string
+	directory         DirectoryClient
+	signingKeys       *signingKeySet
+	serviceAuthHeader string
+	serviceAuthToken  string
+	codeStore         AuthorizationCodeStore
+	refreshTokenStore RefreshTokenStore
+	sessionStore      SessionStore
+	revokedTokens     RevocationStore
+	clients           map[clientKey]ClientConfig
+	clientStore       oauthclient.Store
+	samlProvider      *saml.Provider
+	samlConsumer      *saml.AssertionConsumer
+	deviceStore       DeviceStore
+	signalStore       SignalStore
+	riskEngine        *RiskEngine
+	webAuthn          *webauthn.WebAuthn
+	webAuthnStore     WebAuthnRepository
+	brandingStore     BrandingStore
+	federationStore   FederationStore
+	totpStore         TOTPStore
+	totpCipher        *secretbox.Cipher
+	recoveryCodeStore RecoveryCodeStore
+	ssoProviderStore  SSOProviderStore
+	ssoCipher         *secretbox.Cipher
+	socialStateStore  SocialStateStore
+	authAuditStore    AuthAuditStore
+	consentStore      ConsentStore
+	scopePolicy       string
+	scopeCatalog      scopecatalog.Catalog
+	accessTokenFormat string
+	accessTokenStore  AccessTokenStore
+	dpopReplayStore   DPoPReplayStore
+	dpopNonceKey      []byte
+	deviceCodeStore   DeviceCodeStore
+	baseURL           string
+	// Email verification and password reset
+	emailVerificationStore EmailVerificationStore
+	passwordResetStore     PasswordResetStore
+	mailer                 Mailer
+	impersonationStore     ImpersonationStore
+	permissions            middleware.PermissionChecker
 }
 
 // AuthorizationCodeStore defines the interface for storing authorization codes.
@@ -106,6 +183,8 @@ type RefreshTokenStore interface {
 	Save(ctx context.Context, entry refreshTokenEntry) error
 	Get(ctx context.Context, token string) (refreshTokenEntry, bool, error)
 	Delete(ctx context.Context, token string) error
+	// DeleteFamily removes every refresh token rotated from the same original grant.
+	DeleteFamily(ctx context.Context, familyID string) error
 }
 
 // RevocationStore defines the interface for token revocation.
@@ -117,30 +196,126 @@ type RevocationStore interface {
 // Config captures the settings for the auth service.
 type Config struct {
 	DirectoryServiceURL string
-	ServiceAuthToken    string
-	ServiceAuthHeader   string
-	Clients             []ClientConfig
-	ClientStore         oauthclient.Store
-	SAMLStore           *saml.Store
-	DeviceStore         DeviceStore
-	SignalStore         SignalStore
-	WebAuthnStore       WebAuthnRepository
-	BrandingStore       BrandingStore
-	FederationStore     FederationStore
-	BaseURL             string
+	// DirectoryClient overrides the client built from DirectoryServiceURL.
+	DirectoryClient   DirectoryClient
+	ServiceAuthToken  string
+	ServiceAuthHeader string
+	// ServiceName and ServiceSigningKey sign the service tokens sent to the
+	// directory in place of ServiceAuthToken.
+	ServiceName       string
+	ServiceSigningKey []byte
+	Clients           []ClientConfig
+	ClientStore       oauthclient.Store
+	SAMLStore         *saml.Store
+	SAMLCertificate   *x509.Certificate // published in the SP metadata; see saml.LoadKeyPair
+	SAMLPrivateKey    *rsa.PrivateKey
+	DeviceStore       DeviceStore
+	SignalStore       SignalStore
+	WebAuthnStore     WebAuthnRepository
+	BrandingStore     BrandingStore
+	FederationStore   FederationStore
+	BaseURL           string
 	// Persistent stores (optional, defaults to in-memory if not provided)
-	CodeStore        AuthorizationCodeStore
-	RefreshStore     RefreshTokenStore
-	RevocationStore  RevocationStore
-	TOTPStore        TOTPStore
-	SSOProviderStore SSOProviderStore
+	CodeStore         AuthorizationCodeStore
+	RefreshStore      RefreshTokenStore
+	SessionStore      SessionStore
+	RevocationStore   RevocationStore
+	TOTPStore         TOTPStore
+	RecoveryCodeStore RecoveryCodeStore
+	SSOProviderStore  SSOProviderStore
+	SocialStateStore  SocialStateStore
+	AuthAuditStore    AuthAuditStore
+	ConsentStore      ConsentStore
+	DeviceCodeStore   DeviceCodeStore
+	// EmailVerificationStore backs email verification of new accounts and
+	// PasswordResetStore the forgot-password flow. Mailer delivers their
+	// links, which are only logged when no mailer is configured; that is for
+	// development only, see NewSMTPMailer.
+	EmailVerificationStore EmailVerificationStore
+	PasswordResetStore     PasswordResetStore
+	Mailer                 Mailer
+	// ImpersonationStore holds each tenant's impersonation policy.
+	ImpersonationStore ImpersonationStore
+	// Permissions decides who may impersonate users (users:impersonate).
+	// Impersonation is refused when it is nil.
+	Permissions middleware.PermissionChecker
+	// ScopePolicy decides how Authorize treats scopes outside the client's
+	// AllowedScopes: ScopePolicyReject (the default) or ScopePolicyDrop.
+	ScopePolicy string
+	// ScopeCatalog lists the scopes a tenant's clients may be granted and
+	// describes them on the consent screen. Defaults to
+	// scopecatalog.DefaultCatalog().
+	ScopeCatalog scopecatalog.Catalog
+	// AccessTokenFormat is AccessTokenFormatJWT (the default) or
+	// AccessTokenFormatOpaque. Opaque tokens are kept in AccessTokenStore,
+	// which defaults to in-memory.
+	AccessTokenFormat string
+	AccessTokenStore  AccessTokenStore
+	// DPoPReplayStore remembers accepted DPoP proofs (RFC 9449) so they
+	// cannot be replayed. Defaults to in-memory.
+	DPoPReplayStore DPoPReplayStore
+	// DPoPNonceKey, when set, makes DPoP proofs carry a server-provided
+	// nonce derived from it. Every instance must share the key.
+	DPoPNonceKey []byte
+	// RetainedSigningKeys is how many retired JWT signing keys remain trusted
+	// after RotateSigningKey. Defaults to DefaultRetainedSigningKeys.
+	RetainedSigningKeys int
+	// SigningKeyStore shares the JWT signing keys between instances and
+	// restarts once LoadSigningKeys has run. Without it every instance signs
+	// with its own key, generated at startup. SigningKeyEncryptionKey is the
+	// AES key (16, 24 or 32 bytes) sealing the stored private keys; they are
+	// stored in plaintext when it is empty.
+	SigningKeyStore         SigningKeyStore
+	SigningKeyEncryptionKey []byte
+	// MFAEncryptionKey is the AES key (16, 24 or 32 bytes) protecting TOTP
+	// secrets at rest. A random key is generated when empty, which is only
+	// suitable for development since enrollments will not survive a restart.
+	MFAEncryptionKey []byte
+	// SSOEncryptionKey is the AES key govsvc encrypts SSO provider client
+	// secrets with. When empty, only plaintext secrets can be read.
+	SSOEncryptionKey []byte
 }
 
+// Scope policies for requested scopes that a client is not allowed.
+const (
+	// ScopePolicyReject fails the authorization with invalid_scope.
+	ScopePolicyReject = "reject"
+	// ScopePolicyDrop removes the disallowed scopes and grants the rest.
+	ScopePolicyDrop = "drop"
+)
+
+// Access token formats.
+const (
+	// AccessTokenFormatJWT issues access tokens as JWTs signed with the keys
+	// published at /.well-known/jwks.json, so resource servers can verify
+	// them locally.
+	AccessTokenFormatJWT = "jwt"
+	// AccessTokenFormatOpaque issues random access tokens that resource
+	// servers resolve through introspection.
+	AccessTokenFormatOpaque = "opaque"
+)
+
 // NewService creates a new auth service.
 func NewService(cfg Config) (Service, error) {
 	if cfg.BaseURL == "" {
 		return nil, errors.New("base URL is required")
 	}
+	scopePolicy := cfg.ScopePolicy
+	switch scopePolicy {
+	case "":
+		scopePolicy = ScopePolicyReject
+	case ScopePolicyReject, ScopePolicyDrop:
+	default:
+		return nil, fmt.Errorf("unknown scope policy %q", cfg.ScopePolicy)
+	}
+	accessTokenFormat := cfg.AccessTokenFormat
+	switch accessTokenFormat {
+	case "":
+		accessTokenFormat = AccessTokenFormatJWT
+	case AccessTokenFormatJWT, AccessTokenFormatOpaque:
+	default:
+		return nil, fmt.Errorf("unknown access token format %q", cfg.AccessTokenFormat)
+	}
 	if cfg.DirectoryServiceURL == "" {
 		return nil, errors.New("directory service URL is required")
 	}
@@ -149,32 +324,10 @@ func NewService(cfg Config) (Service, error) {
 		header = middleware.DefaultServiceAuthHeader
 	}
 
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
-		return nil, err
-	}
-
-	// Create self-signed certificate for SAML
-	template := x509.Certificate{
-		SerialNumber: big.NewInt(1),
-		Subject: pkix.Name{
-			CommonName: "wardseal-idp",
-		},
-		NotBefore: time.Now(),
-		NotAfter:  time.Now().Add(365 * 24 * time.Hour),
-
-		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
-		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
-		BasicConstraintsValid: true,
-	}
-	certDER, err := x509.CreateCertificate(rand.Reader, &template, &template, &privateKey.PublicKey, privateKey)
-	if err != nil {
-		return nil, err
-	}
-	cert, err := x509.ParseCertificate(certDER)
-	if err != nil {
-		return nil, err
+	if cfg.SAMLCertificate == nil || cfg.SAMLPrivateKey == nil {
+		return nil, errors.New("SAML certificate and private key are required")
 	}
+	cert, privateKey := cfg.SAMLCertificate, cfg.SAMLPrivateKey
 
 	samlProvider, err := saml.NewProvider(saml.Config{
 		BaseURL:     cfg.BaseURL,
@@ -187,6 +340,20 @@ func NewService(cfg Config) (Service, error) {
 		return nil, err
 	}
 
+	var samlConsumer *saml.AssertionConsumer
+	if cfg.SAMLStore != nil {
+		samlConsumer, err = saml.NewAssertionConsumer(saml.ConsumerConfig{
+			BaseURL:           cfg.BaseURL,
+			Certificate:       cert,
+			PrivateKey:        privateKey,
+			Requests:          cfg.SAMLStore,
+			IdentityProviders: cfg.SAMLStore,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// WebAuthn Init
 	w, err := webauthn.New(&webauthn.Config{
 		RPDisplayName: "WardSeal Identity",
@@ -217,38 +384,150 @@ func NewService(cfg Config) (Service, error) {
 	if cfg.RefreshStore != nil {
 		refreshStore = cfg.RefreshStore
 	}
+	var sessionStore SessionStore = newSessionMemoryStore()
+	if cfg.SessionStore != nil {
+		sessionStore = cfg.SessionStore
+	}
 	var revocationStore RevocationStore = newTokenRevocationStore()
 	if cfg.RevocationStore != nil {
 		revocationStore = cfg.RevocationStore
 	}
+	var totpStore TOTPStore = newTOTPMemoryStore()
+	if cfg.TOTPStore != nil {
+		totpStore = cfg.TOTPStore
+	}
+	var recoveryCodeStore RecoveryCodeStore = newRecoveryCodeMemoryStore()
+	if cfg.RecoveryCodeStore != nil {
+		recoveryCodeStore = cfg.RecoveryCodeStore
+	}
+	var authAuditStore AuthAuditStore = newAuthAuditMemoryStore()
+	if cfg.AuthAuditStore != nil {
+		authAuditStore = cfg.AuthAuditStore
+	}
+	var consentStore ConsentStore = newConsentMemoryStore()
+	if cfg.ConsentStore != nil {
+		consentStore = cfg.ConsentStore
+	}
+	var deviceCodeStore DeviceCodeStore = newDeviceCodeMemoryStore()
+	if cfg.DeviceCodeStore != nil {
+		deviceCodeStore = cfg.DeviceCodeStore
+	}
+	var socialStateStore SocialStateStore = newSocialStateMemoryStore()
+	if cfg.SocialStateStore != nil {
+		socialStateStore = cfg.SocialStateStore
+	}
+	var emailVerificationStore EmailVerificationStore = newEmailVerificationMemoryStore()
+	if cfg.EmailVerificationStore != nil {
+		emailVerificationStore = cfg.EmailVerificationStore
+	}
+	var passwordResetStore PasswordResetStore = newPasswordResetMemoryStore()
+	if cfg.PasswordResetStore != nil {
+		passwordResetStore = cfg.PasswordResetStore
+	}
+	var signalStore SignalStore = newSignalMemoryStore()
+	if cfg.SignalStore != nil {
+		signalStore = cfg.SignalStore
+	}
+	var impersonationStore ImpersonationStore = newImpersonationMemoryStore()
+	if cfg.ImpersonationStore != nil {
+		impersonationStore = cfg.ImpersonationStore
+	}
+	var accessTokenStore AccessTokenStore = newAccessTokenMemoryStore()
+	if cfg.AccessTokenStore != nil {
+		accessTokenStore = cfg.AccessTokenStore
+	}
+	var dpopReplayStore DPoPReplayStore = newDPoPReplayMemoryStore()
+	if cfg.DPoPReplayStore != nil {
+		dpopReplayStore = cfg.DPoPReplayStore
+	}
+	scopeCatalog := scopecatalog.DefaultCatalog()
+	if cfg.ScopeCatalog != nil {
+		scopeCatalog = cfg.ScopeCatalog
+	}
+	var mailer Mailer = logMailer{}
+	if cfg.Mailer != nil {
+		mailer = cfg.Mailer
+	}
 
-	// Generate Key ID
-	keyID := uuid.New().String()
-	// In a real system, we might derive this from the key material (JWK Thumbprint)
-	// or persist it to rotate keys gracefully.
+	mfaKey := cfg.MFAEncryptionKey
+	if len(mfaKey) == 0 {
+		mfaKey = make([]byte, 32)
+		if _, err := rand.Read(mfaKey); err != nil {
+			return nil, err
+		}
+	}
+	totpCipher, err := secretbox.New(mfaKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MFA encryption key: %w", err)
+	}
+	directory := cfg.DirectoryClient
+	if directory == nil {
+		directory = NewDirectoryClient(DirectoryClientConfig{
+			BaseURL:           cfg.DirectoryServiceURL,
+			ServiceAuthHeader: header,
+			ServiceAuthToken:  cfg.ServiceAuthToken,
+			ServiceName:       cfg.ServiceName,
+			SigningKey:        cfg.ServiceSigningKey,
+		})
+	}
+	var ssoCipher *secretbox.Cipher
+	if len(cfg.SSOEncryptionKey) > 0 {
+		if ssoCipher, err = secretbox.New(cfg.SSOEncryptionKey); err != nil {
+			return nil, fmt.Errorf("invalid SSO encryption key: %w", err)
+		}
+	}
+	signingKeys := newSigningKeySet(privateKey, cfg.RetainedSigningKeys)
+	if cfg.SigningKeyStore != nil {
+		var signingKeyCipher *secretbox.Cipher
+		if len(cfg.SigningKeyEncryptionKey) > 0 {
+			if signingKeyCipher, err = secretbox.New(cfg.SigningKeyEncryptionKey); err != nil {
+				return nil, fmt.Errorf("invalid signing key encryption key: %w", err)
+			}
+		}
+		signingKeys.useStore(cfg.SigningKeyStore, signingKeyCipher)
+	}
 
 	return &authService{
-		directoryServiceURL: cfg.DirectoryServiceURL,
-		httpClient:          &http.Client{Timeout: 5 * time.Second},
-		privateKey:          privateKey,
-		keyID:               keyID,
-		serviceAuthHeader:   header,
-		serviceAuthToken:    cfg.ServiceAuthToken,
-		codeStore:           codeStore,
-		refreshTokenStore:   refreshStore,
-		revokedTokens:       revocationStore,
-		clients:             clientMap,
-		clientStore:         cfg.ClientStore,
-		samlProvider:        samlProvider,
-		deviceStore:         cfg.DeviceStore,
-		signalStore:         cfg.SignalStore,
-		riskEngine:          NewRiskEngine(cfg.DeviceStore, cfg.SignalStore, zap.L()),
-		webAuthn:            w,
-		webAuthnStore:       cfg.WebAuthnStore,
-		federationStore:     cfg.FederationStore,
-		totpStore:           cfg.TOTPStore,
-		brandingStore:       cfg.BrandingStore,
-		ssoProviderStore:    cfg.SSOProviderStore,
+		directory:              directory,
+		signingKeys:            signingKeys,
+		serviceAuthHeader:      header,
+		serviceAuthToken:       cfg.ServiceAuthToken,
+		codeStore:              codeStore,
+		refreshTokenStore:      refreshStore,
+		sessionStore:           sessionStore,
+		revokedTokens:          revocationStore,
+		clients:                clientMap,
+		clientStore:            cfg.ClientStore,
+		samlProvider:           samlProvider,
+		samlConsumer:           samlConsumer,
+		deviceStore:            cfg.DeviceStore,
+		signalStore:            signalStore,
+		riskEngine:             NewRiskEngine(cfg.DeviceStore, signalStore, zap.L()),
+		webAuthn:               w,
+		webAuthnStore:          cfg.WebAuthnStore,
+		federationStore:        cfg.FederationStore,
+		totpStore:              totpStore,
+		totpCipher:             totpCipher,
+		recoveryCodeStore:      recoveryCodeStore,
+		brandingStore:          cfg.BrandingStore,
+		ssoProviderStore:       cfg.SSOProviderStore,
+		ssoCipher:              ssoCipher,
+		socialStateStore:       socialStateStore,
+		authAuditStore:         authAuditStore,
+		consentStore:           consentStore,
+		scopePolicy:            scopePolicy,
+		scopeCatalog:           scopeCatalog,
+		accessTokenFormat:      accessTokenFormat,
+		accessTokenStore:       accessTokenStore,
+		dpopReplayStore:        dpopReplayStore,
+		dpopNonceKey:           cfg.DPoPNonceKey,
+		deviceCodeStore:        deviceCodeStore,
+		baseURL:                strings.TrimSuffix(cfg.BaseURL, "/"),
+		emailVerificationStore: emailVerificationStore,
+		passwordResetStore:     passwordResetStore,
+		mailer:                 mailer,
+		impersonationStore:     impersonationStore,
+		permissions:            cfg.Permissions,
 	}, nil
 }
 
@@ -257,49 +536,18 @@ func (s *authService) Login(ctx context.Context, username, password, deviceID, u
 	if err != nil {
 		return "", err
 	}
-	payload := struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
-	}{Email: username, Password: password}
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return "", err
-	}
 	// 1. Ask the directory service to verify the credentials.
-	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/internal/credentials/verify", s.directoryServiceURL), bytes.NewReader(body))
+	user, err := s.directory.VerifyCredentials(ctx, tenantID, username, password)
 	if err != nil {
 		return "", err
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set(middleware.DefaultTenantHeader, tenantID)
-	if s.serviceAuthToken != "" {
-		req.Header.Set(s.serviceAuthHeader, s.serviceAuthToken)
-	}
-	resp, err := s.httpClient.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer func() { _ = resp.Body.Close() }()
-
-	if resp.StatusCode == http.StatusUnauthorized {
-		return "", ErrInvalidCredentials
-	}
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("directory service returned status %d", resp.StatusCode)
-	}
 
-	var userResp struct {
-		User struct {
-			ID    string `json:"id"`
-			Email string `json:"email"`
-		} `json:"user"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&userResp); err != nil {
+	if err := s.checkEmailVerified(ctx, tenantID, user.ID); err != nil {
 		return "", err
 	}
 
 	// 2. Risk Evaluation
-	risk, err := s.riskEngine.Evaluate(ctx, userResp.User.ID, deviceID, ip)
+	risk, err := s.riskEngine.Evaluate(ctx, user.ID, deviceID, ip)
 	if err != nil {
 		// Log error but maybe fail open or closed?
 		// Let's fail open (allow) but log error for MVP, or treat as medium risk.
@@ -307,7 +555,7 @@ func (s *authService) Login(ctx context.Context, username, password, deviceID, u
 	} else {
 		if risk.Level == RiskLevelHigh {
 			zap.L().Warn("Login blocked due to high risk",
-				zap.String("user_id", userResp.User.ID),
+				zap.String("user_id", user.ID),
 				zap.Int("score", risk.Score),
 				zap.Strings("factors", risk.Factors))
 			return "", &Error{"access_denied", "login blocked due to security risk"}
@@ -324,7 +572,7 @@ func (s *authService) Login(ctx context.Context, username, password, deviceID, u
 		}
 		if err := s.deviceStore.Register(ctx, &Device{
 			TenantID:         tenantID,
-			UserID:           userResp.User.ID,
+			UserID:           user.ID,
 			DeviceIdentifier: deviceID,
 			OS:               osName,
 			OSVersion:        osVersion,
@@ -337,8 +585,14 @@ func (s *authService) Login(ctx context.Context, username, password, deviceID, u
 	}
 
 	// 4. Generate a JWT.
+	return s.generateUserToken(tenantID, user.ID)
+}
+
+// generateUserToken signs the session token handed to an interactively
+// authenticated user.
+func (s *authService) generateUserToken(tenantID, userID string) (string, error) {
 	claims := jwt.MapClaims{
-		"sub":    userResp.User.ID,
+		"sub":    userID,
 		"iss":    "identity-platform",
 		"aud":    "client-app",
 		"exp":    time.Now().Add(time.Hour * 1).Unix(),
@@ -347,15 +601,7 @@ func (s *authService) Login(ctx context.Context, username, password, deviceID, u
 		"tenant": tenantID,
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-	token.Header["kid"] = s.keyID
-
-	signedToken, err := token.SignedString(s.privateKey)
-	if err != nil {
-		return "", err
-	}
-
-	return signedToken, nil
+	return s.signingKeys.sign(claims)
 }
 
 func parseUserAgent(ua string) (string, string) {
@@ -411,79 +657,27 @@ func parseUserAgent(ua string) (string, string) {
 func (s *authService) LookupUser(ctx context.Context, tenantID, email string) (LookupResult, error) {
 	// 0. Tenant Discovery (if not provided)
 	if tenantID == "" {
-		req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/internal/discover", s.directoryServiceURL), nil)
-		if err != nil {
-			return LookupResult{}, err
-		}
-		q := req.URL.Query()
-		q.Add("email", email)
-		req.URL.RawQuery = q.Encode()
-
-		if s.serviceAuthToken != "" {
-			req.Header.Set(s.serviceAuthHeader, s.serviceAuthToken)
+		discovered, err := s.directory.DiscoverTenant(ctx, email)
+		if errors.Is(err, ErrDirectoryUserNotFound) {
+			return LookupResult{}, errors.New("user not found (or tenant could not be discovered)")
 		}
-
-		resp, err := s.httpClient.Do(req)
 		if err != nil {
 			return LookupResult{}, fmt.Errorf("failed to discover tenant: %w", err)
 		}
-		defer func() { _ = resp.Body.Close() }()
-
-		if resp.StatusCode == http.StatusNotFound {
-			return LookupResult{}, errors.New("user not found (or tenant could not be discovered)")
-		}
-		if resp.StatusCode != http.StatusOK {
-			return LookupResult{}, fmt.Errorf("directory discovery returned status %d", resp.StatusCode)
-		}
-
-		var discoveryResp struct {
-			TenantID string `json:"tenant_id"`
-		}
-		if err := json.NewDecoder(resp.Body).Decode(&discoveryResp); err != nil {
-			return LookupResult{}, fmt.Errorf("failed to decode discovery response: %w", err)
-		}
-		tenantID = discoveryResp.TenantID
+		tenantID = discovered
 	}
 
 	// 1. Call Directory Service to resolve email to UserID
-	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/users", s.directoryServiceURL), nil)
-	if err != nil {
-		return LookupResult{}, err
-	}
-	q := req.URL.Query()
-	q.Add("email", email)
-	req.URL.RawQuery = q.Encode()
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set(middleware.DefaultTenantHeader, tenantID)
-	if s.serviceAuthToken != "" {
-		req.Header.Set(s.serviceAuthHeader, s.serviceAuthToken)
-	}
-
-	resp, err := s.httpClient.Do(req)
+	user, err := s.directory.FindUserByEmail(ctx, tenantID, email)
 	if err != nil {
 		return LookupResult{}, err
 	}
-	defer func() { _ = resp.Body.Close() }()
-
-	if resp.StatusCode == http.StatusNotFound {
+	if user == nil {
 		return LookupResult{}, errors.New("user not found")
 	}
-	if resp.StatusCode != http.StatusOK {
-		return LookupResult{}, fmt.Errorf("directory service returned status %d", resp.StatusCode)
-	}
-
-	var userResp struct {
-		User struct {
-			ID string `json:"id"`
-		} `json:"user"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&userResp); err != nil {
-		return LookupResult{}, err
-	}
 
 	// 2. Check if WebAuthn credentials exist
-	creds, err := s.webAuthnStore.ListCredentials(ctx, userResp.User.ID)
+	creds, err := s.webAuthnStore.ListCredentials(ctx, user.ID)
 	if err != nil {
 		// Log error but assume false? Or fail?
 		zap.L().Warn("Failed to list webauthn credentials during lookup", zap.Error(err))
@@ -491,67 +685,142 @@ func (s *authService) LookupUser(ctx context.Context, tenantID, email string) (L
 	}
 
 	return LookupResult{
-		UserID:          userResp.User.ID,
+		UserID:          user.ID,
 		WebAuthnEnabled: len(creds) > 0,
 		TenantID:        tenantID, // Return the discovered tenant ID
 	}, nil
 }
 
-// JWKS returns the JSON Web Key Set.
+// JWKS returns the JSON Web Key Set, including retired keys that are still trusted.
 func (s *authService) JWKS() jose.JSONWebKeySet {
-	return jose.JSONWebKeySet{
-		Keys: []jose.JSONWebKey{
-			{
-				Key:       &s.privateKey.PublicKey,
-				KeyID:     s.keyID,
-				Algorithm: "RS256",
-				Use:       "sig",
-			},
-		},
+	return s.signingKeys.jwks()
+}
+
+// RotateSigningKey generates a new JWT signing key and makes it active. The
+// previous key is retired but stays published and trusted for verification.
+// With a SigningKeyStore the new key is published first and signs once every
+// instance has loaded it.
+func (s *authService) RotateSigningKey(ctx context.Context) (string, error) {
+	return s.signingKeys.rotate(ctx)
+}
+
+func (s *authService) LoadSigningKeys(ctx context.Context) error {
+	if s.signingKeys.store == nil {
+		return nil
 	}
+	return s.signingKeys.load(ctx)
+}
+
+func (s *authService) RunSigningKeyRotation(ctx context.Context, every time.Duration) {
+	s.signingKeys.run(ctx, every)
 }
 
 func (s *authService) Authorize(ctx context.Context, req AuthorizeRequest) (AuthorizeResponse, error) {
-	tenantID, err := middleware.TenantIDFromContext(ctx)
+	tenantID, client, err := s.validateAuthorizeRequest(ctx, &req)
 	if err != nil {
 		return AuthorizeResponse{}, err
 	}
+	// Third-party clients need the signed-in user's consent to the requested scopes.
+	if !client.FirstParty {
+		subject, err := s.sessionSubject(ctx, tenantID, req.SessionToken)
+		if err != nil {
+			return AuthorizeResponse{}, err
+		}
+		granted, err := s.consentStore.GetConsent(ctx, tenantID, subject, client.ID)
+		if err != nil {
+			return AuthorizeResponse{}, err
+		}
+		if !scopesGranted(strings.Fields(req.Scope), granted) {
+			return AuthorizeResponse{ConsentRequired: true}, nil
+		}
+	}
+	return s.issueAuthorizationCode(ctx, tenantID, req)
+}
+
+// validateAuthorizeRequest checks the client, redirect URI, scopes and PKCE
+// parameters of an authorization request. req.Scope is narrowed to the scopes
+// the client may be granted according to the scope policy.
+func (s *authService) validateAuthorizeRequest(ctx context.Context, req *AuthorizeRequest) (string, ClientConfig, error) {
+	tenantID, err := middleware.TenantIDFromContext(ctx)
+	if err != nil {
+		return "", ClientConfig{}, err
+	}
 	client, err := s.resolveClient(ctx, tenantID, req.ClientID)
 	if err != nil {
-		return AuthorizeResponse{}, err
+		return "", ClientConfig{}, err
 	}
 	if client.TenantID != tenantID {
-		return AuthorizeResponse{}, ErrInvalidClient
+		return "", ClientConfig{}, ErrInvalidClient
 	}
 	if !client.allowsRedirect(req.RedirectURI) {
-		return AuthorizeResponse{}, ErrInvalidRedirectURI
+		return "", ClientConfig{}, ErrInvalidRedirectURI
 	}
-	if err := client.validateScopes(req.Scope); err != nil {
-		return AuthorizeResponse{}, newInvalidScopeError(err.Error())
+	scope, err := s.grantableScope(ctx, tenantID, client, req.Scope)
+	if err != nil {
+		return "", ClientConfig{}, err
+	}
+	req.Scope = scope
+	if req.Resource != "" && !client.allowsResource(req.Resource) {
+		return "", ClientConfig{}, ErrInvalidResource
 	}
 	if req.CodeChallenge == "" {
-		return AuthorizeResponse{}, ErrMissingCodeChallenge
+		return "", ClientConfig{}, ErrMissingCodeChallenge
+	}
+	if req.CodeChallengeMethod != "" && req.CodeChallengeMethod != "S256" {
+		return "", ClientConfig{}, ErrInvalidCodeChallengeMethod
+	}
+	return tenantID, client, nil
+}
+
+// grantableScope applies the scope policy to the requested scopes, returning
+// the space-separated scopes the client may be granted. Scopes missing from
+// the tenant's scope catalog are treated as not allowed.
+func (s *authService) grantableScope(ctx context.Context, tenantID string, client ClientConfig, requested string) (string, error) {
+	granted, disallowed := client.filterScopes(requested)
+	catalog, err := s.scopeCatalog.List(ctx, tenantID)
+	if err != nil {
+		return "", err
 	}
+	granted, uncatalogued := cataloguedScopes(granted, catalog)
+	disallowed = append(disallowed, uncatalogued...)
+	if len(disallowed) > 0 && s.scopePolicy != ScopePolicyDrop {
+		return "", newInvalidScopeError(fmt.Sprintf("scope %s is not allowed", strings.Join(disallowed, " ")))
+	}
+	if len(granted) == 0 {
+		return "", newInvalidScopeError("none of the requested scopes are allowed")
+	}
+	return strings.Join(granted, " "), nil
+}
+
+// issueAuthorizationCode stores a new authorization code for an already
+// validated request and returns the redirect carrying it.
+func (s *authService) issueAuthorizationCode(ctx context.Context, tenantID string, req AuthorizeRequest) (AuthorizeResponse, error) {
 	method := req.CodeChallengeMethod
 	if method == "" {
 		method = "S256"
 	}
-	if method != "S256" {
-		return AuthorizeResponse{}, ErrInvalidCodeChallengeMethod
-	}
 	code, err := generateAuthorizationCode()
 	if err != nil {
 		return AuthorizeResponse{}, err
 	}
 	expiresAt := time.Now().Add(5 * time.Minute)
+	// The signed-in user, when known, owns the session the code starts.
+	var subject string
+	if req.SessionToken != "" {
+		if subject, err = s.sessionSubject(ctx, tenantID, req.SessionToken); errors.Is(err, ErrImpersonationSession) {
+			return AuthorizeResponse{}, err
+		}
+	}
 	entry := authorizationCode{
 		Code:                code,
 		ClientID:            req.ClientID,
+		Subject:             subject,
 		RedirectURI:         req.RedirectURI,
 		Scope:               req.Scope,
 		TenantID:            tenantID,
 		CodeChallenge:       req.CodeChallenge,
 		CodeChallengeMethod: method,
+		Resource:            req.Resource,
 		ExpiresAt:           expiresAt,
 	}
 	_ = s.codeStore.Save(ctx, entry)
@@ -568,6 +837,13 @@ func (s *authService) Token(ctx context.Context, req TokenRequest) (TokenRespons
 		return TokenResponse{}, err
 	}
 
+	// A DPoP proof binds the issued tokens to the client's key.
+	if req.DPoPProof != "" {
+		if req.dpopJKT, err = s.verifyDPoPProof(ctx, req.DPoPProof, http.MethodPost, s.baseURL+"/oauth2/token", ""); err != nil {
+			return TokenResponse{}, err
+		}
+	}
+
 	switch req.GrantType {
 	case "authorization_code":
 		return s.handleAuthorizationCodeGrant(ctx, tenantID, req)
@@ -575,6 +851,10 @@ func (s *authService) Token(ctx context.Context, req TokenRequest) (TokenRespons
 		return s.handleClientCredentialsGrant(ctx, tenantID, req)
 	case "refresh_token":
 		return s.handleRefreshTokenGrant(ctx, tenantID, req)
+	case DeviceCodeGrantType:
+		return s.handleDeviceCodeGrant(ctx, tenantID, req)
+	case TokenExchangeGrantType:
+		return s.handleTokenExchangeGrant(ctx, tenantID, req)
 	default:
 		return TokenResponse{}, ErrUnsupportedGrantType
 	}
@@ -605,9 +885,14 @@ func (s *authService) handleAuthorizationCodeGrant(ctx context.Context, tenantID
 	if err := verifyCodeChallenge(code.CodeChallenge, code.CodeChallengeMethod, req.CodeVerifier); err != nil {
 		return TokenResponse{}, err
 	}
+	resource, err := tokenResource(client, code.Resource, req.Resource)
+	if err != nil {
+		return TokenResponse{}, err
+	}
 	_ = s.codeStore.Delete(ctx, req.Code)
 
-	return s.issueTokens(ctx, tenantID, req.ClientID, code.Scope, "user")
+	return s.issueTokens(ctx, tenantID, req.ClientID, code.Scope, "user", tokenConfirmation(client, req), resource,
+		Session{Subject: code.Subject, UserAgent: req.UserAgent, IPAddress: req.ClientIP})
 }
 
 func (s *authService) handleClientCredentialsGrant(ctx context.Context, tenantID string, req TokenRequest) (TokenResponse, error) {
@@ -620,31 +905,8 @@ func (s *authService) handleClientCredentialsGrant(ctx context.Context, tenantID
 		return TokenResponse{}, err
 	}
 
-	// Client credentials flow requires a confidential client
-	if client.ClientType != "confidential" {
-		return TokenResponse{}, &Error{"unauthorized_client", "client_credentials grant requires a confidential client"}
-	}
-
-	// Validate client secret
-	if req.ClientSecret == "" {
-		return TokenResponse{}, &Error{"invalid_request", "client_secret is required for confidential clients"}
-	}
-
-	// Get secret hash from store and verify
-	if s.clientStore != nil {
-		record, err := s.clientStore.GetClient(ctx, tenantID, req.ClientID)
-		if err != nil {
-			return TokenResponse{}, ErrInvalidClient
-		}
-		if len(record.ClientSecretHash) == 0 {
-			return TokenResponse{}, &Error{"invalid_client", "client has no secret configured"}
-		}
-		if err := verifyClientSecret(req.ClientSecret, record.ClientSecretHash); err != nil {
-			return TokenResponse{}, &Error{"invalid_client", "invalid client secret"}
-		}
-	} else {
-		// Static clients don't have secrets in this implementation
-		return TokenResponse{}, &Error{"invalid_client", "client_credentials requires database-backed clients"}
+	if err := s.authenticateConfidentialClient(ctx, tenantID, client, req.ClientSecret, req.GrantType); err != nil {
+		return TokenResponse{}, err
 	}
 
 	// Determine scopes - use requested or default to client's allowed scopes
@@ -657,20 +919,51 @@ func (s *authService) handleClientCredentialsGrant(ctx context.Context, tenantID
 		}
 	}
 
+	resource, err := tokenResource(client, "", req.Resource)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
 	// Issue access token only (no refresh token for client_credentials per RFC 6749)
-	accessToken, err := s.generateAccessToken(tenantID, req.ClientID, scope, "client")
+	cnf := tokenConfirmation(client, req)
+	accessToken, err := s.generateAccessToken(ctx, tenantID, req.ClientID, scope, "client", cnf, resource)
 	if err != nil {
 		return TokenResponse{}, err
 	}
 
 	return TokenResponse{
 		AccessToken: accessToken,
-		TokenType:   "Bearer",
+		TokenType:   cnf.tokenType(),
 		ExpiresIn:   3600,
 		Scope:       scope,
 	}, nil
 }
 
+// authenticateConfidentialClient checks that the client is confidential and
+// that secret matches its stored hash. grant names the grant in error messages.
+func (s *authService) authenticateConfidentialClient(ctx context.Context, tenantID string, client ClientConfig, secret, grant string) error {
+	if client.ClientType != "confidential" {
+		return &Error{"unauthorized_client", grant + " grant requires a confidential client"}
+	}
+	if secret == "" {
+		return &Error{"invalid_request", "client_secret is required for confidential clients"}
+	}
+	// Static clients don't have secrets in this implementation
+	if s.clientStore == nil {
+		return &Error{"invalid_client", grant + " requires database-backed clients"}
+	}
+	switch err := s.clientStore.ValidateSecret(ctx, tenantID, client.ID, secret); {
+	case err == nil:
+		return nil
+	case errors.Is(err, oauthclient.ErrNoSecret):
+		return &Error{"invalid_client", "client has no secret configured"}
+	case errors.Is(err, oauthclient.ErrInvalidSecret):
+		return &Error{"invalid_client", "invalid client secret"}
+	default:
+		return ErrInvalidClient
+	}
+}
+
 func (s *authService) handleRefreshTokenGrant(ctx context.Context, tenantID string, req TokenRequest) (TokenResponse, error) {
 	if req.RefreshToken == "" {
 		return TokenResponse{}, &Error{"invalid_request", "refresh_token is required"}
@@ -699,51 +992,190 @@ func (s *authService) handleRefreshTokenGrant(ctx context.Context, tenantID stri
 		return TokenResponse{}, &Error{"invalid_grant", "refresh token tenant mismatch"}
 	}
 
+	// A DPoP-bound refresh token is only redeemed with a proof for its key.
+	if stored.DPoPJKT != "" && stored.DPoPJKT != req.dpopJKT {
+		return TokenResponse{}, &Error{"invalid_grant", "refresh token is bound to a different DPoP key"}
+	}
+
+	// Re-bind to whoever redeems the refresh token. Tokens from internal
+	// pseudo-clients (e.g. social login) have no registration and are only
+	// bound to a DPoP key.
+	cnf := confirmation{JKT: req.dpopJKT}
+	client, err := s.resolveClient(ctx, tenantID, stored.ClientID)
+	if err == nil {
+		cnf = tokenConfirmation(client, req)
+	}
+	resource, err := tokenResource(client, stored.Resource, req.Resource)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
 	// Rotate refresh token - delete old and issue new
 	_ = s.refreshTokenStore.Delete(ctx, req.RefreshToken)
 
-	return s.issueTokens(ctx, tenantID, stored.ClientID, stored.Scope, stored.SubjectType)
+	return s.issueTokens(ctx, tenantID, stored.ClientID, stored.Scope, stored.SubjectType, cnf, resource,
+		Session{ID: stored.FamilyID, UserAgent: req.UserAgent, IPAddress: req.ClientIP})
+}
+
+// tokenResource decides the resource an access token is issued for. granted
+// is the resource the code or refresh token being redeemed was issued for, if
+// any. A requested resource must match it or, when there is none, be allowed
+// for the client.
+func tokenResource(client ClientConfig, granted, requested string) (string, error) {
+	switch {
+	case requested == "":
+		return granted, nil
+	case granted != "":
+		if requested != granted {
+			return "", ErrInvalidResource
+		}
+		return granted, nil
+	case !client.allowsResource(requested):
+		return "", ErrInvalidResource
+	}
+	return requested, nil
 }
 
-func (s *authService) issueTokens(ctx context.Context, tenantID, clientID, scope, subjectType string) (TokenResponse, error) {
-	accessToken, err := s.generateAccessToken(tenantID, clientID, scope, subjectType)
+// issueTokens issues an access and refresh token, plus an ID token when the
+// openid scope was granted. A non-empty resource is the access token's
+// audience and, like a DPoP key binding, is kept with the refresh token.
+// Refresh tokens rotated from one another share a family ID, which is also
+// the ID token's sid and the ID of the Session tracking them. A session
+// without an ID starts a new family; otherwise the session is marked as seen.
+func (s *authService) issueTokens(ctx context.Context, tenantID, clientID, scope, subjectType string, cnf confirmation, resource string, session Session) (TokenResponse, error) {
+	if err := s.trackSession(ctx, tenantID, clientID, &session); err != nil {
+		return TokenResponse{}, err
+	}
+	familyID := session.ID
+	accessToken, err := s.generateAccessToken(ctx, tenantID, clientID, scope, subjectType, cnf, resource)
 	if err != nil {
 		return TokenResponse{}, err
 	}
 
-	refreshToken, err := s.generateRefreshToken(ctx, tenantID, clientID, scope, subjectType)
+	refreshToken, err := s.generateRefreshToken(ctx, tenantID, clientID, scope, subjectType, familyID, resource, cnf.JKT)
 	if err != nil {
 		return TokenResponse{}, err
 	}
 
+	var idToken string
+	if containsScope(strings.Fields(scope), "openid") {
+		if idToken, err = s.generateIDToken(tenantID, clientID, familyID); err != nil {
+			return TokenResponse{}, err
+		}
+	}
+
 	return TokenResponse{
 		AccessToken:  accessToken,
-		TokenType:    "Bearer",
+		TokenType:    cnf.tokenType(),
 		ExpiresIn:    3600,
 		RefreshToken: refreshToken,
+		IDToken:      idToken,
 		Scope:        scope,
 	}, nil
 }
 
-func (s *authService) generateAccessToken(tenantID, clientID, scope, subjectType string) (string, error) {
+// generateIDToken signs an OIDC ID token whose sid names the refresh token family.
+func (s *authService) generateIDToken(tenantID, clientID, familyID string) (string, error) {
+	return s.signingKeys.sign(jwt.MapClaims{
+		"sub":    clientID,
+		"iss":    "identity-platform",
+		"aud":    clientID,
+		"exp":    time.Now().Add(time.Hour).Unix(),
+		"iat":    time.Now().Unix(),
+		"tenant": tenantID,
+		"sid":    familyID,
+	})
+}
+
+// generateAccessToken issues an access token in the configured format. cnf
+// is embedded as the "cnf" claim checked at introspection, and a non-empty
+// resource replaces the default audience.
+func (s *authService) generateAccessToken(ctx context.Context, tenantID, clientID, scope, subjectType string, cnf confirmation, resource string) (string, error) {
+	return s.issueAccessToken(ctx, accessTokenClaims(tenantID, clientID, scope, subjectType, cnf, resource))
+}
+
+// accessTokenClaims returns the claims of a newly issued access token.
+func accessTokenClaims(tenantID, clientID, scope, subjectType string, cnf confirmation, resource string) jwt.MapClaims {
+	audience := "client-app"
+	if resource != "" {
+		audience = resource
+	}
 	claims := jwt.MapClaims{
 		"sub":          clientID,
 		"iss":          "identity-platform",
-		"aud":          "client-app",
+		"aud":          audience,
 		"exp":          time.Now().Add(time.Hour * 1).Unix(),
 		"iat":          time.Now().Unix(),
 		"scope":        scope,
 		"tenant":       tenantID,
 		"subject_type": subjectType,
 	}
+	if claim := cnf.claim(); claim != nil {
+		claims["cnf"] = claim
+	}
+	return claims
+}
+
+// issueAccessToken signs claims as a JWT or, with the opaque format, stores
+// them under a random token.
+func (s *authService) issueAccessToken(ctx context.Context, claims jwt.MapClaims) (string, error) {
+	if s.accessTokenFormat != AccessTokenFormatOpaque {
+		return s.signingKeys.sign(claims)
+	}
+
+	encoded, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	// Read the expiry back from the encoded claims, as introspection will.
+	var stored jwt.MapClaims
+	if err := json.Unmarshal(encoded, &stored); err != nil {
+		return "", err
+	}
+	exp, err := stored.GetExpirationTime()
+	if err != nil || exp == nil {
+		return "", errors.New("access token claims have no expiry")
+	}
+	tenantID, _ := claims["tenant"].(string)
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(tokenBytes)
+	if err := s.accessTokenStore.Save(ctx, opaqueAccessToken{
+		TokenHash: hashToken(token),
+		TenantID:  tenantID,
+		Claims:    encoded,
+		ExpiresAt: exp.Time,
+	}); err != nil {
+		return "", err
+	}
+	return token, nil
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-	token.Header["kid"] = s.keyID
+// resolveAccessToken returns the claims of a valid access token in either
+// format, reporting false for unknown, invalid or expired tokens.
+func (s *authService) resolveAccessToken(ctx context.Context, token string) (jwt.MapClaims, bool, error) {
+	if claims, err := s.parseSignedToken(token); err == nil {
+		return claims, true, nil
+	}
 
-	return token.SignedString(s.privateKey)
+	stored, found, err := s.accessTokenStore.Get(ctx, hashToken(token))
+	if err != nil || !found || !time.Now().Before(stored.ExpiresAt) {
+		return nil, false, err
+	}
+	var claims jwt.MapClaims
+	if err := json.Unmarshal(stored.Claims, &claims); err != nil {
+		return nil, false, err
+	}
+	return claims, true, nil
 }
 
-func (s *authService) generateRefreshToken(ctx context.Context, tenantID, clientID, scope, subjectType string) (string, error) {
+// refreshTokenLifetime is how long a refresh token can be redeemed, and so how
+// long a session stays active without being used.
+const refreshTokenLifetime = 7 * 24 * time.Hour
+
+func (s *authService) generateRefreshToken(ctx context.Context, tenantID, clientID, scope, subjectType, familyID, resource, dpopJKT string) (string, error) {
 	tokenBytes := make([]byte, 32)
 	if _, err := rand.Read(tokenBytes); err != nil {
 		return "", err
@@ -757,7 +1189,10 @@ func (s *authService) generateRefreshToken(ctx context.Context, tenantID, client
 		TenantID:    tenantID,
 		Scope:       scope,
 		SubjectType: subjectType,
-		ExpiresAt:   time.Now().Add(7 * 24 * time.Hour),
+		FamilyID:    familyID,
+		Resource:    resource,
+		DPoPJKT:     dpopJKT,
+		ExpiresAt:   time.Now().Add(refreshTokenLifetime),
 	})
 	if err != nil {
 		return "", err
@@ -767,6 +1202,12 @@ func (s *authService) generateRefreshToken(ctx context.Context, tenantID, client
 }
 
 func (s *authService) Introspect(ctx context.Context, req IntrospectRequest) (IntrospectResponse, error) {
+	// Callers only learn about tokens of their own tenant.
+	callerTenant, err := middleware.TenantIDFromContext(ctx)
+	if err != nil {
+		return IntrospectResponse{}, err
+	}
+
 	// Check if token is revoked
 	revoked, err := s.revokedTokens.IsRevoked(ctx, req.Token)
 	if err != nil {
@@ -776,42 +1217,69 @@ func (s *authService) Introspect(ctx context.Context, req IntrospectRequest) (In
 		return IntrospectResponse{Active: false}, nil
 	}
 
-	// Try to parse as JWT (access token)
-	token, err := jwt.Parse(req.Token, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return &s.privateKey.PublicKey, nil
-	})
+	// Try the token as an access token, JWT or opaque.
+	claims, found, err := s.resolveAccessToken(ctx, req.Token)
+	if err != nil {
+		return IntrospectResponse{}, err
+	}
 
-	if err != nil || !token.Valid {
+	if !found {
 		// Not a valid access token, check if it's a refresh token
 		stored, found, getErr := s.refreshTokenStore.Get(ctx, req.Token)
-		if getErr == nil && found && time.Now().Before(stored.ExpiresAt) {
-			return IntrospectResponse{
+		if getErr == nil && found && time.Now().Before(stored.ExpiresAt) && stored.TenantID == callerTenant {
+			resp := IntrospectResponse{
 				Active:    true,
 				Scope:     stored.Scope,
 				ClientID:  stored.ClientID,
 				TokenType: "refresh_token",
 				Exp:       stored.ExpiresAt.Unix(),
 				TenantID:  stored.TenantID,
-			}, nil
+			}
+			if stored.DPoPJKT != "" {
+				resp.Cnf = map[string]string{"jkt": stored.DPoPJKT}
+			}
+			return resp, nil
 		}
 		return IntrospectResponse{Active: false}, nil
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return IntrospectResponse{Active: false}, nil
-	}
-
 	exp, _ := claims["exp"].(float64)
 	iat, _ := claims["iat"].(float64)
 	sub, _ := claims["sub"].(string)
 	scope, _ := claims["scope"].(string)
 	tenant, _ := claims["tenant"].(string)
-	aud, _ := claims["aud"].(string)
 	iss, _ := claims["iss"].(string)
+	act, _ := claims["act"].(map[string]interface{})
+
+	if tenant != callerTenant {
+		return IntrospectResponse{Active: false}, nil
+	}
+	// A token scoped to one resource server is not valid at another.
+	if req.Resource != "" {
+		aud, _ := claims.GetAudience()
+		if !slices.Contains(aud, req.Resource) {
+			return IntrospectResponse{Active: false}, nil
+		}
+	}
+
+	if err := verifyTokenBinding(claims, req.PresenterIP, req.PresenterUserAgent); err != nil {
+		return IntrospectResponse{}, err
+	}
+	// DPoP-bound tokens are checked against a proof when the caller sends
+	// one; otherwise the resource server checks its proof against cnf.jkt.
+	var cnf map[string]string
+	if jkt := dpopThumbprint(claims); jkt != "" {
+		if req.DPoPProof != "" {
+			proofJKT, err := s.verifyDPoPProof(ctx, req.DPoPProof, http.MethodPost, s.baseURL+"/oauth2/introspect", req.Token)
+			if err != nil {
+				return IntrospectResponse{}, err
+			}
+			if proofJKT != jkt {
+				return IntrospectResponse{}, ErrDPoPKeyMismatch
+			}
+		}
+		cnf = map[string]string{"jkt": jkt}
+	}
 
 	// Check for CAE (Critical Access Evaluation)
 	// If the token is valid, we check if any revocation events occurred AFTER the token was issued (iat).
@@ -839,9 +1307,11 @@ func (s *authService) Introspect(ctx context.Context, req IntrospectRequest) (In
 		Exp:       int64(exp),
 		Iat:       int64(iat),
 		Sub:       sub,
-		Aud:       aud,
+		Aud:       claims["aud"],
 		Iss:       iss,
 		TenantID:  tenant,
+		Act:       act,
+		Cnf:       cnf,
 	}, nil
 }
 
@@ -873,9 +1343,22 @@ func (s *authService) TOTP() TOTPStore {
 	return s.totpStore
 }
 
+func (s *authService) AuthAudit() AuthAuditStore {
+	return s.authAuditStore
+}
+
+// ServiceAuthHeader is the header carrying the internal service token.
+func (s *authService) ServiceAuthHeader() string {
+	return s.serviceAuthHeader
+}
+
 // ErrInvalidCredentials is returned when login fails.
 var ErrInvalidCredentials = &Error{"invalid_credentials", "invalid username or password"}
 
+// ErrTenantSelectionRequired is returned by LookupUser when the email exists
+// in several tenants and the caller must send X-Tenant-ID.
+var ErrTenantSelectionRequired = &Error{"tenant_required", "account exists in multiple tenants; specify the tenant"}
+
 const (
 	SystemTenantID  = "11111111-1111-1111-1111-111111111111"
 	AnonymousUserID = "00000000-0000-0000-0000-000000000000"
@@ -883,6 +1366,10 @@ const (
 
 var ErrMissingCodeChallenge = &Error{"invalid_request", "code_challenge is required"}
 var ErrInvalidCodeChallengeMethod = &Error{"invalid_request", "only S256 code_challenge_method is supported"}
+
+// ErrInvalidResource is returned for a resource indicator the client may not
+// request, or one that differs from the resource a grant was issued for.
+var ErrInvalidResource = &Error{"invalid_target", "resource is not allowed for this client"}
 var ErrInvalidAuthorizationCode = &Error{"invalid_grant", "authorization code is invalid or expired"}
 var ErrUnsupportedGrantType = &Error{"unsupported_grant_type", "only authorization_code grant is supported"}
 var ErrInvalidCodeVerifier = &Error{"invalid_grant", "code_verifier does not match code_challenge"}
@@ -906,12 +1393,15 @@ func newInvalidScopeError(detail string) *Error {
 type authorizationCode struct {
 	Code                string
 	ClientID            string
+	Subject             string
 	RedirectURI         string
 	Scope               string
 	TenantID            string
 	CodeChallenge       string
 	CodeChallengeMethod string
-	ExpiresAt           time.Time
+	// Resource is the resource indicator the code was issued for, if any.
+	Resource  string
+	ExpiresAt time.Time
 }
 
 type authorizationCodeStore struct {
@@ -1013,24 +1503,32 @@ func clientConfigFromRecord(record oauthclient.Client) ClientConfig {
 		clientType = "public"
 	}
 	return ClientConfig{
-		ID:            record.ClientID,
-		TenantID:      record.TenantID,
-		Name:          record.Name,
-		Description:   description,
-		ClientType:    clientType,
-		RedirectURIs:  append([]string(nil), record.RedirectURIs...),
-		AllowedScopes: append([]string(nil), record.AllowedScopes...),
+		ID:                     record.ClientID,
+		TenantID:               record.TenantID,
+		Name:                   record.Name,
+		Description:            description,
+		ClientType:             clientType,
+		RedirectURIs:           append([]string(nil), record.RedirectURIs...),
+		BindTokens:             record.BindTokens,
+		FirstParty:             record.FirstParty,
+		PostLogoutRedirectURIs: append([]string(nil), record.PostLogoutRedirectURIs...),
+		AllowedScopes:          append([]string(nil), record.AllowedScopes...),
+		AllowedResources:       append([]string(nil), record.AllowedResources...),
 	}
 }
 
 // refreshTokenEntry represents a stored refresh token.
 type refreshTokenEntry struct {
-	Token       string
-	ClientID    string
-	TenantID    string
-	Scope       string
-	SubjectType string
-	ExpiresAt   time.Time
+	Token       string `db:"token"`
+	ClientID    string `db:"client_id"`
+	TenantID    string `db:"tenant_id"`
+	Scope       string `db:"scope"`
+	SubjectType string `db:"subject_type"`
+	FamilyID    string `db:"family_id"`
+	Resource    string `db:"resource"`
+	// DPoPJKT is the thumbprint of the DPoP key the token is bound to.
+	DPoPJKT   string    `db:"dpop_jkt"`
+	ExpiresAt time.Time `db:"expires_at"`
 }
 
 // refreshTokenStore provides in-memory storage for refresh tokens.
@@ -1064,6 +1562,17 @@ func (s *refreshTokenStore) Delete(ctx context.Context, token string) error {
 	return nil
 }
 
+func (s *refreshTokenStore) DeleteFamily(ctx context.Context, familyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for token, entry := range s.tokens {
+		if entry.FamilyID == familyID {
+			delete(s.tokens, token)
+		}
+	}
+	return nil
+}
+
 // tokenRevocationStore provides in-memory storage for revoked tokens.
 type tokenRevocationStore struct {
 	mu      sync.RWMutex
@@ -1088,11 +1597,6 @@ func (s *tokenRevocationStore) IsRevoked(ctx context.Context, token string) (boo
 	return exists, nil
 }
 
-// verifyClientSecret compares a plaintext secret against a bcrypt hash.
-func verifyClientSecret(secret string, hash []byte) error {
-	return bcrypt.CompareHashAndPassword(hash, []byte(secret))
-}
-
 func (s *authService) BeginWebAuthnRegistration(ctx context.Context, userID string) (*protocol.CredentialCreation, *webauthn.SessionData, error) {
 	// 1. Fetch user (or create dummy adapter)
 	// We need Name and DisplayName. For MVP, reusing ID as name or fetching from DirSvc is hard without token.
@@ -1180,8 +1684,9 @@ func (s *authService) FinishWebAuthnLogin(ctx context.Context, userID string, se
 	_, _ = middleware.TenantIDFromContext(ctx)
 	tenantID := SystemTenantID
 
-	// Scopes? Default.
-	return s.generateAccessToken(tenantID, userID, "openid", "user")
+	// Scopes? Default. The token doubles as the login session, so it is
+	// always a JWT whatever the access token format.
+	return s.signingKeys.sign(accessTokenClaims(tenantID, userID, "openid", "user", confirmation{}, ""))
 }
 
 func (s *authService) WebAuthn() *webauthn.WebAuthn {
@@ -1191,88 +1696,18 @@ func (s *authService) SignUp(ctx context.Context, email, password, companyName s
 	// 1. Generate new Tenant ID
 	tenantID := uuid.New().String()
 
-	// 2. Create User in Directory Service
-	// We call POST /users directly on directory service URL, injecting the new Tenant ID header.
-	// Payload must match CreateUserRequest: { "user": { ... } }
-	userPayload := map[string]interface{}{
-		"user": map[string]interface{}{
-			"email":    email,
-			"password": password,
-			"status":   "active",
-		},
-	}
-	body, err := json.Marshal(userPayload)
+	// 2. Create User in Directory Service under the new Tenant ID.
+	user, err := s.directory.CreateUser(ctx, tenantID, email, password)
 	if err != nil {
 		return "", "", err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/users", s.directoryServiceURL), bytes.NewReader(body))
-	if err != nil {
-		return "", "", err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set(middleware.DefaultTenantHeader, tenantID)
-	// Directory service /users is tenant-protected but might not require auth token if internal?
-	// Based on api.go analysis, it only checks TenantID.
-	// But it's safer to send service auth if configured/needed (though api.go didn't show it for /users).
-
-	resp, err := s.httpClient.Do(req)
-	if err != nil {
-		return "", "", err
-	}
-	defer func() { _ = resp.Body.Close() }()
-
-	if resp.StatusCode != http.StatusCreated {
-		// Try to read error body
-		var errResp struct {
-			Error string `json:"error"`
-		}
-		_ = json.NewDecoder(resp.Body).Decode(&errResp)
-		return "", "", fmt.Errorf("failed to create user: status %d, error: %s", resp.StatusCode, errResp.Error)
+	if err := s.SendEmailVerification(ctx, tenantID, user.ID, email); err != nil {
+		zap.L().Warn("Failed to send email verification", zap.String("tenant_id", tenantID), zap.Error(err))
 	}
 
-	// 3. Login to get token
-	// We call our own Login logic, but we need to pass the context with the new TenantID?
-	// Login implementation derives TenantID from context using middleware.TenantIDFromContext.
-	// We can't easily inject it into context for internal call without mocking middleware?
-	// Actually, s.Login() reads context.
-	// So we create a new context with tenantID.
-
-	// Issue: middleware.TenantIDContextKey is unexported key type in middleware package.
-	// We cannot set it from here using `context.WithValue` unless middleware exports the key or a setter.
-	// Checking middleware/tenant.go... key IS unexported `type tenantContextKey string`.
-	// BUT `TenantIDFromContext` reads it.
-	// We can't inject it easily.
-
-	// ALTERNATIVE: Use `s.privateKey` to mint token directly here without calling `Login`.
-	// This is duplicate logic but cleaner than hacking context.
-
-	// Read created user ID (response from POST /users includes it)
-	var createUserResp struct {
-		UserID string `json:"user_id"`
-	}
-	// We already decoded response? No.
-	// Wait, I didn't decode success response above.
-	// Need to re-read body if I didn't close it? `defer` closes at end of func.
-	// Re-reading is fine if I decode it now.
-	if err := json.NewDecoder(resp.Body).Decode(&createUserResp); err != nil {
-		return "", "", fmt.Errorf("failed to decode create user response: %w", err)
-	}
-
-	claims := jwt.MapClaims{
-		"sub":    createUserResp.UserID,
-		"iss":    "identity-platform",
-		"aud":    "client-app",
-		"exp":    time.Now().Add(time.Hour * 1).Unix(),
-		"iat":    time.Now().Unix(),
-		"scope":  "openid profile email",
-		"tenant": tenantID,
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-	token.Header["kid"] = s.keyID
-
-	signedToken, err := token.SignedString(s.privateKey)
+	// 3. Mint the session token directly; Login needs the tenant in the context.
+	signedToken, err := s.generateUserToken(tenantID, user.ID)
 	if err != nil {
 		return "", "", err
 	}
@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/dhawalhost/wardseal/internal/scopecatalog"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// ErrLoginRequired is returned when a third-party authorization needs a signed-in user.
+	ErrLoginRequired = &Error{"login_required", "user must sign in to authorize this client"}
+	// ErrConsentScope is returned when the consent grants a scope that was not requested.
+	ErrConsentScope = &Error{"invalid_scope", "granted scopes must be a subset of the requested scopes"}
+	// ErrImpersonationSession is returned when an impersonation token is
+	// presented as a session, which would let it outlive its expiry through
+	// refresh tokens.
+	ErrImpersonationSession = &Error{"login_required", "impersonation tokens cannot start sessions"}
+)
+
+// ConsentPrompt validates an authorization request that needs consent and
+// returns what the consent screen should display.
+func (s *authService) ConsentPrompt(ctx context.Context, req AuthorizeRequest) (ConsentPrompt, error) {
+	tenantID, client, err := s.validateAuthorizeRequest(ctx, &req)
+	if err != nil {
+		return ConsentPrompt{}, err
+	}
+	if _, err := s.sessionSubject(ctx, tenantID, req.SessionToken); err != nil {
+		return ConsentPrompt{}, err
+	}
+	name := client.Name
+	if name == "" {
+		name = client.ID
+	}
+	scopes, err := s.describeScopes(ctx, tenantID, strings.Fields(req.Scope))
+	if err != nil {
+		return ConsentPrompt{}, err
+	}
+	return ConsentPrompt{ClientID: client.ID, ClientName: name, Scopes: scopes}, nil
+}
+
+// describeScopes looks up the catalog entries for scopes, in order, for the
+// consent screen. Scopes without an entry are shown by their value.
+func (s *authService) describeScopes(ctx context.Context, tenantID string, scopes []string) ([]ConsentScope, error) {
+	catalog, err := s.scopeCatalog.List(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string]scopecatalog.Scope, len(catalog))
+	for _, entry := range catalog {
+		entries[entry.ID] = entry
+	}
+	described := make([]ConsentScope, 0, len(scopes))
+	for _, scope := range scopes {
+		entry, ok := entries[scope]
+		if !ok {
+			entry = scopecatalog.Scope{ID: scope}
+		}
+		described = append(described, ConsentScope{
+			Scope:       scope,
+			DisplayName: entry.Name(),
+			Description: entry.Description,
+			Sensitive:   entry.Sensitive,
+		})
+	}
+	return described, nil
+}
+
+// Consent records the user's decision. Approved scopes are merged into the
+// stored consent and an authorization code is issued for exactly those scopes;
+// a denial redirects back to the client with access_denied.
+func (s *authService) Consent(ctx context.Context, req ConsentRequest) (AuthorizeResponse, error) {
+	tenantID, client, err := s.validateAuthorizeRequest(ctx, &req.AuthorizeRequest)
+	if err != nil {
+		return AuthorizeResponse{}, err
+	}
+	subject, err := s.sessionSubject(ctx, tenantID, req.SessionToken)
+	if err != nil {
+		return AuthorizeResponse{}, err
+	}
+
+	requested := strings.Fields(req.Scope)
+	var granted []string
+	for _, scope := range req.GrantedScopes {
+		for _, field := range strings.Fields(scope) {
+			if !containsScope(requested, field) {
+				return AuthorizeResponse{}, ErrConsentScope
+			}
+			if !containsScope(granted, field) {
+				granted = append(granted, field)
+			}
+		}
+	}
+	if req.Decision != "approve" || len(granted) == 0 {
+		redirectURI, err := buildAuthorizationErrorRedirect(req.RedirectURI, "access_denied", req.State)
+		if err != nil {
+			return AuthorizeResponse{}, err
+		}
+		return AuthorizeResponse{RedirectURI: redirectURI}, nil
+	}
+
+	existing, err := s.consentStore.GetConsent(ctx, tenantID, subject, client.ID)
+	if err != nil {
+		return AuthorizeResponse{}, err
+	}
+	merged := append([]string(nil), existing...)
+	for _, scope := range granted {
+		if !containsScope(merged, scope) {
+			merged = append(merged, scope)
+		}
+	}
+	if err := s.consentStore.SaveConsent(ctx, tenantID, subject, client.ID, merged); err != nil {
+		return AuthorizeResponse{}, err
+	}
+
+	authReq := req.AuthorizeRequest
+	authReq.Scope = strings.Join(granted, " ")
+	return s.issueAuthorizationCode(ctx, tenantID, authReq)
+}
+
+// SessionSubject implements Service.
+func (s *authService) SessionSubject(ctx context.Context, tenantID, sessionToken string) (string, error) {
+	return s.sessionSubject(ctx, tenantID, sessionToken)
+}
+
+// sessionSubject returns the user ID of a session token issued by Login for
+// the tenant. Sessions that predate a password reset are rejected.
+func (s *authService) sessionSubject(ctx context.Context, tenantID, sessionToken string) (string, error) {
+	if sessionToken == "" {
+		return "", ErrLoginRequired
+	}
+	claims, err := s.parseSignedToken(sessionToken, jwt.WithAudience("client-app"))
+	if err != nil {
+		return "", ErrLoginRequired
+	}
+	subject, _ := claims["sub"].(string)
+	tenant, _ := claims["tenant"].(string)
+	if subject == "" || tenant != tenantID || s.sessionRevoked(ctx, subject, claims) {
+		return "", ErrLoginRequired
+	}
+	if _, impersonated := claims["act"]; impersonated {
+		return "", ErrImpersonationSession
+	}
+	return subject, nil
+}
+
+// scopesGranted reports whether every requested scope has been consented to.
+func scopesGranted(requested, granted []string) bool {
+	for _, scope := range requested {
+		if !containsScope(granted, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func buildAuthorizationErrorRedirect(baseURI, errorCode, state string) (string, error) {
+	parsed, err := url.Parse(baseURI)
+	if err != nil {
+		return "", err
+	}
+	values := parsed.Query()
+	values.Set("error", errorCode)
+	if state != "" {
+		values.Set("state", state)
+	}
+	parsed.RawQuery = values.Encode()
+	return parsed.String(), nil
+}
@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+)
+
+// DeviceCodeGrantType is the token endpoint grant_type for RFC 8628 polling.
+const DeviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+const (
+	// DeviceCodeLifetime is how long a device authorization may be approved and redeemed.
+	DeviceCodeLifetime = 10 * time.Minute
+	// DefaultDevicePollInterval is the minimum number of seconds between token polls.
+	DefaultDevicePollInterval = 5
+	// userCodeAlphabet omits vowels and look-alike characters so user codes
+	// are easy to type and never spell words.
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+)
+
+var (
+	ErrAuthorizationPending = &Error{"authorization_pending", "the user has not yet approved the device"}
+	ErrSlowDown             = &Error{"slow_down", "polling too frequently; increase the interval by 5 seconds"}
+	ErrExpiredToken         = &Error{"expired_token", "the device code has expired"}
+	ErrAccessDenied         = &Error{"access_denied", "the user denied the authorization request"}
+	ErrInvalidDeviceCode    = &Error{"invalid_grant", "device code is invalid"}
+	ErrInvalidUserCode      = &Error{"invalid_request", "user code is invalid or expired"}
+)
+
+// DeviceAuthorization issues a device code and user code for a client that
+// cannot host a browser (RFC 8628 section 3.1).
+func (s *authService) DeviceAuthorization(ctx context.Context, req DeviceAuthorizationRequest) (DeviceAuthorizationResponse, error) {
+	tenantID, err := middleware.TenantIDFromContext(ctx)
+	if err != nil {
+		return DeviceAuthorizationResponse{}, err
+	}
+	client, err := s.resolveClient(ctx, tenantID, req.ClientID)
+	if err != nil {
+		return DeviceAuthorizationResponse{}, err
+	}
+	if client.TenantID != tenantID {
+		return DeviceAuthorizationResponse{}, ErrInvalidClient
+	}
+	scope, err := s.grantableScope(ctx, tenantID, client, req.Scope)
+	if err != nil {
+		return DeviceAuthorizationResponse{}, err
+	}
+
+	deviceCode, err := generateAuthorizationCode()
+	if err != nil {
+		return DeviceAuthorizationResponse{}, err
+	}
+	userCode, err := generateUserCode()
+	if err != nil {
+		return DeviceAuthorizationResponse{}, err
+	}
+	entry := deviceAuthorization{
+		DeviceCode: deviceCode,
+		UserCode:   userCode,
+		TenantID:   tenantID,
+		ClientID:   client.ID,
+		Scope:      scope,
+		Status:     DeviceCodePending,
+		Interval:   DefaultDevicePollInterval,
+		ExpiresAt:  time.Now().Add(DeviceCodeLifetime),
+	}
+	if err := s.deviceCodeStore.Save(ctx, entry); err != nil {
+		return DeviceAuthorizationResponse{}, err
+	}
+
+	verificationURI := s.baseURL + "/device"
+	return DeviceAuthorizationResponse{
+		DeviceCode:              deviceCode,
+		UserCode:                userCode,
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: verificationURI + "?user_code=" + url.QueryEscape(userCode),
+		ExpiresIn:               int(DeviceCodeLifetime.Seconds()),
+		Interval:                DefaultDevicePollInterval,
+	}, nil
+}
+
+// DeviceVerification looks up a pending user code for the signed-in user and
+// returns what the approval page should display.
+func (s *authService) DeviceVerification(ctx context.Context, userCode, sessionToken string) (ConsentPrompt, error) {
+	entry, err := s.pendingDeviceAuthorization(ctx, userCode, sessionToken)
+	if err != nil {
+		return ConsentPrompt{}, err
+	}
+	name := entry.ClientID
+	if client, err := s.resolveClient(ctx, entry.TenantID, entry.ClientID); err == nil && client.Name != "" {
+		name = client.Name
+	}
+	scopes, err := s.describeScopes(ctx, entry.TenantID, strings.Fields(entry.Scope))
+	if err != nil {
+		return ConsentPrompt{}, err
+	}
+	return ConsentPrompt{ClientID: entry.ClientID, ClientName: name, Scopes: scopes}, nil
+}
+
+// DeviceApproval records the signed-in user's decision for a user code.
+func (s *authService) DeviceApproval(ctx context.Context, req DeviceApprovalRequest) error {
+	entry, err := s.pendingDeviceAuthorization(ctx, req.UserCode, req.SessionToken)
+	if err != nil {
+		return err
+	}
+	subject, err := s.sessionSubject(ctx, entry.TenantID, req.SessionToken)
+	if err != nil {
+		return err
+	}
+	entry.Subject = subject
+	entry.Status = DeviceCodeDenied
+	if req.Decision == "approve" {
+		entry.Status = DeviceCodeApproved
+	}
+	return s.deviceCodeStore.Save(ctx, entry)
+}
+
+func (s *authService) pendingDeviceAuthorization(ctx context.Context, userCode, sessionToken string) (deviceAuthorization, error) {
+	tenantID, err := middleware.TenantIDFromContext(ctx)
+	if err != nil {
+		return deviceAuthorization{}, err
+	}
+	if _, err := s.sessionSubject(ctx, tenantID, sessionToken); err != nil {
+		return deviceAuthorization{}, err
+	}
+	entry, found, err := s.deviceCodeStore.GetByUserCode(ctx, normalizeUserCode(userCode))
+	if err != nil {
+		return deviceAuthorization{}, err
+	}
+	if !found || entry.TenantID != tenantID || entry.Status != DeviceCodePending || time.Now().After(entry.ExpiresAt) {
+		return deviceAuthorization{}, ErrInvalidUserCode
+	}
+	return entry, nil
+}
+
+// handleDeviceCodeGrant answers a device's token poll (RFC 8628 section 3.5).
+func (s *authService) handleDeviceCodeGrant(ctx context.Context, tenantID string, req TokenRequest) (TokenResponse, error) {
+	if req.ClientID == "" || req.DeviceCode == "" {
+		return TokenResponse{}, &Error{"invalid_request", "missing required parameters for device_code grant"}
+	}
+	client, err := s.resolveClient(ctx, tenantID, req.ClientID)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+	entry, found, err := s.deviceCodeStore.GetByDeviceCode(ctx, req.DeviceCode)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+	if !found || entry.TenantID != tenantID || entry.ClientID != client.ID {
+		return TokenResponse{}, ErrInvalidDeviceCode
+	}
+
+	now := time.Now()
+	if now.After(entry.ExpiresAt) {
+		_ = s.deviceCodeStore.Delete(ctx, entry.DeviceCode)
+		return TokenResponse{}, ErrExpiredToken
+	}
+	switch entry.Status {
+	case DeviceCodeApproved, DeviceCodeDenied:
+		// Only the poll that removes the decision answers it; a concurrent
+		// poll that read the same entry finds it gone.
+		consumed, err := s.deviceCodeStore.Consume(ctx, entry.DeviceCode, entry.Status)
+		if err != nil {
+			return TokenResponse{}, err
+		}
+		if !consumed {
+			return TokenResponse{}, ErrInvalidDeviceCode
+		}
+		if entry.Status == DeviceCodeDenied {
+			return TokenResponse{}, ErrAccessDenied
+		}
+		return s.issueTokens(ctx, tenantID, client.ID, entry.Scope, "user", tokenConfirmation(client, req), "",
+			Session{Subject: entry.Subject, UserAgent: req.UserAgent, IPAddress: req.ClientIP})
+	}
+
+	pollErr := ErrAuthorizationPending
+	if !entry.LastPolledAt.IsZero() && now.Sub(entry.LastPolledAt) < time.Duration(entry.Interval)*time.Second {
+		// RFC 8628 section 3.5: each slow_down adds 5 seconds to the interval.
+		entry.Interval += 5
+		pollErr = ErrSlowDown
+	}
+	entry.LastPolledAt = now
+	if err := s.deviceCodeStore.Save(ctx, entry); err != nil {
+		return TokenResponse{}, err
+	}
+	return TokenResponse{}, pollErr
+}
+
+// generateUserCode returns a random code formatted as XXXX-XXXX.
+func generateUserCode() (string, error) {
+	var b strings.Builder
+	alphabetSize := big.NewInt(int64(len(userCodeAlphabet)))
+	for i := 0; i < 8; i++ {
+		if i == 4 {
+			b.WriteByte('-')
+		}
+		n, err := rand.Int(rand.Reader, alphabetSize)
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(userCodeAlphabet[n.Int64()])
+	}
+	return b.String(), nil
+}
+
+// normalizeUserCode accepts user codes typed in any case, with or without the dash.
+func normalizeUserCode(code string) string {
+	code = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	if len(code) != 8 {
+		return code
+	}
+	return code[:4] + "-" + code[4:]
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrInvalidIDTokenHint           = &Error{"invalid_request", "id_token_hint is invalid"}
+	ErrInvalidPostLogoutRedirectURI = &Error{"invalid_request", "post_logout_redirect_uri is not registered for this client"}
+)
+
+// EndSession implements RP-initiated logout. The id_token_hint identifies the
+// client and session; every refresh token in the session's family is revoked.
+// It returns the validated post-logout redirect, or "" when none was requested.
+func (s *authService) EndSession(ctx context.Context, req EndSessionRequest) (string, error) {
+	tenantID, err := middleware.TenantIDFromContext(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// The hint may have expired by the time the user logs out; only its
+	// signature and issuer-side claims matter here.
+	claims, err := s.parseSignedToken(req.IDTokenHint, jwt.WithoutClaimsValidation())
+	if err != nil {
+		return "", ErrInvalidIDTokenHint
+	}
+	tenant, _ := claims["tenant"].(string)
+	clientID, _ := claims["aud"].(string)
+	sid, _ := claims["sid"].(string)
+	if tenant != tenantID || clientID == "" || sid == "" {
+		return "", ErrInvalidIDTokenHint
+	}
+	if req.ClientID != "" && req.ClientID != clientID {
+		return "", ErrInvalidIDTokenHint
+	}
+	client, err := s.resolveClient(ctx, tenantID, clientID)
+	if err != nil {
+		return "", err
+	}
+
+	// Validate the redirect before revoking so a bad request has no side effects.
+	redirectURI := ""
+	if req.PostLogoutRedirectURI != "" {
+		if !client.allowsPostLogoutRedirect(req.PostLogoutRedirectURI) {
+			return "", ErrInvalidPostLogoutRedirectURI
+		}
+		parsed, err := url.Parse(req.PostLogoutRedirectURI)
+		if err != nil {
+			return "", ErrInvalidPostLogoutRedirectURI
+		}
+		if req.State != "" {
+			values := parsed.Query()
+			values.Set("state", req.State)
+			parsed.RawQuery = values.Encode()
+		}
+		redirectURI = parsed.String()
+	}
+
+	if err := s.endSession(ctx, tenantID, sid); err != nil {
+		return "", err
+	}
+	return redirectURI, nil
+}
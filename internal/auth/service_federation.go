@@ -1,310 +1,329 @@
 package auth
 
 import (
-	"bytes"
 	"context"
+	"crypto/subtle"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
 	"net/url"
-
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/dhawalhost/wardseal/internal/sso"
 	"github.com/dhawalhost/wardseal/pkg/middleware"
 	"go.uber.org/zap"
 	"golang.org/x/oauth2"
 )
 
-// SocialLogin handles the login/registration via an external provider.
-func (s *authService) SocialLogin(ctx context.Context, req SocialLoginRequest) (TokenResponse, error) {
-	tenantID, err := middleware.TenantIDFromContext(ctx)
-	if err != nil {
-		return TokenResponse{}, err
-	}
+// socialLoginStateTTL bounds how long a user has to finish a social login
+// after it was started.
+const socialLoginStateTTL = 10 * time.Minute
+
+var errInvalidSocialState = &Error{"invalid_request", "invalid or expired social login state"}
 
-	// 1. Validate Provider & Exchange Token
-	ssoProvider, err := s.ssoProviderStore.GetByName(ctx, tenantID, req.Provider)
+var errUnregisteredSocialRedirect = &Error{"invalid_request", "redirect_uri is not registered for this provider"}
+
+// socialClient is a provider's OAuth2 client and userinfo endpoint.
+type socialClient struct {
+	provider    *SSOProvider
+	config      *oauth2.Config
+	userInfoURL string
+}
+
+// socialClientFor loads the tenant's provider and builds its OAuth2 client.
+func (s *authService) socialClientFor(ctx context.Context, tenantID, provider string) (*socialClient, error) {
+	ssoProvider, err := s.ssoProviderStore.GetByName(ctx, tenantID, provider)
 	if err != nil {
-		return TokenResponse{}, err
+		return nil, err
 	}
 	if ssoProvider == nil {
-		return TokenResponse{}, &Error{"invalid_request", fmt.Sprintf("provider '%s' not configured", req.Provider)}
+		return nil, &Error{"invalid_request", fmt.Sprintf("provider '%s' not configured", provider)}
 	}
 
-	// Default to generic OIDC if not specified
-	authURL := ""
-	tokenURL := ""
-	userInfoURL := ""
-
-	if ssoProvider.OIDCIssuerURL != nil && *ssoProvider.OIDCIssuerURL != "" {
-		// Basic assumption for standard OIDC
-		issuer := strings.TrimRight(*ssoProvider.OIDCIssuerURL, "/")
-		authURL = issuer + "/authorize"
-		tokenURL = issuer + "/token"
-		userInfoURL = issuer + "/userinfo"
-
-		// Handle Google specifically if needed, but Google follows OIDC usually
-		if req.Provider == "google" {
-			userInfoURL = "https://www.googleapis.com/oauth2/v3/userinfo"
-		}
-	} else {
-		// Fallback for known providers if URL not set (unlikely if configured correctly)
-		return TokenResponse{}, &Error{"invalid_configuration", "sso provider issuer url missing"}
+	if ssoProvider.OIDCIssuerURL == nil || *ssoProvider.OIDCIssuerURL == "" {
+		return nil, &Error{"invalid_configuration", "sso provider issuer url missing"}
+	}
+	// Basic assumption for standard OIDC
+	issuer := strings.TrimRight(*ssoProvider.OIDCIssuerURL, "/")
+	userInfoURL := issuer + "/userinfo"
+	// Handle Google specifically if needed, but Google follows OIDC usually
+	if provider == "google" {
+		userInfoURL = "https://www.googleapis.com/oauth2/v3/userinfo"
 	}
 
 	clientID := ""
 	if ssoProvider.OIDCClientID != nil {
 		clientID = *ssoProvider.OIDCClientID
 	}
+	clientSecret, err := s.ssoCipher.Decrypt(string(ssoProvider.OIDCClientSecret), sso.SecretAdditionalData(tenantID))
+	if err != nil {
+		return nil, &Error{"invalid_configuration", "sso provider client secret cannot be decrypted"}
+	}
 
 	conf := &oauth2.Config{
 		ClientID:     clientID,
-		ClientSecret: string(ssoProvider.OIDCClientSecret),
-		// RedirectURL:  req.RedirectURI, // Need to verify if request has it
-		Scopes: []string{"openid", "profile", "email"},
+		ClientSecret: clientSecret,
+		Scopes:       []string{"openid", "profile", "email"},
 		Endpoint: oauth2.Endpoint{
-			AuthURL:  authURL,
-			TokenURL: tokenURL,
+			AuthURL:  issuer + "/authorize",
+			TokenURL: issuer + "/token",
 		},
 	}
-
 	if ssoProvider.OIDCScopes != nil && *ssoProvider.OIDCScopes != "" {
 		conf.Scopes = strings.Split(*ssoProvider.OIDCScopes, " ")
 	}
+	return &socialClient{provider: ssoProvider, config: conf, userInfoURL: userInfoURL}, nil
+}
 
-	token, err := conf.Exchange(ctx, req.Code)
+// StartSocialLogin returns the provider's authorize URL for a new login and
+// its state. The state, nonce and PKCE verifier the URL carries are stored
+// until the callback. redirectURI defaults to this service's
+// /auth/sso/{provider}/callback; any other must be one of the provider's
+// registered redirect URIs.
+func (s *authService) StartSocialLogin(ctx context.Context, provider, redirectURI string) (string, string, error) {
+	tenantID, err := middleware.TenantIDFromContext(ctx)
 	if err != nil {
-		return TokenResponse{}, &Error{"invalid_grant", "failed to exchange code: " + err.Error()}
+		return "", "", err
 	}
-
-	client := conf.Client(ctx, token)
-	resp, err := client.Get(userInfoURL)
+	client, err := s.socialClientFor(ctx, tenantID, provider)
 	if err != nil {
-		return TokenResponse{}, &Error{"invalid_request", "failed to fetch user profile"}
+		return "", "", err
 	}
-	defer func() { _ = resp.Body.Close() }()
-
-	var idTokenClaims struct {
-		Email string `json:"email"`
-		Sub   string `json:"sub"`
-		Name  string `json:"name"`
+	callback := s.baseURL + "/auth/sso/" + url.PathEscape(provider) + "/callback"
+	if redirectURI == "" {
+		redirectURI = callback
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&idTokenClaims); err != nil {
-		return TokenResponse{}, &Error{"server_error", "failed to decode user profile"}
+	if redirectURI != callback && !slices.Contains(client.provider.OIDCRedirectURIs, redirectURI) {
+		return "", "", errUnregisteredSocialRedirect
 	}
 
-	profile := struct {
-		Email      string
-		ExternalID string
-		Name       string
-	}{
-		Email:      idTokenClaims.Email,
-		ExternalID: idTokenClaims.Sub,
-		Name:       idTokenClaims.Name,
+	state, err := generateAuthorizationCode()
+	if err != nil {
+		return "", "", err
 	}
-
-	if profile.Email == "" || profile.ExternalID == "" {
-		return TokenResponse{}, &Error{"invalid_request", "no email or sub in provider response"}
+	nonce, err := generateAuthorizationCode()
+	if err != nil {
+		return "", "", err
+	}
+	entry := socialLoginState{
+		State:        state,
+		TenantID:     tenantID,
+		Provider:     provider,
+		Nonce:        nonce,
+		CodeVerifier: oauth2.GenerateVerifier(),
+		RedirectURI:  redirectURI,
+		ExpiresAt:    time.Now().Add(socialLoginStateTTL),
+	}
+	if err := s.socialStateStore.Save(ctx, entry); err != nil {
+		return "", "", err
 	}
 
-	// 2. Check for existing link
-	existingParams, err := s.federationStore.Get(ctx, tenantID, req.Provider, profile.ExternalID)
+	client.config.RedirectURL = redirectURI
+	return client.config.AuthCodeURL(state,
+		oauth2.S256ChallengeOption(entry.CodeVerifier),
+		oauth2.SetAuthURLParam("nonce", nonce),
+	), state, nil
+}
+
+// SocialLogin completes a social login started by StartSocialLogin in the
+// tenant of ctx. The state must be the one the start issued for the provider.
+func (s *authService) SocialLogin(ctx context.Context, req SocialLoginRequest) (TokenResponse, error) {
+	tenantID, err := middleware.TenantIDFromContext(ctx)
 	if err != nil {
 		return TokenResponse{}, err
 	}
-
-	var userID string
-
-	switch {
-	case existingParams != nil:
-		// Link exists -> Login
-		userID = existingParams.IdentityID //nolint:ineffassign // Used in issueTokens below
-
-	default:
-		// No link -> Check if user exists by email (JIT / Auto-Link)
-		// We need to call Directory Service to find user by email
-		// Note: Directory Service abstraction is needed here.
-		// authService has `Login` which calls `verifyCredentials`.
-		// We need a `GetUserByEmail` method exposed on Directory Service or via HTTP.
-
-		// For now, let's assume we can create/provision or find via HTTP.
-		// Since we don't have a direct `FindUserByEmail` RPC client, we'll implement a helper.
-
-		user, err := s.findUserByEmail(ctx, tenantID, profile.Email)
-		if err != nil {
-			// Error looking up user
-			return TokenResponse{}, err
-		}
-
-		if user != nil {
-			// User exists -> Create Link
-			userID = user.ID
-			profileDataBytes, _ := json.Marshal(map[string]interface{}{"email": profile.Email})
-			if err := s.federationStore.Create(ctx, FederatedIdentity{
-				IdentityID:  userID,
-				TenantID:    tenantID,
-				Provider:    req.Provider,
-				ExternalID:  profile.ExternalID,
-				ProfileData: JSON(profileDataBytes),
-			}); err != nil {
-				return TokenResponse{}, err
-			}
-		} else {
-			// User does not exist -> JIT Provision
-			// 1. Create User in Directory
-			newUser, err := s.provisionUser(ctx, tenantID, profile.Email, profile.Name)
-			if err != nil {
-				return TokenResponse{}, err
-			}
-			userID = newUser.ID
-
-			// 2. Create Link
-			profileDataBytes, _ := json.Marshal(map[string]interface{}{"email": profile.Email})
-			if err := s.federationStore.Create(ctx, FederatedIdentity{
-				IdentityID:  userID,
-				TenantID:    tenantID,
-				Provider:    req.Provider,
-				ExternalID:  profile.ExternalID,
-				ProfileData: JSON(profileDataBytes),
-			}); err != nil {
-				return TokenResponse{}, err
-			}
-		}
+	entry, err := s.takeSocialState(ctx, req.Provider, req.State)
+	if err != nil {
+		return TokenResponse{}, err
 	}
-
-	// 3. Issue Tokens (Same as Login)
-	// We assume minimal scope for now or default
-	scope := "openid profile email"
-	_ = userID // TODO: issueTokens should use userID for subject claim
-
-	return s.issueTokens(ctx, tenantID, "social-client", scope, "user") // ClientID is dummy for now
+	if entry.TenantID != tenantID {
+		return TokenResponse{}, errInvalidSocialState
+	}
+	return s.finishSocialLogin(ctx, entry, req.Code)
 }
 
-// Helper structs for internal calls
-type directoryUser struct {
-	ID    string `json:"id"`
-	Email string `json:"email"`
+// SocialLoginCallback completes a social login when the provider redirects
+// back. The tenant is the one that started the login.
+func (s *authService) SocialLoginCallback(ctx context.Context, provider, state, code string) (TokenResponse, error) {
+	entry, err := s.takeSocialState(ctx, provider, state)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+	return s.finishSocialLogin(ctx, entry, code)
 }
 
-// findUserByEmail calls Directory service to find a user
-func (s *authService) findUserByEmail(ctx context.Context, tenantID, email string) (*directoryUser, error) {
-	// Call SCIM Service: GET /scim/v2/Users?filter=userName eq "email"
-	// We use s.directoryServiceURL + /scim/v2/Users
+// takeSocialState redeems a state issued for provider. A state is only good
+// once, so a replayed or forged callback is rejected.
+func (s *authService) takeSocialState(ctx context.Context, provider, state string) (socialLoginState, error) {
+	if state == "" {
+		return socialLoginState{}, &Error{"invalid_request", "state is required"}
+	}
+	entry, ok, err := s.socialStateStore.Take(ctx, state)
+	if err != nil {
+		return socialLoginState{}, err
+	}
+	if !ok || entry.Provider != provider || time.Now().After(entry.ExpiresAt) {
+		return socialLoginState{}, errInvalidSocialState
+	}
+	return entry, nil
+}
 
-	filter := fmt.Sprintf("userName eq \"%s\"", email)
-	u, err := url.Parse(fmt.Sprintf("%s/scim/v2/Users", s.directoryServiceURL))
+// finishSocialLogin exchanges the code, checks the ID token nonce and signs
+// in the linked or provisioned user.
+func (s *authService) finishSocialLogin(ctx context.Context, entry socialLoginState, code string) (TokenResponse, error) {
+	tenantID := entry.TenantID
+	client, err := s.socialClientFor(ctx, tenantID, entry.Provider)
 	if err != nil {
-		return nil, err
+		return TokenResponse{}, err
 	}
-	q := u.Query()
-	q.Set("filter", filter)
-	u.RawQuery = q.Encode()
+	client.config.RedirectURL = entry.RedirectURI
 
-	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	token, err := client.config.Exchange(ctx, code, oauth2.VerifierOption(entry.CodeVerifier))
 	if err != nil {
-		return nil, err
+		return TokenResponse{}, &Error{"invalid_grant", "failed to exchange code: " + err.Error()}
 	}
-	req.Header.Set(middleware.DefaultTenantHeader, tenantID)
-	// Internal Auth
-	if s.serviceAuthToken != "" {
-		req.Header.Set(s.serviceAuthHeader, s.serviceAuthToken)
+	if rawIDToken, ok := token.Extra("id_token").(string); ok && rawIDToken != "" {
+		nonce, err := idTokenNonce(rawIDToken)
+		if err != nil || subtle.ConstantTimeCompare([]byte(nonce), []byte(entry.Nonce)) != 1 {
+			return TokenResponse{}, &Error{"invalid_grant", "id token nonce does not match"}
+		}
 	}
 
-	resp, err := s.httpClient.Do(req)
+	resp, err := client.config.Client(ctx, token).Get(client.userInfoURL)
 	if err != nil {
-		return nil, err
+		return TokenResponse{}, &Error{"invalid_request", "failed to fetch user profile"}
 	}
 	defer func() { _ = resp.Body.Close() }()
 
-	if resp.StatusCode != http.StatusOK {
-		// Log or return error
-		zap.L().Warn("Failed to search user by email", zap.Int("status", resp.StatusCode))
-		return nil, nil // Treat as not found or error
+	claims, err := client.provider.ClaimMap()
+	if err != nil {
+		return TokenResponse{}, &Error{"invalid_configuration", err.Error()}
 	}
-
-	// Parse SCIM List Response
-	var listResp struct {
-		TotalResults int `json:"totalResults"`
-		Resources    []struct {
-			ID       string `json:"id"`
-			UserName string `json:"userName"`
-		} `json:"Resources"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&listResp); err != nil {
-		return nil, err
+	profile, err := decodeUserInfo(resp.Body, claims)
+	if err != nil {
+		return TokenResponse{}, err
 	}
-
-	if listResp.TotalResults > 0 && len(listResp.Resources) > 0 {
-		return &directoryUser{
-			ID:    listResp.Resources[0].ID,
-			Email: listResp.Resources[0].UserName,
-		}, nil
+	if profile.Email == "" {
+		return TokenResponse{}, &Error{"invalid_request", fmt.Sprintf("provider response has no %q claim for the email", claims.EmailClaim)}
 	}
 
-	return nil, nil
-}
+	// Resolve (link or JIT provision) the local user
+	userID, err := s.resolveFederatedUser(ctx, tenantID, entry.Provider, client.provider.LinkPolicy, profile)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+	if token.RefreshToken != "" && grantsOfflineAccess(token, client.config.Scopes) {
+		s.keepProviderRefreshToken(ctx, tenantID, entry.Provider, profile.Subject, token.RefreshToken)
+	}
+	s.recordFederatedLogin(ctx, tenantID, userID)
 
-func (s *authService) provisionUser(ctx context.Context, tenantID, email, name string) (*directoryUser, error) {
-	// Call SCIM Service: POST /scim/v2/Users
-	u := fmt.Sprintf("%s/scim/v2/Users", s.directoryServiceURL)
+	// Issue Tokens (Same as Login)
+	// We assume minimal scope for now or default
+	scope := "openid profile email"
+	// TODO: issueTokens should use userID for subject claim
 
-	// Construct SCIM JSON
-	// Very basic SCIM payload
-	// Note: We need to set a dummy password or handle passwordless creation if directory supports it.
-	// Our CreateUser implementation expects a password.
-	// We'll generate a random complex password for federated users since they won't use it directly.
+	return s.issueTokens(ctx, tenantID, "social-client", scope, "user", confirmation{}, "", Session{Subject: userID}) // ClientID is dummy for now
+}
 
-	randomPwd, _ := generateAuthorizationCode() // Reusing random string gen, 32 bytes base64 is strong enough
+// recordFederatedLogin updates the user's last login in the directory, which
+// password logins do as part of verifying credentials. A failure is logged
+// rather than failing the login.
+func (s *authService) recordFederatedLogin(ctx context.Context, tenantID, userID string) {
+	if err := s.directory.RecordLogin(ctx, tenantID, userID); err != nil {
+		zap.L().Warn("Failed to record federated login", zap.String("tenant_id", tenantID), zap.String("user_id", userID), zap.Error(err))
+	}
+}
 
-	scimUser := map[string]interface{}{
-		"schemas":  []string{"urn:ietf:params:scim:schemas:core:2.0:User"},
-		"userName": email,
-		"password": randomPwd,
-		"active":   true,
-		"name": map[string]string{
-			"formatted":  name,
-			"familyName": name, // fallback
-		},
-		"emails": []map[string]interface{}{
-			{
-				"value":   email,
-				"primary": true,
-				"type":    "work",
-			},
-		},
+// keepProviderRefreshToken stores the provider's refresh token on the user's
+// link. A failure is logged rather than failing the login, as it only
+// affects later calls to the provider's API.
+func (s *authService) keepProviderRefreshToken(ctx context.Context, tenantID, provider, externalID, refreshToken string) {
+	link, err := s.federationStore.Get(ctx, tenantID, provider, externalID)
+	if err == nil && link != nil {
+		err = s.saveProviderRefreshToken(ctx, link, refreshToken)
 	}
+	if err != nil {
+		zap.L().Warn("Failed to store provider refresh token", zap.String("tenant_id", tenantID), zap.String("provider", provider), zap.Error(err))
+	}
+}
 
-	bodyBytes, _ := json.Marshal(scimUser)
-	req, err := http.NewRequestWithContext(ctx, "POST", u, bytes.NewReader(bodyBytes))
+// idTokenNonce reads the nonce claim of an ID token. The token came straight
+// from the provider's token endpoint over TLS, which OIDC accepts in place
+// of checking its signature.
+func idTokenNonce(rawIDToken string) (string, error) {
+	parts := strings.Split(rawIDToken, ".")
+	if len(parts) != 3 {
+		return "", errors.New("malformed id token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+	var claims struct {
+		Nonce string `json:"nonce"`
 	}
-	req.Header.Set("Content-Type", "application/scim+json")
-	req.Header.Set(middleware.DefaultTenantHeader, tenantID)
-	if s.serviceAuthToken != "" {
-		req.Header.Set(s.serviceAuthHeader, s.serviceAuthToken)
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", err
 	}
+	return claims.Nonce, nil
+}
 
-	resp, err := s.httpClient.Do(req)
+// resolveFederatedUser returns the local user linked to an external identity.
+// When no link exists yet, the user is matched by email (auto-link) or
+// provisioned just-in-time, and the link is recorded. Auto-linking requires
+// the provider to have verified the email; under sso.LinkPolicyNone a
+// matching email fails the login instead of linking.
+func (s *authService) resolveFederatedUser(ctx context.Context, tenantID, provider, linkPolicy string, profile FederatedProfile) (string, error) {
+	existing, err := s.federationStore.Get(ctx, tenantID, provider, profile.Subject)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	defer func() { _ = resp.Body.Close() }()
-
-	if resp.StatusCode != http.StatusCreated {
-		return nil, fmt.Errorf("failed to provision user, status: %d", resp.StatusCode)
+	if existing != nil {
+		return existing.IdentityID, nil
 	}
 
-	var createdUser struct {
-		ID       string `json:"id"`
-		UserName string `json:"userName"`
+	// No link -> Check if user exists by email (JIT / Auto-Link)
+	user, err := s.directory.FindUserByEmail(ctx, tenantID, profile.Email)
+	if err != nil {
+		return "", err
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&createdUser); err != nil {
-		return nil, err
+	if user != nil && linkPolicy == sso.LinkPolicyNone {
+		return "", &Error{"access_denied", "an account with this email already exists and provider " + provider + " does not link accounts"}
+	}
+	if user != nil && !profile.EmailVerified {
+		return "", &Error{"access_denied", "an account with this email already exists and provider " + provider + " has not verified the email"}
+	}
+	if user == nil {
+		// User does not exist -> JIT Provision. Federated users never sign in
+		// with a password, so they get a random one the directory requires.
+		password, err := generateAuthorizationCode()
+		if err != nil {
+			return "", err
+		}
+		user, err = s.directory.CreateUser(ctx, tenantID, profile.Email, password)
+		if err != nil {
+			return "", err
+		}
+		if err := s.SendEmailVerification(ctx, tenantID, user.ID, profile.Email); err != nil {
+			zap.L().Warn("Failed to send email verification", zap.String("tenant_id", tenantID), zap.Error(err))
+		}
 	}
 
-	return &directoryUser{
-		ID:    createdUser.ID,
-		Email: createdUser.UserName,
-	}, nil
+	profileData, err := json.Marshal(profile)
+	if err != nil {
+		return "", err
+	}
+	if err := s.federationStore.Create(ctx, FederatedIdentity{
+		IdentityID:  user.ID,
+		TenantID:    tenantID,
+		Provider:    provider,
+		ExternalID:  profile.Subject,
+		ProfileData: JSON(profileData),
+	}); err != nil {
+		return "", err
+	}
+	return user.ID, nil
 }
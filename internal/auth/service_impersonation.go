@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Impersonation token lifetimes, in minutes.
+const (
+	DefaultImpersonationMinutes = 15
+	MaxImpersonationMinutes     = 60
+)
+
+// The permission an administrator needs to impersonate users.
+const (
+	impersonationResource = "users"
+	impersonationAction   = "impersonate"
+)
+
+var (
+	ErrImpersonationForbidden = errors.New("impersonation requires the users:impersonate permission")
+	ErrImpersonationDisabled  = errors.New("impersonation is disabled for this tenant")
+	// ErrImpersonationTarget is returned for a target that may itself
+	// impersonate, which includes the administrator.
+	ErrImpersonationTarget       = errors.New("users who can impersonate cannot be impersonated")
+	ErrInvalidImpersonationToken = errors.New("not an active impersonation token")
+)
+
+// StartImpersonation issues the administrator holding req.ActorToken a token
+// for req.UserID, naming the administrator in its "act" claim. The token
+// cannot be refreshed or used to sign in to clients, so impersonation ends
+// when it expires. When the administrator is authenticated, the returned
+// Impersonation names them even if the request is refused.
+func (s *authService) StartImpersonation(ctx context.Context, tenantID string, req ImpersonationRequest) (Impersonation, error) {
+	actor, err := s.sessionSubject(ctx, tenantID, req.ActorToken)
+	if err != nil {
+		return Impersonation{}, err
+	}
+	result := Impersonation{Actor: actor, Subject: req.UserID}
+
+	if s.permissions == nil {
+		return result, ErrImpersonationForbidden
+	}
+	allowed, err := s.permissions.HasPermission(ctx, tenantID, actor, impersonationResource, impersonationAction)
+	if err != nil {
+		return result, err
+	}
+	if !allowed {
+		return result, ErrImpersonationForbidden
+	}
+	policy, err := s.impersonationStore.Policy(ctx, tenantID)
+	if err != nil {
+		return result, err
+	}
+	if policy.Disabled {
+		return result, ErrImpersonationDisabled
+	}
+	if req.UserID == actor {
+		return result, ErrImpersonationTarget
+	}
+	// Impersonating another administrator would hand over their privileges.
+	privileged, err := s.permissions.HasPermission(ctx, tenantID, req.UserID, impersonationResource, impersonationAction)
+	if err != nil {
+		return result, err
+	}
+	if privileged {
+		return result, ErrImpersonationTarget
+	}
+
+	minutes := policy.maxMinutes()
+	if req.Minutes > 0 && req.Minutes < minutes {
+		minutes = req.Minutes
+	}
+	now := time.Now()
+	expiresAt := now.Add(time.Duration(minutes) * time.Minute)
+	const scope = "openid profile email"
+	token, err := s.signingKeys.sign(jwt.MapClaims{
+		"sub":          req.UserID,
+		"iss":          "identity-platform",
+		"aud":          "client-app",
+		"exp":          expiresAt.Unix(),
+		"iat":          now.Unix(),
+		"jti":          uuid.New().String(),
+		"scope":        scope,
+		"tenant":       tenantID,
+		"subject_type": "user",
+		"act":          map[string]interface{}{"sub": actor},
+	})
+	if err != nil {
+		return result, err
+	}
+	result.TokenResponse = TokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(time.Until(expiresAt).Seconds()),
+		Scope:       scope,
+	}
+	return result, nil
+}
+
+// StopImpersonation revokes an impersonation token of the tenant and returns
+// who was impersonated by whom.
+func (s *authService) StopImpersonation(ctx context.Context, tenantID, token string) (Impersonation, error) {
+	claims, err := s.parseSignedToken(token, jwt.WithAudience("client-app"))
+	if err != nil {
+		return Impersonation{}, ErrInvalidImpersonationToken
+	}
+	subject, _ := claims["sub"].(string)
+	tenant, _ := claims["tenant"].(string)
+	act, _ := claims["act"].(map[string]interface{})
+	actor, _ := act["sub"].(string)
+	if tenant != tenantID || subject == "" || actor == "" {
+		return Impersonation{}, ErrInvalidImpersonationToken
+	}
+	revoked, err := s.revokedTokens.IsRevoked(ctx, token)
+	if err != nil {
+		return Impersonation{}, err
+	}
+	if revoked {
+		return Impersonation{}, ErrInvalidImpersonationToken
+	}
+	if err := s.revokedTokens.Revoke(ctx, token); err != nil {
+		return Impersonation{}, err
+	}
+	return Impersonation{Subject: subject, Actor: actor}, nil
+}
+
+// ImpersonationPolicy returns whether the tenant allows impersonation and for how long.
+func (s *authService) ImpersonationPolicy(ctx context.Context, tenantID string) (ImpersonationPolicy, error) {
+	return s.impersonationStore.Policy(ctx, tenantID)
+}
+
+// UpdateImpersonationPolicy sets whether the tenant allows impersonation and for how long.
+func (s *authService) UpdateImpersonationPolicy(ctx context.Context, tenantID string, policy ImpersonationPolicy) error {
+	return s.impersonationStore.SetPolicy(ctx, tenantID, policy)
+}
+
+// maxMinutes is the longest an impersonation token may live under the policy.
+func (p ImpersonationPolicy) maxMinutes() int {
+	if p.MaxMinutes <= 0 {
+		return DefaultImpersonationMinutes
+	}
+	if p.MaxMinutes > MaxImpersonationMinutes {
+		return MaxImpersonationMinutes
+	}
+	return p.MaxMinutes
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	recoveryCodeCount = 10
+	// recoveryCodeLength excludes the separator shown between the two halves.
+	recoveryCodeLength = 10
+	// recoveryCodeAlphabet omits characters that are easily confused (0/o, 1/l/i).
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+// RegenerateRecoveryCodes replaces the account's recovery codes with a fresh
+// set once code, a current TOTP code or an unused recovery code, checks out.
+// Previously issued codes stop working. The plaintext codes are only
+// available from this call.
+func (s *authService) RegenerateRecoveryCodes(ctx context.Context, accountID, code string) ([]string, error) {
+	tenantID, err := middleware.TenantIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := s.totpStore.GetByIdentity(ctx, tenantID, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		return nil, ErrTOTPNotEnrolled
+	}
+	if err := s.checkSecondFactor(ctx, tenantID, accountID, code); err != nil {
+		return nil, err
+	}
+	return s.issueRecoveryCodes(ctx, tenantID, accountID)
+}
+
+func (s *authService) issueRecoveryCodes(ctx context.Context, tenantID, accountID string) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(normalizeRecoveryCode(code)), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, string(hash))
+	}
+	if err := s.recoveryCodeStore.Replace(ctx, tenantID, accountID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// consumeRecoveryCode reports whether code matches one of the account's unused
+// recovery codes, marking it used so it cannot be presented again.
+func (s *authService) consumeRecoveryCode(ctx context.Context, tenantID, accountID, code string) (bool, error) {
+	normalized := normalizeRecoveryCode(code)
+	if len(normalized) != recoveryCodeLength {
+		return false, nil
+	}
+	unused, err := s.recoveryCodeStore.ListUnused(ctx, tenantID, accountID)
+	if err != nil {
+		return false, err
+	}
+	for _, candidate := range unused {
+		if err := bcrypt.CompareHashAndPassword([]byte(candidate.CodeHash), []byte(normalized)); err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				continue
+			}
+			return false, err
+		}
+		return s.recoveryCodeStore.MarkUsed(ctx, candidate.ID)
+	}
+	return false, nil
+}
+
+// generateRecoveryCode returns a random code formatted as "xxxxx-xxxxx".
+func generateRecoveryCode() (string, error) {
+	var b strings.Builder
+	max := big.NewInt(int64(len(recoveryCodeAlphabet)))
+	for i := 0; i < recoveryCodeLength; i++ {
+		if i == recoveryCodeLength/2 {
+			b.WriteByte('-')
+		}
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(recoveryCodeAlphabet[n.Int64()])
+	}
+	return b.String(), nil
+}
+
+// normalizeRecoveryCode strips separators and case so users can type codes loosely.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dhawalhost/wardseal/internal/saml"
+	"github.com/dhawalhost/wardseal/internal/sso"
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+)
+
+// samlEmailAttributes lists the attribute names IdPs commonly use for the user's email.
+var samlEmailAttributes = []string{
+	"email",
+	"mail",
+	"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress",
+}
+
+// samlNameAttributes lists the attribute names IdPs commonly use for the display name.
+var samlNameAttributes = []string{
+	"displayName",
+	"name",
+	"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/name",
+}
+
+// BeginSAMLLogin starts an SP-initiated login against the tenant's named SAML IdP
+// and returns the URL the browser should be redirected to, and the request ID
+// the browser must present with the response.
+func (s *authService) BeginSAMLLogin(ctx context.Context, provider, relayState string) (string, string, error) {
+	if s.samlConsumer == nil {
+		return "", "", &Error{Code: "server_error", Message: "SAML service provider is not configured"}
+	}
+	tenantID, err := middleware.TenantIDFromContext(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	redirectURL, requestID, err := s.samlConsumer.BeginLogin(ctx, tenantID, provider, relayState)
+	if err != nil {
+		if errors.Is(err, saml.ErrIdentityProviderNotFound) {
+			return "", "", &Error{Code: "invalid_request", Message: "unknown SAML identity provider"}
+		}
+		return "", "", err
+	}
+	return redirectURL.String(), requestID, nil
+}
+
+// ConsumeSAMLResponse validates a SAMLResponse posted to the ACS, resolves (or
+// provisions) the local user and returns a signed session token. requestID is
+// the request the posting browser started, from BeginSAMLLogin.
+func (s *authService) ConsumeSAMLResponse(ctx context.Context, samlResponse, requestID string) (string, error) {
+	if s.samlConsumer == nil {
+		return "", &Error{Code: "server_error", Message: "SAML service provider is not configured"}
+	}
+	result, err := s.samlConsumer.Consume(ctx, samlResponse, requestID)
+	if err != nil {
+		return "", &Error{Code: "access_denied", Message: fmt.Sprintf("SAML assertion rejected: %v", err)}
+	}
+
+	email := firstSAMLAttribute(result, samlEmailAttributes)
+	if email == "" {
+		email = result.NameID
+	}
+	name := firstSAMLAttribute(result, samlNameAttributes)
+
+	profile := FederatedProfile{Subject: result.NameID, Email: email, Name: name}.normalize()
+	userID, err := s.resolveFederatedUser(ctx, result.TenantID, result.Provider, sso.LinkPolicyEmail, profile)
+	if err != nil {
+		return "", err
+	}
+	s.recordFederatedLogin(ctx, result.TenantID, userID)
+	return s.generateUserToken(result.TenantID, userID)
+}
+
+// SAMLMetadata returns the SP metadata XML that IdPs use to configure trust.
+func (s *authService) SAMLMetadata() ([]byte, error) {
+	if s.samlConsumer == nil {
+		return nil, &Error{Code: "server_error", Message: "SAML service provider is not configured"}
+	}
+	return s.samlConsumer.MetadataXML()
+}
+
+func firstSAMLAttribute(result *saml.AssertionResult, names []string) string {
+	for _, name := range names {
+		if value := result.Attribute(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dhawalhost/wardseal/internal/saml"
+)
+
+// testSAMLKeyPair is the SP key pair shared by the test services, generated
+// once since RSA key generation is slow.
+var testSAMLKeyPair = sync.OnceValues(func() (*x509.Certificate, *rsa.PrivateKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "wardseal-sp"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		panic(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		panic(err)
+	}
+	return cert, key
+})
+
+func TestSAMLMetadataCertificateIsStableAcrossInstances(t *testing.T) {
+	cert, key := testSAMLKeyPair()
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "saml.crt"), filepath.Join(dir, "saml.key")
+	writePEM(t, certFile, "CERTIFICATE", cert.Raw)
+	writePEM(t, keyFile, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key))
+
+	var published [][]byte
+	for i := 0; i < 2; i++ {
+		loadedCert, loadedKey, err := saml.LoadKeyPair(certFile, keyFile)
+		if err != nil {
+			t.Fatalf("load key pair: %v", err)
+		}
+		svc, err := NewService(samlTestConfig(loadedCert, loadedKey))
+		if err != nil {
+			t.Fatalf("instance %d: %v", i+1, err)
+		}
+		metadata, err := svc.SAMLMetadata()
+		if err != nil {
+			t.Fatalf("instance %d metadata: %v", i+1, err)
+		}
+		published = append(published, metadata)
+	}
+
+	want := []byte(base64.StdEncoding.EncodeToString(cert.Raw))
+	for i, metadata := range published {
+		if !bytes.Contains(metadata, want) {
+			t.Fatalf("instance %d does not publish the configured certificate", i+1)
+		}
+	}
+}
+
+func TestNewServiceRequiresSAMLKeyPair(t *testing.T) {
+	if _, err := NewService(samlTestConfig(nil, nil)); err == nil {
+		t.Fatalf("expected a service without a SAML key pair to be rejected")
+	}
+}
+
+func samlTestConfig(cert *x509.Certificate, key *rsa.PrivateKey) Config {
+	return Config{
+		BaseURL:             "http://wardseal.com",
+		DirectoryServiceURL: "http://dirsvc",
+		SAMLStore:           saml.NewStore(nil),
+		SAMLCertificate:     cert,
+		SAMLPrivateKey:      key,
+		Clients: []ClientConfig{{
+			ID:            "test-client",
+			TenantID:      "11111111-1111-1111-1111-111111111111",
+			RedirectURIs:  []string{"https://app.wardseal.com/callback"},
+			AllowedScopes: []string{"openid"},
+		}},
+	}
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
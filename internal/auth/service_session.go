@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// trackSession starts a session for a new refresh token family, setting its
+// ID, or marks an existing one as seen. Failing to mark a session seen is
+// only logged, as the tokens are valid either way.
+func (s *authService) trackSession(ctx context.Context, tenantID, clientID string, session *Session) error {
+	now := time.Now()
+	if session.ID != "" {
+		if err := s.sessionStore.Touch(ctx, tenantID, session.ID, session.UserAgent, session.IPAddress, now); err != nil {
+			zap.L().Warn("Failed to update session", zap.String("tenant_id", tenantID), zap.String("session_id", session.ID), zap.Error(err))
+		}
+		return nil
+	}
+	session.ID = uuid.New().String()
+	session.TenantID = tenantID
+	session.ClientID = clientID
+	session.CreatedAt = now
+	return s.sessionStore.Create(ctx, *session)
+}
+
+func (s *authService) ListSessions(ctx context.Context, tenantID, subject string) ([]Session, error) {
+	// A session whose refresh token can no longer be redeemed has ended.
+	return s.sessionStore.List(ctx, tenantID, subject, time.Now().Add(-refreshTokenLifetime))
+}
+
+// RevokeSession revokes the session's refresh tokens, so it cannot obtain new
+// access tokens; ones already issued stay valid until they expire.
+func (s *authService) RevokeSession(ctx context.Context, tenantID, id string) (Session, error) {
+	session, found, err := s.sessionStore.Get(ctx, tenantID, id)
+	if err != nil {
+		return Session{}, err
+	}
+	if !found {
+		return Session{}, ErrSessionNotFound
+	}
+	if err := s.endSession(ctx, tenantID, id); err != nil {
+		return Session{}, err
+	}
+	return session, nil
+}
+
+// endSession revokes a refresh token family and forgets its session.
+func (s *authService) endSession(ctx context.Context, tenantID, familyID string) error {
+	if err := s.refreshTokenStore.DeleteFamily(ctx, familyID); err != nil {
+		return err
+	}
+	return s.sessionStore.Delete(ctx, tenantID, familyID)
+}
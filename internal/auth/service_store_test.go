@@ -24,6 +24,7 @@ func TestPKCEFlowWithClientStore(t *testing.T) {
 		Description:   sql.NullString{Valid: false},
 		RedirectURIs:  pq.StringArray{"https://app-db.wardseal.com/callback"},
 		AllowedScopes: pq.StringArray{"openid", "profile"},
+		FirstParty:    true,
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
 	})
@@ -112,11 +113,14 @@ func TestAuthorizeRejectsUnknownClientFromStore(t *testing.T) {
 
 func newServiceWithStore(t *testing.T, store oauthclient.Store) Service {
 	t.Helper()
+	samlCert, samlKey := testSAMLKeyPair()
 	svc, err := NewService(Config{
 		BaseURL:             "http://wardseal.com",
 		DirectoryServiceURL: "http://dir-service",
 		ClientStore:         store,
 		SAMLStore:           saml.NewStore(nil),
+		SAMLCertificate:     samlCert,
+		SAMLPrivateKey:      samlKey,
 	})
 	if err != nil {
 		t.Fatalf("failed to create auth service with store: %v", err)
@@ -158,6 +162,11 @@ func (s *stubClientStore) ListClientsByTenant(ctx context.Context, tenantID stri
 	return out, nil
 }
 
+func (s *stubClientStore) ListClientsPage(ctx context.Context, tenantID string, filter oauthclient.ListFilter) ([]oauthclient.Client, int, error) {
+	clients, err := s.ListClientsByTenant(ctx, tenantID)
+	return clients, len(clients), err
+}
+
 func (s *stubClientStore) GetClient(ctx context.Context, tenantID, clientID string) (oauthclient.Client, error) {
 	if client, ok := s.clients[s.key(tenantID, clientID)]; ok {
 		return client, nil
@@ -213,6 +222,30 @@ func (s *stubClientStore) DeleteClient(ctx context.Context, tenantID, clientID s
 	return nil
 }
 
+func (s *stubClientStore) ValidateSecret(ctx context.Context, tenantID, clientID, secret string) error {
+	client, err := s.GetClient(ctx, tenantID, clientID)
+	if err != nil {
+		return err
+	}
+	return oauthclient.CheckSecret(client, secret)
+}
+
+func (s *stubClientStore) RotateSecret(ctx context.Context, tenantID, clientID string) (string, error) {
+	client, err := s.GetClient(ctx, tenantID, clientID)
+	if err != nil {
+		return "", err
+	}
+	secret, err := oauthclient.GenerateSecret()
+	if err != nil {
+		return "", err
+	}
+	if client.ClientSecretHash, err = oauthclient.HashSecret(secret); err != nil {
+		return "", err
+	}
+	s.addClient(client)
+	return secret, nil
+}
+
 func nullableDescription(value *string) sql.NullString {
 	if value == nil {
 		return sql.NullString{}
@@ -172,16 +172,38 @@ func contextWithTenant(t *testing.T, tenant string) context.Context {
 
 func newTestService(t *testing.T) *authService {
 	t.Helper()
+	samlCert, samlKey := testSAMLKeyPair()
 	svc, err := NewService(Config{
 		BaseURL:             "http://wardseal.com",
 		DirectoryServiceURL: "http://dirsvc",
 		SAMLStore:           saml.NewStore(nil),
+		SAMLCertificate:     samlCert,
+		SAMLPrivateKey:      samlKey,
 		Clients: []ClientConfig{
 			{
-				ID:            "test-client",
+				ID:                     "test-client",
+				TenantID:               "11111111-1111-1111-1111-111111111111",
+				Name:                   "Test Client",
+				RedirectURIs:           []string{"https://app.wardseal.com/callback"},
+				AllowedScopes:          []string{"openid", "profile"},
+				FirstParty:             true,
+				PostLogoutRedirectURIs: []string{"https://app.wardseal.com/logged-out"},
+				AllowedResources:       []string{"https://orders.wardseal.com"},
+			},
+			{
+				ID:            "bound-client",
 				TenantID:      "11111111-1111-1111-1111-111111111111",
-				Name:          "Test Client",
+				Name:          "Bound Client",
 				RedirectURIs:  []string{"https://app.wardseal.com/callback"},
+				AllowedScopes: []string{"openid"},
+				BindTokens:    true,
+				FirstParty:    true,
+			},
+			{
+				ID:            "partner-client",
+				TenantID:      "11111111-1111-1111-1111-111111111111",
+				Name:          "Partner App",
+				RedirectURIs:  []string{"https://partner.example.com/callback"},
 				AllowedScopes: []string{"openid", "profile"},
 			},
 		},
@@ -191,3 +213,96 @@ func newTestService(t *testing.T) *authService {
 	}
 	return svc.(*authService)
 }
+
+func TestAuthorizeGrantsAllowedSubset(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTestService(t)
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+
+	verifier := "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNO1234567890abcd"
+	authResp, err := as.Authorize(ctx, AuthorizeRequest{
+		ResponseType:  "code",
+		ClientID:      "test-client",
+		RedirectURI:   "https://app.wardseal.com/callback",
+		Scope:         "openid",
+		CodeChallenge: pkceChallenge(verifier),
+	})
+	if err != nil {
+		t.Fatalf("authorize error: %v", err)
+	}
+	tokenResp, err := as.Token(ctx, TokenRequest{
+		GrantType:    "authorization_code",
+		Code:         extractCode(t, authResp.RedirectURI),
+		RedirectURI:  "https://app.wardseal.com/callback",
+		ClientID:     "test-client",
+		CodeVerifier: verifier,
+	})
+	if err != nil {
+		t.Fatalf("token error: %v", err)
+	}
+	if tokenResp.Scope != "openid" {
+		t.Fatalf("expected granted scope openid, got %q", tokenResp.Scope)
+	}
+}
+
+func TestAuthorizeRejectsDisallowedScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTestService(t)
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+
+	_, err := as.Authorize(ctx, AuthorizeRequest{
+		ResponseType:  "code",
+		ClientID:      "test-client",
+		RedirectURI:   "https://app.wardseal.com/callback",
+		Scope:         "openid admin",
+		CodeChallenge: pkceChallenge("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNO1234567890abcd"),
+	})
+	svcErr := &Error{}
+	if !errors.As(err, &svcErr) || svcErr.Code != "invalid_scope" {
+		t.Fatalf("expected invalid_scope, got %v", err)
+	}
+}
+
+func TestAuthorizeDropsDisallowedScopeWhenConfigured(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTestService(t)
+	as.scopePolicy = ScopePolicyDrop
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+
+	verifier := "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNO1234567890abcd"
+	authResp, err := as.Authorize(ctx, AuthorizeRequest{
+		ResponseType:  "code",
+		ClientID:      "test-client",
+		RedirectURI:   "https://app.wardseal.com/callback",
+		Scope:         "openid admin profile",
+		CodeChallenge: pkceChallenge(verifier),
+	})
+	if err != nil {
+		t.Fatalf("authorize error: %v", err)
+	}
+	tokenResp, err := as.Token(ctx, TokenRequest{
+		GrantType:    "authorization_code",
+		Code:         extractCode(t, authResp.RedirectURI),
+		RedirectURI:  "https://app.wardseal.com/callback",
+		ClientID:     "test-client",
+		CodeVerifier: verifier,
+	})
+	if err != nil {
+		t.Fatalf("token error: %v", err)
+	}
+	if tokenResp.Scope != "openid profile" {
+		t.Fatalf("expected granted scope %q, got %q", "openid profile", tokenResp.Scope)
+	}
+
+	_, err = as.Authorize(ctx, AuthorizeRequest{
+		ResponseType:  "code",
+		ClientID:      "test-client",
+		RedirectURI:   "https://app.wardseal.com/callback",
+		Scope:         "admin",
+		CodeChallenge: pkceChallenge(verifier),
+	})
+	svcErr := &Error{}
+	if !errors.As(err, &svcErr) || svcErr.Code != "invalid_scope" {
+		t.Fatalf("expected invalid_scope when nothing is allowed, got %v", err)
+	}
+}
@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Token exchange (RFC 8693) identifiers.
+const (
+	TokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	AccessTokenType        = "urn:ietf:params:oauth:token-type:access_token"
+	JWTTokenType           = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+var (
+	ErrInvalidSubjectToken = &Error{"invalid_grant", "subject_token is invalid, expired or revoked"}
+	ErrScopeEscalation     = &Error{"invalid_scope", "requested scope exceeds the scope of the subject token"}
+	ErrInvalidTarget       = &Error{"invalid_target", "audience is not a registered client of this tenant"}
+	// ErrSubjectTokenDPoPBound is returned when a DPoP-bound subject token is
+	// exchanged without a proof for its key.
+	ErrSubjectTokenDPoPBound = &Error{"invalid_grant", "subject_token is bound to a DPoP key; send a DPoP proof for that key"}
+)
+
+// nonAccessTokenAudiences are the audiences of tokens this service signs that
+// are not access tokens and must never be exchanged for one.
+var nonAccessTokenAudiences = map[string]bool{
+	mfaChallengeType:          true,
+	passwordResetAudience:     true,
+	emailVerificationAudience: true,
+}
+
+// resolveSubjectToken returns the claims of an access token presented as a
+// token exchange subject. Opaque tokens are access tokens by construction;
+// JWTs must carry an access token audience (the default, a registered client
+// or a resource indicator) and a scope, which rules out pre-MFA challenge,
+// password reset, email verification and ID tokens. Tokens revoked directly
+// or by a later security event for the subject are rejected.
+func (s *authService) resolveSubjectToken(ctx context.Context, tenantID, token string) (jwt.MapClaims, error) {
+	revoked, err := s.revokedTokens.IsRevoked(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrInvalidSubjectToken
+	}
+	subject, found, err := s.resolveAccessToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrInvalidSubjectToken
+	}
+	if tenant, _ := subject["tenant"].(string); tenant != tenantID {
+		return nil, ErrInvalidSubjectToken
+	}
+	if _, ok := subject["scope"].(string); !ok {
+		return nil, ErrInvalidSubjectToken
+	}
+	audiences, err := subject.GetAudience()
+	if err != nil || len(audiences) == 0 {
+		return nil, ErrInvalidSubjectToken
+	}
+	for _, aud := range audiences {
+		if nonAccessTokenAudiences[aud] {
+			return nil, ErrInvalidSubjectToken
+		}
+		if aud == "client-app" || validResourceIndicator(aud) {
+			continue
+		}
+		if _, err := s.resolveClient(ctx, tenantID, aud); err != nil {
+			return nil, ErrInvalidSubjectToken
+		}
+	}
+	sub, _ := subject["sub"].(string)
+	if sub == "" || s.sessionRevoked(ctx, sub, subject) {
+		return nil, ErrInvalidSubjectToken
+	}
+	return subject, nil
+}
+
+// subjectConfirmation returns what a subject token is bound to.
+func subjectConfirmation(claims jwt.MapClaims) confirmation {
+	cnf, _ := claims["cnf"].(map[string]interface{})
+	fpt, _ := cnf["fpt"].(string)
+	jkt, _ := cnf["jkt"].(string)
+	return confirmation{Fingerprint: fpt, JKT: jkt}
+}
+
+// handleTokenExchangeGrant lets an authenticated confidential client (the
+// actor) trade a user's access token for a token with the same or narrower
+// scope, optionally for another audience. The new token records the actor in
+// its "act" claim, nesting any actor already present on the subject token.
+func (s *authService) handleTokenExchangeGrant(ctx context.Context, tenantID string, req TokenRequest) (TokenResponse, error) {
+	if req.ClientID == "" || req.SubjectToken == "" || req.SubjectTokenType == "" {
+		return TokenResponse{}, &Error{"invalid_request", "client_id, subject_token and subject_token_type are required"}
+	}
+	if req.SubjectTokenType != AccessTokenType && req.SubjectTokenType != JWTTokenType {
+		return TokenResponse{}, &Error{"invalid_request", "unsupported subject_token_type"}
+	}
+	if req.RequestedTokenType != "" && req.RequestedTokenType != AccessTokenType {
+		return TokenResponse{}, &Error{"invalid_request", "only access tokens can be requested"}
+	}
+
+	client, err := s.resolveClient(ctx, tenantID, req.ClientID)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+	if err := s.authenticateConfidentialClient(ctx, tenantID, client, req.ClientSecret, "token-exchange"); err != nil {
+		return TokenResponse{}, err
+	}
+
+	subject, err := s.resolveSubjectToken(ctx, tenantID, req.SubjectToken)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+	// A DPoP-bound subject token is only exchanged by the key holder, and
+	// stays bound in the new token.
+	subjectCnf := subjectConfirmation(subject)
+	if subjectCnf.JKT != "" && subjectCnf.JKT != req.dpopJKT {
+		return TokenResponse{}, ErrSubjectTokenDPoPBound
+	}
+
+	// The exchanged scope must be covered by both the subject token and the actor's registration.
+	subjectScopes, _ := subject["scope"].(string)
+	scope := subjectScopes
+	if req.Scope != "" {
+		if !scopesGranted(strings.Fields(req.Scope), strings.Fields(subjectScopes)) {
+			return TokenResponse{}, ErrScopeEscalation
+		}
+		scope = strings.Join(strings.Fields(req.Scope), " ")
+	}
+	if err := client.validateScopes(scope); err != nil {
+		return TokenResponse{}, newInvalidScopeError(err.Error())
+	}
+
+	audience := subject["aud"]
+	if req.Audience != "" {
+		if _, err := s.resolveClient(ctx, tenantID, req.Audience); err != nil {
+			return TokenResponse{}, ErrInvalidTarget
+		}
+		audience = req.Audience
+	}
+
+	// Never outlive the subject token.
+	now := time.Now()
+	expiresAt := now.Add(time.Hour)
+	if exp, err := subject.GetExpirationTime(); err == nil && exp != nil && exp.Before(expiresAt) {
+		expiresAt = exp.Time
+	}
+
+	act := map[string]interface{}{"sub": client.ID}
+	if prior, ok := subject["act"]; ok {
+		act["act"] = prior
+	}
+	claims := jwt.MapClaims{
+		"sub":          subject["sub"],
+		"iss":          "identity-platform",
+		"aud":          audience,
+		"exp":          expiresAt.Unix(),
+		"iat":          now.Unix(),
+		"scope":        scope,
+		"tenant":       tenantID,
+		"subject_type": subject["subject_type"],
+		"act":          act,
+	}
+	cnf := tokenConfirmation(client, req)
+	if cnf.Fingerprint == "" {
+		cnf.Fingerprint = subjectCnf.Fingerprint
+	}
+	if claim := cnf.claim(); claim != nil {
+		claims["cnf"] = claim
+	}
+	accessToken, err := s.issueAccessToken(ctx, claims)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	return TokenResponse{
+		AccessToken:     accessToken,
+		TokenType:       cnf.tokenType(),
+		ExpiresIn:       int(time.Until(expiresAt).Seconds()),
+		Scope:           scope,
+		IssuedTokenType: AccessTokenType,
+	}, nil
+}
@@ -0,0 +1,319 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	totpIssuer = "WardSeal"
+	totpPeriod = 30
+	// totpSkew is the number of periods either side of "now" that are accepted.
+	totpSkew = 1
+
+	mfaChallengeType = "mfa_challenge"
+	mfaChallengeTTL  = 5 * time.Minute
+	// MaxMFAChallengeFailures is how many wrong codes, counted with the
+	// account's failed logins, end an MFA challenge.
+	MaxMFAChallengeFailures = 5
+)
+
+var ErrTOTPNotEnrolled = &Error{"invalid_request", "TOTP is not enrolled for this account"}
+var ErrInvalidTOTPCode = &Error{"invalid_grant", "TOTP code is invalid or has already been used"}
+var ErrInvalidMFAChallenge = &Error{"invalid_grant", "MFA challenge is invalid or expired"}
+var ErrTOTPAlreadyEnrolled = &Error{"invalid_request", "TOTP is already enrolled for this account; remove it before enrolling again"}
+
+// PendingMFALogin is a login waiting on its second factor, as asserted by
+// an MFA challenge token.
+type PendingMFALogin struct {
+	// ChallengeID identifies the challenge token for RevokeMFAChallenge.
+	ChallengeID string
+	// Login is the name the password was checked for, which failed second
+	// factors are counted against.
+	Login     string
+	subject   string
+	accountID string
+}
+
+// TOTPEnrollment is returned when an account starts TOTP enrollment.
+type TOTPEnrollment struct {
+	Key *otp.Key
+	// RecoveryCodes are the plaintext one-time codes; only their hashes are stored.
+	RecoveryCodes []string
+}
+
+// EnrollTOTP generates a new TOTP secret for the account and stores it,
+// encrypted and unverified, until the first code is confirmed with VerifyTOTP.
+// A verified secret is never replaced; it has to be removed with DeleteTOTP
+// first.
+func (s *authService) EnrollTOTP(ctx context.Context, accountID string) (*TOTPEnrollment, error) {
+	tenantID, err := middleware.TenantIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      totpIssuer,
+		AccountName: accountID,
+		Period:      totpPeriod,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate TOTP key: %w", err)
+	}
+
+	encrypted, err := s.totpCipher.Encrypt(key.Secret(), totpAdditionalData(tenantID, accountID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt TOTP secret: %w", err)
+	}
+	if err := s.totpStore.Create(ctx, &TOTPSecret{
+		IdentityID: accountID,
+		TenantID:   tenantID,
+		Secret:     encrypted,
+	}); err != nil {
+		return nil, err
+	}
+
+	recoveryCodes, err := s.issueRecoveryCodes(ctx, tenantID, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return &TOTPEnrollment{Key: key, RecoveryCodes: recoveryCodes}, nil
+}
+
+// VerifyTOTP checks a code for the account and, on first success, marks the
+// enrollment as verified so that MFA is enforced at login.
+func (s *authService) VerifyTOTP(ctx context.Context, accountID, code string) error {
+	tenantID, err := middleware.TenantIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	stored, err := s.checkTOTPCode(ctx, tenantID, accountID, code)
+	if err != nil {
+		return err
+	}
+	if !stored.Verified {
+		return s.totpStore.MarkVerified(ctx, stored.ID)
+	}
+	return nil
+}
+
+// DeleteTOTP removes the account's TOTP secret. Once the secret is verified
+// this takes a current TOTP code or an unused recovery code, so that a stolen
+// session alone cannot turn MFA off.
+func (s *authService) DeleteTOTP(ctx context.Context, accountID, code string) error {
+	tenantID, err := middleware.TenantIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	stored, err := s.totpStore.GetByIdentity(ctx, tenantID, accountID)
+	if err != nil {
+		return err
+	}
+	if stored == nil {
+		return ErrTOTPNotEnrolled
+	}
+	if stored.Verified {
+		if err := s.checkSecondFactor(ctx, tenantID, accountID, code); err != nil {
+			return err
+		}
+	}
+	return s.totpStore.Delete(ctx, tenantID, accountID)
+}
+
+// MFAChallenge returns a short-lived challenge token when the session's user
+// has a verified second factor, or "" when the session token can be used
+// directly. The session token itself is never handed out while MFA is pending.
+// Factors are keyed by user ID; one enrolled under the login name before that
+// is still enforced.
+func (s *authService) MFAChallenge(ctx context.Context, login, sessionToken string) (string, error) {
+	tenantID, err := middleware.TenantIDFromContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	session, err := s.parseSignedToken(sessionToken)
+	if err != nil {
+		return "", err
+	}
+	subject, _ := session["sub"].(string)
+
+	accountID := subject
+	stored, err := s.totpStore.GetByIdentity(ctx, tenantID, subject)
+	if err != nil {
+		return "", err
+	}
+	if (stored == nil || !stored.Verified) && login != "" && login != subject {
+		accountID = login
+		if stored, err = s.totpStore.GetByIdentity(ctx, tenantID, login); err != nil {
+			return "", err
+		}
+	}
+	if stored == nil || !stored.Verified {
+		return "", nil
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub":         subject,
+		"iss":         "identity-platform",
+		"aud":         mfaChallengeType,
+		"typ":         mfaChallengeType,
+		"mfa_account": accountID,
+		"login":       login,
+		"tenant":      tenantID,
+		"jti":         uuid.New().String(),
+		"iat":         now.Unix(),
+		"exp":         now.Add(mfaChallengeTTL).Unix(),
+	}
+	return s.signingKeys.sign(claims)
+}
+
+// ParseMFAChallenge returns the login an unused, unexpired MFA challenge
+// token was issued for, or ErrInvalidMFAChallenge.
+func (s *authService) ParseMFAChallenge(ctx context.Context, challenge string) (*PendingMFALogin, error) {
+	tenantID, err := middleware.TenantIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := s.parseSignedToken(challenge, jwt.WithAudience(mfaChallengeType))
+	if err != nil {
+		return nil, ErrInvalidMFAChallenge
+	}
+	pending := &PendingMFALogin{}
+	pending.subject, _ = claims["sub"].(string)
+	pending.accountID, _ = claims["mfa_account"].(string)
+	pending.ChallengeID, _ = claims["jti"].(string)
+	pending.Login, _ = claims["login"].(string)
+	if claims["typ"] != mfaChallengeType || claims["tenant"] != tenantID || pending.subject == "" || pending.accountID == "" || pending.ChallengeID == "" {
+		return nil, ErrInvalidMFAChallenge
+	}
+	if pending.Login == "" {
+		pending.Login = pending.subject
+	}
+	if revoked, err := s.revokedTokens.IsRevoked(ctx, pending.ChallengeID); err != nil {
+		return nil, err
+	} else if revoked {
+		return nil, ErrInvalidMFAChallenge
+	}
+	return pending, nil
+}
+
+// RevokeMFAChallenge ends an MFA challenge before it expires.
+func (s *authService) RevokeMFAChallenge(ctx context.Context, challengeID string) error {
+	return s.revokedTokens.Revoke(ctx, challengeID)
+}
+
+// CompleteMFALogin exchanges an MFA challenge token and a TOTP code (or an
+// unused recovery code) for a session token.
+func (s *authService) CompleteMFALogin(ctx context.Context, challenge, code string) (string, error) {
+	pending, err := s.ParseMFAChallenge(ctx, challenge)
+	if err != nil {
+		return "", err
+	}
+	tenantID, err := middleware.TenantIDFromContext(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.checkSecondFactor(ctx, tenantID, pending.accountID, code); err != nil {
+		return "", err
+	}
+	// A challenge can only be exchanged once.
+	if err := s.RevokeMFAChallenge(ctx, pending.ChallengeID); err != nil {
+		return "", err
+	}
+	return s.generateUserToken(tenantID, pending.subject)
+}
+
+// checkSecondFactor accepts a current TOTP code or, failing that, an unused
+// recovery code for the account, consuming whichever matched.
+func (s *authService) checkSecondFactor(ctx context.Context, tenantID, accountID, code string) error {
+	_, err := s.checkTOTPCode(ctx, tenantID, accountID, code)
+	if err == nil || !errors.Is(err, ErrInvalidTOTPCode) {
+		return err
+	}
+	recovered, err := s.consumeRecoveryCode(ctx, tenantID, accountID, code)
+	if err != nil {
+		return err
+	}
+	if !recovered {
+		return ErrInvalidTOTPCode
+	}
+	return nil
+}
+
+// checkTOTPCode validates code against the account's secret within the skew
+// window and consumes its time step so the same code cannot be replayed.
+func (s *authService) checkTOTPCode(ctx context.Context, tenantID, accountID, code string) (*TOTPSecret, error) {
+	stored, err := s.totpStore.GetByIdentity(ctx, tenantID, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		return nil, ErrTOTPNotEnrolled
+	}
+	secret, err := s.totpCipher.Decrypt(stored.Secret, totpAdditionalData(tenantID, accountID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt TOTP secret: %w", err)
+	}
+
+	step, ok := matchTOTPStep(secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidTOTPCode
+	}
+	fresh, err := s.totpStore.MarkUsed(ctx, stored.ID, step)
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		return nil, ErrInvalidTOTPCode
+	}
+	return stored, nil
+}
+
+// matchTOTPStep returns the time step whose code equals code, searching
+// totpSkew periods either side of now.
+func matchTOTPStep(secret, code string, now time.Time) (int64, bool) {
+	current := now.Unix() / totpPeriod
+	for offset := -totpSkew; offset <= totpSkew; offset++ {
+		step := current + int64(offset)
+		expected, err := totp.GenerateCodeCustom(secret, time.Unix(step*totpPeriod, 0), totp.ValidateOpts{
+			Period:    totpPeriod,
+			Digits:    otp.DigitsSix,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// parseSignedToken verifies a JWT issued by this service and returns its claims.
+func (s *authService) parseSignedToken(tokenString string, opts ...jwt.ParserOption) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, s.signingKeys.keyFunc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+	return claims, nil
+}
+
+func totpAdditionalData(tenantID, accountID string) string {
+	return tenantID + "/" + accountID
+}
@@ -0,0 +1,275 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/pquerna/otp/totp"
+	"go.uber.org/zap"
+)
+
+const totpTestTenant = "11111111-1111-1111-1111-111111111111"
+
+func TestEnrollTOTPStoresEncryptedSecret(t *testing.T) {
+	as := newTestService(t)
+	ctx := contextWithTenant(t, totpTestTenant)
+
+	enrollment, err := as.EnrollTOTP(ctx, "user-1")
+	if err != nil {
+		t.Fatalf("enroll error: %v", err)
+	}
+	if enrollment.Key.Secret() == "" || enrollment.Key.URL() == "" {
+		t.Fatalf("expected secret and otpauth URL")
+	}
+
+	stored, err := as.totpStore.GetByIdentity(ctx, totpTestTenant, "user-1")
+	if err != nil || stored == nil {
+		t.Fatalf("expected stored secret, got %v, %v", stored, err)
+	}
+	if stored.Secret == enrollment.Key.Secret() {
+		t.Fatalf("expected secret to be encrypted at rest")
+	}
+	if stored.Verified {
+		t.Fatalf("expected enrollment to be unverified")
+	}
+}
+
+func TestVerifyTOTPMarksEnrollmentVerified(t *testing.T) {
+	as := newTestService(t)
+	ctx := contextWithTenant(t, totpTestTenant)
+
+	enrollment, err := as.EnrollTOTP(ctx, "user-1")
+	if err != nil {
+		t.Fatalf("enroll error: %v", err)
+	}
+	code, err := totp.GenerateCode(enrollment.Key.Secret(), time.Now())
+	if err != nil {
+		t.Fatalf("generate code: %v", err)
+	}
+	if err := as.VerifyTOTP(ctx, "user-1", code); err != nil {
+		t.Fatalf("verify error: %v", err)
+	}
+
+	stored, _ := as.totpStore.GetByIdentity(ctx, totpTestTenant, "user-1")
+	if !stored.Verified {
+		t.Fatalf("expected enrollment to be verified")
+	}
+}
+
+func TestVerifyTOTPRejectsReusedCode(t *testing.T) {
+	as := newTestService(t)
+	ctx := contextWithTenant(t, totpTestTenant)
+
+	enrollment, err := as.EnrollTOTP(ctx, "user-1")
+	if err != nil {
+		t.Fatalf("enroll error: %v", err)
+	}
+	code, _ := totp.GenerateCode(enrollment.Key.Secret(), time.Now())
+	if err := as.VerifyTOTP(ctx, "user-1", code); err != nil {
+		t.Fatalf("first verify error: %v", err)
+	}
+	if err := as.VerifyTOTP(ctx, "user-1", code); !errors.Is(err, ErrInvalidTOTPCode) {
+		t.Fatalf("expected ErrInvalidTOTPCode for reused code, got %v", err)
+	}
+}
+
+func TestMFAChallengeMustBeExchangedWithCode(t *testing.T) {
+	as := newTestService(t)
+	ctx := contextWithTenant(t, totpTestTenant)
+
+	session, err := as.generateUserToken(totpTestTenant, "user-1")
+	if err != nil {
+		t.Fatalf("session token: %v", err)
+	}
+	challenge, err := as.MFAChallenge(ctx, "alice@example.com", session)
+	if err != nil || challenge != "" {
+		t.Fatalf("expected no challenge before enrollment, got %q, %v", challenge, err)
+	}
+
+	enrollment, _ := as.EnrollTOTP(ctx, "user-1")
+	// Confirm enrollment with the previous period's code so the current one stays unused.
+	previous, _ := totp.GenerateCode(enrollment.Key.Secret(), time.Now().Add(-totpPeriod*time.Second))
+	if err := as.VerifyTOTP(ctx, "user-1", previous); err != nil {
+		t.Fatalf("verify error: %v", err)
+	}
+
+	challenge, err = as.MFAChallenge(ctx, "alice@example.com", session)
+	if err != nil || challenge == "" {
+		t.Fatalf("expected challenge after enrollment, got %q, %v", challenge, err)
+	}
+	if challenge == session {
+		t.Fatalf("challenge must not be the session token")
+	}
+
+	code, _ := totp.GenerateCode(enrollment.Key.Secret(), time.Now())
+	token, err := as.CompleteMFALogin(ctx, challenge, code)
+	if err != nil {
+		t.Fatalf("complete MFA error: %v", err)
+	}
+	claims, err := as.parseSignedToken(token)
+	if err != nil || claims["sub"] != "user-1" {
+		t.Fatalf("expected session token for user-1, got %v, %v", claims, err)
+	}
+
+	if _, err := as.CompleteMFALogin(ctx, challenge, code); err == nil {
+		t.Fatalf("expected challenge reuse to be rejected")
+	}
+}
+
+func TestRecoveryCodeWorksExactlyOnce(t *testing.T) {
+	as := newTestService(t)
+	ctx := contextWithTenant(t, totpTestTenant)
+	enrollment := enrollVerifiedTOTP(t, as, "user-1")
+
+	if len(enrollment.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("expected %d recovery codes, got %d", recoveryCodeCount, len(enrollment.RecoveryCodes))
+	}
+	code := enrollment.RecoveryCodes[0]
+	if _, err := as.CompleteMFALogin(ctx, newMFAChallenge(t, as, "user-1"), code); err != nil {
+		t.Fatalf("expected recovery code to be accepted: %v", err)
+	}
+	if _, err := as.CompleteMFALogin(ctx, newMFAChallenge(t, as, "user-1"), code); !errors.Is(err, ErrInvalidTOTPCode) {
+		t.Fatalf("expected used recovery code to be rejected, got %v", err)
+	}
+}
+
+func TestRegenerateRecoveryCodesInvalidatesPrevious(t *testing.T) {
+	as := newTestService(t)
+	ctx := contextWithTenant(t, totpTestTenant)
+	enrollment := enrollVerifiedTOTP(t, as, "user-1")
+
+	if _, err := as.RegenerateRecoveryCodes(ctx, "user-1", "000000"); !errors.Is(err, ErrInvalidTOTPCode) {
+		t.Fatalf("expected a second factor to be required, got %v", err)
+	}
+	code, _ := totp.GenerateCode(enrollment.Key.Secret(), time.Now().Add(totpPeriod*time.Second))
+	fresh, err := as.RegenerateRecoveryCodes(ctx, "user-1", code)
+	if err != nil {
+		t.Fatalf("regenerate error: %v", err)
+	}
+	challenge := newMFAChallenge(t, as, "user-1")
+	if _, err := as.CompleteMFALogin(ctx, challenge, enrollment.RecoveryCodes[0]); !errors.Is(err, ErrInvalidTOTPCode) {
+		t.Fatalf("expected old recovery code to be rejected, got %v", err)
+	}
+	if _, err := as.CompleteMFALogin(ctx, challenge, fresh[0]); err != nil {
+		t.Fatalf("expected new recovery code to be accepted: %v", err)
+	}
+}
+
+func enrollVerifiedTOTP(t *testing.T, as *authService, accountID string) *TOTPEnrollment {
+	t.Helper()
+	ctx := contextWithTenant(t, totpTestTenant)
+	enrollment, err := as.EnrollTOTP(ctx, accountID)
+	if err != nil {
+		t.Fatalf("enroll error: %v", err)
+	}
+	code, _ := totp.GenerateCode(enrollment.Key.Secret(), time.Now())
+	if err := as.VerifyTOTP(ctx, accountID, code); err != nil {
+		t.Fatalf("verify error: %v", err)
+	}
+	return enrollment
+}
+
+func newMFAChallenge(t *testing.T, as *authService, accountID string) string {
+	t.Helper()
+	ctx := contextWithTenant(t, totpTestTenant)
+	session, err := as.generateUserToken(totpTestTenant, accountID)
+	if err != nil {
+		t.Fatalf("session token: %v", err)
+	}
+	challenge, err := as.MFAChallenge(ctx, "alice@example.com", session)
+	if err != nil || challenge == "" {
+		t.Fatalf("expected MFA challenge, got %q, %v", challenge, err)
+	}
+	return challenge
+}
+
+func TestEnrollTOTPKeepsVerifiedSecret(t *testing.T) {
+	as := newTestService(t)
+	ctx := contextWithTenant(t, totpTestTenant)
+	enrollment := enrollVerifiedTOTP(t, as, "user-1")
+
+	if _, err := as.EnrollTOTP(ctx, "user-1"); !errors.Is(err, ErrTOTPAlreadyEnrolled) {
+		t.Fatalf("expected ErrTOTPAlreadyEnrolled, got %v", err)
+	}
+	// The verified secret still gates login.
+	code, _ := totp.GenerateCode(enrollment.Key.Secret(), time.Now().Add(totpPeriod*time.Second))
+	if _, err := as.CompleteMFALogin(ctx, newMFAChallenge(t, as, "user-1"), code); err != nil {
+		t.Fatalf("expected the original secret to still work: %v", err)
+	}
+
+	if err := as.DeleteTOTP(ctx, "user-1", ""); !errors.Is(err, ErrInvalidTOTPCode) {
+		t.Fatalf("expected deleting a verified secret to need a code, got %v", err)
+	}
+	if err := as.DeleteTOTP(ctx, "user-1", enrollment.RecoveryCodes[0]); err != nil {
+		t.Fatalf("delete with recovery code error: %v", err)
+	}
+	if _, err := as.EnrollTOTP(ctx, "user-1"); err != nil {
+		t.Fatalf("expected enrollment after removal, got %v", err)
+	}
+}
+
+func TestTOTPRoutesActOnSessionUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTestService(t)
+	router := gin.New()
+	NewHTTPHandler(as, zap.NewNop(), nil).RegisterRoutes(router)
+	session, err := as.generateUserToken(totpTestTenant, "user-1")
+	if err != nil {
+		t.Fatalf("session token: %v", err)
+	}
+
+	post := func(path, body, cookie string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(middleware.DefaultTenantHeader, totpTestTenant)
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: AccessTokenCookie, Value: cookie})
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, path := range []string{"/api/v1/mfa/totp/enroll", "/api/v1/mfa/totp/recovery-codes"} {
+		if w := post(path, `{"user_id":"user-2","code":"000000"}`, ""); w.Code != http.StatusUnauthorized {
+			t.Fatalf("%s: expected 401 without a session, got %d %s", path, w.Code, w.Body)
+		}
+	}
+	if w := post("/api/v1/mfa/totp/enroll", `{"user_id":"user-2"}`, session); w.Code != http.StatusOK {
+		t.Fatalf("expected enrollment with a session, got %d %s", w.Code, w.Body)
+	}
+	ctx := contextWithTenant(t, totpTestTenant)
+	if stored, _ := as.totpStore.GetByIdentity(ctx, totpTestTenant, "user-2"); stored != nil {
+		t.Fatalf("expected user_id in the body to be ignored")
+	}
+	if stored, _ := as.totpStore.GetByIdentity(ctx, totpTestTenant, "user-1"); stored == nil {
+		t.Fatalf("expected the session user to be enrolled")
+	}
+	if w := post("/api/v1/mfa/totp/recovery-codes", `{"code":"000000"}`, session); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected recovery codes to need a valid code, got %d %s", w.Code, w.Body)
+	}
+}
+
+func TestMFAChallengeEnforcesFactorEnrolledUnderLogin(t *testing.T) {
+	as := newTestService(t)
+	ctx := contextWithTenant(t, totpTestTenant)
+	enrollment := enrollVerifiedTOTP(t, as, "alice@example.com")
+
+	session, err := as.generateUserToken(totpTestTenant, "user-1")
+	if err != nil {
+		t.Fatalf("session token: %v", err)
+	}
+	challenge, err := as.MFAChallenge(ctx, "alice@example.com", session)
+	if err != nil || challenge == "" {
+		t.Fatalf("expected a challenge for the login's factor, got %q, %v", challenge, err)
+	}
+	if _, err := as.CompleteMFALogin(ctx, challenge, enrollment.RecoveryCodes[0]); err != nil {
+		t.Fatalf("complete MFA error: %v", err)
+	}
+}
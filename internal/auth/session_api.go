@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// listSessions handles GET /api/v1/sessions, optionally filtered by ?subject=.
+func (h *HTTPHandler) listSessions(c *gin.Context) {
+	tenantID, err := middleware.TenantIDFromGinContext(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing tenant context"})
+		return
+	}
+
+	sessions, err := h.svc.ListSessions(c.Request.Context(), tenantID, c.Query("subject"))
+	if err != nil {
+		h.logger.Error("Failed to list sessions", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list sessions"})
+		return
+	}
+	if sessions == nil {
+		sessions = []Session{}
+	}
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// revokeSession handles DELETE /api/v1/sessions/{id}.
+func (h *HTTPHandler) revokeSession(c *gin.Context) {
+	tenantID, err := middleware.TenantIDFromGinContext(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing tenant context"})
+		return
+	}
+
+	id := c.Param("id")
+	session, err := h.svc.RevokeSession(c.Request.Context(), tenantID, id)
+	if err != nil {
+		if errors.Is(err, ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+			return
+		}
+		h.logger.Error("Failed to revoke session", zap.String("session_id", id), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke session"})
+		return
+	}
+
+	h.recordAuthEvent(c, AuthEvent{EventType: AuthEventSessionRevoked, Subject: session.Subject, ClientID: session.ClientID})
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrSessionNotFound is returned when the tenant has no such session.
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session is a signed-in device or application: one refresh token family,
+// from the grant that started it through every rotation. Its ID is the
+// family ID, which ID tokens carry as sid.
+type Session struct {
+	ID         string    `json:"id" db:"id"`
+	TenantID   string    `json:"tenant_id" db:"tenant_id"`
+	Subject    string    `json:"subject,omitempty" db:"subject"`
+	ClientID   string    `json:"client_id" db:"client_id"`
+	UserAgent  string    `json:"user_agent,omitempty" db:"user_agent"`
+	IPAddress  string    `json:"ip_address,omitempty" db:"ip_address"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at" db:"last_seen_at"`
+}
+
+// SessionStore persists sessions next to their refresh token families.
+type SessionStore interface {
+	Create(ctx context.Context, session Session) error
+	// Touch records that the session's refresh token was redeemed at seenAt,
+	// updating the user agent and IP address when they are set.
+	Touch(ctx context.Context, tenantID, id, userAgent, ipAddress string, seenAt time.Time) error
+	Get(ctx context.Context, tenantID, id string) (Session, bool, error)
+	// List returns the tenant's sessions seen at or after activeSince, most
+	// recently seen first. An empty subject lists every user's sessions.
+	List(ctx context.Context, tenantID, subject string, activeSince time.Time) ([]Session, error)
+	Delete(ctx context.Context, tenantID, id string) error
+}
+
+type sessionRepo struct {
+	db *sqlx.DB
+}
+
+// NewSessionStore creates a new SQL-backed session store.
+func NewSessionStore(db *sqlx.DB) SessionStore {
+	return &sessionRepo{db: db}
+}
+
+func (r *sessionRepo) Create(ctx context.Context, session Session) error {
+	query := `
+		INSERT INTO auth_sessions (id, tenant_id, subject, client_id, user_agent, ip_address, created_at, last_seen_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+	`
+	_, err := r.db.ExecContext(ctx, query, session.ID, session.TenantID, session.Subject, session.ClientID,
+		session.UserAgent, session.IPAddress, session.CreatedAt)
+	return err
+}
+
+func (r *sessionRepo) Touch(ctx context.Context, tenantID, id, userAgent, ipAddress string, seenAt time.Time) error {
+	query := `
+		UPDATE auth_sessions
+		SET last_seen_at = $3,
+			user_agent = COALESCE(NULLIF($4, ''), user_agent),
+			ip_address = COALESCE(NULLIF($5, ''), ip_address)
+		WHERE tenant_id = $1 AND id = $2
+	`
+	_, err := r.db.ExecContext(ctx, query, tenantID, id, seenAt, userAgent, ipAddress)
+	return err
+}
+
+func (r *sessionRepo) Get(ctx context.Context, tenantID, id string) (Session, bool, error) {
+	var session Session
+	query := `
+		SELECT id, tenant_id, subject, client_id, user_agent, ip_address, created_at, last_seen_at
+		FROM auth_sessions WHERE tenant_id = $1 AND id = $2
+	`
+	if err := r.db.GetContext(ctx, &session, query, tenantID, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Session{}, false, nil
+		}
+		return Session{}, false, err
+	}
+	return session, true, nil
+}
+
+func (r *sessionRepo) List(ctx context.Context, tenantID, subject string, activeSince time.Time) ([]Session, error) {
+	var sessions []Session
+	query := `
+		SELECT id, tenant_id, subject, client_id, user_agent, ip_address, created_at, last_seen_at
+		FROM auth_sessions
+		WHERE tenant_id = $1 AND ($2 = '' OR subject = $2) AND last_seen_at >= $3
+		ORDER BY last_seen_at DESC, id
+	`
+	if err := r.db.SelectContext(ctx, &sessions, query, tenantID, subject, activeSince); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (r *sessionRepo) Delete(ctx context.Context, tenantID, id string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM auth_sessions WHERE tenant_id = $1 AND id = $2`, tenantID, id)
+	return err
+}
+
+// sessionMemoryStore is an in-memory SessionStore used when no database is configured.
+type sessionMemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]Session
+}
+
+func newSessionMemoryStore() *sessionMemoryStore {
+	return &sessionMemoryStore{sessions: make(map[string]Session)}
+}
+
+func (s *sessionMemoryStore) Create(ctx context.Context, session Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session.LastSeenAt = session.CreatedAt
+	s.sessions[session.ID] = session
+	return nil
+}
+
+func (s *sessionMemoryStore) Touch(ctx context.Context, tenantID, id, userAgent, ipAddress string, seenAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[id]
+	if !ok || session.TenantID != tenantID {
+		return nil
+	}
+	session.LastSeenAt = seenAt
+	if userAgent != "" {
+		session.UserAgent = userAgent
+	}
+	if ipAddress != "" {
+		session.IPAddress = ipAddress
+	}
+	s.sessions[id] = session
+	return nil
+}
+
+func (s *sessionMemoryStore) Get(ctx context.Context, tenantID, id string) (Session, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[id]
+	if !ok || session.TenantID != tenantID {
+		return Session{}, false, nil
+	}
+	return session, true, nil
+}
+
+func (s *sessionMemoryStore) List(ctx context.Context, tenantID, subject string, activeSince time.Time) ([]Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var sessions []Session
+	for _, session := range s.sessions {
+		if session.TenantID != tenantID || (subject != "" && session.Subject != subject) || session.LastSeenAt.Before(activeSince) {
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].LastSeenAt.Equal(sessions[j].LastSeenAt) {
+			return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+		}
+		return sessions[i].ID < sessions[j].ID
+	})
+	return sessions, nil
+}
+
+func (s *sessionMemoryStore) Delete(ctx context.Context, tenantID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if session, ok := s.sessions[id]; ok && session.TenantID == tenantID {
+		delete(s.sessions, id)
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const sessionTestTenant = "11111111-1111-1111-1111-111111111111"
+
+// permissionGrants grants each user the listed "resource:action" permissions.
+type permissionGrants map[string][]string
+
+func (g permissionGrants) HasPermission(ctx context.Context, tenantID, userID, resource, action string) (bool, error) {
+	return slices.Contains(g[userID], resource+":"+action), nil
+}
+
+// signInFrom runs the authorization code flow for userID from the given
+// device and returns the tokens it issues.
+func signInFrom(t *testing.T, as *authService, ctx context.Context, userID, userAgent, ip string) TokenResponse {
+	t.Helper()
+	session, err := as.generateUserToken(sessionTestTenant, userID)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	verifier := "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNO1234567890abcd"
+	authResp, err := as.Authorize(ctx, AuthorizeRequest{
+		ResponseType:  "code",
+		ClientID:      "test-client",
+		RedirectURI:   "https://app.wardseal.com/callback",
+		Scope:         "openid profile",
+		CodeChallenge: pkceChallenge(verifier),
+		SessionToken:  session,
+	})
+	if err != nil {
+		t.Fatalf("authorize error: %v", err)
+	}
+	tokens, err := as.Token(ctx, TokenRequest{
+		GrantType:    "authorization_code",
+		Code:         extractCode(t, authResp.RedirectURI),
+		RedirectURI:  "https://app.wardseal.com/callback",
+		ClientID:     "test-client",
+		CodeVerifier: verifier,
+		ClientIP:     ip,
+		UserAgent:    userAgent,
+	})
+	if err != nil {
+		t.Fatalf("token error: %v", err)
+	}
+	return tokens
+}
+
+func TestListSessionsAndRevokeOne(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTestService(t)
+	ctx := contextWithTenant(t, sessionTestTenant)
+
+	laptop := signInFrom(t, as, ctx, "user-1", "Laptop/1.0", "203.0.113.10")
+	phone := signInFrom(t, as, ctx, "user-1", "Phone/2.0", "198.51.100.7")
+	signInFrom(t, as, ctx, "user-2", "Tablet/3.0", "192.0.2.1")
+
+	sessions, err := as.ListSessions(ctx, sessionTestTenant, "user-1")
+	if err != nil {
+		t.Fatalf("list sessions error: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("expected two sessions for user-1, got %+v", sessions)
+	}
+	byAgent := map[string]Session{}
+	for _, session := range sessions {
+		if session.Subject != "user-1" || session.ClientID != "test-client" || session.CreatedAt.IsZero() {
+			t.Fatalf("unexpected session %+v", session)
+		}
+		byAgent[session.UserAgent] = session
+	}
+	laptopSession, phoneSession := byAgent["Laptop/1.0"], byAgent["Phone/2.0"]
+	if laptopSession.IPAddress != "203.0.113.10" || phoneSession.IPAddress != "198.51.100.7" {
+		t.Fatalf("expected each session to keep its device's IP, got %+v", sessions)
+	}
+	claims, err := as.parseSignedToken(laptop.IDToken)
+	if err != nil || claims["sid"] != laptopSession.ID {
+		t.Fatalf("expected the ID token sid to name the session, got %v (%v)", claims["sid"], err)
+	}
+	all, err := as.ListSessions(ctx, sessionTestTenant, "")
+	if err != nil || len(all) != 3 {
+		t.Fatalf("expected three sessions in the tenant, got %d (%v)", len(all), err)
+	}
+
+	// Rotating a refresh token keeps the session and records where it was used.
+	rotated, err := as.Token(ctx, TokenRequest{GrantType: "refresh_token", RefreshToken: phone.RefreshToken, ClientIP: "198.51.100.99"})
+	if err != nil {
+		t.Fatalf("refresh error: %v", err)
+	}
+	sessions, _ = as.ListSessions(ctx, sessionTestTenant, "user-1")
+	if len(sessions) != 2 || sessions[0].ID != phoneSession.ID || sessions[0].IPAddress != "198.51.100.99" || sessions[0].UserAgent != "Phone/2.0" {
+		t.Fatalf("expected the refreshed phone session first with its new IP, got %+v", sessions)
+	}
+
+	revoked, err := as.RevokeSession(ctx, sessionTestTenant, laptopSession.ID)
+	if err != nil {
+		t.Fatalf("revoke session error: %v", err)
+	}
+	if revoked.ID != laptopSession.ID || revoked.Subject != "user-1" {
+		t.Fatalf("expected the laptop session to be returned, got %+v", revoked)
+	}
+	sessions, _ = as.ListSessions(ctx, sessionTestTenant, "user-1")
+	if len(sessions) != 1 || sessions[0].ID != phoneSession.ID {
+		t.Fatalf("expected only the phone session to remain, got %+v", sessions)
+	}
+	if _, err := as.Token(ctx, TokenRequest{GrantType: "refresh_token", RefreshToken: laptop.RefreshToken}); err == nil {
+		t.Fatalf("expected the revoked session's refresh token to be rejected")
+	}
+	if _, err := as.Token(ctx, TokenRequest{GrantType: "refresh_token", RefreshToken: rotated.RefreshToken}); err != nil {
+		t.Fatalf("expected the other session to keep working, got %v", err)
+	}
+
+	if _, err := as.RevokeSession(ctx, sessionTestTenant, laptopSession.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("expected ErrSessionNotFound for a revoked session, got %v", err)
+	}
+	if _, err := as.RevokeSession(ctx, "22222222-2222-2222-2222-222222222222", phoneSession.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("expected another tenant's session to be hidden, got %v", err)
+	}
+}
+
+func TestSessionEndpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTestService(t)
+	ctx := contextWithTenant(t, sessionTestTenant)
+	signInFrom(t, as, ctx, "user-1", "Laptop/1.0", "203.0.113.10")
+	signInFrom(t, as, ctx, "user-1", "Phone/2.0", "198.51.100.7")
+
+	admin, _ := as.generateUserToken(sessionTestTenant, "admin-1")
+	auditor, _ := as.generateUserToken(sessionTestTenant, "auditor-1")
+	router := gin.New()
+	handler := NewHTTPHandler(as, zap.NewNop(), nil)
+	handler.UsePermissions(permissionGrants{
+		"admin-1":   {"sessions:read", "sessions:delete"},
+		"auditor-1": {"sessions:read"},
+	})
+	handler.RegisterRoutes(router)
+	serveAs := func(bearer, method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set(middleware.DefaultTenantHeader, sessionTestTenant)
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+	serve := func(method, path string) *httptest.ResponseRecorder { return serveAs(admin, method, path) }
+
+	if resp := serveAs("", http.MethodGet, "/api/v1/sessions"); resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a session, got %d", resp.Code)
+	}
+	resp := serve(http.MethodGet, "/api/v1/sessions?subject=user-1")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var payload struct {
+		Sessions []Session `json:"sessions"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &payload); err != nil || len(payload.Sessions) != 2 {
+		t.Fatalf("expected two sessions, got %s (%v)", resp.Body.String(), err)
+	}
+
+	target := payload.Sessions[0].ID
+	if resp := serveAs(auditor, http.MethodDelete, "/api/v1/sessions/"+target); resp.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 without sessions:delete, got %d", resp.Code)
+	}
+	if resp := serve(http.MethodDelete, "/api/v1/sessions/"+target); resp.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if resp := serve(http.MethodDelete, "/api/v1/sessions/"+target); resp.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a revoked session, got %d", resp.Code)
+	}
+	events, err := as.AuthAudit().ListAuthEvents(context.Background(), AuthEventQuery{TenantID: sessionTestTenant, Subject: "user-1"})
+	if err != nil || len(events) != 1 || events[0].EventType != AuthEventSessionRevoked {
+		t.Fatalf("expected one session_revoked event, got %+v (%v)", events, err)
+	}
+}
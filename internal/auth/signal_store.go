@@ -2,6 +2,8 @@ package auth
 
 import (
 	"context"
+	"database/sql"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -69,3 +71,43 @@ func (r *signalRepo) GetLatestCriticalEvent(ctx context.Context, subjectID strin
 	}
 	return &event, nil
 }
+
+// signalMemoryStore is an in-memory SignalStore used when no database is configured.
+type signalMemoryStore struct {
+	mu     sync.RWMutex
+	events []SecurityEvent
+}
+
+func newSignalMemoryStore() *signalMemoryStore {
+	return &signalMemoryStore{}
+}
+
+func (s *signalMemoryStore) Ingest(ctx context.Context, event *SecurityEvent) error {
+	if event.ID == "" {
+		event.ID = uuid.New().String()
+	}
+	if event.EventTime.IsZero() {
+		event.EventTime = time.Now()
+	}
+	event.CreatedAt = time.Now()
+	s.mu.Lock()
+	s.events = append(s.events, *event)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *signalMemoryStore) GetLatestCriticalEvent(ctx context.Context, subjectID string, since time.Time) (*SecurityEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var latest *SecurityEvent
+	for i := range s.events {
+		event := s.events[i]
+		if event.SubjectID == subjectID && event.EventTime.After(since) && (latest == nil || event.EventTime.After(latest.EventTime)) {
+			latest = &event
+		}
+	}
+	if latest == nil {
+		return nil, sql.ErrNoRows
+	}
+	return latest, nil
+}
@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// storedSigningKey is a JWT signing key as persisted. PrivateKey is the
+// PKCS#1 PEM encoding, encrypted when a signing key encryption key is set.
+type storedSigningKey struct {
+	ID         string    `db:"id"`
+	PrivateKey string    `db:"private_key"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+// SigningKeyStore persists JWT signing keys so tokens survive restarts and
+// every auth service instance signs and verifies with the same keys.
+type SigningKeyStore interface {
+	// List returns the stored keys, newest first.
+	List(ctx context.Context) ([]storedSigningKey, error)
+	// Add stores key unless the newest stored key is no longer previousID
+	// (empty when none is stored yet), meaning another instance added one
+	// first. It reports whether key was stored.
+	Add(ctx context.Context, key storedSigningKey, previousID string) (bool, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// SQLSigningKeyStore implements persistent storage for JWT signing keys.
+type SQLSigningKeyStore struct {
+	db *sqlx.DB
+}
+
+// NewSQLSigningKeyStore creates a new SQL-backed signing key store.
+func NewSQLSigningKeyStore(db *sqlx.DB) *SQLSigningKeyStore {
+	return &SQLSigningKeyStore{db: db}
+}
+
+func (s *SQLSigningKeyStore) List(ctx context.Context) ([]storedSigningKey, error) {
+	var keys []storedSigningKey
+	err := s.db.SelectContext(ctx, &keys,
+		`SELECT id, private_key, created_at FROM jwt_signing_keys ORDER BY created_at DESC, id DESC`)
+	return keys, err
+}
+
+func (s *SQLSigningKeyStore) Add(ctx context.Context, key storedSigningKey, previousID string) (bool, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Instances rotating at the same time queue here, and all but the first
+	// find a newer key than the one they read.
+	if _, err := tx.ExecContext(ctx, `LOCK TABLE jwt_signing_keys IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+		return false, err
+	}
+	var newest string
+	err = tx.GetContext(ctx, &newest, `SELECT id FROM jwt_signing_keys ORDER BY created_at DESC, id DESC LIMIT 1`)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+	if newest != previousID {
+		return false, nil
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO jwt_signing_keys (id, private_key, created_at) VALUES ($1, $2, $3)`,
+		key.ID, key.PrivateKey, key.CreatedAt); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func (s *SQLSigningKeyStore) Delete(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM jwt_signing_keys WHERE id = $1`, id)
+	return err
+}
@@ -0,0 +1,298 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dhawalhost/wardseal/pkg/secretbox"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gopkg.in/go-jose/go-jose.v2"
+)
+
+// DefaultRetainedSigningKeys is how many retired signing keys stay trusted for
+// verification after a rotation, so tokens issued before it remain valid.
+const DefaultRetainedSigningKeys = 2
+
+const (
+	// signingKeyRefreshInterval is how often instances reload stored keys,
+	// picking up rotations made by other instances.
+	signingKeyRefreshInterval = time.Minute
+	// signingKeyPropagationDelay is how long a stored key is only published
+	// and trusted before it signs, so every instance and JWKS cache knows it
+	// by the time tokens carry its kid.
+	signingKeyPropagationDelay = 2 * signingKeyRefreshInterval
+)
+
+type signingKey struct {
+	id        string
+	key       *rsa.PrivateKey
+	createdAt time.Time
+}
+
+// signingKeySet holds the active JWT signing key and the retired keys that are
+// still published in JWKS and accepted when verifying tokens.
+type signingKeySet struct {
+	mu          sync.RWMutex
+	active      signingKey
+	upcoming    []signingKey // stored keys not yet signing, newest first
+	retired     []signingKey // newest first
+	maxRetired  int
+	generateKey func() (*rsa.PrivateKey, error)
+	// store, when set, holds the keys shared by all instances; private keys
+	// are sealed with cipher.
+	store  SigningKeyStore
+	cipher *secretbox.Cipher
+	now    func() time.Time
+}
+
+func newSigningKeySet(initial *rsa.PrivateKey, maxRetired int) *signingKeySet {
+	if maxRetired <= 0 {
+		maxRetired = DefaultRetainedSigningKeys
+	}
+	return &signingKeySet{
+		active:     signingKey{id: uuid.New().String(), key: initial, createdAt: time.Now()},
+		maxRetired: maxRetired,
+		generateKey: func() (*rsa.PrivateKey, error) {
+			return rsa.GenerateKey(rand.Reader, 2048)
+		},
+		now: time.Now,
+	}
+}
+
+// sign signs claims with the active key and stamps its kid in the header.
+func (ks *signingKeySet) sign(claims jwt.Claims) (string, error) {
+	ks.mu.RLock()
+	active := ks.active
+	ks.mu.RUnlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = active.id
+	return token.SignedString(active.key)
+}
+
+// keyFunc resolves the verification key from the token's kid. Tokens without a
+// kid are checked against the active key.
+func (ks *signingKeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	kid, _ := token.Header["kid"].(string)
+
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	if kid == "" || kid == ks.active.id {
+		return &ks.active.key.PublicKey, nil
+	}
+	for _, k := range ks.trustedLocked() {
+		if k.id == kid {
+			return &k.key.PublicKey, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// trustedLocked lists every key that verifies tokens, newest first. The
+// caller holds mu.
+func (ks *signingKeySet) trustedLocked() []signingKey {
+	keys := make([]signingKey, 0, len(ks.upcoming)+1+len(ks.retired))
+	keys = append(keys, ks.upcoming...)
+	keys = append(keys, ks.active)
+	return append(keys, ks.retired...)
+}
+
+// rotate promotes a freshly generated key to active and retires the previous
+// one, dropping the oldest retired keys beyond maxRetired. With a store the
+// key is saved and starts signing after signingKeyPropagationDelay; if
+// another instance rotated first, its key is kept instead.
+func (ks *signingKeySet) rotate(ctx context.Context) (string, error) {
+	key, err := ks.generateKey()
+	if err != nil {
+		return "", err
+	}
+	next := signingKey{id: uuid.New().String(), key: key, createdAt: ks.now()}
+
+	if ks.store != nil {
+		ks.mu.RLock()
+		newest := ks.trustedLocked()[0].id
+		ks.mu.RUnlock()
+		if _, err := ks.add(ctx, next, newest); err != nil {
+			return "", err
+		}
+		if err := ks.load(ctx); err != nil {
+			return "", err
+		}
+		ks.mu.RLock()
+		defer ks.mu.RUnlock()
+		return ks.trustedLocked()[0].id, nil
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.retired = append([]signingKey{ks.active}, ks.retired...)
+	if len(ks.retired) > ks.maxRetired {
+		ks.retired = ks.retired[:ks.maxRetired]
+	}
+	ks.active = next
+	return next.id, nil
+}
+
+// rotateIfDue rotates when the newest key is older than every.
+func (ks *signingKeySet) rotateIfDue(ctx context.Context, every time.Duration) (bool, error) {
+	ks.mu.RLock()
+	newest := ks.trustedLocked()[0]
+	ks.mu.RUnlock()
+	if ks.now().Sub(newest.createdAt) < every {
+		return false, nil
+	}
+	_, err := ks.rotate(ctx)
+	return err == nil, err
+}
+
+// useStore shares the keys through store, sealing private keys with cipher
+// (nil stores them in plaintext). The keys are read by load.
+func (ks *signingKeySet) useStore(store SigningKeyStore, cipher *secretbox.Cipher) {
+	ks.store = store
+	ks.cipher = cipher
+}
+
+// load replaces the keys with the stored ones, saving the current active key
+// first when none are stored yet. The newest key older than
+// signingKeyPropagationDelay signs; retired keys beyond maxRetired are
+// deleted.
+func (ks *signingKeySet) load(ctx context.Context) error {
+	stored, err := ks.store.List(ctx)
+	if err != nil {
+		return err
+	}
+	if len(stored) == 0 {
+		ks.mu.RLock()
+		active := ks.active
+		ks.mu.RUnlock()
+		if _, err := ks.add(ctx, active, ""); err != nil {
+			return err
+		}
+		if stored, err = ks.store.List(ctx); err != nil {
+			return err
+		}
+		if len(stored) == 0 {
+			return errors.New("no signing key was stored")
+		}
+	}
+
+	keys := make([]signingKey, len(stored))
+	for i, s := range stored {
+		if keys[i], err = ks.open(s); err != nil {
+			return err
+		}
+	}
+	// Fall back to the oldest key while every key is still propagating.
+	activeAt := len(keys) - 1
+	for i, k := range keys {
+		if ks.now().Sub(k.createdAt) >= signingKeyPropagationDelay {
+			activeAt = i
+			break
+		}
+	}
+	retired := keys[activeAt+1:]
+	if len(retired) > ks.maxRetired {
+		for _, k := range retired[ks.maxRetired:] {
+			if err := ks.store.Delete(ctx, k.id); err != nil {
+				return err
+			}
+		}
+		retired = retired[:ks.maxRetired]
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.upcoming = keys[:activeAt]
+	ks.active = keys[activeAt]
+	ks.retired = retired
+	return nil
+}
+
+func (ks *signingKeySet) add(ctx context.Context, k signingKey, previousID string) (bool, error) {
+	encoded := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k.key)})
+	sealed, err := ks.cipher.Encrypt(string(encoded), signingKeyAdditionalData(k.id))
+	if err != nil {
+		return false, err
+	}
+	return ks.store.Add(ctx, storedSigningKey{ID: k.id, PrivateKey: sealed, CreatedAt: k.createdAt}, previousID)
+}
+
+func (ks *signingKeySet) open(s storedSigningKey) (signingKey, error) {
+	encoded, err := ks.cipher.Decrypt(s.PrivateKey, signingKeyAdditionalData(s.ID))
+	if err != nil {
+		return signingKey{}, fmt.Errorf("signing key %s: %w", s.ID, err)
+	}
+	block, _ := pem.Decode([]byte(encoded))
+	if block == nil {
+		return signingKey{}, fmt.Errorf("signing key %s: invalid PEM", s.ID)
+	}
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return signingKey{}, fmt.Errorf("signing key %s: %w", s.ID, err)
+	}
+	return signingKey{id: s.ID, key: key, createdAt: s.CreatedAt}, nil
+}
+
+// signingKeyAdditionalData binds a sealed private key to its kid.
+func signingKeyAdditionalData(id string) string {
+	return "jwt-signing-key:" + id
+}
+
+// run reloads stored keys every signingKeyRefreshInterval and rotates once
+// the newest key is older than every, until ctx is done. every <= 0 only
+// reloads.
+func (ks *signingKeySet) run(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(signingKeyRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if ks.store != nil {
+			if err := ks.load(ctx); err != nil {
+				zap.L().Warn("Failed to reload signing keys", zap.Error(err))
+				continue
+			}
+		}
+		if every <= 0 {
+			continue
+		}
+		if rotated, err := ks.rotateIfDue(ctx, every); err != nil {
+			zap.L().Error("Failed to rotate signing key", zap.Error(err))
+		} else if rotated {
+			zap.L().Info("Rotated JWT signing key")
+		}
+	}
+}
+
+// jwks publishes the public halves of the active and retired keys, and of
+// stored keys about to become active.
+func (ks *signingKeySet) jwks() jose.JSONWebKeySet {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	trusted := ks.trustedLocked()
+	keys := make([]jose.JSONWebKey, 0, len(trusted))
+	for _, k := range trusted {
+		keys = append(keys, jose.JSONWebKey{
+			Key:       &k.key.PublicKey,
+			KeyID:     k.id,
+			Algorithm: "RS256",
+			Use:       "sig",
+		})
+	}
+	return jose.JSONWebKeySet{Keys: keys}
+}
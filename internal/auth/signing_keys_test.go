@@ -0,0 +1,213 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dhawalhost/wardseal/pkg/secretbox"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestRotateSigningKeySignsWithNewKey(t *testing.T) {
+	as := newTestService(t)
+
+	before := as.JWKS().Keys[0].KeyID
+	newKID, err := as.RotateSigningKey(context.Background())
+	if err != nil {
+		t.Fatalf("rotate error: %v", err)
+	}
+	if newKID == before {
+		t.Fatalf("expected a new key id after rotation")
+	}
+
+	token, err := as.generateUserToken("11111111-1111-1111-1111-111111111111", "user-1")
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		t.Fatalf("failed to parse token: %v", err)
+	}
+	if kid := parsed.Header["kid"]; kid != newKID {
+		t.Fatalf("expected token signed with kid %s, got %v", newKID, kid)
+	}
+
+	keys := as.JWKS().Keys
+	if len(keys) != 2 || keys[0].KeyID != newKID || keys[1].KeyID != before {
+		t.Fatalf("expected JWKS to publish active and retired keys, got %d keys", len(keys))
+	}
+}
+
+func TestTokenSignedByRetiredKeyStillVerifies(t *testing.T) {
+	as := newTestService(t)
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+
+	token, err := as.generateUserToken("11111111-1111-1111-1111-111111111111", "user-1")
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	if _, err := as.RotateSigningKey(context.Background()); err != nil {
+		t.Fatalf("rotate error: %v", err)
+	}
+
+	resp, err := as.Introspect(ctx, IntrospectRequest{Token: token})
+	if err != nil {
+		t.Fatalf("introspect error: %v", err)
+	}
+	if !resp.Active {
+		t.Fatalf("expected token signed by a retired key to remain active")
+	}
+}
+
+func TestSigningKeyDroppedAfterRetention(t *testing.T) {
+	as := newTestService(t)
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+
+	token, err := as.generateUserToken("11111111-1111-1111-1111-111111111111", "user-1")
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	for i := 0; i <= DefaultRetainedSigningKeys; i++ {
+		if _, err := as.RotateSigningKey(context.Background()); err != nil {
+			t.Fatalf("rotate error: %v", err)
+		}
+	}
+
+	if got := len(as.JWKS().Keys); got != DefaultRetainedSigningKeys+1 {
+		t.Fatalf("expected %d published keys, got %d", DefaultRetainedSigningKeys+1, got)
+	}
+	resp, err := as.Introspect(ctx, IntrospectRequest{Token: token})
+	if err != nil {
+		t.Fatalf("introspect error: %v", err)
+	}
+	if resp.Active {
+		t.Fatalf("expected token signed by a dropped key to be inactive")
+	}
+}
+
+// memorySigningKeyStore is an in-memory SigningKeyStore.
+type memorySigningKeyStore struct {
+	mu   sync.Mutex
+	keys []storedSigningKey // newest first
+}
+
+func (m *memorySigningKeyStore) List(ctx context.Context) ([]storedSigningKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]storedSigningKey(nil), m.keys...), nil
+}
+
+func (m *memorySigningKeyStore) Add(ctx context.Context, key storedSigningKey, previousID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	newest := ""
+	if len(m.keys) > 0 {
+		newest = m.keys[0].ID
+	}
+	if newest != previousID {
+		return false, nil
+	}
+	m.keys = append([]storedSigningKey{key}, m.keys...)
+	return true, nil
+}
+
+func (m *memorySigningKeyStore) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, k := range m.keys {
+		if k.ID == id {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// newStoredKeyService returns a service sharing its signing keys through store.
+func newStoredKeyService(t *testing.T, store SigningKeyStore) *authService {
+	t.Helper()
+	as := newTestService(t)
+	cipher, err := secretbox.New(bytes.Repeat([]byte{5}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	as.signingKeys.useStore(store, cipher)
+	if err := as.LoadSigningKeys(context.Background()); err != nil {
+		t.Fatalf("load signing keys: %v", err)
+	}
+	return as
+}
+
+func TestStoredSigningKeysSharedAcrossInstances(t *testing.T) {
+	store := &memorySigningKeyStore{}
+	first := newStoredKeyService(t, store)
+	token, err := first.generateUserToken("11111111-1111-1111-1111-111111111111", "user-1")
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	if strings.Contains(store.keys[0].PrivateKey, "PRIVATE KEY") {
+		t.Fatal("expected the private key to be stored encrypted")
+	}
+
+	// Another instance, or the same one after a restart.
+	second := newStoredKeyService(t, store)
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+	resp, err := second.Introspect(ctx, IntrospectRequest{Token: token})
+	if err != nil || !resp.Active {
+		t.Fatalf("expected the token to verify on another instance, got %+v, %v", resp, err)
+	}
+}
+
+func TestStoredSigningKeyRotationPublishesBeforeSigning(t *testing.T) {
+	store := &memorySigningKeyStore{}
+	now := time.Now()
+	first := newStoredKeyService(t, store)
+	second := newStoredKeyService(t, store)
+	for _, as := range []*authService{first, second} {
+		as.signingKeys.now = func() time.Time { return now }
+	}
+	before := first.JWKS().Keys[0].KeyID
+
+	newKID, err := first.RotateSigningKey(context.Background())
+	if err != nil {
+		t.Fatalf("rotate error: %v", err)
+	}
+	if _, err := second.RotateSigningKey(context.Background()); err != nil {
+		t.Fatalf("concurrent rotate error: %v", err)
+	}
+	if len(store.keys) != 2 {
+		t.Fatalf("expected the later rotation to keep the first one's key, got %d keys", len(store.keys))
+	}
+	if kid := signedKID(t, first); kid != before {
+		t.Fatalf("expected the old key to sign while the new one propagates, got %s", kid)
+	}
+	if keys := first.JWKS().Keys; len(keys) != 2 || keys[0].KeyID != newKID {
+		t.Fatalf("expected the new key to be published, got %d keys", len(keys))
+	}
+
+	now = now.Add(signingKeyPropagationDelay)
+	if err := second.LoadSigningKeys(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if kid := signedKID(t, second); kid != newKID {
+		t.Fatalf("expected the new key to sign after propagation, got %s", kid)
+	}
+}
+
+func signedKID(t *testing.T, as *authService) string {
+	t.Helper()
+	token, err := as.generateUserToken("11111111-1111-1111-1111-111111111111", "user-1")
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		t.Fatalf("failed to parse token: %v", err)
+	}
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTPConfig locates the relay account emails are sent through.
+type SMTPConfig struct {
+	Host string
+	Port int
+	// Username and Password authenticate with PLAIN auth, which net/smtp
+	// only sends over TLS or to localhost. No auth is used when Username is
+	// empty.
+	Username string
+	Password string
+	// From is the sender address, e.g. "WardSeal <no-reply@wardseal.com>".
+	From string
+}
+
+// SMTPMailer delivers account emails through an SMTP relay. The connection
+// is upgraded with STARTTLS when the relay offers it.
+type SMTPMailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+	// sender is the bare address of from, for the SMTP envelope.
+	sender string
+	// send is smtp.SendMail, replaced in tests.
+	send func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewSMTPMailer returns a Mailer sending through the relay in cfg.
+func NewSMTPMailer(cfg SMTPConfig) (*SMTPMailer, error) {
+	if cfg.Host == "" || cfg.From == "" {
+		return nil, errors.New("smtp mailer needs a host and a from address")
+	}
+	sender, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid from address: %w", err)
+	}
+	port := cfg.Port
+	if port == 0 {
+		port = 587
+	}
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	return &SMTPMailer{
+		addr:   net.JoinHostPort(cfg.Host, strconv.Itoa(port)),
+		auth:   auth,
+		from:   cfg.From,
+		sender: sender.Address,
+		send:   smtp.SendMail,
+	}, nil
+}
+
+// SendVerificationEmail implements Mailer.
+func (m *SMTPMailer) SendVerificationEmail(ctx context.Context, tenantID, email, link string) error {
+	return m.sendMessage(email, "Verify your email address",
+		"Confirm your email address by opening this link:\r\n\r\n"+link+"\r\n\r\n"+
+			"If you did not create an account, you can ignore this email.\r\n")
+}
+
+// SendPasswordResetEmail implements Mailer.
+func (m *SMTPMailer) SendPasswordResetEmail(ctx context.Context, tenantID, email, link string) error {
+	return m.sendMessage(email, "Reset your password",
+		"Choose a new password by opening this link within "+PasswordResetLifetime.String()+":\r\n\r\n"+link+"\r\n\r\n"+
+			"If you did not ask to reset your password, you can ignore this email.\r\n")
+}
+
+func (m *SMTPMailer) sendMessage(to, subject, body string) error {
+	// The address ends up in a header; refuse anything that could add more.
+	if strings.ContainsAny(to, "\r\n") {
+		return fmt.Errorf("invalid recipient address")
+	}
+	var msg strings.Builder
+	msg.WriteString("From: " + m.from + "\r\n")
+	msg.WriteString("To: " + to + "\r\n")
+	msg.WriteString("Subject: " + subject + "\r\n")
+	msg.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(body)
+	return m.send(m.addr, m.auth, m.sender, []string{to}, []byte(msg.String()))
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/smtp"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSMTPMailerSendsVerificationLink(t *testing.T) {
+	mailer, err := NewSMTPMailer(SMTPConfig{Host: "smtp.wardseal.com", From: "WardSeal <no-reply@wardseal.com>"})
+	if err != nil {
+		t.Fatalf("NewSMTPMailer: %v", err)
+	}
+	var addr, from string
+	var to []string
+	var msg []byte
+	mailer.send = func(a string, _ smtp.Auth, f string, t []string, m []byte) error {
+		addr, from, to, msg = a, f, t, m
+		return nil
+	}
+
+	link := "http://wardseal.com/auth/verify-email?token=abc"
+	if err := mailer.SendVerificationEmail(context.Background(), "tenant-1", "alice@example.com", link); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if addr != "smtp.wardseal.com:587" || from != "no-reply@wardseal.com" || len(to) != 1 || to[0] != "alice@example.com" {
+		t.Fatalf("unexpected envelope %s %s %v", addr, from, to)
+	}
+	if !strings.Contains(string(msg), "To: alice@example.com\r\n") || !strings.Contains(string(msg), link) {
+		t.Fatalf("unexpected message:\n%s", msg)
+	}
+
+	if err := mailer.SendVerificationEmail(context.Background(), "tenant-1", "alice@example.com\r\nBcc: eve@example.com", link); err == nil {
+		t.Fatalf("expected a recipient with a line break to be refused")
+	}
+}
+
+func TestForgotPasswordMailsResetLinkWithoutLoggingIt(t *testing.T) {
+	as := newTestService(t)
+	stubResetDirectory(t, as)
+	mailer, err := NewSMTPMailer(SMTPConfig{Host: "smtp.wardseal.com", From: "no-reply@wardseal.com"})
+	if err != nil {
+		t.Fatalf("NewSMTPMailer: %v", err)
+	}
+	var msg []byte
+	mailer.send = func(_ string, _ smtp.Auth, _ string, _ []string, m []byte) error {
+		msg = m
+		return nil
+	}
+	as.mailer = mailer
+	core, logs := observer.New(zap.DebugLevel)
+	defer zap.ReplaceGlobals(zap.New(core))()
+
+	if err := as.ForgotPassword(contextWithTenant(t, "11111111-1111-1111-1111-111111111111"), "alice@example.com"); err != nil {
+		t.Fatalf("forgot password error: %v", err)
+	}
+	_, token, ok := strings.Cut(string(msg), "/auth/password/reset?token=")
+	if !ok || !strings.Contains(string(msg), "Subject: Reset your password\r\n") {
+		t.Fatalf("expected a reset email, got:\n%s", msg)
+	}
+	token, _, _ = strings.Cut(token, "\r\n")
+	for _, entry := range logs.All() {
+		if strings.Contains(fmt.Sprint(entry.Message, entry.ContextMap()), token) {
+			t.Fatalf("reset token logged: %s %v", entry.Message, entry.ContextMap())
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const socialTenant = "11111111-1111-1111-1111-111111111111"
+
+// staticSSOProviders serves one provider per name for any tenant.
+type staticSSOProviders map[string]*SSOProvider
+
+func (p staticSSOProviders) GetByName(ctx context.Context, tenantID, name string) (*SSOProvider, error) {
+	return p[name], nil
+}
+
+// newUpstreamIdP serves a token endpoint whose ID token carries nonce and
+// counts the code exchanges.
+func newUpstreamIdP(t *testing.T, nonce *string, exchanges *atomic.Int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			exchanges.Add(1)
+			payload := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"u1","nonce":"` + *nonce + `"}`))
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"access_token":"at","token_type":"Bearer","id_token":"e30.` + payload + `.sig"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newSocialTestService(t *testing.T, issuer string) *authService {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	as := newTestService(t)
+	clientID := "wardseal"
+	as.ssoProviderStore = staticSSOProviders{
+		"github": {Name: "github", OIDCIssuerURL: &issuer, OIDCClientID: &clientID, OIDCClientSecret: []byte("secret")},
+		"okta":   {Name: "okta", OIDCIssuerURL: &issuer, OIDCClientID: &clientID, OIDCClientSecret: []byte("secret")},
+	}
+	return as
+}
+
+func startSocial(t *testing.T, as *authService, provider string) url.Values {
+	t.Helper()
+	authorizeURL, _, err := as.StartSocialLogin(contextWithTenant(t, socialTenant), provider, "")
+	if err != nil {
+		t.Fatalf("StartSocialLogin failed: %v", err)
+	}
+	u, err := url.Parse(authorizeURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Query()
+}
+
+func TestStartSocialLoginBuildsAuthorizeURL(t *testing.T) {
+	var exchanges atomic.Int32
+	nonce := ""
+	upstream := newUpstreamIdP(t, &nonce, &exchanges)
+	as := newSocialTestService(t, upstream.URL)
+
+	q := startSocial(t, as, "github")
+	if q.Get("state") == "" || q.Get("nonce") == "" || q.Get("code_challenge") == "" {
+		t.Fatalf("expected state, nonce and code_challenge, got %v", q)
+	}
+	if q.Get("code_challenge_method") != "S256" {
+		t.Fatalf("expected S256 PKCE, got %q", q.Get("code_challenge_method"))
+	}
+	if q.Get("redirect_uri") != "http://wardseal.com/auth/sso/github/callback" {
+		t.Fatalf("unexpected redirect_uri %q", q.Get("redirect_uri"))
+	}
+	if q.Get("state") == startSocial(t, as, "github").Get("state") {
+		t.Fatal("expected a fresh state per start")
+	}
+}
+
+func TestSocialLoginRejectsStateMismatch(t *testing.T) {
+	var exchanges atomic.Int32
+	nonce := ""
+	upstream := newUpstreamIdP(t, &nonce, &exchanges)
+	as := newSocialTestService(t, upstream.URL)
+	ctx := contextWithTenant(t, socialTenant)
+
+	tests := []struct {
+		name  string
+		state func() string
+		call  func(state string) error
+	}{
+		{"missing state", func() string { return "" }, func(state string) error {
+			_, err := as.SocialLogin(ctx, SocialLoginRequest{Provider: "github", Code: "c", State: state})
+			return err
+		}},
+		{"unknown state", func() string { return "forged" }, func(state string) error {
+			_, err := as.SocialLoginCallback(context.Background(), "github", state, "c")
+			return err
+		}},
+		{"state for another provider", func() string { return startSocial(t, as, "okta").Get("state") }, func(state string) error {
+			_, err := as.SocialLoginCallback(context.Background(), "github", state, "c")
+			return err
+		}},
+		{"state for another tenant", func() string { return startSocial(t, as, "github").Get("state") }, func(state string) error {
+			other := contextWithTenant(t, "22222222-2222-2222-2222-222222222222")
+			_, err := as.SocialLogin(other, SocialLoginRequest{Provider: "github", Code: "c", State: state})
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call(tt.state())
+			var authErr *Error
+			if !errors.As(err, &authErr) || authErr.Code != "invalid_request" {
+				t.Fatalf("expected invalid_request, got %v", err)
+			}
+		})
+	}
+	if n := exchanges.Load(); n != 0 {
+		t.Fatalf("expected no code exchange for a bad state, got %d", n)
+	}
+}
+
+func TestSocialLoginStateIsSingleUse(t *testing.T) {
+	var exchanges atomic.Int32
+	nonce := "not-the-nonce"
+	upstream := newUpstreamIdP(t, &nonce, &exchanges)
+	as := newSocialTestService(t, upstream.URL)
+
+	state := startSocial(t, as, "github").Get("state")
+	var authErr *Error
+	// The ID token's nonce differs from the one the start issued.
+	if _, err := as.SocialLoginCallback(context.Background(), "github", state, "c"); !errors.As(err, &authErr) || authErr.Code != "invalid_grant" {
+		t.Fatalf("expected invalid_grant for a nonce mismatch, got %v", err)
+	}
+	if _, err := as.SocialLoginCallback(context.Background(), "github", state, "c"); !errors.As(err, &authErr) || authErr.Code != "invalid_request" {
+		t.Fatalf("expected a replayed state to be rejected, got %v", err)
+	}
+	if n := exchanges.Load(); n != 1 {
+		t.Fatalf("expected one code exchange, got %d", n)
+	}
+}
+
+func TestStartSocialLoginRejectsUnregisteredRedirectURI(t *testing.T) {
+	var exchanges atomic.Int32
+	nonce := ""
+	upstream := newUpstreamIdP(t, &nonce, &exchanges)
+	as := newSocialTestService(t, upstream.URL)
+	as.ssoProviderStore.(staticSSOProviders)["github"].OIDCRedirectURIs = []string{"https://app.wardseal.com/sso/done"}
+	ctx := contextWithTenant(t, socialTenant)
+
+	if _, _, err := as.StartSocialLogin(ctx, "github", "https://attacker.example/collect"); !errors.Is(err, errUnregisteredSocialRedirect) {
+		t.Fatalf("expected an unregistered redirect_uri to be rejected, got %v", err)
+	}
+	for _, redirectURI := range []string{"https://app.wardseal.com/sso/done", "http://wardseal.com/auth/sso/github/callback"} {
+		if _, _, err := as.StartSocialLogin(ctx, "github", redirectURI); err != nil {
+			t.Fatalf("expected %s to be accepted, got %v", redirectURI, err)
+		}
+	}
+}
+
+func TestSocialLoginCallbackRequiresStateCookie(t *testing.T) {
+	var exchanges atomic.Int32
+	nonce := "not-the-nonce"
+	upstream := newUpstreamIdP(t, &nonce, &exchanges)
+	as := newSocialTestService(t, upstream.URL)
+	router := gin.New()
+	NewHTTPHandler(as, zap.NewNop(), nil).RegisterRoutes(router)
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/sso/github/start", nil)
+	req.Header.Set(middleware.DefaultTenantHeader, socialTenant)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusFound {
+		t.Fatalf("expected a redirect to the provider, got %d %s", w.Code, w.Body)
+	}
+	var cookie *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == SocialStateCookie {
+			cookie = c
+		}
+	}
+	if cookie == nil || !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode {
+		t.Fatalf("expected an HttpOnly Lax state cookie, got %+v", cookie)
+	}
+	location, _ := url.Parse(w.Header().Get("Location"))
+	state := location.Query().Get("state")
+	if state != cookie.Value {
+		t.Fatalf("expected the cookie to carry the state %q, got %q", state, cookie.Value)
+	}
+
+	callback := func(withCookie bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/auth/sso/github/callback?code=c&state="+url.QueryEscape(state), nil)
+		if withCookie {
+			req.AddCookie(&http.Cookie{Name: SocialStateCookie, Value: cookie.Value})
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	// A callback link opened in another browser does not complete the login.
+	if w := callback(false); w.Code != http.StatusBadRequest || exchanges.Load() != 0 {
+		t.Fatalf("expected 400 without the state cookie and no exchange, got %d and %d exchanges", w.Code, exchanges.Load())
+	}
+	// With the cookie the state is accepted and the code exchanged; the
+	// upstream's nonce then fails the login.
+	if w := callback(true); !strings.Contains(w.Body.String(), "invalid_grant") || exchanges.Load() != 1 {
+		t.Fatalf("expected the bound state to reach the code exchange, got %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// socialLoginState is an outstanding social login start, redeemed once by
+// the callback that presents its state.
+type socialLoginState struct {
+	State        string    `db:"state"`
+	TenantID     string    `db:"tenant_id"`
+	Provider     string    `db:"provider"`
+	Nonce        string    `db:"nonce"`
+	CodeVerifier string    `db:"code_verifier"`
+	RedirectURI  string    `db:"redirect_uri"`
+	ExpiresAt    time.Time `db:"expires_at"`
+}
+
+// SocialStateStore keeps social login states between the start and the
+// callback.
+type SocialStateStore interface {
+	Save(ctx context.Context, entry socialLoginState) error
+	// Take removes and returns the state, so it cannot be replayed.
+	Take(ctx context.Context, state string) (socialLoginState, bool, error)
+}
+
+// SQLSocialStateStore implements persistent storage for social login states.
+type SQLSocialStateStore struct {
+	db *sqlx.DB
+}
+
+// NewSQLSocialStateStore creates a new SQL-backed social login state store.
+func NewSQLSocialStateStore(db *sqlx.DB) *SQLSocialStateStore {
+	return &SQLSocialStateStore{db: db}
+}
+
+func (s *SQLSocialStateStore) Save(ctx context.Context, e socialLoginState) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO social_login_states (state, tenant_id, provider, nonce, code_verifier, redirect_uri, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		e.State, e.TenantID, e.Provider, e.Nonce, e.CodeVerifier, e.RedirectURI, e.ExpiresAt)
+	return err
+}
+
+func (s *SQLSocialStateStore) Take(ctx context.Context, state string) (socialLoginState, bool, error) {
+	var entry socialLoginState
+	err := s.db.GetContext(ctx, &entry, `
+		DELETE FROM social_login_states WHERE state = $1
+		RETURNING state, tenant_id, provider, nonce, code_verifier, redirect_uri, expires_at`, state)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return socialLoginState{}, false, nil
+		}
+		return socialLoginState{}, false, err
+	}
+	return entry, true, nil
+}
+
+// CleanupExpired removes expired social login states (can be run periodically).
+func (s *SQLSocialStateStore) CleanupExpired(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM social_login_states WHERE expires_at < $1`, time.Now())
+	return err
+}
+
+// socialStateMemoryStore is an in-memory SocialStateStore used when no database is configured.
+type socialStateMemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]socialLoginState
+}
+
+func newSocialStateMemoryStore() *socialStateMemoryStore {
+	return &socialStateMemoryStore{entries: make(map[string]socialLoginState)}
+}
+
+func (s *socialStateMemoryStore) Save(ctx context.Context, e socialLoginState) error {
+	s.mu.Lock()
+	s.entries[e.State] = e
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *socialStateMemoryStore) Take(ctx context.Context, state string) (socialLoginState, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[state]
+	delete(s.entries, state)
+	return entry, ok, nil
+}
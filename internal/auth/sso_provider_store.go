@@ -6,6 +6,7 @@ import (
 	"errors"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 // SSOProvider represents a configuration for an external Identity Provider (OIDC/SAML).
@@ -21,6 +22,14 @@ type SSOProvider struct {
 	OIDCClientID     *string `db:"oidc_client_id"`
 	OIDCClientSecret []byte  `db:"oidc_client_secret"`
 	OIDCScopes       *string `db:"oidc_scopes"`
+	// OIDCRedirectURIs are the redirect URIs a social login may use besides
+	// this service's callback.
+	OIDCRedirectURIs pq.StringArray `db:"oidc_redirect_uris"`
+
+	// AttributeMappings holds the provider's ClaimMap.
+	AttributeMappings JSON `db:"attribute_mappings"`
+	// LinkPolicy is sso.LinkPolicyEmail or sso.LinkPolicyNone.
+	LinkPolicy string `db:"link_policy"`
 
 	// SAML Fields (omitted for brevity as we are focusing on OIDC Social Login here)
 }
@@ -43,7 +52,8 @@ func (s *SQLSSOProviderStore) GetByName(ctx context.Context, tenantID, name stri
 	// We select only relevant fields for OIDC/Social login for now
 	query := `
 		SELECT id, tenant_id, name, type, enabled, 
-		       oidc_issuer_url, oidc_client_id, oidc_client_secret, oidc_scopes
+		       oidc_issuer_url, oidc_client_id, oidc_client_secret, oidc_scopes, oidc_redirect_uris,
+		       attribute_mappings, link_policy
 		FROM sso_providers 
 		WHERE tenant_id = $1 AND name = $2 AND enabled = true
 	`
@@ -25,8 +25,8 @@ func NewSQLAuthorizationCodeStore(db *sqlx.DB) *SQLAuthorizationCodeStore {
 
 func (s *SQLAuthorizationCodeStore) Save(ctx context.Context, code authorizationCode) error {
 	query := `
-		INSERT INTO authorization_codes (code, client_id, redirect_uri, scope, tenant_id, code_challenge, code_challenge_method, expires_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO authorization_codes (code, client_id, redirect_uri, scope, tenant_id, code_challenge, code_challenge_method, expires_at, subject, resource)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 	`
 	_, err := s.db.ExecContext(ctx, query,
 		code.Code,
@@ -37,13 +37,15 @@ func (s *SQLAuthorizationCodeStore) Save(ctx context.Context, code authorization
 		code.CodeChallenge,
 		code.CodeChallengeMethod,
 		code.ExpiresAt,
+		code.Subject,
+		code.Resource,
 	)
 	return err
 }
 
 func (s *SQLAuthorizationCodeStore) Get(ctx context.Context, code string) (authorizationCode, bool, error) {
 	var entry authorizationCode
-	query := `SELECT code, client_id, redirect_uri, scope, tenant_id, code_challenge, code_challenge_method, expires_at FROM authorization_codes WHERE code = $1`
+	query := `SELECT code, client_id, redirect_uri, scope, tenant_id, code_challenge, code_challenge_method, expires_at, subject, resource FROM authorization_codes WHERE code = $1`
 	err := s.db.GetContext(ctx, &entry, query, code)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -81,8 +83,8 @@ func NewSQLRefreshTokenStore(db *sqlx.DB) *SQLRefreshTokenStore {
 
 func (s *SQLRefreshTokenStore) Save(ctx context.Context, entry refreshTokenEntry) error {
 	query := `
-		INSERT INTO refresh_tokens (token, client_id, tenant_id, scope, subject_type, expires_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO refresh_tokens (token, client_id, tenant_id, scope, subject_type, family_id, resource, dpop_jkt, expires_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9)
 	`
 	_, err := s.db.ExecContext(ctx, query,
 		entry.Token,
@@ -90,6 +92,9 @@ func (s *SQLRefreshTokenStore) Save(ctx context.Context, entry refreshTokenEntry
 		entry.TenantID,
 		entry.Scope,
 		entry.SubjectType,
+		entry.FamilyID,
+		entry.Resource,
+		entry.DPoPJKT,
 		entry.ExpiresAt,
 	)
 	return err
@@ -97,7 +102,7 @@ func (s *SQLRefreshTokenStore) Save(ctx context.Context, entry refreshTokenEntry
 
 func (s *SQLRefreshTokenStore) Get(ctx context.Context, token string) (refreshTokenEntry, bool, error) {
 	var entry refreshTokenEntry
-	query := `SELECT token, client_id, tenant_id, scope, subject_type, expires_at FROM refresh_tokens WHERE token = $1`
+	query := `SELECT token, client_id, tenant_id, scope, subject_type, COALESCE(family_id, '') AS family_id, resource, dpop_jkt, expires_at FROM refresh_tokens WHERE token = $1`
 	err := s.db.GetContext(ctx, &entry, query, token)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -114,6 +119,12 @@ func (s *SQLRefreshTokenStore) Delete(ctx context.Context, token string) error {
 	return err
 }
 
+func (s *SQLRefreshTokenStore) DeleteFamily(ctx context.Context, familyID string) error {
+	query := `DELETE FROM refresh_tokens WHERE family_id = $1`
+	_, err := s.db.ExecContext(ctx, query, familyID)
+	return err
+}
+
 // CleanupExpired removes expired tokens.
 func (s *SQLRefreshTokenStore) CleanupExpired(ctx context.Context) error {
 	query := `DELETE FROM refresh_tokens WHERE expires_at < $1`
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net"
+)
+
+// ErrTokenBindingMismatch is returned when a bound token is presented from a
+// different network or user agent than it was issued to.
+var ErrTokenBindingMismatch = &Error{"invalid_token", "token is bound to a different client"}
+
+// tokenFingerprint hashes the caller's network and user agent. IPv4 addresses
+// are reduced to their /24 and IPv6 to their /64 so that ordinary address
+// churn (mobile carriers, DHCP) does not invalidate the token.
+func tokenFingerprint(ip, userAgent string) string {
+	network := ip
+	if parsed := net.ParseIP(ip); parsed != nil {
+		if v4 := parsed.To4(); v4 != nil {
+			network = v4.Mask(net.CIDRMask(24, 32)).String()
+		} else {
+			network = parsed.Mask(net.CIDRMask(64, 128)).String()
+		}
+	}
+	sum := sha256.Sum256([]byte(network + "|" + userAgent))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// confirmation is what an access token is bound to, carried in its "cnf"
+// claim (RFC 7800).
+type confirmation struct {
+	// Fingerprint binds the token to the caller's network and user agent.
+	Fingerprint string
+	// JKT binds the token to the client's DPoP key (RFC 9449).
+	JKT string
+}
+
+// tokenConfirmation returns what tokens issued to client for req are bound to.
+func tokenConfirmation(client ClientConfig, req TokenRequest) confirmation {
+	return confirmation{Fingerprint: tokenBinding(client, req), JKT: req.dpopJKT}
+}
+
+// claim returns the "cnf" claim, or nil for an unbound token.
+func (c confirmation) claim() map[string]string {
+	if c.Fingerprint == "" && c.JKT == "" {
+		return nil
+	}
+	claim := make(map[string]string)
+	if c.Fingerprint != "" {
+		claim["fpt"] = c.Fingerprint
+	}
+	if c.JKT != "" {
+		claim["jkt"] = c.JKT
+	}
+	return claim
+}
+
+// tokenType returns the token_type of access tokens bound to c.
+func (c confirmation) tokenType() string {
+	if c.JKT != "" {
+		return DPoPTokenType
+	}
+	return "Bearer"
+}
+
+// tokenBinding returns the fingerprint to embed in tokens issued to client,
+// or "" when the client has not opted in.
+func tokenBinding(client ClientConfig, req TokenRequest) string {
+	if !client.BindTokens {
+		return ""
+	}
+	return tokenFingerprint(req.ClientIP, req.UserAgent)
+}
+
+// verifyTokenBinding checks the "cnf" fingerprint of a bound access token
+// against the presenter. Unbound tokens always pass.
+func verifyTokenBinding(claims map[string]interface{}, ip, userAgent string) error {
+	cnf, ok := claims["cnf"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	expected, _ := cnf["fpt"].(string)
+	if expected == "" {
+		return nil
+	}
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(tokenFingerprint(ip, userAgent))) != 1 {
+		return ErrTokenBindingMismatch
+	}
+	return nil
+}
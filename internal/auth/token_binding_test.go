@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+const testBrowserUA = "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_0) AppleWebKit/605.1.15"
+
+func TestBoundTokenIntrospectsFromSameSubnet(t *testing.T) {
+	as := newTestService(t)
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+	token := issueBoundToken(t, as, ctx, "bound-client", "203.0.113.10")
+
+	// A different host in the same /24 (e.g. a mobile IP change) is accepted.
+	resp, err := as.Introspect(ctx, IntrospectRequest{Token: token, PresenterIP: "203.0.113.77", PresenterUserAgent: testBrowserUA})
+	if err != nil {
+		t.Fatalf("introspect error: %v", err)
+	}
+	if !resp.Active {
+		t.Fatalf("expected bound token to be active for the same subnet and user agent")
+	}
+}
+
+func TestBoundTokenRejectedForDifferentPresenter(t *testing.T) {
+	as := newTestService(t)
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+	token := issueBoundToken(t, as, ctx, "bound-client", "203.0.113.10")
+
+	_, err := as.Introspect(ctx, IntrospectRequest{Token: token, PresenterIP: "198.51.100.10", PresenterUserAgent: testBrowserUA})
+	if !errors.Is(err, ErrTokenBindingMismatch) {
+		t.Fatalf("expected ErrTokenBindingMismatch for a different network, got %v", err)
+	}
+
+	_, err = as.Introspect(ctx, IntrospectRequest{Token: token, PresenterIP: "203.0.113.10", PresenterUserAgent: "curl/8.4.0"})
+	if !errors.Is(err, ErrTokenBindingMismatch) {
+		t.Fatalf("expected ErrTokenBindingMismatch for a different user agent, got %v", err)
+	}
+}
+
+func TestUnboundClientTokensIgnorePresenter(t *testing.T) {
+	as := newTestService(t)
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+	token := issueBoundToken(t, as, ctx, "test-client", "203.0.113.10")
+
+	resp, err := as.Introspect(ctx, IntrospectRequest{Token: token, PresenterIP: "198.51.100.10", PresenterUserAgent: "curl/8.4.0"})
+	if err != nil || !resp.Active {
+		t.Fatalf("expected unbound token to be active from anywhere, got active=%v err=%v", resp.Active, err)
+	}
+}
+
+func issueBoundToken(t *testing.T, as *authService, ctx context.Context, clientID, ip string) string {
+	t.Helper()
+	verifier := "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNO1234567890abcd"
+	authResp, err := as.Authorize(ctx, AuthorizeRequest{
+		ResponseType:        "code",
+		ClientID:            clientID,
+		RedirectURI:         "https://app.wardseal.com/callback",
+		Scope:               "openid",
+		CodeChallenge:       pkceChallenge(verifier),
+		CodeChallengeMethod: "S256",
+	})
+	if err != nil {
+		t.Fatalf("authorize error: %v", err)
+	}
+	tokenResp, err := as.Token(ctx, TokenRequest{
+		GrantType:    "authorization_code",
+		Code:         extractCode(t, authResp.RedirectURI),
+		RedirectURI:  "https://app.wardseal.com/callback",
+		ClientID:     clientID,
+		CodeVerifier: verifier,
+		ClientIP:     ip,
+		UserAgent:    testBrowserUA,
+	})
+	if err != nil {
+		t.Fatalf("token error: %v", err)
+	}
+	return tokenResp.AccessToken
+}
@@ -0,0 +1,239 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dhawalhost/wardseal/internal/oauthclient"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTokenExchangeService(t *testing.T) *authService {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("orders-secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("failed to hash secret: %v", err)
+	}
+	store := newStubClientStore()
+	store.addClient(oauthclient.Client{
+		TenantID:      "11111111-1111-1111-1111-111111111111",
+		ClientID:      "web-app",
+		ClientType:    "public",
+		Name:          "Web App",
+		RedirectURIs:  pq.StringArray{"https://app.wardseal.com/callback"},
+		AllowedScopes: pq.StringArray{"openid", "orders:read", "orders:write"},
+		FirstParty:    true,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	})
+	store.addClient(oauthclient.Client{
+		TenantID:         "11111111-1111-1111-1111-111111111111",
+		ClientID:         "orders-service",
+		ClientType:       "confidential",
+		Name:             "Orders Service",
+		ClientSecretHash: hash,
+		RedirectURIs:     pq.StringArray{"https://orders.wardseal.com/callback"},
+		AllowedScopes:    pq.StringArray{"orders:read", "orders:write", "admin"},
+		CreatedAt:        time.Now(),
+		UpdatedAt:        time.Now(),
+	})
+	return newServiceWithStore(t, store).(*authService)
+}
+
+func TestTokenExchangeIssuesDownscopedDelegatedToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTokenExchangeService(t)
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+
+	subjectToken, err := as.generateAccessToken(ctx, "11111111-1111-1111-1111-111111111111", "web-app", "openid orders:read orders:write", "user", confirmation{}, "")
+	if err != nil {
+		t.Fatalf("failed to create subject token: %v", err)
+	}
+
+	resp, err := as.Token(ctx, TokenRequest{
+		GrantType:        TokenExchangeGrantType,
+		ClientID:         "orders-service",
+		ClientSecret:     "orders-secret",
+		SubjectToken:     subjectToken,
+		SubjectTokenType: AccessTokenType,
+		Scope:            "orders:read",
+	})
+	if err != nil {
+		t.Fatalf("token exchange error: %v", err)
+	}
+	if resp.Scope != "orders:read" || resp.IssuedTokenType != AccessTokenType || resp.RefreshToken != "" {
+		t.Fatalf("unexpected exchange response: %+v", resp)
+	}
+
+	introspected, err := as.Introspect(ctx, IntrospectRequest{Token: resp.AccessToken})
+	if err != nil {
+		t.Fatalf("introspect error: %v", err)
+	}
+	if !introspected.Active || introspected.Sub != "web-app" || introspected.Scope != "orders:read" {
+		t.Fatalf("unexpected introspection: %+v", introspected)
+	}
+	if introspected.Act["sub"] != "orders-service" {
+		t.Fatalf("expected act.sub orders-service, got %v", introspected.Act)
+	}
+}
+
+func TestTokenExchangeRejectsScopeEscalation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTokenExchangeService(t)
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+
+	subjectToken, err := as.generateAccessToken(ctx, "11111111-1111-1111-1111-111111111111", "web-app", "orders:read", "user", confirmation{}, "")
+	if err != nil {
+		t.Fatalf("failed to create subject token: %v", err)
+	}
+
+	_, err = as.Token(ctx, TokenRequest{
+		GrantType:        TokenExchangeGrantType,
+		ClientID:         "orders-service",
+		ClientSecret:     "orders-secret",
+		SubjectToken:     subjectToken,
+		SubjectTokenType: AccessTokenType,
+		Scope:            "orders:read admin",
+	})
+	if !errors.Is(err, ErrScopeEscalation) {
+		t.Fatalf("expected ErrScopeEscalation, got %v", err)
+	}
+}
+
+func TestTokenExchangeRequiresClientAuthentication(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTokenExchangeService(t)
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+
+	subjectToken, _ := as.generateAccessToken(ctx, "11111111-1111-1111-1111-111111111111", "web-app", "orders:read", "user", confirmation{}, "")
+	_, err := as.Token(ctx, TokenRequest{
+		GrantType:        TokenExchangeGrantType,
+		ClientID:         "orders-service",
+		ClientSecret:     "wrong",
+		SubjectToken:     subjectToken,
+		SubjectTokenType: AccessTokenType,
+	})
+	svcErr := &Error{}
+	if !errors.As(err, &svcErr) || svcErr.Code != "invalid_client" {
+		t.Fatalf("expected invalid_client, got %v", err)
+	}
+}
+
+func exchangeSubjectToken(ctx context.Context, as *authService, subjectToken string) error {
+	_, err := as.Token(ctx, TokenRequest{
+		GrantType:        TokenExchangeGrantType,
+		ClientID:         "orders-service",
+		ClientSecret:     "orders-secret",
+		SubjectToken:     subjectToken,
+		SubjectTokenType: JWTTokenType,
+	})
+	return err
+}
+
+func TestTokenExchangeRejectsNonAccessTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTokenExchangeService(t)
+	tenantID := "11111111-1111-1111-1111-111111111111"
+	ctx := contextWithTenant(t, tenantID)
+
+	// Each is signed by this service, and given a scope so only its
+	// audience or kind rules it out.
+	signed := func(aud string, extra jwt.MapClaims) string {
+		claims := jwt.MapClaims{
+			"sub": "user-1", "iss": "identity-platform", "aud": aud, "tenant": tenantID,
+			"iat": time.Now().Unix(), "exp": time.Now().Add(time.Hour).Unix(), "scope": "orders:read",
+		}
+		for k, v := range extra {
+			claims[k] = v
+		}
+		token, err := as.signingKeys.sign(claims)
+		if err != nil {
+			t.Fatalf("failed to sign token: %v", err)
+		}
+		return token
+	}
+	resetToken, err := as.passwordResetToken(ctx, tenantID, "user-1", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create reset token: %v", err)
+	}
+	verificationToken, err := as.emailVerificationToken(ctx, tenantID, "user-1", "user@wardseal.com", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create verification token: %v", err)
+	}
+	idToken, err := as.generateIDToken(tenantID, "web-app", "family-1")
+	if err != nil {
+		t.Fatalf("failed to create ID token: %v", err)
+	}
+
+	for name, token := range map[string]string{
+		"mfa challenge":             signed(mfaChallengeType, jwt.MapClaims{"typ": mfaChallengeType}),
+		"password reset":            signed(passwordResetAudience, nil),
+		"password reset (real)":     resetToken,
+		"email verification":        signed(emailVerificationAudience, nil),
+		"email verification (real)": verificationToken,
+		"id token":                  idToken,
+		"unknown audience":          signed("someone-else", nil),
+	} {
+		if err := exchangeSubjectToken(ctx, as, token); !errors.Is(err, ErrInvalidSubjectToken) {
+			t.Errorf("%s: expected ErrInvalidSubjectToken, got %v", name, err)
+		}
+	}
+}
+
+func TestTokenExchangeRejectsRevokedSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTokenExchangeService(t)
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+
+	subjectToken, err := as.generateAccessToken(ctx, "11111111-1111-1111-1111-111111111111", "web-app", "orders:read", "user", confirmation{}, "")
+	if err != nil {
+		t.Fatalf("failed to create subject token: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := as.signalStore.Ingest(ctx, &SecurityEvent{
+		TenantID:  "11111111-1111-1111-1111-111111111111",
+		SubjectID: "web-app",
+		EventType: SecurityEventPasswordChanged,
+	}); err != nil {
+		t.Fatalf("ingest error: %v", err)
+	}
+	if err := exchangeSubjectToken(ctx, as, subjectToken); !errors.Is(err, ErrInvalidSubjectToken) {
+		t.Fatalf("expected ErrInvalidSubjectToken after a password change, got %v", err)
+	}
+}
+
+func TestTokenExchangeRequiresDPoPProofForBoundSubject(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	as := newTokenExchangeService(t)
+	ctx := contextWithTenant(t, "11111111-1111-1111-1111-111111111111")
+	key := newDPoPKey(t)
+
+	subjectToken, err := as.generateAccessToken(ctx, "11111111-1111-1111-1111-111111111111", "web-app", "orders:read", "user", confirmation{JKT: key.thumbprint()}, "")
+	if err != nil {
+		t.Fatalf("failed to create subject token: %v", err)
+	}
+	if err := exchangeSubjectToken(ctx, as, subjectToken); !errors.Is(err, ErrSubjectTokenDPoPBound) {
+		t.Fatalf("expected ErrSubjectTokenDPoPBound without a proof, got %v", err)
+	}
+
+	resp, err := as.Token(ctx, TokenRequest{
+		GrantType:        TokenExchangeGrantType,
+		ClientID:         "orders-service",
+		ClientSecret:     "orders-secret",
+		SubjectToken:     subjectToken,
+		SubjectTokenType: AccessTokenType,
+		DPoPProof:        key.proof(testTokenURI, "", ""),
+	})
+	if err != nil {
+		t.Fatalf("token exchange with proof error: %v", err)
+	}
+	introspected, err := as.Introspect(ctx, IntrospectRequest{Token: resp.AccessToken})
+	if err != nil || resp.TokenType != DPoPTokenType || introspected.Cnf["jkt"] != key.thumbprint() {
+		t.Fatalf("expected the exchanged token to stay bound, got %+v %+v err=%v", resp, introspected, err)
+	}
+}
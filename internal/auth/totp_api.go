@@ -3,66 +3,68 @@ package auth
 import (
 	"bytes"
 	"encoding/base64"
+	"errors"
 	"image/png"
 	"net/http"
 
+	"github.com/dhawalhost/wardseal/internal/featureflag"
+	"github.com/dhawalhost/wardseal/pkg/middleware"
 	"github.com/gin-gonic/gin"
-	"github.com/pquerna/otp/totp"
 	"go.uber.org/zap"
 )
 
-// TOTPEnrollRequest is the request to start TOTP enrollment.
-type TOTPEnrollRequest struct {
-	UserID string `json:"user_id" binding:"required"`
-}
-
 // TOTPEnrollResponse contains the secret and QR code for enrollment.
 type TOTPEnrollResponse struct {
 	Secret  string `json:"secret"`
 	QRCode  string `json:"qr_code"` // Base64 encoded PNG
 	OTPAuth string `json:"otpauth_url"`
+	// RecoveryCodes are shown only once; store them somewhere safe.
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// RecoveryCodesRequest is the request to regenerate MFA recovery codes. Code
+// is a current TOTP code or an unused recovery code.
+type RecoveryCodesRequest struct {
+	Code string `json:"code" binding:"required"`
 }
 
 // TOTPVerifyRequest is the request to verify a TOTP code.
 type TOTPVerifyRequest struct {
-	UserID string `json:"user_id" binding:"required"`
-	Code   string `json:"code" binding:"required"`
+	Code string `json:"code" binding:"required"`
 }
 
-// RegisterTOTPRoutes registers TOTP-related routes.
+// TOTPDeleteRequest is the request to remove TOTP. Code is a current TOTP
+// code or an unused recovery code, required once enrollment is verified.
+type TOTPDeleteRequest struct {
+	Code string `json:"code"`
+}
+
+// RegisterTOTPRoutes registers TOTP-related routes. They act on the signed-in
+// user's own factor, so rg must run the tenant extractor.
 func (h *HTTPHandler) RegisterTOTPRoutes(rg *gin.RouterGroup) {
 	totp := rg.Group("/mfa/totp")
 	{
-		totp.POST("/enroll", h.enrollTOTP)
-		totp.POST("/verify", h.verifyTOTP)
-		totp.DELETE("", h.deleteTOTP)
-		totp.GET("/status", h.getTOTPStatus)
+		totp.POST("/enroll", h.requireSession(), h.requireFeature(featureflag.FeatureMFA), h.enrollTOTP)
+		totp.POST("/verify", h.requireSession(), h.verifyTOTP)
+		totp.DELETE("", h.requireSession(), h.deleteTOTP)
+		totp.GET("/status", h.requireSession(), h.getTOTPStatus)
+		totp.POST("/recovery-codes", h.requireSession(), h.regenerateRecoveryCodes)
 	}
 }
 
 func (h *HTTPHandler) enrollTOTP(c *gin.Context) {
-	var req TOTPEnrollRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	tenantID := c.GetHeader("X-Tenant-ID")
-	if tenantID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Tenant-ID header required"})
-		return
-	}
-
-	// Generate TOTP key
-	key, err := totp.Generate(totp.GenerateOpts{
-		Issuer:      "WardSeal",
-		AccountName: req.UserID,
-	})
+	// Generate and store the secret (encrypted, unverified)
+	enrollment, err := h.svc.EnrollTOTP(c.Request.Context(), middleware.SubjectFromContext(c))
 	if err != nil {
-		h.logger.Error("Failed to generate TOTP key", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate TOTP key"})
+		if errors.Is(err, ErrTOTPAlreadyEnrolled) {
+			c.JSON(http.StatusConflict, gin.H{"error": "TOTP is already enrolled; remove it before enrolling again"})
+			return
+		}
+		h.logger.Error("Failed to enroll TOTP", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to enroll TOTP"})
 		return
 	}
+	key := enrollment.Key
 
 	// Generate QR code
 	var buf bytes.Buffer
@@ -79,22 +81,11 @@ func (h *HTTPHandler) enrollTOTP(c *gin.Context) {
 	}
 	qrBase64 := base64.StdEncoding.EncodeToString(buf.Bytes())
 
-	// Store the secret (unverified)
-	secret := &TOTPSecret{
-		IdentityID: req.UserID,
-		TenantID:   tenantID,
-		Secret:     key.Secret(),
-	}
-	if err := h.svc.TOTP().Create(c.Request.Context(), secret); err != nil {
-		h.logger.Error("Failed to store TOTP secret", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store TOTP secret"})
-		return
-	}
-
 	c.JSON(http.StatusOK, TOTPEnrollResponse{
-		Secret:  key.Secret(),
-		QRCode:  qrBase64,
-		OTPAuth: key.URL(),
+		Secret:        key.Secret(),
+		QRCode:        qrBase64,
+		OTPAuth:       key.URL(),
+		RecoveryCodes: enrollment.RecoveryCodes,
 	})
 }
 
@@ -105,59 +96,67 @@ func (h *HTTPHandler) verifyTOTP(c *gin.Context) {
 		return
 	}
 
-	tenantID := c.GetHeader("X-Tenant-ID")
-	if tenantID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Tenant-ID header required"})
+	if err := h.svc.VerifyTOTP(c.Request.Context(), middleware.SubjectFromContext(c), req.Code); err != nil {
+		switch {
+		case errors.Is(err, ErrTOTPNotEnrolled):
+			c.JSON(http.StatusNotFound, gin.H{"error": "TOTP not enrolled for this user"})
+		case errors.Is(err, ErrInvalidTOTPCode):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid TOTP code"})
+		default:
+			h.logger.Error("Failed to verify TOTP", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify TOTP"})
+		}
 		return
 	}
 
-	// Get stored secret
-	stored, err := h.svc.TOTP().GetByIdentity(c.Request.Context(), tenantID, req.UserID)
-	if err != nil {
-		h.logger.Error("Failed to get TOTP secret", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to retrieve TOTP secret"})
-		return
-	}
-	if stored == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "TOTP not enrolled for this user"})
-		return
-	}
+	c.JSON(http.StatusOK, gin.H{"verified": true})
+}
 
-	// Validate code
-	valid := totp.Validate(req.Code, stored.Secret)
-	if !valid {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid TOTP code"})
+// regenerateRecoveryCodes issues a new set of recovery codes, invalidating the old ones.
+func (h *HTTPHandler) regenerateRecoveryCodes(c *gin.Context) {
+	var req RecoveryCodesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Mark as verified if not already
-	if !stored.Verified {
-		if err := h.svc.TOTP().MarkVerified(c.Request.Context(), stored.ID); err != nil {
-			h.logger.Error("Failed to mark TOTP verified", zap.Error(err))
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify TOTP"})
+	codes, err := h.svc.RegenerateRecoveryCodes(c.Request.Context(), middleware.SubjectFromContext(c), req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrTOTPNotEnrolled):
+			c.JSON(http.StatusNotFound, gin.H{"error": "TOTP not enrolled for this user"})
+			return
+		case errors.Is(err, ErrInvalidTOTPCode):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "a current TOTP or recovery code is required"})
 			return
 		}
+		h.logger.Error("Failed to regenerate recovery codes", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to regenerate recovery codes"})
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"verified": true})
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
 }
 
 func (h *HTTPHandler) deleteTOTP(c *gin.Context) {
-	userID := c.Query("user_id")
-	if userID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id query parameter required"})
-		return
-	}
-
-	tenantID := c.GetHeader("X-Tenant-ID")
-	if tenantID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Tenant-ID header required"})
-		return
+	var req TOTPDeleteRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 	}
 
-	if err := h.svc.TOTP().Delete(c.Request.Context(), tenantID, userID); err != nil {
-		h.logger.Error("Failed to delete TOTP", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete TOTP"})
+	if err := h.svc.DeleteTOTP(c.Request.Context(), middleware.SubjectFromContext(c), req.Code); err != nil {
+		switch {
+		case errors.Is(err, ErrTOTPNotEnrolled):
+			c.JSON(http.StatusNotFound, gin.H{"error": "TOTP not enrolled for this user"})
+		case errors.Is(err, ErrInvalidTOTPCode):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "a current TOTP or recovery code is required"})
+		default:
+			h.logger.Error("Failed to delete TOTP", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete TOTP"})
+		}
 		return
 	}
 
@@ -165,19 +164,13 @@ func (h *HTTPHandler) deleteTOTP(c *gin.Context) {
 }
 
 func (h *HTTPHandler) getTOTPStatus(c *gin.Context) {
-	userID := c.Query("user_id")
-	if userID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id query parameter required"})
-		return
-	}
-
-	tenantID := c.GetHeader("X-Tenant-ID")
-	if tenantID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Tenant-ID header required"})
+	tenantID, err := middleware.TenantIDFromGinContext(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing tenant context"})
 		return
 	}
 
-	stored, err := h.svc.TOTP().GetByIdentity(c.Request.Context(), tenantID, userID)
+	stored, err := h.svc.TOTP().GetByIdentity(c.Request.Context(), tenantID, middleware.SubjectFromContext(c))
 	if err != nil {
 		h.logger.Error("Failed to get TOTP status", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get TOTP status"})
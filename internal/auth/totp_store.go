@@ -4,8 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -14,17 +16,24 @@ type TOTPSecret struct {
 	ID         string     `db:"id" json:"id"`
 	IdentityID string     `db:"identity_id" json:"identity_id"`
 	TenantID   string     `db:"tenant_id" json:"tenant_id"`
-	Secret     string     `db:"secret" json:"-"` // Never expose in JSON
+	Secret     string     `db:"secret" json:"-"` // Encrypted at rest; never expose in JSON
 	Verified   bool       `db:"verified" json:"verified"`
 	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
 	VerifiedAt *time.Time `db:"verified_at" json:"verified_at,omitempty"`
+	// LastUsedStep is the most recent accepted TOTP time step, used to reject replays.
+	LastUsedStep *int64 `db:"last_used_step" json:"-"`
 }
 
 // TOTPStore defines the interface for TOTP secret storage.
 type TOTPStore interface {
+	// Create stores an unverified secret, replacing an unverified one. It
+	// returns ErrTOTPAlreadyEnrolled when the identity's secret is verified.
 	Create(ctx context.Context, secret *TOTPSecret) error
 	GetByIdentity(ctx context.Context, tenantID, identityID string) (*TOTPSecret, error)
 	MarkVerified(ctx context.Context, id string) error
+	// MarkUsed records step as consumed. It returns false if step is not newer
+	// than the last accepted step, i.e. the code is being replayed.
+	MarkUsed(ctx context.Context, id string, step int64) (bool, error)
 	Delete(ctx context.Context, tenantID, identityID string) error
 }
 
@@ -42,20 +51,26 @@ func (r *totpRepo) Create(ctx context.Context, secret *TOTPSecret) error {
 		INSERT INTO totp_secrets (identity_id, tenant_id, secret, verified)
 		VALUES ($1, $2, $3, $4)
 		ON CONFLICT (identity_id, tenant_id) 
-		DO UPDATE SET secret = EXCLUDED.secret, verified = FALSE, verified_at = NULL
+		DO UPDATE SET secret = EXCLUDED.secret, verified = FALSE, verified_at = NULL, last_used_step = NULL
+		WHERE totp_secrets.verified = FALSE
 		RETURNING id, created_at
 	`
-	return r.db.QueryRowxContext(ctx, query,
+	err := r.db.QueryRowxContext(ctx, query,
 		secret.IdentityID,
 		secret.TenantID,
 		secret.Secret,
 		false,
 	).Scan(&secret.ID, &secret.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		// The conflicting row is verified, so the update was skipped.
+		return ErrTOTPAlreadyEnrolled
+	}
+	return err
 }
 
 func (r *totpRepo) GetByIdentity(ctx context.Context, tenantID, identityID string) (*TOTPSecret, error) {
 	var secret TOTPSecret
-	query := `SELECT id, identity_id, tenant_id, secret, verified, created_at, verified_at FROM totp_secrets WHERE tenant_id = $1 AND identity_id = $2`
+	query := `SELECT id, identity_id, tenant_id, secret, verified, created_at, verified_at, last_used_step FROM totp_secrets WHERE tenant_id = $1 AND identity_id = $2`
 	err := r.db.GetContext(ctx, &secret, query, tenantID, identityID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -72,8 +87,103 @@ func (r *totpRepo) MarkVerified(ctx context.Context, id string) error {
 	return err
 }
 
+func (r *totpRepo) MarkUsed(ctx context.Context, id string, step int64) (bool, error) {
+	query := `UPDATE totp_secrets SET last_used_step = $2 WHERE id = $1 AND (last_used_step IS NULL OR last_used_step < $2)`
+	result, err := r.db.ExecContext(ctx, query, id, step)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
 func (r *totpRepo) Delete(ctx context.Context, tenantID, identityID string) error {
 	query := `DELETE FROM totp_secrets WHERE tenant_id = $1 AND identity_id = $2`
 	_, err := r.db.ExecContext(ctx, query, tenantID, identityID)
 	return err
 }
+
+// totpMemoryStore is an in-memory TOTPStore used when no database is configured.
+type totpMemoryStore struct {
+	mu      sync.Mutex
+	secrets map[string]*TOTPSecret
+}
+
+func newTOTPMemoryStore() *totpMemoryStore {
+	return &totpMemoryStore{secrets: make(map[string]*TOTPSecret)}
+}
+
+func (s *totpMemoryStore) key(tenantID, identityID string) string {
+	return tenantID + "::" + identityID
+}
+
+func (s *totpMemoryStore) Create(ctx context.Context, secret *TOTPSecret) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.secrets[s.key(secret.TenantID, secret.IdentityID)]; ok && existing.Verified {
+		return ErrTOTPAlreadyEnrolled
+	}
+	secret.ID = uuid.New().String()
+	secret.CreatedAt = time.Now()
+	secret.Verified = false
+	secret.VerifiedAt = nil
+	secret.LastUsedStep = nil
+	stored := *secret
+	s.secrets[s.key(secret.TenantID, secret.IdentityID)] = &stored
+	return nil
+}
+
+func (s *totpMemoryStore) GetByIdentity(ctx context.Context, tenantID, identityID string) (*TOTPSecret, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.secrets[s.key(tenantID, identityID)]
+	if !ok {
+		return nil, nil
+	}
+	copied := *stored
+	return &copied, nil
+}
+
+func (s *totpMemoryStore) byID(id string) *TOTPSecret {
+	for _, stored := range s.secrets {
+		if stored.ID == id {
+			return stored
+		}
+	}
+	return nil
+}
+
+func (s *totpMemoryStore) MarkVerified(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stored := s.byID(id); stored != nil {
+		now := time.Now()
+		stored.Verified = true
+		stored.VerifiedAt = &now
+	}
+	return nil
+}
+
+func (s *totpMemoryStore) MarkUsed(ctx context.Context, id string, step int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := s.byID(id)
+	if stored == nil {
+		return false, nil
+	}
+	if stored.LastUsedStep != nil && *stored.LastUsedStep >= step {
+		return false, nil
+	}
+	stored.LastUsedStep = &step
+	return true, nil
+}
+
+func (s *totpMemoryStore) Delete(ctx context.Context, tenantID, identityID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.secrets, s.key(tenantID, identityID))
+	return nil
+}
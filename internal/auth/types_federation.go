@@ -1,9 +1,12 @@
 package auth
 
+import "strings"
+
 // SocialLoginRequest represents the payload for social login.
 type SocialLoginRequest struct {
 	Provider    string `json:"provider" binding:"required"`
 	Code        string `json:"code"`     // For auth code flow
+	State       string `json:"state"`    // Issued by the social login start
 	IDToken     string `json:"id_token"` // For implicit/mobile
 	RedirectURI string `json:"redirect_uri"`
 
@@ -11,3 +14,25 @@ type SocialLoginRequest struct {
 	Email      string `json:"email"`
 	ExternalID string `json:"external_id"`
 }
+
+// FederatedProfile is the normalized profile an external identity provider
+// reported, stored as a federated identity's profile data.
+type FederatedProfile struct {
+	Subject string `json:"sub"`
+	Email   string `json:"email,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Picture string `json:"picture,omitempty"`
+	// EmailVerified reports whether the provider vouches for the email.
+	EmailVerified bool `json:"email_verified,omitempty"`
+}
+
+// normalize trims surrounding whitespace from the claims.
+func (p FederatedProfile) normalize() FederatedProfile {
+	return FederatedProfile{
+		Subject:       strings.TrimSpace(p.Subject),
+		Email:         strings.TrimSpace(p.Email),
+		Name:          strings.TrimSpace(p.Name),
+		Picture:       strings.TrimSpace(p.Picture),
+		EmailVerified: p.EmailVerified,
+	}
+}
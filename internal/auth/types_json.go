@@ -3,31 +3,48 @@ package auth
 import (
 	"database/sql/driver"
 	"encoding/json"
-	"errors"
+	"fmt"
 )
 
-// JSON is a wrapper for JSONB fields that implements sql.Scanner and driver.Valuer.
+// JSON is a wrapper for JSONB fields that implements sql.Scanner and
+// driver.Valuer. It marshals as the JSON it holds, not as base64.
 type JSON json.RawMessage
 
-// Scan implements the sql.Scanner interface.
+// Scan implements the sql.Scanner interface. The bytes are copied, since
+// drivers may reuse their buffers after Scan returns.
 func (j *JSON) Scan(value interface{}) error {
-	if value == nil {
+	switch v := value.(type) {
+	case nil:
 		*j = nil
-		return nil
+	case []byte:
+		*j = append(JSON(nil), v...)
+	case string:
+		*j = JSON(v)
+	default:
+		return fmt.Errorf("cannot scan %T into JSON", value)
 	}
-	bytes, ok := value.([]byte)
-	if !ok {
-		return errors.New("type assertion to []byte failed")
-	}
-	result := json.RawMessage(bytes)
-	*j = JSON(result)
 	return nil
 }
 
-// Value implements the driver.Valuer interface.
+// Value implements the driver.Valuer interface. It returns a plain []byte,
+// as drivers do not accept named byte slice types.
 func (j JSON) Value() (driver.Value, error) {
 	if len(j) == 0 {
 		return nil, nil
 	}
-	return json.RawMessage(j), nil
+	return []byte(j), nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (j JSON) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return []byte("null"), nil
+	}
+	return json.RawMessage(j).MarshalJSON()
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (j *JSON) UnmarshalJSON(data []byte) error {
+	*j = append((*j)[:0], data...)
+	return nil
 }
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"testing"
+)
+
+func TestJSONRoundTripsThroughDriver(t *testing.T) {
+	const raw = `{"sub":"1234","email":"ada@example.com"}`
+	in := JSON(raw)
+
+	// database/sql converts arguments with the default converter, which only
+	// accepts plain driver value types from Value.
+	v, err := driver.DefaultParameterConverter.ConvertValue(in)
+	if err != nil {
+		t.Fatalf("ConvertValue failed: %v", err)
+	}
+	b, ok := v.([]byte)
+	if !ok {
+		t.Fatalf("expected []byte, got %T", v)
+	}
+
+	var out JSON
+	if err := out.Scan(b); err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	b[0] = 'x' // the driver reusing its buffer must not change out
+	if string(out) != raw {
+		t.Fatalf("expected %s, got %s", raw, out)
+	}
+
+	if err := out.Scan(`{"sub":"5678"}`); err != nil || string(out) != `{"sub":"5678"}` {
+		t.Fatalf("Scan(string) = %s, %v", out, err)
+	}
+	if err := out.Scan(nil); err != nil || out != nil {
+		t.Fatalf("Scan(nil) = %s, %v", out, err)
+	}
+	if v, err := out.Value(); err != nil || v != nil {
+		t.Fatalf("expected empty JSON to be NULL, got %v, %v", v, err)
+	}
+	if err := out.Scan(42); err == nil {
+		t.Fatal("expected scanning an int to fail")
+	}
+}
+
+func TestJSONMarshalsAsRawJSON(t *testing.T) {
+	b, err := json.Marshal(struct {
+		Profile JSON `json:"profile"`
+		Empty   JSON `json:"empty"`
+	}{Profile: JSON(`{"sub":"1234"}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"profile":{"sub":"1234"},"empty":null}` {
+		t.Fatalf("unexpected encoding %s", b)
+	}
+}
+
+func TestFederatedIdentityProfile(t *testing.T) {
+	want := FederatedProfile{Subject: "1234", Email: "ada@example.com", Name: "Ada Lovelace", Picture: "https://example.com/ada.png"}
+	data, _ := json.Marshal(want)
+	got, err := FederatedIdentity{ProfileData: JSON(data)}.Profile()
+	if err != nil || got != want {
+		t.Fatalf("Profile() = %+v, %v", got, err)
+	}
+
+	// Links recorded before full profiles were stored carry only the email.
+	got, err = FederatedIdentity{ProfileData: JSON(`{"email":"ada@example.com"}`)}.Profile()
+	if err != nil || got != (FederatedProfile{Email: "ada@example.com"}) {
+		t.Fatalf("Profile() = %+v, %v", got, err)
+	}
+	if got, err := (FederatedIdentity{}).Profile(); err != nil || got != (FederatedProfile{}) {
+		t.Fatalf("Profile() of an empty identity = %+v, %v", got, err)
+	}
+}
@@ -1,6 +1,7 @@
 package connector
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/dhawalhost/wardseal/pkg/middleware"
@@ -42,6 +43,17 @@ func (h *HTTPHandler) tenantID(c *gin.Context) (string, bool) {
 	return tenantID, true
 }
 
+// errorBody renders a create or update error, listing the offending fields
+// of an invalid config.
+func errorBody(err error) gin.H {
+	body := gin.H{"error": err.Error()}
+	var cfgErr *ConfigError
+	if errors.As(err, &cfgErr) {
+		body["fields"] = cfgErr.Fields
+	}
+	return body
+}
+
 func (h *HTTPHandler) listConnectors(c *gin.Context) {
 	tenantID, ok := h.tenantID(c)
 	if !ok {
@@ -73,7 +85,7 @@ func (h *HTTPHandler) createConnector(c *gin.Context) {
 	id, err := h.svc.CreateConnector(c.Request.Context(), tenantID, config)
 	if err != nil {
 		h.logger.Error("Failed to create connector", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, errorBody(err))
 		return
 	}
 
@@ -111,7 +123,7 @@ func (h *HTTPHandler) updateConnector(c *gin.Context) {
 
 	if err := h.svc.UpdateConnector(c.Request.Context(), tenantID, config); err != nil {
 		h.logger.Error("Failed to update connector", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, errorBody(err))
 		return
 	}
 
@@ -159,9 +171,7 @@ func (h *HTTPHandler) toggleConnector(c *gin.Context) {
 }
 
 func (h *HTTPHandler) testConnection(c *gin.Context) {
-	// For testing, tenant ID isn't strictly necessary but good practice
-	_, ok := h.tenantID(c)
-	if !ok {
+	if _, ok := h.tenantID(c); !ok {
 		return
 	}
 
@@ -170,11 +180,15 @@ func (h *HTTPHandler) testConnection(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	if err := h.svc.TestConnection(c.Request.Context(), config); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "status": "failed"})
+	if config.Type == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "connector type is required"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "success"})
+	// A failed test is still a successful request; the result says why.
+	result := h.svc.TestConnection(c.Request.Context(), config)
+	if !result.OK {
+		h.logger.Info("Connector test failed", zap.String("type", config.Type), zap.String("stage", result.Stage), zap.String("error", result.Error))
+	}
+	c.JSON(http.StatusOK, result)
 }
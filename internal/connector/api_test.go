@@ -0,0 +1,128 @@
+package connector_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dhawalhost/wardseal/internal/connector"
+	"github.com/dhawalhost/wardseal/internal/connector/memory"
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func newTestRouter(t *testing.T) (*gin.Engine, connector.Registry) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	registry := connector.NewRegistry()
+	registry.Register("memory", memory.New, memory.Schema)
+	// No store: testing a connection must not persist anything.
+	handler := connector.NewHTTPHandler(connector.NewService(nil, registry), zap.NewNop())
+	r := gin.New()
+	api := r.Group("/")
+	api.Use(middleware.TenantExtractor(middleware.TenantConfig{}))
+	handler.RegisterRoutes(api)
+	return r, registry
+}
+
+func postConnectionTest(t *testing.T, r *gin.Engine, body string) (int, connector.ConnectionTestResult) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/connectors/test", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(middleware.DefaultTenantHeader, "22222222-2222-2222-2222-222222222222")
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+
+	var result connector.ConnectionTestResult
+	if resp.Code == http.StatusOK {
+		if err := json.Unmarshal(resp.Body.Bytes(), &result); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+	}
+	return resp.Code, result
+}
+
+func TestConnectionTestReportsInitializeFailure(t *testing.T) {
+	r, registry := newTestRouter(t)
+
+	code, result := postConnectionTest(t, r, `{"name":"broken","type":"memory","settings":{"fail_initialize":"invalid bind credentials"}}`)
+
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if result.OK || result.Stage != "initialize" || result.Error != "invalid bind credentials" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if len(registry.List()) != 0 {
+		t.Fatalf("expected the transient connector not to be registered")
+	}
+}
+
+func TestConnectionTestReportsHealthCheckFailure(t *testing.T) {
+	r, _ := newTestRouter(t)
+
+	_, result := postConnectionTest(t, r, `{"name":"down","type":"memory","settings":{"fail_health_check":"endpoint unreachable"}}`)
+
+	if result.OK || result.Stage != "health_check" || result.Error != "endpoint unreachable" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
+
+func TestConnectionTestSucceeds(t *testing.T) {
+	r, registry := newTestRouter(t)
+
+	_, result := postConnectionTest(t, r, `{"name":"ok","type":"memory"}`)
+
+	if !result.OK || result.Error != "" {
+		t.Fatalf("expected a successful test, got %+v", result)
+	}
+	if len(registry.List()) != 0 {
+		t.Fatalf("expected the transient connector not to be registered")
+	}
+}
+
+func TestConnectionTestRejectsUnknownType(t *testing.T) {
+	r, _ := newTestRouter(t)
+
+	_, result := postConnectionTest(t, r, `{"name":"x","type":"carrier-pigeon"}`)
+	if result.OK || result.Stage != "create" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	if code, _ := postConnectionTest(t, r, `{"name":"x"}`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without a type, got %d", code)
+	}
+}
+
+func TestCreateConnectorListsInvalidFields(t *testing.T) {
+	r, _ := newTestRouter(t)
+	req := httptest.NewRequest(http.MethodPost, "/connectors", strings.NewReader(`{"name":"hr","type":"okta"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(middleware.DefaultTenantHeader, "22222222-2222-2222-2222-222222222222")
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown type, got %d", resp.Code)
+	}
+
+	r, registry := newTestRouter(t)
+	registry.Register("strict", memory.New, connector.ConfigSchema{Endpoint: true, Credentials: []string{"token"}})
+	req = httptest.NewRequest(http.MethodPost, "/connectors", strings.NewReader(`{"name":"hr","type":"strict"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(middleware.DefaultTenantHeader, "22222222-2222-2222-2222-222222222222")
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+
+	var body struct {
+		Fields []connector.FieldError `json:"fields"`
+	}
+	if resp.Code != http.StatusBadRequest || json.Unmarshal(resp.Body.Bytes(), &body) != nil {
+		t.Fatalf("expected 400 with fields, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if len(body.Fields) != 2 || body.Fields[0].Field != "endpoint" || body.Fields[1].Field != "credentials.token" {
+		t.Fatalf("unexpected fields %+v", body.Fields)
+	}
+}
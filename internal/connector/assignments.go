@@ -0,0 +1,77 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// LicenseAssigner is implemented by connectors whose targets license users,
+// such as Microsoft 365 SKUs in Azure AD.
+type LicenseAssigner interface {
+	AssignLicense(ctx context.Context, userID, skuID string) error
+}
+
+// RoleAssigner is implemented by connectors whose targets grant directory
+// roles to users.
+type RoleAssigner interface {
+	AssignDirectoryRole(ctx context.Context, userID, roleID string) error
+}
+
+// PasswordSetter is implemented by connectors that can set a user's password
+// in the target, for onboarding with a known password or forcing a reset.
+// When forceChange is true the user must choose a new password at next
+// sign-in, where the target supports it.
+type PasswordSetter interface {
+	SetPassword(ctx context.Context, userID, password string, forceChange bool) error
+}
+
+// Provisioning operations for the optional assignment capabilities.
+const (
+	// OperationAssignLicense takes a {"user_id", "sku_id"} payload.
+	OperationAssignLicense = "assign_license"
+	// OperationAssignDirectoryRole takes a {"user_id", "role_id"} payload.
+	OperationAssignDirectoryRole = "assign_directory_role"
+)
+
+// assignmentPayload is the payload of the assignment operations.
+type assignmentPayload struct {
+	UserID string `json:"user_id"`
+	SkuID  string `json:"sku_id"`
+	RoleID string `json:"role_id"`
+}
+
+// executeAssignment runs an assignment operation, failing when the connector
+// lacks the capability.
+func executeAssignment(ctx context.Context, conn Connector, operation string, payload []byte) error {
+	var p assignmentPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return err
+	}
+	if p.UserID == "" {
+		return fmt.Errorf("%s needs user_id", operation)
+	}
+
+	switch operation {
+	case OperationAssignLicense:
+		assigner, ok := conn.(LicenseAssigner)
+		if !ok {
+			return fmt.Errorf("%s connector cannot assign licenses", conn.Type())
+		}
+		if p.SkuID == "" {
+			return fmt.Errorf("%s needs sku_id", operation)
+		}
+		return assigner.AssignLicense(ctx, p.UserID, p.SkuID)
+	case OperationAssignDirectoryRole:
+		assigner, ok := conn.(RoleAssigner)
+		if !ok {
+			return fmt.Errorf("%s connector cannot assign directory roles", conn.Type())
+		}
+		if p.RoleID == "" {
+			return fmt.Errorf("%s needs role_id", operation)
+		}
+		return assigner.AssignDirectoryRole(ctx, p.UserID, p.RoleID)
+	default:
+		return fmt.Errorf("unknown operation: %s", operation)
+	}
+}
@@ -0,0 +1,199 @@
+package connector
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SettingAttributeMap holds a JSON object that overrides which source
+// attribute each User field is read from and written to, keyed by field:
+//
+//	{"username": "employeeId", "email": "mail"}
+//
+// Fields that are not listed keep the connector's default attribute.
+const SettingAttributeMap = "attribute_map"
+
+// User fields an attribute map can target. The names match User's JSON tags.
+// The profile fields (phone, title, department, location) have no default
+// attribute in any connector; they are synced once mapped.
+const (
+	FieldUsername    = "username"
+	FieldEmail       = "email"
+	FieldFirstName   = "first_name"
+	FieldLastName    = "last_name"
+	FieldDisplayName = "display_name"
+	FieldPhone       = "phone"
+	FieldTitle       = "title"
+	FieldDepartment  = "department"
+	FieldLocation    = "location"
+)
+
+// AttributeMap maps User fields to the source attribute each is stored in.
+type AttributeMap map[string]string
+
+// ParseAttributeMap returns defaults overridden by the connector's
+// attribute_map setting. It fails on malformed JSON, unknown fields and
+// empty attribute names, so connectors call it from Initialize.
+func ParseAttributeMap(config Config, defaults AttributeMap) (AttributeMap, error) {
+	m := make(AttributeMap, len(defaults))
+	for field, attr := range defaults {
+		m[field] = attr
+	}
+	raw := config.Settings[SettingAttributeMap]
+	if raw == "" {
+		return m, nil
+	}
+
+	var overrides map[string]string
+	if err := json.Unmarshal([]byte(raw), &overrides); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", SettingAttributeMap, err)
+	}
+	for field, attr := range overrides {
+		if userField(&User{}, field) == nil {
+			return nil, fmt.Errorf("invalid %s: unknown user field %q", SettingAttributeMap, field)
+		}
+		if strings.TrimSpace(attr) == "" {
+			return nil, fmt.Errorf("invalid %s: empty attribute for field %q", SettingAttributeMap, field)
+		}
+		m[field] = attr
+	}
+	return m, nil
+}
+
+// Attributes returns the distinct source attributes in the map, sorted.
+func (m AttributeMap) Attributes() []string {
+	seen := make(map[string]bool, len(m))
+	attrs := make([]string, 0, len(m))
+	for _, attr := range m {
+		if !seen[attr] {
+			seen[attr] = true
+			attrs = append(attrs, attr)
+		}
+	}
+	sort.Strings(attrs)
+	return attrs
+}
+
+// Overrides returns the fields whose attribute differs from defaults.
+func (m AttributeMap) Overrides(defaults AttributeMap) AttributeMap {
+	overrides := AttributeMap{}
+	for field, attr := range m {
+		if defaults[field] != attr {
+			overrides[field] = attr
+		}
+	}
+	return overrides
+}
+
+// ApplyTo sets each mapped field of user from get, which returns the value of
+// a source attribute.
+func (m AttributeMap) ApplyTo(user *User, get func(attr string) string) {
+	for field, attr := range m {
+		if p := userField(user, field); p != nil {
+			*p = get(attr)
+		}
+	}
+}
+
+// Values returns the non-empty mapped fields of user keyed by source
+// attribute. When two fields share an attribute, the first non-empty field in
+// sorted field order wins.
+func (m AttributeMap) Values(user User) map[string]string {
+	fields := make([]string, 0, len(m))
+	for field := range m {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	values := make(map[string]string, len(m))
+	for _, field := range fields {
+		attr := m[field]
+		if p := userField(&user, field); p != nil && *p != "" {
+			if _, ok := values[attr]; !ok {
+				values[attr] = *p
+			}
+		}
+	}
+	return values
+}
+
+func userField(user *User, field string) *string {
+	switch field {
+	case FieldUsername:
+		return &user.Username
+	case FieldEmail:
+		return &user.Email
+	case FieldFirstName:
+		return &user.FirstName
+	case FieldLastName:
+		return &user.LastName
+	case FieldDisplayName:
+		return &user.DisplayName
+	case FieldPhone:
+		return &user.Phone
+	case FieldTitle:
+		return &user.Title
+	case FieldDepartment:
+		return &user.Department
+	case FieldLocation:
+		return &user.Location
+	}
+	return nil
+}
+
+// FlattenJSON flattens a decoded JSON object into dotted attribute paths
+// ("name.givenName"). For arrays of objects the primary element, or else the
+// first, stands for the attribute ("emails.value"). Non-string scalars are
+// formatted with fmt.
+func FlattenJSON(obj map[string]interface{}) map[string]string {
+	flat := make(map[string]string)
+	flattenInto(flat, "", obj)
+	return flat
+}
+
+func flattenInto(flat map[string]string, prefix string, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			path := key
+			if prefix != "" {
+				path = prefix + "." + key
+			}
+			flattenInto(flat, path, child)
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			return
+		}
+		chosen := v[0]
+		for _, item := range v {
+			if obj, ok := item.(map[string]interface{}); ok && obj["primary"] == true {
+				chosen = item
+				break
+			}
+		}
+		flattenInto(flat, prefix, chosen)
+	case string:
+		flat[prefix] = v
+	case nil:
+	default:
+		flat[prefix] = fmt.Sprint(v)
+	}
+}
+
+// SetJSONPath sets a dotted attribute path in obj, creating nested objects
+// as needed.
+func SetJSONPath(obj map[string]interface{}, path string, value interface{}) {
+	parts := strings.Split(path, ".")
+	for _, part := range parts[:len(parts)-1] {
+		child, ok := obj[part].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			obj[part] = child
+		}
+		obj = child
+	}
+	obj[parts[len(parts)-1]] = value
+}
@@ -19,21 +19,50 @@ const (
 	loginURL     = "https://login.microsoftonline.com"
 )
 
+// defaultAttributes maps User fields to Microsoft Graph user properties.
+var defaultAttributes = connector.AttributeMap{
+	connector.FieldUsername:    "userPrincipalName",
+	connector.FieldEmail:       "mail",
+	connector.FieldFirstName:   "givenName",
+	connector.FieldLastName:    "surname",
+	connector.FieldDisplayName: "displayName",
+}
+
+// Schema is the Azure AD connector's config schema: the app registration
+// used for the client credentials grant.
+var Schema = connector.ConfigSchema{
+	Credentials: []string{"tenant_id", "client_id", "client_secret"},
+}
+
 // Connector implements the connector.Connector interface for Azure AD via Microsoft Graph.
 type Connector struct {
 	config      connector.Config
 	httpClient  *http.Client
 	accessToken string
 	tokenExpiry time.Time
+	attrs       connector.AttributeMap
+	signer      connector.RequestSigner
 }
 
 // New creates a new Azure AD connector.
 func New(config connector.Config) (connector.Connector, error) {
+	attrs, err := connector.ParseAttributeMap(config, defaultAttributes)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := connector.ParseRequestSigner(config)
+	if err != nil {
+		return nil, err
+	}
+	httpClient, err := connector.NewHTTPClient(config)
+	if err != nil {
+		return nil, err
+	}
 	return &Connector{
-		config: config,
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		config:     config,
+		httpClient: httpClient,
+		attrs:      attrs,
+		signer:     signer,
 	}, nil
 }
 
@@ -41,8 +70,30 @@ func (c *Connector) ID() string   { return c.config.ID }
 func (c *Connector) Name() string { return c.config.Name }
 func (c *Connector) Type() string { return "azure-ad" }
 
+// Capabilities reports license and directory role assignment and password
+// changes.
+func (c *Connector) Capabilities() connector.CapabilitySet {
+	return connector.NewCapabilitySet(connector.CapabilityAssignLicense, connector.CapabilityAssignDirectoryRole,
+		connector.CapabilitySetPassword)
+}
+
 func (c *Connector) Initialize(ctx context.Context, config connector.Config) error {
+	attrs, err := connector.ParseAttributeMap(config, defaultAttributes)
+	if err != nil {
+		return err
+	}
+	signer, err := connector.ParseRequestSigner(config)
+	if err != nil {
+		return err
+	}
+	httpClient, err := connector.NewHTTPClient(config)
+	if err != nil {
+		return err
+	}
 	c.config = config
+	c.httpClient = httpClient
+	c.attrs = attrs
+	c.signer = signer
 	return c.authenticate(ctx)
 }
 
@@ -100,7 +151,7 @@ func (c *Connector) HealthCheck(ctx context.Context) error {
 	}
 	req, _ := http.NewRequestWithContext(ctx, "GET", graphBaseURL+"/organization", nil)
 	c.setHeaders(req)
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	defer func() { _ = resp.Body.Close() }()
 
 	if err != nil {
@@ -132,12 +183,13 @@ func (c *Connector) CreateUser(ctx context.Context, user connector.User) (string
 			"password":                      generateTempPassword(),
 		},
 	}
+	c.setMappedAttributes(userData, user)
 	body, _ := json.Marshal(userData)
 
 	req, _ := http.NewRequestWithContext(ctx, "POST", graphBaseURL+"/users", bytes.NewReader(body))
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	defer func() { _ = resp.Body.Close() }()
 
 	if err != nil {
@@ -149,7 +201,9 @@ func (c *Connector) CreateUser(ctx context.Context, user connector.User) (string
 		return "", fmt.Errorf("create user failed: %s", string(respBody))
 	}
 
-	var result graphUserResponse
+	var result struct {
+		ID string `json:"id"`
+	}
 	_ = json.NewDecoder(resp.Body).Decode(&result)
 	return result.ID, nil
 }
@@ -159,10 +213,10 @@ func (c *Connector) GetUser(ctx context.Context, id string) (connector.User, err
 		return connector.User{}, err
 	}
 
-	req, _ := http.NewRequestWithContext(ctx, "GET", graphBaseURL+"/users/"+id, nil)
+	req, _ := http.NewRequestWithContext(ctx, "GET", graphBaseURL+"/users/"+id+c.userSelect("?"), nil)
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	defer func() { _ = resp.Body.Close() }()
 
 	if err != nil {
@@ -173,9 +227,9 @@ func (c *Connector) GetUser(ctx context.Context, id string) (connector.User, err
 		return connector.User{}, fmt.Errorf("user not found")
 	}
 
-	var result graphUserResponse
+	var result map[string]interface{}
 	_ = json.NewDecoder(resp.Body).Decode(&result)
-	return fromGraphUser(result), nil
+	return fromGraphUser(result, c.attrs), nil
 }
 
 func (c *Connector) UpdateUser(ctx context.Context, id string, user connector.User) error {
@@ -194,12 +248,13 @@ func (c *Connector) UpdateUser(ctx context.Context, id string, user connector.Us
 		userData["surname"] = user.LastName
 	}
 	userData["accountEnabled"] = user.Active
+	c.setMappedAttributes(userData, user)
 
 	body, _ := json.Marshal(userData)
 	req, _ := http.NewRequestWithContext(ctx, "PATCH", graphBaseURL+"/users/"+id, bytes.NewReader(body))
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	defer func() { _ = resp.Body.Close() }()
 
 	if err != nil {
@@ -220,7 +275,7 @@ func (c *Connector) DeleteUser(ctx context.Context, id string) error {
 	req, _ := http.NewRequestWithContext(ctx, "DELETE", graphBaseURL+"/users/"+id, nil)
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	defer func() { _ = resp.Body.Close() }()
 
 	if err != nil {
@@ -234,7 +289,7 @@ func (c *Connector) ListUsers(ctx context.Context, filter string, limit, offset
 		return nil, 0, err
 	}
 
-	url := fmt.Sprintf("%s/users?$top=%d&$skip=%d", graphBaseURL, limit, offset)
+	url := fmt.Sprintf("%s/users?$top=%d&$skip=%d", graphBaseURL, limit, offset) + c.userSelect("&")
 	if filter != "" {
 		url += "&$filter=" + filter
 	}
@@ -242,7 +297,7 @@ func (c *Connector) ListUsers(ctx context.Context, filter string, limit, offset
 	req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	defer func() { _ = resp.Body.Close() }()
 
 	if err != nil {
@@ -250,13 +305,13 @@ func (c *Connector) ListUsers(ctx context.Context, filter string, limit, offset
 	}
 
 	var result struct {
-		Value []graphUserResponse `json:"value"`
+		Value []map[string]interface{} `json:"value"`
 	}
 	_ = json.NewDecoder(resp.Body).Decode(&result)
 
 	users := make([]connector.User, len(result.Value))
 	for i, u := range result.Value {
-		users[i] = fromGraphUser(u)
+		users[i] = fromGraphUser(u, c.attrs)
 	}
 	return users, len(users), nil // Graph doesn't return total easily
 }
@@ -279,7 +334,7 @@ func (c *Connector) CreateGroup(ctx context.Context, group connector.Group) (str
 	req, _ := http.NewRequestWithContext(ctx, "POST", graphBaseURL+"/groups", bytes.NewReader(body))
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	defer func() { _ = resp.Body.Close() }()
 
 	if err != nil {
@@ -301,7 +356,7 @@ func (c *Connector) GetGroup(ctx context.Context, id string) (connector.Group, e
 	req, _ := http.NewRequestWithContext(ctx, "GET", graphBaseURL+"/groups/"+id, nil)
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	defer func() { _ = resp.Body.Close() }()
 
 	if err != nil {
@@ -335,7 +390,7 @@ func (c *Connector) UpdateGroup(ctx context.Context, id string, group connector.
 	req, _ := http.NewRequestWithContext(ctx, "PATCH", graphBaseURL+"/groups/"+id, bytes.NewReader(body))
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	defer func() { _ = resp.Body.Close() }()
 
 	if err != nil {
@@ -352,7 +407,7 @@ func (c *Connector) DeleteGroup(ctx context.Context, id string) error {
 	req, _ := http.NewRequestWithContext(ctx, "DELETE", graphBaseURL+"/groups/"+id, nil)
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	defer func() { _ = resp.Body.Close() }()
 
 	if err != nil {
@@ -370,7 +425,7 @@ func (c *Connector) ListGroups(ctx context.Context, filter string, limit, offset
 	req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	defer func() { _ = resp.Body.Close() }()
 
 	if err != nil {
@@ -412,12 +467,107 @@ func (c *Connector) AddUserToGroup(ctx context.Context, userID, groupID string)
 		bytes.NewReader(body))
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
+	defer func() { _ = resp.Body.Close() }()
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// AssignLicense adds the license SKU to the user. Graph requires the user's
+// usageLocation to be set first.
+func (c *Connector) AssignLicense(ctx context.Context, userID, skuID string) error {
+	if err := c.ensureAuthenticated(ctx); err != nil {
+		return err
+	}
+
+	data := map[string]interface{}{
+		"addLicenses":    []map[string]string{{"skuId": skuID}},
+		"removeLicenses": []string{},
+	}
+	body, _ := json.Marshal(data)
+
+	req, _ := http.NewRequestWithContext(ctx, "POST",
+		fmt.Sprintf("%s/users/%s/assignLicense", graphBaseURL, url.PathEscape(userID)),
+		bytes.NewReader(body))
+	c.setHeaders(req)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
 	defer func() { _ = resp.Body.Close() }()
 
+	if resp.StatusCode >= 400 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("assign license failed: %s", string(respBody))
+	}
+	return nil
+}
+
+// SetPassword replaces the user's passwordProfile. Graph only lets the
+// application do this with the User Administrator role or higher.
+func (c *Connector) SetPassword(ctx context.Context, userID, password string, forceChange bool) error {
+	if err := c.ensureAuthenticated(ctx); err != nil {
+		return err
+	}
+
+	data := map[string]interface{}{
+		"passwordProfile": map[string]interface{}{
+			"password":                      password,
+			"forceChangePasswordNextSignIn": forceChange,
+		},
+	}
+	body, _ := json.Marshal(data)
+
+	req, _ := http.NewRequestWithContext(ctx, "PATCH",
+		graphBaseURL+"/users/"+url.PathEscape(userID), bytes.NewReader(body))
+	c.setHeaders(req)
+
+	resp, err := c.do(req)
 	if err != nil {
 		return err
 	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode >= 400 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("set password failed: %s", string(respBody))
+	}
+	return nil
+}
+
+// AssignDirectoryRole grants the user a directory role, identified by its
+// role definition ID, across the whole directory.
+func (c *Connector) AssignDirectoryRole(ctx context.Context, userID, roleID string) error {
+	if err := c.ensureAuthenticated(ctx); err != nil {
+		return err
+	}
+
+	data := map[string]string{
+		"principalId":      userID,
+		"roleDefinitionId": roleID,
+		"directoryScopeId": "/",
+	}
+	body, _ := json.Marshal(data)
+
+	req, _ := http.NewRequestWithContext(ctx, "POST",
+		graphBaseURL+"/roleManagement/directory/roleAssignments",
+		bytes.NewReader(body))
+	c.setHeaders(req)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode >= 400 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("assign directory role failed: %s", string(respBody))
+	}
 	return nil
 }
 
@@ -430,7 +580,7 @@ func (c *Connector) RemoveUserFromGroup(ctx context.Context, userID, groupID str
 		fmt.Sprintf("%s/groups/%s/members/%s/$ref", graphBaseURL, groupID, userID), nil)
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	defer func() { _ = resp.Body.Close() }()
 
 	if err != nil {
@@ -448,7 +598,7 @@ func (c *Connector) GetGroupMembers(ctx context.Context, groupID string) ([]conn
 		fmt.Sprintf("%s/groups/%s/members", graphBaseURL, groupID), nil)
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	defer func() { _ = resp.Body.Close() }()
 
 	if err != nil {
@@ -456,13 +606,13 @@ func (c *Connector) GetGroupMembers(ctx context.Context, groupID string) ([]conn
 	}
 
 	var result struct {
-		Value []graphUserResponse `json:"value"`
+		Value []map[string]interface{} `json:"value"`
 	}
 	_ = json.NewDecoder(resp.Body).Decode(&result)
 
 	users := make([]connector.User, len(result.Value))
 	for i, u := range result.Value {
-		users[i] = fromGraphUser(u)
+		users[i] = fromGraphUser(u, c.attrs)
 	}
 	return users, nil
 }
@@ -472,30 +622,54 @@ func (c *Connector) setHeaders(req *http.Request) {
 	req.Header.Set("Content-Type", "application/json")
 }
 
-type graphUserResponse struct {
-	ID                string `json:"id"`
-	DisplayName       string `json:"displayName"`
-	GivenName         string `json:"givenName"`
-	Surname           string `json:"surname"`
-	UserPrincipalName string `json:"userPrincipalName"`
-	Mail              string `json:"mail"`
-	AccountEnabled    bool   `json:"accountEnabled"`
+// do signs a Graph request, when signing is configured, and sends it. The
+// token request in authenticate goes to the identity platform and is not
+// signed.
+func (c *Connector) do(req *http.Request) (*http.Response, error) {
+	if c.signer != nil {
+		if err := c.signer.Sign(req); err != nil {
+			return nil, fmt.Errorf("sign request: %w", err)
+		}
+	}
+	return c.httpClient.Do(req)
+}
+
+// setMappedAttributes writes fields whose attribute_map entry differs from
+// the default; the default properties are already set by the caller.
+func (c *Connector) setMappedAttributes(userData map[string]interface{}, user connector.User) {
+	for attr, value := range c.attrs.Overrides(defaultAttributes).Values(user) {
+		connector.SetJSONPath(userData, attr, value)
+	}
+}
+
+// userSelect returns a $select query parameter, prefixed with sep, that adds
+// remapped properties to the ones Graph returns by default. It is empty when
+// no attribute is remapped.
+func (c *Connector) userSelect(sep string) string {
+	overrides := c.attrs.Overrides(defaultAttributes)
+	if len(overrides) == 0 {
+		return ""
+	}
+	fields := append([]string{"id", "accountEnabled"}, defaultAttributes.Attributes()...)
+	for _, attr := range overrides.Attributes() {
+		// Nested paths select their top-level property.
+		property, _, _ := strings.Cut(attr, ".")
+		fields = append(fields, property)
+	}
+	return sep + "$select=" + strings.Join(fields, ",")
 }
 
-func fromGraphUser(u graphUserResponse) connector.User {
-	email := u.Mail
-	if email == "" {
-		email = u.UserPrincipalName
+func fromGraphUser(u map[string]interface{}, attrs connector.AttributeMap) connector.User {
+	flat := connector.FlattenJSON(u)
+	user := connector.User{
+		ExternalID: flat["id"],
+		Active:     u["accountEnabled"] == true,
 	}
-	return connector.User{
-		ExternalID:  u.ID,
-		Username:    u.UserPrincipalName,
-		Email:       email,
-		FirstName:   u.GivenName,
-		LastName:    u.Surname,
-		DisplayName: u.DisplayName,
-		Active:      u.AccountEnabled,
+	attrs.ApplyTo(&user, func(attr string) string { return flat[attr] })
+	if user.Email == "" {
+		user.Email = flat["userPrincipalName"]
 	}
+	return user
 }
 
 func generateTempPassword() string {
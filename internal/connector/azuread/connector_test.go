@@ -0,0 +1,109 @@
+package azuread
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// redirectTransport sends every request to the mock Graph server, so the
+// connector can keep its fixed Graph base URL.
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (t redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.target.Scheme
+	req.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func newMockConnector(t *testing.T, handler http.HandlerFunc) *Connector {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	target, _ := url.Parse(server.URL)
+	return &Connector{
+		httpClient:  &http.Client{Transport: redirectTransport{target: target}},
+		accessToken: "token",
+		tokenExpiry: time.Now().Add(time.Hour),
+		attrs:       defaultAttributes,
+	}
+}
+
+func TestAssignLicense(t *testing.T) {
+	const skuID = "c7df2760-2c81-4ef7-b578-5b5392b571df"
+	var path, auth string
+	var body struct {
+		AddLicenses []struct {
+			SkuID string `json:"skuId"`
+		} `json:"addLicenses"`
+		RemoveLicenses []string `json:"removeLicenses"`
+	}
+	c := newMockConnector(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.Method + " " + r.URL.Path
+		auth = r.Header.Get("Authorization")
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		_, _ = w.Write([]byte(`{"id": "u1"}`))
+	})
+
+	if err := c.AssignLicense(context.Background(), "u1", skuID); err != nil {
+		t.Fatalf("AssignLicense failed: %v", err)
+	}
+	if path != "POST /v1.0/users/u1/assignLicense" {
+		t.Fatalf("unexpected request %q", path)
+	}
+	if auth != "Bearer token" {
+		t.Fatalf("expected the access token, got %q", auth)
+	}
+	if len(body.AddLicenses) != 1 || body.AddLicenses[0].SkuID != skuID || body.RemoveLicenses == nil {
+		t.Fatalf("unexpected body %+v", body)
+	}
+}
+
+func TestAssignLicenseReportsGraphError(t *testing.T) {
+	c := newMockConnector(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error": {"message": "License assignment cannot be done for user with invalid usage location."}}`))
+	})
+	if err := c.AssignLicense(context.Background(), "u1", "sku"); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestSetPassword(t *testing.T) {
+	var path, contentType string
+	var body map[string]map[string]interface{}
+	c := newMockConnector(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.Method + " " + r.URL.Path
+		contentType = r.Header.Get("Content-Type")
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	if err := c.SetPassword(context.Background(), "u1", "N3w-Passw0rd!", true); err != nil {
+		t.Fatalf("SetPassword failed: %v", err)
+	}
+	if path != "PATCH /v1.0/users/u1" || contentType != "application/json" {
+		t.Fatalf("unexpected request %q (%s)", path, contentType)
+	}
+	profile := body["passwordProfile"]
+	if len(body) != 1 || profile["password"] != "N3w-Passw0rd!" || profile["forceChangePasswordNextSignIn"] != true {
+		t.Fatalf("unexpected body %v", body)
+	}
+}
+
+func TestSetPasswordReportsGraphError(t *testing.T) {
+	c := newMockConnector(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error": {"message": "The specified password does not comply with password complexity requirements."}}`))
+	})
+	if err := c.SetPassword(context.Background(), "u1", "weak", false); err == nil {
+		t.Fatal("expected an error")
+	}
+}
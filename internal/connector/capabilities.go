@@ -0,0 +1,115 @@
+package connector
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Capability names an optional feature a connector may support.
+type Capability string
+
+const (
+	// CapabilitySuspendUser means the connector implements Suspender.
+	CapabilitySuspendUser Capability = "suspend_user"
+	// CapabilityAssignLicense means the connector implements LicenseAssigner.
+	CapabilityAssignLicense Capability = "assign_license"
+	// CapabilityAssignDirectoryRole means the connector implements RoleAssigner.
+	CapabilityAssignDirectoryRole Capability = "assign_directory_role"
+	// CapabilityNestedGroups means GetGroupMembers can expand nested groups.
+	CapabilityNestedGroups Capability = "nested_groups"
+	// CapabilityIncrementalSync means the connector implements ChangeLister.
+	CapabilityIncrementalSync Capability = "incremental_sync"
+	// CapabilitySetPassword means the connector implements PasswordSetter.
+	CapabilitySetPassword Capability = "set_password"
+)
+
+// CapabilitySet is the set of optional features a connector supports.
+type CapabilitySet map[Capability]bool
+
+// NewCapabilitySet returns a set holding caps.
+func NewCapabilitySet(caps ...Capability) CapabilitySet {
+	set := make(CapabilitySet, len(caps))
+	for _, c := range caps {
+		set[c] = true
+	}
+	return set
+}
+
+// Has reports whether the set contains c.
+func (s CapabilitySet) Has(c Capability) bool { return s[c] }
+
+// List returns the capabilities in the set, sorted.
+func (s CapabilitySet) List() []Capability {
+	list := make([]Capability, 0, len(s))
+	for c, ok := range s {
+		if ok {
+			list = append(list, c)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i] < list[j] })
+	return list
+}
+
+// CapabilityProvider is implemented by connectors that report their
+// optional features.
+type CapabilityProvider interface {
+	Capabilities() CapabilitySet
+}
+
+// CapabilitiesOf returns what conn supports. Connectors that do not
+// implement CapabilityProvider are judged by the optional interfaces they
+// implement.
+func CapabilitiesOf(conn Connector) CapabilitySet {
+	if p, ok := conn.(CapabilityProvider); ok {
+		return p.Capabilities()
+	}
+	set := NewCapabilitySet()
+	if _, ok := conn.(Suspender); ok {
+		set[CapabilitySuspendUser] = true
+	}
+	if _, ok := conn.(LicenseAssigner); ok {
+		set[CapabilityAssignLicense] = true
+	}
+	if _, ok := conn.(RoleAssigner); ok {
+		set[CapabilityAssignDirectoryRole] = true
+	}
+	if _, ok := conn.(ChangeLister); ok {
+		set[CapabilityIncrementalSync] = true
+	}
+	if _, ok := conn.(PasswordSetter); ok {
+		set[CapabilitySetPassword] = true
+	}
+	return set
+}
+
+// ErrUnsupportedOperation is returned when a provisioning operation needs a
+// capability the connector lacks.
+var ErrUnsupportedOperation = errors.New("operation not supported by connector")
+
+// requiredCapability returns the capability a provisioning operation needs,
+// if any. delete_user needs one only when the connector suspends instead.
+func requiredCapability(operation, deprovisionMode string) (Capability, bool) {
+	switch operation {
+	case OperationAssignLicense:
+		return CapabilityAssignLicense, true
+	case OperationAssignDirectoryRole:
+		return CapabilityAssignDirectoryRole, true
+	case "delete_user":
+		if deprovisionMode == DeprovisionSuspend {
+			return CapabilitySuspendUser, true
+		}
+	}
+	return "", false
+}
+
+// checkSupported fails with ErrUnsupportedOperation when conn cannot run
+// the operation.
+func checkSupported(conn Connector, operation, deprovisionMode string) error {
+	capability, ok := requiredCapability(operation, deprovisionMode)
+	if !ok || CapabilitiesOf(conn).Has(capability) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s connector %s lacks %s, needed by %s",
+		ErrUnsupportedOperation, conn.Type(), conn.ID(), capability, operation)
+}
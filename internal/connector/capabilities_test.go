@@ -0,0 +1,83 @@
+package connector_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dhawalhost/wardseal/internal/connector"
+	"github.com/dhawalhost/wardseal/internal/connector/azuread"
+	"github.com/dhawalhost/wardseal/internal/connector/google"
+	"github.com/dhawalhost/wardseal/internal/connector/ldap"
+	"github.com/dhawalhost/wardseal/internal/connector/memory"
+	"github.com/dhawalhost/wardseal/internal/connector/scim"
+)
+
+func TestConnectorsReportTheirCapabilities(t *testing.T) {
+	tests := []struct {
+		name    string
+		factory connector.Factory
+		want    []connector.Capability
+	}{
+		{"scim", scim.New, []connector.Capability{connector.CapabilitySetPassword}},
+		{"ldap", ldap.New, []connector.Capability{connector.CapabilityNestedGroups, connector.CapabilitySetPassword}},
+		{"azure-ad", azuread.New, []connector.Capability{connector.CapabilityAssignDirectoryRole, connector.CapabilityAssignLicense, connector.CapabilitySetPassword}},
+		{"google", google.New, []connector.Capability{connector.CapabilitySetPassword, connector.CapabilitySuspendUser}},
+		{"memory", memory.New, []connector.Capability{connector.CapabilityIncrementalSync}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := tt.factory(connector.Config{ID: "c1", Endpoint: "https://target.example"})
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+			caps := connector.CapabilitiesOf(conn)
+			got := caps.List()
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected capabilities %v, got %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("expected capabilities %v, got %v", tt.want, got)
+				}
+			}
+
+			// Capabilities backed by an interface must match it.
+			_, suspends := conn.(connector.Suspender)
+			_, licenses := conn.(connector.LicenseAssigner)
+			_, roles := conn.(connector.RoleAssigner)
+			_, changes := conn.(connector.ChangeLister)
+			_, passwords := conn.(connector.PasswordSetter)
+			if caps.Has(connector.CapabilitySuspendUser) != suspends ||
+				caps.Has(connector.CapabilityAssignLicense) != licenses ||
+				caps.Has(connector.CapabilityAssignDirectoryRole) != roles ||
+				caps.Has(connector.CapabilityIncrementalSync) != changes ||
+				caps.Has(connector.CapabilitySetPassword) != passwords {
+				t.Fatalf("capabilities %v disagree with the interfaces implemented", got)
+			}
+		})
+	}
+}
+
+func TestCheckSupportedFailsFast(t *testing.T) {
+	conn, err := scim.New(connector.Config{ID: "c1", Endpoint: "https://target.example"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := connector.CheckSupported(conn, connector.OperationAssignLicense, connector.DeprovisionDelete); !errors.Is(err, connector.ErrUnsupportedOperation) {
+		t.Fatalf("expected ErrUnsupportedOperation, got %v", err)
+	}
+	if err := connector.CheckSupported(conn, "delete_user", connector.DeprovisionSuspend); !errors.Is(err, connector.ErrUnsupportedOperation) {
+		t.Fatalf("expected ErrUnsupportedOperation for suspend, got %v", err)
+	}
+	if err := connector.CheckSupported(conn, "delete_user", connector.DeprovisionDelete); err != nil {
+		t.Fatalf("expected delete to be supported, got %v", err)
+	}
+
+	gws, err := google.New(connector.Config{ID: "c2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := connector.CheckSupported(gws, "delete_user", connector.DeprovisionSuspend); err != nil {
+		t.Fatalf("expected google to support suspend, got %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package connector
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/dhawalhost/wardseal/internal/directory"
+)
+
+// SettingConflictPolicy selects how inbound sync resolves a user whose
+// attributes differ between the source and the directory.
+const SettingConflictPolicy = "conflict_policy"
+
+// Conflict policies for inbound sync.
+const (
+	// ConflictSourceWins overwrites the directory with the source's values.
+	ConflictSourceWins = "source_wins"
+	// ConflictDirectoryWins keeps the directory's values.
+	ConflictDirectoryWins = "directory_wins"
+	// ConflictNewestWins keeps whichever side changed most recently. Sources
+	// that do not report a change time are treated as newest.
+	ConflictNewestWins = "newest_wins"
+)
+
+// SyncConflict records one attribute that differed between the source and
+// the directory during inbound sync, and which side was kept.
+type SyncConflict struct {
+	ConnectorID    string    `json:"connector_id" db:"connector_id"`
+	TenantID       string    `json:"tenant_id" db:"tenant_id"`
+	ExternalID     string    `json:"external_id" db:"external_id"`
+	InternalID     string    `json:"internal_id" db:"internal_id"`
+	Attribute      string    `json:"attribute" db:"attribute"`
+	SourceValue    string    `json:"source_value" db:"source_value"`
+	DirectoryValue string    `json:"directory_value" db:"directory_value"`
+	Policy         string    `json:"policy" db:"policy"`
+	Resolution     string    `json:"resolution" db:"resolution"` // source or directory
+	DetectedAt     time.Time `json:"detected_at" db:"detected_at"`
+}
+
+// ConflictPolicyFor returns the connector's conflict policy, defaulting to
+// ConflictSourceWins.
+func ConflictPolicyFor(config Config) string {
+	if policy := config.Settings[SettingConflictPolicy]; policy != "" {
+		return policy
+	}
+	return ConflictSourceWins
+}
+
+// validateSyncSettings rejects unknown sync and provisioning settings values.
+func validateSyncSettings(config Config) error {
+	switch policy := config.Settings[SettingConflictPolicy]; policy {
+	case "", ConflictSourceWins, ConflictDirectoryWins, ConflictNewestWins:
+	default:
+		return fmt.Errorf("invalid %s %q: must be %s, %s or %s",
+			SettingConflictPolicy, policy, ConflictSourceWins, ConflictDirectoryWins, ConflictNewestWins)
+	}
+	if raw := config.Settings[SettingMaxMembershipRemovals]; raw != "" {
+		if n, err := strconv.Atoi(raw); err != nil || n < 0 {
+			return fmt.Errorf("invalid %s %q: must be a non-negative integer", SettingMaxMembershipRemovals, raw)
+		}
+	}
+	if err := validateMaxConcurrentTasks(config); err != nil {
+		return err
+	}
+	return validateDeprovisionMode(config)
+}
+
+// resolveUserConflict compares the synced attributes of a source user with
+// its directory counterpart. It returns one conflict per differing attribute
+// and whether the source's values should be written.
+func resolveUserConflict(policy string, source User, existing directory.User) ([]SyncConflict, bool) {
+	type attribute struct {
+		name              string
+		source, directory string
+	}
+	var diffs []attribute
+	if source.Email != existing.Email {
+		diffs = append(diffs, attribute{"email", source.Email, existing.Email})
+	}
+	// Only activeness is synced; a suspended directory user is inactive.
+	if source.Active != (existing.Status == "active") {
+		diffs = append(diffs, attribute{"active", strconv.FormatBool(source.Active), existing.Status})
+	}
+	if len(diffs) == 0 {
+		return nil, true
+	}
+
+	sourceWins := true
+	switch policy {
+	case ConflictDirectoryWins:
+		sourceWins = false
+	case ConflictNewestWins:
+		sourceWins = source.UpdatedAt.IsZero() || source.UpdatedAt.After(existing.UpdatedAt)
+	}
+	resolution := "source"
+	if !sourceWins {
+		resolution = "directory"
+	}
+
+	now := time.Now()
+	conflicts := make([]SyncConflict, len(diffs))
+	for i, d := range diffs {
+		conflicts[i] = SyncConflict{
+			ExternalID:     source.ExternalID,
+			InternalID:     existing.ID,
+			Attribute:      d.name,
+			SourceValue:    d.source,
+			DirectoryValue: d.directory,
+			Policy:         policy,
+			Resolution:     resolution,
+			DetectedAt:     now,
+		}
+	}
+	return conflicts, sourceWins
+}
+
+// directoryStatus maps a source user's activeness to a directory status.
+func directoryStatus(user User) string {
+	if user.Active {
+		return "active"
+	}
+	return "inactive"
+}
@@ -62,8 +62,14 @@ type User struct {
 	FirstName   string            `json:"first_name,omitempty"`
 	LastName    string            `json:"last_name,omitempty"`
 	DisplayName string            `json:"display_name,omitempty"`
+	Phone       string            `json:"phone,omitempty"`
+	Title       string            `json:"title,omitempty"`
+	Department  string            `json:"department,omitempty"`
+	Location    string            `json:"location,omitempty"`
 	Active      bool              `json:"active"`
 	Attributes  map[string]string `json:"attributes,omitempty"`
+	// UpdatedAt is when the source last changed the user, when it reports it.
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
 }
 
 // Group represents a group in an external system.
@@ -87,18 +93,37 @@ type ProvisioningTask struct {
 	ErrorMessage string      `json:"error_message,omitempty"`
 	RetryCount   int         `json:"retry_count"`
 	MaxRetries   int         `json:"max_retries"`
-	CreatedAt    time.Time   `json:"created_at"`
-	ProcessedAt  *time.Time  `json:"processed_at,omitempty"`
+	// ManualRetries counts how often the task was retried after failing.
+	ManualRetries int        `json:"manual_retries"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ProcessedAt   *time.Time `json:"processed_at,omitempty"`
 }
 
 // Registry manages connector instances.
 type Registry interface {
-	Register(connectorType string, factory Factory)
+	// Register adds a connector type. Configs are checked against schema
+	// before the factory sees them.
+	Register(connectorType string, factory Factory, schema ConfigSchema)
+	// ValidateConfig checks config against its type's schema. It returns a
+	// *ConfigError listing every missing or invalid field.
+	ValidateConfig(config Config) error
 	Create(connectorType string, config Config) (Connector, error)
+	// Build creates a connector without tracking it, for one-off use such as
+	// testing a configuration. The caller must Close it.
+	Build(connectorType string, config Config) (Connector, error)
 	Get(connectorID string) (Connector, bool)
 	List() []Connector
 	Remove(connectorID string) error
 }
 
+// ConnectionTestResult reports the outcome of testing a connector configuration.
+type ConnectionTestResult struct {
+	OK bool `json:"ok"`
+	// Stage is where a failed test stopped: create, initialize or health_check.
+	Stage string `json:"stage,omitempty"`
+	// Error is the provider's error message when the test failed.
+	Error string `json:"error,omitempty"`
+}
+
 // Factory creates connector instances.
 type Factory func(config Config) (Connector, error)
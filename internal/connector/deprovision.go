@@ -0,0 +1,56 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+)
+
+// SettingDeprovisionMode selects what the delete_user provisioning
+// operation does on the target.
+const SettingDeprovisionMode = "deprovision_mode"
+
+// Deprovisioning modes.
+const (
+	// DeprovisionDelete deletes the user. It is the default.
+	DeprovisionDelete = "delete"
+	// DeprovisionSuspend suspends the user instead, which can be reversed.
+	// The connector must implement Suspender.
+	DeprovisionSuspend = "suspend"
+)
+
+// Suspender is implemented by connectors whose targets can disable an
+// account without deleting it.
+type Suspender interface {
+	SuspendUser(ctx context.Context, id string) error
+	UnsuspendUser(ctx context.Context, id string) error
+}
+
+// DeprovisionModeFor returns the connector's deprovisioning mode,
+// defaulting to DeprovisionDelete.
+func DeprovisionModeFor(config Config) string {
+	if mode := config.Settings[SettingDeprovisionMode]; mode != "" {
+		return mode
+	}
+	return DeprovisionDelete
+}
+
+func validateDeprovisionMode(config Config) error {
+	switch mode := config.Settings[SettingDeprovisionMode]; mode {
+	case "", DeprovisionDelete, DeprovisionSuspend:
+		return nil
+	default:
+		return fmt.Errorf("invalid %s %q: must be %s or %s", SettingDeprovisionMode, mode, DeprovisionDelete, DeprovisionSuspend)
+	}
+}
+
+// deprovisionUser removes a user from the target the way mode says.
+func deprovisionUser(ctx context.Context, conn Connector, mode, id string) error {
+	if mode != DeprovisionSuspend {
+		return conn.DeleteUser(ctx, id)
+	}
+	suspender, ok := conn.(Suspender)
+	if !ok {
+		return fmt.Errorf("%s connector cannot suspend users", conn.Type())
+	}
+	return suspender.SuspendUser(ctx, id)
+}
@@ -0,0 +1,69 @@
+package connector_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dhawalhost/wardseal/internal/connector"
+)
+
+// deprovisionRecorder records which deprovisioning call a connector got.
+type deprovisionRecorder struct {
+	connector.Connector
+	calls []string
+}
+
+func (r *deprovisionRecorder) Type() string { return "recorder" }
+
+func (r *deprovisionRecorder) DeleteUser(ctx context.Context, id string) error {
+	r.calls = append(r.calls, "delete "+id)
+	return nil
+}
+
+type suspendingRecorder struct {
+	deprovisionRecorder
+}
+
+func (r *suspendingRecorder) SuspendUser(ctx context.Context, id string) error {
+	r.calls = append(r.calls, "suspend "+id)
+	return nil
+}
+
+func (r *suspendingRecorder) UnsuspendUser(ctx context.Context, id string) error {
+	r.calls = append(r.calls, "unsuspend "+id)
+	return nil
+}
+
+func TestDeprovisionUserHonorsMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]string
+		want     string
+	}{
+		{"default deletes", nil, "delete u1"},
+		{"delete", map[string]string{connector.SettingDeprovisionMode: connector.DeprovisionDelete}, "delete u1"},
+		{"suspend", map[string]string{connector.SettingDeprovisionMode: connector.DeprovisionSuspend}, "suspend u1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &suspendingRecorder{}
+			mode := connector.DeprovisionModeFor(connector.Config{Settings: tt.settings})
+			if err := connector.DeprovisionUser(context.Background(), conn, mode, "u1"); err != nil {
+				t.Fatalf("DeprovisionUser failed: %v", err)
+			}
+			if len(conn.calls) != 1 || conn.calls[0] != tt.want {
+				t.Fatalf("expected %q, got %v", tt.want, conn.calls)
+			}
+		})
+	}
+}
+
+func TestDeprovisionUserSuspendNeedsSupport(t *testing.T) {
+	conn := &deprovisionRecorder{}
+	if err := connector.DeprovisionUser(context.Background(), conn, connector.DeprovisionSuspend, "u1"); err == nil {
+		t.Fatal("expected an error for a connector that cannot suspend")
+	}
+	if len(conn.calls) != 0 {
+		t.Fatalf("expected the user not to be deleted, got %v", conn.calls)
+	}
+}
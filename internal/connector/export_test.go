@@ -0,0 +1,13 @@
+package connector
+
+// NewSyncStateMemoryStore exposes the in-memory sync state store to the
+// external connector_test package.
+var NewSyncStateMemoryStore = newSyncStateMemoryStore
+
+// DeprovisionUser exposes deprovisionUser to the external connector_test
+// package.
+var DeprovisionUser = deprovisionUser
+
+// CheckSupported exposes checkSupported to the external connector_test
+// package.
+var CheckSupported = checkSupported
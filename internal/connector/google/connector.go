@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/dhawalhost/wardseal/internal/connector"
@@ -18,18 +19,53 @@ const (
 	adminAPIBase = "https://admin.googleapis.com/admin/directory/v1"
 )
 
+// defaultAttributes maps User fields to Directory API user properties.
+var defaultAttributes = connector.AttributeMap{
+	connector.FieldUsername:    "primaryEmail",
+	connector.FieldEmail:       "primaryEmail",
+	connector.FieldFirstName:   "name.givenName",
+	connector.FieldLastName:    "name.familyName",
+	connector.FieldDisplayName: "name.fullName",
+}
+
+// Schema is the Google Workspace connector's config schema.
+var Schema = connector.ConfigSchema{
+	Settings:    []string{"domain"},
+	Credentials: []string{"service_account_json"},
+	Validate: func(config connector.Config) []connector.FieldError {
+		if raw := config.Credentials["service_account_json"]; raw != "" && !json.Valid([]byte(raw)) {
+			return []connector.FieldError{{Field: "credentials.service_account_json", Problem: "must be JSON"}}
+		}
+		return nil
+	},
+}
+
 // Connector implements the connector.Connector interface for Google Workspace.
 type Connector struct {
 	config     connector.Config
 	httpClient *http.Client
+	// baseClient carries the proxy, TLS and timeout settings; httpClient
+	// adds OAuth2 on top of it once authenticated.
+	baseClient *http.Client
 	domain     string
+	attrs      connector.AttributeMap
 }
 
 // New creates a new Google Workspace connector.
 func New(config connector.Config) (connector.Connector, error) {
+	attrs, err := connector.ParseAttributeMap(config, defaultAttributes)
+	if err != nil {
+		return nil, err
+	}
+	baseClient, err := connector.NewHTTPClient(config)
+	if err != nil {
+		return nil, err
+	}
 	return &Connector{
-		config: config,
-		domain: config.Settings["domain"],
+		config:     config,
+		baseClient: baseClient,
+		domain:     config.Settings["domain"],
+		attrs:      attrs,
 	}, nil
 }
 
@@ -37,9 +73,24 @@ func (c *Connector) ID() string   { return c.config.ID }
 func (c *Connector) Name() string { return c.config.Name }
 func (c *Connector) Type() string { return "google" }
 
+// Capabilities reports suspension and password changes.
+func (c *Connector) Capabilities() connector.CapabilitySet {
+	return connector.NewCapabilitySet(connector.CapabilitySuspendUser, connector.CapabilitySetPassword)
+}
+
 func (c *Connector) Initialize(ctx context.Context, config connector.Config) error {
+	attrs, err := connector.ParseAttributeMap(config, defaultAttributes)
+	if err != nil {
+		return err
+	}
+	baseClient, err := connector.NewHTTPClient(config)
+	if err != nil {
+		return err
+	}
 	c.config = config
+	c.baseClient = baseClient
 	c.domain = config.Settings["domain"]
+	c.attrs = attrs
 	return c.authenticate(ctx)
 }
 
@@ -48,6 +99,9 @@ func (c *Connector) authenticate(ctx context.Context) error {
 	credJSON := c.config.Credentials["service_account_json"]
 	adminEmail := c.config.Credentials["admin_email"]
 
+	// Token requests go through the configured client as well.
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, c.baseClient)
+
 	creds, err := google.CredentialsFromJSON(ctx, []byte(credJSON),
 		"https://www.googleapis.com/auth/admin.directory.user",
 		"https://www.googleapis.com/auth/admin.directory.group",
@@ -70,6 +124,7 @@ func (c *Connector) authenticate(ctx context.Context) error {
 	} else {
 		c.httpClient = oauth2.NewClient(ctx, creds.TokenSource)
 	}
+	c.httpClient.Timeout = c.baseClient.Timeout
 
 	return nil
 }
@@ -96,13 +151,14 @@ func (c *Connector) Close() error { return nil }
 func (c *Connector) CreateUser(ctx context.Context, user connector.User) (string, error) {
 	userData := map[string]interface{}{
 		"primaryEmail": user.Email,
-		"name": map[string]string{
+		"name": map[string]interface{}{
 			"givenName":  user.FirstName,
 			"familyName": user.LastName,
 		},
 		"suspended": !user.Active,
 		"password":  generateTempPassword(),
 	}
+	c.setMappedAttributes(userData, user)
 	body, _ := json.Marshal(userData)
 
 	req, _ := http.NewRequestWithContext(ctx, "POST", adminAPIBase+"/users", bytes.NewReader(body))
@@ -120,13 +176,15 @@ func (c *Connector) CreateUser(ctx context.Context, user connector.User) (string
 		return "", fmt.Errorf("create user failed: %s", string(respBody))
 	}
 
-	var result googleUserResponse
+	var result struct {
+		ID string `json:"id"`
+	}
 	_ = json.NewDecoder(resp.Body).Decode(&result)
 	return result.ID, nil
 }
 
 func (c *Connector) GetUser(ctx context.Context, id string) (connector.User, error) {
-	req, _ := http.NewRequestWithContext(ctx, "GET", adminAPIBase+"/users/"+id, nil)
+	req, _ := http.NewRequestWithContext(ctx, "GET", adminAPIBase+"/users/"+id+c.userProjection("?"), nil)
 
 	resp, err := c.httpClient.Do(req)
 	defer func() { _ = resp.Body.Close() }()
@@ -139,20 +197,21 @@ func (c *Connector) GetUser(ctx context.Context, id string) (connector.User, err
 		return connector.User{}, fmt.Errorf("user not found")
 	}
 
-	var result googleUserResponse
+	var result map[string]interface{}
 	_ = json.NewDecoder(resp.Body).Decode(&result)
-	return fromGoogleUser(result), nil
+	return fromGoogleUser(result, c.attrs), nil
 }
 
 func (c *Connector) UpdateUser(ctx context.Context, id string, user connector.User) error {
 	userData := map[string]interface{}{}
 	if user.FirstName != "" || user.LastName != "" {
-		userData["name"] = map[string]string{
+		userData["name"] = map[string]interface{}{
 			"givenName":  user.FirstName,
 			"familyName": user.LastName,
 		}
 	}
 	userData["suspended"] = !user.Active
+	c.setMappedAttributes(userData, user)
 
 	body, _ := json.Marshal(userData)
 	req, _ := http.NewRequestWithContext(ctx, "PUT", adminAPIBase+"/users/"+id, bytes.NewReader(body))
@@ -183,8 +242,60 @@ func (c *Connector) DeleteUser(ctx context.Context, id string) error {
 	return nil
 }
 
+// SuspendUser suspends the user, which blocks sign-in but keeps the account
+// and its data so it can be restored.
+func (c *Connector) SuspendUser(ctx context.Context, id string) error {
+	return c.setSuspended(ctx, id, true)
+}
+
+// UnsuspendUser restores a suspended user.
+func (c *Connector) UnsuspendUser(ctx context.Context, id string) error {
+	return c.setSuspended(ctx, id, false)
+}
+
+func (c *Connector) setSuspended(ctx context.Context, id string, suspended bool) error {
+	body, _ := json.Marshal(map[string]bool{"suspended": suspended})
+	req, _ := http.NewRequestWithContext(ctx, "PATCH", adminAPIBase+"/users/"+id, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode >= 400 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to update user suspension: %s", string(respBody))
+	}
+	return nil
+}
+
+// SetPassword sets the user's password; Google hashes it on receipt.
+// forceChange maps to changePasswordAtNextLogin.
+func (c *Connector) SetPassword(ctx context.Context, id, password string, forceChange bool) error {
+	body, _ := json.Marshal(map[string]interface{}{
+		"password":                  password,
+		"changePasswordAtNextLogin": forceChange,
+	})
+	req, _ := http.NewRequestWithContext(ctx, "PATCH", adminAPIBase+"/users/"+url.PathEscape(id), bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode >= 400 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to set password: %s", string(respBody))
+	}
+	return nil
+}
+
 func (c *Connector) ListUsers(ctx context.Context, filter string, limit, offset int) ([]connector.User, int, error) {
-	url := fmt.Sprintf("%s/users?domain=%s&maxResults=%d", adminAPIBase, c.domain, limit)
+	url := fmt.Sprintf("%s/users?domain=%s&maxResults=%d", adminAPIBase, c.domain, limit) + c.userProjection("&")
 	if filter != "" {
 		url += "&query=" + filter
 	}
@@ -198,13 +309,13 @@ func (c *Connector) ListUsers(ctx context.Context, filter string, limit, offset
 	}
 
 	var result struct {
-		Users []googleUserResponse `json:"users"`
+		Users []map[string]interface{} `json:"users"`
 	}
 	_ = json.NewDecoder(resp.Body).Decode(&result)
 
 	users := make([]connector.User, len(result.Users))
 	for i, u := range result.Users {
-		users[i] = fromGoogleUser(u)
+		users[i] = fromGoogleUser(u, c.attrs)
 	}
 	return users, len(users), nil
 }
@@ -317,8 +428,12 @@ func (c *Connector) ListGroups(ctx context.Context, filter string, limit, offset
 }
 
 func (c *Connector) AddUserToGroup(ctx context.Context, userID, groupID string) error {
+	email, err := c.memberEmail(ctx, userID)
+	if err != nil {
+		return err
+	}
 	member := map[string]string{
-		"email": userID,
+		"email": email,
 		"role":  "MEMBER",
 	}
 	body, _ := json.Marshal(member)
@@ -329,27 +444,70 @@ func (c *Connector) AddUserToGroup(ctx context.Context, userID, groupID string)
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := c.httpClient.Do(req)
-	defer func() { _ = resp.Body.Close() }()
-
 	if err != nil {
 		return err
 	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode >= 400 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to add group member: %s", string(respBody))
+	}
 	return nil
 }
 
 func (c *Connector) RemoveUserFromGroup(ctx context.Context, userID, groupID string) error {
+	email, err := c.memberEmail(ctx, userID)
+	if err != nil {
+		return err
+	}
 	req, _ := http.NewRequestWithContext(ctx, "DELETE",
-		fmt.Sprintf("%s/groups/%s/members/%s", adminAPIBase, groupID, userID), nil)
+		fmt.Sprintf("%s/groups/%s/members/%s", adminAPIBase, groupID, url.PathEscape(email)), nil)
 
 	resp, err := c.httpClient.Do(req)
-	defer func() { _ = resp.Body.Close() }()
-
 	if err != nil {
 		return err
 	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode >= 400 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to remove group member: %s", string(respBody))
+	}
 	return nil
 }
 
+// memberEmail returns the email a membership call identifies userID by.
+// Callers pass either the email or the numeric Google user ID; an ID is
+// resolved to the user's primaryEmail.
+func (c *Connector) memberEmail(ctx context.Context, userID string) (string, error) {
+	if !isGoogleID(userID) {
+		return userID, nil
+	}
+	user, err := c.GetUser(ctx, userID)
+	if err != nil {
+		return "", fmt.Errorf("resolve member %s: %w", userID, err)
+	}
+	if user.Email == "" {
+		return "", fmt.Errorf("resolve member %s: user has no primary email", userID)
+	}
+	return user.Email, nil
+}
+
+// isGoogleID reports whether id looks like a Google user ID, which is all
+// digits, rather than an email.
+func isGoogleID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, r := range id {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
 func (c *Connector) GetGroupMembers(ctx context.Context, groupID string) ([]connector.User, error) {
 	req, _ := http.NewRequestWithContext(ctx, "GET",
 		fmt.Sprintf("%s/groups/%s/members", adminAPIBase, groupID), nil)
@@ -378,27 +536,31 @@ func (c *Connector) GetGroupMembers(ctx context.Context, groupID string) ([]conn
 	return users, nil
 }
 
-type googleUserResponse struct {
-	ID           string `json:"id"`
-	PrimaryEmail string `json:"primaryEmail"`
-	Name         struct {
-		GivenName  string `json:"givenName"`
-		FamilyName string `json:"familyName"`
-		FullName   string `json:"fullName"`
-	} `json:"name"`
-	Suspended bool `json:"suspended"`
+// setMappedAttributes writes fields whose attribute_map entry differs from
+// the default; the default properties are already set by the caller.
+func (c *Connector) setMappedAttributes(userData map[string]interface{}, user connector.User) {
+	for attr, value := range c.attrs.Overrides(defaultAttributes).Values(user) {
+		connector.SetJSONPath(userData, attr, value)
+	}
+}
+
+// userProjection returns a projection=full query parameter, prefixed with
+// sep, when attributes are remapped, so custom schema fields are returned.
+func (c *Connector) userProjection(sep string) string {
+	if len(c.attrs.Overrides(defaultAttributes)) == 0 {
+		return ""
+	}
+	return sep + "projection=full"
 }
 
-func fromGoogleUser(u googleUserResponse) connector.User {
-	return connector.User{
-		ExternalID:  u.ID,
-		Username:    u.PrimaryEmail,
-		Email:       u.PrimaryEmail,
-		FirstName:   u.Name.GivenName,
-		LastName:    u.Name.FamilyName,
-		DisplayName: u.Name.FullName,
-		Active:      !u.Suspended,
+func fromGoogleUser(u map[string]interface{}, attrs connector.AttributeMap) connector.User {
+	flat := connector.FlattenJSON(u)
+	user := connector.User{
+		ExternalID: flat["id"],
+		Active:     u["suspended"] != true,
 	}
+	attrs.ApplyTo(&user, func(attr string) string { return flat[attr] })
+	return user
 }
 
 func generateTempPassword() string {
@@ -0,0 +1,157 @@
+package google
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// redirectTransport sends every request to the test server, so the
+// connector can keep its fixed Directory API base URL.
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (t redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.target.Scheme
+	req.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func newMockConnector(t *testing.T, handler http.HandlerFunc) *Connector {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	target, _ := url.Parse(server.URL)
+	return &Connector{
+		httpClient: &http.Client{Transport: redirectTransport{target: target}},
+		attrs:      defaultAttributes,
+	}
+}
+
+func TestAddUserToGroupAcceptsEmailOrID(t *testing.T) {
+	const membersPath = "/admin/directory/v1/groups/eng/members"
+	tests := []struct {
+		name      string
+		userID    string
+		wantPaths []string
+	}{
+		{"email", "ada@example.com", []string{"POST " + membersPath}},
+		{"id", "114122334455667788990", []string{"GET /admin/directory/v1/users/114122334455667788990", "POST " + membersPath}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var paths []string
+			var added string
+			c := newMockConnector(t, func(w http.ResponseWriter, r *http.Request) {
+				paths = append(paths, r.Method+" "+r.URL.Path)
+				switch r.Method {
+				case http.MethodGet:
+					_, _ = w.Write([]byte(`{"id": "114122334455667788990", "primaryEmail": "ada@example.com"}`))
+				case http.MethodPost:
+					var member map[string]string
+					_ = json.NewDecoder(r.Body).Decode(&member)
+					added = member["email"]
+					_, _ = w.Write([]byte(`{}`))
+				}
+			})
+
+			if err := c.AddUserToGroup(context.Background(), tt.userID, "eng"); err != nil {
+				t.Fatalf("AddUserToGroup failed: %v", err)
+			}
+			if added != "ada@example.com" {
+				t.Fatalf("expected member ada@example.com, got %q", added)
+			}
+			if len(paths) != len(tt.wantPaths) {
+				t.Fatalf("expected requests %v, got %v", tt.wantPaths, paths)
+			}
+			for i := range paths {
+				if paths[i] != tt.wantPaths[i] {
+					t.Fatalf("expected requests %v, got %v", tt.wantPaths, paths)
+				}
+			}
+		})
+	}
+}
+
+func TestRemoveUserFromGroupResolvesID(t *testing.T) {
+	var removed string
+	c := newMockConnector(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			_, _ = w.Write([]byte(`{"id": "1141", "primaryEmail": "ada@example.com"}`))
+		case http.MethodDelete:
+			removed = r.URL.Path
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+
+	if err := c.RemoveUserFromGroup(context.Background(), "1141", "eng"); err != nil {
+		t.Fatalf("RemoveUserFromGroup failed: %v", err)
+	}
+	if removed != "/admin/directory/v1/groups/eng/members/ada@example.com" {
+		t.Fatalf("unexpected delete path %q", removed)
+	}
+}
+
+func TestAddUserToGroupReportsFailure(t *testing.T) {
+	c := newMockConnector(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error": {"message": "Resource Not Found: groupKey"}}`))
+	})
+	if err := c.AddUserToGroup(context.Background(), "ada@example.com", "missing"); err == nil {
+		t.Fatal("expected an error for a failed add")
+	}
+}
+
+func TestSuspendAndUnsuspendUser(t *testing.T) {
+	var got []string
+	c := newMockConnector(t, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]bool
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if len(body) != 1 {
+			t.Errorf("expected only the suspended property, got %v", body)
+		}
+		got = append(got, fmt.Sprintf("%s %s suspended=%t", r.Method, r.URL.Path, body["suspended"]))
+		_, _ = w.Write([]byte(`{}`))
+	})
+
+	if err := c.SuspendUser(context.Background(), "1141"); err != nil {
+		t.Fatalf("SuspendUser failed: %v", err)
+	}
+	if err := c.UnsuspendUser(context.Background(), "1141"); err != nil {
+		t.Fatalf("UnsuspendUser failed: %v", err)
+	}
+	want := []string{
+		"PATCH /admin/directory/v1/users/1141 suspended=true",
+		"PATCH /admin/directory/v1/users/1141 suspended=false",
+	}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("expected requests %v, got %v", want, got)
+	}
+}
+
+func TestSetPassword(t *testing.T) {
+	var path string
+	var body map[string]interface{}
+	c := newMockConnector(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.Method + " " + r.URL.Path
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		_, _ = w.Write([]byte(`{}`))
+	})
+
+	if err := c.SetPassword(context.Background(), "ada@example.com", "N3w-Passw0rd!", false); err != nil {
+		t.Fatalf("SetPassword failed: %v", err)
+	}
+	if path != "PATCH /admin/directory/v1/users/ada@example.com" {
+		t.Fatalf("unexpected request %q", path)
+	}
+	if len(body) != 2 || body["password"] != "N3w-Passw0rd!" || body["changePasswordAtNextLogin"] != false {
+		t.Fatalf("unexpected body %v", body)
+	}
+}
@@ -0,0 +1,82 @@
+package connector
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Settings that configure the HTTP client of connectors that call a remote
+// API.
+const (
+	// SettingHTTPProxy is the proxy URL requests are sent through, e.g.
+	// "http://proxy.corp:3128". Without it the environment's HTTPS_PROXY and
+	// NO_PROXY apply.
+	SettingHTTPProxy = "http_proxy"
+	// SettingTLSCABundle holds PEM certificates trusted in addition to the
+	// system roots, for targets or proxies behind a private CA.
+	SettingTLSCABundle = "tls_ca_bundle"
+	// SettingTLSInsecureSkipVerify disables certificate verification when
+	// "true". It is meant for testing only.
+	SettingTLSInsecureSkipVerify = "tls_insecure_skip_verify"
+	// SettingRequestTimeout bounds each request, as a Go duration such as
+	// "45s". It defaults to DefaultRequestTimeout.
+	SettingRequestTimeout = "request_timeout"
+)
+
+// DefaultRequestTimeout is the per-request timeout when none is configured.
+const DefaultRequestTimeout = 30 * time.Second
+
+// NewHTTPClient returns an HTTP client configured by the connector's proxy,
+// TLS and timeout settings. It fails on invalid settings, so connectors call
+// it from Initialize.
+func NewHTTPClient(config Config) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if raw := config.Settings[SettingHTTPProxy]; raw != "" {
+		proxy, err := url.Parse(raw)
+		if err != nil || proxy.Host == "" {
+			return nil, fmt.Errorf("invalid %s %q", SettingHTTPProxy, raw)
+		}
+		switch proxy.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("invalid %s: unsupported scheme %q", SettingHTTPProxy, proxy.Scheme)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if bundle := config.Settings[SettingTLSCABundle]; bundle != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM([]byte(bundle)) {
+			return nil, fmt.Errorf("invalid %s: no PEM certificates found", SettingTLSCABundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	switch v := config.Settings[SettingTLSInsecureSkipVerify]; v {
+	case "", "false":
+	case "true":
+		tlsConfig.InsecureSkipVerify = true
+	default:
+		return nil, fmt.Errorf("invalid %s %q: must be true or false", SettingTLSInsecureSkipVerify, v)
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	timeout := DefaultRequestTimeout
+	if raw := config.Settings[SettingRequestTimeout]; raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid %s %q", SettingRequestTimeout, raw)
+		}
+		timeout = d
+	}
+
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
@@ -0,0 +1,79 @@
+package connector_test
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dhawalhost/wardseal/internal/connector"
+)
+
+func TestNewHTTPClientTrustsCABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	bundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	client, err := connector.NewHTTPClient(connector.Config{})
+	if err != nil {
+		t.Fatalf("NewHTTPClient failed: %v", err)
+	}
+	if _, err := client.Get(server.URL); err == nil {
+		t.Fatal("expected the test server's certificate to be rejected without the CA bundle")
+	}
+
+	client, err = connector.NewHTTPClient(connector.Config{Settings: map[string]string{connector.SettingTLSCABundle: string(bundle)}})
+	if err != nil {
+		t.Fatalf("NewHTTPClient failed: %v", err)
+	}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected the CA bundle to be trusted: %v", err)
+	}
+	_ = resp.Body.Close()
+}
+
+func TestNewHTTPClientUsesProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A forward proxy receives the absolute target URL.
+		proxied = r.URL.String()
+	}))
+	defer proxy.Close()
+
+	client, err := connector.NewHTTPClient(connector.Config{Settings: map[string]string{
+		connector.SettingHTTPProxy:      proxy.URL,
+		connector.SettingRequestTimeout: "5s",
+	}})
+	if err != nil {
+		t.Fatalf("NewHTTPClient failed: %v", err)
+	}
+	if client.Timeout != 5*time.Second {
+		t.Fatalf("expected a 5s timeout, got %s", client.Timeout)
+	}
+	resp, err := client.Get("http://scim.example.invalid/v2/Users")
+	if err != nil {
+		t.Fatalf("request through proxy failed: %v", err)
+	}
+	_ = resp.Body.Close()
+	if proxied != "http://scim.example.invalid/v2/Users" {
+		t.Fatalf("expected the proxy to receive the request, got %q", proxied)
+	}
+}
+
+func TestNewHTTPClientRejectsInvalidSettings(t *testing.T) {
+	tests := map[string]map[string]string{
+		"proxy without host": {connector.SettingHTTPProxy: "proxy.corp:3128"},
+		"proxy scheme":       {connector.SettingHTTPProxy: "ftp://proxy.corp"},
+		"ca bundle":          {connector.SettingTLSCABundle: "not a certificate"},
+		"skip verify":        {connector.SettingTLSInsecureSkipVerify: "yes"},
+		"timeout":            {connector.SettingRequestTimeout: "30"},
+		"negative timeout":   {connector.SettingRequestTimeout: "-1s"},
+	}
+	for name, settings := range tests {
+		if _, err := connector.NewHTTPClient(connector.Config{Settings: settings}); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
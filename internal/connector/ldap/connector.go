@@ -2,35 +2,201 @@ package ldap
 
 import (
 	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode/utf16"
 
 	"github.com/dhawalhost/wardseal/internal/connector"
 	"github.com/go-ldap/ldap/v3"
 )
 
+// SettingExpandNestedGroups enables recursive expansion of nested groups in
+// GetGroupMembers when set to "true".
+const SettingExpandNestedGroups = "expand_nested_groups"
+
+// maxNestedGroupDepth bounds how many levels of nested groups are expanded.
+const maxNestedGroupDepth = 10
+
+// SettingActiveDirectory, when "true", makes CreateUser set Active
+// Directory's userAccountControl from the user's active flag. It is the
+// older spelling of directory_type "ad".
+const SettingActiveDirectory = "active_directory"
+
+// SettingDirectoryType selects directory-specific behaviour: DirectoryTypeAD
+// sets userAccountControl on new users and writes passwords to unicodePwd;
+// DirectoryTypeGeneric, the default, writes userPassword.
+const SettingDirectoryType = "directory_type"
+
+// Directory types for SettingDirectoryType.
+const (
+	DirectoryTypeGeneric = "generic"
+	DirectoryTypeAD      = "ad"
+)
+
+// ErrInsecureConnection is returned by SetPassword when the connection to
+// the directory is not encrypted.
+var ErrInsecureConnection = errors.New("ldap: password changes need an encrypted (ldaps://) connection")
+
+// Password attributes. Active Directory takes unicodePwd and forces a change
+// by zeroing pwdLastSet; other directories take userPassword and, with the
+// password policy overlay, force a change through pwdReset.
+const (
+	adPasswordAttribute   = "unicodePwd"
+	adPwdLastSetAttribute = "pwdLastSet"
+	passwordAttribute     = "userPassword"
+	pwdResetAttribute     = "pwdReset"
+)
+
+// Active Directory userAccountControl attribute and flags.
+const (
+	uacAttribute      = "userAccountControl"
+	uacAccountDisable = 0x2
+	uacNormalAccount  = 0x200
+)
+
+// Settings keys for the directory schema. Object class lists are
+// comma-separated; the first class is the one searches filter on.
+const (
+	SettingUserObjectClasses  = "user_object_classes"
+	SettingGroupObjectClasses = "group_object_classes"
+	SettingUserRDNAttribute   = "user_rdn_attribute"
+	SettingGroupRDNAttribute  = "group_rdn_attribute"
+	// SettingUsernameAttribute and SettingMailAttribute change the default
+	// attributes for the username and email; attribute_map still wins.
+	SettingUsernameAttribute = "username_attribute"
+	SettingMailAttribute     = "mail_attribute"
+)
+
+var (
+	defaultUserObjectClasses  = []string{"inetOrgPerson", "organizationalPerson", "person", "top"}
+	defaultGroupObjectClasses = []string{"groupOfNames", "top"}
+)
+
+// defaultAttributes maps User fields to inetOrgPerson attributes.
+var defaultAttributes = connector.AttributeMap{
+	connector.FieldUsername:    "uid",
+	connector.FieldEmail:       "mail",
+	connector.FieldFirstName:   "givenName",
+	connector.FieldLastName:    "sn",
+	connector.FieldDisplayName: "displayName",
+}
+
+// Schema is the LDAP connector's config schema. The client refuses binds
+// with an empty password, so both bind credentials are required.
+var Schema = connector.ConfigSchema{
+	Endpoint:    true,
+	Settings:    []string{"base_dn"},
+	Credentials: []string{"bind_dn", "bind_password"},
+	Validate: func(config connector.Config) []connector.FieldError {
+		errs := connector.CheckEndpointScheme(config, "ldap", "ldaps")
+		switch config.Settings[SettingDirectoryType] {
+		case "", DirectoryTypeGeneric, DirectoryTypeAD:
+		default:
+			errs = append(errs, connector.FieldError{
+				Field:   "settings." + SettingDirectoryType,
+				Problem: "must be " + DirectoryTypeGeneric + " or " + DirectoryTypeAD,
+			})
+		}
+		return errs
+	},
+}
+
 // Connector implements the connector.Connector interface for LDAP/Active Directory.
 type Connector struct {
 	config connector.Config
 	conn   *ldap.Conn
 	baseDN string
+	attrs  connector.AttributeMap
+
+	userClasses  []string
+	groupClasses []string
+	userRDN      string
+	groupRDN     string
 }
 
 // New creates a new LDAP connector.
 func New(config connector.Config) (connector.Connector, error) {
-	return &Connector{
-		config: config,
-		baseDN: config.Settings["base_dn"],
-	}, nil
+	c := &Connector{}
+	if err := c.configure(config); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// configure applies config's settings, falling back to inetOrgPerson and
+// groupOfNames entries named by cn.
+func (c *Connector) configure(config connector.Config) error {
+	defaults := make(connector.AttributeMap, len(defaultAttributes))
+	for field, attr := range defaultAttributes {
+		defaults[field] = attr
+	}
+	if attr := config.Settings[SettingUsernameAttribute]; attr != "" {
+		defaults[connector.FieldUsername] = attr
+	}
+	if attr := config.Settings[SettingMailAttribute]; attr != "" {
+		defaults[connector.FieldEmail] = attr
+	}
+	attrs, err := connector.ParseAttributeMap(config, defaults)
+	if err != nil {
+		return err
+	}
+
+	c.config = config
+	c.baseDN = config.Settings["base_dn"]
+	c.attrs = attrs
+	c.userClasses = settingList(config, SettingUserObjectClasses, defaultUserObjectClasses)
+	c.groupClasses = settingList(config, SettingGroupObjectClasses, defaultGroupObjectClasses)
+	c.userRDN = settingOr(config, SettingUserRDNAttribute, "cn")
+	c.groupRDN = settingOr(config, SettingGroupRDNAttribute, "cn")
+	return nil
+}
+
+func settingOr(config connector.Config, key, fallback string) string {
+	if v := strings.TrimSpace(config.Settings[key]); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func settingList(config connector.Config, key string, fallback []string) []string {
+	var values []string
+	for _, v := range strings.Split(config.Settings[key], ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	if len(values) == 0 {
+		return fallback
+	}
+	return values
+}
+
+// isActiveDirectory reports whether directory_type is "ad" or the older
+// active_directory setting is on.
+func (c *Connector) isActiveDirectory() bool {
+	return c.config.Settings[SettingDirectoryType] == DirectoryTypeAD ||
+		c.config.Settings[SettingActiveDirectory] == "true"
 }
 
 func (c *Connector) ID() string   { return c.config.ID }
 func (c *Connector) Name() string { return c.config.Name }
 func (c *Connector) Type() string { return "ldap" }
 
+// Capabilities reports nested group expansion, which GetGroupMembers does
+// when expand_nested_groups is set, and password changes, which need an
+// ldaps endpoint.
+func (c *Connector) Capabilities() connector.CapabilitySet {
+	return connector.NewCapabilitySet(connector.CapabilityNestedGroups, connector.CapabilitySetPassword)
+}
+
 func (c *Connector) Initialize(ctx context.Context, config connector.Config) error {
-	c.config = config
-	c.baseDN = config.Settings["base_dn"]
+	if err := c.configure(config); err != nil {
+		return err
+	}
 	return c.connect()
 }
 
@@ -75,74 +241,186 @@ func (c *Connector) Close() error {
 
 // User operations
 func (c *Connector) CreateUser(ctx context.Context, user connector.User) (string, error) {
-	userDN := fmt.Sprintf("cn=%s,%s", user.Username, c.getUsersOU())
+	addReq := c.newAddRequest(user)
+	if err := c.conn.Add(addReq); err != nil {
+		return "", fmt.Errorf("failed to create user: %w", err)
+	}
+	return addReq.DN, nil
+}
+
+// newAddRequest builds the entry for user, writing each mapped field to its
+// attribute.
+func (c *Connector) newAddRequest(user connector.User) *ldap.AddRequest {
+	userDN := fmt.Sprintf("%s=%s,%s", c.userRDN, ldap.EscapeDN(user.Username), c.getUsersOU())
 
 	addReq := ldap.NewAddRequest(userDN, nil)
-	addReq.Attribute("objectClass", []string{"inetOrgPerson", "organizationalPerson", "person", "top"})
-	addReq.Attribute("cn", []string{user.Username})
-	addReq.Attribute("sn", []string{user.LastName})
-	addReq.Attribute("givenName", []string{user.FirstName})
-	addReq.Attribute("mail", []string{user.Email})
-	addReq.Attribute("uid", []string{user.Username})
-	if user.DisplayName != "" {
-		addReq.Attribute("displayName", []string{user.DisplayName})
+	addReq.Attribute("objectClass", c.userClasses)
+	addReq.Attribute(c.userRDN, []string{user.Username})
+	values := c.attrs.Values(user)
+	for _, attr := range sortedKeys(values) {
+		if !strings.EqualFold(attr, c.userRDN) {
+			addReq.Attribute(attr, []string{values[attr]})
+		}
 	}
+	if c.isActiveDirectory() {
+		addReq.Attribute(uacAttribute, []string{strconv.FormatInt(accountControl(uacNormalAccount, user.Active), 10)})
+	}
+	return addReq
+}
 
-	if err := c.conn.Add(addReq); err != nil {
-		return "", fmt.Errorf("failed to create user: %w", err)
+// newModifyRequest replaces the mapped attributes of entry with user's
+// non-empty fields. The username is not changed. When the entry has a
+// userAccountControl, its disabled bit is set from user.Active and its other
+// flags are kept.
+func (c *Connector) newModifyRequest(entry *ldap.Entry, user connector.User) *ldap.ModifyRequest {
+	user.Username = ""
+	modReq := ldap.NewModifyRequest(entry.DN, nil)
+	values := c.attrs.Values(user)
+	for _, attr := range sortedKeys(values) {
+		modReq.Replace(attr, []string{values[attr]})
+	}
+	if uac, ok := entryAccountControl(entry); ok {
+		modReq.Replace(uacAttribute, []string{strconv.FormatInt(accountControl(uac, user.Active), 10)})
+	}
+	return modReq
+}
+
+// userFromEntry reads each mapped field from its attribute. Users are active
+// unless their userAccountControl marks them disabled.
+func (c *Connector) userFromEntry(entry *ldap.Entry) connector.User {
+	user := connector.User{
+		ExternalID: entry.DN,
+		Active:     true,
+	}
+	if uac, ok := entryAccountControl(entry); ok {
+		user.Active = uac&uacAccountDisable == 0
+	}
+	c.attrs.ApplyTo(&user, entry.GetAttributeValue)
+	return user
+}
+
+// entryAccountControl returns the entry's userAccountControl, if it has a
+// valid one.
+func entryAccountControl(entry *ldap.Entry) (int64, bool) {
+	raw := entry.GetAttributeValue(uacAttribute)
+	if raw == "" {
+		return 0, false
+	}
+	uac, err := strconv.ParseInt(raw, 10, 64)
+	return uac, err == nil
+}
+
+// accountControl returns uac with the disabled bit set or cleared.
+func accountControl(uac int64, active bool) int64 {
+	if active {
+		return uac &^ uacAccountDisable
+	}
+	return uac | uacAccountDisable
+}
+
+// userSearchAttributes lists the attributes to fetch for user entries.
+func (c *Connector) userSearchAttributes() []string {
+	return append(c.attrs.Attributes(), c.userRDN, uacAttribute)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
 	}
-	return userDN, nil
+	sort.Strings(keys)
+	return keys
 }
 
 func (c *Connector) GetUser(ctx context.Context, id string) (connector.User, error) {
-	filter := fmt.Sprintf("(|(uid=%s)(cn=%s))", ldap.EscapeFilter(id), ldap.EscapeFilter(id))
+	entry, err := c.findUserEntry(id)
+	if err != nil {
+		return connector.User{}, err
+	}
+	return c.userFromEntry(entry), nil
+}
+
+// findUserEntry finds a user by username or RDN attribute.
+func (c *Connector) findUserEntry(id string) (*ldap.Entry, error) {
 	result, err := c.conn.Search(&ldap.SearchRequest{
 		BaseDN:     c.getUsersOU(),
 		Scope:      ldap.ScopeWholeSubtree,
-		Filter:     filter,
-		Attributes: []string{"uid", "cn", "sn", "givenName", "mail", "displayName"},
+		Filter:     c.userFilter(id),
+		Attributes: c.userSearchAttributes(),
 	})
 	if err != nil {
-		return connector.User{}, err
+		return nil, err
 	}
 	if len(result.Entries) == 0 {
-		return connector.User{}, fmt.Errorf("user not found")
+		return nil, fmt.Errorf("user not found")
 	}
-
-	entry := result.Entries[0]
-	return connector.User{
-		ExternalID:  entry.DN,
-		Username:    entry.GetAttributeValue("uid"),
-		Email:       entry.GetAttributeValue("mail"),
-		FirstName:   entry.GetAttributeValue("givenName"),
-		LastName:    entry.GetAttributeValue("sn"),
-		DisplayName: entry.GetAttributeValue("displayName"),
-		Active:      true, // LDAP typically doesn't have active flag
-	}, nil
+	return result.Entries[0], nil
 }
 
 func (c *Connector) UpdateUser(ctx context.Context, id string, user connector.User) error {
-	// Find user DN
-	u, err := c.GetUser(ctx, id)
+	entry, err := c.findUserEntry(id)
 	if err != nil {
 		return err
 	}
+	return c.conn.Modify(c.newModifyRequest(entry, user))
+}
 
-	modReq := ldap.NewModifyRequest(u.ExternalID, nil)
-	if user.Email != "" {
-		modReq.Replace("mail", []string{user.Email})
+// SetPassword replaces the user's password. The password travels in the
+// clear inside the LDAP message, and Active Directory refuses unicodePwd
+// writes over plain connections anyway, so it fails with
+// ErrInsecureConnection unless the connection is encrypted.
+func (c *Connector) SetPassword(ctx context.Context, id, password string, forceChange bool) error {
+	if err := c.requireTLS(); err != nil {
+		return err
 	}
-	if user.FirstName != "" {
-		modReq.Replace("givenName", []string{user.FirstName})
+	entry, err := c.findUserEntry(id)
+	if err != nil {
+		return err
 	}
-	if user.LastName != "" {
-		modReq.Replace("sn", []string{user.LastName})
+	if err := c.conn.Modify(c.newPasswordModifyRequest(entry.DN, password, forceChange)); err != nil {
+		return fmt.Errorf("failed to set password: %w", err)
 	}
-	if user.DisplayName != "" {
-		modReq.Replace("displayName", []string{user.DisplayName})
+	return nil
+}
+
+// newPasswordModifyRequest builds the modification that sets the password of
+// the entry at dn.
+func (c *Connector) newPasswordModifyRequest(dn, password string, forceChange bool) *ldap.ModifyRequest {
+	modReq := ldap.NewModifyRequest(dn, nil)
+	if c.isActiveDirectory() {
+		modReq.Replace(adPasswordAttribute, []string{encodeUnicodePwd(password)})
+		if forceChange {
+			modReq.Replace(adPwdLastSetAttribute, []string{"0"})
+		}
+		return modReq
+	}
+	modReq.Replace(passwordAttribute, []string{password})
+	if forceChange {
+		modReq.Replace(pwdResetAttribute, []string{"TRUE"})
 	}
+	return modReq
+}
 
-	return c.conn.Modify(modReq)
+// requireTLS fails unless the connector holds an encrypted connection.
+func (c *Connector) requireTLS() error {
+	if c.conn == nil {
+		return fmt.Errorf("ldap: not connected")
+	}
+	if _, ok := c.conn.TLSConnectionState(); !ok {
+		return ErrInsecureConnection
+	}
+	return nil
+}
+
+// encodeUnicodePwd returns password in the form Active Directory requires for
+// unicodePwd: enclosed in double quotes and encoded as UTF-16LE.
+func encodeUnicodePwd(password string) string {
+	units := utf16.Encode([]rune(`"` + password + `"`))
+	buf := make([]byte, 2*len(units))
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(buf[2*i:], u)
+	}
+	return string(buf)
 }
 
 func (c *Connector) DeleteUser(ctx context.Context, id string) error {
@@ -154,7 +432,7 @@ func (c *Connector) DeleteUser(ctx context.Context, id string) error {
 }
 
 func (c *Connector) ListUsers(ctx context.Context, filter string, limit, offset int) ([]connector.User, int, error) {
-	searchFilter := "(objectClass=inetOrgPerson)"
+	searchFilter := classFilter(c.userClasses)
 	if filter != "" {
 		searchFilter = fmt.Sprintf("(&%s(%s))", searchFilter, filter)
 	}
@@ -163,7 +441,7 @@ func (c *Connector) ListUsers(ctx context.Context, filter string, limit, offset
 		BaseDN:     c.getUsersOU(),
 		Scope:      ldap.ScopeWholeSubtree,
 		Filter:     searchFilter,
-		Attributes: []string{"uid", "cn", "sn", "givenName", "mail", "displayName"},
+		Attributes: c.userSearchAttributes(),
 	})
 	if err != nil {
 		return nil, 0, err
@@ -180,32 +458,25 @@ func (c *Connector) ListUsers(ctx context.Context, filter string, limit, offset
 
 	users := make([]connector.User, 0, end-offset)
 	for i := offset; i < end; i++ {
-		entry := result.Entries[i]
-		users = append(users, connector.User{
-			ExternalID:  entry.DN,
-			Username:    entry.GetAttributeValue("uid"),
-			Email:       entry.GetAttributeValue("mail"),
-			FirstName:   entry.GetAttributeValue("givenName"),
-			LastName:    entry.GetAttributeValue("sn"),
-			DisplayName: entry.GetAttributeValue("displayName"),
-			Active:      true,
-		})
+		users = append(users, c.userFromEntry(result.Entries[i]))
 	}
 	return users, total, nil
 }
 
 // Group operations
 func (c *Connector) CreateGroup(ctx context.Context, group connector.Group) (string, error) {
-	groupDN := fmt.Sprintf("cn=%s,%s", group.Name, c.getGroupsOU())
+	groupDN := fmt.Sprintf("%s=%s,%s", c.groupRDN, ldap.EscapeDN(group.Name), c.getGroupsOU())
 
 	addReq := ldap.NewAddRequest(groupDN, nil)
-	addReq.Attribute("objectClass", []string{"groupOfNames", "top"})
-	addReq.Attribute("cn", []string{group.Name})
+	addReq.Attribute("objectClass", c.groupClasses)
+	addReq.Attribute(c.groupRDN, []string{group.Name})
 	if group.Description != "" {
 		addReq.Attribute("description", []string{group.Description})
 	}
-	// groupOfNames requires at least one member
-	addReq.Attribute("member", []string{c.baseDN}) // Placeholder
+	if hasClass(c.groupClasses, "groupOfNames") {
+		// groupOfNames requires at least one member
+		addReq.Attribute("member", []string{c.baseDN}) // Placeholder
+	}
 
 	if err := c.conn.Add(addReq); err != nil {
 		return "", fmt.Errorf("failed to create group: %w", err)
@@ -214,12 +485,11 @@ func (c *Connector) CreateGroup(ctx context.Context, group connector.Group) (str
 }
 
 func (c *Connector) GetGroup(ctx context.Context, id string) (connector.Group, error) {
-	filter := fmt.Sprintf("(cn=%s)", ldap.EscapeFilter(id))
 	result, err := c.conn.Search(&ldap.SearchRequest{
 		BaseDN:     c.getGroupsOU(),
 		Scope:      ldap.ScopeWholeSubtree,
-		Filter:     filter,
-		Attributes: []string{"cn", "description"},
+		Filter:     c.groupFilter(id),
+		Attributes: []string{c.groupRDN, "description"},
 	})
 	if err != nil {
 		return connector.Group{}, err
@@ -231,7 +501,7 @@ func (c *Connector) GetGroup(ctx context.Context, id string) (connector.Group, e
 	entry := result.Entries[0]
 	return connector.Group{
 		ExternalID:  entry.DN,
-		Name:        entry.GetAttributeValue("cn"),
+		Name:        entry.GetAttributeValue(c.groupRDN),
 		Description: entry.GetAttributeValue("description"),
 	}, nil
 }
@@ -258,12 +528,11 @@ func (c *Connector) DeleteGroup(ctx context.Context, id string) error {
 }
 
 func (c *Connector) ListGroups(ctx context.Context, filter string, limit, offset int) ([]connector.Group, int, error) {
-	searchFilter := "(objectClass=groupOfNames)"
 	result, err := c.conn.Search(&ldap.SearchRequest{
 		BaseDN:     c.getGroupsOU(),
 		Scope:      ldap.ScopeWholeSubtree,
-		Filter:     searchFilter,
-		Attributes: []string{"cn", "description"},
+		Filter:     classFilter(c.groupClasses),
+		Attributes: []string{c.groupRDN, "description"},
 	})
 	if err != nil {
 		return nil, 0, err
@@ -280,7 +549,7 @@ func (c *Connector) ListGroups(ctx context.Context, filter string, limit, offset
 		entry := result.Entries[i]
 		groups = append(groups, connector.Group{
 			ExternalID:  entry.DN,
-			Name:        entry.GetAttributeValue("cn"),
+			Name:        entry.GetAttributeValue(c.groupRDN),
 			Description: entry.GetAttributeValue("description"),
 		})
 	}
@@ -317,44 +586,165 @@ func (c *Connector) RemoveUserFromGroup(ctx context.Context, userID, groupID str
 	return c.conn.Modify(modReq)
 }
 
+// GetGroupMembers returns the group's user members. Nested groups are
+// skipped unless the expand_nested_groups setting is "true", in which case
+// their users are included, up to maxNestedGroupDepth levels deep.
 func (c *Connector) GetGroupMembers(ctx context.Context, groupID string) ([]connector.User, error) {
 	g, err := c.GetGroup(ctx, groupID)
 	if err != nil {
 		return nil, err
 	}
 
-	result, err := c.conn.Search(&ldap.SearchRequest{
-		BaseDN:     g.ExternalID,
-		Scope:      ldap.ScopeBaseObject,
-		Filter:     "(objectClass=*)",
-		Attributes: []string{"member"},
-	})
+	entries, err := c.flattenMembers(g.ExternalID, c.conn.Search)
 	if err != nil {
 		return nil, err
 	}
-	if len(result.Entries) == 0 {
-		return []connector.User{}, nil
+	users := make([]connector.User, len(entries))
+	for i, entry := range entries {
+		users[i] = c.userFromEntry(entry)
 	}
+	return users, nil
+}
+
+// searchFunc runs an LDAP search; *ldap.Conn's Search satisfies it.
+type searchFunc func(*ldap.SearchRequest) (*ldap.SearchResult, error)
+
+// memberBatchSize is how many DNs are fetched per search.
+const memberBatchSize = 100
+
+// lookupEntries fetches the entries at dns, memberBatchSize per search, keyed
+// by lower-cased DN. Entries are matched on their DN through the
+// distinguishedName (Active Directory) or entryDN (OpenLDAP) attribute, so
+// the RDN attribute does not matter. Missing entries are left out.
+func (c *Connector) lookupEntries(search searchFunc, dns []string) (map[string]*ldap.Entry, error) {
+	entries := make(map[string]*ldap.Entry, len(dns))
+	for start := 0; start < len(dns); start += memberBatchSize {
+		end := start + memberBatchSize
+		if end > len(dns) {
+			end = len(dns)
+		}
+		var filter strings.Builder
+		filter.WriteString("(|")
+		for _, dn := range dns[start:end] {
+			escaped := ldap.EscapeFilter(dn)
+			fmt.Fprintf(&filter, "(distinguishedName=%s)(entryDN=%s)", escaped, escaped)
+		}
+		filter.WriteString(")")
 
-	members := result.Entries[0].GetAttributeValues("member")
-	users := make([]connector.User, 0, len(members))
-	for _, memberDN := range members {
-		if memberDN == c.baseDN {
-			continue // Skip placeholder
+		result, err := search(&ldap.SearchRequest{
+			BaseDN:     c.baseDN,
+			Scope:      ldap.ScopeWholeSubtree,
+			Filter:     filter.String(),
+			Attributes: append(c.userSearchAttributes(), "objectClass", "member"),
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range result.Entries {
+			entries[strings.ToLower(entry.DN)] = entry
 		}
-		// Extract CN from DN
-		parts := strings.Split(memberDN, ",")
-		if len(parts) > 0 {
-			cn := strings.TrimPrefix(parts[0], "cn=")
-			u, err := c.GetUser(ctx, cn)
-			if err == nil {
-				users = append(users, u)
+	}
+	return entries, nil
+}
+
+// flattenMembers returns the user entries that are members of the group at
+// groupDN, each once, fetching each level's members in batches. Member
+// groups are expanded when enabled; a group reached again through a cycle is
+// not expanded twice.
+func (c *Connector) flattenMembers(groupDN string, search searchFunc) ([]*ldap.Entry, error) {
+	expand := c.config.Settings[SettingExpandNestedGroups] == "true"
+	visited := map[string]bool{strings.ToLower(groupDN): true}
+	var users []*ldap.Entry
+
+	var walk func(group *ldap.Entry, depth int) error
+	walk = func(group *ldap.Entry, depth int) error {
+		var memberDNs []string
+		for _, memberDN := range group.GetAttributeValues("member") {
+			key := strings.ToLower(memberDN)
+			if memberDN == c.baseDN || visited[key] {
+				continue // Skip placeholder and members already seen
+			}
+			visited[key] = true
+			memberDNs = append(memberDNs, memberDN)
+		}
+		members, err := c.lookupEntries(search, memberDNs)
+		if err != nil {
+			return err
+		}
+
+		for _, memberDN := range memberDNs {
+			member := members[strings.ToLower(memberDN)]
+			switch {
+			case member == nil:
+			case !c.isGroupEntry(member):
+				users = append(users, member)
+			case !expand:
+			case depth >= maxNestedGroupDepth:
+				return fmt.Errorf("group %s is nested more than %d levels deep", memberDN, maxNestedGroupDepth)
+			default:
+				if err := walk(member, depth+1); err != nil {
+					return err
+				}
 			}
 		}
+		return nil
+	}
+
+	groups, err := c.lookupEntries(search, []string{groupDN})
+	if err != nil {
+		return nil, err
+	}
+	group := groups[strings.ToLower(groupDN)]
+	if group == nil {
+		return nil, nil
+	}
+	if err := walk(group, 1); err != nil {
+		return nil, err
 	}
 	return users, nil
 }
 
+// isGroupEntry reports whether entry is a group: an LDAP or Active Directory
+// group, or an entry of the configured group class.
+func (c *Connector) isGroupEntry(entry *ldap.Entry) bool {
+	for _, class := range entry.GetAttributeValues("objectClass") {
+		switch strings.ToLower(class) {
+		case "group", "groupofnames", "groupofuniquenames":
+			return true
+		}
+		if strings.EqualFold(class, c.groupClasses[0]) {
+			return true
+		}
+	}
+	return false
+}
+
+// userFilter matches the user whose username or RDN attribute is id.
+func (c *Connector) userFilter(id string) string {
+	escaped := ldap.EscapeFilter(id)
+	return fmt.Sprintf("(&%s(|(%s=%s)(%s=%s)))",
+		classFilter(c.userClasses), c.attrs[connector.FieldUsername], escaped, c.userRDN, escaped)
+}
+
+// groupFilter matches the group whose RDN attribute is id.
+func (c *Connector) groupFilter(id string) string {
+	return fmt.Sprintf("(&%s(%s=%s))", classFilter(c.groupClasses), c.groupRDN, ldap.EscapeFilter(id))
+}
+
+// classFilter matches entries of the first, structural, class in classes.
+func classFilter(classes []string) string {
+	return "(objectClass=" + classes[0] + ")"
+}
+
+func hasClass(classes []string, class string) bool {
+	for _, v := range classes {
+		if strings.EqualFold(v, class) {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Connector) getUsersOU() string {
 	if ou, ok := c.config.Settings["users_ou"]; ok {
 		return ou
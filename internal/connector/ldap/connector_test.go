@@ -0,0 +1,474 @@
+package ldap
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dhawalhost/wardseal/internal/connector"
+	"github.com/go-ldap/ldap/v3"
+)
+
+func newTestConnector(t *testing.T, attributeMap string) *Connector {
+	t.Helper()
+	conn, err := New(connector.Config{
+		Settings: map[string]string{
+			"base_dn":                     "dc=example,dc=com",
+			connector.SettingAttributeMap: attributeMap,
+		},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return conn.(*Connector)
+}
+
+func TestCustomAttributeMapReadsEntries(t *testing.T) {
+	conn := newTestConnector(t, `{"username": "sAMAccountName", "email": "userPrincipalName"}`)
+	entry := ldap.NewEntry("cn=Ada Lovelace,ou=users,dc=example,dc=com", map[string][]string{
+		"sAMAccountName":    {"alovelace"},
+		"userPrincipalName": {"ada@corp.example"},
+		"uid":               {"ignored"},
+		"mail":              {"ignored@example.com"},
+		"givenName":         {"Ada"},
+		"sn":                {"Lovelace"},
+	})
+
+	user := conn.userFromEntry(entry)
+	if user.Username != "alovelace" || user.Email != "ada@corp.example" ||
+		user.FirstName != "Ada" || user.LastName != "Lovelace" || user.ExternalID != entry.DN {
+		t.Fatalf("unexpected user %+v", user)
+	}
+
+	attrs := conn.userSearchAttributes()
+	for _, want := range []string{"sAMAccountName", "userPrincipalName", "givenName", "sn", "cn"} {
+		found := false
+		for _, attr := range attrs {
+			found = found || attr == want
+		}
+		if !found {
+			t.Errorf("expected %s in search attributes %v", want, attrs)
+		}
+	}
+}
+
+func TestCustomAttributeMapWritesEntries(t *testing.T) {
+	conn := newTestConnector(t, `{"username": "sAMAccountName", "email": "userPrincipalName"}`)
+	user := connector.User{Username: "alovelace", Email: "ada@corp.example", FirstName: "Ada", LastName: "Lovelace"}
+
+	addReq := conn.newAddRequest(user)
+	got := make(map[string][]string)
+	for _, attr := range addReq.Attributes {
+		got[attr.Type] = attr.Vals
+	}
+	want := map[string][]string{
+		"objectClass":       {"inetOrgPerson", "organizationalPerson", "person", "top"},
+		"cn":                {"alovelace"},
+		"sAMAccountName":    {"alovelace"},
+		"userPrincipalName": {"ada@corp.example"},
+		"givenName":         {"Ada"},
+		"sn":                {"Lovelace"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected attributes %v, got %v", want, got)
+	}
+
+	modReq := conn.newModifyRequest(ldap.NewEntry("cn=alovelace,ou=users,dc=example,dc=com", nil), user)
+	replaced := make(map[string][]string)
+	for _, change := range modReq.Changes {
+		replaced[change.Modification.Type] = change.Modification.Vals
+	}
+	if _, ok := replaced["sAMAccountName"]; ok {
+		t.Fatalf("expected the username not to be modified, got %v", replaced)
+	}
+	if !reflect.DeepEqual(replaced["userPrincipalName"], []string{"ada@corp.example"}) {
+		t.Fatalf("expected the email in userPrincipalName, got %v", replaced)
+	}
+}
+
+func TestDefaultAttributeMapMatchesInetOrgPerson(t *testing.T) {
+	conn := newTestConnector(t, "")
+	entry := ldap.NewEntry("cn=ada,ou=users,dc=example,dc=com", map[string][]string{
+		"uid": {"ada"}, "mail": {"ada@example.com"}, "displayName": {"Ada L."},
+	})
+	user := conn.userFromEntry(entry)
+	if user.Username != "ada" || user.Email != "ada@example.com" || user.DisplayName != "Ada L." || !user.Active {
+		t.Fatalf("unexpected user %+v", user)
+	}
+}
+
+// nestedDirectory is engineering -> (ada, backend -> (grace, platform ->
+// (linus, engineering))), where platform's membership of engineering is a cycle.
+func nestedDirectory() map[string]*ldap.Entry {
+	group := func(dn string, members ...string) *ldap.Entry {
+		return ldap.NewEntry(dn, map[string][]string{"objectClass": {"top", "groupOfNames"}, "member": members})
+	}
+	user := func(dn, uid string) *ldap.Entry {
+		return ldap.NewEntry(dn, map[string][]string{"objectClass": {"inetOrgPerson"}, "uid": {uid}})
+	}
+	entries := []*ldap.Entry{
+		group("cn=engineering,ou=groups,dc=example,dc=com",
+			"cn=ada,ou=users,dc=example,dc=com", "cn=backend,ou=groups,dc=example,dc=com"),
+		group("cn=backend,ou=groups,dc=example,dc=com",
+			"cn=grace,ou=users,dc=example,dc=com", "cn=platform,ou=groups,dc=example,dc=com", "cn=ada,ou=users,dc=example,dc=com"),
+		group("cn=platform,ou=groups,dc=example,dc=com",
+			"cn=linus,ou=users,dc=example,dc=com", "cn=engineering,ou=groups,dc=example,dc=com"),
+		user("cn=ada,ou=users,dc=example,dc=com", "ada"),
+		user("cn=grace,ou=users,dc=example,dc=com", "grace"),
+		user("cn=linus,ou=users,dc=example,dc=com", "linus"),
+	}
+	byDN := make(map[string]*ldap.Entry, len(entries))
+	for _, entry := range entries {
+		byDN[entry.DN] = entry
+	}
+	return byDN
+}
+
+// fakeSearch serves searches from entries, returning those whose DN the
+// filter asks for, and counts the searches.
+func fakeSearch(entries map[string]*ldap.Entry, searches *int) searchFunc {
+	return func(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+		*searches++
+		result := &ldap.SearchResult{}
+		for dn, entry := range entries {
+			if strings.Contains(req.Filter, "(entryDN="+ldap.EscapeFilter(dn)+")") {
+				result.Entries = append(result.Entries, entry)
+			}
+		}
+		return result, nil
+	}
+}
+
+func memberUIDs(t *testing.T, conn *Connector, entries map[string]*ldap.Entry) []string {
+	t.Helper()
+	searches := 0
+	members, err := conn.flattenMembers("cn=engineering,ou=groups,dc=example,dc=com", fakeSearch(entries, &searches))
+	if err != nil {
+		t.Fatalf("flattenMembers failed: %v", err)
+	}
+	if searches > 10 {
+		t.Fatalf("expansion did not terminate after %d searches", searches)
+	}
+	var uids []string
+	for _, member := range members {
+		uids = append(uids, conn.userFromEntry(member).Username)
+	}
+	return uids
+}
+
+func TestFlattenMembersExpandsNestedGroupsWhenEnabled(t *testing.T) {
+	conn := newTestConnector(t, "")
+	conn.config.Settings[SettingExpandNestedGroups] = "true"
+
+	got := memberUIDs(t, conn, nestedDirectory())
+	if want := []string{"ada", "grace", "linus"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected each nested user once, %v, got %v", want, got)
+	}
+}
+
+func TestFlattenMembersSkipsNestedGroupsByDefault(t *testing.T) {
+	conn := newTestConnector(t, "")
+
+	got := memberUIDs(t, conn, nestedDirectory())
+	if want := []string{"ada"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected only direct users %v, got %v", want, got)
+	}
+}
+
+func TestFlattenMembersEnforcesDepthLimit(t *testing.T) {
+	conn := newTestConnector(t, "")
+	conn.config.Settings[SettingExpandNestedGroups] = "true"
+
+	entries := make(map[string]*ldap.Entry)
+	for i := 0; i <= maxNestedGroupDepth; i++ {
+		dn := fmt.Sprintf("cn=level%d,ou=groups,dc=example,dc=com", i)
+		child := fmt.Sprintf("cn=level%d,ou=groups,dc=example,dc=com", i+1)
+		entries[dn] = ldap.NewEntry(dn, map[string][]string{"objectClass": {"groupOfNames"}, "member": {child}})
+	}
+	searches := 0
+	_, err := conn.flattenMembers("cn=level0,ou=groups,dc=example,dc=com", fakeSearch(entries, &searches))
+	if err == nil {
+		t.Fatal("expected an error for groups nested past the depth limit")
+	}
+}
+
+func TestFlattenMembersBatchesMemberLookups(t *testing.T) {
+	conn := newTestConnector(t, "")
+	groupDN := "cn=everyone,ou=groups,dc=example,dc=com"
+	entries := make(map[string]*ldap.Entry)
+	var members []string
+	for i := 0; i < 50; i++ {
+		// RDNs other than cn are resolved by DN, not parsed.
+		dn := fmt.Sprintf("uid=user%d,ou=people,dc=example,dc=com", i)
+		entries[dn] = ldap.NewEntry(dn, map[string][]string{"objectClass": {"inetOrgPerson"}, "uid": {fmt.Sprintf("user%d", i)}})
+		members = append(members, dn)
+	}
+	entries[groupDN] = ldap.NewEntry(groupDN, map[string][]string{"objectClass": {"groupOfNames"}, "member": members})
+
+	searches := 0
+	got, err := conn.flattenMembers(groupDN, fakeSearch(entries, &searches))
+	if err != nil {
+		t.Fatalf("flattenMembers failed: %v", err)
+	}
+	if len(got) != 50 {
+		t.Fatalf("expected 50 members, got %d", len(got))
+	}
+	if searches > 2 {
+		t.Fatalf("expected the group and its members in at most 2 searches, got %d", searches)
+	}
+}
+
+func TestUserAccountControlSetsActive(t *testing.T) {
+	conn := newTestConnector(t, "")
+	tests := []struct {
+		name string
+		uac  []string
+		want bool
+	}{
+		{name: "enabled", uac: []string{"512"}, want: true},
+		{name: "disabled", uac: []string{"514"}, want: false},
+		{name: "disabled with other flags", uac: []string{"66050"}, want: false},
+		{name: "absent", uac: nil, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attrs := map[string][]string{"uid": {"ada"}}
+			if tt.uac != nil {
+				attrs["userAccountControl"] = tt.uac
+			}
+			if got := conn.userFromEntry(ldap.NewEntry("cn=ada,dc=example,dc=com", attrs)).Active; got != tt.want {
+				t.Fatalf("expected active %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestUserAccountControlIsWrittenWhenTogglingActive(t *testing.T) {
+	conn := newTestConnector(t, "")
+	replaced := func(modReq *ldap.ModifyRequest) []string {
+		for _, change := range modReq.Changes {
+			if change.Modification.Type == "userAccountControl" {
+				return change.Modification.Vals
+			}
+		}
+		return nil
+	}
+
+	// 66048 is a normal account whose password does not expire.
+	enabled := ldap.NewEntry("cn=ada,dc=example,dc=com", map[string][]string{"userAccountControl": {"66048"}})
+	if got := replaced(conn.newModifyRequest(enabled, connector.User{Active: false})); !reflect.DeepEqual(got, []string{"66050"}) {
+		t.Fatalf("expected disabling to set only the disabled bit, got %v", got)
+	}
+	disabled := ldap.NewEntry("cn=ada,dc=example,dc=com", map[string][]string{"userAccountControl": {"514"}})
+	if got := replaced(conn.newModifyRequest(disabled, connector.User{Active: true})); !reflect.DeepEqual(got, []string{"512"}) {
+		t.Fatalf("expected enabling to clear the disabled bit, got %v", got)
+	}
+	plain := ldap.NewEntry("cn=ada,dc=example,dc=com", map[string][]string{"uid": {"ada"}})
+	if got := replaced(conn.newModifyRequest(plain, connector.User{Active: false})); got != nil {
+		t.Fatalf("expected no userAccountControl for entries without one, got %v", got)
+	}
+
+	created := func(conn *Connector, active bool) []string {
+		for _, attr := range conn.newAddRequest(connector.User{Username: "ada", Active: active}).Attributes {
+			if attr.Type == "userAccountControl" {
+				return attr.Vals
+			}
+		}
+		return nil
+	}
+	if got := created(conn, false); got != nil {
+		t.Fatalf("expected no userAccountControl outside Active Directory, got %v", got)
+	}
+	conn.config.Settings[SettingActiveDirectory] = "true"
+	if got := created(conn, true); !reflect.DeepEqual(got, []string{"512"}) {
+		t.Fatalf("expected an enabled account, got %v", got)
+	}
+	if got := created(conn, false); !reflect.DeepEqual(got, []string{"514"}) {
+		t.Fatalf("expected a disabled account, got %v", got)
+	}
+}
+
+func newActiveDirectoryConnector(t *testing.T) *Connector {
+	t.Helper()
+	conn, err := New(connector.Config{
+		Settings: map[string]string{
+			"base_dn":                 "dc=corp,dc=example",
+			"users_ou":                "cn=Users,dc=corp,dc=example",
+			SettingUserObjectClasses:  "user, person, organizationalPerson, top",
+			SettingGroupObjectClasses: "group,top",
+			SettingUsernameAttribute:  "sAMAccountName",
+			SettingMailAttribute:      "userPrincipalName",
+		},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return conn.(*Connector)
+}
+
+func TestActiveDirectorySchemaAddRequest(t *testing.T) {
+	conn := newActiveDirectoryConnector(t)
+
+	addReq := conn.newAddRequest(connector.User{Username: "alovelace", Email: "ada@corp.example", LastName: "Lovelace"})
+	if addReq.DN != "cn=alovelace,cn=Users,dc=corp,dc=example" {
+		t.Fatalf("unexpected DN %q", addReq.DN)
+	}
+	got := make(map[string][]string)
+	for _, attr := range addReq.Attributes {
+		got[attr.Type] = attr.Vals
+	}
+	want := map[string][]string{
+		"objectClass":       {"user", "person", "organizationalPerson", "top"},
+		"cn":                {"alovelace"},
+		"sAMAccountName":    {"alovelace"},
+		"userPrincipalName": {"ada@corp.example"},
+		"sn":                {"Lovelace"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected attributes %v, got %v", want, got)
+	}
+}
+
+func TestActiveDirectorySchemaSearchFilters(t *testing.T) {
+	conn := newActiveDirectoryConnector(t)
+
+	if got, want := conn.userFilter("a*da"), `(&(objectClass=user)(|(sAMAccountName=a\2ada)(cn=a\2ada)))`; got != want {
+		t.Fatalf("expected user filter %s, got %s", want, got)
+	}
+	if got, want := conn.groupFilter("Engineers"), "(&(objectClass=group)(cn=Engineers))"; got != want {
+		t.Fatalf("expected group filter %s, got %s", want, got)
+	}
+	if !conn.isGroupEntry(ldap.NewEntry("cn=Engineers,dc=corp,dc=example", map[string][]string{"objectClass": {"top", "group"}})) {
+		t.Fatal("expected AD groups to be recognized")
+	}
+}
+
+func TestCustomRDNAttribute(t *testing.T) {
+	conn, err := New(connector.Config{Settings: map[string]string{
+		"base_dn":                "dc=example,dc=com",
+		SettingUserRDNAttribute:  "uid",
+		SettingGroupRDNAttribute: "ou",
+	}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	c := conn.(*Connector)
+
+	addReq := c.newAddRequest(connector.User{Username: "ada"})
+	if addReq.DN != "uid=ada,ou=users,dc=example,dc=com" {
+		t.Fatalf("unexpected DN %q", addReq.DN)
+	}
+	for _, attr := range addReq.Attributes {
+		if attr.Type == "cn" {
+			t.Fatalf("expected no cn attribute when uid is the RDN, got %v", attr.Vals)
+		}
+	}
+	if got, want := c.userFilter("ada"), "(&(objectClass=inetOrgPerson)(|(uid=ada)(uid=ada)))"; got != want {
+		t.Fatalf("expected user filter %s, got %s", want, got)
+	}
+	if got, want := c.groupFilter("eng"), "(&(objectClass=groupOfNames)(ou=eng))"; got != want {
+		t.Fatalf("expected group filter %s, got %s", want, got)
+	}
+}
+
+func TestPasswordModifyRequest(t *testing.T) {
+	tests := []struct {
+		name        string
+		conn        *Connector
+		forceChange bool
+		want        map[string][]string
+	}{
+		{
+			name: "openldap",
+			conn: newTestConnector(t, ""),
+			want: map[string][]string{"userPassword": {"s3cret!"}},
+		},
+		{
+			name:        "openldap forced change",
+			conn:        newTestConnector(t, ""),
+			forceChange: true,
+			want:        map[string][]string{"userPassword": {"s3cret!"}, "pwdReset": {"TRUE"}},
+		},
+		{
+			name:        "active directory forced change",
+			conn:        withActiveDirectory(newActiveDirectoryConnector(t)),
+			forceChange: true,
+			want: map[string][]string{
+				// "s3cret!" in quotes, UTF-16LE.
+				"unicodePwd": {"\"\x00s\x003\x00c\x00r\x00e\x00t\x00!\x00\"\x00"},
+				"pwdLastSet": {"0"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modReq := tt.conn.newPasswordModifyRequest("cn=ada,dc=example,dc=com", "s3cret!", tt.forceChange)
+			if modReq.DN != "cn=ada,dc=example,dc=com" {
+				t.Fatalf("unexpected DN %q", modReq.DN)
+			}
+			got := make(map[string][]string)
+			for _, change := range modReq.Changes {
+				if change.Operation != ldap.ReplaceAttribute {
+					t.Fatalf("expected replace operations, got %d for %s", change.Operation, change.Modification.Type)
+				}
+				got[change.Modification.Type] = change.Modification.Vals
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected changes %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func withActiveDirectory(c *Connector) *Connector {
+	c.config.Settings[SettingDirectoryType] = DirectoryTypeAD
+	return c
+}
+
+func TestEncodeUnicodePwd(t *testing.T) {
+	got := []byte(encodeUnicodePwd("Pä$1"))
+	// '"', 'P', 'ä' (U+00E4), '$', '1', '"' as UTF-16LE code units.
+	want := []byte{0x22, 0x00, 0x50, 0x00, 0xe4, 0x00, 0x24, 0x00, 0x31, 0x00, 0x22, 0x00}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("expected % x, got % x", want, got)
+	}
+	// Characters outside the BMP take a surrogate pair.
+	if got := []byte(encodeUnicodePwd("😀")); !bytes.Equal(got, []byte{0x22, 0x00, 0x3d, 0xd8, 0x00, 0xde, 0x22, 0x00}) {
+		t.Fatalf("unexpected encoding % x", got)
+	}
+}
+
+func TestSetPasswordRequiresTLS(t *testing.T) {
+	conn := withActiveDirectory(newActiveDirectoryConnector(t))
+	if err := conn.SetPassword(context.Background(), "ada", "s3cret!", false); err == nil {
+		t.Fatal("expected an error without a connection")
+	}
+
+	client, server := net.Pipe()
+	t.Cleanup(func() { _ = client.Close(); _ = server.Close() })
+
+	conn.conn = ldap.NewConn(client, false)
+	if err := conn.SetPassword(context.Background(), "ada", "s3cret!", false); !errors.Is(err, ErrInsecureConnection) {
+		t.Fatalf("expected ErrInsecureConnection over a plain connection, got %v", err)
+	}
+
+	conn.conn = ldap.NewConn(tls.Client(client, &tls.Config{ServerName: "dc.corp.example"}), true)
+	if err := conn.requireTLS(); err != nil {
+		t.Fatalf("expected a TLS connection to be accepted, got %v", err)
+	}
+}
+
+func TestDirectoryTypeIsValidated(t *testing.T) {
+	for value, wantErr := range map[string]bool{"": false, "generic": false, "ad": false, "openldap": true} {
+		errs := Schema.Validate(connector.Config{Settings: map[string]string{SettingDirectoryType: value}})
+		if (len(errs) > 0) != wantErr {
+			t.Errorf("directory_type %q: got errors %v", value, errs)
+		}
+	}
+}
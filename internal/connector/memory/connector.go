@@ -0,0 +1,353 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dhawalhost/wardseal/internal/connector"
+	"github.com/google/uuid"
+)
+
+// Settings keys that make the connector fail on purpose, for exercising
+// error paths without an external system.
+const (
+	SettingFailInitialize  = "fail_initialize"
+	SettingFailHealthCheck = "fail_health_check"
+)
+
+// SettingLatency is a Go duration each user write and membership change
+// waits before applying, to stand in for a slow target.
+const SettingLatency = "latency"
+
+// Schema is the memory connector's config schema; it needs nothing.
+var Schema = connector.ConfigSchema{}
+
+// Connector implements the connector.Connector interface with in-process
+// storage. It is intended for tests and local development.
+type Connector struct {
+	config connector.Config
+
+	mu      sync.Mutex
+	users   map[string]connector.User
+	groups  map[string]connector.Group
+	members map[string]map[string]bool // group ID -> user IDs
+
+	// seq numbers every change so ListUserChanges and ListGroupChanges can
+	// resume from a cursor.
+	seq       int64
+	userSeqs  map[string]int64
+	groupSeqs map[string]int64
+
+	// calls counts the writes in progress, and peakCalls the most seen at once.
+	callMu    sync.Mutex
+	calls     int
+	peakCalls int
+}
+
+// New creates a new in-memory connector.
+func New(config connector.Config) (connector.Connector, error) {
+	return &Connector{
+		config:    config,
+		users:     make(map[string]connector.User),
+		groups:    make(map[string]connector.Group),
+		members:   make(map[string]map[string]bool),
+		userSeqs:  make(map[string]int64),
+		groupSeqs: make(map[string]int64),
+	}, nil
+}
+
+func (c *Connector) ID() string   { return c.config.ID }
+func (c *Connector) Name() string { return c.config.Name }
+func (c *Connector) Type() string { return "memory" }
+
+// Capabilities reports incremental sync through ListUserChanges and
+// ListGroupChanges.
+func (c *Connector) Capabilities() connector.CapabilitySet {
+	return connector.NewCapabilitySet(connector.CapabilityIncrementalSync)
+}
+
+func (c *Connector) Initialize(ctx context.Context, config connector.Config) error {
+	if msg := config.Settings[SettingFailInitialize]; msg != "" {
+		return errors.New(msg)
+	}
+	c.config = config
+	return nil
+}
+
+func (c *Connector) HealthCheck(ctx context.Context) error {
+	if msg := c.config.Settings[SettingFailHealthCheck]; msg != "" {
+		return errors.New(msg)
+	}
+	return nil
+}
+
+func (c *Connector) Close() error { return nil }
+
+// PeakConcurrentCalls returns the most user writes and membership changes
+// that have been in progress at once.
+func (c *Connector) PeakConcurrentCalls() int {
+	c.callMu.Lock()
+	defer c.callMu.Unlock()
+	return c.peakCalls
+}
+
+// call records a write as in progress and waits out the configured latency.
+// The returned func marks it done.
+func (c *Connector) call(ctx context.Context) func() {
+	c.callMu.Lock()
+	c.calls++
+	if c.calls > c.peakCalls {
+		c.peakCalls = c.calls
+	}
+	c.callMu.Unlock()
+
+	if d, err := time.ParseDuration(c.config.Settings[SettingLatency]); err == nil && d > 0 {
+		select {
+		case <-time.After(d):
+		case <-ctx.Done():
+		}
+	}
+	return func() {
+		c.callMu.Lock()
+		c.calls--
+		c.callMu.Unlock()
+	}
+}
+
+// User operations
+func (c *Connector) CreateUser(ctx context.Context, user connector.User) (string, error) {
+	defer c.call(ctx)()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	user.ExternalID = uuid.New().String()
+	user.UpdatedAt = time.Now()
+	c.users[user.ExternalID] = user
+	c.seq++
+	c.userSeqs[user.ExternalID] = c.seq
+	return user.ExternalID, nil
+}
+
+func (c *Connector) GetUser(ctx context.Context, id string) (connector.User, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	user, ok := c.users[id]
+	if !ok {
+		return connector.User{}, fmt.Errorf("user not found: %s", id)
+	}
+	return user, nil
+}
+
+func (c *Connector) UpdateUser(ctx context.Context, id string, user connector.User) error {
+	defer c.call(ctx)()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.users[id]; !ok {
+		return fmt.Errorf("user not found: %s", id)
+	}
+	user.ExternalID = id
+	user.UpdatedAt = time.Now()
+	c.users[id] = user
+	c.seq++
+	c.userSeqs[id] = c.seq
+	return nil
+}
+
+func (c *Connector) DeleteUser(ctx context.Context, id string) error {
+	defer c.call(ctx)()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.users, id)
+	delete(c.userSeqs, id)
+	for _, members := range c.members {
+		delete(members, id)
+	}
+	return nil
+}
+
+// ListUsers matches filter as a case-insensitive substring of the username or email.
+func (c *Connector) ListUsers(ctx context.Context, filter string, limit, offset int) ([]connector.User, int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	filter = strings.ToLower(filter)
+	var matched []connector.User
+	for _, user := range c.users {
+		if filter == "" || strings.Contains(strings.ToLower(user.Username), filter) || strings.Contains(strings.ToLower(user.Email), filter) {
+			matched = append(matched, user)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ExternalID < matched[j].ExternalID })
+	return paginate(matched, limit, offset), len(matched), nil
+}
+
+// Group operations
+func (c *Connector) CreateGroup(ctx context.Context, group connector.Group) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	group.ExternalID = uuid.New().String()
+	c.groups[group.ExternalID] = group
+	c.seq++
+	c.groupSeqs[group.ExternalID] = c.seq
+	return group.ExternalID, nil
+}
+
+func (c *Connector) GetGroup(ctx context.Context, id string) (connector.Group, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	group, ok := c.groups[id]
+	if !ok {
+		return connector.Group{}, fmt.Errorf("group not found: %s", id)
+	}
+	return group, nil
+}
+
+func (c *Connector) UpdateGroup(ctx context.Context, id string, group connector.Group) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.groups[id]; !ok {
+		return fmt.Errorf("group not found: %s", id)
+	}
+	group.ExternalID = id
+	c.groups[id] = group
+	c.seq++
+	c.groupSeqs[id] = c.seq
+	return nil
+}
+
+func (c *Connector) DeleteGroup(ctx context.Context, id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.groups, id)
+	delete(c.groupSeqs, id)
+	delete(c.members, id)
+	return nil
+}
+
+// ListGroups matches filter as a case-insensitive substring of the group name.
+func (c *Connector) ListGroups(ctx context.Context, filter string, limit, offset int) ([]connector.Group, int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	filter = strings.ToLower(filter)
+	var matched []connector.Group
+	for _, group := range c.groups {
+		if filter == "" || strings.Contains(strings.ToLower(group.Name), filter) {
+			matched = append(matched, group)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ExternalID < matched[j].ExternalID })
+	return paginate(matched, limit, offset), len(matched), nil
+}
+
+// Group membership
+func (c *Connector) AddUserToGroup(ctx context.Context, userID, groupID string) error {
+	defer c.call(ctx)()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.users[userID]; !ok {
+		return fmt.Errorf("user not found: %s", userID)
+	}
+	if _, ok := c.groups[groupID]; !ok {
+		return fmt.Errorf("group not found: %s", groupID)
+	}
+	if c.members[groupID] == nil {
+		c.members[groupID] = make(map[string]bool)
+	}
+	c.members[groupID][userID] = true
+	return nil
+}
+
+func (c *Connector) RemoveUserFromGroup(ctx context.Context, userID, groupID string) error {
+	defer c.call(ctx)()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.members[groupID], userID)
+	return nil
+}
+
+func (c *Connector) GetGroupMembers(ctx context.Context, groupID string) ([]connector.User, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.groups[groupID]; !ok {
+		return nil, fmt.Errorf("group not found: %s", groupID)
+	}
+	var users []connector.User
+	for userID := range c.members[groupID] {
+		users = append(users, c.users[userID])
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ExternalID < users[j].ExternalID })
+	return users, nil
+}
+
+// ListUserChanges returns users created or updated after cursor, oldest
+// change first. It implements connector.ChangeLister.
+func (c *Connector) ListUserChanges(ctx context.Context, cursor string, limit int) ([]connector.User, string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ids, next, err := changedSince(c.userSeqs, cursor, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	users := make([]connector.User, len(ids))
+	for i, id := range ids {
+		users[i] = c.users[id]
+	}
+	return users, next, nil
+}
+
+// ListGroupChanges returns groups created or updated after cursor, oldest
+// change first. It implements connector.ChangeLister.
+func (c *Connector) ListGroupChanges(ctx context.Context, cursor string, limit int) ([]connector.Group, string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ids, next, err := changedSince(c.groupSeqs, cursor, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	groups := make([]connector.Group, len(ids))
+	for i, id := range ids {
+		groups[i] = c.groups[id]
+	}
+	return groups, next, nil
+}
+
+// changedSince returns up to limit IDs whose change sequence is after cursor,
+// in sequence order, and the cursor to resume from.
+func changedSince(seqs map[string]int64, cursor string, limit int) ([]string, string, error) {
+	var after int64
+	if cursor != "" {
+		var err error
+		if after, err = strconv.ParseInt(cursor, 10, 64); err != nil {
+			return nil, "", fmt.Errorf("invalid cursor %q", cursor)
+		}
+	}
+	var ids []string
+	for id, seq := range seqs {
+		if seq > after {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return seqs[ids[i]] < seqs[ids[j]] })
+	if limit > 0 && len(ids) > limit {
+		ids = ids[:limit]
+	}
+	if len(ids) == 0 {
+		return nil, cursor, nil
+	}
+	return ids, strconv.FormatInt(seqs[ids[len(ids)-1]], 10), nil
+}
+
+func paginate[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	items = items[offset:]
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
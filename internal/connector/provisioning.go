@@ -2,7 +2,9 @@ package connector
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -13,6 +15,7 @@ import (
 // ProvisioningService manages async provisioning tasks.
 type ProvisioningService struct {
 	db       *sqlx.DB
+	store    Store
 	registry Registry
 	logger   *zap.Logger
 }
@@ -21,13 +24,20 @@ type ProvisioningService struct {
 func NewProvisioningService(db *sqlx.DB, registry Registry, logger *zap.Logger) *ProvisioningService {
 	return &ProvisioningService{
 		db:       db,
+		store:    NewStore(db),
 		registry: registry,
 		logger:   logger,
 	}
 }
 
-// EnqueueTask adds a provisioning task to the queue.
+// EnqueueTask adds a provisioning task to the queue. It fails with
+// ErrUnsupportedOperation, without queueing, when the connector lacks the
+// capability the operation needs.
 func (s *ProvisioningService) EnqueueTask(ctx context.Context, task ProvisioningTask) (string, error) {
+	if err := s.checkSupported(ctx, task); err != nil {
+		return "", err
+	}
+
 	payloadBytes, err := json.Marshal(task.Payload)
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal payload: %w", err)
@@ -51,6 +61,25 @@ func (s *ProvisioningService) EnqueueTask(ctx context.Context, task Provisioning
 	return id, nil
 }
 
+// checkSupported checks the task's operation against its connector's
+// capabilities. Connectors that are not running are checked when the task is
+// processed.
+func (s *ProvisioningService) checkSupported(ctx context.Context, task ProvisioningTask) error {
+	conn, ok := s.registry.Get(task.ConnectorID)
+	if !ok {
+		return nil
+	}
+	mode := DeprovisionDelete
+	if task.Operation == "delete_user" {
+		config, err := s.store.Get(ctx, task.TenantID, task.ConnectorID)
+		if err != nil {
+			return err
+		}
+		mode = DeprovisionModeFor(config)
+	}
+	return checkSupported(conn, task.Operation, mode)
+}
+
 // GetTask retrieves a task by ID.
 func (s *ProvisioningService) GetTask(ctx context.Context, tenantID, taskID string) (ProvisioningTask, error) {
 	var t taskRow
@@ -62,13 +91,76 @@ func (s *ProvisioningService) GetTask(ctx context.Context, tenantID, taskID stri
 	return t.toTask(), nil
 }
 
-// ListPendingTasks returns tasks that are ready to be processed.
-func (s *ProvisioningService) ListPendingTasks(ctx context.Context, limit int) ([]ProvisioningTask, error) {
+// Task errors returned by RetryTask.
+var (
+	ErrTaskNotFound  = errors.New("provisioning task not found")
+	ErrTaskNotFailed = errors.New("only failed provisioning tasks can be retried")
+)
+
+// ListFailedTasks returns the tenant's failed tasks, most recently failed
+// first. Failed tasks stay there until retried with RetryTask.
+func (s *ProvisioningService) ListFailedTasks(ctx context.Context, tenantID string, limit int) ([]ProvisioningTask, error) {
+	var rows []taskRow
+	err := s.db.SelectContext(ctx, &rows,
+		`SELECT * FROM provisioning_tasks
+		 WHERE tenant_id = $1 AND status = 'failed'
+		 ORDER BY processed_at DESC, id LIMIT $2`, tenantID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := make([]ProvisioningTask, len(rows))
+	for i, r := range rows {
+		tasks[i] = r.toTask()
+	}
+	return tasks, nil
+}
+
+// RetryTask puts a failed task back in the queue to run now, with its
+// automatic retries reset and its manual retry count bumped. It returns
+// ErrTaskNotFailed for tasks in any other state.
+func (s *ProvisioningService) RetryTask(ctx context.Context, tenantID, taskID string) (ProvisioningTask, error) {
+	var t taskRow
+	err := s.db.GetContext(ctx, &t,
+		`UPDATE provisioning_tasks
+		 SET status = 'pending', retry_count = 0, manual_retries = manual_retries + 1,
+		     scheduled_at = NOW(), processed_at = NULL
+		 WHERE id = $1 AND tenant_id = $2 AND status = 'failed'
+		 RETURNING *`, taskID, tenantID)
+	if err == nil {
+		return t.toTask(), nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return ProvisioningTask{}, err
+	}
+
+	// Nothing was updated: tell a missing task from one that has not failed.
+	var status string
+	err = s.db.GetContext(ctx, &status,
+		`SELECT status FROM provisioning_tasks WHERE id = $1 AND tenant_id = $2`, taskID, tenantID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ProvisioningTask{}, ErrTaskNotFound
+	}
+	if err != nil {
+		return ProvisioningTask{}, err
+	}
+	return ProvisioningTask{}, fmt.Errorf("%w: task is %s", ErrTaskNotFailed, status)
+}
+
+// ListPendingTasks returns up to limit tasks that are ready to be processed,
+// oldest first, taking at most perConnector from each connector so a backlog
+// on one connector cannot crowd out the others.
+func (s *ProvisioningService) ListPendingTasks(ctx context.Context, limit, perConnector int) ([]ProvisioningTask, error) {
 	var rows []taskRow
 	err := s.db.SelectContext(ctx, &rows,
-		`SELECT * FROM provisioning_tasks 
-		 WHERE status = 'pending' AND scheduled_at <= NOW()
-		 ORDER BY scheduled_at LIMIT $1`, limit)
+		`SELECT t.* FROM provisioning_tasks t
+		 JOIN (
+		     SELECT id, ROW_NUMBER() OVER (PARTITION BY connector_id ORDER BY scheduled_at) AS connector_rank
+		     FROM provisioning_tasks
+		     WHERE status = 'pending' AND scheduled_at <= NOW()
+		 ) ranked ON ranked.id = t.id
+		 WHERE ranked.connector_rank <= $2
+		 ORDER BY t.scheduled_at LIMIT $1`, limit, perConnector)
 	if err != nil {
 		return nil, err
 	}
@@ -154,7 +246,11 @@ func (s *ProvisioningService) executeOperation(ctx context.Context, conn Connect
 		return conn.UpdateUser(ctx, task.ResourceID, user)
 
 	case "delete_user":
-		return conn.DeleteUser(ctx, task.ResourceID)
+		config, err := s.store.Get(ctx, task.TenantID, task.ConnectorID)
+		if err != nil {
+			return err
+		}
+		return deprovisionUser(ctx, conn, DeprovisionModeFor(config), task.ResourceID)
 
 	case "add_to_group":
 		var payload struct {
@@ -180,6 +276,10 @@ func (s *ProvisioningService) executeOperation(ctx context.Context, conn Connect
 		}
 		return conn.RemoveUserFromGroup(ctx, payload.UserID, payload.GroupID)
 
+	case OperationAssignLicense, OperationAssignDirectoryRole:
+		b, _ := task.Payload.([]byte)
+		return executeAssignment(ctx, conn, task.Operation, b)
+
 	default:
 		return fmt.Errorf("unknown operation: %s", task.Operation)
 	}
@@ -187,35 +287,37 @@ func (s *ProvisioningService) executeOperation(ctx context.Context, conn Connect
 
 // taskRow represents a DB row.
 type taskRow struct {
-	ID           string     `db:"id"`
-	TenantID     string     `db:"tenant_id"`
-	ConnectorID  string     `db:"connector_id"`
-	Operation    string     `db:"operation"`
-	ResourceType string     `db:"resource_type"`
-	ResourceID   *string    `db:"resource_id"`
-	Payload      []byte     `db:"payload"`
-	Status       string     `db:"status"`
-	ErrorMessage *string    `db:"error_message"`
-	RetryCount   int        `db:"retry_count"`
-	MaxRetries   int        `db:"max_retries"`
-	CreatedAt    time.Time  `db:"created_at"`
-	ProcessedAt  *time.Time `db:"processed_at"`
-	ScheduledAt  time.Time  `db:"scheduled_at"`
+	ID            string     `db:"id"`
+	TenantID      string     `db:"tenant_id"`
+	ConnectorID   string     `db:"connector_id"`
+	Operation     string     `db:"operation"`
+	ResourceType  string     `db:"resource_type"`
+	ResourceID    *string    `db:"resource_id"`
+	Payload       []byte     `db:"payload"`
+	Status        string     `db:"status"`
+	ErrorMessage  *string    `db:"error_message"`
+	RetryCount    int        `db:"retry_count"`
+	MaxRetries    int        `db:"max_retries"`
+	ManualRetries int        `db:"manual_retries"`
+	CreatedAt     time.Time  `db:"created_at"`
+	ProcessedAt   *time.Time `db:"processed_at"`
+	ScheduledAt   time.Time  `db:"scheduled_at"`
 }
 
 func (r taskRow) toTask() ProvisioningTask {
 	t := ProvisioningTask{
-		ID:           r.ID,
-		TenantID:     r.TenantID,
-		ConnectorID:  r.ConnectorID,
-		Operation:    r.Operation,
-		ResourceType: r.ResourceType,
-		Payload:      r.Payload,
-		Status:       r.Status,
-		RetryCount:   r.RetryCount,
-		MaxRetries:   r.MaxRetries,
-		CreatedAt:    r.CreatedAt,
-		ProcessedAt:  r.ProcessedAt,
+		ID:            r.ID,
+		TenantID:      r.TenantID,
+		ConnectorID:   r.ConnectorID,
+		Operation:     r.Operation,
+		ResourceType:  r.ResourceType,
+		Payload:       r.Payload,
+		Status:        r.Status,
+		RetryCount:    r.RetryCount,
+		MaxRetries:    r.MaxRetries,
+		ManualRetries: r.ManualRetries,
+		CreatedAt:     r.CreatedAt,
+		ProcessedAt:   r.ProcessedAt,
 	}
 	if r.ResourceID != nil {
 		t.ResourceID = *r.ResourceID
@@ -0,0 +1,92 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/dhawalhost/wardseal/pkg/apierr"
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// TaskService is the part of ProvisioningService the dead-letter endpoints
+// use.
+type TaskService interface {
+	ListFailedTasks(ctx context.Context, tenantID string, limit int) ([]ProvisioningTask, error)
+	RetryTask(ctx context.Context, tenantID, taskID string) (ProvisioningTask, error)
+}
+
+const (
+	defaultFailedTaskLimit = 50
+	maxFailedTaskLimit     = 500
+)
+
+// ProvisioningHTTPHandler serves the failed provisioning tasks and retries
+// them.
+type ProvisioningHTTPHandler struct {
+	tasks  TaskService
+	logger *zap.Logger
+}
+
+// NewProvisioningHTTPHandler creates a new provisioning HTTP handler.
+func NewProvisioningHTTPHandler(tasks TaskService, logger *zap.Logger) *ProvisioningHTTPHandler {
+	return &ProvisioningHTTPHandler{tasks: tasks, logger: logger}
+}
+
+// RegisterRoutes registers provisioning task routes.
+func (h *ProvisioningHTTPHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	g := rg.Group("/provisioning/tasks")
+	{
+		g.GET("/failed", h.listFailedTasks)
+		g.POST("/:id/retry", h.retryTask)
+	}
+}
+
+func (h *ProvisioningHTTPHandler) listFailedTasks(c *gin.Context) {
+	tenantID, err := middleware.TenantIDFromGinContext(c)
+	if err != nil {
+		apierr.Abort(c, apierr.Invalid("tenant id required"))
+		return
+	}
+	limit := defaultFailedTaskLimit
+	if raw := c.Query("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxFailedTaskLimit {
+			apierr.Abort(c, apierr.Invalid("limit must be between 1 and 500"))
+			return
+		}
+	}
+
+	tasks, err := h.tasks.ListFailedTasks(c.Request.Context(), tenantID, limit)
+	if err != nil {
+		apierr.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tasks": tasks})
+}
+
+func (h *ProvisioningHTTPHandler) retryTask(c *gin.Context) {
+	tenantID, err := middleware.TenantIDFromGinContext(c)
+	if err != nil {
+		apierr.Abort(c, apierr.Invalid("tenant id required"))
+		return
+	}
+
+	task, err := h.tasks.RetryTask(c.Request.Context(), tenantID, c.Param("id"))
+	switch {
+	case errors.Is(err, ErrTaskNotFound):
+		apierr.Abort(c, apierr.NotFound(err.Error()))
+		return
+	case errors.Is(err, ErrTaskNotFailed):
+		apierr.Abort(c, apierr.Conflict(err.Error()))
+		return
+	case err != nil:
+		apierr.Abort(c, err)
+		return
+	}
+	h.logger.Info("Provisioning task retried", zap.String("task_id", task.ID), zap.Int("manual_retries", task.ManualRetries))
+	c.JSON(http.StatusOK, task)
+}
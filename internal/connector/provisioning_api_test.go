@@ -0,0 +1,116 @@
+package connector_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dhawalhost/wardseal/internal/connector"
+	"github.com/dhawalhost/wardseal/pkg/apierr"
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// taskQueue is an in-memory TaskService that applies RetryTask's rules.
+type taskQueue struct {
+	tasks map[string]*connector.ProvisioningTask
+}
+
+func (q *taskQueue) ListFailedTasks(ctx context.Context, tenantID string, limit int) ([]connector.ProvisioningTask, error) {
+	var failed []connector.ProvisioningTask
+	for _, t := range q.tasks {
+		if t.TenantID == tenantID && t.Status == "failed" && len(failed) < limit {
+			failed = append(failed, *t)
+		}
+	}
+	return failed, nil
+}
+
+func (q *taskQueue) RetryTask(ctx context.Context, tenantID, taskID string) (connector.ProvisioningTask, error) {
+	t, ok := q.tasks[taskID]
+	if !ok || t.TenantID != tenantID {
+		return connector.ProvisioningTask{}, connector.ErrTaskNotFound
+	}
+	if t.Status != "failed" {
+		return connector.ProvisioningTask{}, fmt.Errorf("%w: task is %s", connector.ErrTaskNotFailed, t.Status)
+	}
+	t.Status, t.RetryCount = "pending", 0
+	t.ManualRetries++
+	return *t, nil
+}
+
+const taskTenant = "22222222-2222-2222-2222-222222222222"
+
+func newTaskRouter(q *taskQueue) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(apierr.Handler(zap.NewNop()))
+	api := r.Group("/")
+	api.Use(middleware.TenantExtractor(middleware.TenantConfig{}))
+	connector.NewProvisioningHTTPHandler(q, zap.NewNop()).RegisterRoutes(api)
+	return r
+}
+
+func doTaskRequest(r *gin.Engine, method, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set(middleware.DefaultTenantHeader, taskTenant)
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	return resp
+}
+
+func TestRetryFailedTask(t *testing.T) {
+	q := &taskQueue{tasks: map[string]*connector.ProvisioningTask{
+		"t1": {ID: "t1", TenantID: taskTenant, Status: "failed", RetryCount: 3, MaxRetries: 3},
+	}}
+	r := newTaskRouter(q)
+
+	resp := doTaskRequest(r, http.MethodGet, "/provisioning/tasks/failed")
+	var listed struct {
+		Tasks []connector.ProvisioningTask `json:"tasks"`
+	}
+	if resp.Code != http.StatusOK || json.Unmarshal(resp.Body.Bytes(), &listed) != nil || len(listed.Tasks) != 1 {
+		t.Fatalf("expected one failed task, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	resp = doTaskRequest(r, http.MethodPost, "/provisioning/tasks/t1/retry")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var task connector.ProvisioningTask
+	if err := json.Unmarshal(resp.Body.Bytes(), &task); err != nil {
+		t.Fatal(err)
+	}
+	if task.Status != "pending" || task.RetryCount != 0 || task.ManualRetries != 1 {
+		t.Fatalf("unexpected retried task: %+v", task)
+	}
+
+	resp = doTaskRequest(r, http.MethodGet, "/provisioning/tasks/failed")
+	if err := json.Unmarshal(resp.Body.Bytes(), &listed); err != nil || len(listed.Tasks) != 0 {
+		t.Fatalf("expected no failed tasks after the retry, got %s", resp.Body.String())
+	}
+}
+
+func TestRetryRejectsTasksThatHaveNotFailed(t *testing.T) {
+	q := &taskQueue{tasks: map[string]*connector.ProvisioningTask{
+		"done": {ID: "done", TenantID: taskTenant, Status: "completed"},
+	}}
+	r := newTaskRouter(q)
+
+	resp := doTaskRequest(r, http.MethodPost, "/provisioning/tasks/done/retry")
+	if resp.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if q.tasks["done"].Status != "completed" {
+		t.Fatalf("expected the completed task to be left alone, got %s", q.tasks["done"].Status)
+	}
+
+	resp = doTaskRequest(r, http.MethodPost, "/provisioning/tasks/missing/retry")
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d: %s", resp.Code, resp.Body.String())
+	}
+}
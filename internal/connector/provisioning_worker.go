@@ -0,0 +1,209 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// SettingMaxConcurrentTasks caps how many provisioning tasks run against the
+// connector at once, so a burst of work cannot overwhelm the target. It
+// defaults to DefaultMaxConcurrentTasks.
+const SettingMaxConcurrentTasks = "max_concurrent_tasks"
+
+const (
+	// DefaultMaxConcurrentTasks is the per-connector cap when none is set.
+	DefaultMaxConcurrentTasks = 4
+	// DefaultProvisioningInterval is how often the worker polls for tasks.
+	DefaultProvisioningInterval = 5 * time.Second
+
+	defaultProvisioningBatchSize        = 100
+	defaultProvisioningPerConnectorSize = 16
+)
+
+// MaxConcurrentTasksFor returns the connector's concurrency cap.
+func MaxConcurrentTasksFor(config Config) int {
+	if n, err := strconv.Atoi(config.Settings[SettingMaxConcurrentTasks]); err == nil && n > 0 {
+		return n
+	}
+	return DefaultMaxConcurrentTasks
+}
+
+func validateMaxConcurrentTasks(config Config) error {
+	raw := config.Settings[SettingMaxConcurrentTasks]
+	if raw == "" {
+		return nil
+	}
+	if n, err := strconv.Atoi(raw); err != nil || n < 1 {
+		return fmt.Errorf("invalid %s %q: must be a positive integer", SettingMaxConcurrentTasks, raw)
+	}
+	return nil
+}
+
+// TaskQueue is the part of ProvisioningService the worker drives.
+type TaskQueue interface {
+	ListPendingTasks(ctx context.Context, limit, perConnector int) ([]ProvisioningTask, error)
+	ProcessTask(ctx context.Context, taskID string) error
+}
+
+// ConnectorConfigs looks up a connector's configuration. Store implements it.
+type ConnectorConfigs interface {
+	Get(ctx context.Context, tenantID, id string) (Config, error)
+}
+
+// ProvisioningWorkerConfig configures a ProvisioningWorker.
+type ProvisioningWorkerConfig struct {
+	Queue   TaskQueue
+	Configs ConnectorConfigs
+	// Interval defaults to DefaultProvisioningInterval.
+	Interval time.Duration
+	// BatchSize bounds how many pending tasks are read per poll; it defaults
+	// to 100.
+	BatchSize int
+	// PerConnectorBatchSize bounds how many of those come from one connector,
+	// so other connectors still get tasks read while one has a backlog. Keep
+	// it at least the largest max_concurrent_tasks; it defaults to 16.
+	PerConnectorBatchSize int
+	Logger                *zap.Logger
+}
+
+// ProvisioningWorker runs pending provisioning tasks in the background. Each
+// connector runs at most its max_concurrent_tasks at a time; tasks over the
+// cap stay pending and are picked up by a later poll.
+type ProvisioningWorker struct {
+	queue        TaskQueue
+	configs      ConnectorConfigs
+	interval     time.Duration
+	batchSize    int
+	perConnector int
+	logger       *zap.Logger
+
+	mu       sync.Mutex
+	inFlight map[string]int  // connector ID -> running tasks
+	running  map[string]bool // task IDs dispatched and not yet finished
+	wg       sync.WaitGroup
+}
+
+// NewProvisioningWorker creates a new provisioning worker.
+func NewProvisioningWorker(cfg ProvisioningWorkerConfig) *ProvisioningWorker {
+	w := &ProvisioningWorker{
+		queue:        cfg.Queue,
+		configs:      cfg.Configs,
+		interval:     cfg.Interval,
+		batchSize:    cfg.BatchSize,
+		perConnector: cfg.PerConnectorBatchSize,
+		logger:       cfg.Logger,
+		inFlight:     make(map[string]int),
+		running:      make(map[string]bool),
+	}
+	if w.interval <= 0 {
+		w.interval = DefaultProvisioningInterval
+	}
+	if w.batchSize <= 0 {
+		w.batchSize = defaultProvisioningBatchSize
+	}
+	if w.perConnector <= 0 {
+		w.perConnector = defaultProvisioningPerConnectorSize
+	}
+	if w.logger == nil {
+		w.logger = zap.NewNop()
+	}
+	return w
+}
+
+// Start polls for pending tasks every interval until ctx is done, then waits
+// for the tasks already running.
+func (w *ProvisioningWorker) Start(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		w.RunOnce(ctx)
+		select {
+		case <-ctx.Done():
+			w.Wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce starts the pending tasks their connectors have room for and
+// returns how many it started. It does not wait for them to finish.
+func (w *ProvisioningWorker) RunOnce(ctx context.Context) int {
+	tasks, err := w.queue.ListPendingTasks(ctx, w.batchSize, w.perConnector)
+	if err != nil {
+		w.logger.Error("Failed to list pending provisioning tasks", zap.Error(err))
+		return 0
+	}
+
+	limits := make(map[string]int)
+	started := 0
+	for _, task := range tasks {
+		if ctx.Err() != nil {
+			break
+		}
+		limit, ok := limits[task.ConnectorID]
+		if !ok {
+			limit = w.limitFor(ctx, task)
+			limits[task.ConnectorID] = limit
+		}
+		if !w.claim(task, limit) {
+			continue
+		}
+		started++
+		w.wg.Add(1)
+		go func(task ProvisioningTask) {
+			defer w.wg.Done()
+			defer w.release(task)
+			if err := w.queue.ProcessTask(ctx, task.ID); err != nil {
+				w.logger.Error("Provisioning task failed", zap.String("task_id", task.ID), zap.Error(err))
+			}
+		}(task)
+	}
+	return started
+}
+
+// Wait blocks until every started task has finished.
+func (w *ProvisioningWorker) Wait() {
+	w.wg.Wait()
+}
+
+// limitFor returns the task's connector cap. A connector whose config cannot
+// be read gets the default.
+func (w *ProvisioningWorker) limitFor(ctx context.Context, task ProvisioningTask) int {
+	if w.configs == nil {
+		return DefaultMaxConcurrentTasks
+	}
+	config, err := w.configs.Get(ctx, task.TenantID, task.ConnectorID)
+	if err != nil {
+		w.logger.Warn("Failed to load connector for provisioning", zap.String("connector_id", task.ConnectorID), zap.Error(err))
+		return DefaultMaxConcurrentTasks
+	}
+	return MaxConcurrentTasksFor(config)
+}
+
+// claim reserves a slot on the task's connector. It fails when the connector
+// is at its cap or the task is still running from an earlier poll.
+func (w *ProvisioningWorker) claim(task ProvisioningTask, limit int) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.running[task.ID] || w.inFlight[task.ConnectorID] >= limit {
+		return false
+	}
+	w.running[task.ID] = true
+	w.inFlight[task.ConnectorID]++
+	return true
+}
+
+func (w *ProvisioningWorker) release(task ProvisioningTask) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.running, task.ID)
+	if w.inFlight[task.ConnectorID]--; w.inFlight[task.ConnectorID] <= 0 {
+		delete(w.inFlight, task.ConnectorID)
+	}
+}
@@ -0,0 +1,149 @@
+package connector_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dhawalhost/wardseal/internal/connector"
+	"github.com/dhawalhost/wardseal/internal/connector/memory"
+)
+
+// memoryTaskQueue is a TaskQueue that provisions users into memory
+// connectors.
+type memoryTaskQueue struct {
+	conns map[string]*memory.Connector
+
+	mu    sync.Mutex
+	tasks []*connector.ProvisioningTask
+}
+
+func (q *memoryTaskQueue) ListPendingTasks(ctx context.Context, limit, perConnector int) ([]connector.ProvisioningTask, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var pending []connector.ProvisioningTask
+	perConn := make(map[string]int)
+	for _, t := range q.tasks {
+		if t.Status == "pending" && len(pending) < limit && perConn[t.ConnectorID] < perConnector {
+			perConn[t.ConnectorID]++
+			pending = append(pending, *t)
+		}
+	}
+	return pending, nil
+}
+
+func (q *memoryTaskQueue) ProcessTask(ctx context.Context, taskID string) error {
+	task := q.setStatus(taskID, "processing")
+	_, err := q.conns[task.ConnectorID].CreateUser(ctx, connector.User{Username: task.ID})
+	q.setStatus(taskID, "completed")
+	return err
+}
+
+func (q *memoryTaskQueue) setStatus(taskID, status string) connector.ProvisioningTask {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, t := range q.tasks {
+		if t.ID == taskID {
+			t.Status = status
+			return *t
+		}
+	}
+	return connector.ProvisioningTask{}
+}
+
+func (q *memoryTaskQueue) done() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, t := range q.tasks {
+		if t.Status != "completed" {
+			return false
+		}
+	}
+	return true
+}
+
+type configMap map[string]connector.Config
+
+func (m configMap) Get(ctx context.Context, tenantID, id string) (connector.Config, error) {
+	config, ok := m[id]
+	if !ok {
+		return connector.Config{}, fmt.Errorf("connector not found: %s", id)
+	}
+	return config, nil
+}
+
+func TestProvisioningWorkerCapsConcurrencyPerConnector(t *testing.T) {
+	configs := configMap{
+		"slow":   {ID: "slow", Settings: map[string]string{connector.SettingMaxConcurrentTasks: "2", memory.SettingLatency: "30ms"}},
+		"serial": {ID: "serial", Settings: map[string]string{connector.SettingMaxConcurrentTasks: "1", memory.SettingLatency: "30ms"}},
+	}
+	q := &memoryTaskQueue{conns: make(map[string]*memory.Connector)}
+	for id, config := range configs {
+		conn, err := memory.New(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q.conns[id] = conn.(*memory.Connector)
+	}
+	for i := 0; i < 6; i++ {
+		q.tasks = append(q.tasks, &connector.ProvisioningTask{ID: fmt.Sprintf("slow-%d", i), ConnectorID: "slow", Status: "pending"})
+	}
+	for i := 0; i < 3; i++ {
+		q.tasks = append(q.tasks, &connector.ProvisioningTask{ID: fmt.Sprintf("serial-%d", i), ConnectorID: "serial", Status: "pending"})
+	}
+
+	w := connector.NewProvisioningWorker(connector.ProvisioningWorkerConfig{Queue: q, Configs: configs})
+	ctx := context.Background()
+	if started := w.RunOnce(ctx); started != 3 {
+		t.Fatalf("expected the first poll to start 3 tasks, started %d", started)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !q.done() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for tasks to complete")
+		}
+		time.Sleep(5 * time.Millisecond)
+		w.RunOnce(ctx)
+	}
+	w.Wait()
+
+	if peak := q.conns["slow"].PeakConcurrentCalls(); peak != 2 {
+		t.Errorf("expected at most 2 concurrent calls to slow, saw %d", peak)
+	}
+	if peak := q.conns["serial"].PeakConcurrentCalls(); peak != 1 {
+		t.Errorf("expected 1 concurrent call to serial, saw %d", peak)
+	}
+	for _, conn := range q.conns {
+		if _, total, _ := conn.ListUsers(ctx, "", 10, 0); total == 0 {
+			t.Errorf("expected users to be provisioned")
+		}
+	}
+}
+
+func TestProvisioningWorkerDoesNotStarveOtherConnectors(t *testing.T) {
+	configs := configMap{
+		"backlog": {ID: "backlog", Settings: map[string]string{connector.SettingMaxConcurrentTasks: "1", memory.SettingLatency: "30ms"}},
+		"quiet":   {ID: "quiet", Settings: map[string]string{connector.SettingMaxConcurrentTasks: "1"}},
+	}
+	q := &memoryTaskQueue{conns: make(map[string]*memory.Connector)}
+	for id, config := range configs {
+		conn, err := memory.New(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q.conns[id] = conn.(*memory.Connector)
+	}
+	// The backlog's tasks are older and fill a whole batch on their own.
+	for i := 0; i < 20; i++ {
+		q.tasks = append(q.tasks, &connector.ProvisioningTask{ID: fmt.Sprintf("backlog-%d", i), ConnectorID: "backlog", Status: "pending"})
+	}
+	q.tasks = append(q.tasks, &connector.ProvisioningTask{ID: "quiet-0", ConnectorID: "quiet", Status: "pending"})
+
+	w := connector.NewProvisioningWorker(connector.ProvisioningWorkerConfig{Queue: q, Configs: configs, BatchSize: 10, PerConnectorBatchSize: 4})
+	if started := w.RunOnce(context.Background()); started != 2 {
+		t.Fatalf("expected a task from each connector to start, started %d", started)
+	}
+	w.Wait()
+}
@@ -8,6 +8,7 @@ import (
 // registry implements the Registry interface.
 type registry struct {
 	factories  map[string]Factory
+	schemas    map[string]ConfigSchema
 	connectors map[string]Connector
 	mu         sync.RWMutex
 }
@@ -16,14 +17,29 @@ type registry struct {
 func NewRegistry() Registry {
 	return &registry{
 		factories:  make(map[string]Factory),
+		schemas:    make(map[string]ConfigSchema),
 		connectors: make(map[string]Connector),
 	}
 }
 
-func (r *registry) Register(connectorType string, factory Factory) {
+func (r *registry) Register(connectorType string, factory Factory, schema ConfigSchema) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	r.factories[connectorType] = factory
+	r.schemas[connectorType] = schema
+}
+
+func (r *registry) ValidateConfig(config Config) error {
+	r.mu.RLock()
+	schema, ok := r.schemas[config.Type]
+	r.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("unknown connector type: %s", config.Type)
+	}
+	if problems := schema.Check(config); len(problems) > 0 {
+		return &ConfigError{Type: config.Type, Fields: problems}
+	}
+	return nil
 }
 
 func (r *registry) Create(connectorType string, config Config) (Connector, error) {
@@ -34,6 +50,9 @@ func (r *registry) Create(connectorType string, config Config) (Connector, error
 	if !ok {
 		return nil, fmt.Errorf("unknown connector type: %s", connectorType)
 	}
+	if problems := r.schemas[connectorType].Check(config); len(problems) > 0 {
+		return nil, &ConfigError{Type: connectorType, Fields: problems}
+	}
 
 	conn, err := factory(config)
 	if err != nil {
@@ -44,6 +63,25 @@ func (r *registry) Create(connectorType string, config Config) (Connector, error
 	return conn, nil
 }
 
+func (r *registry) Build(connectorType string, config Config) (Connector, error) {
+	r.mu.RLock()
+	factory, ok := r.factories[connectorType]
+	schema := r.schemas[connectorType]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown connector type: %s", connectorType)
+	}
+	if problems := schema.Check(config); len(problems) > 0 {
+		return nil, &ConfigError{Type: connectorType, Fields: problems}
+	}
+
+	conn, err := factory(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create connector: %w", err)
+	}
+	return conn, nil
+}
+
 func (r *registry) Get(connectorID string) (Connector, bool) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -0,0 +1,97 @@
+package connector
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// ConfigSchema lists what a connector type needs in its Config. The registry
+// checks it before a connector is built, so misconfigurations are reported
+// when the connector is saved rather than on first use.
+type ConfigSchema struct {
+	// Endpoint requires Config.Endpoint to be set.
+	Endpoint bool
+	// Settings and Credentials list keys that must be non-empty.
+	Settings    []string
+	Credentials []string
+	// Validate reports problems the key lists cannot express, such as
+	// malformed values or keys that depend on each other.
+	Validate func(config Config) []FieldError
+}
+
+// FieldError is one missing or invalid config field, named like
+// "credentials.client_id".
+type FieldError struct {
+	Field   string `json:"field"`
+	Problem string `json:"problem"`
+}
+
+// Missing returns a FieldError for a required field that is not set.
+func Missing(field string) FieldError {
+	return FieldError{Field: field, Problem: "required"}
+}
+
+// ConfigError is returned for a Config that does not satisfy its type's
+// schema.
+type ConfigError struct {
+	Type   string
+	Fields []FieldError
+}
+
+func (e *ConfigError) Error() string {
+	problems := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		problems[i] = f.Field + ": " + f.Problem
+	}
+	return fmt.Sprintf("invalid %s connector config: %s", e.Type, strings.Join(problems, "; "))
+}
+
+// Check returns every field of config that violates the schema.
+func (s ConfigSchema) Check(config Config) []FieldError {
+	var problems []FieldError
+	if s.Endpoint && strings.TrimSpace(config.Endpoint) == "" {
+		problems = append(problems, Missing("endpoint"))
+	}
+	for _, key := range s.Settings {
+		if strings.TrimSpace(config.Settings[key]) == "" {
+			problems = append(problems, Missing("settings."+key))
+		}
+	}
+	for _, key := range s.Credentials {
+		if config.Credentials[key] == "" {
+			problems = append(problems, Missing("credentials."+key))
+		}
+	}
+	if s.Validate != nil {
+		problems = append(problems, s.Validate(config)...)
+	}
+	return problems
+}
+
+// CheckEndpointScheme reports an endpoint that is not an absolute URL with
+// one of schemes. An empty endpoint is left to ConfigSchema.Endpoint.
+func CheckEndpointScheme(config Config, schemes ...string) []FieldError {
+	if config.Endpoint == "" {
+		return nil
+	}
+	u, err := url.Parse(config.Endpoint)
+	if err != nil || u.Host == "" || !slices.Contains(schemes, u.Scheme) {
+		return []FieldError{{Field: "endpoint", Problem: "must be a " + strings.Join(schemes, " or ") + " URL"}}
+	}
+	return nil
+}
+
+// CheckPair reports a credential set without its partner, such as a
+// username without a password.
+func CheckPair(config Config, key, partner string) []FieldError {
+	hasKey, hasPartner := config.Credentials[key] != "", config.Credentials[partner] != ""
+	switch {
+	case hasKey && !hasPartner:
+		return []FieldError{{Field: "credentials." + partner, Problem: "required with " + key}}
+	case hasPartner && !hasKey:
+		return []FieldError{{Field: "credentials." + key, Problem: "required with " + partner}}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package connector_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dhawalhost/wardseal/internal/connector"
+	"github.com/dhawalhost/wardseal/internal/connector/azuread"
+	"github.com/dhawalhost/wardseal/internal/connector/google"
+	"github.com/dhawalhost/wardseal/internal/connector/ldap"
+	"github.com/dhawalhost/wardseal/internal/connector/memory"
+	"github.com/dhawalhost/wardseal/internal/connector/scim"
+)
+
+func newSchemaRegistry() connector.Registry {
+	registry := connector.NewRegistry()
+	registry.Register("scim", scim.New, scim.Schema)
+	registry.Register("ldap", ldap.New, ldap.Schema)
+	registry.Register("azure-ad", azuread.New, azuread.Schema)
+	registry.Register("google", google.New, google.Schema)
+	registry.Register("memory", memory.New, memory.Schema)
+	return registry
+}
+
+func TestValidateConfigReportsMissingFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		config connector.Config
+		want   []string
+	}{
+		{"scim", connector.Config{Type: "scim"}, []string{"endpoint"}},
+		{"scim bad endpoint", connector.Config{Type: "scim", Endpoint: "scim.example.com/v2"}, []string{"endpoint"}},
+		{"scim username without password", connector.Config{Type: "scim", Endpoint: "https://scim.example.com/v2",
+			Credentials: map[string]string{"username": "svc"}}, []string{"credentials.password"}},
+		{"ldap", connector.Config{Type: "ldap"}, []string{"endpoint", "settings.base_dn", "credentials.bind_dn", "credentials.bind_password"}},
+		{"ldap bad scheme", connector.Config{Type: "ldap", Endpoint: "https://dc.example.com",
+			Settings:    map[string]string{"base_dn": "dc=example,dc=com"},
+			Credentials: map[string]string{"bind_dn": "cn=svc", "bind_password": "secret"}}, []string{"endpoint"}},
+		{"azure-ad", connector.Config{Type: "azure-ad", Credentials: map[string]string{"tenant_id": "t1"}},
+			[]string{"credentials.client_id", "credentials.client_secret"}},
+		{"google", connector.Config{Type: "google"}, []string{"settings.domain", "credentials.service_account_json"}},
+		{"google invalid json", connector.Config{Type: "google", Settings: map[string]string{"domain": "example.com"},
+			Credentials: map[string]string{"service_account_json": "{"}}, []string{"credentials.service_account_json"}},
+	}
+	registry := newSchemaRegistry()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := registry.ValidateConfig(tt.config)
+			var cfgErr *connector.ConfigError
+			if !errors.As(err, &cfgErr) {
+				t.Fatalf("expected a *ConfigError, got %v", err)
+			}
+			if len(cfgErr.Fields) != len(tt.want) {
+				t.Fatalf("expected fields %v, got %+v", tt.want, cfgErr.Fields)
+			}
+			for i, field := range cfgErr.Fields {
+				if field.Field != tt.want[i] {
+					t.Fatalf("expected fields %v, got %+v", tt.want, cfgErr.Fields)
+				}
+			}
+			// Building the connector fails the same way, before Initialize.
+			if _, err := registry.Build(tt.config.Type, tt.config); !errors.As(err, &cfgErr) {
+				t.Fatalf("expected Build to fail with a *ConfigError, got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateConfigAcceptsCompleteConfigs(t *testing.T) {
+	configs := []connector.Config{
+		{Type: "scim", Endpoint: "https://scim.example.com/v2", Credentials: map[string]string{"token": "t"}},
+		{Type: "ldap", Endpoint: "ldaps://dc.example.com", Settings: map[string]string{"base_dn": "dc=example,dc=com"},
+			Credentials: map[string]string{"bind_dn": "cn=svc", "bind_password": "secret"}},
+		{Type: "azure-ad", Credentials: map[string]string{"tenant_id": "t", "client_id": "c", "client_secret": "s"}},
+		{Type: "google", Settings: map[string]string{"domain": "example.com"},
+			Credentials: map[string]string{"service_account_json": `{"type": "service_account"}`}},
+		{Type: "memory"},
+	}
+	registry := newSchemaRegistry()
+	for _, config := range configs {
+		if err := registry.ValidateConfig(config); err != nil {
+			t.Errorf("%s: unexpected error: %v", config.Type, err)
+		}
+	}
+	if err := registry.ValidateConfig(connector.Config{Type: "okta"}); err == nil {
+		t.Error("expected an unknown type to be rejected")
+	}
+}
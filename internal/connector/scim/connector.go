@@ -7,44 +7,109 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"time"
+	"net/url"
+	"strings"
 
 	"github.com/dhawalhost/wardseal/internal/connector"
+	"github.com/dhawalhost/wardseal/internal/scim/filter"
 )
 
+const scimUserSchema = "urn:ietf:params:scim:schemas:core:2.0:User"
+
+// defaultAttributes maps User fields to SCIM attribute paths. Multi-valued
+// attributes use their primary value ("emails.value"), and extension
+// attributes are written as "<schema URN>:<attribute>".
+var defaultAttributes = connector.AttributeMap{
+	connector.FieldUsername:    "userName",
+	connector.FieldEmail:       "emails.value",
+	connector.FieldFirstName:   "name.givenName",
+	connector.FieldLastName:    "name.familyName",
+	connector.FieldDisplayName: "displayName",
+}
+
+// multiValued lists the SCIM core attributes that are arrays of objects.
+var multiValued = map[string]bool{
+	"emails":       true,
+	"phoneNumbers": true,
+	"addresses":    true,
+}
+
+// Schema is the SCIM connector's config schema. Authentication is optional;
+// basic auth needs both a username and a password.
+var Schema = connector.ConfigSchema{
+	Endpoint: true,
+	Validate: func(config connector.Config) []connector.FieldError {
+		return append(connector.CheckEndpointScheme(config, "https", "http"),
+			connector.CheckPair(config, "username", "password")...)
+	},
+}
+
 // Connector implements the connector.Connector interface for SCIM 2.0 targets.
 type Connector struct {
 	config     connector.Config
 	httpClient *http.Client
+	attrs      connector.AttributeMap
+	tenant     tenantScope
+	signer     connector.RequestSigner
 }
 
 // New creates a new SCIM connector.
 func New(config connector.Config) (connector.Connector, error) {
-	return &Connector{
-		config: config,
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-	}, nil
+	c := &Connector{}
+	if err := c.configure(config); err != nil {
+		return nil, err
+	}
+	return c, nil
 }
 
 func (c *Connector) ID() string   { return c.config.ID }
 func (c *Connector) Name() string { return c.config.Name }
 func (c *Connector) Type() string { return "scim" }
 
+// Capabilities reports password changes through the core password
+// attribute; everything else goes through the core operations.
+func (c *Connector) Capabilities() connector.CapabilitySet {
+	return connector.NewCapabilitySet(connector.CapabilitySetPassword)
+}
+
 func (c *Connector) Initialize(ctx context.Context, config connector.Config) error {
+	return c.configure(config)
+}
+
+// configure validates config and applies it.
+func (c *Connector) configure(config connector.Config) error {
+	attrs, err := connector.ParseAttributeMap(config, defaultAttributes)
+	if err != nil {
+		return err
+	}
+	tenant, err := parseTenantScope(config)
+	if err != nil {
+		return err
+	}
+	signer, err := connector.ParseRequestSigner(config)
+	if err != nil {
+		return err
+	}
+	httpClient, err := connector.NewHTTPClient(config)
+	if err != nil {
+		return err
+	}
 	c.config = config
+	c.httpClient = httpClient
+	c.attrs = attrs
+	c.tenant = tenant
+	c.signer = signer
 	return nil
 }
 
 func (c *Connector) HealthCheck(ctx context.Context) error {
 	// Try to access ServiceProviderConfig
-	req, err := http.NewRequestWithContext(ctx, "GET", c.config.Endpoint+"/ServiceProviderConfig", nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", c.tenant.baseURL+"/ServiceProviderConfig", nil)
 	if err != nil {
 		return err
 	}
 	c.setHeaders(req)
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return err
 	}
@@ -59,16 +124,16 @@ func (c *Connector) Close() error { return nil }
 
 // User operations
 func (c *Connector) CreateUser(ctx context.Context, user connector.User) (string, error) {
-	scimUser := toSCIMUser(user)
+	scimUser := toSCIMUser(user, c.attrs)
 	body, _ := json.Marshal(scimUser)
 
-	req, err := http.NewRequestWithContext(ctx, "POST", c.config.Endpoint+"/Users", bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", c.tenant.baseURL+"/Users", bytes.NewReader(body))
 	if err != nil {
 		return "", err
 	}
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return "", err
 	}
@@ -79,7 +144,9 @@ func (c *Connector) CreateUser(ctx context.Context, user connector.User) (string
 		return "", fmt.Errorf("create user failed: %s", string(respBody))
 	}
 
-	var result scimUserResource
+	var result struct {
+		ID string `json:"id"`
+	}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return "", err
 	}
@@ -87,13 +154,13 @@ func (c *Connector) CreateUser(ctx context.Context, user connector.User) (string
 }
 
 func (c *Connector) GetUser(ctx context.Context, id string) (connector.User, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", c.config.Endpoint+"/Users/"+id, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", c.tenant.baseURL+"/Users/"+id, nil)
 	if err != nil {
 		return connector.User{}, err
 	}
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return connector.User{}, err
 	}
@@ -103,24 +170,24 @@ func (c *Connector) GetUser(ctx context.Context, id string) (connector.User, err
 		return connector.User{}, fmt.Errorf("get user failed: %d", resp.StatusCode)
 	}
 
-	var result scimUserResource
+	var result map[string]interface{}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return connector.User{}, err
 	}
-	return fromSCIMUser(result), nil
+	return fromSCIMUser(result, c.attrs), nil
 }
 
 func (c *Connector) UpdateUser(ctx context.Context, id string, user connector.User) error {
-	scimUser := toSCIMUser(user)
+	scimUser := toSCIMUser(user, c.attrs)
 	body, _ := json.Marshal(scimUser)
 
-	req, err := http.NewRequestWithContext(ctx, "PUT", c.config.Endpoint+"/Users/"+id, bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, "PUT", c.tenant.baseURL+"/Users/"+id, bytes.NewReader(body))
 	if err != nil {
 		return err
 	}
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return err
 	}
@@ -134,13 +201,13 @@ func (c *Connector) UpdateUser(ctx context.Context, id string, user connector.Us
 }
 
 func (c *Connector) DeleteUser(ctx context.Context, id string) error {
-	req, err := http.NewRequestWithContext(ctx, "DELETE", c.config.Endpoint+"/Users/"+id, nil)
+	req, err := http.NewRequestWithContext(ctx, "DELETE", c.tenant.baseURL+"/Users/"+id, nil)
 	if err != nil {
 		return err
 	}
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return err
 	}
@@ -153,9 +220,9 @@ func (c *Connector) DeleteUser(ctx context.Context, id string) error {
 }
 
 func (c *Connector) ListUsers(ctx context.Context, filter string, limit, offset int) ([]connector.User, int, error) {
-	url := fmt.Sprintf("%s/Users?startIndex=%d&count=%d", c.config.Endpoint, offset+1, limit)
-	if filter != "" {
-		url += "&filter=" + filter
+	url, err := listURL(c.tenant.baseURL+"/Users", filter, limit, offset)
+	if err != nil {
+		return nil, 0, err
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
@@ -164,7 +231,7 @@ func (c *Connector) ListUsers(ctx context.Context, filter string, limit, offset
 	}
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -177,7 +244,7 @@ func (c *Connector) ListUsers(ctx context.Context, filter string, limit, offset
 
 	users := make([]connector.User, len(result.Resources))
 	for i, r := range result.Resources {
-		users[i] = fromSCIMUser(r)
+		users[i] = fromSCIMUser(r, c.attrs)
 	}
 	return users, result.TotalResults, nil
 }
@@ -187,13 +254,13 @@ func (c *Connector) CreateGroup(ctx context.Context, group connector.Group) (str
 	scimGroup := scimGroupResource{DisplayName: group.Name}
 	body, _ := json.Marshal(scimGroup)
 
-	req, err := http.NewRequestWithContext(ctx, "POST", c.config.Endpoint+"/Groups", bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", c.tenant.baseURL+"/Groups", bytes.NewReader(body))
 	if err != nil {
 		return "", err
 	}
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return "", err
 	}
@@ -209,13 +276,13 @@ func (c *Connector) CreateGroup(ctx context.Context, group connector.Group) (str
 }
 
 func (c *Connector) GetGroup(ctx context.Context, id string) (connector.Group, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", c.config.Endpoint+"/Groups/"+id, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", c.tenant.baseURL+"/Groups/"+id, nil)
 	if err != nil {
 		return connector.Group{}, err
 	}
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return connector.Group{}, err
 	}
@@ -230,13 +297,13 @@ func (c *Connector) UpdateGroup(ctx context.Context, id string, group connector.
 	scimGroup := scimGroupResource{ID: id, DisplayName: group.Name}
 	body, _ := json.Marshal(scimGroup)
 
-	req, err := http.NewRequestWithContext(ctx, "PUT", c.config.Endpoint+"/Groups/"+id, bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, "PUT", c.tenant.baseURL+"/Groups/"+id, bytes.NewReader(body))
 	if err != nil {
 		return err
 	}
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return err
 	}
@@ -245,13 +312,13 @@ func (c *Connector) UpdateGroup(ctx context.Context, id string, group connector.
 }
 
 func (c *Connector) DeleteGroup(ctx context.Context, id string) error {
-	req, err := http.NewRequestWithContext(ctx, "DELETE", c.config.Endpoint+"/Groups/"+id, nil)
+	req, err := http.NewRequestWithContext(ctx, "DELETE", c.tenant.baseURL+"/Groups/"+id, nil)
 	if err != nil {
 		return err
 	}
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return err
 	}
@@ -260,14 +327,17 @@ func (c *Connector) DeleteGroup(ctx context.Context, id string) error {
 }
 
 func (c *Connector) ListGroups(ctx context.Context, filter string, limit, offset int) ([]connector.Group, int, error) {
-	url := fmt.Sprintf("%s/Groups?startIndex=%d&count=%d", c.config.Endpoint, offset+1, limit)
+	url, err := listURL(c.tenant.baseURL+"/Groups", filter, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, 0, err
 	}
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -301,13 +371,13 @@ func (c *Connector) AddUserToGroup(ctx context.Context, userID, groupID string)
 	}
 	body, _ := json.Marshal(patch)
 
-	req, err := http.NewRequestWithContext(ctx, "PATCH", c.config.Endpoint+"/Groups/"+groupID, bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, "PATCH", c.tenant.baseURL+"/Groups/"+groupID, bytes.NewReader(body))
 	if err != nil {
 		return err
 	}
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return err
 	}
@@ -327,13 +397,13 @@ func (c *Connector) RemoveUserFromGroup(ctx context.Context, userID, groupID str
 	}
 	body, _ := json.Marshal(patch)
 
-	req, err := http.NewRequestWithContext(ctx, "PATCH", c.config.Endpoint+"/Groups/"+groupID, bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, "PATCH", c.tenant.baseURL+"/Groups/"+groupID, bytes.NewReader(body))
 	if err != nil {
 		return err
 	}
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return err
 	}
@@ -341,6 +411,43 @@ func (c *Connector) RemoveUserFromGroup(ctx context.Context, userID, groupID str
 	return nil
 }
 
+// SetPassword replaces the user's password attribute (RFC 7643 section
+// 4.1.1). SCIM has no standard way to force a change at next sign-in, so
+// forceChange fails with connector.ErrUnsupportedOperation.
+func (c *Connector) SetPassword(ctx context.Context, userID, password string, forceChange bool) error {
+	if forceChange {
+		return fmt.Errorf("%w: SCIM cannot force a password change", connector.ErrUnsupportedOperation)
+	}
+	patch := map[string]interface{}{
+		"schemas": []string{"urn:ietf:params:scim:api:messages:2.0:PatchOp"},
+		"Operations": []map[string]interface{}{
+			{
+				"op":    "replace",
+				"path":  "password",
+				"value": password,
+			},
+		},
+	}
+	body, _ := json.Marshal(patch)
+
+	req, err := http.NewRequestWithContext(ctx, "PATCH", c.tenant.baseURL+"/Users/"+url.PathEscape(userID), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	c.setHeaders(req)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode >= 400 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("set password failed: %d %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
 func (c *Connector) GetGroupMembers(ctx context.Context, groupID string) ([]connector.User, error) {
 	group, err := c.GetGroup(ctx, groupID)
 	if err != nil {
@@ -354,6 +461,7 @@ func (c *Connector) GetGroupMembers(ctx context.Context, groupID string) ([]conn
 func (c *Connector) setHeaders(req *http.Request) {
 	req.Header.Set("Content-Type", "application/scim+json")
 	req.Header.Set("Accept", "application/scim+json")
+	c.tenant.apply(req)
 
 	// Bearer token auth
 	if token, ok := c.config.Credentials["token"]; ok {
@@ -367,63 +475,95 @@ func (c *Connector) setHeaders(req *http.Request) {
 	}
 }
 
-// SCIM types
-type scimUserResource struct {
-	ID       string `json:"id,omitempty"`
-	UserName string `json:"userName"`
-	Active   bool   `json:"active"`
-	Name     struct {
-		GivenName  string `json:"givenName,omitempty"`
-		FamilyName string `json:"familyName,omitempty"`
-	} `json:"name,omitempty"`
-	Emails []struct {
-		Value   string `json:"value"`
-		Primary bool   `json:"primary"`
-	} `json:"emails,omitempty"`
-	DisplayName string `json:"displayName,omitempty"`
+// do signs req, when signing is configured, and sends it.
+func (c *Connector) do(req *http.Request) (*http.Response, error) {
+	if c.signer != nil {
+		if err := c.signer.Sign(req); err != nil {
+			return nil, fmt.Errorf("sign request: %w", err)
+		}
+	}
+	return c.httpClient.Do(req)
 }
 
+// SCIM types
 type scimGroupResource struct {
 	ID          string `json:"id,omitempty"`
 	DisplayName string `json:"displayName"`
 }
 
 type scimListResponse struct {
-	TotalResults int                `json:"totalResults"`
-	Resources    []scimUserResource `json:"Resources"`
+	TotalResults int                      `json:"totalResults"`
+	Resources    []map[string]interface{} `json:"Resources"`
 }
 
-func toSCIMUser(u connector.User) scimUserResource {
-	return scimUserResource{
-		UserName:    u.Username,
-		Active:      u.Active,
-		DisplayName: u.DisplayName,
-		Name: struct {
-			GivenName  string `json:"givenName,omitempty"`
-			FamilyName string `json:"familyName,omitempty"`
-		}{
-			GivenName:  u.FirstName,
-			FamilyName: u.LastName,
-		},
-		Emails: []struct {
-			Value   string `json:"value"`
-			Primary bool   `json:"primary"`
-		}{{Value: u.Email, Primary: true}},
+func toSCIMUser(u connector.User, attrs connector.AttributeMap) map[string]interface{} {
+	resource := map[string]interface{}{
+		"schemas": []string{scimUserSchema},
+		"active":  u.Active,
+	}
+	for attr, value := range attrs.Values(u) {
+		setSCIMAttribute(resource, attr, value)
+	}
+	return resource
+}
+
+// setSCIMAttribute sets a path in the form used by defaultAttributes.
+func setSCIMAttribute(resource map[string]interface{}, path, value string) {
+	if strings.HasPrefix(path, "urn:") {
+		i := strings.LastIndex(path, ":")
+		schema, attr := path[:i], path[i+1:]
+		extension, ok := resource[schema].(map[string]interface{})
+		if !ok {
+			extension = make(map[string]interface{})
+			resource[schema] = extension
+			resource["schemas"] = append(resource["schemas"].([]string), schema)
+		}
+		connector.SetJSONPath(extension, attr, value)
+		return
+	}
+	head, rest, nested := strings.Cut(path, ".")
+	if nested && multiValued[head] {
+		resource[head] = []interface{}{map[string]interface{}{rest: value, "primary": true}}
+		return
+	}
+	connector.SetJSONPath(resource, path, value)
+}
+
+func fromSCIMUser(resource map[string]interface{}, attrs connector.AttributeMap) connector.User {
+	core := make(map[string]interface{}, len(resource))
+	flat := make(map[string]string)
+	for key, value := range resource {
+		extension, ok := value.(map[string]interface{})
+		if !ok || !strings.HasPrefix(key, "urn:") {
+			core[key] = value
+			continue
+		}
+		for attr, v := range connector.FlattenJSON(extension) {
+			flat[key+":"+attr] = v
+		}
+	}
+	for attr, v := range connector.FlattenJSON(core) {
+		flat[attr] = v
+	}
+
+	user := connector.User{
+		ExternalID: flat["id"],
+		Active:     resource["active"] == true,
 	}
+	attrs.ApplyTo(&user, func(attr string) string { return flat[attr] })
+	return user
 }
 
-func fromSCIMUser(r scimUserResource) connector.User {
-	email := ""
-	if len(r.Emails) > 0 {
-		email = r.Emails[0].Value
+// listURL builds a paged list request for endpoint. The filter is checked
+// before it is sent so a malformed one fails here rather than as an opaque
+// 400 from the target.
+func listURL(endpoint, expr string, limit, offset int) (string, error) {
+	u := fmt.Sprintf("%s?startIndex=%d&count=%d", endpoint, offset+1, limit)
+	if expr == "" {
+		return u, nil
 	}
-	return connector.User{
-		ExternalID:  r.ID,
-		Username:    r.UserName,
-		Email:       email,
-		FirstName:   r.Name.GivenName,
-		LastName:    r.Name.FamilyName,
-		DisplayName: r.DisplayName,
-		Active:      r.Active,
+	if _, err := filter.Parse(expr); err != nil {
+		return "", fmt.Errorf("scim filter: %w", err)
 	}
+	return u + "&filter=" + url.QueryEscape(expr), nil
 }
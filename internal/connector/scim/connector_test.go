@@ -0,0 +1,286 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dhawalhost/wardseal/internal/connector"
+)
+
+const enterpriseSchema = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+
+func newTestConnector(t *testing.T, endpoint, attributeMap string) *Connector {
+	t.Helper()
+	config := connector.Config{
+		ID:       "conn-1",
+		Type:     "scim",
+		Endpoint: endpoint,
+		Settings: map[string]string{connector.SettingAttributeMap: attributeMap},
+	}
+	conn, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err := conn.Initialize(context.Background(), config); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	return conn.(*Connector)
+}
+
+func TestCustomAttributeMapIsAppliedBothWays(t *testing.T) {
+	var created map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/Users/u1":
+			_, _ = w.Write([]byte(`{
+				"id": "u1",
+				"userName": "ada@corp.example",
+				"active": true,
+				"nickName": "Countess",
+				"name": {"givenName": "Ada", "familyName": "Lovelace"},
+				"emails": [{"value": "ada@home.example"}, {"value": "ada@corp.example", "primary": true}],
+				"` + enterpriseSchema + `": {"employeeNumber": "E-1815"}
+			}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/Users":
+			_ = json.NewDecoder(r.Body).Decode(&created)
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id": "u2"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	conn := newTestConnector(t, server.URL,
+		`{"username": "`+enterpriseSchema+`:employeeNumber", "display_name": "nickName"}`)
+
+	user, err := conn.GetUser(context.Background(), "u1")
+	if err != nil {
+		t.Fatalf("GetUser failed: %v", err)
+	}
+	want := connector.User{
+		ExternalID:  "u1",
+		Username:    "E-1815",
+		Email:       "ada@corp.example",
+		FirstName:   "Ada",
+		LastName:    "Lovelace",
+		DisplayName: "Countess",
+		Active:      true,
+	}
+	if user.ExternalID != want.ExternalID || user.Username != want.Username || user.Email != want.Email ||
+		user.FirstName != want.FirstName || user.LastName != want.LastName ||
+		user.DisplayName != want.DisplayName || user.Active != want.Active {
+		t.Fatalf("expected %+v, got %+v", want, user)
+	}
+
+	if _, err := conn.CreateUser(context.Background(), connector.User{
+		Username: "E-1906", Email: "grace@corp.example", DisplayName: "Amazing Grace", Active: true,
+	}); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	extension, _ := created[enterpriseSchema].(map[string]interface{})
+	if extension["employeeNumber"] != "E-1906" {
+		t.Fatalf("expected the username in the enterprise extension, got %v", created)
+	}
+	if _, ok := created["userName"]; ok {
+		t.Fatalf("expected userName to be unmapped, got %v", created["userName"])
+	}
+	if created["nickName"] != "Amazing Grace" {
+		t.Fatalf("expected the display name in nickName, got %v", created)
+	}
+	emails, _ := created["emails"].([]interface{})
+	if len(emails) != 1 || emails[0].(map[string]interface{})["value"] != "grace@corp.example" {
+		t.Fatalf("expected the default email mapping to be kept, got %v", created["emails"])
+	}
+	schemas, _ := created["schemas"].([]interface{})
+	if len(schemas) != 2 || schemas[1] != enterpriseSchema {
+		t.Fatalf("expected the enterprise schema to be declared, got %v", schemas)
+	}
+}
+
+func TestProfileFieldsAreSyncedOnceMapped(t *testing.T) {
+	var created map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/Users/u1":
+			_, _ = w.Write([]byte(`{
+				"id": "u1",
+				"userName": "ada@corp.example",
+				"active": true,
+				"title": "Staff Engineer",
+				"phoneNumbers": [{"value": "+1 555 0100"}],
+				"addresses": [{"locality": "London", "primary": true}],
+				"` + enterpriseSchema + `": {"department": "Engineering"}
+			}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/Users":
+			_ = json.NewDecoder(r.Body).Decode(&created)
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id": "u2"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	conn := newTestConnector(t, server.URL, `{
+		"title": "title",
+		"phone": "phoneNumbers.value",
+		"location": "addresses.locality",
+		"department": "`+enterpriseSchema+`:department"
+	}`)
+
+	user, err := conn.GetUser(context.Background(), "u1")
+	if err != nil {
+		t.Fatalf("GetUser failed: %v", err)
+	}
+	if user.Title != "Staff Engineer" || user.Phone != "+1 555 0100" || user.Location != "London" || user.Department != "Engineering" {
+		t.Fatalf("expected the profile fields to be read, got %+v", user)
+	}
+
+	if _, err := conn.CreateUser(context.Background(), connector.User{
+		Username: "grace@corp.example", Email: "grace@corp.example", Title: "Rear Admiral", Department: "Navy", Active: true,
+	}); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	extension, _ := created[enterpriseSchema].(map[string]interface{})
+	if created["title"] != "Rear Admiral" || extension["department"] != "Navy" {
+		t.Fatalf("expected the profile fields to be written, got %v", created)
+	}
+	if _, ok := created["phoneNumbers"]; ok {
+		t.Fatalf("expected an empty phone to be left out, got %v", created["phoneNumbers"])
+	}
+}
+
+func TestInitializeRejectsInvalidAttributeMap(t *testing.T) {
+	for _, attributeMap := range []string{
+		`not json`,
+		`{"employee_number": "employeeNumber"}`,
+		`{"username": " "}`,
+	} {
+		conn := &Connector{}
+		config := connector.Config{Settings: map[string]string{connector.SettingAttributeMap: attributeMap}}
+		if err := conn.Initialize(context.Background(), config); err == nil {
+			t.Errorf("expected %s to be rejected", attributeMap)
+		}
+	}
+}
+
+func TestTenantScopeIsAppliedToEveryRequest(t *testing.T) {
+	const tenantID = "11111111-1111-1111-1111-111111111111"
+	var seen []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("X-Tenant-Id"); got != tenantID {
+			t.Errorf("%s %s: expected tenant header %q, got %q", r.Method, r.URL.Path, tenantID, got)
+		}
+		seen = append(seen, r.Method+" "+r.URL.Path)
+		switch r.Method {
+		case http.MethodPost:
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id": "u1"}`))
+		default:
+			_, _ = w.Write([]byte(`{"totalResults": 0, "Resources": []}`))
+		}
+	}))
+	defer server.Close()
+
+	config := connector.Config{
+		ID:       "conn-1",
+		TenantID: tenantID,
+		Type:     "scim",
+		Endpoint: server.URL + "/scim/v2/",
+		Settings: map[string]string{
+			SettingTenantPathTemplate: "/tenants/{tenant_id}",
+			SettingTenantHeader:       "x-tenant-id",
+		},
+	}
+	conn := &Connector{httpClient: server.Client()}
+	if err := conn.Initialize(context.Background(), config); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	if _, err := conn.CreateUser(context.Background(), connector.User{Username: "ada", Active: true}); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	if _, _, err := conn.ListUsers(context.Background(), `userName eq "ada"`, 10, 0); err != nil {
+		t.Fatalf("ListUsers failed: %v", err)
+	}
+	if _, _, err := conn.ListGroups(context.Background(), "", 10, 0); err != nil {
+		t.Fatalf("ListGroups failed: %v", err)
+	}
+
+	prefix := "/scim/v2/tenants/" + tenantID
+	want := []string{"POST " + prefix + "/Users", "GET " + prefix + "/Users", "GET " + prefix + "/Groups"}
+	if len(seen) != len(want) {
+		t.Fatalf("expected requests %v, got %v", want, seen)
+	}
+	for i := range want {
+		if seen[i] != want[i] {
+			t.Fatalf("expected requests %v, got %v", want, seen)
+		}
+	}
+}
+
+func TestInitializeRejectsInvalidTenantScope(t *testing.T) {
+	tests := []struct {
+		name     string
+		tenantID string
+		settings map[string]string
+	}{
+		{"template without placeholder", "t1", map[string]string{SettingTenantPathTemplate: "/tenants"}},
+		{"relative template", "t1", map[string]string{SettingTenantPathTemplate: "tenants/{tenant_id}"}},
+		{"template without tenant", "", map[string]string{SettingTenantPathTemplate: "/tenants/{tenant_id}"}},
+		{"header without value or tenant", "", map[string]string{SettingTenantHeader: "X-Tenant"}},
+		{"value without header", "t1", map[string]string{SettingTenantHeaderValue: "acme"}},
+		{"invalid header name", "t1", map[string]string{SettingTenantHeader: "X Tenant"}},
+		{"reserved header", "t1", map[string]string{SettingTenantHeader: "authorization"}},
+		{"header injection", "t1", map[string]string{SettingTenantHeader: "X-Tenant", SettingTenantHeaderValue: "acme\r\nX-Admin: 1"}},
+	}
+	for _, tt := range tests {
+		conn := &Connector{}
+		config := connector.Config{TenantID: tt.tenantID, Endpoint: "https://scim.example", Settings: tt.settings}
+		if err := conn.Initialize(context.Background(), config); err == nil {
+			t.Errorf("%s: expected Initialize to fail", tt.name)
+		}
+	}
+}
+
+func TestSetPasswordPatchesPasswordAttribute(t *testing.T) {
+	var path string
+	var patch struct {
+		Schemas    []string `json:"schemas"`
+		Operations []struct {
+			Op    string `json:"op"`
+			Path  string `json:"path"`
+			Value string `json:"value"`
+		} `json:"Operations"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.Method + " " + r.URL.Path
+		_ = json.NewDecoder(r.Body).Decode(&patch)
+		_, _ = w.Write([]byte(`{"id": "u1"}`))
+	}))
+	defer server.Close()
+	conn := newTestConnector(t, server.URL, "")
+
+	if err := conn.SetPassword(context.Background(), "u1", "s3cret!", false); err != nil {
+		t.Fatalf("SetPassword failed: %v", err)
+	}
+	if path != "PATCH /Users/u1" {
+		t.Fatalf("unexpected request %q", path)
+	}
+	if len(patch.Schemas) != 1 || patch.Schemas[0] != "urn:ietf:params:scim:api:messages:2.0:PatchOp" {
+		t.Fatalf("unexpected schemas %v", patch.Schemas)
+	}
+	if len(patch.Operations) != 1 || patch.Operations[0].Op != "replace" ||
+		patch.Operations[0].Path != "password" || patch.Operations[0].Value != "s3cret!" {
+		t.Fatalf("unexpected operations %+v", patch.Operations)
+	}
+
+	if err := conn.SetPassword(context.Background(), "u1", "s3cret!", true); !errors.Is(err, connector.ErrUnsupportedOperation) {
+		t.Fatalf("expected ErrUnsupportedOperation for a forced change, got %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package scim
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dhawalhost/wardseal/internal/connector"
+)
+
+// Settings that scope every outbound request to the connector's tenant on
+// multi-tenant SCIM targets.
+const (
+	// SettingTenantPathTemplate is appended to the endpoint, with
+	// {tenant_id} replaced by the tenant, e.g. "/tenants/{tenant_id}".
+	SettingTenantPathTemplate = "tenant_path_template"
+	// SettingTenantHeader names a header sent on every request.
+	SettingTenantHeader = "tenant_header"
+	// SettingTenantHeaderValue is that header's value; it defaults to the
+	// tenant ID.
+	SettingTenantHeaderValue = "tenant_header_value"
+)
+
+const tenantPlaceholder = "{tenant_id}"
+
+// reservedHeaders are set by the connector itself and cannot carry the tenant.
+var reservedHeaders = map[string]bool{
+	"Authorization": true,
+	"Content-Type":  true,
+	"Accept":        true,
+	"Host":          true,
+}
+
+// tenantScope is the tenant discriminator applied to outbound requests.
+type tenantScope struct {
+	baseURL     string
+	header      string
+	headerValue string
+}
+
+// parseTenantScope validates the tenant settings and resolves the base URL
+// requests are sent to.
+func parseTenantScope(config connector.Config) (tenantScope, error) {
+	scope := tenantScope{baseURL: strings.TrimSuffix(config.Endpoint, "/")}
+
+	if tmpl := config.Settings[SettingTenantPathTemplate]; tmpl != "" {
+		if !strings.HasPrefix(tmpl, "/") || strings.Count(tmpl, tenantPlaceholder) != 1 {
+			return tenantScope{}, fmt.Errorf("invalid %s: must start with / and contain %s once", SettingTenantPathTemplate, tenantPlaceholder)
+		}
+		if config.TenantID == "" {
+			return tenantScope{}, fmt.Errorf("%s needs the connector's tenant", SettingTenantPathTemplate)
+		}
+		path := strings.Replace(tmpl, tenantPlaceholder, url.PathEscape(config.TenantID), 1)
+		scope.baseURL += strings.TrimSuffix(path, "/")
+	}
+
+	header := config.Settings[SettingTenantHeader]
+	value := config.Settings[SettingTenantHeaderValue]
+	if header == "" {
+		if value != "" {
+			return tenantScope{}, fmt.Errorf("%s needs %s", SettingTenantHeaderValue, SettingTenantHeader)
+		}
+		return scope, nil
+	}
+	if !validHeaderName(header) {
+		return tenantScope{}, fmt.Errorf("invalid %s %q", SettingTenantHeader, header)
+	}
+	header = http.CanonicalHeaderKey(header)
+	if reservedHeaders[header] {
+		return tenantScope{}, fmt.Errorf("%s cannot be %s", SettingTenantHeader, header)
+	}
+	if value == "" {
+		value = config.TenantID
+	}
+	if value == "" {
+		return tenantScope{}, fmt.Errorf("%s needs %s or the connector's tenant", SettingTenantHeader, SettingTenantHeaderValue)
+	}
+	if strings.ContainsAny(value, "\r\n") {
+		return tenantScope{}, fmt.Errorf("invalid %s", SettingTenantHeaderValue)
+	}
+	scope.header, scope.headerValue = header, value
+	return scope, nil
+}
+
+// apply sets the tenant header on req, when one is configured.
+func (s tenantScope) apply(req *http.Request) {
+	if s.header != "" {
+		req.Header.Set(s.header, s.headerValue)
+	}
+}
+
+// validHeaderName reports whether name is an RFC 7230 token.
+func validHeaderName(name string) bool {
+	for _, r := range name {
+		if r > 0x7e || r <= ' ' || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r) {
+			return false
+		}
+	}
+	return name != ""
+}
@@ -3,6 +3,7 @@ package connector
 import (
 	"context"
 	"fmt"
+	"time"
 )
 
 // Service defines connector service operations.
@@ -13,9 +14,12 @@ type Service interface {
 	UpdateConnector(ctx context.Context, tenantID string, config Config) error
 	DeleteConnector(ctx context.Context, tenantID, id string) error
 	ToggleConnector(ctx context.Context, tenantID, id string, enabled bool) error
-	TestConnection(ctx context.Context, config Config) error
+	TestConnection(ctx context.Context, config Config) ConnectionTestResult
 }
 
+// ConnectionTestTimeout bounds how long TestConnection waits on the provider.
+const ConnectionTestTimeout = 10 * time.Second
+
 type service struct {
 	store    Store
 	registry Registry
@@ -33,6 +37,12 @@ func (s *service) CreateConnector(ctx context.Context, tenantID string, config C
 	if config.Type == "" {
 		return "", fmt.Errorf("connector type is required")
 	}
+	if err := validateSyncSettings(config); err != nil {
+		return "", err
+	}
+	if err := s.registry.ValidateConfig(config); err != nil {
+		return "", err
+	}
 
 	config.TenantID = tenantID
 	config.Enabled = true
@@ -66,6 +76,9 @@ func (s *service) UpdateConnector(ctx context.Context, tenantID string, config C
 	if err != nil {
 		return fmt.Errorf("connector not found: %w", err)
 	}
+	if err := validateSyncSettings(config); err != nil {
+		return err
+	}
 
 	// Preserve credentials if not updated
 	if len(config.Credentials) == 0 {
@@ -79,6 +92,11 @@ func (s *service) UpdateConnector(ctx context.Context, tenantID string, config C
 		}
 	}
 
+	// Checked after the merge, since omitted credentials keep their values.
+	if err := s.registry.ValidateConfig(config); err != nil {
+		return err
+	}
+
 	config.TenantID = tenantID
 	if err := s.store.Update(ctx, config); err != nil {
 		return err
@@ -117,14 +135,37 @@ func (s *service) ToggleConnector(ctx context.Context, tenantID, id string, enab
 	return nil
 }
 
-func (s *service) TestConnection(ctx context.Context, config Config) error {
-	// Create a temporary connector instance
-	conn, err := s.registry.Create(config.Type, config)
+// TestConnection builds a transient connector from config, initializes it and
+// runs its health check. Nothing is persisted or registered.
+func (s *service) TestConnection(ctx context.Context, config Config) ConnectionTestResult {
+	conn, err := s.registry.Build(config.Type, config)
 	if err != nil {
-		return fmt.Errorf("failed to create connector: %w", err)
+		return ConnectionTestResult{Stage: "create", Error: err.Error()}
 	}
-	// Don't keep it in the registry
-	defer func() { _ = s.registry.Remove(config.ID) }()
 
-	return conn.HealthCheck(ctx)
+	ctx, cancel := context.WithTimeout(ctx, ConnectionTestTimeout)
+	defer cancel()
+
+	// Run the provider calls in the background so a connector that ignores
+	// its context still cannot hold the request past the timeout.
+	done := make(chan ConnectionTestResult, 1)
+	go func() {
+		defer func() { _ = conn.Close() }()
+		if err := conn.Initialize(ctx, config); err != nil {
+			done <- ConnectionTestResult{Stage: "initialize", Error: err.Error()}
+			return
+		}
+		if err := conn.HealthCheck(ctx); err != nil {
+			done <- ConnectionTestResult{Stage: "health_check", Error: err.Error()}
+			return
+		}
+		done <- ConnectionTestResult{OK: true}
+	}()
+
+	select {
+	case result := <-done:
+		return result
+	case <-ctx.Done():
+		return ConnectionTestResult{Error: fmt.Sprintf("connection test timed out after %s", ConnectionTestTimeout)}
+	}
 }
@@ -0,0 +1,73 @@
+package connector_test
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dhawalhost/wardseal/internal/connector"
+)
+
+func TestHMACSignerSignsKnownRequest(t *testing.T) {
+	const body = `{"userName":"alice"}`
+	req, err := http.NewRequest(http.MethodPost, "https://scim.example.com/v2/Users?b=2&a=1", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := &connector.HMACSigner{
+		KeyID:  "k1",
+		Secret: []byte("test-secret"),
+		Now:    func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC) },
+	}
+	if err := signer.Sign(req); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+
+	if got := req.Header.Get(connector.SignatureDateHeader); got != "20260102T030405Z" {
+		t.Fatalf("expected date header 20260102T030405Z, got %q", got)
+	}
+	if got := req.Header.Get(connector.ContentHashHeader); got != "b49bbe2efac2885cdd3c287192d7186762471187f87b58faa42d0c0c21e2569f" {
+		t.Fatalf("unexpected body hash %q", got)
+	}
+	want := "HMAC-SHA256 KeyId=k1, SignedHeaders=x-content-sha256;x-signature-date, " +
+		"Signature=5a5de3010605c8e614779c11c171d015da3fbb5e23a77d842d9c406a1cbf61e0"
+	if got := req.Header.Get(connector.SignatureHeader); got != want {
+		t.Fatalf("expected signature header\n%s\ngot\n%s", want, got)
+	}
+
+	sent, err := io.ReadAll(req.Body)
+	if err != nil || string(sent) != body {
+		t.Fatalf("expected the body to survive signing, got %q, %v", sent, err)
+	}
+}
+
+func TestParseRequestSigner(t *testing.T) {
+	signer, err := connector.ParseRequestSigner(connector.Config{})
+	if err != nil || signer != nil {
+		t.Fatalf("expected no signer without settings, got %v, %v", signer, err)
+	}
+
+	config := connector.Config{
+		Settings:    map[string]string{connector.SettingRequestSigning: connector.SigningHMACSHA256, connector.SettingSigningKeyID: "k1"},
+		Credentials: map[string]string{connector.CredentialSigningSecret: "test-secret"},
+	}
+	if signer, err := connector.ParseRequestSigner(config); err != nil || signer == nil {
+		t.Fatalf("expected an HMAC signer, got %v, %v", signer, err)
+	}
+
+	invalid := []connector.Config{
+		{Settings: map[string]string{connector.SettingRequestSigning: "rsa"}},
+		{Settings: map[string]string{connector.SettingRequestSigning: connector.SigningHMACSHA256, connector.SettingSigningKeyID: "k1"}},
+		{
+			Settings:    map[string]string{connector.SettingRequestSigning: connector.SigningHMACSHA256, connector.SettingSigningKeyID: "k1, Signature=x"},
+			Credentials: map[string]string{connector.CredentialSigningSecret: "test-secret"},
+		},
+	}
+	for i, config := range invalid {
+		if _, err := connector.ParseRequestSigner(config); err == nil {
+			t.Errorf("config %d: expected an error", i)
+		}
+	}
+}
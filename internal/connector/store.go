@@ -15,6 +15,9 @@ type Store interface {
 	Update(ctx context.Context, config Config) error
 	Delete(ctx context.Context, tenantID, id string) error
 	Toggle(ctx context.Context, tenantID, id string, enabled bool) error
+	// ListSyncSources returns enabled connectors in every tenant that have
+	// inbound sync turned on.
+	ListSyncSources(ctx context.Context) ([]Config, error)
 }
 
 type store struct {
@@ -82,6 +85,29 @@ func (s *store) List(ctx context.Context, tenantID string) ([]Config, error) {
 	return configs, nil
 }
 
+func (s *store) ListSyncSources(ctx context.Context) ([]Config, error) {
+	var rows []struct {
+		Config
+		CredentialsRaw []byte `db:"credentials"`
+		SettingsRaw    []byte `db:"settings"`
+	}
+	err := s.db.SelectContext(ctx, &rows,
+		`SELECT * FROM connectors WHERE enabled AND settings->>$1 = 'true' ORDER BY tenant_id, name`, SettingSyncEnabled)
+	if err != nil {
+		return nil, err
+	}
+
+	configs := make([]Config, len(rows))
+	for i, r := range rows {
+		r.Credentials = make(map[string]string)
+		r.Settings = make(map[string]string)
+		_ = json.Unmarshal(r.CredentialsRaw, &r.Credentials)
+		_ = json.Unmarshal(r.SettingsRaw, &r.Settings)
+		configs[i] = r.Config
+	}
+	return configs, nil
+}
+
 func (s *store) Update(ctx context.Context, config Config) error {
 	credentials, _ := json.Marshal(config.Credentials)
 	settings, _ := json.Marshal(config.Settings)
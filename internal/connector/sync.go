@@ -0,0 +1,440 @@
+package connector
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dhawalhost/wardseal/internal/directory"
+	"go.uber.org/zap"
+)
+
+const (
+	// SettingSyncEnabled marks a connector as a source for inbound sync when
+	// set to "true" in its settings.
+	SettingSyncEnabled = "sync_enabled"
+	// DefaultSyncInterval is how often the scheduler syncs when no interval is configured.
+	DefaultSyncInterval = 15 * time.Minute
+	// defaultSyncPageSize is how many users or groups are read per connector call.
+	defaultSyncPageSize = 200
+
+	syncResourceUser  = "user"
+	syncResourceGroup = "group"
+)
+
+// ErrSyncInProgress is returned when a connector's previous sync has not finished.
+var ErrSyncInProgress = errors.New("sync already in progress for connector")
+
+// ChangeLister is implemented by connectors that can list only what changed
+// since an opaque cursor. Connectors without it are fully re-read each run.
+type ChangeLister interface {
+	// ListUserChanges returns up to limit users changed after cursor ("" for
+	// everything) and the cursor to resume from.
+	ListUserChanges(ctx context.Context, cursor string, limit int) ([]User, string, error)
+	ListGroupChanges(ctx context.Context, cursor string, limit int) ([]Group, string, error)
+}
+
+// SyncSource lists the connectors that inbound sync should pull from.
+type SyncSource interface {
+	ListSyncSources(ctx context.Context) ([]Config, error)
+}
+
+// DirectorySink writes synced users and groups into the directory. Each
+// upsert returns the directory ID; InternalID is set when the object already
+// exists in the directory.
+type DirectorySink interface {
+	// LookupUser finds the directory user a source user maps to, by
+	// InternalID when set and otherwise by email.
+	LookupUser(ctx context.Context, tenantID string, user User) (directory.User, bool, error)
+	UpsertUser(ctx context.Context, tenantID string, user User) (string, error)
+	UpsertGroup(ctx context.Context, tenantID string, group Group) (string, error)
+	GroupMemberIDs(ctx context.Context, tenantID, groupID string) ([]string, error)
+	AddGroupMember(ctx context.Context, tenantID, groupID, userID string) error
+	RemoveGroupMember(ctx context.Context, tenantID, groupID, userID string) error
+}
+
+// SyncResult counts what one sync run changed.
+type SyncResult struct {
+	Users              int `json:"users"`
+	Groups             int `json:"groups"`
+	MembershipsAdded   int `json:"memberships_added"`
+	MembershipsRemoved int `json:"memberships_removed"`
+	// PlannedMemberships lists the membership changes a dry run would make.
+	PlannedMemberships []MembershipChange `json:"planned_memberships,omitempty"`
+}
+
+// SyncSchedulerConfig configures a SyncScheduler.
+type SyncSchedulerConfig struct {
+	Sources  SyncSource
+	Registry Registry
+	Sink     DirectorySink
+	// States defaults to an in-memory store.
+	States SyncStateStore
+	// Interval defaults to DefaultSyncInterval.
+	Interval time.Duration
+	// PageSize defaults to 200.
+	PageSize int
+	Logger   *zap.Logger
+}
+
+// SyncScheduler periodically pulls users and groups from source connectors
+// into the directory. Each run resumes from the cursors saved by the last
+// one, and a connector is never synced twice at the same time.
+type SyncScheduler struct {
+	sources  SyncSource
+	registry Registry
+	sink     DirectorySink
+	states   SyncStateStore
+	interval time.Duration
+	pageSize int
+	logger   *zap.Logger
+
+	mu      sync.Mutex
+	running map[string]bool
+}
+
+// NewSyncScheduler creates a new sync scheduler.
+func NewSyncScheduler(cfg SyncSchedulerConfig) *SyncScheduler {
+	s := &SyncScheduler{
+		sources:  cfg.Sources,
+		registry: cfg.Registry,
+		sink:     cfg.Sink,
+		states:   cfg.States,
+		interval: cfg.Interval,
+		pageSize: cfg.PageSize,
+		logger:   cfg.Logger,
+		running:  make(map[string]bool),
+	}
+	if s.states == nil {
+		s.states = newSyncStateMemoryStore()
+	}
+	if s.interval <= 0 {
+		s.interval = DefaultSyncInterval
+	}
+	if s.pageSize <= 0 {
+		s.pageSize = defaultSyncPageSize
+	}
+	if s.logger == nil {
+		s.logger = zap.NewNop()
+	}
+	return s
+}
+
+// Start runs a sync immediately and then every interval until ctx is done.
+// Runs happen on one goroutine, so a slow run delays the next instead of
+// overlapping it.
+func (s *SyncScheduler) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		s.RunOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce syncs every source connector once. Failures are logged and
+// recorded in the connector's sync state; they do not stop other connectors.
+func (s *SyncScheduler) RunOnce(ctx context.Context) {
+	configs, err := s.sources.ListSyncSources(ctx)
+	if err != nil {
+		s.logger.Error("Failed to list sync sources", zap.Error(err))
+		return
+	}
+	for _, config := range configs {
+		if ctx.Err() != nil {
+			return
+		}
+		result, err := s.SyncConnector(ctx, config)
+		if err != nil {
+			s.logger.Error("Connector sync failed", zap.String("connector_id", config.ID), zap.Error(err))
+			continue
+		}
+		s.logger.Info("Connector sync completed",
+			zap.String("connector_id", config.ID), zap.Int("users", result.Users), zap.Int("groups", result.Groups),
+			zap.Int("memberships_added", result.MembershipsAdded), zap.Int("memberships_removed", result.MembershipsRemoved),
+			zap.Int("planned_memberships", len(result.PlannedMemberships)))
+	}
+}
+
+// SyncConnector pulls users and groups changed since the connector's saved
+// cursors, then reconciles group memberships. It returns ErrSyncInProgress if
+// the connector is already syncing.
+func (s *SyncScheduler) SyncConnector(ctx context.Context, config Config) (SyncResult, error) {
+	if !s.claim(config.ID) {
+		return SyncResult{}, ErrSyncInProgress
+	}
+	defer s.release(config.ID)
+
+	conn, closeConn, err := s.connector(ctx, config)
+	if err != nil {
+		return SyncResult{}, err
+	}
+	defer closeConn()
+
+	state, err := s.states.GetState(ctx, config.ID)
+	if err != nil {
+		return SyncResult{}, err
+	}
+	state.ConnectorID = config.ID
+	state.TenantID = config.TenantID
+
+	var result SyncResult
+	syncErr := s.syncUsers(ctx, conn, config, &state, &result)
+	if syncErr == nil {
+		syncErr = s.syncGroups(ctx, conn, config, &state, &result)
+	}
+	if syncErr == nil {
+		syncErr = s.syncMemberships(ctx, conn, config, &result)
+	}
+
+	state.LastSyncedAt = time.Now()
+	state.LastError = ""
+	if syncErr != nil {
+		state.LastError = syncErr.Error()
+	}
+	if err := s.states.SaveState(ctx, state); err != nil && syncErr == nil {
+		syncErr = err
+	}
+	return result, syncErr
+}
+
+func (s *SyncScheduler) claim(connectorID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running[connectorID] {
+		return false
+	}
+	s.running[connectorID] = true
+	return true
+}
+
+func (s *SyncScheduler) release(connectorID string) {
+	s.mu.Lock()
+	delete(s.running, connectorID)
+	s.mu.Unlock()
+}
+
+// connector returns the registered instance for config, or builds and
+// initializes a transient one that the returned func closes.
+func (s *SyncScheduler) connector(ctx context.Context, config Config) (Connector, func(), error) {
+	if conn, ok := s.registry.Get(config.ID); ok {
+		return conn, func() {}, nil
+	}
+	conn, err := s.registry.Build(config.Type, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := conn.Initialize(ctx, config); err != nil {
+		_ = conn.Close()
+		return nil, nil, fmt.Errorf("failed to initialize connector: %w", err)
+	}
+	return conn, func() { _ = conn.Close() }, nil
+}
+
+func (s *SyncScheduler) syncUsers(ctx context.Context, conn Connector, config Config, state *SyncState, result *SyncResult) error {
+	lister, incremental := conn.(ChangeLister)
+	for offset := 0; ; offset += s.pageSize {
+		var users []User
+		var err error
+		next := state.UserCursor
+		if incremental {
+			users, next, err = lister.ListUserChanges(ctx, state.UserCursor, s.pageSize)
+		} else {
+			users, _, err = conn.ListUsers(ctx, "", s.pageSize, offset)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to list users: %w", err)
+		}
+
+		for _, user := range users {
+			if err := s.upsertUser(ctx, config, user); err != nil {
+				return err
+			}
+			result.Users++
+		}
+		// Advance the watermark page by page so an interrupted run resumes
+		// where it stopped.
+		if incremental {
+			state.UserCursor = next
+			if err := s.states.SaveState(ctx, *state); err != nil {
+				return err
+			}
+		}
+		if len(users) < s.pageSize {
+			return nil
+		}
+	}
+}
+
+func (s *SyncScheduler) syncGroups(ctx context.Context, conn Connector, config Config, state *SyncState, result *SyncResult) error {
+	lister, incremental := conn.(ChangeLister)
+	for offset := 0; ; offset += s.pageSize {
+		var groups []Group
+		var err error
+		next := state.GroupCursor
+		if incremental {
+			groups, next, err = lister.ListGroupChanges(ctx, state.GroupCursor, s.pageSize)
+		} else {
+			groups, _, err = conn.ListGroups(ctx, "", s.pageSize, offset)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to list groups: %w", err)
+		}
+
+		for _, group := range groups {
+			if err := s.upsertGroup(ctx, config, group); err != nil {
+				return err
+			}
+			result.Groups++
+		}
+		if incremental {
+			state.GroupCursor = next
+			if err := s.states.SaveState(ctx, *state); err != nil {
+				return err
+			}
+		}
+		if len(groups) < s.pageSize {
+			return nil
+		}
+	}
+}
+
+func (s *SyncScheduler) upsertUser(ctx context.Context, config Config, user User) error {
+	internalID, err := s.states.InternalID(ctx, config.ID, syncResourceUser, user.ExternalID)
+	if err != nil {
+		return err
+	}
+	user.InternalID = internalID
+	existing, found, err := s.sink.LookupUser(ctx, config.TenantID, user)
+	if err != nil {
+		return fmt.Errorf("failed to look up user %s: %w", user.ExternalID, err)
+	}
+	if found {
+		user.InternalID = existing.ID
+		policy := ConflictPolicyFor(config)
+		conflicts, sourceWins := resolveUserConflict(policy, user, existing)
+		for _, conflict := range conflicts {
+			conflict.ConnectorID = config.ID
+			conflict.TenantID = config.TenantID
+			if err := s.states.RecordConflict(ctx, conflict); err != nil {
+				return err
+			}
+		}
+		if !sourceWins {
+			if existing.ID != internalID {
+				return s.states.SaveLink(ctx, config.ID, syncResourceUser, user.ExternalID, existing.ID)
+			}
+			return nil
+		}
+	}
+	id, err := s.sink.UpsertUser(ctx, config.TenantID, user)
+	if err != nil {
+		return fmt.Errorf("failed to upsert user %s: %w", user.ExternalID, err)
+	}
+	if id != internalID {
+		return s.states.SaveLink(ctx, config.ID, syncResourceUser, user.ExternalID, id)
+	}
+	return nil
+}
+
+func (s *SyncScheduler) upsertGroup(ctx context.Context, config Config, group Group) error {
+	internalID, err := s.states.InternalID(ctx, config.ID, syncResourceGroup, group.ExternalID)
+	if err != nil {
+		return err
+	}
+	group.InternalID = internalID
+	id, err := s.sink.UpsertGroup(ctx, config.TenantID, group)
+	if err != nil {
+		return fmt.Errorf("failed to upsert group %s: %w", group.ExternalID, err)
+	}
+	if id != internalID {
+		return s.states.SaveLink(ctx, config.ID, syncResourceGroup, group.ExternalID, id)
+	}
+	return nil
+}
+
+// directorySink writes synced objects through the directory service.
+type directorySink struct {
+	svc directory.Service
+}
+
+// NewDirectorySink creates a DirectorySink backed by a directory service.
+func NewDirectorySink(svc directory.Service) DirectorySink {
+	return &directorySink{svc: svc}
+}
+
+func (d *directorySink) LookupUser(ctx context.Context, tenantID string, user User) (directory.User, bool, error) {
+	if user.InternalID != "" {
+		existing, err := d.svc.GetUserByID(ctx, tenantID, user.InternalID)
+		if err == nil {
+			return existing, true, nil
+		}
+		// A linked user deleted from the directory is matched by email again.
+		if !errors.Is(err, sql.ErrNoRows) {
+			return directory.User{}, false, err
+		}
+	}
+	existing, err := d.svc.GetUserByEmail(ctx, tenantID, user.Email)
+	if errors.Is(err, directory.ErrUserNotFound) {
+		return directory.User{}, false, nil
+	}
+	if err != nil {
+		return directory.User{}, false, err
+	}
+	return existing, true, nil
+}
+
+func (d *directorySink) UpsertUser(ctx context.Context, tenantID string, user User) (string, error) {
+	status := directoryStatus(user)
+	// Profile attributes the source leaves empty keep their directory value.
+	profile := directory.Profile{Phone: user.Phone, Title: user.Title, Department: user.Department, Location: user.Location}
+	if user.InternalID != "" {
+		return user.InternalID, d.svc.UpdateUser(ctx, tenantID, user.InternalID, directory.User{Email: user.Email, Status: status, Profile: profile})
+	}
+
+	// Synced accounts sign in through the source or set a password with the
+	// reset flow, so they get a random one here.
+	password, err := randomPassword()
+	if err != nil {
+		return "", err
+	}
+	return d.svc.CreateUser(ctx, tenantID, directory.User{Email: user.Email, Password: password, Status: status, Profile: profile})
+}
+
+func (d *directorySink) UpsertGroup(ctx context.Context, tenantID string, group Group) (string, error) {
+	if group.InternalID != "" {
+		return group.InternalID, d.svc.UpdateGroup(ctx, tenantID, group.InternalID, directory.Group{Name: group.Name})
+	}
+	return d.svc.CreateGroup(ctx, tenantID, directory.Group{Name: group.Name})
+}
+
+func (d *directorySink) GroupMemberIDs(ctx context.Context, tenantID, groupID string) ([]string, error) {
+	return d.svc.ListGroupMemberIDs(ctx, tenantID, groupID)
+}
+
+func (d *directorySink) AddGroupMember(ctx context.Context, tenantID, groupID, userID string) error {
+	return d.svc.AddUserToGroup(ctx, tenantID, userID, groupID)
+}
+
+func (d *directorySink) RemoveGroupMember(ctx context.Context, tenantID, groupID, userID string) error {
+	return d.svc.RemoveUserFromGroup(ctx, tenantID, userID, groupID)
+}
+
+// randomPassword returns a password that satisfies any directory password
+// policy; the fixed suffix covers every character class.
+func randomPassword() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b) + "Aa1!", nil
+}
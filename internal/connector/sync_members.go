@@ -0,0 +1,152 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+const (
+	// SettingMembershipDryRun, when "true", makes sync report the group
+	// membership changes it would make instead of applying them.
+	SettingMembershipDryRun = "membership_dry_run"
+	// SettingMaxMembershipRemovals caps how many directory memberships one
+	// sync may remove. A run over the cap removes none of them.
+	SettingMaxMembershipRemovals = "membership_max_removals"
+	// DefaultMaxMembershipRemovals is the removal cap when none is configured.
+	DefaultMaxMembershipRemovals = 100
+)
+
+// ErrMembershipRemovalLimit is returned when a sync would remove more group
+// memberships than the connector allows.
+var ErrMembershipRemovalLimit = errors.New("membership removals exceed limit")
+
+// MembershipChange is one directory group membership that sync adds or removes.
+type MembershipChange struct {
+	GroupID string `json:"group_id"`
+	UserID  string `json:"user_id"`
+	Add     bool   `json:"add"`
+}
+
+// maxMembershipRemovals returns the connector's removal cap.
+func maxMembershipRemovals(config Config) int {
+	if n, err := strconv.Atoi(config.Settings[SettingMaxMembershipRemovals]); err == nil && n >= 0 {
+		return n
+	}
+	return DefaultMaxMembershipRemovals
+}
+
+// syncMemberships makes each synced group's directory members match the
+// source's. Members that have not been synced as users are ignored.
+func (s *SyncScheduler) syncMemberships(ctx context.Context, conn Connector, config Config, result *SyncResult) error {
+	changes, err := s.planMemberships(ctx, conn, config)
+	if err != nil {
+		return err
+	}
+	if config.Settings[SettingMembershipDryRun] == "true" {
+		result.PlannedMemberships = changes
+		return nil
+	}
+
+	removals := 0
+	for _, change := range changes {
+		if !change.Add {
+			removals++
+		}
+	}
+	limit := maxMembershipRemovals(config)
+	blocked := removals > limit
+
+	for _, change := range changes {
+		switch {
+		case change.Add:
+			if err := s.sink.AddGroupMember(ctx, config.TenantID, change.GroupID, change.UserID); err != nil {
+				return fmt.Errorf("failed to add user %s to group %s: %w", change.UserID, change.GroupID, err)
+			}
+			result.MembershipsAdded++
+		case !blocked:
+			if err := s.sink.RemoveGroupMember(ctx, config.TenantID, change.GroupID, change.UserID); err != nil {
+				return fmt.Errorf("failed to remove user %s from group %s: %w", change.UserID, change.GroupID, err)
+			}
+			result.MembershipsRemoved++
+		}
+	}
+	if blocked {
+		return fmt.Errorf("%w: %d removals, limit %d", ErrMembershipRemovalLimit, removals, limit)
+	}
+	return nil
+}
+
+// planMemberships diffs every synced source group's members against the
+// directory, returning additions and removals in a stable order.
+func (s *SyncScheduler) planMemberships(ctx context.Context, conn Connector, config Config) ([]MembershipChange, error) {
+	var changes []MembershipChange
+	for offset := 0; ; offset += s.pageSize {
+		groups, _, err := conn.ListGroups(ctx, "", s.pageSize, offset)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list groups: %w", err)
+		}
+		for _, group := range groups {
+			groupChanges, err := s.planGroupMemberships(ctx, conn, config, group)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, groupChanges...)
+		}
+		if len(groups) < s.pageSize {
+			return changes, nil
+		}
+	}
+}
+
+func (s *SyncScheduler) planGroupMemberships(ctx context.Context, conn Connector, config Config, group Group) ([]MembershipChange, error) {
+	groupID, err := s.states.InternalID(ctx, config.ID, syncResourceGroup, group.ExternalID)
+	if err != nil || groupID == "" {
+		return nil, err
+	}
+
+	members, err := conn.GetGroupMembers(ctx, group.ExternalID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list members of group %s: %w", group.ExternalID, err)
+	}
+	want := make(map[string]bool, len(members))
+	for _, member := range members {
+		userID, err := s.states.InternalID(ctx, config.ID, syncResourceUser, member.ExternalID)
+		if err != nil {
+			return nil, err
+		}
+		if userID != "" {
+			want[userID] = true
+		}
+	}
+
+	current, err := s.sink.GroupMemberIDs(ctx, config.TenantID, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list directory members of group %s: %w", groupID, err)
+	}
+	have := make(map[string]bool, len(current))
+	for _, userID := range current {
+		have[userID] = true
+	}
+
+	var changes []MembershipChange
+	for userID := range want {
+		if !have[userID] {
+			changes = append(changes, MembershipChange{GroupID: groupID, UserID: userID, Add: true})
+		}
+	}
+	for userID := range have {
+		if !want[userID] {
+			changes = append(changes, MembershipChange{GroupID: groupID, UserID: userID})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Add != changes[j].Add {
+			return changes[i].Add
+		}
+		return changes[i].UserID < changes[j].UserID
+	})
+	return changes, nil
+}
@@ -0,0 +1,171 @@
+package connector
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// SyncState is a connector's inbound sync watermark.
+type SyncState struct {
+	ConnectorID  string    `json:"connector_id" db:"connector_id"`
+	TenantID     string    `json:"tenant_id" db:"tenant_id"`
+	UserCursor   string    `json:"user_cursor" db:"user_cursor"`
+	GroupCursor  string    `json:"group_cursor" db:"group_cursor"`
+	LastSyncedAt time.Time `json:"last_synced_at" db:"last_synced_at"`
+	LastError    string    `json:"last_error,omitempty" db:"last_error"`
+}
+
+// SyncStateStore records each connector's sync cursors and which directory
+// object each external user or group was synced to.
+type SyncStateStore interface {
+	// GetState returns the connector's state, or a zero state before its first sync.
+	GetState(ctx context.Context, connectorID string) (SyncState, error)
+	SaveState(ctx context.Context, state SyncState) error
+	// InternalID returns the directory ID linked to an external object, or ""
+	// when it has not been synced yet.
+	InternalID(ctx context.Context, connectorID, resourceType, externalID string) (string, error)
+	SaveLink(ctx context.Context, connectorID, resourceType, externalID, internalID string) error
+	RecordConflict(ctx context.Context, conflict SyncConflict) error
+	// ListConflicts returns the connector's most recent conflicts first.
+	ListConflicts(ctx context.Context, connectorID string, limit int) ([]SyncConflict, error)
+}
+
+type syncStateRepo struct {
+	db *sqlx.DB
+}
+
+// NewSyncStateStore creates a new SQL-backed sync state store.
+func NewSyncStateStore(db *sqlx.DB) SyncStateStore {
+	return &syncStateRepo{db: db}
+}
+
+func (r *syncStateRepo) GetState(ctx context.Context, connectorID string) (SyncState, error) {
+	var state SyncState
+	err := r.db.GetContext(ctx, &state,
+		`SELECT connector_id, tenant_id, user_cursor, group_cursor, last_synced_at, last_error
+		 FROM connector_sync_state WHERE connector_id = $1`, connectorID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return SyncState{ConnectorID: connectorID}, nil
+	}
+	return state, err
+}
+
+func (r *syncStateRepo) SaveState(ctx context.Context, state SyncState) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO connector_sync_state (connector_id, tenant_id, user_cursor, group_cursor, last_synced_at, last_error)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 ON CONFLICT (connector_id) DO UPDATE SET
+			user_cursor = EXCLUDED.user_cursor, group_cursor = EXCLUDED.group_cursor,
+			last_synced_at = EXCLUDED.last_synced_at, last_error = EXCLUDED.last_error`,
+		state.ConnectorID, state.TenantID, state.UserCursor, state.GroupCursor, state.LastSyncedAt, state.LastError)
+	return err
+}
+
+func (r *syncStateRepo) InternalID(ctx context.Context, connectorID, resourceType, externalID string) (string, error) {
+	var id string
+	err := r.db.GetContext(ctx, &id,
+		`SELECT internal_id FROM connector_sync_links
+		 WHERE connector_id = $1 AND resource_type = $2 AND external_id = $3`,
+		connectorID, resourceType, externalID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return id, err
+}
+
+func (r *syncStateRepo) SaveLink(ctx context.Context, connectorID, resourceType, externalID, internalID string) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO connector_sync_links (connector_id, resource_type, external_id, internal_id)
+		 VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (connector_id, resource_type, external_id) DO UPDATE SET internal_id = EXCLUDED.internal_id`,
+		connectorID, resourceType, externalID, internalID)
+	return err
+}
+
+func (r *syncStateRepo) RecordConflict(ctx context.Context, conflict SyncConflict) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO connector_sync_conflicts
+			(connector_id, tenant_id, external_id, internal_id, attribute, source_value, directory_value, policy, resolution, detected_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		conflict.ConnectorID, conflict.TenantID, conflict.ExternalID, conflict.InternalID, conflict.Attribute,
+		conflict.SourceValue, conflict.DirectoryValue, conflict.Policy, conflict.Resolution, conflict.DetectedAt)
+	return err
+}
+
+func (r *syncStateRepo) ListConflicts(ctx context.Context, connectorID string, limit int) ([]SyncConflict, error) {
+	var conflicts []SyncConflict
+	err := r.db.SelectContext(ctx, &conflicts,
+		`SELECT connector_id, tenant_id, external_id, internal_id, attribute, source_value, directory_value, policy, resolution, detected_at
+		 FROM connector_sync_conflicts WHERE connector_id = $1
+		 ORDER BY detected_at DESC LIMIT $2`, connectorID, limit)
+	return conflicts, err
+}
+
+// syncStateMemoryStore is an in-memory SyncStateStore used when no database is configured.
+type syncStateMemoryStore struct {
+	mu        sync.Mutex
+	states    map[string]SyncState
+	links     map[string]string
+	conflicts []SyncConflict
+}
+
+func newSyncStateMemoryStore() *syncStateMemoryStore {
+	return &syncStateMemoryStore{
+		states: make(map[string]SyncState),
+		links:  make(map[string]string),
+	}
+}
+
+func (s *syncStateMemoryStore) GetState(ctx context.Context, connectorID string) (SyncState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.states[connectorID]
+	if !ok {
+		return SyncState{ConnectorID: connectorID}, nil
+	}
+	return state, nil
+}
+
+func (s *syncStateMemoryStore) SaveState(ctx context.Context, state SyncState) error {
+	s.mu.Lock()
+	s.states[state.ConnectorID] = state
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *syncStateMemoryStore) InternalID(ctx context.Context, connectorID, resourceType, externalID string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.links[connectorID+"::"+resourceType+"::"+externalID], nil
+}
+
+func (s *syncStateMemoryStore) SaveLink(ctx context.Context, connectorID, resourceType, externalID, internalID string) error {
+	s.mu.Lock()
+	s.links[connectorID+"::"+resourceType+"::"+externalID] = internalID
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *syncStateMemoryStore) RecordConflict(ctx context.Context, conflict SyncConflict) error {
+	s.mu.Lock()
+	s.conflicts = append(s.conflicts, conflict)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *syncStateMemoryStore) ListConflicts(ctx context.Context, connectorID string, limit int) ([]SyncConflict, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var conflicts []SyncConflict
+	for i := len(s.conflicts) - 1; i >= 0 && len(conflicts) < limit; i-- {
+		if s.conflicts[i].ConnectorID == connectorID {
+			conflicts = append(conflicts, s.conflicts[i])
+		}
+	}
+	return conflicts, nil
+}
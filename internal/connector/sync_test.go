@@ -0,0 +1,426 @@
+package connector_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dhawalhost/wardseal/internal/connector"
+	"github.com/dhawalhost/wardseal/internal/connector/memory"
+	"github.com/dhawalhost/wardseal/internal/directory"
+)
+
+type staticSources []connector.Config
+
+func (s staticSources) ListSyncSources(context.Context) ([]connector.Config, error) {
+	return s, nil
+}
+
+// recordingSink assigns directory IDs and records every upsert. Upserted
+// users are kept in directory so later lookups find them.
+type recordingSink struct {
+	mu        sync.Mutex
+	nextID    int
+	users     []connector.User
+	groups    []connector.Group
+	directory []directory.User
+	members   map[string]map[string]bool // group ID -> user IDs
+	// block, when set, holds UpsertUser until closed.
+	block   chan struct{}
+	entered chan struct{}
+}
+
+func (s *recordingSink) LookupUser(ctx context.Context, tenantID string, user connector.User) (directory.User, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.directory {
+		if existing.ID == user.InternalID || existing.Email == user.Email {
+			return existing, true, nil
+		}
+	}
+	return directory.User{}, false, nil
+}
+
+func (s *recordingSink) UpsertUser(ctx context.Context, tenantID string, user connector.User) (string, error) {
+	if s.block != nil {
+		s.entered <- struct{}{}
+		<-s.block
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users = append(s.users, user)
+	stored := directory.User{ID: user.InternalID, Email: user.Email, Status: "active", UpdatedAt: time.Now()}
+	if !user.Active {
+		stored.Status = "inactive"
+	}
+	for i, existing := range s.directory {
+		if existing.ID == user.InternalID {
+			s.directory[i] = stored
+			return stored.ID, nil
+		}
+	}
+	s.nextID++
+	stored.ID = fmt.Sprintf("dir-user-%d", s.nextID)
+	s.directory = append(s.directory, stored)
+	return stored.ID, nil
+}
+
+func (s *recordingSink) UpsertGroup(ctx context.Context, tenantID string, group connector.Group) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.groups = append(s.groups, group)
+	if group.InternalID != "" {
+		return group.InternalID, nil
+	}
+	s.nextID++
+	return fmt.Sprintf("dir-group-%d", s.nextID), nil
+}
+
+func (s *recordingSink) GroupMemberIDs(ctx context.Context, tenantID, groupID string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ids []string
+	for userID := range s.members[groupID] {
+		ids = append(ids, userID)
+	}
+	return ids, nil
+}
+
+func (s *recordingSink) AddGroupMember(ctx context.Context, tenantID, groupID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.members == nil {
+		s.members = make(map[string]map[string]bool)
+	}
+	if s.members[groupID] == nil {
+		s.members[groupID] = make(map[string]bool)
+	}
+	s.members[groupID][userID] = true
+	return nil
+}
+
+func (s *recordingSink) RemoveGroupMember(ctx context.Context, tenantID, groupID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.members[groupID], userID)
+	return nil
+}
+
+// userID returns the directory ID the sink assigned to email.
+func (s *recordingSink) userID(email string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, user := range s.directory {
+		if user.Email == email {
+			return user.ID
+		}
+	}
+	return ""
+}
+
+func newMemorySource(t *testing.T) (connector.Config, connector.Registry, connector.Connector) {
+	t.Helper()
+	config := connector.Config{
+		ID:       "conn-1",
+		TenantID: "22222222-2222-2222-2222-222222222222",
+		Name:     "hr",
+		Type:     "memory",
+		Enabled:  true,
+		Settings: map[string]string{connector.SettingSyncEnabled: "true"},
+	}
+	registry := connector.NewRegistry()
+	registry.Register("memory", memory.New, memory.Schema)
+	conn, err := registry.Create("memory", config)
+	if err != nil {
+		t.Fatalf("failed to create memory connector: %v", err)
+	}
+	return config, registry, conn
+}
+
+func TestSyncSchedulerUpsertsIncrementally(t *testing.T) {
+	ctx := context.Background()
+	config, registry, source := newMemorySource(t)
+	sink := &recordingSink{}
+	scheduler := connector.NewSyncScheduler(connector.SyncSchedulerConfig{
+		Sources:  staticSources{config},
+		Registry: registry,
+		Sink:     sink,
+		PageSize: 2,
+	})
+
+	var ids []string
+	for _, email := range []string{"ada@wardseal.com", "grace@wardseal.com", "linus@wardseal.com"} {
+		id, _ := source.CreateUser(ctx, connector.User{Email: email, Active: true})
+		ids = append(ids, id)
+	}
+	_, _ = source.CreateGroup(ctx, connector.Group{Name: "engineering"})
+
+	result, err := scheduler.SyncConnector(ctx, config)
+	if err != nil {
+		t.Fatalf("first sync failed: %v", err)
+	}
+	if result.Users != 3 || result.Groups != 1 {
+		t.Fatalf("expected 3 users and 1 group on the first sync, got %+v", result)
+	}
+
+	result, err = scheduler.SyncConnector(ctx, config)
+	if err != nil {
+		t.Fatalf("second sync failed: %v", err)
+	}
+	if result.Users != 0 || result.Groups != 0 {
+		t.Fatalf("expected nothing to sync without changes, got %+v", result)
+	}
+
+	_ = source.UpdateUser(ctx, ids[1], connector.User{Email: "grace@wardseal.com", Active: false})
+	_, _ = source.CreateUser(ctx, connector.User{Email: "alan@wardseal.com", Active: true})
+
+	sink.users = nil
+	result, err = scheduler.SyncConnector(ctx, config)
+	if err != nil {
+		t.Fatalf("third sync failed: %v", err)
+	}
+	if result.Users != 2 || len(sink.users) != 2 {
+		t.Fatalf("expected only the 2 changed users, got %+v", result)
+	}
+	updated, created := sink.users[0], sink.users[1]
+	if updated.Email != "grace@wardseal.com" || updated.Active || updated.InternalID != "dir-user-2" {
+		t.Fatalf("expected grace to update her existing directory user, got %+v", updated)
+	}
+	if created.Email != "alan@wardseal.com" || created.InternalID != "" {
+		t.Fatalf("expected alan to be created, got %+v", created)
+	}
+}
+
+func TestSyncSchedulerDoesNotOverlapRuns(t *testing.T) {
+	ctx := context.Background()
+	config, registry, source := newMemorySource(t)
+	_, _ = source.CreateUser(ctx, connector.User{Email: "ada@wardseal.com", Active: true})
+	sink := &recordingSink{block: make(chan struct{}), entered: make(chan struct{}, 1)}
+	scheduler := connector.NewSyncScheduler(connector.SyncSchedulerConfig{
+		Sources:  staticSources{config},
+		Registry: registry,
+		Sink:     sink,
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := scheduler.SyncConnector(ctx, config)
+		done <- err
+	}()
+	<-sink.entered
+
+	if _, err := scheduler.SyncConnector(ctx, config); !errors.Is(err, connector.ErrSyncInProgress) {
+		t.Fatalf("expected ErrSyncInProgress while a sync is running, got %v", err)
+	}
+
+	close(sink.block)
+	if err := <-done; err != nil {
+		t.Fatalf("first sync failed: %v", err)
+	}
+	sink.block = nil
+	if _, err := scheduler.SyncConnector(ctx, config); err != nil {
+		t.Fatalf("expected a new sync once the first finished, got %v", err)
+	}
+}
+
+func TestSyncSchedulerAppliesConflictPolicy(t *testing.T) {
+	tests := []struct {
+		name           string
+		policy         string
+		directoryAge   time.Duration
+		wantResolution string
+	}{
+		{name: "default", policy: "", wantResolution: "source"},
+		{name: "source wins", policy: connector.ConflictSourceWins, wantResolution: "source"},
+		{name: "directory wins", policy: connector.ConflictDirectoryWins, wantResolution: "directory"},
+		{name: "newest wins with newer source", policy: connector.ConflictNewestWins, directoryAge: -time.Hour, wantResolution: "source"},
+		{name: "newest wins with newer directory", policy: connector.ConflictNewestWins, directoryAge: time.Hour, wantResolution: "directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			config, registry, source := newMemorySource(t)
+			if tt.policy != "" {
+				config.Settings[connector.SettingConflictPolicy] = tt.policy
+			}
+			_, _ = source.CreateUser(ctx, connector.User{Email: "ada@wardseal.com", Active: true})
+
+			// The directory already has ada, but suspended.
+			sink := &recordingSink{directory: []directory.User{{
+				ID:        "dir-ada",
+				Email:     "ada@wardseal.com",
+				Status:    "suspended",
+				UpdatedAt: time.Now().Add(tt.directoryAge),
+			}}}
+			states := connector.NewSyncStateMemoryStore()
+			scheduler := connector.NewSyncScheduler(connector.SyncSchedulerConfig{
+				Sources:  staticSources{config},
+				Registry: registry,
+				Sink:     sink,
+				States:   states,
+			})
+			if _, err := scheduler.SyncConnector(ctx, config); err != nil {
+				t.Fatalf("sync failed: %v", err)
+			}
+
+			conflicts, _ := states.ListConflicts(ctx, config.ID, 10)
+			if len(conflicts) != 1 {
+				t.Fatalf("expected 1 recorded conflict, got %+v", conflicts)
+			}
+			conflict := conflicts[0]
+			if conflict.Attribute != "active" || conflict.SourceValue != "true" || conflict.DirectoryValue != "suspended" ||
+				conflict.InternalID != "dir-ada" || conflict.Resolution != tt.wantResolution {
+				t.Fatalf("unexpected conflict %+v", conflict)
+			}
+
+			wantStatus := "active"
+			if tt.wantResolution == "directory" {
+				wantStatus = "suspended"
+			}
+			if got := sink.directory[0].Status; got != wantStatus {
+				t.Fatalf("expected directory status %q, got %q", wantStatus, got)
+			}
+			if len(sink.directory) != 1 {
+				t.Fatalf("expected ada to be matched, not duplicated: %+v", sink.directory)
+			}
+
+			// The resolved user is linked, so an unchanged source is not
+			// re-resolved on the next run.
+			if internalID, _ := states.InternalID(ctx, config.ID, "user", conflict.ExternalID); internalID != "dir-ada" {
+				t.Fatalf("expected ada to be linked to dir-ada, got %q", internalID)
+			}
+		})
+	}
+}
+
+func TestSyncSchedulerRecordsNoConflictWhenAttributesMatch(t *testing.T) {
+	ctx := context.Background()
+	config, registry, source := newMemorySource(t)
+	config.Settings[connector.SettingConflictPolicy] = connector.ConflictDirectoryWins
+	_, _ = source.CreateUser(ctx, connector.User{Email: "ada@wardseal.com", Active: true})
+	sink := &recordingSink{directory: []directory.User{{ID: "dir-ada", Email: "ada@wardseal.com", Status: "active"}}}
+	states := connector.NewSyncStateMemoryStore()
+	scheduler := connector.NewSyncScheduler(connector.SyncSchedulerConfig{
+		Sources:  staticSources{config},
+		Registry: registry,
+		Sink:     sink,
+		States:   states,
+	})
+	if _, err := scheduler.SyncConnector(ctx, config); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	if conflicts, _ := states.ListConflicts(ctx, config.ID, 10); len(conflicts) != 0 {
+		t.Fatalf("expected no conflicts, got %+v", conflicts)
+	}
+}
+
+// newMembershipFixture syncs ada and grace as members of engineering, then
+// applies settings and has grace leave and linus join upstream.
+func newMembershipFixture(t *testing.T, settings map[string]string) (connector.Config, *connector.SyncScheduler, *recordingSink) {
+	t.Helper()
+	ctx := context.Background()
+	config, registry, source := newMemorySource(t)
+	ids := make(map[string]string)
+	for _, email := range []string{"ada@wardseal.com", "grace@wardseal.com", "linus@wardseal.com"} {
+		ids[email], _ = source.CreateUser(ctx, connector.User{Email: email, Active: true})
+	}
+	groupID, _ := source.CreateGroup(ctx, connector.Group{Name: "engineering"})
+	_ = source.AddUserToGroup(ctx, ids["ada@wardseal.com"], groupID)
+	_ = source.AddUserToGroup(ctx, ids["grace@wardseal.com"], groupID)
+
+	sink := &recordingSink{}
+	scheduler := connector.NewSyncScheduler(connector.SyncSchedulerConfig{
+		Sources:  staticSources{config},
+		Registry: registry,
+		Sink:     sink,
+	})
+	result, err := scheduler.SyncConnector(ctx, config)
+	if err != nil {
+		t.Fatalf("first sync failed: %v", err)
+	}
+	if result.MembershipsAdded != 2 || result.MembershipsRemoved != 0 {
+		t.Fatalf("expected ada and grace to be added, got %+v", result)
+	}
+	for key, value := range settings {
+		config.Settings[key] = value
+	}
+
+	// Upstream, grace leaves engineering and linus joins.
+	_ = source.RemoveUserFromGroup(ctx, ids["grace@wardseal.com"], groupID)
+	_ = source.AddUserToGroup(ctx, ids["linus@wardseal.com"], groupID)
+	return config, scheduler, sink
+}
+
+func directoryMembers(sink *recordingSink) []string {
+	var emails []string
+	for _, group := range sink.members {
+		for userID := range group {
+			for _, user := range sink.directory {
+				if user.ID == userID {
+					emails = append(emails, user.Email)
+				}
+			}
+		}
+	}
+	sort.Strings(emails)
+	return emails
+}
+
+func TestSyncSchedulerReconcilesGroupMemberships(t *testing.T) {
+	config, scheduler, sink := newMembershipFixture(t, nil)
+
+	result, err := scheduler.SyncConnector(context.Background(), config)
+	if err != nil {
+		t.Fatalf("second sync failed: %v", err)
+	}
+	if result.MembershipsAdded != 1 || result.MembershipsRemoved != 1 {
+		t.Fatalf("expected one membership added and one removed, got %+v", result)
+	}
+	want := []string{"ada@wardseal.com", "linus@wardseal.com"}
+	if got := directoryMembers(sink); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected members %v, got %v", want, got)
+	}
+}
+
+func TestSyncSchedulerMembershipDryRunChangesNothing(t *testing.T) {
+	config, scheduler, sink := newMembershipFixture(t, map[string]string{connector.SettingMembershipDryRun: "true"})
+
+	result, err := scheduler.SyncConnector(context.Background(), config)
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if result.MembershipsAdded != 0 || result.MembershipsRemoved != 0 {
+		t.Fatalf("expected a dry run to apply nothing, got %+v", result)
+	}
+	planned := result.PlannedMemberships
+	if len(planned) != 2 ||
+		!planned[0].Add || planned[0].UserID != sink.userID("linus@wardseal.com") ||
+		planned[1].Add || planned[1].UserID != sink.userID("grace@wardseal.com") {
+		t.Fatalf("expected linus to be added and grace removed, got %+v", planned)
+	}
+	want := []string{"ada@wardseal.com", "grace@wardseal.com"}
+	if got := directoryMembers(sink); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected members to be unchanged as %v, got %v", want, got)
+	}
+}
+
+func TestSyncSchedulerMembershipRemovalGuardrail(t *testing.T) {
+	config, scheduler, sink := newMembershipFixture(t, map[string]string{connector.SettingMaxMembershipRemovals: "0"})
+
+	result, err := scheduler.SyncConnector(context.Background(), config)
+	if !errors.Is(err, connector.ErrMembershipRemovalLimit) {
+		t.Fatalf("expected ErrMembershipRemovalLimit, got %v", err)
+	}
+	if result.MembershipsAdded != 1 || result.MembershipsRemoved != 0 {
+		t.Fatalf("expected additions to apply and removals to be held back, got %+v", result)
+	}
+	want := []string{"ada@wardseal.com", "grace@wardseal.com", "linus@wardseal.com"}
+	if got := directoryMembers(sink); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected members %v, got %v", want, got)
+	}
+}
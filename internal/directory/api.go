@@ -1,21 +1,37 @@
 package directory
 
 import (
+	"compress/gzip"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/dhawalhost/wardseal/pkg/apierr"
 	"github.com/dhawalhost/wardseal/pkg/middleware"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"go.uber.org/zap"
 )
 
+// ServiceName is the audience signed service tokens name to call the directory.
+const ServiceName = "dirsvc"
+
 // HTTPHandler represents the HTTP API handlers for the directory service.
 type HTTPHandler struct {
-	svc         Service
-	logger      *zap.Logger
-	validate    *validator.Validate
-	serviceAuth middleware.ServiceAuthConfig
+	svc           Service
+	logger        *zap.Logger
+	validate      *validator.Validate
+	serviceAuth   middleware.ServiceAuthConfig
+	photos        PhotoStore
+	maxPhotoBytes int64
+	tenantConfig  middleware.TenantConfig
 }
 
 // NewHTTPHandler creates a new HTTPHandler.
@@ -23,14 +39,38 @@ func NewHTTPHandler(svc Service, logger *zap.Logger, cfg HTTPHandlerConfig) *HTT
 	serviceAuth := middleware.ServiceAuthConfig{
 		HeaderName: cfg.ServiceAuthHeader,
 		Token:      cfg.ServiceAuthToken,
+		Audience:   ServiceName,
+		Keys:       cfg.ServiceKeys,
+	}
+	maxPhotoBytes := cfg.MaxPhotoBytes
+	if maxPhotoBytes <= 0 {
+		maxPhotoBytes = DefaultMaxPhotoBytes
+	}
+	return &HTTPHandler{
+		svc:           svc,
+		logger:        logger,
+		validate:      apierr.NewValidator(),
+		serviceAuth:   serviceAuth,
+		photos:        cfg.Photos,
+		maxPhotoBytes: maxPhotoBytes,
+		tenantConfig:  middleware.TenantConfig{Lookup: cfg.TenantLookup},
 	}
-	return &HTTPHandler{svc: svc, logger: logger, validate: validator.New(), serviceAuth: serviceAuth}
 }
 
 // HTTPHandlerConfig controls optional behavior for the HTTP handler.
 type HTTPHandlerConfig struct {
 	ServiceAuthToken  string
 	ServiceAuthHeader string
+	// ServiceKeys maps calling services to the keys their signed tokens use.
+	ServiceKeys map[string][]byte
+	// Photos stores user photos. The photo routes are registered only when
+	// it is set.
+	Photos PhotoStore
+	// MaxPhotoBytes caps an uploaded photo; zero means DefaultMaxPhotoBytes.
+	// The router's body limit applies as well.
+	MaxPhotoBytes int64
+	// TenantLookup, when set, rejects requests for unknown or suspended tenants.
+	TenantLookup middleware.TenantLookup
 }
 
 // RegisterRoutes registers the directory routes.
@@ -39,32 +79,42 @@ func (h *HTTPHandler) RegisterRoutes(router *gin.Engine) {
 	router.GET("/health", h.healthCheck)
 
 	tenantProtected := router.Group("/")
-	tenantProtected.Use(middleware.TenantExtractor(middleware.TenantConfig{}))
+	tenantProtected.Use(middleware.TenantExtractor(h.tenantConfig))
 
 	internalRoutes := router.Group("/internal")
-	internalRoutes.Use(middleware.ServiceAuthenticator(h.serviceAuth))
-	internalRoutes.Use(middleware.TenantExtractor(middleware.TenantConfig{}))
+	internalRoutes.Use(middleware.ServiceAuth(h.serviceAuth))
+	internalRoutes.Use(middleware.TenantExtractor(h.tenantConfig))
 	internalRoutes.POST("/credentials/verify", h.verifyCredentials)
+	internalRoutes.POST("/credentials/password", h.setPassword)
+	internalRoutes.POST("/credentials/login", h.recordLogin)
 
 	// Global internal routes (no tenant context required)
 	globalInternalRoutes := router.Group("/internal")
-	globalInternalRoutes.Use(middleware.ServiceAuthenticator(h.serviceAuth))
+	globalInternalRoutes.Use(middleware.ServiceAuth(h.serviceAuth))
 	globalInternalRoutes.GET("/discover", h.discoverTenant)
 
 	// User routes
 	users := tenantProtected.Group("/users")
 	{
 		users.POST("", h.createUser)
+		users.POST("/batch", h.createUsers)
+		users.GET("/export", h.exportUsers)
+		users.GET("/stale", h.listStaleUsers) // /users/stale?days=90
 		users.GET("/:id", h.getUserByID)
-		users.GET("", h.getUserByEmail) // /users?email=...
+		users.GET("", h.findUsers) // /users?email=... or /users?q=...
 		users.PUT("/:id", h.updateUser)
 		users.DELETE("/:id", h.deleteUser)
+		if h.photos != nil {
+			users.GET("/:id/photo", h.getPhoto)
+			users.PUT("/:id/photo", h.putPhoto)
+		}
 	}
 
 	// Group routes
 	groups := tenantProtected.Group("/groups")
 	{
 		groups.POST("", h.createGroup)
+		groups.GET("", h.listGroups)
 		groups.GET("/:id", h.getGroupByID)
 		groups.PUT("/:id", h.updateGroup)
 		groups.DELETE("/:id", h.deleteGroup)
@@ -81,8 +131,7 @@ func (h *HTTPHandler) RegisterRoutes(router *gin.Engine) {
 func (h *HTTPHandler) healthCheck(c *gin.Context) {
 	ok, err := h.svc.HealthCheck(c.Request.Context())
 	if err != nil {
-		h.logger.Error("Health check failed", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierr.Abort(c, apierr.Internal(err))
 		return
 	}
 	c.JSON(http.StatusOK, HealthCheckResponse{Healthy: ok})
@@ -97,25 +146,57 @@ func (h *HTTPHandler) createUser(c *gin.Context) {
 	var req CreateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		h.logger.Error("Failed to bind create user request", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierr.Abort(c, apierr.Validation(err))
 		return
 	}
 
 	if err := h.validate.Struct(req); err != nil {
 		h.logger.Error("Create user request validation failed", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierr.Abort(c, apierr.Validation(err))
 		return
 	}
 
 	userID, err := h.svc.CreateUser(c.Request.Context(), tenantID, req.User)
 	if err != nil {
-		h.logger.Error("Create user failed", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierr.Abort(c, serviceError(err))
 		return
 	}
 	c.JSON(http.StatusCreated, CreateUserResponse{UserID: userID})
 }
 
+func (h *HTTPHandler) createUsers(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+	var req BatchCreateUsersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("Failed to bind batch create users request", zap.Error(err))
+		apierr.Abort(c, apierr.Validation(err))
+		return
+	}
+	if len(req.Users) == 0 || len(req.Users) > MaxBatchSize {
+		apierr.Abort(c, apierr.Invalid(fmt.Sprintf("users must contain between 1 and %d items", MaxBatchSize)))
+		return
+	}
+
+	results, err := h.svc.CreateUsers(c.Request.Context(), tenantID, req.Users)
+	if err != nil {
+		apierr.Abort(c, serviceError(err))
+		return
+	}
+
+	resp := BatchCreateUsersResponse{Results: results}
+	for _, result := range results {
+		if result.Error != "" {
+			resp.Failed++
+		} else {
+			resp.Created++
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
 func (h *HTTPHandler) getUserByID(c *gin.Context) {
 	tenantID, ok := h.tenantID(c)
 	if !ok {
@@ -124,19 +205,88 @@ func (h *HTTPHandler) getUserByID(c *gin.Context) {
 	req := GetUserByIDRequest{ID: c.Param("id")} // Extract ID from param
 	if err := h.validate.Struct(req); err != nil {
 		h.logger.Error("Get user by ID request validation failed", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierr.Abort(c, apierr.Validation(err))
 		return
 	}
 
 	user, err := h.svc.GetUserByID(c.Request.Context(), tenantID, req.ID)
 	if err != nil {
-		h.logger.Error("Get user by ID failed", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierr.Abort(c, serviceError(err))
 		return
 	}
 	c.JSON(http.StatusOK, GetUserByIDResponse{User: user})
 }
 
+// findUsers searches by login when q is given and otherwise looks up an exact email.
+func (h *HTTPHandler) findUsers(c *gin.Context) {
+	if _, ok := c.GetQuery("q"); ok {
+		h.searchUsers(c)
+		return
+	}
+	h.getUserByEmail(c)
+}
+
+func (h *HTTPHandler) searchUsers(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+	req := SearchUsersRequest{Query: strings.TrimSpace(c.Query("q"))}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			apierr.Abort(c, apierr.Invalid("limit must be an integer"))
+			return
+		}
+		req.Limit = n
+	}
+	if err := h.validate.Struct(req); err != nil {
+		h.logger.Error("Search users request validation failed", zap.Error(err))
+		apierr.Abort(c, apierr.Validation(err))
+		return
+	}
+
+	users, err := h.svc.SearchUsers(c.Request.Context(), tenantID, req.Query, req.Limit)
+	if err != nil {
+		apierr.Abort(c, serviceError(err))
+		return
+	}
+	if users == nil {
+		users = []User{}
+	}
+	c.JSON(http.StatusOK, SearchUsersResponse{Users: users})
+}
+
+// listStaleUsers lists active users who have not signed in for the given
+// number of days, e.g. to review them in an access certification.
+func (h *HTTPHandler) listStaleUsers(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+	days, err := strconv.Atoi(c.Query("days"))
+	if err != nil {
+		apierr.Abort(c, apierr.Invalid("days must be an integer"))
+		return
+	}
+	req := ListStaleUsersRequest{Days: days}
+	if err := h.validate.Struct(req); err != nil {
+		h.logger.Error("List stale users request validation failed", zap.Error(err))
+		apierr.Abort(c, apierr.Validation(err))
+		return
+	}
+
+	users, err := h.svc.ListStaleUsers(c.Request.Context(), tenantID, time.Duration(req.Days)*24*time.Hour)
+	if err != nil {
+		apierr.Abort(c, serviceError(err))
+		return
+	}
+	if users == nil {
+		users = []User{}
+	}
+	c.JSON(http.StatusOK, ListStaleUsersResponse{Users: users})
+}
+
 func (h *HTTPHandler) getUserByEmail(c *gin.Context) {
 	tenantID, ok := h.tenantID(c)
 	if !ok {
@@ -145,19 +295,103 @@ func (h *HTTPHandler) getUserByEmail(c *gin.Context) {
 	req := GetUserByEmailRequest{Email: c.Query("email")} // Extract email from query
 	if err := h.validate.Struct(req); err != nil {
 		h.logger.Error("Get user by email request validation failed", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierr.Abort(c, apierr.Validation(err))
 		return
 	}
 
 	user, err := h.svc.GetUserByEmail(c.Request.Context(), tenantID, req.Email)
 	if err != nil {
-		h.logger.Error("Get user by email failed", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierr.Abort(c, serviceError(err))
 		return
 	}
 	c.JSON(http.StatusOK, GetUserByEmailResponse{User: user})
 }
 
+// exportFlushInterval is how many exported users are buffered between flushes.
+const exportFlushInterval = 100
+
+// exportUsers streams every user in the tenant as newline-delimited JSON,
+// gzip-compressed when the client accepts it. Headers are committed with the
+// first user, so an error after that point truncates the stream instead of
+// turning into an error response.
+func (h *HTTPHandler) exportUsers(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+	status := c.Query("status")
+	if status != "" && status != "active" && status != "inactive" && status != "suspended" {
+		apierr.Abort(c, apierr.Invalid("status must be one of active, inactive, suspended"))
+		return
+	}
+
+	var (
+		out     io.Writer
+		gz      *gzip.Writer
+		enc     *json.Encoder
+		written int
+	)
+	start := func() {
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header("Vary", "Accept-Encoding")
+		out = c.Writer
+		if acceptsGzip(c.GetHeader("Accept-Encoding")) {
+			c.Header("Content-Encoding", "gzip")
+			gz = gzip.NewWriter(c.Writer)
+			out = gz
+		}
+		c.Status(http.StatusOK)
+		enc = json.NewEncoder(out)
+	}
+	flush := func() {
+		if gz != nil {
+			_ = gz.Flush()
+		}
+		c.Writer.Flush()
+	}
+
+	err := h.svc.ExportUsers(c.Request.Context(), tenantID, status, func(user User) error {
+		if enc == nil {
+			start()
+		}
+		user.Password = ""
+		if err := enc.Encode(user); err != nil {
+			return err
+		}
+		written++
+		if written%exportFlushInterval == 0 {
+			flush()
+		}
+		return nil
+	})
+	if err != nil {
+		h.logger.Error("Export users failed", zap.Int("written", written), zap.Error(err))
+		if enc == nil {
+			apierr.Abort(c, apierr.Internal(err))
+			return
+		}
+	}
+	if enc == nil {
+		start()
+	}
+	if gz != nil {
+		_ = gz.Close()
+	}
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip.
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		q := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(params), "q="))
+		return strings.Trim(q, "0.") != "" || q == ""
+	}
+	return false
+}
+
 func (h *HTTPHandler) updateUser(c *gin.Context) {
 	tenantID, ok := h.tenantID(c)
 	if !ok {
@@ -167,26 +401,105 @@ func (h *HTTPHandler) updateUser(c *gin.Context) {
 	var user User
 	if err := c.ShouldBindJSON(&user); err != nil {
 		h.logger.Error("Failed to bind update user request", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierr.Abort(c, apierr.Validation(err))
 		return
 	}
 
 	req := UpdateUserRequest{ID: id, User: user} // Create UpdateUserRequest
 	if err := h.validate.Struct(req); err != nil {
 		h.logger.Error("Update user request validation failed", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierr.Abort(c, apierr.Validation(err))
 		return
 	}
 
 	err := h.svc.UpdateUser(c.Request.Context(), tenantID, id, req.User)
 	if err != nil {
-		h.logger.Error("Update user failed", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierr.Abort(c, serviceError(err))
 		return
 	}
 	c.Status(http.StatusOK)
 }
 
+// Photo handlers
+func (h *HTTPHandler) getPhoto(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+	req := GetUserByIDRequest{ID: c.Param("id")}
+	if err := h.validate.Struct(req); err != nil {
+		apierr.Abort(c, apierr.Validation(err))
+		return
+	}
+
+	photo, err := h.photos.GetPhoto(c.Request.Context(), tenantID, req.ID)
+	if err != nil {
+		apierr.Abort(c, serviceError(err))
+		return
+	}
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Header("Last-Modified", photo.UpdatedAt.UTC().Format(http.TimeFormat))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, photo.ContentType, photo.Data)
+}
+
+// putPhoto stores the request body as the user's photo. The body is the raw
+// image and its Content-Type must be one of PhotoContentTypes and match the
+// image data.
+func (h *HTTPHandler) putPhoto(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+	req := GetUserByIDRequest{ID: c.Param("id")}
+	if err := h.validate.Struct(req); err != nil {
+		apierr.Abort(c, apierr.Validation(err))
+		return
+	}
+
+	contentType, _, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if err != nil || !slices.Contains(PhotoContentTypes, contentType) {
+		apierr.Abort(c, apierr.New(http.StatusUnsupportedMediaType, CodeUnsupportedPhotoType,
+			"photo must be one of "+strings.Join(PhotoContentTypes, ", ")))
+		return
+	}
+	if c.Request.ContentLength > h.maxPhotoBytes {
+		apierr.Abort(c, photoTooLarge(h.maxPhotoBytes))
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, h.maxPhotoBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			apierr.Abort(c, photoTooLarge(h.maxPhotoBytes))
+			return
+		}
+		apierr.Abort(c, apierr.Invalid("failed to read photo"))
+		return
+	}
+	if len(data) == 0 {
+		apierr.Abort(c, apierr.Invalid("photo is empty"))
+		return
+	}
+	if sniffed := http.DetectContentType(data); sniffed != contentType {
+		apierr.Abort(c, apierr.New(http.StatusUnsupportedMediaType, CodeUnsupportedPhotoType,
+			fmt.Sprintf("photo data is %s, not %s", sniffed, contentType)))
+		return
+	}
+
+	err = h.photos.PutPhoto(c.Request.Context(), tenantID, req.ID, Photo{ContentType: contentType, Data: data})
+	if err != nil {
+		apierr.Abort(c, serviceError(err))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func photoTooLarge(limit int64) *apierr.Error {
+	return apierr.New(http.StatusRequestEntityTooLarge, CodePhotoTooLarge,
+		fmt.Sprintf("photo must not exceed %d bytes", limit)).WithDetail("max_bytes", limit)
+}
+
 func (h *HTTPHandler) deleteUser(c *gin.Context) {
 	tenantID, ok := h.tenantID(c)
 	if !ok {
@@ -195,14 +508,13 @@ func (h *HTTPHandler) deleteUser(c *gin.Context) {
 	req := DeleteUserRequest{ID: c.Param("id")} // Extract ID from param
 	if err := h.validate.Struct(req); err != nil {
 		h.logger.Error("Delete user request validation failed", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierr.Abort(c, apierr.Validation(err))
 		return
 	}
 
 	err := h.svc.DeleteUser(c.Request.Context(), tenantID, req.ID)
 	if err != nil {
-		h.logger.Error("Delete user failed", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierr.Abort(c, serviceError(err))
 		return
 	}
 	c.Status(http.StatusNoContent)
@@ -217,25 +529,65 @@ func (h *HTTPHandler) createGroup(c *gin.Context) {
 	var req CreateGroupRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		h.logger.Error("Failed to bind create group request", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierr.Abort(c, apierr.Validation(err))
 		return
 	}
 
 	if err := h.validate.Struct(req); err != nil {
 		h.logger.Error("Create group request validation failed", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierr.Abort(c, apierr.Validation(err))
 		return
 	}
 
 	groupID, err := h.svc.CreateGroup(c.Request.Context(), tenantID, req.Group)
 	if err != nil {
-		h.logger.Error("Create group failed", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierr.Abort(c, serviceError(err))
 		return
 	}
 	c.JSON(http.StatusCreated, CreateGroupResponse{GroupID: groupID})
 }
 
+// listGroups returns a page of the tenant's groups ordered by name, with the
+// total and next/prev links in the response headers.
+func (h *HTTPHandler) listGroups(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+	req := ListGroupsRequest{Limit: DefaultGroupPageSize}
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			apierr.Abort(c, apierr.Invalid("limit must be an integer"))
+			return
+		}
+		req.Limit = n
+	}
+	if v := c.Query("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			apierr.Abort(c, apierr.Invalid("offset must be an integer"))
+			return
+		}
+		req.Offset = n
+	}
+	if err := h.validate.Struct(req); err != nil {
+		apierr.Abort(c, apierr.Validation(err))
+		return
+	}
+
+	groups, total, err := h.svc.ListGroups(c.Request.Context(), tenantID, req.Limit, req.Offset, Sort{Field: SortByName})
+	if err != nil {
+		apierr.Abort(c, serviceError(err))
+		return
+	}
+	if groups == nil {
+		groups = []Group{}
+	}
+	middleware.SetPaginationHeaders(c, "", req.Limit, req.Offset, total)
+	c.JSON(http.StatusOK, ListGroupsResponse{Groups: groups, Total: total})
+}
+
 func (h *HTTPHandler) getGroupByID(c *gin.Context) {
 	tenantID, ok := h.tenantID(c)
 	if !ok {
@@ -244,14 +596,13 @@ func (h *HTTPHandler) getGroupByID(c *gin.Context) {
 	req := GetGroupByIDRequest{ID: c.Param("id")} // Extract ID from param
 	if err := h.validate.Struct(req); err != nil {
 		h.logger.Error("Get group by ID request validation failed", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierr.Abort(c, apierr.Validation(err))
 		return
 	}
 
 	group, err := h.svc.GetGroupByID(c.Request.Context(), tenantID, req.ID)
 	if err != nil {
-		h.logger.Error("Get group by ID failed", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierr.Abort(c, serviceError(err))
 		return
 	}
 	c.JSON(http.StatusOK, GetGroupByIDResponse{Group: group})
@@ -266,21 +617,20 @@ func (h *HTTPHandler) updateGroup(c *gin.Context) {
 	var group Group
 	if err := c.ShouldBindJSON(&group); err != nil {
 		h.logger.Error("Failed to bind update group request", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierr.Abort(c, apierr.Validation(err))
 		return
 	}
 
 	req := UpdateGroupRequest{ID: id, Group: group} // Create UpdateGroupRequest
 	if err := h.validate.Struct(req); err != nil {
 		h.logger.Error("Update group request validation failed", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierr.Abort(c, apierr.Validation(err))
 		return
 	}
 
 	err := h.svc.UpdateGroup(c.Request.Context(), tenantID, id, req.Group)
 	if err != nil {
-		h.logger.Error("Update group failed", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierr.Abort(c, serviceError(err))
 		return
 	}
 	c.Status(http.StatusOK)
@@ -294,14 +644,13 @@ func (h *HTTPHandler) deleteGroup(c *gin.Context) {
 	req := DeleteGroupRequest{ID: c.Param("id")} // Extract ID from param
 	if err := h.validate.Struct(req); err != nil {
 		h.logger.Error("Delete group request validation failed", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierr.Abort(c, apierr.Validation(err))
 		return
 	}
 
 	err := h.svc.DeleteGroup(c.Request.Context(), tenantID, req.ID)
 	if err != nil {
-		h.logger.Error("Delete group failed", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierr.Abort(c, serviceError(err))
 		return
 	}
 	c.Status(http.StatusNoContent)
@@ -317,21 +666,20 @@ func (h *HTTPHandler) addUserToGroup(c *gin.Context) {
 	var req AddUserToGroupRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		h.logger.Error("Failed to bind add user to group request", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierr.Abort(c, apierr.Validation(err))
 		return
 	}
 
 	req.GroupID = groupID
 	if err := h.validate.Struct(req); err != nil {
 		h.logger.Error("Add user to group request validation failed", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierr.Abort(c, apierr.Validation(err))
 		return
 	}
 
 	err := h.svc.AddUserToGroup(c.Request.Context(), tenantID, req.UserID, groupID)
 	if err != nil {
-		h.logger.Error("Add user to group failed", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierr.Abort(c, serviceError(err))
 		return
 	}
 	c.Status(http.StatusNoContent)
@@ -346,13 +694,12 @@ func (h *HTTPHandler) removeUserFromGroup(c *gin.Context) {
 	req := RemoveUserFromGroupRequest{GroupID: groupID, UserID: c.Param("userID")} // Create RemoveUserFromGroupRequest
 	if err := h.validate.Struct(req); err != nil {
 		h.logger.Error("Remove user from group request validation failed", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierr.Abort(c, apierr.Validation(err))
 		return
 	}
 	err := h.svc.RemoveUserFromGroup(c.Request.Context(), tenantID, req.UserID, req.GroupID)
 	if err != nil {
-		h.logger.Error("Remove user from group failed", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierr.Abort(c, serviceError(err))
 		return
 	}
 	c.Status(http.StatusNoContent)
@@ -366,41 +713,87 @@ func (h *HTTPHandler) verifyCredentials(c *gin.Context) {
 	var req VerifyCredentialsRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		h.logger.Error("Failed to bind verify credentials request", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierr.Abort(c, apierr.Validation(err))
 		return
 	}
 
 	if err := h.validate.Struct(req); err != nil {
 		h.logger.Error("Verify credentials request validation failed", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierr.Abort(c, apierr.Validation(err))
 		return
 	}
 
 	user, err := h.svc.VerifyCredentials(c.Request.Context(), tenantID, req.Email, req.Password)
 	if err != nil {
-		if errors.Is(err, ErrInvalidCredentials) {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": ErrInvalidCredentials.Error()})
-			return
-		}
-		h.logger.Error("Verify credentials failed", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierr.Abort(c, serviceError(err))
 		return
 	}
 
 	c.JSON(http.StatusOK, VerifyCredentialsResponse{User: user})
 }
 
+func (h *HTTPHandler) setPassword(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+	var req SetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("Failed to bind set password request", zap.Error(err))
+		apierr.Abort(c, apierr.Validation(err))
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		h.logger.Error("Set password request validation failed", zap.Error(err))
+		apierr.Abort(c, apierr.Validation(err))
+		return
+	}
+
+	if err := h.svc.SetPassword(c.Request.Context(), tenantID, req.UserID, req.Password); err != nil {
+		apierr.Abort(c, serviceError(err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *HTTPHandler) recordLogin(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+	var req RecordLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("Failed to bind record login request", zap.Error(err))
+		apierr.Abort(c, apierr.Validation(err))
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		h.logger.Error("Record login request validation failed", zap.Error(err))
+		apierr.Abort(c, apierr.Validation(err))
+		return
+	}
+
+	if err := h.svc.RecordLogin(c.Request.Context(), tenantID, req.UserID); err != nil {
+		apierr.Abort(c, serviceError(err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
 func (h *HTTPHandler) discoverTenant(c *gin.Context) {
 	email := c.Query("email")
 	if email == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "email required"})
+		apierr.Abort(c, apierr.Invalid("email required"))
 		return
 	}
 
 	tenantID, err := h.svc.GetTenantByEmail(c.Request.Context(), email)
 	if err != nil {
-		h.logger.Error("Discover tenant failed", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierr.Abort(c, serviceError(err))
 		return
 	}
 	if tenantID == "" {
@@ -411,11 +804,42 @@ func (h *HTTPHandler) discoverTenant(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"tenant_id": tenantID})
 }
 
+// CodeWeakPassword is the API error code of a password rejected by the
+// password policy; the failed rule is rendered under "rule".
+const CodeWeakPassword apierr.Code = "weak_password"
+
+// Photo upload error codes.
+const (
+	CodePhotoTooLarge        apierr.Code = "photo_too_large"
+	CodeUnsupportedPhotoType apierr.Code = "unsupported_photo_type"
+)
+
+// serviceError maps directory service errors onto API errors. Errors it does
+// not recognise render as internal errors.
+func serviceError(err error) error {
+	var weak *WeakPasswordError
+	switch {
+	case errors.As(err, &weak):
+		return apierr.New(http.StatusBadRequest, CodeWeakPassword, weak.Error()).WithDetail("rule", weak.Rule).Wrap(err)
+	case errors.Is(err, ErrUserNotFound):
+		return apierr.NotFound(ErrUserNotFound.Error()).Wrap(err)
+	case errors.Is(err, ErrPhotoNotFound):
+		return apierr.NotFound(ErrPhotoNotFound.Error()).Wrap(err)
+	case errors.Is(err, ErrInvalidManager):
+		return apierr.Invalid(ErrInvalidManager.Error()).Wrap(err)
+	case errors.Is(err, ErrInvalidCredentials):
+		return apierr.Unauthorized(ErrInvalidCredentials.Error()).Wrap(err)
+	case errors.Is(err, ErrAmbiguousTenant):
+		return apierr.Conflict(ErrAmbiguousTenant.Error()).Wrap(err)
+	}
+	return err
+}
+
 func (h *HTTPHandler) tenantID(c *gin.Context) (string, bool) {
 	tenantID, err := middleware.TenantIDFromGinContext(c)
 	if err != nil {
 		h.logger.Error("tenant id missing", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": "tenant id required"})
+		apierr.Abort(c, apierr.Invalid("tenant id required"))
 		return "", false
 	}
 	return tenantID, true
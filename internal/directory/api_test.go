@@ -1,13 +1,20 @@
 package directory
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/dhawalhost/wardseal/pkg/apierr"
 	"github.com/dhawalhost/wardseal/pkg/middleware"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -24,6 +31,7 @@ func TestCreateUserUsesTenantHeader(t *testing.T) {
 	svc := &mockDirectoryService{createUserID: "user-123"}
 	handler := newHandler(svc)
 	r := gin.New()
+	r.Use(apierr.Handler(zap.NewNop()))
 	handler.RegisterRoutes(r)
 
 	body := strings.NewReader(`{"user":{"email":"user@wardseal.com","password":"password123","status":"active"}}`)
@@ -43,11 +51,47 @@ func TestCreateUserUsesTenantHeader(t *testing.T) {
 	}
 }
 
+func TestCreateUserAcceptsProfileAttributes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc := &mockDirectoryService{createUserID: "user-123"}
+	handler := newHandler(svc)
+	r := gin.New()
+	r.Use(apierr.Handler(zap.NewNop()))
+	handler.RegisterRoutes(r)
+
+	body := strings.NewReader(`{"user":{"email":"user@wardseal.com","password":"password123","status":"active",
+		"phone":"+1 555 0100","title":"Engineer","department":"R&D",
+		"manager_id":"33333333-3333-3333-3333-333333333333","location":"London"}}`)
+	req := httptest.NewRequest(http.MethodPost, "/users", body)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(middleware.DefaultTenantHeader, "22222222-2222-2222-2222-222222222222")
+	resp := httptest.NewRecorder()
+
+	r.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", resp.Code, resp.Body.String())
+	}
+	want := Profile{Phone: "+1 555 0100", Title: "Engineer", Department: "R&D", ManagerID: "33333333-3333-3333-3333-333333333333", Location: "London"}
+	if svc.lastUser.Profile != want {
+		t.Fatalf("expected profile %+v, got %+v", want, svc.lastUser.Profile)
+	}
+
+	out, err := json.Marshal(svc.lastUser)
+	if err != nil {
+		t.Fatalf("encode user: %v", err)
+	}
+	if !strings.Contains(string(out), `"title":"Engineer"`) || !strings.Contains(string(out), `"manager_id":"33333333-3333-3333-3333-333333333333"`) {
+		t.Fatalf("expected profile attributes at the top level of the user JSON, got %s", out)
+	}
+}
+
 func TestCreateUserMissingTenantHeader(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	svc := &mockDirectoryService{createUserID: "user-123"}
 	handler := newHandler(svc)
 	r := gin.New()
+	r.Use(apierr.Handler(zap.NewNop()))
 	handler.RegisterRoutes(r)
 
 	body := strings.NewReader(`{"user":{"email":"user@wardseal.com","password":"password123","status":"active"}}`)
@@ -66,12 +110,148 @@ func TestCreateUserMissingTenantHeader(t *testing.T) {
 	}
 }
 
+func TestCreateUserRendersServiceErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cases := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantBody   map[string]string
+	}{
+		{
+			name:       "weak password",
+			err:        &WeakPasswordError{Rule: "min_length", Message: "password is too short"},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   map[string]string{"code": string(CodeWeakPassword), "rule": "min_length"},
+		},
+		{
+			name:       "invalid manager",
+			err:        ErrInvalidManager,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   map[string]string{"code": string(apierr.CodeInvalid)},
+		},
+		{
+			name:       "internal",
+			err:        fmt.Errorf("insert user: %w", io.ErrUnexpectedEOF),
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   map[string]string{"code": string(apierr.CodeInternal), "error": "internal server error"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := newHandler(&mockDirectoryService{createUserErr: tc.err})
+			r := gin.New()
+			r.Use(apierr.Handler(zap.NewNop()))
+			handler.RegisterRoutes(r)
+
+			body := strings.NewReader(`{"user":{"email":"user@wardseal.com","password":"password123","status":"active"}}`)
+			req := httptest.NewRequest(http.MethodPost, "/users", body)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(middleware.DefaultTenantHeader, "22222222-2222-2222-2222-222222222222")
+			resp := httptest.NewRecorder()
+
+			r.ServeHTTP(resp, req)
+
+			if resp.Code != tc.wantStatus {
+				t.Fatalf("expected %d, got %d", tc.wantStatus, resp.Code)
+			}
+			var got map[string]string
+			if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+			for key, want := range tc.wantBody {
+				if got[key] != want {
+					t.Fatalf("expected %s %q, got %q", key, want, got[key])
+				}
+			}
+		})
+	}
+}
+
+func TestCreateUserReportsInvalidFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc := &mockDirectoryService{createUserID: "user-123"}
+	handler := newHandler(svc)
+	r := gin.New()
+	r.Use(apierr.Handler(zap.NewNop()))
+	handler.RegisterRoutes(r)
+
+	body := strings.NewReader(`{"user":{"password":"password123","status":"active"}}`)
+	req := httptest.NewRequest(http.MethodPost, "/users", body)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(middleware.DefaultTenantHeader, "22222222-2222-2222-2222-222222222222")
+	resp := httptest.NewRecorder()
+
+	r.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", resp.Code)
+	}
+	var got struct {
+		Error  string              `json:"error"`
+		Code   string              `json:"code"`
+		Fields []apierr.FieldError `json:"fields"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	want := apierr.FieldError{Field: "user.email", Rule: "required", Message: "user.email is required"}
+	if got.Code != string(apierr.CodeInvalid) || len(got.Fields) != 1 || got.Fields[0] != want {
+		t.Fatalf("expected %+v, got %s", want, resp.Body.String())
+	}
+	if strings.Contains(resp.Body.String(), "CreateUserRequest") {
+		t.Fatalf("expected no Go type names in the response, got %s", resp.Body.String())
+	}
+	if svc.createUserCalled {
+		t.Fatalf("service should not be called for an invalid request")
+	}
+}
+
+func TestListGroupsSetsPaginationHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name     string
+		query    string
+		wantLink string
+	}{
+		{"more results", "?limit=2", `</groups?limit=2&offset=2>; rel="next"`},
+		{"last page", "?limit=2&offset=2", `</groups?limit=2&offset=0>; rel="prev"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &mockDirectoryService{groups: []Group{{Name: "a"}, {Name: "b"}}, groupsTotal: 4}
+			r := gin.New()
+			r.Use(apierr.Handler(zap.NewNop()))
+			newHandler(svc).RegisterRoutes(r)
+
+			req := httptest.NewRequest(http.MethodGet, "/groups"+tt.query, nil)
+			req.Header.Set(middleware.DefaultTenantHeader, "22222222-2222-2222-2222-222222222222")
+			resp := httptest.NewRecorder()
+			r.ServeHTTP(resp, req)
+
+			if resp.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %s", resp.Code, resp.Body.String())
+			}
+			if got := resp.Header().Get("Link"); got != tt.wantLink {
+				t.Fatalf("expected Link %q, got %q", tt.wantLink, got)
+			}
+			if got := resp.Header().Get(middleware.TotalCountHeader); got != "4" {
+				t.Fatalf("expected total 4, got %q", got)
+			}
+			if svc.groupsLimit != 2 {
+				t.Fatalf("expected limit 2, got %d", svc.groupsLimit)
+			}
+		})
+	}
+}
+
 func TestVerifyCredentialsUsesTenantHeader(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	user := User{ID: "user-123", Email: "user@wardseal.com", Status: "active"}
 	svc := &mockDirectoryService{verifyReturnUser: user}
 	handler := newHandler(svc)
 	r := gin.New()
+	r.Use(apierr.Handler(zap.NewNop()))
 	handler.RegisterRoutes(r)
 
 	body := strings.NewReader(`{"email":"user@wardseal.com","password":"password123"}`)
@@ -109,6 +289,7 @@ func TestVerifyCredentialsMissingTenantHeader(t *testing.T) {
 	svc := &mockDirectoryService{}
 	handler := newHandler(svc)
 	r := gin.New()
+	r.Use(apierr.Handler(zap.NewNop()))
 	handler.RegisterRoutes(r)
 
 	body := strings.NewReader(`{"email":"user@wardseal.com","password":"password123"}`)
@@ -133,6 +314,7 @@ func TestVerifyCredentialsUnauthorized(t *testing.T) {
 	svc := &mockDirectoryService{verifyErr: ErrInvalidCredentials}
 	handler := newHandler(svc)
 	r := gin.New()
+	r.Use(apierr.Handler(zap.NewNop()))
 	handler.RegisterRoutes(r)
 
 	body := strings.NewReader(`{"email":"user@wardseal.com","password":"badpassword"}`)
@@ -154,6 +336,7 @@ func TestVerifyCredentialsMissingServiceToken(t *testing.T) {
 	svc := &mockDirectoryService{}
 	handler := newHandler(svc)
 	r := gin.New()
+	r.Use(apierr.Handler(zap.NewNop()))
 	handler.RegisterRoutes(r)
 
 	body := strings.NewReader(`{"email":"user@wardseal.com","password":"password123"}`)
@@ -169,6 +352,255 @@ func TestVerifyCredentialsMissingServiceToken(t *testing.T) {
 	}
 }
 
+func TestCreateUsersBatchReportsPerItemResults(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc := &mockDirectoryService{}
+	handler := newHandler(svc)
+	r := gin.New()
+	r.Use(apierr.Handler(zap.NewNop()))
+	handler.RegisterRoutes(r)
+
+	body := strings.NewReader(`{"users":[
+		{"email":"one@wardseal.com","password":"Password123","status":"active"},
+		{"email":"taken@wardseal.com","password":"Password123","status":"active"},
+		{"email":"two@wardseal.com","password":"Password123","status":"active"}]}`)
+	req := httptest.NewRequest(http.MethodPost, "/users/batch", body)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(middleware.DefaultTenantHeader, "22222222-2222-2222-2222-222222222222")
+	resp := httptest.NewRecorder()
+
+	r.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var payload BatchCreateUsersResponse
+	if err := json.Unmarshal(resp.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if payload.Created != 2 || payload.Failed != 1 || len(payload.Results) != 3 {
+		t.Fatalf("unexpected batch summary: %+v", payload)
+	}
+	if payload.Results[1].Error == "" || payload.Results[1].UserID != "" || payload.Results[2].UserID != "user-2" {
+		t.Fatalf("unexpected per-item results: %+v", payload.Results)
+	}
+}
+
+func TestCreateUsersBatchRejectsOversizedBatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := newHandler(&mockDirectoryService{})
+	r := gin.New()
+	r.Use(apierr.Handler(zap.NewNop()))
+	handler.RegisterRoutes(r)
+
+	users := make([]User, MaxBatchSize+1)
+	body, _ := json.Marshal(BatchCreateUsersRequest{Users: users})
+	req := httptest.NewRequest(http.MethodPost, "/users/batch", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(middleware.DefaultTenantHeader, "22222222-2222-2222-2222-222222222222")
+	resp := httptest.NewRecorder()
+
+	r.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", resp.Code)
+	}
+}
+
+func TestExportUsersStreamsNDJSONWithoutPasswords(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc := &mockDirectoryService{exportUsers: []User{
+		{ID: "user-1", Email: "one@wardseal.com", Password: "Password123", Status: "active"},
+		{ID: "user-2", Email: "two@wardseal.com", Password: "Password123", Status: "active"},
+		{ID: "user-3", Email: "three@wardseal.com", Status: "active"},
+	}}
+	handler := newHandler(svc)
+	r := gin.New()
+	r.Use(apierr.Handler(zap.NewNop()))
+	handler.RegisterRoutes(r)
+
+	for _, encoding := range []string{"", "gzip"} {
+		req := httptest.NewRequest(http.MethodGet, "/users/export?status=active", nil)
+		req.Header.Set(middleware.DefaultTenantHeader, "22222222-2222-2222-2222-222222222222")
+		if encoding != "" {
+			req.Header.Set("Accept-Encoding", encoding)
+		}
+		resp := httptest.NewRecorder()
+
+		r.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", resp.Code, resp.Body.String())
+		}
+		if ct := resp.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+			t.Fatalf("unexpected content type %q", ct)
+		}
+		if got := resp.Header().Get("Content-Encoding"); got != encoding {
+			t.Fatalf("expected content encoding %q, got %q", encoding, got)
+		}
+		var body io.Reader = resp.Body
+		if encoding == "gzip" {
+			gz, err := gzip.NewReader(resp.Body)
+			if err != nil {
+				t.Fatalf("failed to open gzip stream: %v", err)
+			}
+			body = gz
+		}
+
+		scanner := bufio.NewScanner(body)
+		count := 0
+		for scanner.Scan() {
+			line := scanner.Text()
+			if strings.Contains(line, "password") {
+				t.Fatalf("export leaked a password field: %s", line)
+			}
+			var user User
+			if err := json.Unmarshal([]byte(line), &user); err != nil {
+				t.Fatalf("invalid NDJSON line %q: %v", line, err)
+			}
+			count++
+		}
+		if err := scanner.Err(); err != nil {
+			t.Fatalf("failed to read export: %v", err)
+		}
+		if count != 3 {
+			t.Fatalf("expected 3 users, got %d", count)
+		}
+	}
+	if svc.exportStatus != "active" {
+		t.Fatalf("expected status filter to reach the service, got %q", svc.exportStatus)
+	}
+}
+
+func TestExportUsersRejectsUnknownStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := newHandler(&mockDirectoryService{})
+	r := gin.New()
+	r.Use(apierr.Handler(zap.NewNop()))
+	handler.RegisterRoutes(r)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/export?status=deleted", nil)
+	req.Header.Set(middleware.DefaultTenantHeader, "22222222-2222-2222-2222-222222222222")
+	resp := httptest.NewRecorder()
+
+	r.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", resp.Code)
+	}
+}
+
+func TestFindUsersSearchesWhenQueryGiven(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc := &mockDirectoryService{searchUsers: []User{{ID: "user-1", Email: "alice@wardseal.com", Status: "active"}}}
+	handler := newHandler(svc)
+	r := gin.New()
+	r.Use(apierr.Handler(zap.NewNop()))
+	handler.RegisterRoutes(r)
+
+	req := httptest.NewRequest(http.MethodGet, "/users?q=alice@&limit=10", nil)
+	req.Header.Set(middleware.DefaultTenantHeader, "22222222-2222-2222-2222-222222222222")
+	resp := httptest.NewRecorder()
+
+	r.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if svc.searchQuery != "alice@" || svc.searchLimit != 10 {
+		t.Fatalf("unexpected search arguments %q, %d", svc.searchQuery, svc.searchLimit)
+	}
+	var payload SearchUsersResponse
+	if err := json.Unmarshal(resp.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(payload.Users) != 1 || payload.Users[0].Email != "alice@wardseal.com" {
+		t.Fatalf("unexpected search results: %+v", payload.Users)
+	}
+}
+
+func TestListStaleUsersConvertsDays(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	lastLogin := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	svc := &mockDirectoryService{staleUsers: []User{{ID: "user-1", Email: "idle@wardseal.com", Status: "active", LastLoginAt: &lastLogin}}}
+	handler := newHandler(svc)
+	r := gin.New()
+	r.Use(apierr.Handler(zap.NewNop()))
+	handler.RegisterRoutes(r)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/stale?days=90", nil)
+	req.Header.Set(middleware.DefaultTenantHeader, "22222222-2222-2222-2222-222222222222")
+	resp := httptest.NewRecorder()
+
+	r.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if svc.staleOlderThan != 90*24*time.Hour {
+		t.Fatalf("expected 90 days, got %v", svc.staleOlderThan)
+	}
+	var payload ListStaleUsersResponse
+	if err := json.Unmarshal(resp.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(payload.Users) != 1 || payload.Users[0].LastLoginAt == nil || !payload.Users[0].LastLoginAt.Equal(lastLogin) {
+		t.Fatalf("unexpected stale users: %+v", payload.Users)
+	}
+}
+
+func TestListStaleUsersRejectsInvalidDays(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := newHandler(&mockDirectoryService{})
+	r := gin.New()
+	r.Use(apierr.Handler(zap.NewNop()))
+	handler.RegisterRoutes(r)
+
+	for _, query := range []string{"", "?days=soon", "?days=0", "?days=-5"} {
+		req := httptest.NewRequest(http.MethodGet, "/users/stale"+query, nil)
+		req.Header.Set(middleware.DefaultTenantHeader, "22222222-2222-2222-2222-222222222222")
+		resp := httptest.NewRecorder()
+
+		r.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected 400, got %d", query, resp.Code)
+		}
+	}
+}
+
+func TestRecordLoginRequiresServiceToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc := &mockDirectoryService{}
+	handler := newHandler(svc)
+	r := gin.New()
+	r.Use(apierr.Handler(zap.NewNop()))
+	handler.RegisterRoutes(r)
+
+	body := `{"user_id":"33333333-3333-3333-3333-333333333333"}`
+	for _, token := range []string{"", testServiceToken} {
+		req := httptest.NewRequest(http.MethodPost, "/internal/credentials/login", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(middleware.DefaultTenantHeader, "22222222-2222-2222-2222-222222222222")
+		if token != "" {
+			req.Header.Set(middleware.DefaultServiceAuthHeader, token)
+		}
+		resp := httptest.NewRecorder()
+
+		r.ServeHTTP(resp, req)
+
+		if token == "" && resp.Code != http.StatusUnauthorized {
+			t.Fatalf("expected 401 without a service token, got %d", resp.Code)
+		}
+		if token != "" && resp.Code != http.StatusNoContent {
+			t.Fatalf("expected 204, got %d: %s", resp.Code, resp.Body.String())
+		}
+	}
+	if svc.recordedLogin != "33333333-3333-3333-3333-333333333333" {
+		t.Fatalf("expected the login to be recorded once authorized, got %q", svc.recordedLogin)
+	}
+}
+
 type mockDirectoryService struct {
 	createUserID            string
 	createUserErr           error
@@ -179,6 +611,18 @@ type mockDirectoryService struct {
 	verifyReturnUser        User
 	verifyTenantID          string
 	verifyCredentialsCalled bool
+	exportUsers             []User
+	exportStatus            string
+	searchUsers             []User
+	searchQuery             string
+	searchLimit             int
+	staleUsers              []User
+	staleOlderThan          time.Duration
+	recordedLogin           string
+	groups                  []Group
+	groupsTotal             int
+	groupsLimit             int
+	groupsOffset            int
 }
 
 func (m *mockDirectoryService) HealthCheck(context.Context) (bool, error) {
@@ -200,14 +644,40 @@ func (m *mockDirectoryService) GetUserByEmail(context.Context, string, string) (
 	return User{}, nil
 }
 
-func (m *mockDirectoryService) ListUsers(context.Context, string, int, int) ([]User, int, error) {
+func (m *mockDirectoryService) ListUsers(context.Context, string, int, int, Sort) ([]User, int, error) {
 	return []User{}, 0, nil
 }
 
+func (m *mockDirectoryService) ListUsersAfter(context.Context, string, string, int) ([]User, string, error) {
+	return nil, "", nil
+}
+
+func (m *mockDirectoryService) SearchUsers(ctx context.Context, tenantID, query string, limit int) ([]User, error) {
+	m.lastTenantID = tenantID
+	m.searchQuery = query
+	m.searchLimit = limit
+	return m.searchUsers, nil
+}
+
+func (m *mockDirectoryService) ExportUsers(ctx context.Context, tenantID, status string, fn func(User) error) error {
+	m.lastTenantID = tenantID
+	m.exportStatus = status
+	for _, user := range m.exportUsers {
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (m *mockDirectoryService) UpdateUser(context.Context, string, string, User) error {
 	return nil
 }
 
+func (m *mockDirectoryService) ReplaceProfile(context.Context, string, string, Profile) error {
+	return nil
+}
+
 func (m *mockDirectoryService) DeleteUser(context.Context, string, string) error {
 	return nil
 }
@@ -220,8 +690,9 @@ func (m *mockDirectoryService) GetGroupByID(context.Context, string, string) (Gr
 	return Group{}, nil
 }
 
-func (m *mockDirectoryService) ListGroups(context.Context, string, int, int) ([]Group, int, error) {
-	return []Group{}, 0, nil
+func (m *mockDirectoryService) ListGroups(ctx context.Context, tenantID string, limit, offset int, sort Sort) ([]Group, int, error) {
+	m.groupsLimit, m.groupsOffset = limit, offset
+	return m.groups, m.groupsTotal, nil
 }
 
 func (m *mockDirectoryService) UpdateGroup(context.Context, string, string, Group) error {
@@ -240,6 +711,10 @@ func (m *mockDirectoryService) RemoveUserFromGroup(context.Context, string, stri
 	return nil
 }
 
+func (m *mockDirectoryService) ListGroupMemberIDs(context.Context, string, string) ([]string, error) {
+	return nil, nil
+}
+
 func (m *mockDirectoryService) VerifyCredentials(ctx context.Context, tenantID, email, password string) (User, error) {
 	m.verifyCredentialsCalled = true
 	m.verifyTenantID = tenantID
@@ -250,6 +725,161 @@ func (m *mockDirectoryService) VerifyCredentials(ctx context.Context, tenantID,
 	return m.verifyReturnUser, nil
 }
 
+func (m *mockDirectoryService) CreateUsers(ctx context.Context, tenantID string, users []User) ([]BatchCreateResult, error) {
+	m.lastTenantID = tenantID
+	results := make([]BatchCreateResult, len(users))
+	for i, user := range users {
+		results[i].Index = i
+		if user.Email == "taken@wardseal.com" {
+			results[i].Error = "duplicate login"
+			continue
+		}
+		results[i].UserID = fmt.Sprintf("user-%d", i)
+	}
+	return results, nil
+}
+
+func (m *mockDirectoryService) SetPassword(context.Context, string, string, string) error {
+	return nil
+}
+
+func (m *mockDirectoryService) RecordLogin(ctx context.Context, tenantID, userID string) error {
+	m.recordedLogin = userID
+	return nil
+}
+
+func (m *mockDirectoryService) ListStaleUsers(ctx context.Context, tenantID string, olderThan time.Duration) ([]User, error) {
+	m.lastTenantID = tenantID
+	m.staleOlderThan = olderThan
+	return m.staleUsers, nil
+}
+
 func (m *mockDirectoryService) GetTenantByEmail(context.Context, string) (string, error) {
 	return "22222222-2222-2222-2222-222222222222", nil
 }
+
+// memPhotos is an in-memory PhotoStore that knows one user.
+type memPhotos struct {
+	userID string
+	photos map[string]Photo
+}
+
+func (m *memPhotos) GetPhoto(ctx context.Context, tenantID, userID string) (Photo, error) {
+	photo, ok := m.photos[tenantID+"/"+userID]
+	if !ok {
+		return Photo{}, ErrPhotoNotFound
+	}
+	return photo, nil
+}
+
+func (m *memPhotos) PutPhoto(ctx context.Context, tenantID, userID string, photo Photo) error {
+	if userID != m.userID {
+		return ErrUserNotFound
+	}
+	photo.UpdatedAt = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	m.photos[tenantID+"/"+userID] = photo
+	return nil
+}
+
+func photoRequest(r *gin.Engine, method, userID, contentType string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/users/"+userID+"/photo", bytes.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set(middleware.DefaultTenantHeader, "22222222-2222-2222-2222-222222222222")
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	return resp
+}
+
+func TestUserPhotoUploadAndRetrieval(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const userID = "33333333-3333-3333-3333-333333333333"
+	photos := &memPhotos{userID: userID, photos: map[string]Photo{}}
+	handler := NewHTTPHandler(&mockDirectoryService{}, zap.NewNop(), HTTPHandlerConfig{Photos: photos, MaxPhotoBytes: 64})
+	r := gin.New()
+	r.Use(apierr.Handler(zap.NewNop()))
+	handler.RegisterRoutes(r)
+
+	if resp := photoRequest(r, http.MethodGet, userID, "", nil); resp.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 before upload, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 24)...)
+	if resp := photoRequest(r, http.MethodPut, userID, "image/png", png); resp.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	resp := photoRequest(r, http.MethodGet, userID, "", nil)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if ct := resp.Header().Get("Content-Type"); ct != "image/png" {
+		t.Fatalf("expected image/png, got %q", ct)
+	}
+	if resp.Header().Get("Last-Modified") != "Fri, 02 Jan 2026 03:04:05 GMT" {
+		t.Fatalf("unexpected Last-Modified %q", resp.Header().Get("Last-Modified"))
+	}
+	if !bytes.Equal(resp.Body.Bytes(), png) {
+		t.Fatalf("expected the uploaded bytes back, got %d bytes", resp.Body.Len())
+	}
+
+	if resp := photoRequest(r, http.MethodPut, "44444444-4444-4444-4444-444444444444", "image/png", png); resp.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown user, got %d", resp.Code)
+	}
+	if resp := photoRequest(r, http.MethodGet, "not-a-uuid", "", nil); resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a malformed ID, got %d", resp.Code)
+	}
+}
+
+func TestUserPhotoUploadRejectsInvalidPhotos(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const userID = "33333333-3333-3333-3333-333333333333"
+	photos := &memPhotos{userID: userID, photos: map[string]Photo{}}
+	handler := NewHTTPHandler(&mockDirectoryService{}, zap.NewNop(), HTTPHandlerConfig{Photos: photos, MaxPhotoBytes: 64})
+	r := gin.New()
+	r.Use(apierr.Handler(zap.NewNop()))
+	handler.RegisterRoutes(r)
+
+	png := []byte("\x89PNG\r\n\x1a\n")
+	cases := []struct {
+		name        string
+		contentType string
+		body        []byte
+		wantStatus  int
+		wantCode    apierr.Code
+	}{
+		{"too large", "image/png", append(png, make([]byte, 64)...), http.StatusRequestEntityTooLarge, CodePhotoTooLarge},
+		{"not an image type", "text/html", []byte("<html></html>"), http.StatusUnsupportedMediaType, CodeUnsupportedPhotoType},
+		{"missing type", "", png, http.StatusUnsupportedMediaType, CodeUnsupportedPhotoType},
+		{"data does not match type", "image/jpeg", png, http.StatusUnsupportedMediaType, CodeUnsupportedPhotoType},
+		{"empty", "image/png", nil, http.StatusBadRequest, apierr.CodeInvalid},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := photoRequest(r, http.MethodPut, userID, tc.contentType, tc.body)
+			if resp.Code != tc.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tc.wantStatus, resp.Code, resp.Body.String())
+			}
+			var got map[string]any
+			if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+			if got["code"] != string(tc.wantCode) {
+				t.Fatalf("expected code %q, got %v", tc.wantCode, got["code"])
+			}
+		})
+	}
+	if len(photos.photos) != 0 {
+		t.Fatalf("expected no photo to be stored, got %d", len(photos.photos))
+	}
+}
+
+func TestPhotoRoutesNeedAStore(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	newHandler(&mockDirectoryService{}).RegisterRoutes(r)
+	if resp := photoRequest(r, http.MethodGet, "33333333-3333-3333-3333-333333333333", "", nil); resp.Code != http.StatusNotFound {
+		t.Fatalf("expected no photo route without a store, got %d", resp.Code)
+	}
+}
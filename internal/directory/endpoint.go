@@ -4,7 +4,6 @@ import (
 	"time"
 )
 
-// User represents a user in the system.
 // User represents a user in the system.
 type User struct {
 	ID        string    `json:"id,omitempty" db:"id" validate:"omitempty,uuid"`
@@ -14,6 +13,26 @@ type User struct {
 	Status    string    `json:"status,omitempty" db:"status" validate:"required,oneof=active inactive suspended"`
 	CreatedAt time.Time `json:"created_at,omitempty" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at,omitempty" db:"updated_at"`
+	// LastLoginAt is when the user last signed in, nil if never. It is
+	// recorded at most once per ServiceConfig.LoginRecordInterval.
+	LastLoginAt *time.Time `json:"last_login_at,omitempty" db:"last_login_at"`
+	Profile
+}
+
+// Profile holds a user's directory attributes. Its fields are flattened into
+// the user's JSON; empty fields are left unchanged by UpdateUser.
+type Profile struct {
+	Phone      string `json:"phone,omitempty" db:"phone" validate:"omitempty,max=64"`
+	Title      string `json:"title,omitempty" db:"title" validate:"omitempty,max=255"`
+	Department string `json:"department,omitempty" db:"department" validate:"omitempty,max=255"`
+	// ManagerID is the ID of another user in the same tenant.
+	ManagerID string `json:"manager_id,omitempty" db:"manager_id" validate:"omitempty,uuid"`
+	Location  string `json:"location,omitempty" db:"location" validate:"omitempty,max=255"`
+}
+
+// isEmpty reports whether no profile attribute is set.
+func (p Profile) isEmpty() bool {
+	return p == Profile{}
 }
 
 // Group represents a group in the system.
@@ -40,6 +59,26 @@ type CreateUserResponse struct {
 	UserID string `json:"user_id"`
 }
 
+// BatchCreateUsersRequest holds the request parameters for the batch CreateUsers endpoint.
+type BatchCreateUsersRequest struct {
+	Users []User `json:"users"`
+}
+
+// BatchCreateResult reports the outcome for one user of a batch, by its
+// position in the request: the created ID or why it was not created.
+type BatchCreateResult struct {
+	Index  int    `json:"index"`
+	UserID string `json:"user_id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BatchCreateUsersResponse holds the response values for the batch CreateUsers endpoint.
+type BatchCreateUsersResponse struct {
+	Results []BatchCreateResult `json:"results"`
+	Created int                 `json:"created"`
+	Failed  int                 `json:"failed"`
+}
+
 // GetUserByEmailRequest holds the request parameters for the GetUserByEmail endpoint.
 type GetUserByEmailRequest struct {
 	Email string `json:"email" validate:"required,email"`
@@ -50,6 +89,28 @@ type GetUserByEmailResponse struct {
 	User User `json:"user"`
 }
 
+// SearchUsersRequest holds the request parameters for the SearchUsers endpoint.
+type SearchUsersRequest struct {
+	Query string `json:"q" validate:"required"`
+	Limit int    `json:"limit" validate:"omitempty,min=1,max=100"`
+}
+
+// SearchUsersResponse holds the response values for the SearchUsers endpoint.
+type SearchUsersResponse struct {
+	Users []User `json:"users"`
+}
+
+// ListStaleUsersRequest holds the request parameters for the ListStaleUsers endpoint.
+type ListStaleUsersRequest struct {
+	// Days is how long a user must not have signed in to be listed.
+	Days int `json:"days" validate:"required,min=1,max=3650"`
+}
+
+// ListStaleUsersResponse holds the response values for the ListStaleUsers endpoint.
+type ListStaleUsersResponse struct {
+	Users []User `json:"users"`
+}
+
 // GetUserByIDRequest holds the request parameters for the GetUserByID endpoint.
 type GetUserByIDRequest struct {
 	ID string `json:"id" validate:"required,uuid"`
@@ -87,6 +148,21 @@ type CreateGroupResponse struct {
 	GroupID string `json:"group_id"`
 }
 
+// DefaultGroupPageSize is the page size of ListGroups when no limit is given.
+const DefaultGroupPageSize = 50
+
+// ListGroupsRequest holds the request parameters for the ListGroups endpoint.
+type ListGroupsRequest struct {
+	Limit  int `json:"limit" validate:"min=1,max=100"`
+	Offset int `json:"offset" validate:"min=0"`
+}
+
+// ListGroupsResponse holds the response values for the ListGroups endpoint.
+type ListGroupsResponse struct {
+	Groups []Group `json:"groups"`
+	Total  int     `json:"total"`
+}
+
 // GetGroupByIDRequest holds the request parameters for the GetGroupByID endpoint.
 type GetGroupByIDRequest struct {
 	ID string `json:"id" validate:"required,uuid"`
@@ -142,3 +218,15 @@ type VerifyCredentialsRequest struct {
 type VerifyCredentialsResponse struct {
 	User User `json:"user"`
 }
+
+// RecordLoginRequest holds the request parameters for recording a login made
+// without a password.
+type RecordLoginRequest struct {
+	UserID string `json:"user_id" validate:"required,uuid"`
+}
+
+// SetPasswordRequest holds the request parameters for replacing a user's password.
+type SetPasswordRequest struct {
+	UserID   string `json:"user_id" validate:"required,uuid"`
+	Password string `json:"password" validate:"required,min=8"`
+}
@@ -0,0 +1,94 @@
+package directory
+
+import (
+	"context"
+	"crypto/sha1" //nolint:gosec // G505: matches the Pwned Passwords range API.
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type stubBreachChecker struct {
+	breached map[string]bool
+}
+
+func (c stubBreachChecker) IsBreached(ctx context.Context, password string) (bool, error) {
+	return c.breached[password], nil
+}
+
+func TestPasswordPolicyRules(t *testing.T) {
+	policy := PasswordPolicy{
+		MinLength:     10,
+		RequireUpper:  true,
+		RequireLower:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+		BreachChecker: stubBreachChecker{breached: map[string]bool{"Password123!": true}},
+	}
+
+	tests := []struct {
+		password string
+		rule     string
+	}{
+		{"Ab1!", PasswordRuleMinLength},
+		{"lowercase1!", PasswordRuleUppercase},
+		{"UPPERCASE1!", PasswordRuleLowercase},
+		{"NoDigitsHere!", PasswordRuleDigit},
+		{"NoSymbols123", PasswordRuleSymbol},
+		{"Password123!", PasswordRuleBreached},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			err := policy.Validate(context.Background(), tt.password)
+			if !errors.Is(err, ErrWeakPassword) {
+				t.Fatalf("expected ErrWeakPassword, got %v", err)
+			}
+			var weak *WeakPasswordError
+			if !errors.As(err, &weak) || weak.Rule != tt.rule {
+				t.Fatalf("expected rule %q, got %v", tt.rule, err)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyAcceptsStrongPassword(t *testing.T) {
+	policy := DefaultPasswordPolicy()
+	policy.RequireSymbol = true
+	policy.BreachChecker = stubBreachChecker{}
+	if err := policy.Validate(context.Background(), "Correct-Horse-42"); err != nil {
+		t.Fatalf("expected password to pass, got %v", err)
+	}
+}
+
+func TestHIBPCheckerSendsOnlyHashPrefix(t *testing.T) {
+	sum := sha1.Sum([]byte("hunter2")) //nolint:gosec // G401: see import comment.
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	var requestedPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedPath = r.URL.Path
+		_, _ = fmt.Fprintf(w, "0000000000000000000000000000000000A:0\r\n%s:42\r\n", hash[5:])
+	}))
+	defer server.Close()
+
+	checker := &HIBPChecker{BaseURL: server.URL, HTTPClient: server.Client()}
+	breached, err := checker.IsBreached(context.Background(), "hunter2")
+	if err != nil {
+		t.Fatalf("breach check error: %v", err)
+	}
+	if !breached {
+		t.Fatal("expected password to be reported as breached")
+	}
+	if requestedPath != "/range/"+hash[:5] {
+		t.Fatalf("expected only the 5 character prefix to be sent, got %q", requestedPath)
+	}
+
+	breached, err = checker.IsBreached(context.Background(), "a much less common passphrase")
+	if err != nil || breached {
+		t.Fatalf("expected unlisted password to pass, got %v, %v", breached, err)
+	}
+}
@@ -0,0 +1,76 @@
+package directory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// DefaultMaxPhotoBytes caps an uploaded photo when
+// HTTPHandlerConfig.MaxPhotoBytes is zero.
+const DefaultMaxPhotoBytes int64 = 512 << 10
+
+// PhotoContentTypes are the image types a photo may have. Uploads are checked
+// against the bytes as well as the declared type.
+var PhotoContentTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+
+// ErrPhotoNotFound is returned when a user has no photo.
+var ErrPhotoNotFound = errors.New("photo not found")
+
+// Photo is a user's profile photo.
+type Photo struct {
+	ContentType string    `db:"content_type"`
+	Data        []byte    `db:"data"`
+	UpdatedAt   time.Time `db:"updated_at"`
+}
+
+// PhotoStore keeps user photos. It is separate from Service so deployments
+// can keep the bytes in an object store instead of the database.
+type PhotoStore interface {
+	// GetPhoto returns ErrPhotoNotFound when the user has none.
+	GetPhoto(ctx context.Context, tenantID, userID string) (Photo, error)
+	// PutPhoto replaces the user's photo. It returns ErrUserNotFound when
+	// the tenant has no such user.
+	PutPhoto(ctx context.Context, tenantID, userID string, photo Photo) error
+}
+
+type sqlPhotoStore struct {
+	db *sqlx.DB
+}
+
+// NewPhotoStore returns a PhotoStore over the user_photos table.
+func NewPhotoStore(db *sqlx.DB) PhotoStore {
+	return &sqlPhotoStore{db: db}
+}
+
+func (s *sqlPhotoStore) GetPhoto(ctx context.Context, tenantID, userID string) (Photo, error) {
+	var photo Photo
+	err := s.db.GetContext(ctx, &photo,
+		`SELECT content_type, data, updated_at FROM user_photos WHERE identity_id = $1 AND tenant_id = $2`,
+		userID, tenantID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Photo{}, ErrPhotoNotFound
+	}
+	return photo, err
+}
+
+func (s *sqlPhotoStore) PutPhoto(ctx context.Context, tenantID, userID string, photo Photo) error {
+	res, err := s.db.ExecContext(ctx, `INSERT INTO user_photos (identity_id, tenant_id, content_type, data)
+		SELECT i.id, i.tenant_id, $3, $4 FROM identities i WHERE i.id = $1 AND i.tenant_id = $2
+		ON CONFLICT (identity_id) DO UPDATE SET content_type = EXCLUDED.content_type, data = EXCLUDED.data, updated_at = NOW()`,
+		userID, tenantID, photo.ContentType, photo.Data)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
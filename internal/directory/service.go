@@ -2,9 +2,16 @@ package directory
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
 	"errors"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/dhawalhost/wardseal/pkg/apierr"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -15,39 +22,130 @@ type Service interface {
 
 	// User management
 	CreateUser(ctx context.Context, tenantID string, user User) (string, error)
+	CreateUsers(ctx context.Context, tenantID string, users []User) ([]BatchCreateResult, error)
 	GetUserByID(ctx context.Context, tenantID, id string) (User, error)
 	GetUserByEmail(ctx context.Context, tenantID, email string) (User, error)
-	ListUsers(ctx context.Context, tenantID string, limit, offset int) ([]User, int, error)
+	// ListUsers returns one page of the tenant's users in the given order and
+	// the total number of users.
+	ListUsers(ctx context.Context, tenantID string, limit, offset int, sort Sort) ([]User, int, error)
+	// ListUsersAfter returns up to limit users ordered by ID, starting after
+	// afterID ("" for the first page), and the cursor for the next page, which
+	// is empty once the last page has been read.
+	ListUsersAfter(ctx context.Context, tenantID, afterID string, limit int) ([]User, string, error)
+	// SearchUsers returns up to limit users whose login contains query,
+	// case-insensitively, with prefix matches first.
+	SearchUsers(ctx context.Context, tenantID, query string, limit int) ([]User, error)
+	// ExportUsers calls fn for every user in the tenant, optionally filtered by
+	// status, reading them a page at a time so large tenants are not loaded
+	// into memory.
+	ExportUsers(ctx context.Context, tenantID, status string, fn func(User) error) error
+	// UpdateUser changes the user's non-empty fields, profile attributes
+	// included, and leaves the others as they are.
 	UpdateUser(ctx context.Context, tenantID, id string, user User) error
+	// ReplaceProfile stores profile as the user's whole profile, clearing
+	// attributes it leaves empty.
+	ReplaceProfile(ctx context.Context, tenantID, id string, profile Profile) error
 	DeleteUser(ctx context.Context, tenantID, id string) error
 
 	// Group management
 	CreateGroup(ctx context.Context, tenantID string, group Group) (string, error)
 	GetGroupByID(ctx context.Context, tenantID, id string) (Group, error)
-	ListGroups(ctx context.Context, tenantID string, limit, offset int) ([]Group, int, error)
+	ListGroups(ctx context.Context, tenantID string, limit, offset int, sort Sort) ([]Group, int, error)
 	UpdateGroup(ctx context.Context, tenantID, id string, group Group) error
 	DeleteGroup(ctx context.Context, tenantID, id string) error
 
 	// Group membership
 	AddUserToGroup(ctx context.Context, tenantID, userID, groupID string) error
 	RemoveUserFromGroup(ctx context.Context, tenantID, userID, groupID string) error
+	// ListGroupMemberIDs returns the IDs of the group's members.
+	ListGroupMemberIDs(ctx context.Context, tenantID, groupID string) ([]string, error)
 
 	// Credential validation
+	// VerifyCredentials checks the password and records the login.
 	VerifyCredentials(ctx context.Context, tenantID, email, password string) (User, error)
+	SetPassword(ctx context.Context, tenantID, userID, password string) error
+	// RecordLogin records that the user signed in without a password, e.g.
+	// through federation. Like VerifyCredentials it writes at most once per
+	// login record interval.
+	RecordLogin(ctx context.Context, tenantID, userID string) error
+	// ListStaleUsers returns the tenant's active users who have not signed in
+	// for olderThan, including those created before then who never have,
+	// longest-idle first.
+	ListStaleUsers(ctx context.Context, tenantID string, olderThan time.Duration) ([]User, error)
 
 	// Discovery
 	GetTenantByEmail(ctx context.Context, email string) (string, error)
 }
 
 type directoryService struct {
-	db *sqlx.DB // Use sqlx.DB
+	db         *sqlx.DB // Use sqlx.DB
+	policy     PasswordPolicy
+	bcryptCost int
+	validate   *validator.Validate
+	// loginInterval throttles last-login writes.
+	loginInterval time.Duration
+	now           func() time.Time
+
+	dummyHashOnce sync.Once
+	dummyHash     []byte
 }
 
+// ServiceConfig controls how the directory service handles passwords.
+type ServiceConfig struct {
+	// PasswordPolicy is enforced whenever a password is set.
+	PasswordPolicy PasswordPolicy
+	// BcryptCost is the cost for new password hashes. Zero means
+	// bcrypt.DefaultCost. Hashes at a lower cost are upgraded on login.
+	BcryptCost int
+	// LoginRecordInterval is how stale a user's last login must be before a
+	// new login is written, so frequent sign-ins do not update the account on
+	// every request. Zero means DefaultLoginRecordInterval.
+	LoginRecordInterval time.Duration
+}
+
+// DefaultLoginRecordInterval is the ServiceConfig.LoginRecordInterval default.
+const DefaultLoginRecordInterval = 15 * time.Minute
+
 var ErrInvalidCredentials = errors.New("invalid credentials")
 
+// ErrAmbiguousTenant is returned when a login exists in more than one tenant,
+// so the caller has to name the tenant explicitly.
+var ErrAmbiguousTenant = errors.New("login exists in multiple tenants")
+
+// ErrUserNotFound is returned when an operation targets a user that does not exist.
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrInvalidManager is returned when a profile's manager is the user itself or
+// not a user of the same tenant.
+var ErrInvalidManager = errors.New("manager must be another user in the same tenant")
+
+// userColumns and userTables select a User with its profile. Users without a
+// user_profiles row read as an empty profile.
+const (
+	userColumns = `i.id, i.tenant_id, a.login AS email, i.status, i.created_at, i.updated_at, a.last_login_at,
+		COALESCE(p.phone, '') AS phone, COALESCE(p.title, '') AS title, COALESCE(p.department, '') AS department,
+		COALESCE(p.manager_id::text, '') AS manager_id, COALESCE(p.location, '') AS location`
+	userTables = `identities i JOIN accounts a ON i.id = a.identity_id LEFT JOIN user_profiles p ON p.identity_id = i.id`
+)
+
 // NewService creates a new directory service.
-func NewService(db *sqlx.DB) Service { // Use sqlx.DB
-	return &directoryService{db: db}
+func NewService(db *sqlx.DB, cfg ServiceConfig) Service { // Use sqlx.DB
+	cost := cfg.BcryptCost
+	if cost == 0 {
+		cost = bcrypt.DefaultCost
+	}
+	loginInterval := cfg.LoginRecordInterval
+	if loginInterval <= 0 {
+		loginInterval = DefaultLoginRecordInterval
+	}
+	return &directoryService{
+		db:            db,
+		policy:        cfg.PasswordPolicy,
+		bcryptCost:    cost,
+		validate:      apierr.NewValidator(),
+		loginInterval: loginInterval,
+		now:           time.Now,
+	}
 }
 
 func (s *directoryService) HealthCheck(ctx context.Context) (bool, error) {
@@ -59,7 +157,10 @@ func (s *directoryService) HealthCheck(ctx context.Context) (bool, error) {
 }
 
 func (s *directoryService) CreateUser(ctx context.Context, tenantID string, user User) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	if err := s.policy.Validate(ctx, user.Password); err != nil {
+		return "", err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), s.bcryptCost)
 	if err != nil {
 		return "", err
 	}
@@ -70,54 +171,207 @@ func (s *directoryService) CreateUser(ctx context.Context, tenantID string, user
 	}
 	defer func() { _ = tx.Rollback() }()
 
+	userID, err := insertUser(ctx, tx, tenantID, user.Email, string(hashedPassword), user.Status, user.Profile)
+	if err != nil {
+		return "", err
+	}
+	return userID, tx.Commit()
+}
+
+// insertUser creates the identity with status, active when empty, its login
+// account and, if any attribute is set, its profile.
+func insertUser(ctx context.Context, tx *sqlx.Tx, tenantID, email, passwordHash, status string, profile Profile) (string, error) {
+	if status == "" {
+		status = "active"
+	}
 	var userID string
-	err = tx.QueryRowxContext(ctx, // Use QueryRowxContext for sqlx
+	err := tx.QueryRowxContext(ctx, // Use QueryRowxContext for sqlx
 		`INSERT INTO identities (tenant_id, status) VALUES ($1, $2) RETURNING id`,
-		tenantID, "active").Scan(&userID)
+		tenantID, status).Scan(&userID)
 	if err != nil {
 		return "", err
 	}
 
 	_, err = tx.ExecContext(ctx,
 		`INSERT INTO accounts (identity_id, tenant_id, login, password_hash) VALUES ($1, $2, $3, $4)`,
-		userID, tenantID, user.Email, string(hashedPassword))
+		userID, tenantID, email, passwordHash)
 	if err != nil {
 		return "", err
 	}
+	if !profile.isEmpty() {
+		if err := writeProfile(ctx, tx, tenantID, userID, profile, true); err != nil {
+			return "", err
+		}
+	}
+	return userID, nil
+}
+
+// profileColumns are the user_profiles columns a Profile writes.
+var profileColumns = []string{"phone", "title", "department", "manager_id", "location"}
+
+// writeProfile stores profile for the user. With merge, empty attributes keep
+// their stored value; otherwise they are cleared. It writes nothing for an
+// identity outside the tenant.
+func writeProfile(ctx context.Context, tx *sqlx.Tx, tenantID, userID string, profile Profile, merge bool) error {
+	if profile.ManagerID != "" {
+		if _, err := uuid.Parse(profile.ManagerID); err != nil || profile.ManagerID == userID {
+			return ErrInvalidManager
+		}
+		var exists bool
+		err := tx.GetContext(ctx, &exists, `SELECT EXISTS (SELECT 1 FROM identities WHERE id = $1 AND tenant_id = $2)`,
+			profile.ManagerID, tenantID)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrInvalidManager
+		}
+	}
+	set := make([]string, 0, len(profileColumns)+1)
+	for _, col := range profileColumns {
+		if merge {
+			set = append(set, col+" = COALESCE(EXCLUDED."+col+", user_profiles."+col+")")
+		} else {
+			set = append(set, col+" = EXCLUDED."+col)
+		}
+	}
+	set = append(set, "updated_at = NOW()")
+	_, err := tx.ExecContext(ctx, `INSERT INTO user_profiles (identity_id, tenant_id, phone, title, department, manager_id, location)
+		SELECT i.id, i.tenant_id, NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, '')::uuid, NULLIF($7, '')
+		FROM identities i WHERE i.id = $1 AND i.tenant_id = $2
+		ON CONFLICT (identity_id) DO UPDATE SET `+strings.Join(set, ", "),
+		userID, tenantID, profile.Phone, profile.Title, profile.Department, profile.ManagerID, profile.Location)
+	return err
+}
 
-	return userID, tx.Commit()
+const (
+	// MaxBatchSize caps the number of users in one batch create request.
+	MaxBatchSize = 1000
+	// batchChunkSize is how many users are inserted per transaction.
+	batchChunkSize = 100
+)
+
+// batchUser is a validated batch item ready to insert.
+type batchUser struct {
+	index        int
+	email        string
+	passwordHash string
+	status       string
+	profile      Profile
+}
+
+// CreateUsers validates and hashes every user first, then inserts the valid
+// ones in transactions of batchChunkSize. Each item runs under a savepoint so
+// one failing insert (e.g. a duplicate login) does not abort its chunk. If a
+// chunk fails as a whole, its users and those of later chunks are reported as
+// not created, while the results of committed chunks are kept.
+func (s *directoryService) CreateUsers(ctx context.Context, tenantID string, users []User) ([]BatchCreateResult, error) {
+	results, prepared := s.prepareBatch(ctx, users)
+	for start := 0; start < len(prepared); start += batchChunkSize {
+		end := start + batchChunkSize
+		if end > len(prepared) {
+			end = len(prepared)
+		}
+		if err := s.insertBatchChunk(ctx, tenantID, prepared[start:end], results); err != nil {
+			for _, item := range prepared[start:] {
+				results[item.index].UserID = ""
+				results[item.index].Error = "not created: " + err.Error()
+			}
+			break
+		}
+	}
+	return results, nil
+}
+
+// prepareBatch returns a result per user, with errors filled in for users
+// that fail validation or the password policy, and the users left to insert.
+func (s *directoryService) prepareBatch(ctx context.Context, users []User) ([]BatchCreateResult, []batchUser) {
+	results := make([]BatchCreateResult, len(users))
+	var prepared []batchUser
+	for i, user := range users {
+		results[i].Index = i
+		if err := s.validate.Struct(user); err != nil {
+			results[i].Error = apierr.Validation(err).Message
+			continue
+		}
+		if err := s.policy.Validate(ctx, user.Password); err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), s.bcryptCost)
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		prepared = append(prepared, batchUser{index: i, email: user.Email, passwordHash: string(hash), status: user.Status, profile: user.Profile})
+	}
+	return results, prepared
+}
+
+func (s *directoryService) insertBatchChunk(ctx context.Context, tenantID string, chunk []batchUser, results []BatchCreateResult) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, item := range chunk {
+		if _, err := tx.ExecContext(ctx, `SAVEPOINT batch_item`); err != nil {
+			return err
+		}
+		userID, err := insertUser(ctx, tx, tenantID, item.email, item.passwordHash, item.status, item.profile)
+		if err != nil {
+			if _, rbErr := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT batch_item`); rbErr != nil {
+				return rbErr
+			}
+			results[item.index].Error = err.Error()
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `RELEASE SAVEPOINT batch_item`); err != nil {
+			return err
+		}
+		results[item.index].UserID = userID
+	}
+	return tx.Commit()
 }
 
 func (s *directoryService) GetUserByID(ctx context.Context, tenantID, id string) (User, error) {
 	var user User
-	err := s.db.GetContext(ctx, &user, `SELECT i.id, i.tenant_id, a.login AS email, i.status, i.created_at, i.updated_at
-		 FROM identities i JOIN accounts a ON i.id = a.identity_id WHERE i.id = $1 AND i.tenant_id = $2`,
+	err := s.db.GetContext(ctx, &user, `SELECT `+userColumns+` FROM `+userTables+`
+		 WHERE i.id = $1 AND i.tenant_id = $2`,
 		id, tenantID)
 	return user, err
 }
 
 func (s *directoryService) GetUserByEmail(ctx context.Context, tenantID, email string) (User, error) {
 	var user User
-	err := s.db.GetContext(ctx, &user, `SELECT i.id, i.tenant_id, a.login AS email, i.status, i.created_at, i.updated_at
-		 FROM identities i JOIN accounts a ON i.id = a.identity_id WHERE a.login = $1 AND a.tenant_id = $2 AND i.tenant_id = $2`,
+	err := s.db.GetContext(ctx, &user, `SELECT `+userColumns+` FROM `+userTables+`
+		 WHERE a.login = $1 AND a.tenant_id = $2 AND i.tenant_id = $2`,
 		email, tenantID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, ErrUserNotFound
+	}
 	return user, err
 }
 
-func (s *directoryService) ListUsers(ctx context.Context, tenantID string, limit, offset int) ([]User, int, error) {
+func (s *directoryService) ListUsers(ctx context.Context, tenantID string, limit, offset int, sort Sort) ([]User, int, error) {
+	order, err := sort.orderBy(userSortColumns, "i.id")
+	if err != nil {
+		return nil, 0, err
+	}
+
 	// Get total count
 	var total int
-	err := s.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM identities WHERE tenant_id = $1`, tenantID)
+	err = s.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM identities WHERE tenant_id = $1`, tenantID)
 	if err != nil {
 		return nil, 0, err
 	}
 
 	// Get paginated users
 	var users []User
-	err = s.db.SelectContext(ctx, &users, `SELECT i.id, i.tenant_id, a.login AS email, i.status, i.created_at, i.updated_at
-		FROM identities i JOIN accounts a ON i.id = a.identity_id 
+	err = s.db.SelectContext(ctx, &users, `SELECT `+userColumns+` FROM `+userTables+`
 		WHERE i.tenant_id = $1 
-		ORDER BY i.created_at DESC 
+		ORDER BY `+order+`
 		LIMIT $2 OFFSET $3`,
 		tenantID, limit, offset)
 	if err != nil {
@@ -126,7 +380,106 @@ func (s *directoryService) ListUsers(ctx context.Context, tenantID string, limit
 	return users, total, nil
 }
 
+// MaxSearchLimit caps how many users SearchUsers returns.
+const MaxSearchLimit = 100
+
+func (s *directoryService) SearchUsers(ctx context.Context, tenantID, query string, limit int) ([]User, error) {
+	if limit <= 0 || limit > MaxSearchLimit {
+		limit = MaxSearchLimit
+	}
+	escaped := escapeLike(strings.ToLower(query))
+	var users []User
+	// The trigram index on lower(login) serves the substring LIKE.
+	err := s.db.SelectContext(ctx, &users, `SELECT `+userColumns+` FROM `+userTables+`
+		WHERE a.tenant_id = $1 AND i.tenant_id = $1 AND lower(a.login) LIKE $2
+		ORDER BY lower(a.login) LIKE $3 DESC, a.login
+		LIMIT $4`,
+		tenantID, "%"+escaped+"%", escaped+"%", limit)
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// escapeLike escapes LIKE wildcards so they match literally. Backslash is
+// PostgreSQL's default LIKE escape character.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// exportPageSize is how many users ExportUsers reads per query.
+const exportPageSize = 500
+
+// ListUsersAfter pages through users with keyset pagination on the identity
+// ID, which stays fast however deep the page, unlike ListUsers' OFFSET.
+func (s *directoryService) ListUsersAfter(ctx context.Context, tenantID, afterID string, limit int) ([]User, string, error) {
+	return s.listUsersAfter(ctx, tenantID, "", afterID, limit)
+}
+
+func (s *directoryService) listUsersAfter(ctx context.Context, tenantID, status, afterID string, limit int) ([]User, string, error) {
+	if limit <= 0 {
+		limit = exportPageSize
+	}
+	if afterID == "" {
+		// The nil UUID sorts before every other ID.
+		afterID = "00000000-0000-0000-0000-000000000000"
+	}
+	var users []User
+	// One extra row tells whether another page follows.
+	err := s.db.SelectContext(ctx, &users, `SELECT `+userColumns+` FROM `+userTables+`
+		WHERE i.tenant_id = $1 AND i.id > $2 AND ($3 = '' OR i.status = $3)
+		ORDER BY i.id
+		LIMIT $4`,
+		tenantID, afterID, status, limit+1)
+	if err != nil {
+		return nil, "", err
+	}
+	users, next := pageCursor(users, limit)
+	return users, next, nil
+}
+
+// pageCursor trims a result fetched with limit+1 rows to limit and returns the
+// cursor for the next page, or "" when there is none.
+func pageCursor(users []User, limit int) ([]User, string) {
+	if len(users) <= limit {
+		return users, ""
+	}
+	users = users[:limit]
+	return users, users[limit-1].ID
+}
+
+func (s *directoryService) ExportUsers(ctx context.Context, tenantID, status string, fn func(User) error) error {
+	return forEachUser(func(afterID string) ([]User, string, error) {
+		return s.listUsersAfter(ctx, tenantID, status, afterID, exportPageSize)
+	}, fn)
+}
+
+// forEachUser walks every page returned by fetch, following its cursor.
+func forEachUser(fetch func(afterID string) ([]User, string, error), fn func(User) error) error {
+	cursor := ""
+	for {
+		users, next, err := fetch(cursor)
+		if err != nil {
+			return err
+		}
+		for _, user := range users {
+			if err := fn(user); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
+
 func (s *directoryService) UpdateUser(ctx context.Context, tenantID, id string, user User) error {
+	if user.Password != "" {
+		if err := s.policy.Validate(ctx, user.Password); err != nil {
+			return err
+		}
+	}
 	tx, err := s.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
@@ -141,7 +494,7 @@ func (s *directoryService) UpdateUser(ctx context.Context, tenantID, id string,
 	}
 
 	if user.Password != "" {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), s.bcryptCost)
 		if err != nil {
 			return err
 		}
@@ -158,6 +511,12 @@ func (s *directoryService) UpdateUser(ctx context.Context, tenantID, id string,
 		}
 	}
 
+	if !user.Profile.isEmpty() {
+		if err := writeProfile(ctx, tx, tenantID, id, user.Profile, true); err != nil {
+			return err
+		}
+	}
+
 	_, err = tx.ExecContext(ctx, `UPDATE identities SET updated_at = NOW() WHERE id = $1 AND tenant_id = $2`, id, tenantID)
 	if err != nil {
 		return err
@@ -166,6 +525,34 @@ func (s *directoryService) UpdateUser(ctx context.Context, tenantID, id string,
 	return tx.Commit()
 }
 
+func (s *directoryService) ReplaceProfile(ctx context.Context, tenantID, id string, profile Profile) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx, `UPDATE identities SET updated_at = NOW() WHERE id = $1 AND tenant_id = $2`, id, tenantID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrUserNotFound
+	}
+
+	if profile.isEmpty() {
+		_, err = tx.ExecContext(ctx, `DELETE FROM user_profiles WHERE identity_id = $1`, id)
+	} else {
+		err = writeProfile(ctx, tx, tenantID, id, profile, false)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
 func (s *directoryService) DeleteUser(ctx context.Context, tenantID, id string) error {
 	_, err := s.db.ExecContext(ctx, `DELETE FROM identities WHERE id = $1 AND tenant_id = $2`, id, tenantID)
 	return err
@@ -186,16 +573,21 @@ func (s *directoryService) GetGroupByID(ctx context.Context, tenantID, id string
 	return group, err
 }
 
-func (s *directoryService) ListGroups(ctx context.Context, tenantID string, limit, offset int) ([]Group, int, error) {
+func (s *directoryService) ListGroups(ctx context.Context, tenantID string, limit, offset int, sort Sort) ([]Group, int, error) {
+	order, err := sort.orderBy(groupSortColumns, "id")
+	if err != nil {
+		return nil, 0, err
+	}
+
 	var total int
-	err := s.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM groups WHERE tenant_id = $1`, tenantID)
+	err = s.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM groups WHERE tenant_id = $1`, tenantID)
 	if err != nil {
 		return nil, 0, err
 	}
 
 	var groups []Group
 	err = s.db.SelectContext(ctx, &groups, `SELECT id, tenant_id, name, created_at, updated_at 
-		FROM groups WHERE tenant_id = $1 ORDER BY created_at DESC LIMIT $2 OFFSET $3`,
+		FROM groups WHERE tenant_id = $1 ORDER BY `+order+` LIMIT $2 OFFSET $3`,
 		tenantID, limit, offset)
 	if err != nil {
 		return nil, 0, err
@@ -227,38 +619,164 @@ func (s *directoryService) RemoveUserFromGroup(ctx context.Context, tenantID, us
 	return err
 }
 
-func (s *directoryService) VerifyCredentials(ctx context.Context, tenantID, email, password string) (User, error) {
-	var record struct {
-		User
-		PasswordHash string `db:"password_hash"`
-	}
+func (s *directoryService) ListGroupMemberIDs(ctx context.Context, tenantID, groupID string) ([]string, error) {
+	var ids []string
+	err := s.db.SelectContext(ctx, &ids, `SELECT identity_id FROM identity_groups
+	WHERE group_id = $1 AND tenant_id = $2 ORDER BY identity_id`, groupID, tenantID)
+	return ids, err
+}
 
-	err := s.db.GetContext(ctx, &record, `SELECT i.id, i.tenant_id, a.login AS email, i.status, i.created_at, i.updated_at, a.password_hash
-		FROM identities i JOIN accounts a ON i.id = a.identity_id
+// credentialRecord is an account row with its password hash.
+type credentialRecord struct {
+	User
+	PasswordHash string `db:"password_hash"`
+}
+
+func (s *directoryService) VerifyCredentials(ctx context.Context, tenantID, email, password string) (User, error) {
+	var record credentialRecord
+	err := s.db.GetContext(ctx, &record, `SELECT `+userColumns+`, a.password_hash FROM `+userTables+`
 		WHERE a.login = $1 AND a.tenant_id = $2 AND i.tenant_id = $2`, email, tenantID)
+	found := true
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return User{}, ErrInvalidCredentials
+		if !errors.Is(err, sql.ErrNoRows) {
+			return User{}, err
 		}
+		found = false
+	}
+
+	user, upgraded, err := s.verifyRecord(record, found, password)
+	if err != nil {
 		return User{}, err
 	}
+	if upgraded != nil {
+		// Best effort: a failed upgrade must not fail an otherwise valid login.
+		_, _ = s.db.ExecContext(ctx, `UPDATE accounts SET password_hash = $1 WHERE identity_id = $2 AND tenant_id = $3 AND password_hash = $4`,
+			string(upgraded), record.ID, tenantID, record.PasswordHash)
+	}
+	if loginDue(record.LastLoginAt, s.now(), s.loginInterval) {
+		// Best effort, like the upgrade above.
+		_ = s.RecordLogin(ctx, tenantID, record.ID)
+	}
+	return user, nil
+}
+
+// loginDue reports whether a login at now should be written over the last
+// recorded one.
+func loginDue(last *time.Time, now time.Time, interval time.Duration) bool {
+	return last == nil || now.Sub(*last) >= interval
+}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(record.PasswordHash), []byte(password)); err != nil {
-		return User{}, ErrInvalidCredentials
+func (s *directoryService) RecordLogin(ctx context.Context, tenantID, userID string) error {
+	// The interval is checked again here so concurrent logins, and callers
+	// that have not read the account, write at most once per interval.
+	_, err := s.db.ExecContext(ctx, `UPDATE accounts SET last_login_at = NOW()
+		WHERE identity_id = $1 AND tenant_id = $2
+		AND (last_login_at IS NULL OR last_login_at <= NOW() - $3 * INTERVAL '1 second')`,
+		userID, tenantID, s.loginInterval.Seconds())
+	return err
+}
+
+func (s *directoryService) ListStaleUsers(ctx context.Context, tenantID string, olderThan time.Duration) ([]User, error) {
+	cutoff := s.now().Add(-olderThan)
+	var users []User
+	err := s.db.SelectContext(ctx, &users, `SELECT `+userColumns+` FROM `+userTables+`
+		WHERE i.tenant_id = $1 AND a.tenant_id = $1 AND i.status = 'active'
+		AND COALESCE(a.last_login_at, i.created_at) < $2
+		ORDER BY a.last_login_at NULLS FIRST, i.created_at, i.id`,
+		tenantID, cutoff)
+	if err != nil {
+		return nil, err
 	}
+	return users, nil
+}
 
-	return record.User, nil
+// verifyRecord checks password against the account. A missing account is
+// compared against a dummy hash at the same cost, so it takes as long as a
+// wrong password and response timing does not reveal which accounts exist.
+// It returns a replacement hash when the stored one needs a cost upgrade.
+func (s *directoryService) verifyRecord(record credentialRecord, found bool, password string) (User, []byte, error) {
+	if !found {
+		_ = bcrypt.CompareHashAndPassword(s.dummyPasswordHash(), []byte(password))
+		return User{}, nil, ErrInvalidCredentials
+	}
+	upgraded, err := checkPassword([]byte(record.PasswordHash), password, s.bcryptCost)
+	if err != nil {
+		return User{}, nil, ErrInvalidCredentials
+	}
+	return record.User, upgraded, nil
+}
+
+// dummyPasswordHash lazily hashes a random value at the configured cost.
+func (s *directoryService) dummyPasswordHash() []byte {
+	s.dummyHashOnce.Do(func() {
+		secret := make([]byte, 16)
+		_, _ = rand.Read(secret)
+		s.dummyHash, _ = bcrypt.GenerateFromPassword(secret, s.bcryptCost)
+	})
+	return s.dummyHash
 }
 
+// SetPassword replaces the password hash of a user's account.
+func (s *directoryService) SetPassword(ctx context.Context, tenantID, userID, password string) error {
+	if err := s.policy.Validate(ctx, password); err != nil {
+		return err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), s.bcryptCost)
+	if err != nil {
+		return err
+	}
+	res, err := s.db.ExecContext(ctx, `UPDATE accounts SET password_hash = $1 WHERE identity_id = $2 AND tenant_id = $3`,
+		string(hashedPassword), userID, tenantID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// checkPassword verifies password against hash. When the hash was made at a
+// lower cost than cost, it also returns a fresh hash to store in its place.
+func checkPassword(hash []byte, password string, cost int) ([]byte, error) {
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil {
+		return nil, err
+	}
+	current, err := bcrypt.Cost(hash)
+	if err != nil || current >= cost {
+		return nil, nil
+	}
+	upgraded, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		return nil, nil
+	}
+	return upgraded, nil
+}
+
+// GetTenantByEmail returns the tenant whose account uses the login, or "" if
+// none does. It returns ErrAmbiguousTenant when several tenants share it.
 func (s *directoryService) GetTenantByEmail(ctx context.Context, email string) (string, error) {
-	var tenantID string
-	// We just need the tenant_id from accounts table
-	err := s.db.GetContext(ctx, &tenantID, `SELECT tenant_id FROM accounts WHERE login = $1 LIMIT 1`, email)
+	var tenantIDs []string
+	// Two rows are enough to tell a unique match from an ambiguous one.
+	err := s.db.SelectContext(ctx, &tenantIDs, `SELECT DISTINCT tenant_id FROM accounts WHERE login = $1 LIMIT 2`, email)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return "", nil // Not found
-		}
 		return "", err
 	}
-	return tenantID, nil
+	return resolveTenant(tenantIDs)
+}
+
+// resolveTenant picks the single tenant owning a login.
+func resolveTenant(tenantIDs []string) (string, error) {
+	switch len(tenantIDs) {
+	case 0:
+		return "", nil // Not found
+	case 1:
+		return tenantIDs[0], nil
+	default:
+		return "", ErrAmbiguousTenant
+	}
 }
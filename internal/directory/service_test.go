@@ -0,0 +1,244 @@
+package directory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestCheckPasswordUpgradesLowCostHash(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("Correct-Horse-42"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+
+	upgraded, err := checkPassword(hash, "Correct-Horse-42", bcrypt.MinCost+2)
+	if err != nil {
+		t.Fatalf("expected correct password to verify, got %v", err)
+	}
+	if upgraded == nil {
+		t.Fatal("expected a low-cost hash to be upgraded")
+	}
+	if cost, _ := bcrypt.Cost(upgraded); cost != bcrypt.MinCost+2 {
+		t.Fatalf("expected upgraded cost %d, got %d", bcrypt.MinCost+2, cost)
+	}
+	if _, err := checkPassword(upgraded, "Correct-Horse-42", bcrypt.MinCost+2); err != nil {
+		t.Fatalf("expected upgraded hash to verify, got %v", err)
+	}
+}
+
+func TestCheckPasswordKeepsCurrentHash(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("Correct-Horse-42"), bcrypt.MinCost+2)
+	upgraded, err := checkPassword(hash, "Correct-Horse-42", bcrypt.MinCost+2)
+	if err != nil || upgraded != nil {
+		t.Fatalf("expected no upgrade for a current hash, got %v, %v", upgraded, err)
+	}
+	if _, err := checkPassword(hash, "wrong-password", bcrypt.MinCost+2); err == nil {
+		t.Fatal("expected wrong password to fail")
+	}
+}
+
+func newCredentialTestService() *directoryService {
+	return &directoryService{bcryptCost: bcrypt.MinCost}
+}
+
+func TestVerifyRecordValidPassword(t *testing.T) {
+	svc := newCredentialTestService()
+	hash, _ := bcrypt.GenerateFromPassword([]byte("Correct-Horse-42"), bcrypt.MinCost)
+	record := credentialRecord{User: User{ID: "user-1", Email: "alice@example.com"}, PasswordHash: string(hash)}
+
+	user, upgraded, err := svc.verifyRecord(record, true, "Correct-Horse-42")
+	if err != nil {
+		t.Fatalf("expected valid credentials, got %v", err)
+	}
+	if user.ID != "user-1" || upgraded != nil {
+		t.Fatalf("unexpected result: %+v, upgraded=%v", user, upgraded != nil)
+	}
+}
+
+func TestVerifyRecordInvalidPassword(t *testing.T) {
+	svc := newCredentialTestService()
+	hash, _ := bcrypt.GenerateFromPassword([]byte("Correct-Horse-42"), bcrypt.MinCost)
+	record := credentialRecord{User: User{ID: "user-1"}, PasswordHash: string(hash)}
+
+	if _, _, err := svc.verifyRecord(record, true, "wrong-password"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+	}
+}
+
+func TestVerifyRecordMissingAccount(t *testing.T) {
+	svc := newCredentialTestService()
+
+	if _, _, err := svc.verifyRecord(credentialRecord{}, false, "Correct-Horse-42"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+	}
+	// The missing-account path must still pay for a bcrypt comparison.
+	if cost, err := bcrypt.Cost(svc.dummyHash); err != nil || cost != bcrypt.MinCost {
+		t.Fatalf("expected a dummy hash at the configured cost, got cost %d, err %v", cost, err)
+	}
+}
+
+func TestResolveTenantSingleMatch(t *testing.T) {
+	tenantID, err := resolveTenant([]string{"22222222-2222-2222-2222-222222222222"})
+	if err != nil || tenantID != "22222222-2222-2222-2222-222222222222" {
+		t.Fatalf("expected the single tenant, got %q, %v", tenantID, err)
+	}
+}
+
+func TestResolveTenantNoMatch(t *testing.T) {
+	tenantID, err := resolveTenant(nil)
+	if err != nil || tenantID != "" {
+		t.Fatalf("expected no tenant, got %q, %v", tenantID, err)
+	}
+}
+
+func TestResolveTenantMultiMatch(t *testing.T) {
+	_, err := resolveTenant([]string{"22222222-2222-2222-2222-222222222222", "33333333-3333-3333-3333-333333333333"})
+	if !errors.Is(err, ErrAmbiguousTenant) {
+		t.Fatalf("expected ErrAmbiguousTenant, got %v", err)
+	}
+}
+
+func TestPrepareBatchValidatesEveryItemBeforeInsert(t *testing.T) {
+	svc := NewService(nil, ServiceConfig{PasswordPolicy: DefaultPasswordPolicy(), BcryptCost: bcrypt.MinCost}).(*directoryService)
+	users := []User{
+		{Email: "one@wardseal.com", Password: "Password123", Status: "active"},
+		{Email: "not-an-email", Password: "Password123", Status: "active"},
+		{Email: "weak@wardseal.com", Password: "password", Status: "active"},
+		{Email: "two@wardseal.com", Password: "Password456", Status: "suspended"},
+	}
+
+	results, prepared := svc.prepareBatch(context.Background(), users)
+	if len(results) != 4 || len(prepared) != 2 {
+		t.Fatalf("expected 4 results and 2 users to insert, got %d and %d", len(results), len(prepared))
+	}
+	if results[1].Error == "" || results[2].Error == "" || results[0].Error != "" || results[3].Error != "" {
+		t.Fatalf("unexpected validation results: %+v", results)
+	}
+	if prepared[0].index != 0 || prepared[1].index != 3 {
+		t.Fatalf("expected valid items to keep their request positions, got %+v", prepared)
+	}
+	if prepared[0].status != "active" || prepared[1].status != "suspended" {
+		t.Fatalf("expected requested statuses to be kept, got %q and %q", prepared[0].status, prepared[1].status)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(prepared[1].passwordHash), []byte("Password456")); err != nil {
+		t.Fatalf("expected prepared password to be hashed: %v", err)
+	}
+}
+
+func TestPrepareBatchValidatesProfile(t *testing.T) {
+	svc := NewService(nil, ServiceConfig{PasswordPolicy: DefaultPasswordPolicy(), BcryptCost: bcrypt.MinCost}).(*directoryService)
+	users := []User{
+		{Email: "one@wardseal.com", Password: "Password123", Status: "active", Profile: Profile{Title: "Engineer", ManagerID: "33333333-3333-3333-3333-333333333333"}},
+		{Email: "two@wardseal.com", Password: "Password123", Status: "active", Profile: Profile{ManagerID: "not-a-uuid"}},
+	}
+
+	results, prepared := svc.prepareBatch(context.Background(), users)
+	if len(prepared) != 1 || results[1].Error == "" {
+		t.Fatalf("expected the malformed manager ID to be rejected, got %+v", results)
+	}
+	if prepared[0].profile != users[0].Profile {
+		t.Fatalf("expected the profile to be kept for insert, got %+v", prepared[0].profile)
+	}
+}
+
+func TestLoginDueThrottlesWrites(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	recent := now.Add(-5 * time.Minute)
+	old := now.Add(-time.Hour)
+
+	if !loginDue(nil, now, 15*time.Minute) {
+		t.Fatal("expected a first login to be recorded")
+	}
+	if loginDue(&recent, now, 15*time.Minute) {
+		t.Fatal("expected a login within the interval to be skipped")
+	}
+	if !loginDue(&old, now, 15*time.Minute) {
+		t.Fatal("expected a login after the interval to be recorded")
+	}
+}
+
+func TestPageCursor(t *testing.T) {
+	rows := []User{{ID: "a"}, {ID: "b"}, {ID: "c"}}
+
+	page, next := pageCursor(rows, 2)
+	if len(page) != 2 || next != "b" {
+		t.Fatalf("expected a full page with cursor b, got %d rows and %q", len(page), next)
+	}
+	page, next = pageCursor(rows, 3)
+	if len(page) != 3 || next != "" {
+		t.Fatalf("expected the last page without a cursor, got %d rows and %q", len(page), next)
+	}
+}
+
+func TestForEachUserFollowsCursorWithoutSkipsOrDuplicates(t *testing.T) {
+	var all []User
+	for i := 0; i < 23; i++ {
+		all = append(all, User{ID: fmt.Sprintf("%08d-0000-0000-0000-000000000000", i)})
+	}
+	// fetch mimics the keyset query: rows ordered by ID after the cursor,
+	// limit+1 fetched to detect a following page.
+	const limit = 5
+	var cursors []string
+	fetch := func(afterID string) ([]User, string, error) {
+		cursors = append(cursors, afterID)
+		var rows []User
+		for _, u := range all {
+			if u.ID > afterID && len(rows) < limit+1 {
+				rows = append(rows, u)
+			}
+		}
+		page, next := pageCursor(rows, limit)
+		return page, next, nil
+	}
+
+	var seen []string
+	if err := forEachUser(fetch, func(u User) error {
+		seen = append(seen, u.ID)
+		return nil
+	}); err != nil {
+		t.Fatalf("forEachUser returned error: %v", err)
+	}
+
+	if len(seen) != len(all) {
+		t.Fatalf("expected %d users, got %d", len(all), len(seen))
+	}
+	for i, id := range seen {
+		if id != all[i].ID {
+			t.Fatalf("expected user %d to be %s, got %s", i, all[i].ID, id)
+		}
+	}
+	if len(cursors) != 5 || cursors[0] != "" || cursors[1] != all[limit-1].ID {
+		t.Fatalf("unexpected cursor sequence %v", cursors)
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	if got := escapeLike(`100%_a\b`); got != `100\%\_a\\b` {
+		t.Fatalf("unexpected escaped pattern %q", got)
+	}
+}
+
+func TestSortOrderBy(t *testing.T) {
+	tests := []struct {
+		sort Sort
+		want string
+	}{
+		{Sort{}, "i.created_at ASC, i.id ASC"},
+		{Sort{Field: SortByLogin}, "a.login ASC, i.id ASC"},
+		{Sort{Field: SortByCreated, Descending: true}, "i.created_at DESC, i.id DESC"},
+	}
+	for _, tt := range tests {
+		got, err := tt.sort.orderBy(userSortColumns, "i.id")
+		if err != nil || got != tt.want {
+			t.Errorf("orderBy(%+v) = %q, %v; want %q", tt.sort, got, err, tt.want)
+		}
+	}
+	if _, err := (Sort{Field: SortByName}).orderBy(userSortColumns, "i.id"); !errors.Is(err, ErrInvalidSort) {
+		t.Fatalf("expected ErrInvalidSort for users sorted by name, got %v", err)
+	}
+}
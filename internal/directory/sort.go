@@ -0,0 +1,52 @@
+package directory
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SortField names the attribute list results are ordered by.
+type SortField string
+
+const (
+	// SortByCreated orders by creation time. It is the default.
+	SortByCreated SortField = "created"
+	// SortByLogin orders users by login.
+	SortByLogin SortField = "login"
+	// SortByName orders groups by name.
+	SortByName SortField = "name"
+)
+
+// Sort orders list results. The zero value lists oldest first. Ties are
+// broken by ID so pages stay stable.
+type Sort struct {
+	Field      SortField
+	Descending bool
+}
+
+// ErrInvalidSort is returned when a list cannot be ordered by the requested
+// field.
+var ErrInvalidSort = errors.New("unsupported sort field")
+
+var (
+	userSortColumns  = map[SortField]string{SortByCreated: "i.created_at", SortByLogin: "a.login"}
+	groupSortColumns = map[SortField]string{SortByCreated: "created_at", SortByName: "name"}
+)
+
+// orderBy renders the ORDER BY list for s from the allowed columns, so only
+// known column names ever reach the query.
+func (s Sort) orderBy(columns map[SortField]string, idColumn string) (string, error) {
+	field := s.Field
+	if field == "" {
+		field = SortByCreated
+	}
+	column, ok := columns[field]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrInvalidSort, field)
+	}
+	direction := " ASC"
+	if s.Descending {
+		direction = " DESC"
+	}
+	return column + direction + ", " + idColumn + direction, nil
+}
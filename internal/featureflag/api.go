@@ -0,0 +1,82 @@
+package featureflag
+
+import (
+	"net/http"
+
+	"github.com/dhawalhost/wardseal/pkg/apierr"
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// HTTPHandler handles feature flag HTTP requests.
+type HTTPHandler struct {
+	svc    Service
+	logger *zap.Logger
+}
+
+// NewHTTPHandler creates a new feature flag HTTP handler.
+func NewHTTPHandler(svc Service, logger *zap.Logger) *HTTPHandler {
+	return &HTTPHandler{svc: svc, logger: logger}
+}
+
+// RegisterRoutes registers the feature flag routes under /features. rg must
+// be tenant-scoped.
+func (h *HTTPHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	features := rg.Group("/features")
+	{
+		features.GET("", h.listFeatures)
+		features.PUT("/:feature", h.setFeature)
+	}
+}
+
+// setFeatureRequest turns a feature on or off.
+type setFeatureRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+func (h *HTTPHandler) tenantID(c *gin.Context) (string, bool) {
+	tenantID, err := middleware.TenantIDFromGinContext(c)
+	if err != nil {
+		apierr.Abort(c, apierr.Invalid("tenant id required"))
+		return "", false
+	}
+	return tenantID, true
+}
+
+func (h *HTTPHandler) listFeatures(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+	features, err := h.svc.ListFeatures(c.Request.Context(), tenantID)
+	if err != nil {
+		apierr.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"features": features})
+}
+
+func (h *HTTPHandler) setFeature(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+	var req setFeatureRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierr.Abort(c, apierr.Validation(err))
+		return
+	}
+
+	state, err := h.svc.SetFeature(c.Request.Context(), tenantID, c.Param("feature"), *req.Enabled)
+	if err != nil {
+		apierr.Abort(c, err)
+		return
+	}
+
+	h.logger.Info("Feature flag updated",
+		zap.String("tenant_id", tenantID),
+		zap.String("feature", state.Feature),
+		zap.Bool("enabled", state.Enabled))
+	c.JSON(http.StatusOK, state)
+}
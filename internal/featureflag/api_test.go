@@ -0,0 +1,172 @@
+package featureflag_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dhawalhost/wardseal/internal/featureflag"
+	"github.com/dhawalhost/wardseal/internal/license"
+	"github.com/dhawalhost/wardseal/pkg/apierr"
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const testTenant = "11111111-1111-1111-1111-111111111111"
+
+// memoryStore is an in-memory featureflag.Store.
+type memoryStore struct {
+	flags map[string]featureflag.Flag
+}
+
+func (s *memoryStore) Get(ctx context.Context, tenantID, feature string) (featureflag.Flag, error) {
+	f, ok := s.flags[tenantID+"/"+feature]
+	if !ok {
+		return featureflag.Flag{}, sql.ErrNoRows
+	}
+	return f, nil
+}
+
+func (s *memoryStore) List(ctx context.Context, tenantID string) ([]featureflag.Flag, error) {
+	var out []featureflag.Flag
+	for key, f := range s.flags {
+		if strings.HasPrefix(key, tenantID+"/") {
+			out = append(out, f)
+		}
+	}
+	return out, nil
+}
+
+func (s *memoryStore) Set(ctx context.Context, tenantID string, flag featureflag.Flag) (featureflag.Flag, error) {
+	flag.UpdatedAt = time.Now()
+	s.flags[tenantID+"/"+flag.Feature] = flag
+	return flag, nil
+}
+
+// newFeatureRouter serves the feature flag API and a /api/v1/campaigns route
+// gated on the campaigns feature.
+func newFeatureRouter(t *testing.T, lic *license.License) (*gin.Engine, *memoryStore) {
+	t.Helper()
+	store := &memoryStore{flags: make(map[string]featureflag.Flag)}
+	svc := featureflag.NewService(store, lic)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(apierr.Handler(zap.NewNop()))
+	api := r.Group("/api/v1")
+	api.Use(middleware.TenantExtractor(middleware.TenantConfig{}))
+	featureflag.NewHTTPHandler(svc, zap.NewNop()).RegisterRoutes(api)
+	api.GET("/campaigns", featureflag.Require(svc, featureflag.FeatureCampaigns), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	return r, store
+}
+
+func doFeatureRequest(r *gin.Engine, method, path string, body any) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		_ = json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(middleware.DefaultTenantHeader, testTenant)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestFeatureFlagOnOff(t *testing.T) {
+	r, _ := newFeatureRouter(t, nil)
+
+	// Features are on until the tenant turns them off.
+	if w := doFeatureRequest(r, http.MethodGet, "/api/v1/campaigns", nil); w.Code != http.StatusNoContent {
+		t.Fatalf("default: expected 204, got %d", w.Code)
+	}
+
+	w := doFeatureRequest(r, http.MethodPut, "/api/v1/features/campaigns", map[string]any{"enabled": false})
+	if w.Code != http.StatusOK {
+		t.Fatalf("disable: expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var state featureflag.State
+	_ = json.Unmarshal(w.Body.Bytes(), &state)
+	if state.Enabled || !state.Licensed {
+		t.Fatalf("expected a licensed, disabled feature, got %+v", state)
+	}
+	if w := doFeatureRequest(r, http.MethodGet, "/api/v1/campaigns", nil); w.Code != http.StatusForbidden {
+		t.Fatalf("disabled: expected 403, got %d", w.Code)
+	}
+
+	if w := doFeatureRequest(r, http.MethodPut, "/api/v1/features/campaigns", map[string]any{"enabled": true}); w.Code != http.StatusOK {
+		t.Fatalf("enable: expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if w := doFeatureRequest(r, http.MethodGet, "/api/v1/campaigns", nil); w.Code != http.StatusNoContent {
+		t.Fatalf("re-enabled: expected 204, got %d", w.Code)
+	}
+
+	w = doFeatureRequest(r, http.MethodPut, "/api/v1/features/campaigns", map[string]any{})
+	var invalid struct {
+		Error  string              `json:"error"`
+		Fields []apierr.FieldError `json:"fields"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &invalid)
+	if w.Code != http.StatusBadRequest || invalid.Error != "enabled is required" || len(invalid.Fields) != 1 {
+		t.Fatalf("missing enabled: expected 400 naming the field, got %d %s", w.Code, w.Body)
+	}
+	if w := doFeatureRequest(r, http.MethodPut, "/api/v1/features/unknown", map[string]any{"enabled": true}); w.Code != http.StatusNotFound {
+		t.Fatalf("unknown feature: expected 404, got %d", w.Code)
+	}
+}
+
+func TestFeatureFlagLicenseDenial(t *testing.T) {
+	r, store := newFeatureRouter(t, &license.License{Features: []string{featureflag.FeatureMFA, featureflag.FeatureSSO}})
+
+	w := doFeatureRequest(r, http.MethodPut, "/api/v1/features/campaigns", map[string]any{"enabled": true})
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("enable unlicensed: expected 403, got %d: %s", w.Code, w.Body)
+	}
+	if len(store.flags) != 0 {
+		t.Fatalf("expected no flag to be stored, got %v", store.flags)
+	}
+	if w := doFeatureRequest(r, http.MethodGet, "/api/v1/campaigns", nil); w.Code != http.StatusForbidden {
+		t.Fatalf("unlicensed: expected 403, got %d", w.Code)
+	}
+
+	// A flag left on from before a license change does not outlive the license.
+	store.flags[testTenant+"/"+featureflag.FeatureCampaigns] = featureflag.Flag{Feature: featureflag.FeatureCampaigns, Enabled: true}
+	if w := doFeatureRequest(r, http.MethodGet, "/api/v1/campaigns", nil); w.Code != http.StatusForbidden {
+		t.Fatalf("unlicensed with a stored flag: expected 403, got %d", w.Code)
+	}
+
+	// Disabling an unlicensed feature is allowed.
+	if w := doFeatureRequest(r, http.MethodPut, "/api/v1/features/campaigns", map[string]any{"enabled": false}); w.Code != http.StatusOK {
+		t.Fatalf("disable unlicensed: expected 200, got %d: %s", w.Code, w.Body)
+	}
+
+	w = doFeatureRequest(r, http.MethodGet, "/api/v1/features", nil)
+	var list struct {
+		Features []featureflag.State `json:"features"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("list: expected 200, got %d %s", w.Code, w.Body)
+	}
+	want := map[string]featureflag.State{
+		featureflag.FeatureMFA:       {Feature: featureflag.FeatureMFA, Enabled: true, Licensed: true},
+		featureflag.FeatureCampaigns: {Feature: featureflag.FeatureCampaigns},
+		featureflag.FeatureSSO:       {Feature: featureflag.FeatureSSO, Enabled: true, Licensed: true},
+	}
+	if len(list.Features) != len(want) {
+		t.Fatalf("expected %d features, got %+v", len(want), list.Features)
+	}
+	for _, got := range list.Features {
+		if got != want[got.Feature] {
+			t.Fatalf("feature %s: expected %+v, got %+v", got.Feature, want[got.Feature], got)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package featureflag
+
+import (
+	"net/http"
+
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// Require returns a middleware that lets the request through only when
+// feature is enabled for the request's tenant. It must run after
+// middleware.TenantExtractor. Requests for a disabled feature get 403.
+func Require(flags Checker, feature string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenantID, err := middleware.TenantIDFromGinContext(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "tenant id required"})
+			return
+		}
+		if !flags.IsEnabled(c.Request.Context(), tenantID, feature) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":   "feature not enabled",
+				"feature": feature,
+			})
+			return
+		}
+		c.Next()
+	}
+}
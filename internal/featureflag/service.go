@@ -0,0 +1,132 @@
+package featureflag
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/dhawalhost/wardseal/internal/license"
+	"github.com/dhawalhost/wardseal/pkg/apierr"
+)
+
+// Features that can be turned on and off per tenant. The names match the
+// feature names issued in licenses.
+const (
+	FeatureMFA       = "mfa"
+	FeatureCampaigns = "campaigns"
+	FeatureSSO       = "sso"
+)
+
+// Features lists the known features in the order they are reported.
+var Features = []string{FeatureMFA, FeatureCampaigns, FeatureSSO}
+
+// State is a feature's effective state for a tenant.
+type State struct {
+	Feature string `json:"feature"`
+	Enabled bool   `json:"enabled"`
+	// Licensed reports whether the license allows the feature at all.
+	Licensed bool `json:"licensed"`
+}
+
+// Checker reports whether a feature is enabled for a tenant. Service
+// implements it.
+type Checker interface {
+	IsEnabled(ctx context.Context, tenantID, feature string) bool
+}
+
+// Service defines feature flag operations.
+type Service interface {
+	Checker
+	// ListFeatures returns the effective state of every known feature.
+	ListFeatures(ctx context.Context, tenantID string) ([]State, error)
+	// SetFeature enables or disables a feature for the tenant. Enabling a
+	// feature the license does not include is forbidden.
+	SetFeature(ctx context.Context, tenantID, feature string, enabled bool) (State, error)
+}
+
+type service struct {
+	store   Store
+	license *license.License
+}
+
+// NewService creates a new feature flag service bounded by lic. A nil lic,
+// as when no license is required, allows every feature.
+func NewService(store Store, lic *license.License) Service {
+	return &service{store: store, license: lic}
+}
+
+// licensed reports whether the license allows feature.
+func (s *service) licensed(feature string) bool {
+	return s.license == nil || s.license.HasFeature(feature)
+}
+
+// IsEnabled reports whether feature is on for the tenant. Unlicensed features
+// are always off. Features the tenant has not set are on, so existing tenants
+// keep their features until an administrator turns them off. Store errors
+// fail closed.
+func (s *service) IsEnabled(ctx context.Context, tenantID, feature string) bool {
+	state, err := s.state(ctx, tenantID, feature)
+	return err == nil && state.Enabled
+}
+
+func (s *service) state(ctx context.Context, tenantID, feature string) (State, error) {
+	state := State{Feature: feature, Licensed: s.licensed(feature)}
+	if !state.Licensed {
+		return state, nil
+	}
+	flag, err := s.store.Get(ctx, tenantID, feature)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		state.Enabled = true
+	case err != nil:
+		return State{}, err
+	default:
+		state.Enabled = flag.Enabled
+	}
+	return state, nil
+}
+
+func (s *service) ListFeatures(ctx context.Context, tenantID string) ([]State, error) {
+	flags, err := s.store.List(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[string]bool, len(flags))
+	for _, f := range flags {
+		set[f.Feature] = f.Enabled
+	}
+
+	states := make([]State, 0, len(Features))
+	for _, feature := range Features {
+		state := State{Feature: feature, Licensed: s.licensed(feature)}
+		if state.Licensed {
+			enabled, ok := set[feature]
+			state.Enabled = !ok || enabled
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+func (s *service) SetFeature(ctx context.Context, tenantID, feature string, enabled bool) (State, error) {
+	if !known(feature) {
+		return State{}, apierr.NotFound(fmt.Sprintf("unknown feature: %s", feature))
+	}
+	if enabled && !s.licensed(feature) {
+		return State{}, apierr.Forbidden(fmt.Sprintf("feature %s is not included in the license", feature))
+	}
+	if _, err := s.store.Set(ctx, tenantID, Flag{Feature: feature, Enabled: enabled}); err != nil {
+		return State{}, err
+	}
+	return s.state(ctx, tenantID, feature)
+}
+
+func known(feature string) bool {
+	for _, f := range Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package featureflag
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Flag is a tenant's explicit setting for a feature.
+type Flag struct {
+	Feature   string    `json:"feature" db:"feature"`
+	Enabled   bool      `json:"enabled" db:"enabled"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Store defines feature flag storage operations. Get returns sql.ErrNoRows
+// for features the tenant has not set.
+type Store interface {
+	Get(ctx context.Context, tenantID, feature string) (Flag, error)
+	List(ctx context.Context, tenantID string) ([]Flag, error)
+	Set(ctx context.Context, tenantID string, flag Flag) (Flag, error)
+}
+
+type store struct {
+	db *sqlx.DB
+}
+
+// NewStore creates a new feature flag store.
+func NewStore(db *sqlx.DB) Store {
+	return &store{db: db}
+}
+
+func (s *store) Get(ctx context.Context, tenantID, feature string) (Flag, error) {
+	var f Flag
+	err := s.db.GetContext(ctx, &f,
+		`SELECT feature, enabled, updated_at FROM feature_flags WHERE tenant_id = $1 AND feature = $2`,
+		tenantID, feature)
+	return f, err
+}
+
+func (s *store) List(ctx context.Context, tenantID string) ([]Flag, error) {
+	flags := []Flag{}
+	err := s.db.SelectContext(ctx, &flags,
+		`SELECT feature, enabled, updated_at FROM feature_flags WHERE tenant_id = $1 ORDER BY feature`,
+		tenantID)
+	return flags, err
+}
+
+func (s *store) Set(ctx context.Context, tenantID string, flag Flag) (Flag, error) {
+	var f Flag
+	err := s.db.GetContext(ctx, &f,
+		`INSERT INTO feature_flags (tenant_id, feature, enabled)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (tenant_id, feature)
+		DO UPDATE SET enabled = EXCLUDED.enabled, updated_at = NOW()
+		RETURNING feature, enabled, updated_at`,
+		tenantID, flag.Feature, flag.Enabled)
+	return f, err
+}
@@ -3,8 +3,10 @@ package governance
 import (
 	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/dhawalhost/wardseal/internal/oauthclient"
+	"github.com/dhawalhost/wardseal/pkg/apierr"
 	"github.com/dhawalhost/wardseal/pkg/middleware"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -12,8 +14,9 @@ import (
 
 // HTTPHandler represents the HTTP API handlers for the governance service.
 type HTTPHandler struct {
-	svc    Service
-	logger *zap.Logger
+	svc              Service
+	logger           *zap.Logger
+	idempotencyStore middleware.IdempotencyStore
 }
 
 // NewHTTPHandler creates a new HTTPHandler.
@@ -21,16 +24,25 @@ func NewHTTPHandler(svc Service, logger *zap.Logger) *HTTPHandler {
 	return &HTTPHandler{svc: svc, logger: logger}
 }
 
+// UseIdempotencyStore keeps Idempotency-Key responses in store, e.g. one from
+// NewIdempotencyStore shared by all instances. Without it they are kept in
+// memory. Call before RegisterRoutes.
+func (h *HTTPHandler) UseIdempotencyStore(store middleware.IdempotencyStore) {
+	h.idempotencyStore = store
+}
+
 // RegisterRoutes registers the governance routes.
 func (h *HTTPHandler) RegisterRoutes(router *gin.Engine) {
 	router.GET("/health", h.healthCheck)
 
 	tenantGroup := router.Group("/api/v1")
 	tenantGroup.Use(middleware.TenantExtractor(middleware.TenantConfig{}))
+	// Create routes replay the first response for a retried Idempotency-Key.
+	idempotent := middleware.Idempotency(middleware.IdempotencyConfig{Store: h.idempotencyStore})
 	clients := tenantGroup.Group("/oauth/clients")
 	{
 		clients.GET("", h.listOAuthClients)
-		clients.POST("", h.createOAuthClient)
+		clients.POST("", idempotent, h.createOAuthClient)
 		clients.GET("/:clientID", h.getOAuthClient)
 		clients.PUT("/:clientID", h.updateOAuthClient)
 		clients.DELETE("/:clientID", h.deleteOAuthClient)
@@ -38,7 +50,7 @@ func (h *HTTPHandler) RegisterRoutes(router *gin.Engine) {
 
 	requests := tenantGroup.Group("/governance/requests")
 	{
-		requests.POST("", h.createAccessRequest)
+		requests.POST("", idempotent, h.createAccessRequest)
 		requests.GET("", h.listAccessRequests)
 		requests.POST("/:accessRequestID/approve", h.approveAccessRequest)
 		requests.POST("/:accessRequestID/reject", h.rejectAccessRequest)
@@ -48,8 +60,7 @@ func (h *HTTPHandler) RegisterRoutes(router *gin.Engine) {
 func (h *HTTPHandler) healthCheck(c *gin.Context) {
 	ok, err := h.svc.HealthCheck(c.Request.Context())
 	if err != nil {
-		h.logger.Error("Health check failed", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierr.Abort(c, apierr.Internal(err))
 		return
 	}
 	c.JSON(http.StatusOK, HealthCheckResponse{Healthy: ok})
@@ -60,7 +71,24 @@ func (h *HTTPHandler) listOAuthClients(c *gin.Context) {
 	if !ok {
 		return
 	}
-	clients, err := h.svc.ListOAuthClients(c.Request.Context(), tenantID)
+	input := ListOAuthClientsInput{
+		ClientType: c.Query("client_type"),
+		Query:      c.Query("q"),
+	}
+	var err error
+	if v := c.Query("limit"); v != "" {
+		if input.Limit, err = strconv.Atoi(v); err != nil {
+			apierr.Abort(c, apierr.Invalid("limit must be an integer"))
+			return
+		}
+	}
+	if v := c.Query("offset"); v != "" {
+		if input.Offset, err = strconv.Atoi(v); err != nil {
+			apierr.Abort(c, apierr.Invalid("offset must be an integer"))
+			return
+		}
+	}
+	clients, total, err := h.svc.ListOAuthClients(c.Request.Context(), tenantID, input)
 	if err != nil {
 		h.handleServiceError(c, err)
 		return
@@ -69,7 +97,8 @@ func (h *HTTPHandler) listOAuthClients(c *gin.Context) {
 	for _, client := range clients {
 		responses = append(responses, newOAuthClientResponse(client))
 	}
-	c.JSON(http.StatusOK, gin.H{"clients": responses})
+	middleware.SetPaginationHeaders(c, "", oauthClientPageSize(input.Limit), input.Offset, total)
+	c.JSON(http.StatusOK, gin.H{"clients": responses, "total": total})
 }
 
 func (h *HTTPHandler) getOAuthClient(c *gin.Context) {
@@ -94,7 +123,7 @@ func (h *HTTPHandler) createOAuthClient(c *gin.Context) {
 	var req createOAuthClientRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		h.logger.Error("Failed to bind create oauth client request", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierr.Abort(c, apierr.Validation(err))
 		return
 	}
 	client, err := h.svc.CreateOAuthClient(c.Request.Context(), tenantID, CreateOAuthClientInput(req))
@@ -114,7 +143,7 @@ func (h *HTTPHandler) updateOAuthClient(c *gin.Context) {
 	var req updateOAuthClientRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		h.logger.Error("Failed to bind update oauth client request", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierr.Abort(c, apierr.Validation(err))
 		return
 	}
 	client, err := h.svc.UpdateOAuthClient(c.Request.Context(), tenantID, clientID, UpdateOAuthClientInput(req))
@@ -136,7 +165,6 @@ func (h *HTTPHandler) deleteOAuthClient(c *gin.Context) {
 		return
 	}
 	c.Status(http.StatusNoContent)
-	c.Status(http.StatusNoContent)
 }
 
 func (h *HTTPHandler) createAccessRequest(c *gin.Context) {
@@ -147,7 +175,7 @@ func (h *HTTPHandler) createAccessRequest(c *gin.Context) {
 	var req CreateAccessRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		h.logger.Error("Failed to bind create access request", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierr.Abort(c, apierr.Validation(err))
 		return
 	}
 	resp, err := h.svc.CreateAccessRequest(c.Request.Context(), tenantID, req)
@@ -211,21 +239,21 @@ func (h *HTTPHandler) tenantID(c *gin.Context) (string, bool) {
 	tenantID, err := middleware.TenantIDFromGinContext(c)
 	if err != nil {
 		h.logger.Error("tenant id missing", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": "tenant id required"})
+		apierr.Abort(c, apierr.Invalid("tenant id required"))
 		return "", false
 	}
 	return tenantID, true
 }
 
+// handleServiceError maps err onto an API error for apierr.Handler to render.
 func (h *HTTPHandler) handleServiceError(c *gin.Context, err error) {
-	if IsValidationError(err) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-	if errors.Is(err, oauthclient.ErrNotFound) {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-		return
+	switch {
+	case oauthclient.IsScopeNotAllowed(err):
+		err = apierr.Invalid(err.Error()).Wrap(err)
+	case errors.Is(err, oauthclient.ErrNotFound):
+		err = apierr.NotFound(err.Error()).Wrap(err)
+	case errors.Is(err, oauthclient.ErrConcurrentModification):
+		err = apierr.Conflict(err.Error()).Wrap(err)
 	}
-	h.logger.Error("governance service error", zap.Error(err))
-	c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	apierr.Abort(c, err)
 }
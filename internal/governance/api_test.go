@@ -7,9 +7,11 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/dhawalhost/wardseal/internal/oauthclient"
+	"github.com/dhawalhost/wardseal/pkg/apierr"
 	"github.com/dhawalhost/wardseal/pkg/middleware"
 	"github.com/gin-gonic/gin"
 	"github.com/lib/pq"
@@ -19,7 +21,7 @@ import (
 func TestListOAuthClientsReturnsClients(t *testing.T) {
 	tenantID := "11111111-1111-1111-1111-111111111111"
 	stub := &stubService{
-		listOAuthClientsFn: func(ctx context.Context, gotTenant string) ([]oauthclient.Client, error) {
+		listOAuthClientsFn: func(ctx context.Context, gotTenant string, input ListOAuthClientsInput) ([]oauthclient.Client, int, error) {
 			if gotTenant != tenantID {
 				t.Fatalf("unexpected tenant id: %s", gotTenant)
 			}
@@ -34,7 +36,7 @@ func TestListOAuthClientsReturnsClients(t *testing.T) {
 					"openid",
 					"profile",
 				},
-			}}, nil
+			}}, 1, nil
 		},
 	}
 
@@ -65,6 +67,137 @@ func TestListOAuthClientsReturnsClients(t *testing.T) {
 	}
 }
 
+func newListingRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	store := &fakeStore{}
+	for _, c := range []struct{ id, clientType, name string }{
+		{"batch-worker", "confidential", "Batch Worker"},
+		{"dashboard", "public", "Admin Dashboard"},
+		{"mobile", "public", "Mobile App"},
+		{"reports", "confidential", "Reports Service"},
+		{"web", "public", "Web App"},
+	} {
+		if _, err := store.CreateClient(context.Background(), oauthclient.CreateClientParams{
+			TenantID:   "11111111-1111-1111-1111-111111111111",
+			ClientID:   c.id,
+			ClientType: c.clientType,
+			Name:       c.name,
+		}); err != nil {
+			t.Fatalf("seed client: %v", err)
+		}
+	}
+	return newTestRouter(t, NewService(store, nil, nil))
+}
+
+func listClients(t *testing.T, router *gin.Engine, query string) (int, []string, int) {
+	t.Helper()
+	resp := performRequest(router, http.MethodGet, "/api/v1/oauth/clients"+query, nil, map[string]string{
+		middleware.DefaultTenantHeader: "11111111-1111-1111-1111-111111111111",
+	})
+	var payload struct {
+		Clients []OAuthClientResponse `json:"clients"`
+		Total   int                   `json:"total"`
+	}
+	if resp.Code == http.StatusOK {
+		decodeJSON(t, resp.Body.Bytes(), &payload)
+	}
+	ids := make([]string, 0, len(payload.Clients))
+	for _, c := range payload.Clients {
+		ids = append(ids, c.ClientID)
+	}
+	return resp.Code, ids, payload.Total
+}
+
+func TestListOAuthClientsFiltersByType(t *testing.T) {
+	router := newListingRouter(t)
+
+	code, ids, total := listClients(t, router, "?client_type=confidential")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if strings.Join(ids, ",") != "batch-worker,reports" || total != 2 {
+		t.Fatalf("unexpected confidential clients %v (total %d)", ids, total)
+	}
+
+	_, ids, total = listClients(t, router, "?client_type=public&q=app")
+	if strings.Join(ids, ",") != "mobile,web" || total != 2 {
+		t.Fatalf("unexpected public clients matching q %v (total %d)", ids, total)
+	}
+
+	if code, _, _ := listClients(t, router, "?client_type=service"); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown client_type, got %d", code)
+	}
+}
+
+func TestListOAuthClientsPaginates(t *testing.T) {
+	router := newListingRouter(t)
+
+	code, ids, total := listClients(t, router, "?limit=2&offset=2")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if strings.Join(ids, ",") != "mobile,reports" || total != 5 {
+		t.Fatalf("unexpected page %v (total %d)", ids, total)
+	}
+
+	_, ids, total = listClients(t, router, "?limit=2&offset=4")
+	if strings.Join(ids, ",") != "web" || total != 5 {
+		t.Fatalf("unexpected last page %v (total %d)", ids, total)
+	}
+
+	for _, query := range []string{"?limit=abc", "?offset=-1"} {
+		if code, _, _ := listClients(t, router, query); code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %s, got %d", query, code)
+		}
+	}
+}
+
+func TestListOAuthClientsLinksPages(t *testing.T) {
+	router := newListingRouter(t)
+	tests := []struct {
+		query    string
+		wantLink string
+	}{
+		{"?limit=2", `</api/v1/oauth/clients?limit=2&offset=2>; rel="next"`},
+		{"?limit=2&offset=4", `</api/v1/oauth/clients?limit=2&offset=2>; rel="prev"`},
+		{"?limit=5", ""},
+	}
+	for _, tt := range tests {
+		resp := performRequest(router, http.MethodGet, "/api/v1/oauth/clients"+tt.query, nil, map[string]string{
+			middleware.DefaultTenantHeader: "11111111-1111-1111-1111-111111111111",
+		})
+		if got := resp.Header().Get("Link"); got != tt.wantLink {
+			t.Fatalf("%s: expected Link %q, got %q", tt.query, tt.wantLink, got)
+		}
+		if got := resp.Header().Get(middleware.TotalCountHeader); got != "5" {
+			t.Fatalf("%s: expected total 5, got %q", tt.query, got)
+		}
+	}
+}
+
+func TestListOAuthClientsCapsLimit(t *testing.T) {
+	svc := NewService(&limitRecordingStore{onList: func(filter oauthclient.ListFilter) {
+		if filter.Limit != MaxOAuthClientPageSize {
+			t.Fatalf("expected limit capped at %d, got %d", MaxOAuthClientPageSize, filter.Limit)
+		}
+	}}, nil, nil)
+	router := newTestRouter(t, svc)
+
+	if code, _, _ := listClients(t, router, "?limit=100000"); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+}
+
+type limitRecordingStore struct {
+	fakeStore
+	onList func(filter oauthclient.ListFilter)
+}
+
+func (s *limitRecordingStore) ListClientsPage(ctx context.Context, tenantID string, filter oauthclient.ListFilter) ([]oauthclient.Client, int, error) {
+	s.onList(filter)
+	return nil, 0, nil
+}
+
 func TestCreateOAuthClientValidationErrorPropagates(t *testing.T) {
 	stub := &stubService{
 		createOAuthClientFn: func(ctx context.Context, tenantID string, input CreateOAuthClientInput) (oauthclient.Client, error) {
@@ -96,11 +229,105 @@ func TestCreateOAuthClientValidationErrorPropagates(t *testing.T) {
 
 	var payload struct {
 		Error string `json:"error"`
+		Code  string `json:"code"`
 	}
 	decodeJSON(t, resp.Body.Bytes(), &payload)
 	if payload.Error != "invalid redirect" {
 		t.Fatalf("unexpected error message: %s", payload.Error)
 	}
+	if payload.Code != string(apierr.CodeInvalid) {
+		t.Fatalf("expected code %s, got %s", apierr.CodeInvalid, payload.Code)
+	}
+}
+
+func TestCreateOAuthClientScopeNotAllowed(t *testing.T) {
+	stub := &stubService{
+		createOAuthClientFn: func(ctx context.Context, tenantID string, input CreateOAuthClientInput) (oauthclient.Client, error) {
+			return oauthclient.Client{}, &oauthclient.ScopeNotAllowedError{Scopes: []string{"admin"}}
+		},
+	}
+	router := newTestRouter(t, stub)
+
+	body := mustJSONBody(t, map[string]interface{}{
+		"client_id":      "client-three",
+		"name":           "Client Three",
+		"client_type":    "public",
+		"redirect_uris":  []string{"https://example/app/callback"},
+		"allowed_scopes": []string{"openid", "admin"},
+	})
+	resp := performRequest(router, http.MethodPost, "/api/v1/oauth/clients", body, map[string]string{
+		middleware.DefaultTenantHeader: "11111111-1111-1111-1111-111111111111",
+		"Content-Type":                 "application/json",
+	})
+
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", resp.Code)
+	}
+	var payload struct {
+		Error string `json:"error"`
+	}
+	decodeJSON(t, resp.Body.Bytes(), &payload)
+	if payload.Error != "scopes not in tenant catalog: admin" {
+		t.Fatalf("unexpected error message: %s", payload.Error)
+	}
+}
+
+func TestUpdateOAuthClientStaleVersionConflicts(t *testing.T) {
+	stub := &stubService{
+		updateOAuthClientFn: func(ctx context.Context, tenantID, clientID string, input UpdateOAuthClientInput) (oauthclient.Client, error) {
+			if input.Version == nil || *input.Version != 3 {
+				t.Fatalf("expected version 3 to be parsed, got %v", input.Version)
+			}
+			return oauthclient.Client{}, oauthclient.ErrConcurrentModification
+		},
+	}
+	router := newTestRouter(t, stub)
+
+	body := mustJSONBody(t, map[string]interface{}{
+		"name":    "Renamed",
+		"version": 3,
+	})
+	resp := performRequest(router, http.MethodPut, "/api/v1/oauth/clients/client-one", body, map[string]string{
+		middleware.DefaultTenantHeader: "11111111-1111-1111-1111-111111111111",
+		"Content-Type":                 "application/json",
+	})
+
+	if resp.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", resp.Code)
+	}
+}
+
+func TestCreateOAuthClientReplaysIdempotencyKey(t *testing.T) {
+	calls := 0
+	stub := &stubService{
+		createOAuthClientFn: func(ctx context.Context, tenantID string, input CreateOAuthClientInput) (oauthclient.Client, error) {
+			calls++
+			return oauthclient.Client{TenantID: tenantID, ClientID: input.ClientID, ClientType: "public", Name: input.Name}, nil
+		},
+	}
+	router := newTestRouter(t, stub)
+
+	body := mustJSONBody(t, map[string]interface{}{
+		"client_id":      "client-retry",
+		"name":           "Client Retry",
+		"client_type":    "public",
+		"redirect_uris":  []string{"https://example/app/callback"},
+		"allowed_scopes": []string{"openid"},
+	})
+	headers := map[string]string{
+		middleware.DefaultTenantHeader:  "11111111-1111-1111-1111-111111111111",
+		middleware.IdempotencyKeyHeader: "create-client-retry",
+		"Content-Type":                  "application/json",
+	}
+	for i := 0; i < 2; i++ {
+		resp := performRequest(router, http.MethodPost, "/api/v1/oauth/clients", body, headers)
+		if resp.Code != http.StatusCreated {
+			t.Fatalf("attempt %d: expected 201, got %d", i+1, resp.Code)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected one client to be created, got %d", calls)
+	}
 }
 
 func TestGetOAuthClientNotFound(t *testing.T) {
@@ -118,6 +345,38 @@ func TestGetOAuthClientNotFound(t *testing.T) {
 	if resp.Code != http.StatusNotFound {
 		t.Fatalf("expected 404, got %d", resp.Code)
 	}
+	var payload struct {
+		Code string `json:"code"`
+	}
+	decodeJSON(t, resp.Body.Bytes(), &payload)
+	if payload.Code != string(apierr.CodeNotFound) {
+		t.Fatalf("expected code %s, got %s", apierr.CodeNotFound, payload.Code)
+	}
+}
+
+func TestGetOAuthClientHidesInternalErrors(t *testing.T) {
+	stub := &stubService{
+		getOAuthClientFn: func(ctx context.Context, tenantID, clientID string) (oauthclient.Client, error) {
+			return oauthclient.Client{}, sql.ErrConnDone
+		},
+	}
+	router := newTestRouter(t, stub)
+
+	resp := performRequest(router, http.MethodGet, "/api/v1/oauth/clients/client-one", nil, map[string]string{
+		middleware.DefaultTenantHeader: "11111111-1111-1111-1111-111111111111",
+	})
+
+	if resp.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", resp.Code)
+	}
+	var payload struct {
+		Error string `json:"error"`
+		Code  string `json:"code"`
+	}
+	decodeJSON(t, resp.Body.Bytes(), &payload)
+	if payload.Code != string(apierr.CodeInternal) || payload.Error != "internal server error" {
+		t.Fatalf("unexpected internal error body: %s", resp.Body.String())
+	}
 }
 
 func TestRoutesRequireTenantHeader(t *testing.T) {
@@ -135,6 +394,7 @@ func newTestRouter(t *testing.T, svc Service) *gin.Engine {
 	t.Helper()
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
+	router.Use(apierr.Handler(zap.NewNop()))
 	handler := NewHTTPHandler(svc, zap.NewNop())
 	handler.RegisterRoutes(router)
 	return router
@@ -177,7 +437,7 @@ func decodeJSON(t *testing.T, data []byte, out interface{}) {
 
 type stubService struct {
 	healthCheckFn          func(ctx context.Context) (bool, error)
-	listOAuthClientsFn     func(ctx context.Context, tenantID string) ([]oauthclient.Client, error)
+	listOAuthClientsFn     func(ctx context.Context, tenantID string, input ListOAuthClientsInput) ([]oauthclient.Client, int, error)
 	getOAuthClientFn       func(ctx context.Context, tenantID, clientID string) (oauthclient.Client, error)
 	createOAuthClientFn    func(ctx context.Context, tenantID string, input CreateOAuthClientInput) (oauthclient.Client, error)
 	updateOAuthClientFn    func(ctx context.Context, tenantID, clientID string, input UpdateOAuthClientInput) (oauthclient.Client, error)
@@ -195,11 +455,11 @@ func (s *stubService) HealthCheck(ctx context.Context) (bool, error) {
 	return true, nil
 }
 
-func (s *stubService) ListOAuthClients(ctx context.Context, tenantID string) ([]oauthclient.Client, error) {
+func (s *stubService) ListOAuthClients(ctx context.Context, tenantID string, input ListOAuthClientsInput) ([]oauthclient.Client, int, error) {
 	if s.listOAuthClientsFn == nil {
 		panic("ListOAuthClients called unexpectedly")
 	}
-	return s.listOAuthClientsFn(ctx, tenantID)
+	return s.listOAuthClientsFn(ctx, tenantID, input)
 }
 
 func (s *stubService) GetOAuthClient(ctx context.Context, tenantID, clientID string) (oauthclient.Client, error) {
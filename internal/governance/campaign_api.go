@@ -3,6 +3,7 @@ package governance
 import (
 	"net/http"
 
+	"github.com/dhawalhost/wardseal/pkg/apierr"
 	"github.com/dhawalhost/wardseal/pkg/middleware"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -58,7 +59,7 @@ func (h *CampaignHTTPHandler) createCampaign(c *gin.Context) {
 
 	var input CreateCampaignInput
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierr.Abort(c, apierr.Validation(err))
 		return
 	}
 
@@ -172,7 +173,7 @@ func (h *CampaignHTTPHandler) addReviewItem(c *gin.Context) {
 	campaignID := c.Param("id")
 	var item CertificationItem
 	if err := c.ShouldBindJSON(&item); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierr.Abort(c, apierr.Validation(err))
 		return
 	}
 
@@ -0,0 +1,40 @@
+package governance
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dhawalhost/wardseal/internal/outbox"
+)
+
+// EventAccessRequestApproved is enqueued when an access request is approved.
+// Its payload is AccessRequestApproved.
+const EventAccessRequestApproved = "access_request.approved"
+
+// AccessRequestApproved is the payload of EventAccessRequestApproved.
+type AccessRequestApproved struct {
+	RequestID    string `json:"request_id"`
+	RequesterID  string `json:"requester_id"`
+	ResourceType string `json:"resource_type"`
+	ResourceID   string `json:"resource_id"`
+}
+
+// ProvisionApprovedAccess returns the outbox handler that grants approved
+// access: requests for a group add the requester to it in the directory.
+// Adding an existing member is harmless, so redelivery is safe.
+func ProvisionApprovedAccess(dirClient DirectoryClient) outbox.Handler {
+	return func(ctx context.Context, event outbox.Event) error {
+		var approved AccessRequestApproved
+		if err := event.Decode(&approved); err != nil {
+			return fmt.Errorf("decode %s: %w", event.Type, err)
+		}
+		switch approved.ResourceType {
+		case "group":
+			if err := dirClient.AddUserToGroup(ctx, event.TenantID, approved.RequesterID, approved.ResourceID); err != nil {
+				return fmt.Errorf("provisioning failed: %w", err)
+			}
+		}
+		// TODO: Handle 'app' resource type if needed
+		return nil
+	}
+}
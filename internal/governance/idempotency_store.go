@@ -0,0 +1,85 @@
+package governance
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/jmoiron/sqlx"
+)
+
+type idempotencyRepo struct {
+	db *sqlx.DB
+}
+
+// NewIdempotencyStore creates a SQL-backed middleware.IdempotencyStore, so
+// retried requests are deduplicated across instances.
+func NewIdempotencyStore(db *sqlx.DB) middleware.IdempotencyStore {
+	return &idempotencyRepo{db: db}
+}
+
+type idempotencyRow struct {
+	RequestHash string `db:"request_hash"`
+	Status      int    `db:"status"`
+	ContentType string `db:"content_type"`
+	Body        []byte `db:"body"`
+}
+
+func (r *idempotencyRepo) SaveIfAbsent(ctx context.Context, key string, resp middleware.IdempotentResponse, ttl time.Duration) (*middleware.IdempotentResponse, error) {
+	// An expired row is taken over rather than reported as present.
+	query := `INSERT INTO idempotency_keys (key, request_hash, status, content_type, body, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (key) DO UPDATE SET request_hash = EXCLUDED.request_hash, status = EXCLUDED.status,
+			content_type = EXCLUDED.content_type, body = EXCLUDED.body, expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at < NOW()`
+	result, err := r.db.ExecContext(ctx, query, key, resp.RequestHash, resp.Status, resp.ContentType, resp.Body, time.Now().Add(ttl))
+	if err != nil {
+		return nil, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rows == 1 {
+		return nil, nil
+	}
+
+	var row idempotencyRow
+	err = r.db.GetContext(ctx, &row, `SELECT request_hash, status, content_type, body FROM idempotency_keys WHERE key = $1`, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		// The holder released the key between the two statements; report it
+		// as still in progress so the caller retries.
+		return &middleware.IdempotentResponse{RequestHash: resp.RequestHash}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &middleware.IdempotentResponse{
+		RequestHash: row.RequestHash,
+		Status:      row.Status,
+		ContentType: row.ContentType,
+		Body:        row.Body,
+	}, nil
+}
+
+func (r *idempotencyRepo) Save(ctx context.Context, key string, resp middleware.IdempotentResponse, ttl time.Duration) error {
+	query := `INSERT INTO idempotency_keys (key, request_hash, status, content_type, body, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (key) DO UPDATE SET request_hash = EXCLUDED.request_hash, status = EXCLUDED.status,
+			content_type = EXCLUDED.content_type, body = EXCLUDED.body, expires_at = EXCLUDED.expires_at`
+	_, err := r.db.ExecContext(ctx, query, key, resp.RequestHash, resp.Status, resp.ContentType, resp.Body, time.Now().Add(ttl))
+	return err
+}
+
+func (r *idempotencyRepo) Delete(ctx context.Context, key string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE key = $1`, key)
+	return err
+}
+
+// CleanupExpired removes responses that are no longer replayed (can be run periodically).
+func (r *idempotencyRepo) CleanupExpired(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at < $1`, time.Now())
+	return err
+}
@@ -0,0 +1,77 @@
+package governance
+
+import (
+	"context"
+	"time"
+
+	"github.com/dhawalhost/wardseal/internal/oauthclient"
+)
+
+// ObserveFunc receives the name, duration and outcome of a Service call.
+type ObserveFunc func(operation string, duration time.Duration, err error)
+
+// instrumentedService times every call to the wrapped Service.
+type instrumentedService struct {
+	next    Service
+	observe ObserveFunc
+}
+
+// NewInstrumentedService wraps svc so each call is reported to observe, e.g.
+// to feed an operation latency histogram.
+func NewInstrumentedService(svc Service, observe ObserveFunc) Service {
+	return &instrumentedService{next: svc, observe: observe}
+}
+
+func (s *instrumentedService) record(operation string, start time.Time, err error) {
+	s.observe(operation, time.Since(start), err)
+}
+
+func (s *instrumentedService) HealthCheck(ctx context.Context) (ok bool, err error) {
+	defer func(start time.Time) { s.record("HealthCheck", start, err) }(time.Now())
+	return s.next.HealthCheck(ctx)
+}
+
+func (s *instrumentedService) ListOAuthClients(ctx context.Context, tenantID string, input ListOAuthClientsInput) (clients []oauthclient.Client, total int, err error) {
+	defer func(start time.Time) { s.record("ListOAuthClients", start, err) }(time.Now())
+	return s.next.ListOAuthClients(ctx, tenantID, input)
+}
+
+func (s *instrumentedService) GetOAuthClient(ctx context.Context, tenantID, clientID string) (client oauthclient.Client, err error) {
+	defer func(start time.Time) { s.record("GetOAuthClient", start, err) }(time.Now())
+	return s.next.GetOAuthClient(ctx, tenantID, clientID)
+}
+
+func (s *instrumentedService) CreateOAuthClient(ctx context.Context, tenantID string, input CreateOAuthClientInput) (client oauthclient.Client, err error) {
+	defer func(start time.Time) { s.record("CreateOAuthClient", start, err) }(time.Now())
+	return s.next.CreateOAuthClient(ctx, tenantID, input)
+}
+
+func (s *instrumentedService) UpdateOAuthClient(ctx context.Context, tenantID, clientID string, input UpdateOAuthClientInput) (client oauthclient.Client, err error) {
+	defer func(start time.Time) { s.record("UpdateOAuthClient", start, err) }(time.Now())
+	return s.next.UpdateOAuthClient(ctx, tenantID, clientID, input)
+}
+
+func (s *instrumentedService) DeleteOAuthClient(ctx context.Context, tenantID, clientID string) (err error) {
+	defer func(start time.Time) { s.record("DeleteOAuthClient", start, err) }(time.Now())
+	return s.next.DeleteOAuthClient(ctx, tenantID, clientID)
+}
+
+func (s *instrumentedService) CreateAccessRequest(ctx context.Context, tenantID string, input CreateAccessRequest) (req AccessRequest, err error) {
+	defer func(start time.Time) { s.record("CreateAccessRequest", start, err) }(time.Now())
+	return s.next.CreateAccessRequest(ctx, tenantID, input)
+}
+
+func (s *instrumentedService) ListAccessRequests(ctx context.Context, tenantID, status string) (reqs []AccessRequest, err error) {
+	defer func(start time.Time) { s.record("ListAccessRequests", start, err) }(time.Now())
+	return s.next.ListAccessRequests(ctx, tenantID, status)
+}
+
+func (s *instrumentedService) ApproveAccessRequest(ctx context.Context, tenantID, requestID, approverID, comment string) (err error) {
+	defer func(start time.Time) { s.record("ApproveAccessRequest", start, err) }(time.Now())
+	return s.next.ApproveAccessRequest(ctx, tenantID, requestID, approverID, comment)
+}
+
+func (s *instrumentedService) RejectAccessRequest(ctx context.Context, tenantID, requestID, approverID, comment string) (err error) {
+	defer func(start time.Time) { s.record("RejectAccessRequest", start, err) }(time.Now())
+	return s.next.RejectAccessRequest(ctx, tenantID, requestID, approverID, comment)
+}
@@ -0,0 +1,59 @@
+package governance
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/dhawalhost/wardseal/internal/oauthclient"
+	"github.com/dhawalhost/wardseal/pkg/apierr"
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/dhawalhost/wardseal/pkg/observability"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func TestMetricsCountGovernanceRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	metrics := observability.NewMetrics()
+	stub := &stubService{
+		listOAuthClientsFn: func(ctx context.Context, tenantID string, input ListOAuthClientsInput) ([]oauthclient.Client, int, error) {
+			return nil, 0, nil
+		},
+		getOAuthClientFn: func(ctx context.Context, tenantID, clientID string) (oauthclient.Client, error) {
+			return oauthclient.Client{}, errors.New("database unavailable")
+		},
+	}
+
+	// Wired as in cmd/govsvc.
+	router := gin.New()
+	router.Use(observability.PrometheusMiddleware(metrics, observability.PrometheusConfig{}))
+	router.Use(apierr.Handler(zap.NewNop()))
+	router.GET("/metrics", gin.WrapH(observability.PrometheusHandler()))
+	NewHTTPHandler(NewInstrumentedService(stub, metrics.RecordGovernanceOperation), zap.NewNop()).RegisterRoutes(router)
+
+	headers := map[string]string{middleware.DefaultTenantHeader: "11111111-1111-1111-1111-111111111111"}
+	if resp := performRequest(router, http.MethodGet, "/api/v1/oauth/clients", nil, headers); resp.Code != http.StatusOK {
+		t.Fatalf("list: unexpected status %d", resp.Code)
+	}
+	if resp := performRequest(router, http.MethodGet, "/api/v1/oauth/clients/web", nil, headers); resp.Code != http.StatusInternalServerError {
+		t.Fatalf("get: unexpected status %d", resp.Code)
+	}
+
+	resp := performRequest(router, http.MethodGet, "/metrics", nil, nil)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("metrics: unexpected status %d", resp.Code)
+	}
+	body := resp.Body.String()
+	for _, want := range []string{
+		`http_requests_total{code="200",method="GET",route="/api/v1/oauth/clients",tenant="other"} 1`,
+		`governance_operation_duration_seconds_count{operation="ListOAuthClients",result="success"} 1`,
+		`governance_operation_duration_seconds_count{operation="GetOAuthClient",result="error"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected %s in /metrics output", want)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/dhawalhost/wardseal/pkg/apierr"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -82,7 +83,7 @@ func (h *OrganizationHandler) createOrganization(c *gin.Context) {
 
 	var req CreateOrganizationRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierr.Abort(c, apierr.Validation(err))
 		return
 	}
 
@@ -154,7 +155,7 @@ func (h *OrganizationHandler) updateOrganization(c *gin.Context) {
 
 	var req UpdateOrganizationRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierr.Abort(c, apierr.Validation(err))
 		return
 	}
 
@@ -2,20 +2,21 @@ package governance
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"net/url"
 	"strings"
 
 	"github.com/dhawalhost/wardseal/internal/oauthclient"
 	"github.com/dhawalhost/wardseal/internal/policy"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/dhawalhost/wardseal/pkg/apierr"
 )
 
 // Service defines the interface for the governance service.
 type Service interface {
 	HealthCheck(ctx context.Context) (bool, error)
-	ListOAuthClients(ctx context.Context, tenantID string) ([]oauthclient.Client, error)
+	// ListOAuthClients returns one page of the tenant's clients and the total
+	// number matching the input's filters.
+	ListOAuthClients(ctx context.Context, tenantID string, input ListOAuthClientsInput) ([]oauthclient.Client, int, error)
 	GetOAuthClient(ctx context.Context, tenantID, clientID string) (oauthclient.Client, error)
 	CreateOAuthClient(ctx context.Context, tenantID string, input CreateOAuthClientInput) (oauthclient.Client, error)
 	UpdateOAuthClient(ctx context.Context, tenantID, clientID string, input UpdateOAuthClientInput) (oauthclient.Client, error)
@@ -28,6 +29,23 @@ type Service interface {
 	RejectAccessRequest(ctx context.Context, tenantID, requestID, approverID, comment string) error
 }
 
+// Page sizes for ListOAuthClients.
+const (
+	DefaultOAuthClientPageSize = 50
+	MaxOAuthClientPageSize     = 200
+)
+
+type ListOAuthClientsInput struct {
+	// ClientType filters by "public" or "confidential".
+	ClientType string
+	// Query matches a substring of the client ID or name.
+	Query string
+	// Limit defaults to DefaultOAuthClientPageSize and is capped at
+	// MaxOAuthClientPageSize.
+	Limit  int
+	Offset int
+}
+
 type CreateOAuthClientInput struct {
 	ClientID      string
 	Name          string
@@ -36,30 +54,45 @@ type CreateOAuthClientInput struct {
 	RedirectURIs  []string
 	AllowedScopes []string
 	ClientSecret  string
+	// BindTokens binds issued access tokens to the caller's IP subnet and user agent.
+	BindTokens bool
+	// FirstParty clients skip the user consent screen.
+	FirstParty bool
+	// PostLogoutRedirectURIs are the allowed post_logout_redirect_uri values.
+	PostLogoutRedirectURIs []string
+	// AllowedResources are the resource indicators (RFC 8707) the client may
+	// request tokens for.
+	AllowedResources []string
 }
 
 type UpdateOAuthClientInput struct {
-	Name          *string
-	Description   *string
-	ClientType    *string
-	RedirectURIs  []string
-	AllowedScopes []string
-	ClientSecret  *string
+	Name                   *string
+	Description            *string
+	ClientType             *string
+	RedirectURIs           []string
+	AllowedScopes          []string
+	ClientSecret           *string
+	BindTokens             *bool
+	FirstParty             *bool
+	PostLogoutRedirectURIs []string
+	AllowedResources       []string
+	// Version is the client version the update was based on. When set, the
+	// update fails if the client has changed since.
+	Version *int
 }
 
 type governanceService struct {
 	clientStore  oauthclient.Store
 	reqStore     Store
-	dirClient    DirectoryClient
 	policyEngine policy.Engine
 }
 
-// NewService creates a new governance service.
-func NewService(clientStore oauthclient.Store, reqStore Store, dirClient DirectoryClient, policyEngine policy.Engine) Service {
+// NewService creates a new governance service. Approved access is
+// provisioned by ProvisionApprovedAccess from the outbox.
+func NewService(clientStore oauthclient.Store, reqStore Store, policyEngine policy.Engine) Service {
 	return &governanceService{
 		clientStore:  clientStore,
 		reqStore:     reqStore,
-		dirClient:    dirClient,
 		policyEngine: policyEngine,
 	}
 }
@@ -68,11 +101,37 @@ func (s *governanceService) HealthCheck(ctx context.Context) (bool, error) {
 	return true, nil
 }
 
-func (s *governanceService) ListOAuthClients(ctx context.Context, tenantID string) ([]oauthclient.Client, error) {
+func (s *governanceService) ListOAuthClients(ctx context.Context, tenantID string, input ListOAuthClientsInput) ([]oauthclient.Client, int, error) {
 	if err := requireTenant(tenantID); err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+	filter := oauthclient.ListFilter{
+		Query:  strings.TrimSpace(input.Query),
+		Limit:  input.Limit,
+		Offset: input.Offset,
+	}
+	if input.ClientType != "" {
+		filter.ClientType = normalizedClientType(input.ClientType)
+		if filter.ClientType != "public" && filter.ClientType != "confidential" {
+			return nil, 0, validationError("client_type must be public or confidential")
+		}
 	}
-	return s.clientStore.ListClientsByTenant(ctx, tenantID)
+	if filter.Limit < 0 || filter.Offset < 0 {
+		return nil, 0, validationError("limit and offset must not be negative")
+	}
+	filter.Limit = oauthClientPageSize(filter.Limit)
+	return s.clientStore.ListClientsPage(ctx, tenantID, filter)
+}
+
+// oauthClientPageSize applies the default and the cap to a requested page size.
+func oauthClientPageSize(limit int) int {
+	switch {
+	case limit == 0:
+		return DefaultOAuthClientPageSize
+	case limit > MaxOAuthClientPageSize:
+		return MaxOAuthClientPageSize
+	}
+	return limit
 }
 
 func (s *governanceService) GetOAuthClient(ctx context.Context, tenantID, clientID string) (oauthclient.Client, error) {
@@ -97,14 +156,18 @@ func (s *governanceService) CreateOAuthClient(ctx context.Context, tenantID stri
 		return oauthclient.Client{}, err
 	}
 	params := oauthclient.CreateClientParams{
-		TenantID:         tenantID,
-		ClientID:         input.ClientID,
-		ClientType:       normalizedClientType(input.ClientType),
-		Name:             input.Name,
-		Description:      nullableString(input.Description),
-		RedirectURIs:     append([]string(nil), input.RedirectURIs...),
-		AllowedScopes:    append([]string(nil), input.AllowedScopes...),
-		ClientSecretHash: hash,
+		TenantID:               tenantID,
+		ClientID:               input.ClientID,
+		ClientType:             normalizedClientType(input.ClientType),
+		Name:                   input.Name,
+		Description:            nullableString(input.Description),
+		RedirectURIs:           append([]string(nil), input.RedirectURIs...),
+		AllowedScopes:          append([]string(nil), input.AllowedScopes...),
+		ClientSecretHash:       hash,
+		BindTokens:             input.BindTokens,
+		FirstParty:             input.FirstParty,
+		PostLogoutRedirectURIs: append([]string(nil), input.PostLogoutRedirectURIs...),
+		AllowedResources:       append([]string(nil), input.AllowedResources...),
 	}
 	return s.clientStore.CreateClient(ctx, params)
 }
@@ -121,19 +184,24 @@ func (s *governanceService) UpdateOAuthClient(ctx context.Context, tenantID, cli
 	}
 	var secretHash *[]byte
 	if input.ClientSecret != nil {
-		hash, err := bcrypt.GenerateFromPassword([]byte(*input.ClientSecret), bcrypt.DefaultCost)
+		hash, err := oauthclient.HashSecret(*input.ClientSecret)
 		if err != nil {
 			return oauthclient.Client{}, err
 		}
 		secretHash = &hash
 	}
 	params := oauthclient.UpdateClientParams{
-		Name:             input.Name,
-		Description:      input.Description,
-		RedirectURIs:     cloneSlice(input.RedirectURIs),
-		AllowedScopes:    cloneSlice(input.AllowedScopes),
-		ClientType:       normalizeClientTypePtr(input.ClientType),
-		ClientSecretHash: secretHash,
+		Name:                   input.Name,
+		Description:            input.Description,
+		RedirectURIs:           cloneSlice(input.RedirectURIs),
+		AllowedScopes:          cloneSlice(input.AllowedScopes),
+		ClientType:             normalizeClientTypePtr(input.ClientType),
+		ClientSecretHash:       secretHash,
+		BindTokens:             input.BindTokens,
+		FirstParty:             input.FirstParty,
+		PostLogoutRedirectURIs: cloneSlice(input.PostLogoutRedirectURIs),
+		AllowedResources:       cloneSlice(input.AllowedResources),
+		ExpectedVersion:        input.Version,
 	}
 	return s.clientStore.UpdateClient(ctx, tenantID, clientID, params)
 }
@@ -148,16 +216,8 @@ func (s *governanceService) DeleteOAuthClient(ctx context.Context, tenantID, cli
 	return s.clientStore.DeleteClient(ctx, tenantID, clientID)
 }
 
-type validationErr struct {
-	msg string
-}
-
-func (e *validationErr) Error() string {
-	return e.msg
-}
-
 func validationError(msg string) error {
-	return &validationErr{msg: msg}
+	return apierr.Invalid(msg)
 }
 
 func (s *governanceService) CreateAccessRequest(ctx context.Context, tenantID string, input CreateAccessRequest) (AccessRequest, error) {
@@ -208,30 +268,24 @@ func (s *governanceService) ApproveAccessRequest(ctx context.Context, tenantID,
 
 	// Evaluate policy
 	input := policy.Input{
+		TenantID: tenantID,
 		Subject:  policy.Subject{ID: approverID},
 		Action:   "approve",
 		Resource: policy.Resource{Type: "access_request", ID: requestID},
 		Context:  map[string]interface{}{"requester_id": req.RequesterID},
 	}
-	allowed, reason, err := s.policyEngine.Evaluate(ctx, input)
+	decision, err := s.policyEngine.Evaluate(ctx, input)
 	if err != nil {
 		return fmt.Errorf("policy evaluation failed: %w", err)
 	}
-	if !allowed {
-		return fmt.Errorf("policy violation: %s", reason)
-	}
-
-	// Provision the access
-	if req.ResourceType == "group" {
-		if err := s.dirClient.AddUserToGroup(ctx, tenantID, req.RequesterID, req.ResourceID); err != nil {
-			return fmt.Errorf("provisioning failed: %w", err)
-		}
+	if !decision.Allowed {
+		return fmt.Errorf("policy violation: %s", decision.Reason)
 	}
-	// TODO: Handle 'app' resource type if needed
 
-	// Update status to approved
-	if err := s.reqStore.UpdateRequestStatus(ctx, requestID, "approved"); err != nil {
-		return err
+	// Mark the request approved and queue its provisioning atomically; the
+	// outbox relay grants the access once the approval has committed.
+	if err := s.reqStore.ApproveRequest(ctx, req); err != nil {
+		return fmt.Errorf("failed to approve request: %w", err)
 	}
 	return nil
 }
@@ -268,6 +322,12 @@ func validateCreateInput(input CreateOAuthClientInput) error {
 			return validationError(fmt.Sprintf("invalid redirect_uri %s", uri))
 		}
 	}
+	if err := validatePostLogoutRedirectURIs(input.PostLogoutRedirectURIs); err != nil {
+		return err
+	}
+	if err := validateAllowedResources(input.AllowedResources); err != nil {
+		return err
+	}
 	if len(input.AllowedScopes) == 0 {
 		return validationError("allowed_scopes must include at least one scope")
 	}
@@ -288,6 +348,29 @@ func validateUpdateInput(input UpdateOAuthClientInput) error {
 			return validationError(fmt.Sprintf("invalid redirect_uri %s", uri))
 		}
 	}
+	if err := validatePostLogoutRedirectURIs(input.PostLogoutRedirectURIs); err != nil {
+		return err
+	}
+	return validateAllowedResources(input.AllowedResources)
+}
+
+func validatePostLogoutRedirectURIs(uris []string) error {
+	for _, uri := range uris {
+		if _, err := url.ParseRequestURI(uri); err != nil {
+			return validationError(fmt.Sprintf("invalid post_logout_redirect_uri %s", uri))
+		}
+	}
+	return nil
+}
+
+// validateAllowedResources checks that each resource is an absolute URI
+// without a fragment, as RFC 8707 requires.
+func validateAllowedResources(resources []string) error {
+	for _, resource := range resources {
+		if u, err := url.Parse(resource); err != nil || !u.IsAbs() || u.Fragment != "" {
+			return validationError(fmt.Sprintf("invalid allowed_resource %s", resource))
+		}
+	}
 	return nil
 }
 
@@ -319,7 +402,7 @@ func maybeHashSecret(clientType, secret string) ([]byte, error) {
 	if normalizedClientType(clientType) != "confidential" || strings.TrimSpace(secret) == "" {
 		return nil, nil
 	}
-	return bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	return oauthclient.HashSecret(secret)
 }
 
 func cloneSlice(values []string) []string {
@@ -338,6 +421,5 @@ func nullableString(value string) *string {
 
 // IsValidationError reports whether the error represents invalid user input.
 func IsValidationError(err error) bool {
-	var vErr *validationErr
-	return errors.As(err, &vErr)
+	return apierr.IsCode(err, apierr.CodeInvalid)
 }
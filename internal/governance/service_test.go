@@ -2,15 +2,21 @@ package governance
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/dhawalhost/wardseal/internal/oauthclient"
+	"github.com/dhawalhost/wardseal/internal/outbox"
+	"github.com/dhawalhost/wardseal/internal/policy"
 )
 
 var ctx = context.Background()
 
 func TestCreateOAuthClientValidatesRedirects(t *testing.T) {
-	svc := NewService(&fakeStore{}, nil, &fakeDirClient{}, nil)
+	svc := NewService(&fakeStore{}, nil, nil)
 	_, err := svc.CreateOAuthClient(ctx, "11111111-1111-1111-1111-111111111111", CreateOAuthClientInput{
 		ClientID:      "client-a",
 		Name:          "Client A",
@@ -24,7 +30,7 @@ func TestCreateOAuthClientValidatesRedirects(t *testing.T) {
 
 func TestCreateOAuthClientHashesSecret(t *testing.T) {
 	store := &fakeStore{}
-	svc := NewService(store, nil, &fakeDirClient{}, nil)
+	svc := NewService(store, nil, nil)
 	secret := "super-secret"
 	client, err := svc.CreateOAuthClient(ctx, "11111111-1111-1111-1111-111111111111", CreateOAuthClientInput{
 		ClientID:      "client-b",
@@ -47,7 +53,7 @@ func TestCreateOAuthClientHashesSecret(t *testing.T) {
 
 func TestUpdateOAuthClientValidatesRedirects(t *testing.T) {
 	store := &fakeStore{}
-	svc := NewService(store, nil, &fakeDirClient{}, nil)
+	svc := NewService(store, nil, nil)
 	_, err := svc.UpdateOAuthClient(ctx, "11111111-1111-1111-1111-111111111111", "client-x", UpdateOAuthClientInput{
 		RedirectURIs: []string{"http://localhost:bad"},
 	})
@@ -87,6 +93,25 @@ func (f *fakeStore) ListClientsByTenant(ctx context.Context, tenantID string) ([
 	return out, nil
 }
 
+func (f *fakeStore) ListClientsPage(ctx context.Context, tenantID string, filter oauthclient.ListFilter) ([]oauthclient.Client, int, error) {
+	all, _ := f.ListClientsByTenant(ctx, tenantID)
+	var matched []oauthclient.Client
+	query := strings.ToLower(filter.Query)
+	for _, c := range all {
+		if filter.ClientType != "" && c.ClientType != filter.ClientType {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToLower(c.ClientID), query) && !strings.Contains(strings.ToLower(c.Name), query) {
+			continue
+		}
+		matched = append(matched, c)
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ClientID < matched[j].ClientID })
+	start := min(filter.Offset, len(matched))
+	end := min(start+filter.Limit, len(matched))
+	return matched[start:end], len(matched), nil
+}
+
 func (f *fakeStore) GetClient(ctx context.Context, tenantID, clientID string) (oauthclient.Client, error) {
 	f.ensureClients()
 	c, ok := f.clients[tenantID+clientID]
@@ -124,8 +149,88 @@ func (f *fakeStore) DeleteClient(ctx context.Context, tenantID, clientID string)
 	return nil
 }
 
-type fakeDirClient struct{}
+func (f *fakeStore) ValidateSecret(ctx context.Context, tenantID, clientID, secret string) error {
+	return nil
+}
+
+func (f *fakeStore) RotateSecret(ctx context.Context, tenantID, clientID string) (string, error) {
+	return "", nil
+}
+
+type fakeDirClient struct {
+	added []string
+}
 
 func (f *fakeDirClient) AddUserToGroup(ctx context.Context, tenantID, userID, groupID string) error {
+	f.added = append(f.added, tenantID+"/"+groupID+"/"+userID)
+	return nil
+}
+
+// fakeRequestStore records approvals instead of writing them, standing in for
+// the transaction that updates the request and enqueues its event.
+type fakeRequestStore struct {
+	Store
+	requests map[string]AccessRequest
+	approved []AccessRequest
+}
+
+func (f *fakeRequestStore) GetRequest(ctx context.Context, tenantID, id string) (AccessRequest, error) {
+	req, ok := f.requests[id]
+	if !ok || req.TenantID != tenantID {
+		return AccessRequest{}, errors.New("not found")
+	}
+	return req, nil
+}
+
+func (f *fakeRequestStore) ApproveRequest(ctx context.Context, req AccessRequest) error {
+	f.approved = append(f.approved, req)
 	return nil
 }
+
+type allowAllEngine struct{}
+
+func (allowAllEngine) Evaluate(ctx context.Context, input policy.Input) (policy.Decision, error) {
+	return policy.Decision{Allowed: true}, nil
+}
+
+func TestApproveAccessRequestDefersProvisioningToOutbox(t *testing.T) {
+	const tenant = "11111111-1111-1111-1111-111111111111"
+	reqs := &fakeRequestStore{requests: map[string]AccessRequest{
+		"req-1": {ID: "req-1", TenantID: tenant, RequesterID: "user-1", ResourceType: "group", ResourceID: "group-1"},
+	}}
+	svc := NewService(&fakeStore{}, reqs, allowAllEngine{})
+
+	if err := svc.ApproveAccessRequest(ctx, tenant, "req-1", "approver-1", "ok"); err != nil {
+		t.Fatalf("ApproveAccessRequest: %v", err)
+	}
+	if len(reqs.approved) != 1 || reqs.approved[0].ID != "req-1" {
+		t.Fatalf("approved %+v, want req-1", reqs.approved)
+	}
+}
+
+func TestProvisionApprovedAccess(t *testing.T) {
+	dir := &fakeDirClient{}
+	handle := ProvisionApprovedAccess(dir)
+
+	event := func(resourceType string) outbox.Event {
+		payload, _ := json.Marshal(AccessRequestApproved{
+			RequestID: "req-1", RequesterID: "user-1", ResourceType: resourceType, ResourceID: "res-1",
+		})
+		return outbox.Event{ID: "evt-1", TenantID: "tenant-a", Type: EventAccessRequestApproved, Payload: payload}
+	}
+
+	if err := handle(ctx, event("group")); err != nil {
+		t.Fatalf("handle group: %v", err)
+	}
+	if err := handle(ctx, event("application")); err != nil {
+		t.Fatalf("handle application: %v", err)
+	}
+	if len(dir.added) != 1 || dir.added[0] != "tenant-a/res-1/user-1" {
+		t.Fatalf("directory calls = %v, want one group membership", dir.added)
+	}
+
+	bad := outbox.Event{Type: EventAccessRequestApproved, Payload: []byte("{")}
+	if err := handle(ctx, bad); err == nil {
+		t.Fatal("expected an error for an undecodable payload")
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/dhawalhost/wardseal/internal/outbox"
+	"github.com/dhawalhost/wardseal/pkg/database"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -16,23 +18,30 @@ type Store interface {
 	GetRequest(ctx context.Context, tenantID, id string) (AccessRequest, error)
 	ListRequests(ctx context.Context, tenantID, status string) ([]AccessRequest, error)
 	UpdateRequestStatus(ctx context.Context, id, status string) error
+	// ApproveRequest marks the request approved and enqueues an
+	// EventAccessRequestApproved outbox event in the same transaction.
+	ApproveRequest(ctx context.Context, req AccessRequest) error
 }
 
 type sqlStore struct {
-	db *sqlx.DB
+	stmts *database.StmtCache
 }
 
-// NewStore creates a new governance store.
-func NewStore(db *sqlx.DB) Store {
-	return &sqlStore{db: db}
+// NewStore creates a new governance store. Its queries are prepared once
+// through stmts, which the caller closes on shutdown.
+func NewStore(stmts *database.StmtCache) Store {
+	return &sqlStore{stmts: stmts}
 }
 
 func (s *sqlStore) CreateRequest(ctx context.Context, req AccessRequest) (string, error) {
 	var id string
-	err := s.db.QueryRowContext(ctx,
+	err := s.stmts.Query(ctx, "governance.CreateRequest",
 		`INSERT INTO access_requests (tenant_id, requester_id, resource_type, resource_id, reason, status)
 		 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
-		req.TenantID, req.RequesterID, req.ResourceType, req.ResourceID, req.Reason, "pending").Scan(&id)
+		func(ctx context.Context, stmt *sqlx.Stmt) error {
+			return stmt.QueryRowContext(ctx,
+				req.TenantID, req.RequesterID, req.ResourceType, req.ResourceID, req.Reason, "pending").Scan(&id)
+		})
 	if err != nil {
 		return "", fmt.Errorf("failed to create access request: %w", err)
 	}
@@ -47,11 +56,13 @@ func (s *sqlStore) GetRequest(ctx context.Context, tenantID, id string) (AccessR
 	// Actually for simplicity, let's change struct to use time.Time or custom scanner.
 	// But since I already defined struct with string in types.go, I will Scan into time.Time and convert.
 
-	row := s.db.QueryRowxContext(ctx, `SELECT id, tenant_id, requester_id, resource_type, resource_id, status, reason, created_at, updated_at
-		FROM access_requests WHERE id = $1 AND tenant_id = $2`, id, tenantID)
-
 	var createdAt, updatedAt time.Time
-	err := row.Scan(&req.ID, &req.TenantID, &req.RequesterID, &req.ResourceType, &req.ResourceID, &req.Status, &req.Reason, &createdAt, &updatedAt)
+	err := s.stmts.Query(ctx, "governance.GetRequest", `SELECT id, tenant_id, requester_id, resource_type, resource_id, status, reason, created_at, updated_at
+		FROM access_requests WHERE id = $1 AND tenant_id = $2`,
+		func(ctx context.Context, stmt *sqlx.Stmt) error {
+			return stmt.QueryRowxContext(ctx, id, tenantID).
+				Scan(&req.ID, &req.TenantID, &req.RequesterID, &req.ResourceType, &req.ResourceID, &req.Status, &req.Reason, &createdAt, &updatedAt)
+		})
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return AccessRequest{}, fmt.Errorf("request not found")
@@ -74,27 +85,55 @@ func (s *sqlStore) ListRequests(ctx context.Context, tenantID, status string) ([
 	}
 	query += ` ORDER BY created_at DESC`
 
-	rows, err := s.db.QueryContext(ctx, query, args...)
-	if err != nil {
-		return nil, err
-	}
-	defer func() { _ = rows.Close() }()
-
 	var requests []AccessRequest
-	for rows.Next() {
-		var req AccessRequest
-		var createdAt, updatedAt time.Time
-		if err := rows.Scan(&req.ID, &req.TenantID, &req.RequesterID, &req.ResourceType, &req.ResourceID, &req.Status, &req.Reason, &createdAt, &updatedAt); err != nil {
-			return nil, err
+	// Rows are read inside Query, before its timeout is released.
+	err := s.stmts.Query(ctx, "governance.ListRequests", query, func(ctx context.Context, stmt *sqlx.Stmt) error {
+		rows, err := stmt.QueryContext(ctx, args...)
+		if err != nil {
+			return err
 		}
-		req.CreatedAt = createdAt.Format(time.RFC3339)
-		req.UpdatedAt = updatedAt.Format(time.RFC3339)
-		requests = append(requests, req)
+		defer func() { _ = rows.Close() }()
+
+		for rows.Next() {
+			var req AccessRequest
+			var createdAt, updatedAt time.Time
+			if err := rows.Scan(&req.ID, &req.TenantID, &req.RequesterID, &req.ResourceType, &req.ResourceID, &req.Status, &req.Reason, &createdAt, &updatedAt); err != nil {
+				return err
+			}
+			req.CreatedAt = createdAt.Format(time.RFC3339)
+			req.UpdatedAt = updatedAt.Format(time.RFC3339)
+			requests = append(requests, req)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
 	}
 	return requests, nil
 }
 
 func (s *sqlStore) UpdateRequestStatus(ctx context.Context, id, status string) error {
-	_, err := s.db.ExecContext(ctx, `UPDATE access_requests SET status = $1, updated_at = NOW() WHERE id = $2`, status, id)
-	return err
+	return s.stmts.Query(ctx, "governance.UpdateRequestStatus", `UPDATE access_requests SET status = $1, updated_at = NOW() WHERE id = $2`,
+		func(ctx context.Context, stmt *sqlx.Stmt) error {
+			_, err := stmt.ExecContext(ctx, status, id)
+			return err
+		})
+}
+
+func (s *sqlStore) ApproveRequest(ctx context.Context, req AccessRequest) error {
+	return s.stmts.Run(ctx, "governance.ApproveRequest", func(ctx context.Context) error {
+		return outbox.WithTx(ctx, s.stmts.DB(), func(tx *sqlx.Tx) error {
+			if _, err := tx.ExecContext(ctx,
+				`UPDATE access_requests SET status = 'approved', updated_at = NOW() WHERE id = $1 AND tenant_id = $2`,
+				req.ID, req.TenantID); err != nil {
+				return err
+			}
+			return outbox.Enqueue(ctx, tx, req.TenantID, EventAccessRequestApproved, AccessRequestApproved{
+				RequestID:    req.ID,
+				RequesterID:  req.RequesterID,
+				ResourceType: req.ResourceType,
+				ResourceID:   req.ResourceID,
+			})
+		})
+	})
 }
@@ -4,23 +4,33 @@ import "github.com/dhawalhost/wardseal/internal/oauthclient"
 
 // OAuthClientResponse is the wire format for OAuth clients.
 type OAuthClientResponse struct {
-	ClientID      string   `json:"client_id"`
-	TenantID      string   `json:"tenant_id"`
-	ClientType    string   `json:"client_type"`
-	Name          string   `json:"name"`
-	Description   string   `json:"description,omitempty"`
-	RedirectURIs  []string `json:"redirect_uris"`
-	AllowedScopes []string `json:"allowed_scopes"`
+	ClientID               string   `json:"client_id"`
+	TenantID               string   `json:"tenant_id"`
+	ClientType             string   `json:"client_type"`
+	Name                   string   `json:"name"`
+	Description            string   `json:"description,omitempty"`
+	RedirectURIs           []string `json:"redirect_uris"`
+	AllowedScopes          []string `json:"allowed_scopes"`
+	BindTokens             bool     `json:"bind_tokens"`
+	FirstParty             bool     `json:"first_party"`
+	PostLogoutRedirectURIs []string `json:"post_logout_redirect_uris"`
+	AllowedResources       []string `json:"allowed_resources"`
+	Version                int      `json:"version"`
 }
 
 func newOAuthClientResponse(client oauthclient.Client) OAuthClientResponse {
 	resp := OAuthClientResponse{
-		ClientID:      client.ClientID,
-		TenantID:      client.TenantID,
-		ClientType:    client.ClientType,
-		Name:          client.Name,
-		RedirectURIs:  append([]string(nil), client.RedirectURIs...),
-		AllowedScopes: append([]string(nil), client.AllowedScopes...),
+		ClientID:               client.ClientID,
+		TenantID:               client.TenantID,
+		ClientType:             client.ClientType,
+		Name:                   client.Name,
+		RedirectURIs:           append([]string(nil), client.RedirectURIs...),
+		AllowedScopes:          append([]string(nil), client.AllowedScopes...),
+		BindTokens:             client.BindTokens,
+		FirstParty:             client.FirstParty,
+		PostLogoutRedirectURIs: append([]string(nil), client.PostLogoutRedirectURIs...),
+		AllowedResources:       append([]string(nil), client.AllowedResources...),
+		Version:                client.Version,
 	}
 	if client.Description.Valid {
 		resp.Description = client.Description.String
@@ -29,22 +39,31 @@ func newOAuthClientResponse(client oauthclient.Client) OAuthClientResponse {
 }
 
 type createOAuthClientRequest struct {
-	ClientID      string   `json:"client_id"`
-	Name          string   `json:"name"`
-	Description   string   `json:"description"`
-	ClientType    string   `json:"client_type"`
-	RedirectURIs  []string `json:"redirect_uris"`
-	AllowedScopes []string `json:"allowed_scopes"`
-	ClientSecret  string   `json:"client_secret"`
+	ClientID               string   `json:"client_id"`
+	Name                   string   `json:"name"`
+	Description            string   `json:"description"`
+	ClientType             string   `json:"client_type"`
+	RedirectURIs           []string `json:"redirect_uris"`
+	AllowedScopes          []string `json:"allowed_scopes"`
+	ClientSecret           string   `json:"client_secret"`
+	BindTokens             bool     `json:"bind_tokens"`
+	FirstParty             bool     `json:"first_party"`
+	PostLogoutRedirectURIs []string `json:"post_logout_redirect_uris"`
+	AllowedResources       []string `json:"allowed_resources"`
 }
 
 type updateOAuthClientRequest struct {
-	Name          *string  `json:"name"`
-	Description   *string  `json:"description"`
-	ClientType    *string  `json:"client_type"`
-	RedirectURIs  []string `json:"redirect_uris"`
-	AllowedScopes []string `json:"allowed_scopes"`
-	ClientSecret  *string  `json:"client_secret"`
+	Name                   *string  `json:"name"`
+	Description            *string  `json:"description"`
+	ClientType             *string  `json:"client_type"`
+	RedirectURIs           []string `json:"redirect_uris"`
+	AllowedScopes          []string `json:"allowed_scopes"`
+	ClientSecret           *string  `json:"client_secret"`
+	BindTokens             *bool    `json:"bind_tokens"`
+	FirstParty             *bool    `json:"first_party"`
+	PostLogoutRedirectURIs []string `json:"post_logout_redirect_uris"`
+	AllowedResources       []string `json:"allowed_resources"`
+	Version                *int     `json:"version"`
 }
 
 // Access Request types
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/dhawalhost/wardseal/internal/webhook"
+	"github.com/dhawalhost/wardseal/pkg/apierr"
 	"github.com/dhawalhost/wardseal/pkg/middleware"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -50,7 +51,7 @@ func (h *WebhookHTTPHandler) createWebhook(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierr.Abort(c, apierr.Validation(err))
 		return
 	}
 
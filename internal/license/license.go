@@ -6,6 +6,7 @@ import (
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -26,6 +27,16 @@ type License struct {
 	Plan         string
 }
 
+// HasFeature reports whether the license includes feature.
+func (l *License) HasFeature(feature string) bool {
+	for _, f := range l.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
 // Manager handles license verification.
 type Manager struct {
 	publicKey *rsa.PublicKey
@@ -80,3 +91,36 @@ func (m *Manager) Verify(tokenString string) (*License, error) {
 
 	return nil, errors.New("invalid license claims")
 }
+
+// DefaultPublicKeyPath is where FromEnv reads the license public key when
+// LICENSE_PUBLIC_KEY_PATH is unset.
+const DefaultPublicKeyPath = "/etc/wardseal/license_public.pem"
+
+// FromEnv verifies the license in LICENSE_KEY against the public key at
+// LICENSE_PUBLIC_KEY_PATH when REQUIRE_LICENSE is "true". It returns a nil
+// license when no license is required.
+func FromEnv() (*License, error) {
+	if os.Getenv("REQUIRE_LICENSE") != "true" {
+		return nil, nil
+	}
+
+	pubKeyPath := os.Getenv("LICENSE_PUBLIC_KEY_PATH")
+	if pubKeyPath == "" {
+		pubKeyPath = DefaultPublicKeyPath
+	}
+	pubKey, err := os.ReadFile(pubKeyPath) //nolint:gosec // G304: path is from trusted env var
+	if err != nil {
+		return nil, fmt.Errorf("failed to read license public key: %w", err)
+	}
+
+	mgr, err := NewManager(pubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	licenseKey := os.Getenv("LICENSE_KEY")
+	if licenseKey == "" {
+		return nil, errors.New("LICENSE_KEY environment variable is required for enterprise edition")
+	}
+	return mgr.Verify(licenseKey)
+}
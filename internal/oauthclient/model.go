@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/lib/pq"
@@ -19,42 +21,101 @@ type Client struct {
 	Description      sql.NullString `db:"description"`
 	RedirectURIs     pq.StringArray `db:"redirect_uris"`
 	AllowedScopes    pq.StringArray `db:"allowed_scopes"`
-	ClientSecretHash []byte         `db:"client_secret_hash"`
-	CreatedAt        time.Time      `db:"created_at"`
-	UpdatedAt        time.Time      `db:"updated_at"`
+	ClientSecretHash []byte         `db:"client_secret_hash" json:"-"`
+	BindTokens       bool           `db:"bind_tokens"`
+	FirstParty       bool           `db:"first_party"`
+	// PostLogoutRedirectURIs are the allowed post_logout_redirect_uri values.
+	PostLogoutRedirectURIs pq.StringArray `db:"post_logout_redirect_uris"`
+	// AllowedResources are the resource indicators (RFC 8707) the client may
+	// request tokens for.
+	AllowedResources pq.StringArray `db:"allowed_resources"`
+	// Version is incremented on every update.
+	Version   int       `db:"version"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
 }
 
 // ErrNotFound indicates the requested client does not exist.
 var ErrNotFound = errors.New("oauth client not found")
 
+// ErrConcurrentModification indicates the client changed since the version
+// an update was based on.
+var ErrConcurrentModification = errors.New("oauth client was modified concurrently")
+
+// ScopeNotAllowedError is returned when a client is registered with scopes
+// missing from its tenant's scope catalog.
+type ScopeNotAllowedError struct {
+	Scopes []string
+}
+
+func (e *ScopeNotAllowedError) Error() string {
+	return fmt.Sprintf("scopes not in tenant catalog: %s", strings.Join(e.Scopes, ", "))
+}
+
+// IsScopeNotAllowed reports whether err is a ScopeNotAllowedError.
+func IsScopeNotAllowed(err error) bool {
+	var target *ScopeNotAllowedError
+	return errors.As(err, &target)
+}
+
 // Store defines the repository contract for OAuth clients.
 type Store interface {
 	ListClients(ctx context.Context) ([]Client, error)
 	ListClientsByTenant(ctx context.Context, tenantID string) ([]Client, error)
+	// ListClientsPage returns one page of a tenant's clients ordered by
+	// client_id, with the number of clients matching the filter.
+	ListClientsPage(ctx context.Context, tenantID string, filter ListFilter) ([]Client, int, error)
 	GetClient(ctx context.Context, tenantID, clientID string) (Client, error)
 	CreateClient(ctx context.Context, params CreateClientParams) (Client, error)
 	UpdateClient(ctx context.Context, tenantID, clientID string, params UpdateClientParams) (Client, error)
 	DeleteClient(ctx context.Context, tenantID, clientID string) error
+	// ValidateSecret checks a confidential client's secret, returning
+	// ErrNotFound, ErrNoSecret or ErrInvalidSecret on failure.
+	ValidateSecret(ctx context.Context, tenantID, clientID, secret string) error
+	// RotateSecret replaces the client's secret with a generated one and
+	// returns it. The plaintext is not stored.
+	RotateSecret(ctx context.Context, tenantID, clientID string) (string, error)
+}
+
+// ListFilter narrows and pages a tenant's clients.
+type ListFilter struct {
+	// ClientType, when set, matches the client type exactly.
+	ClientType string
+	// Query, when set, matches a case-insensitive substring of client_id or name.
+	Query  string
+	Limit  int
+	Offset int
 }
 
 // CreateClientParams captures the fields required to create a client.
 type CreateClientParams struct {
-	TenantID         string
-	ClientID         string
-	ClientType       string
-	Name             string
-	Description      *string
-	RedirectURIs     []string
-	AllowedScopes    []string
-	ClientSecretHash []byte
+	TenantID               string
+	ClientID               string
+	ClientType             string
+	Name                   string
+	Description            *string
+	RedirectURIs           []string
+	AllowedScopes          []string
+	ClientSecretHash       []byte
+	BindTokens             bool
+	FirstParty             bool
+	PostLogoutRedirectURIs []string
+	AllowedResources       []string
 }
 
 // UpdateClientParams captures the fields that can be changed for an existing client.
 type UpdateClientParams struct {
-	Name             *string
-	Description      *string
-	RedirectURIs     []string
-	AllowedScopes    []string
-	ClientType       *string
-	ClientSecretHash *[]byte
+	Name                   *string
+	Description            *string
+	RedirectURIs           []string
+	AllowedScopes          []string
+	ClientType             *string
+	ClientSecretHash       *[]byte
+	BindTokens             *bool
+	FirstParty             *bool
+	PostLogoutRedirectURIs []string
+	AllowedResources       []string
+	// ExpectedVersion, when set, makes the update fail with
+	// ErrConcurrentModification unless the stored version matches.
+	ExpectedVersion *int
 }
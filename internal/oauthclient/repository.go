@@ -23,7 +23,7 @@ func NewRepository(db *sqlx.DB) *Repository {
 func (r *Repository) ListClients(ctx context.Context) ([]Client, error) {
 	var clients []Client
 	err := r.db.SelectContext(ctx, &clients, `SELECT id, tenant_id, client_id, client_type, name, description,
-        redirect_uris, allowed_scopes, client_secret_hash, created_at, updated_at FROM oauth_clients`)
+        redirect_uris, allowed_scopes, client_secret_hash, bind_tokens, first_party, post_logout_redirect_uris, allowed_resources, version, created_at, updated_at FROM oauth_clients`)
 	return clients, err
 }
 
@@ -31,16 +31,33 @@ func (r *Repository) ListClients(ctx context.Context) ([]Client, error) {
 func (r *Repository) ListClientsByTenant(ctx context.Context, tenantID string) ([]Client, error) {
 	var clients []Client
 	err := r.db.SelectContext(ctx, &clients, `SELECT id, tenant_id, client_id, client_type, name, description,
-        redirect_uris, allowed_scopes, client_secret_hash, created_at, updated_at
+        redirect_uris, allowed_scopes, client_secret_hash, bind_tokens, first_party, post_logout_redirect_uris, allowed_resources, version, created_at, updated_at
         FROM oauth_clients WHERE tenant_id = $1`, tenantID)
 	return clients, err
 }
 
+// ListClientsPage returns the tenant's clients matching filter, one page at a time.
+func (r *Repository) ListClientsPage(ctx context.Context, tenantID string, filter ListFilter) ([]Client, int, error) {
+	const where = `WHERE tenant_id = $1 AND ($2 = '' OR client_type = $2)
+        AND ($3 = '' OR client_id ILIKE '%' || $3 || '%' OR name ILIKE '%' || $3 || '%')`
+	var total int
+	if err := r.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM oauth_clients `+where,
+		tenantID, filter.ClientType, filter.Query); err != nil {
+		return nil, 0, err
+	}
+	var clients []Client
+	err := r.db.SelectContext(ctx, &clients, `SELECT id, tenant_id, client_id, client_type, name, description,
+        redirect_uris, allowed_scopes, client_secret_hash, bind_tokens, first_party, post_logout_redirect_uris, allowed_resources, version, created_at, updated_at
+        FROM oauth_clients `+where+` ORDER BY client_id LIMIT $4 OFFSET $5`,
+		tenantID, filter.ClientType, filter.Query, filter.Limit, filter.Offset)
+	return clients, total, err
+}
+
 // GetClient fetches a client by tenant and client_id.
 func (r *Repository) GetClient(ctx context.Context, tenantID, clientID string) (Client, error) {
 	var client Client
 	err := r.db.GetContext(ctx, &client, `SELECT id, tenant_id, client_id, client_type, name, description,
-        redirect_uris, allowed_scopes, client_secret_hash, created_at, updated_at
+        redirect_uris, allowed_scopes, client_secret_hash, bind_tokens, first_party, post_logout_redirect_uris, allowed_resources, version, created_at, updated_at
         FROM oauth_clients WHERE tenant_id = $1 AND client_id = $2`, tenantID, clientID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -53,36 +70,61 @@ func (r *Repository) GetClient(ctx context.Context, tenantID, clientID string) (
 
 // CreateClient inserts a new OAuth client.
 func (r *Repository) CreateClient(ctx context.Context, params CreateClientParams) (Client, error) {
+	if err := r.checkScopes(ctx, params.TenantID, params.AllowedScopes); err != nil {
+		return Client{}, err
+	}
 	var client Client
 	err := r.db.GetContext(ctx, &client, `INSERT INTO oauth_clients
-        (tenant_id, client_id, client_type, name, description, redirect_uris, allowed_scopes, client_secret_hash)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        (tenant_id, client_id, client_type, name, description, redirect_uris, allowed_scopes, client_secret_hash, bind_tokens, first_party, post_logout_redirect_uris, allowed_resources)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
         RETURNING id, tenant_id, client_id, client_type, name, description, redirect_uris,
-                  allowed_scopes, client_secret_hash, created_at, updated_at`,
+                  allowed_scopes, client_secret_hash, bind_tokens, first_party, post_logout_redirect_uris, allowed_resources, version, created_at, updated_at`,
 		params.TenantID, params.ClientID, params.ClientType, params.Name,
 		nullableString(params.Description), pq.StringArray(params.RedirectURIs),
-		pq.StringArray(params.AllowedScopes), params.ClientSecretHash)
+		pq.StringArray(params.AllowedScopes), params.ClientSecretHash, params.BindTokens, params.FirstParty,
+		pq.StringArray(params.PostLogoutRedirectURIs), pq.StringArray(params.AllowedResources))
 	return client, err
 }
 
 // UpdateClient updates mutable fields on an OAuth client.
-
 func (r *Repository) UpdateClient(ctx context.Context, tenantID, clientID string, params UpdateClientParams) (Client, error) {
-	_, err := r.db.ExecContext(ctx, `UPDATE oauth_clients
+	if params.AllowedScopes != nil {
+		if err := r.checkScopes(ctx, tenantID, params.AllowedScopes); err != nil {
+			return Client{}, err
+		}
+	}
+	result, err := r.db.ExecContext(ctx, `UPDATE oauth_clients
         SET name = COALESCE($1, name),
             description = COALESCE($2, description),
             redirect_uris = COALESCE($3::text[], redirect_uris),
             allowed_scopes = COALESCE($4::text[], allowed_scopes),
             client_type = COALESCE($5, client_type),
             client_secret_hash = COALESCE($6::bytea, client_secret_hash),
+            bind_tokens = COALESCE($7, bind_tokens),
+            first_party = COALESCE($8, first_party),
+            post_logout_redirect_uris = COALESCE($9::text[], post_logout_redirect_uris),
+            allowed_resources = COALESCE($10::text[], allowed_resources),
+            version = version + 1,
             updated_at = NOW()
-        WHERE tenant_id = $7 AND client_id = $8`,
+        WHERE tenant_id = $11 AND client_id = $12 AND ($13::integer IS NULL OR version = $13)`,
 		params.Name, nullableString(params.Description), nullableStringArray(params.RedirectURIs),
-		nullableStringArray(params.AllowedScopes), params.ClientType, nullableBytea(params.ClientSecretHash), tenantID, clientID)
+		nullableStringArray(params.AllowedScopes), params.ClientType, nullableBytea(params.ClientSecretHash), params.BindTokens, params.FirstParty,
+		nullableStringArray(params.PostLogoutRedirectURIs), nullableStringArray(params.AllowedResources), tenantID, clientID, params.ExpectedVersion)
+	if err != nil {
+		return Client{}, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return Client{}, err
+	}
+	client, err := r.GetClient(ctx, tenantID, clientID)
 	if err != nil {
 		return Client{}, err
 	}
-	return r.GetClient(ctx, tenantID, clientID)
+	if rows == 0 {
+		return Client{}, ErrConcurrentModification
+	}
+	return client, nil
 }
 
 // DeleteClient removes an OAuth client registration.
@@ -101,6 +143,78 @@ func (r *Repository) DeleteClient(ctx context.Context, tenantID, clientID string
 	return nil
 }
 
+// ValidateSecret checks secret against the client's stored hash.
+func (r *Repository) ValidateSecret(ctx context.Context, tenantID, clientID, secret string) error {
+	client, err := r.GetClient(ctx, tenantID, clientID)
+	if err != nil {
+		return err
+	}
+	return CheckSecret(client, secret)
+}
+
+// RotateSecret generates and stores a new secret for the client.
+func (r *Repository) RotateSecret(ctx context.Context, tenantID, clientID string) (string, error) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		return "", err
+	}
+	hash, err := HashSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	result, err := r.db.ExecContext(ctx, `UPDATE oauth_clients SET client_secret_hash = $1, version = version + 1, updated_at = NOW()
+        WHERE tenant_id = $2 AND client_id = $3`, hash, tenantID, clientID)
+	if err != nil {
+		return "", err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return "", err
+	}
+	if rows == 0 {
+		return "", ErrNotFound
+	}
+	return secret, nil
+}
+
+// ScopeCatalog returns the scopes clients of a tenant may be registered with:
+// the default catalog plus the tenant's own entries.
+func (r *Repository) ScopeCatalog(ctx context.Context, tenantID string) ([]string, error) {
+	var scopes []string
+	err := r.db.SelectContext(ctx, &scopes, `SELECT DISTINCT scope FROM oauth_scope_catalog
+        WHERE tenant_id = $1 OR tenant_id IS NULL ORDER BY scope`, tenantID)
+	return scopes, err
+}
+
+func (r *Repository) checkScopes(ctx context.Context, tenantID string, scopes []string) error {
+	catalog, err := r.ScopeCatalog(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	if missing := scopesNotIn(scopes, catalog); len(missing) > 0 {
+		return &ScopeNotAllowedError{Scopes: missing}
+	}
+	return nil
+}
+
+// scopesNotIn returns the scopes absent from catalog, in request order and
+// without duplicates.
+func scopesNotIn(scopes, catalog []string) []string {
+	known := make(map[string]struct{}, len(catalog))
+	for _, scope := range catalog {
+		known[scope] = struct{}{}
+	}
+	var missing []string
+	for _, scope := range scopes {
+		if _, ok := known[scope]; ok {
+			continue
+		}
+		known[scope] = struct{}{}
+		missing = append(missing, scope)
+	}
+	return missing
+}
+
 func nullableString(value *string) sql.NullString {
 	if value == nil {
 		return sql.NullString{Valid: false}
@@ -0,0 +1,38 @@
+package oauthclient
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestScopesNotIn(t *testing.T) {
+	catalog := []string{"openid", "profile", "email"}
+	tests := []struct {
+		name   string
+		scopes []string
+		want   []string
+	}{
+		{name: "all allowed", scopes: []string{"openid", "email"}},
+		{name: "empty", scopes: nil},
+		{name: "disallowed", scopes: []string{"openid", "admin", "payments"}, want: []string{"admin", "payments"}},
+		{name: "duplicates reported once", scopes: []string{"admin", "admin"}, want: []string{"admin"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scopesNotIn(tt.scopes, catalog); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("scopesNotIn(%v) = %v, want %v", tt.scopes, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsScopeNotAllowed(t *testing.T) {
+	err := fmt.Errorf("create client: %w", &ScopeNotAllowedError{Scopes: []string{"admin"}})
+	if !IsScopeNotAllowed(err) {
+		t.Fatalf("expected wrapped ScopeNotAllowedError to be detected")
+	}
+	if IsScopeNotAllowed(ErrNotFound) {
+		t.Fatalf("ErrNotFound is not a scope error")
+	}
+}
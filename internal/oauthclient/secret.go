@@ -0,0 +1,44 @@
+package oauthclient
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrNoSecret indicates the client has no secret configured.
+	ErrNoSecret = errors.New("oauth client has no secret configured")
+	// ErrInvalidSecret indicates the presented secret does not match.
+	ErrInvalidSecret = errors.New("invalid oauth client secret")
+)
+
+// HashSecret returns the bcrypt hash stored for a client secret.
+func HashSecret(secret string) ([]byte, error) {
+	return bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+}
+
+// GenerateSecret returns a random client secret.
+func GenerateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CheckSecret compares secret against the client's stored hash.
+func CheckSecret(client Client, secret string) error {
+	if len(client.ClientSecretHash) == 0 {
+		return ErrNoSecret
+	}
+	if err := bcrypt.CompareHashAndPassword(client.ClientSecretHash, []byte(secret)); err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrInvalidSecret
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package oauthclient
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCheckSecret(t *testing.T) {
+	hash, err := HashSecret("s3cret")
+	if err != nil {
+		t.Fatalf("HashSecret: %v", err)
+	}
+	client := Client{ClientID: "svc", ClientSecretHash: hash}
+
+	if err := CheckSecret(client, "s3cret"); err != nil {
+		t.Fatalf("expected matching secret to validate, got %v", err)
+	}
+	if err := CheckSecret(client, "wrong"); !errors.Is(err, ErrInvalidSecret) {
+		t.Fatalf("expected ErrInvalidSecret, got %v", err)
+	}
+	if err := CheckSecret(Client{ClientID: "public"}, "s3cret"); !errors.Is(err, ErrNoSecret) {
+		t.Fatalf("expected ErrNoSecret, got %v", err)
+	}
+}
+
+func TestRotatedSecretReplacesPrevious(t *testing.T) {
+	first, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret: %v", err)
+	}
+	second, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret: %v", err)
+	}
+	if first == second {
+		t.Fatalf("expected distinct generated secrets")
+	}
+
+	hash, err := HashSecret(second)
+	if err != nil {
+		t.Fatalf("HashSecret: %v", err)
+	}
+	client := Client{ClientSecretHash: hash}
+	if err := CheckSecret(client, second); err != nil {
+		t.Fatalf("expected rotated secret to validate, got %v", err)
+	}
+	if err := CheckSecret(client, first); !errors.Is(err, ErrInvalidSecret) {
+		t.Fatalf("expected previous secret to be rejected, got %v", err)
+	}
+}
+
+func TestClientJSONOmitsSecretHash(t *testing.T) {
+	raw, err := json.Marshal(Client{ClientID: "svc", ClientSecretHash: []byte("hash")})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if strings.Contains(string(raw), "ClientSecretHash") {
+		t.Fatalf("secret hash leaked into JSON: %s", raw)
+	}
+}
@@ -0,0 +1,115 @@
+// Package outbox implements a transactional outbox: events are inserted in
+// the same database transaction as the change they describe, so they exist if
+// and only if the change committed, and a Relay publishes them afterwards.
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Event is a committed change waiting to be, or already, published.
+type Event struct {
+	ID        string          `db:"id"`
+	TenantID  string          `db:"tenant_id"`
+	Type      string          `db:"event_type"`
+	Payload   json.RawMessage `db:"payload"`
+	Attempts  int             `db:"attempts"`
+	CreatedAt time.Time       `db:"created_at"`
+}
+
+// Decode unmarshals the event's payload into v.
+func (e Event) Decode(v any) error {
+	return json.Unmarshal(e.Payload, v)
+}
+
+// Execer is satisfied by *sqlx.Tx; events must be enqueued on the
+// transaction that makes the change.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Enqueue records an event on tx. It is published only if tx commits.
+func Enqueue(ctx context.Context, tx Execer, tenantID, eventType string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encode %s event: %w", eventType, err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO outbox (tenant_id, event_type, payload) VALUES ($1, $2, $3)`,
+		tenantID, eventType, data); err != nil {
+		return fmt.Errorf("enqueue %s event: %w", eventType, err)
+	}
+	return nil
+}
+
+// WithTx runs fn in a transaction and commits it when fn succeeds. Changes
+// fn makes and events it enqueues on tx are committed or discarded together.
+func WithTx(ctx context.Context, db *sqlx.DB, fn func(tx *sqlx.Tx) error) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Store hands committed events to a Relay and records the outcome.
+type Store interface {
+	// Claim leases up to limit events that are due, so that other relays
+	// skip them until lease has passed, and counts the attempt.
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]Event, error)
+	MarkPublished(ctx context.Context, id string) error
+	// MarkFailed records a failed attempt. The event is retried at retryAt,
+	// or never again when retryAt is zero.
+	MarkFailed(ctx context.Context, id string, cause error, retryAt time.Time) error
+}
+
+type sqlStore struct {
+	db *sqlx.DB
+}
+
+// NewStore returns a Store over the outbox table.
+func NewStore(db *sqlx.DB) Store {
+	return &sqlStore{db: db}
+}
+
+func (s *sqlStore) Claim(ctx context.Context, limit int, lease time.Duration) ([]Event, error) {
+	var events []Event
+	err := s.db.SelectContext(ctx, &events,
+		`UPDATE outbox SET attempts = attempts + 1, available_at = NOW() + $2 * INTERVAL '1 second'
+		 WHERE id IN (
+			SELECT id FROM outbox
+			WHERE published_at IS NULL AND failed_at IS NULL AND available_at <= NOW()
+			ORDER BY created_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED)
+		 RETURNING id, tenant_id, event_type, payload, attempts, created_at`,
+		limit, lease.Seconds())
+	return events, err
+}
+
+func (s *sqlStore) MarkPublished(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE outbox SET published_at = NOW(), last_error = NULL WHERE id = $1`, id)
+	return err
+}
+
+func (s *sqlStore) MarkFailed(ctx context.Context, id string, cause error, retryAt time.Time) error {
+	if retryAt.IsZero() {
+		_, err := s.db.ExecContext(ctx,
+			`UPDATE outbox SET failed_at = NOW(), last_error = $2 WHERE id = $1`, id, cause.Error())
+		return err
+	}
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE outbox SET available_at = $2, last_error = $3 WHERE id = $1`, id, retryAt, cause.Error())
+	return err
+}
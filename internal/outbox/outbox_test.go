@@ -0,0 +1,265 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// txDriver is a database/sql driver that holds the rows inserted into outbox
+// inside a transaction until it commits, and discards them on rollback.
+type txDriver struct {
+	mu        sync.Mutex
+	committed []Event
+}
+
+func (d *txDriver) Open(string) (driver.Conn, error) { return &txConn{d: d}, nil }
+
+// pending returns the committed events in the order they were enqueued.
+func (d *txDriver) pending() []Event {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Event(nil), d.committed...)
+}
+
+type txConn struct {
+	d      *txDriver
+	staged []Event
+	inTx   bool
+}
+
+func (c *txConn) Prepare(query string) (driver.Stmt, error) {
+	return &txStmt{c: c, query: query}, nil
+}
+func (c *txConn) Close() error { return nil }
+func (c *txConn) Begin() (driver.Tx, error) {
+	c.inTx = true
+	return c, nil
+}
+
+func (c *txConn) Commit() error {
+	c.d.mu.Lock()
+	c.d.committed = append(c.d.committed, c.staged...)
+	c.d.mu.Unlock()
+	c.staged, c.inTx = nil, false
+	return nil
+}
+
+func (c *txConn) Rollback() error {
+	c.staged, c.inTx = nil, false
+	return nil
+}
+
+type txStmt struct {
+	c     *txConn
+	query string
+}
+
+func (s *txStmt) Close() error  { return nil }
+func (s *txStmt) NumInput() int { return -1 }
+func (s *txStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if !strings.HasPrefix(s.query, "INSERT INTO outbox") {
+		return driver.RowsAffected(1), nil
+	}
+	if !s.c.inTx {
+		return nil, errors.New("outbox insert outside a transaction")
+	}
+	payload, _ := args[2].([]byte)
+	s.c.staged = append(s.c.staged, Event{
+		ID:       fmt.Sprintf("evt-%d", len(s.c.d.pending())+len(s.c.staged)+1),
+		TenantID: args[0].(string),
+		Type:     args[1].(string),
+		Payload:  append([]byte(nil), payload...),
+	})
+	return driver.RowsAffected(1), nil
+}
+func (s *txStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+var driverSeq atomic.Int64
+
+func newTxDB(t *testing.T) (*sqlx.DB, *txDriver) {
+	t.Helper()
+	d := &txDriver{}
+	name := fmt.Sprintf("outboxtx%d", driverSeq.Add(1))
+	sql.Register(name, d)
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	return sqlx.NewDb(db, "postgres"), d
+}
+
+// memStore is a Store over the events a txDriver committed.
+type memStore struct {
+	d         *txDriver
+	claimed   map[string]int
+	published []string
+	failed    map[string]time.Time
+}
+
+func newMemStore(d *txDriver) *memStore {
+	return &memStore{d: d, claimed: map[string]int{}, failed: map[string]time.Time{}}
+}
+
+func (s *memStore) Claim(ctx context.Context, limit int, lease time.Duration) ([]Event, error) {
+	var out []Event
+	for _, e := range s.d.pending() {
+		if len(out) == limit {
+			break
+		}
+		if _, done := s.failed[e.ID]; done || slices.Contains(s.published, e.ID) {
+			continue
+		}
+		s.claimed[e.ID]++
+		e.Attempts = s.claimed[e.ID]
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+func (s *memStore) MarkPublished(ctx context.Context, id string) error {
+	s.published = append(s.published, id)
+	return nil
+}
+
+func (s *memStore) MarkFailed(ctx context.Context, id string, cause error, retryAt time.Time) error {
+	if retryAt.IsZero() {
+		s.failed[id] = retryAt
+	}
+	return nil
+}
+
+type approved struct {
+	RequestID string `json:"request_id"`
+}
+
+func TestWithTxRollbackPublishesNothing(t *testing.T) {
+	db, d := newTxDB(t)
+	ctx := context.Background()
+	errDomain := errors.New("status update failed")
+
+	err := WithTx(ctx, db, func(tx *sqlx.Tx) error {
+		if err := Enqueue(ctx, tx, "tenant-a", "access_request.approved", approved{RequestID: "req-1"}); err != nil {
+			return err
+		}
+		return errDomain
+	})
+	if !errors.Is(err, errDomain) {
+		t.Fatalf("WithTx error = %v, want %v", err, errDomain)
+	}
+
+	var calls int
+	relay := NewRelay(RelayConfig{
+		Store: newMemStore(d),
+		Handlers: map[string]Handler{
+			"access_request.approved": func(context.Context, Event) error { calls++; return nil },
+		},
+	})
+	if n := relay.RunOnce(ctx); n != 0 || calls != 0 {
+		t.Fatalf("published %d events (%d handler calls) after rollback, want none", n, calls)
+	}
+}
+
+func TestWithTxCommitPublishesOnce(t *testing.T) {
+	db, d := newTxDB(t)
+	ctx := context.Background()
+
+	err := WithTx(ctx, db, func(tx *sqlx.Tx) error {
+		return Enqueue(ctx, tx, "tenant-a", "access_request.approved", approved{RequestID: "req-1"})
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+
+	var got []approved
+	store := newMemStore(d)
+	relay := NewRelay(RelayConfig{
+		Store: store,
+		Handlers: map[string]Handler{
+			"access_request.approved": func(_ context.Context, e Event) error {
+				var p approved
+				if err := e.Decode(&p); err != nil {
+					return err
+				}
+				if e.TenantID != "tenant-a" {
+					t.Errorf("tenant = %q, want tenant-a", e.TenantID)
+				}
+				got = append(got, p)
+				return nil
+			},
+		},
+	})
+	if n := relay.RunOnce(ctx); n != 1 {
+		t.Fatalf("RunOnce published %d events, want 1", n)
+	}
+	if n := relay.RunOnce(ctx); n != 0 {
+		t.Fatalf("second RunOnce published %d events, want 0", n)
+	}
+	if len(got) != 1 || got[0].RequestID != "req-1" {
+		t.Fatalf("handled %+v, want one event for req-1", got)
+	}
+}
+
+func TestRelayRetriesThenGivesUp(t *testing.T) {
+	db, d := newTxDB(t)
+	ctx := context.Background()
+	if err := WithTx(ctx, db, func(tx *sqlx.Tx) error {
+		if err := Enqueue(ctx, tx, "tenant-a", "flaky", approved{}); err != nil {
+			return err
+		}
+		return Enqueue(ctx, tx, "tenant-a", "unknown", approved{})
+	}); err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+
+	store := newMemStore(d)
+	relay := NewRelay(RelayConfig{
+		Store:       store,
+		MaxAttempts: 3,
+		Handlers: map[string]Handler{
+			"flaky": func(context.Context, Event) error { return errors.New("directory unavailable") },
+		},
+	})
+	for range 5 {
+		relay.RunOnce(ctx)
+	}
+	if store.claimed["evt-1"] != 3 {
+		t.Fatalf("flaky event attempted %d times, want 3", store.claimed["evt-1"])
+	}
+	if store.claimed["evt-2"] != 1 {
+		t.Fatalf("unknown event attempted %d times, want 1", store.claimed["evt-2"])
+	}
+	if len(store.failed) != 2 || len(store.published) != 0 {
+		t.Fatalf("failed=%v published=%v, want both failed", store.failed, store.published)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	cases := map[int]time.Duration{
+		0:  time.Second,
+		1:  time.Second,
+		2:  2 * time.Second,
+		5:  16 * time.Second,
+		13: time.Hour,
+		40: time.Hour,
+	}
+	for attempts, want := range cases {
+		if got := retryDelay(attempts); got != want {
+			t.Errorf("retryDelay(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultRelayInterval is how often a Relay polls for committed events.
+	DefaultRelayInterval = 2 * time.Second
+	// DefaultMaxAttempts is how often an event is tried before it is marked
+	// failed.
+	DefaultMaxAttempts = 10
+
+	defaultRelayBatchSize = 100
+	// relayLease keeps a claimed event from other relays while it is being
+	// published.
+	relayLease    = time.Minute
+	maxRetryDelay = time.Hour
+)
+
+// Handler publishes one event, e.g. by calling another service. It may run
+// more than once for the same event, so it must be idempotent.
+type Handler func(ctx context.Context, event Event) error
+
+// RelayConfig configures a Relay.
+type RelayConfig struct {
+	Store Store
+	// Handlers publish events by type. Events of other types are marked
+	// failed.
+	Handlers map[string]Handler
+	// Interval defaults to DefaultRelayInterval.
+	Interval time.Duration
+	// BatchSize bounds how many events are claimed per poll; it defaults to
+	// 100.
+	BatchSize int
+	// MaxAttempts defaults to DefaultMaxAttempts.
+	MaxAttempts int
+	Logger      *zap.Logger
+}
+
+// Relay publishes committed outbox events in the background, retrying
+// failures with exponential backoff.
+type Relay struct {
+	store       Store
+	handlers    map[string]Handler
+	interval    time.Duration
+	batchSize   int
+	maxAttempts int
+	logger      *zap.Logger
+	now         func() time.Time
+}
+
+// NewRelay creates a new outbox relay.
+func NewRelay(cfg RelayConfig) *Relay {
+	r := &Relay{
+		store:       cfg.Store,
+		handlers:    cfg.Handlers,
+		interval:    cfg.Interval,
+		batchSize:   cfg.BatchSize,
+		maxAttempts: cfg.MaxAttempts,
+		logger:      cfg.Logger,
+		now:         time.Now,
+	}
+	if r.interval <= 0 {
+		r.interval = DefaultRelayInterval
+	}
+	if r.batchSize <= 0 {
+		r.batchSize = defaultRelayBatchSize
+	}
+	if r.maxAttempts <= 0 {
+		r.maxAttempts = DefaultMaxAttempts
+	}
+	if r.logger == nil {
+		r.logger = zap.NewNop()
+	}
+	return r
+}
+
+// Start publishes due events every interval until ctx is done.
+func (r *Relay) Start(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		r.RunOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce publishes the events that are due and returns how many were
+// published.
+func (r *Relay) RunOnce(ctx context.Context) int {
+	events, err := r.store.Claim(ctx, r.batchSize, relayLease)
+	if err != nil {
+		r.logger.Error("Failed to claim outbox events", zap.Error(err))
+		return 0
+	}
+	published := 0
+	for _, event := range events {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := r.publish(ctx, event); err != nil {
+			r.fail(ctx, event, err)
+			continue
+		}
+		if err := r.store.MarkPublished(ctx, event.ID); err != nil {
+			// The event is published again once its lease runs out.
+			r.logger.Error("Failed to mark outbox event published", zap.String("event_id", event.ID), zap.Error(err))
+			continue
+		}
+		published++
+	}
+	return published
+}
+
+func (r *Relay) publish(ctx context.Context, event Event) error {
+	handler, ok := r.handlers[event.Type]
+	if !ok {
+		return fmt.Errorf("no handler for event type %q", event.Type)
+	}
+	return handler(ctx, event)
+}
+
+func (r *Relay) fail(ctx context.Context, event Event, cause error) {
+	var retryAt time.Time
+	_, known := r.handlers[event.Type]
+	if known && event.Attempts < r.maxAttempts {
+		retryAt = r.now().Add(retryDelay(event.Attempts))
+	}
+	r.logger.Warn("Outbox event not published",
+		zap.String("event_id", event.ID),
+		zap.String("event_type", event.Type),
+		zap.Int("attempts", event.Attempts),
+		zap.Bool("retrying", !retryAt.IsZero()),
+		zap.Error(cause))
+	if err := r.store.MarkFailed(ctx, event.ID, cause, retryAt); err != nil {
+		r.logger.Error("Failed to record outbox failure", zap.String("event_id", event.ID), zap.Error(err))
+	}
+}
+
+// retryDelay doubles from one second per attempt, capped at an hour.
+func retryDelay(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	if attempts > 12 {
+		return maxRetryDelay
+	}
+	return min(time.Second<<(attempts-1), maxRetryDelay)
+}
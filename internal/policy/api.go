@@ -1,8 +1,10 @@
 package policy
 
 import (
+	"errors"
 	"net/http"
 
+	"github.com/dhawalhost/wardseal/pkg/middleware"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -21,6 +23,22 @@ func NewHTTPHandler(svc Service, logger *zap.Logger) *HTTPHandler {
 // RegisterRoutes registers the policy routes.
 func (h *HTTPHandler) RegisterRoutes(router *gin.Engine) {
 	router.GET("/health", h.healthCheck)
+
+	tenant := middleware.TenantExtractor(middleware.TenantConfig{})
+	router.POST("/policy/evaluate", tenant, h.evaluate)
+	router.POST("/policy/simulate", tenant, h.simulate)
+
+	policies := router.Group("/api/v1/policies")
+	policies.Use(tenant)
+	{
+		policies.POST("", h.createRule)
+		policies.GET("", h.listRules)
+		policies.GET("/:id", h.getRule)
+		policies.PUT("/:id", h.updateRule)
+		policies.DELETE("/:id", h.deleteRule)
+		policies.GET("/:id/versions", h.listRuleVersions)
+		policies.POST("/:id/rollback", h.rollbackRule)
+	}
 }
 
 func (h *HTTPHandler) healthCheck(c *gin.Context) {
@@ -32,3 +50,200 @@ func (h *HTTPHandler) healthCheck(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, HealthCheckResponse{Healthy: ok})
 }
+
+func (h *HTTPHandler) tenantID(c *gin.Context) (string, bool) {
+	tenantID, err := middleware.TenantIDFromGinContext(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tenant id required"})
+		return "", false
+	}
+	return tenantID, true
+}
+
+// evaluate decides the posted input against the tenant's rules. The tenant
+// comes from the request, never from the body.
+func (h *HTTPHandler) evaluate(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+	var input Input
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	input.TenantID = tenantID
+
+	decision, err := h.svc.Evaluate(c.Request.Context(), input)
+	if err != nil {
+		h.logger.Error("Failed to evaluate policy", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "policy evaluation failed"})
+		return
+	}
+	c.JSON(http.StatusOK, decision)
+}
+
+// simulate previews the decisions of an unsaved policy set.
+func (h *HTTPHandler) simulate(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+	var req SimulateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	proposed := make([]Rule, len(req.Policies))
+	for i, p := range req.Policies {
+		proposed[i] = p.rule()
+		proposed[i].ID = p.ID
+	}
+
+	results, err := h.svc.Simulate(c.Request.Context(), tenantID, proposed, req.Inputs)
+	if err != nil {
+		h.handleError(c, "Failed to simulate policies", err)
+		return
+	}
+	resp := SimulateResponse{Results: results}
+	for _, r := range results {
+		if r.Changed {
+			resp.Changed++
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *HTTPHandler) createRule(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+	var req RuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule := req.rule()
+	rule.UpdatedBy = author(c)
+
+	rule, err := h.svc.CreateRule(c.Request.Context(), tenantID, rule)
+	if err != nil {
+		h.handleError(c, "Failed to create policy", err)
+		return
+	}
+	c.JSON(http.StatusCreated, rule)
+}
+
+func (h *HTTPHandler) listRules(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+	rules, err := h.svc.ListRules(c.Request.Context(), tenantID)
+	if err != nil {
+		h.handleError(c, "Failed to list policies", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"policies": rules})
+}
+
+func (h *HTTPHandler) getRule(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+	rule, err := h.svc.GetRule(c.Request.Context(), tenantID, c.Param("id"))
+	if err != nil {
+		h.handleError(c, "Failed to get policy", err)
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+func (h *HTTPHandler) updateRule(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+	var req RuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rule := req.rule()
+	rule.ID = c.Param("id")
+	rule.UpdatedBy = author(c)
+
+	rule, err := h.svc.UpdateRule(c.Request.Context(), tenantID, rule)
+	if err != nil {
+		h.handleError(c, "Failed to update policy", err)
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+func (h *HTTPHandler) deleteRule(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+	if err := h.svc.DeleteRule(c.Request.Context(), tenantID, c.Param("id")); err != nil {
+		h.handleError(c, "Failed to delete policy", err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *HTTPHandler) listRuleVersions(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+	versions, err := h.svc.ListRuleVersions(c.Request.Context(), tenantID, c.Param("id"))
+	if err != nil {
+		h.handleError(c, "Failed to list policy versions", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"versions": versions})
+}
+
+// rollbackRule restores a prior revision as the newest one; history is kept.
+func (h *HTTPHandler) rollbackRule(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+	var req RollbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule, err := h.svc.RollbackRule(c.Request.Context(), tenantID, c.Param("id"), req.Version, author(c))
+	if err != nil {
+		h.handleError(c, "Failed to roll back policy", err)
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+// author identifies who made a change, from the X-User-ID header.
+func author(c *gin.Context) string {
+	return c.GetHeader("X-User-ID")
+}
+
+func (h *HTTPHandler) handleError(c *gin.Context, msg string, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidRule):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrRuleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "policy not found"})
+	case errors.Is(err, ErrVersionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "policy version not found"})
+	default:
+		h.logger.Error(msg, zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
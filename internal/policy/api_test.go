@@ -0,0 +1,343 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	testTenantID  = "11111111-1111-1111-1111-111111111111"
+	otherTenantID = "22222222-2222-2222-2222-222222222222"
+)
+
+func newTestRouter(t *testing.T, rules ...Rule) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	store, err := NewMemoryRuleStore(rules...)
+	if err != nil {
+		t.Fatalf("NewMemoryRuleStore: %v", err)
+	}
+	router := gin.New()
+	NewHTTPHandler(NewService(store), zap.NewNop()).RegisterRoutes(router)
+	return router
+}
+
+func performRequest(router *gin.Engine, method, path, tenantID string, body interface{}) *httptest.ResponseRecorder {
+	return performRequestAs(router, method, path, tenantID, "", body)
+}
+
+// performRequestAs sends the request on behalf of userID.
+func performRequestAs(router *gin.Engine, method, path, tenantID, userID string, body interface{}) *httptest.ResponseRecorder {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			panic(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+	if tenantID != "" {
+		req.Header.Set(middleware.DefaultTenantHeader, tenantID)
+	}
+	if userID != "" {
+		req.Header.Set("X-User-ID", userID)
+	}
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	return resp
+}
+
+func decodeJSON(t *testing.T, resp *httptest.ResponseRecorder, out interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(resp.Body.Bytes(), out); err != nil {
+		t.Fatalf("failed to decode response %q: %v", resp.Body.String(), err)
+	}
+}
+
+func TestEvaluateEndpoint(t *testing.T) {
+	router := newTestRouter(t,
+		Rule{ID: "tenant-allow", TenantID: testTenantID, Effect: EffectAllow, Actions: []string{"read"},
+			Obligations: []Obligation{{Type: "audit_log"}}},
+		Rule{ID: "shared-deny", Effect: EffectDeny, Actions: []string{"delete"}},
+	)
+
+	resp := performRequest(router, http.MethodPost, "/policy/evaluate", testTenantID, Input{
+		Subject:  Subject{ID: "user-1"},
+		Action:   "read",
+		Resource: Resource{Type: "report", ID: "q3"},
+	})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var decision Decision
+	decodeJSON(t, resp, &decision)
+	if !decision.Allowed || decision.Rule == nil || decision.Rule.ID != "tenant-allow" {
+		t.Fatalf("expected allow by tenant-allow, got %+v", decision)
+	}
+	if len(decision.Obligations) != 1 || decision.Obligations[0].Type != "audit_log" {
+		t.Errorf("obligations = %+v", decision.Obligations)
+	}
+
+	// The tenant comes from the header, so another tenant's rules never apply.
+	resp = performRequest(router, http.MethodPost, "/policy/evaluate", otherTenantID, Input{
+		TenantID: testTenantID,
+		Action:   "read",
+	})
+	decodeJSON(t, resp, &decision)
+	if decision.Allowed {
+		t.Fatalf("expected another tenant to be denied, got %+v", decision)
+	}
+}
+
+func TestEvaluateRequiresTenant(t *testing.T) {
+	router := newTestRouter(t)
+	resp := performRequest(router, http.MethodPost, "/policy/evaluate", "", Input{Action: "read"})
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without a tenant, got %d", resp.Code)
+	}
+}
+
+func TestPolicyCRUD(t *testing.T) {
+	router := newTestRouter(t, Rule{ID: "shared", Effect: EffectDeny, Actions: []string{"delete"}})
+
+	resp := performRequest(router, http.MethodPost, "/api/v1/policies", testTenantID, RuleRequest{
+		Description: "Admins may export",
+		Effect:      EffectAllow,
+		Actions:     []string{"export"},
+		Subject:     SubjectMatch{Roles: []string{"admin"}},
+		Conditions:  Conditions{IPRanges: []string{"10.0.0.0/8"}},
+	})
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var created Rule
+	decodeJSON(t, resp, &created)
+	if created.ID == "" || created.TenantID != testTenantID || created.Effect != EffectAllow {
+		t.Fatalf("unexpected created rule: %+v", created)
+	}
+	path := "/api/v1/policies/" + created.ID
+
+	resp = performRequest(router, http.MethodGet, path, testTenantID, nil)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("get: expected 200, got %d", resp.Code)
+	}
+	if resp := performRequest(router, http.MethodGet, path, otherTenantID, nil); resp.Code != http.StatusNotFound {
+		t.Fatalf("get from another tenant: expected 404, got %d", resp.Code)
+	}
+
+	// Listing shows the tenant's own rules, not the shared ones.
+	resp = performRequest(router, http.MethodGet, "/api/v1/policies", testTenantID, nil)
+	var list struct {
+		Policies []Rule `json:"policies"`
+	}
+	decodeJSON(t, resp, &list)
+	if len(list.Policies) != 1 || list.Policies[0].ID != created.ID {
+		t.Fatalf("list = %+v, want only the created rule", list.Policies)
+	}
+
+	resp = performRequest(router, http.MethodPut, path, testTenantID, RuleRequest{
+		Description: "Nobody may export",
+		Effect:      EffectDeny,
+		Actions:     []string{"export"},
+	})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("update: expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var updated Rule
+	decodeJSON(t, resp, &updated)
+	if updated.Effect != EffectDeny || updated.Description != "Nobody may export" || !updated.CreatedAt.Equal(created.CreatedAt) {
+		t.Fatalf("unexpected updated rule: %+v", updated)
+	}
+
+	resp = performRequest(router, http.MethodDelete, path, testTenantID, nil)
+	if resp.Code != http.StatusNoContent {
+		t.Fatalf("delete: expected 204, got %d", resp.Code)
+	}
+	if resp := performRequest(router, http.MethodGet, path, testTenantID, nil); resp.Code != http.StatusNotFound {
+		t.Fatalf("get after delete: expected 404, got %d", resp.Code)
+	}
+}
+
+func TestPolicyCRUDRejectsInvalidRules(t *testing.T) {
+	router := newTestRouter(t, Rule{ID: "shared", Effect: EffectAllow})
+
+	tests := []struct {
+		name string
+		body RuleRequest
+	}{
+		{"unknown effect", RuleRequest{Effect: "maybe"}},
+		{"bad ip range", RuleRequest{Effect: EffectAllow, Conditions: Conditions{IPRanges: []string{"not-a-cidr"}}}},
+		{"bad time window", RuleRequest{Effect: EffectAllow, Conditions: Conditions{TimeOfDay: &TimeWindow{Start: "25:00", End: "06:00"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := performRequest(router, http.MethodPost, "/api/v1/policies", testTenantID, tt.body)
+			if resp.Code != http.StatusBadRequest {
+				t.Fatalf("expected 400, got %d: %s", resp.Code, resp.Body.String())
+			}
+		})
+	}
+
+	// Shared rules cannot be changed through a tenant.
+	resp := performRequest(router, http.MethodPut, "/api/v1/policies/shared", testTenantID, RuleRequest{Effect: EffectDeny})
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("update shared rule: expected 404, got %d", resp.Code)
+	}
+	resp = performRequest(router, http.MethodDelete, "/api/v1/policies/00000000-0000-0000-0000-000000000000", testTenantID, nil)
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("delete unknown rule: expected 404, got %d", resp.Code)
+	}
+}
+
+func TestSimulateFlagsChangedDecisions(t *testing.T) {
+	router := newTestRouter(t,
+		Rule{ID: "allow-reads", TenantID: testTenantID, Effect: EffectAllow, Actions: []string{"read", "export"}},
+		Rule{ID: "shared-deny-delete", Effect: EffectDeny, Actions: []string{"delete"}},
+	)
+
+	resp := performRequest(router, http.MethodPost, "/policy/simulate", testTenantID, SimulateRequest{
+		Policies: []RuleRequest{
+			{ID: "allow-reads", Effect: EffectAllow, Actions: []string{"read", "export"}},
+			{ID: "block-export", Effect: EffectDeny, Actions: []string{"export"}, Description: "Exports are frozen"},
+		},
+		Inputs: []Input{
+			{Subject: Subject{ID: "user-1"}, Action: "read"},
+			{Subject: Subject{ID: "user-1"}, Action: "export"},
+			{Subject: Subject{ID: "user-1"}, Action: "delete"},
+		},
+	})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var out SimulateResponse
+	decodeJSON(t, resp, &out)
+	if len(out.Results) != 3 || out.Changed != 1 {
+		t.Fatalf("expected 3 results with 1 change, got %+v", out)
+	}
+	read, export, del := out.Results[0], out.Results[1], out.Results[2]
+	if read.Changed || !read.Active.Allowed || !read.Proposed.Allowed {
+		t.Errorf("read should stay allowed: %+v", read)
+	}
+	if !export.Changed || !export.Active.Allowed || export.Proposed.Allowed {
+		t.Errorf("export should change from allowed to denied: %+v", export)
+	}
+	if export.Proposed.Rule == nil || export.Proposed.Rule.ID != "block-export" {
+		t.Errorf("export should be denied by block-export, got %+v", export.Proposed.Rule)
+	}
+	if del.Changed || del.Proposed.Allowed {
+		t.Errorf("shared deny should apply to both sets: %+v", del)
+	}
+
+	// Simulation does not change the active rules.
+	resp = performRequest(router, http.MethodPost, "/policy/evaluate", testTenantID, Input{Action: "export"})
+	var decision Decision
+	decodeJSON(t, resp, &decision)
+	if !decision.Allowed {
+		t.Fatalf("expected export to remain allowed, got %+v", decision)
+	}
+}
+
+func TestSimulateRejectsInvalidProposal(t *testing.T) {
+	router := newTestRouter(t)
+	resp := performRequest(router, http.MethodPost, "/policy/simulate", testTenantID, SimulateRequest{
+		Policies: []RuleRequest{{Effect: "maybe"}},
+		Inputs:   []Input{{Action: "read"}},
+	})
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", resp.Code, resp.Body.String())
+	}
+}
+
+func TestPolicyVersionsAndRollback(t *testing.T) {
+	router := newTestRouter(t)
+
+	resp := performRequestAs(router, http.MethodPost, "/api/v1/policies", testTenantID, "alice", RuleRequest{
+		Description: "v1", Effect: EffectAllow, Actions: []string{"export"},
+	})
+	var rule Rule
+	decodeJSON(t, resp, &rule)
+	if rule.Version != 1 || rule.UpdatedBy != "alice" {
+		t.Fatalf("unexpected created rule: %+v", rule)
+	}
+	path := "/api/v1/policies/" + rule.ID
+
+	performRequestAs(router, http.MethodPut, path, testTenantID, "bob", RuleRequest{
+		Description: "v2", Effect: EffectAllow, Actions: []string{"export", "read"},
+	})
+	resp = performRequestAs(router, http.MethodPut, path, testTenantID, "carol", RuleRequest{
+		Description: "v3", Effect: EffectDeny, Actions: []string{"export"},
+	})
+	decodeJSON(t, resp, &rule)
+	if rule.Version != 3 {
+		t.Fatalf("expected version 3 after two updates, got %d", rule.Version)
+	}
+
+	resp = performRequest(router, http.MethodGet, path+"/versions", testTenantID, nil)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("versions: expected 200, got %d", resp.Code)
+	}
+	var history struct {
+		Versions []RuleVersion `json:"versions"`
+	}
+	decodeJSON(t, resp, &history)
+	if len(history.Versions) != 3 {
+		t.Fatalf("expected 3 versions, got %d", len(history.Versions))
+	}
+	for i, want := range []struct {
+		version int
+		author  string
+		desc    string
+	}{{3, "carol", "v3"}, {2, "bob", "v2"}, {1, "alice", "v1"}} {
+		got := history.Versions[i]
+		if got.Version != want.version || got.Author != want.author || got.Rule.Description != want.desc {
+			t.Errorf("versions[%d] = %d/%s/%s, want %d/%s/%s", i,
+				got.Version, got.Author, got.Rule.Description, want.version, want.author, want.desc)
+		}
+	}
+
+	// Evaluation uses the latest revision, which denies exports.
+	resp = performRequest(router, http.MethodPost, "/policy/evaluate", testTenantID, Input{Action: "export"})
+	var decision Decision
+	decodeJSON(t, resp, &decision)
+	if decision.Allowed {
+		t.Fatalf("expected v3 to deny, got %+v", decision)
+	}
+
+	resp = performRequestAs(router, http.MethodPost, path+"/rollback", testTenantID, "dave", RollbackRequest{Version: 1})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("rollback: expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	decodeJSON(t, resp, &rule)
+	if rule.Version != 4 || rule.Description != "v1" || rule.Effect != EffectAllow || rule.UpdatedBy != "dave" {
+		t.Fatalf("expected v1 restored as version 4 by dave, got %+v", rule)
+	}
+
+	resp = performRequest(router, http.MethodPost, "/policy/evaluate", testTenantID, Input{Action: "export"})
+	decodeJSON(t, resp, &decision)
+	if !decision.Allowed {
+		t.Fatalf("expected the restored revision to allow, got %+v", decision)
+	}
+
+	// Rolling back keeps every earlier revision.
+	resp = performRequest(router, http.MethodGet, path+"/versions", testTenantID, nil)
+	decodeJSON(t, resp, &history)
+	if len(history.Versions) != 4 || history.Versions[0].Version != 4 {
+		t.Fatalf("expected 4 versions after rollback, got %+v", history.Versions)
+	}
+
+	resp = performRequest(router, http.MethodPost, path+"/rollback", testTenantID, RollbackRequest{Version: 9})
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("rollback to unknown version: expected 404, got %d", resp.Code)
+	}
+	resp = performRequest(router, http.MethodGet, path+"/versions", otherTenantID, nil)
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("versions from another tenant: expected 404, got %d", resp.Code)
+	}
+}
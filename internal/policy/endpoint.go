@@ -4,3 +4,48 @@ package policy
 type HealthCheckResponse struct {
 	Healthy bool `json:"healthy"`
 }
+
+// RollbackRequest is the body of the policy rollback endpoint.
+type RollbackRequest struct {
+	Version int `json:"version" binding:"required,min=1"`
+}
+
+// SimulateRequest is the body of the policy simulation endpoint.
+type SimulateRequest struct {
+	// Policies replace the tenant's own policies for the simulation.
+	Policies []RuleRequest `json:"policies"`
+	Inputs   []Input       `json:"inputs" binding:"required"`
+}
+
+// SimulateResponse holds the per-input results and how many changed.
+type SimulateResponse struct {
+	Results []SimulationResult `json:"results"`
+	Changed int                `json:"changed"`
+}
+
+// RuleRequest is the body of the policy create and update endpoints.
+type RuleRequest struct {
+	// ID is only read by simulation, to name proposed rules.
+	ID            string       `json:"id,omitempty"`
+	Description   string       `json:"description"`
+	Effect        Effect       `json:"effect" binding:"required"`
+	Actions       []string     `json:"actions"`
+	ResourceTypes []string     `json:"resource_types"`
+	ResourceIDs   []string     `json:"resource_ids"`
+	Subject       SubjectMatch `json:"subject"`
+	Conditions    Conditions   `json:"conditions"`
+	Obligations   []Obligation `json:"obligations"`
+}
+
+func (r RuleRequest) rule() Rule {
+	return Rule{
+		Description:   r.Description,
+		Effect:        r.Effect,
+		Actions:       r.Actions,
+		ResourceTypes: r.ResourceTypes,
+		ResourceIDs:   r.ResourceIDs,
+		Subject:       r.Subject,
+		Conditions:    r.Conditions,
+		Obligations:   r.Obligations,
+	}
+}
@@ -2,26 +2,84 @@ package policy
 
 import (
 	"context"
+	"fmt"
+	"time"
 )
 
-// simpleEngine is a basic implementation of Engine that allows everything by default,
-// or implements simple hardcoded rules for now until full OPA integration is ready.
-type simpleEngine struct{}
+// ruleEngine evaluates the rules in a RuleStore with deny-overrides
+// semantics: any applicable deny rule denies, otherwise any applicable allow
+// rule allows, and an input no rule applies to is denied.
+type ruleEngine struct {
+	store RuleStore
+	now   func() time.Time
+}
+
+// NewEngine creates an Engine evaluating the rules in store.
+func NewEngine(store RuleStore) Engine {
+	return &ruleEngine{store: store, now: time.Now}
+}
 
+// NewSimpleEngine returns an engine that allows everything except approving
+// one's own access request (separation of duties).
 func NewSimpleEngine() Engine {
-	return &simpleEngine{}
+	store, err := NewMemoryRuleStore(
+		Rule{
+			ID:            "sod-self-approval",
+			Description:   "Separation of Duties - Cannot approve own request",
+			Effect:        EffectDeny,
+			Actions:       []string{"approve"},
+			ResourceTypes: []string{"access_request"},
+			Conditions:    Conditions{ContextEqualsSubject: "requester_id"},
+		},
+		Rule{ID: "allow-all", Description: "Allowed", Effect: EffectAllow},
+	)
+	if err != nil {
+		panic(err) // the rules above are static
+	}
+	return NewEngine(store)
 }
 
-func (e *simpleEngine) Evaluate(ctx context.Context, input Input) (bool, string, error) {
-	// Example rule: SOD - Reviewer cannot satisfy their own request
-	// This logic should move to Rego policies eventually.
+func (e *ruleEngine) Evaluate(ctx context.Context, input Input) (Decision, error) {
+	rules, err := e.store.ListRules(ctx, input.TenantID)
+	if err != nil {
+		return Decision{}, fmt.Errorf("list policy rules: %w", err)
+	}
+	now := input.Environment.Time
+	if now.IsZero() {
+		now = e.now()
+	}
 
-	// Check Context for specific SOD checks
-	if requesterID, ok := input.Context["requester_id"].(string); ok {
-		if input.Subject.ID == requesterID && input.Action == "approve" {
-			return false, "Policy Violation: Separation of Duties - Cannot approve own request", nil
+	var allow *Rule
+	var obligations []Obligation
+	for i := range rules {
+		rule := &rules[i]
+		if !rule.appliesTo(input) {
+			continue
+		}
+		ok, err := rule.Conditions.hold(input, now)
+		if err != nil {
+			return Decision{}, fmt.Errorf("evaluate rule %s: %w", rule.ID, err)
 		}
+		if !ok {
+			continue
+		}
+		if rule.Effect == EffectDeny {
+			return Decision{Reason: "Policy Violation: " + ruleReason(rule), Rule: rule}, nil
+		}
+		if allow == nil {
+			allow = rule
+		}
+		obligations = append(obligations, rule.Obligations...)
 	}
+	if allow == nil {
+		return Decision{Reason: "no policy rule allows the request"}, nil
+	}
+	return Decision{Allowed: true, Reason: ruleReason(allow), Rule: allow, Obligations: obligations}, nil
+}
 
-	return true, "Allowed", nil
+func ruleReason(rule *Rule) string {
+	if rule.Description != "" {
+		return rule.Description
+	}
+	return "rule " + rule.ID
 }
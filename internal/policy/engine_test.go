@@ -0,0 +1,158 @@
+package policy
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func newTestEngine(t *testing.T, rules ...Rule) Engine {
+	t.Helper()
+	store, err := NewMemoryRuleStore(rules...)
+	if err != nil {
+		t.Fatalf("NewMemoryRuleStore: %v", err)
+	}
+	return NewEngine(store)
+}
+
+func readInput() Input {
+	return Input{
+		TenantID: "tenant-1",
+		Subject: Subject{
+			ID:         "user-1",
+			Roles:      []string{"auditor"},
+			Attributes: map[string]string{"department": "finance"},
+		},
+		Action:      "read",
+		Resource:    Resource{Type: "report", ID: "q3"},
+		Environment: Environment{Time: time.Date(2026, 3, 2, 10, 30, 0, 0, time.UTC), IP: "10.1.2.3"},
+	}
+}
+
+func TestEvaluateAllow(t *testing.T) {
+	engine := newTestEngine(t, Rule{
+		ID:            "auditors-read-reports",
+		Effect:        EffectAllow,
+		Actions:       []string{"read"},
+		ResourceTypes: []string{"report"},
+		Subject:       SubjectMatch{Roles: []string{"auditor"}, Attributes: map[string]string{"department": "finance"}},
+		Conditions: Conditions{
+			TimeOfDay: &TimeWindow{Start: "08:00", End: "18:00"},
+			IPRanges:  []string{"10.0.0.0/8"},
+		},
+		Obligations: []Obligation{{Type: "audit_log"}},
+	})
+
+	decision, err := engine.Evaluate(context.Background(), readInput())
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if !decision.Allowed {
+		t.Fatalf("expected allow, got %+v", decision)
+	}
+	if decision.Rule == nil || decision.Rule.ID != "auditors-read-reports" {
+		t.Errorf("matched rule = %+v, want auditors-read-reports", decision.Rule)
+	}
+	if len(decision.Obligations) != 1 || decision.Obligations[0].Type != "audit_log" {
+		t.Errorf("obligations = %+v, want audit_log", decision.Obligations)
+	}
+}
+
+func TestEvaluateDenyOverridesAllow(t *testing.T) {
+	engine := newTestEngine(t,
+		Rule{ID: "allow-reads", Effect: EffectAllow, Actions: []string{"read"}},
+		Rule{ID: "block-q3", Effect: EffectDeny, ResourceIDs: []string{"q3"}, Description: "Q3 is embargoed"},
+		Rule{ID: "other-tenant", TenantID: "tenant-2", Effect: EffectDeny},
+	)
+
+	decision, err := engine.Evaluate(context.Background(), readInput())
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if decision.Allowed {
+		t.Fatal("expected the deny rule to override the allow rule")
+	}
+	if decision.Rule == nil || decision.Rule.ID != "block-q3" {
+		t.Errorf("matched rule = %+v, want block-q3", decision.Rule)
+	}
+	if decision.Reason != "Policy Violation: Q3 is embargoed" {
+		t.Errorf("reason = %q", decision.Reason)
+	}
+}
+
+func TestEvaluateFailingConditionDoesNotApply(t *testing.T) {
+	tests := []struct {
+		name       string
+		conditions Conditions
+	}{
+		{"outside time window", Conditions{TimeOfDay: &TimeWindow{Start: "22:00", End: "06:00"}}},
+		{"outside ip range", Conditions{IPRanges: []string{"192.168.0.0/16"}}},
+		{"context mismatch", Conditions{Context: map[string]string{"mfa": "true"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := newTestEngine(t, Rule{ID: "conditional", Effect: EffectAllow, Conditions: tt.conditions})
+			decision, err := engine.Evaluate(context.Background(), readInput())
+			if err != nil {
+				t.Fatalf("Evaluate: %v", err)
+			}
+			if decision.Allowed || decision.Rule != nil {
+				t.Fatalf("expected default deny with no matched rule, got %+v", decision)
+			}
+		})
+	}
+}
+
+func TestTimeWindowSpanningMidnight(t *testing.T) {
+	window := TimeWindow{Start: "22:00", End: "06:00"}
+	for hour, want := range map[int]bool{23: true, 3: true, 6: false, 12: false} {
+		got, err := window.contains(time.Date(2026, 1, 1, hour, 0, 0, 0, time.UTC))
+		if err != nil {
+			t.Fatalf("contains: %v", err)
+		}
+		if got != want {
+			t.Errorf("%02d:00 in window = %v, want %v", hour, got, want)
+		}
+	}
+}
+
+func TestSimpleEngineSeparationOfDuties(t *testing.T) {
+	engine := NewSimpleEngine()
+	input := Input{
+		Subject:  Subject{ID: "user-1"},
+		Action:   "approve",
+		Resource: Resource{Type: "access_request", ID: "req-1"},
+		Context:  map[string]interface{}{"requester_id": "user-1"},
+	}
+
+	decision, err := engine.Evaluate(context.Background(), input)
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if decision.Allowed {
+		t.Fatal("expected self-approval to be denied")
+	}
+
+	input.Subject.ID = "user-2"
+	decision, err = engine.Evaluate(context.Background(), input)
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if !decision.Allowed {
+		t.Fatalf("expected another approver to be allowed, got %+v", decision)
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	invalid := []Rule{
+		{Effect: EffectAllow},
+		{ID: "r", Effect: "maybe"},
+		{ID: "r", Effect: EffectAllow, Conditions: Conditions{IPRanges: []string{"10.0.0.1"}}},
+		{ID: "r", Effect: EffectAllow, Conditions: Conditions{TimeOfDay: &TimeWindow{Start: "9am", End: "17:00"}}},
+	}
+	for _, rule := range invalid {
+		if _, err := NewMemoryRuleStore(rule); err == nil {
+			t.Errorf("expected rule %+v to be rejected", rule)
+		}
+	}
+}
@@ -2,19 +2,23 @@ package policy
 
 import (
 	"context"
+	"time"
 )
 
 // Input represents the data provided for policy evaluation.
 type Input struct {
-	Subject  Subject                `json:"subject"`
-	Action   string                 `json:"action"`
-	Resource Resource               `json:"resource"`
-	Context  map[string]interface{} `json:"context,omitempty"`
+	TenantID    string                 `json:"tenant_id,omitempty"`
+	Subject     Subject                `json:"subject"`
+	Action      string                 `json:"action"`
+	Resource    Resource               `json:"resource"`
+	Environment Environment            `json:"environment,omitempty"`
+	Context     map[string]interface{} `json:"context,omitempty"`
 }
 
 type Subject struct {
-	ID    string   `json:"id"`
-	Roles []string `json:"roles"`
+	ID         string            `json:"id"`
+	Roles      []string          `json:"roles"`
+	Attributes map[string]string `json:"attributes,omitempty"`
 }
 
 type Resource struct {
@@ -23,9 +27,28 @@ type Resource struct {
 	// Additional resource attributes can be passed in Context
 }
 
+// Environment describes the circumstances of the request.
+type Environment struct {
+	// Time is when the request is made. The engine's clock is used when zero.
+	Time time.Time `json:"time,omitempty"`
+	// IP is the client address.
+	IP string `json:"ip,omitempty"`
+}
+
+// Decision is the outcome of an evaluation.
+type Decision struct {
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason"`
+	// Rule is the rule that decided the outcome; nil when no rule applied.
+	Rule *Rule `json:"rule,omitempty"`
+	// Obligations must be fulfilled by the caller when the request is
+	// allowed, e.g. logging or step-up authentication.
+	Obligations []Obligation `json:"obligations,omitempty"`
+}
+
 // Engine defines the interface for policy evaluation.
 type Engine interface {
-	// Evaluate determines if an action is allowed based on the input.
-	// It returns allowed (bool), decision reason (string), and any error.
-	Evaluate(ctx context.Context, input Input) (bool, string, error)
+	// Evaluate decides whether the input's subject may perform its action on
+	// its resource. An error means no decision could be made.
+	Evaluate(ctx context.Context, input Input) (Decision, error)
 }
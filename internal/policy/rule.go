@@ -0,0 +1,221 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"time"
+)
+
+// Effect is what a rule decides when it applies.
+type Effect string
+
+const (
+	EffectAllow Effect = "allow"
+	EffectDeny  Effect = "deny"
+)
+
+// Rule is an attribute-based policy rule. A rule applies to an input when
+// every non-empty target field matches and all of its conditions hold; empty
+// target fields match anything.
+type Rule struct {
+	ID          string `json:"id"`
+	TenantID    string `json:"tenant_id,omitempty"`
+	Description string `json:"description,omitempty"`
+	Effect      Effect `json:"effect"`
+
+	Actions       []string     `json:"actions,omitempty"`
+	ResourceTypes []string     `json:"resource_types,omitempty"`
+	ResourceIDs   []string     `json:"resource_ids,omitempty"`
+	Subject       SubjectMatch `json:"subject"`
+	Conditions    Conditions   `json:"conditions"`
+
+	// Obligations are returned with an allow decision made by this rule.
+	Obligations []Obligation `json:"obligations,omitempty"`
+
+	// Version counts the rule's revisions, starting at 1.
+	Version   int       `json:"version"`
+	UpdatedBy string    `json:"updated_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RuleVersion is one stored revision of a rule.
+type RuleVersion struct {
+	RuleID  string `json:"rule_id"`
+	Version int    `json:"version"`
+	// Rule is the rule as it was at this revision.
+	Rule      Rule      `json:"rule"`
+	Author    string    `json:"author,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SubjectMatch selects the subjects a rule applies to.
+type SubjectMatch struct {
+	IDs []string `json:"ids,omitempty"`
+	// Roles matches subjects holding any of the roles.
+	Roles []string `json:"roles,omitempty"`
+	// Attributes matches subjects whose attributes equal all of these.
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// Conditions restrict when a rule applies. All set conditions must hold.
+type Conditions struct {
+	// TimeOfDay limits the rule to a daily window.
+	TimeOfDay *TimeWindow `json:"time_of_day,omitempty"`
+	// IPRanges limits the rule to clients in any of these CIDR ranges.
+	IPRanges []string `json:"ip_ranges,omitempty"`
+	// ContextEqualsSubject names a context value that must equal the subject
+	// ID, e.g. "requester_id" to catch approvers of their own requests.
+	ContextEqualsSubject string `json:"context_equals_subject,omitempty"`
+	// Context requires context values to equal these.
+	Context map[string]string `json:"context,omitempty"`
+}
+
+// TimeWindow is a daily window from Start to End in "15:04" format. A window
+// whose end is before its start spans midnight.
+type TimeWindow struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// Location is an IANA time zone name; defaults to UTC.
+	Location string `json:"location,omitempty"`
+}
+
+// Obligation is an action the caller must take when it acts on a decision.
+type Obligation struct {
+	Type   string            `json:"type"`
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// ErrInvalidRule is wrapped by the errors Validate returns.
+var ErrInvalidRule = errors.New("invalid policy rule")
+
+// Validate reports malformed rules, so evaluation does not fail on them later.
+func (r Rule) Validate() error {
+	if r.Effect != EffectAllow && r.Effect != EffectDeny {
+		return fmt.Errorf("%w: effect must be %q or %q", ErrInvalidRule, EffectAllow, EffectDeny)
+	}
+	if w := r.Conditions.TimeOfDay; w != nil {
+		if _, _, _, err := w.parse(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRule, err)
+		}
+	}
+	for _, cidr := range r.Conditions.IPRanges {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("%w: invalid ip range %q", ErrInvalidRule, cidr)
+		}
+	}
+	return nil
+}
+
+// appliesTo reports whether the rule's target matches the input.
+func (r Rule) appliesTo(input Input) bool {
+	return matchAny(r.Actions, input.Action) &&
+		matchAny(r.ResourceTypes, input.Resource.Type) &&
+		matchAny(r.ResourceIDs, input.Resource.ID) &&
+		r.Subject.matches(input.Subject)
+}
+
+func (m SubjectMatch) matches(subject Subject) bool {
+	if !matchAny(m.IDs, subject.ID) {
+		return false
+	}
+	if len(m.Roles) > 0 && !slices.ContainsFunc(subject.Roles, func(role string) bool {
+		return slices.Contains(m.Roles, role)
+	}) {
+		return false
+	}
+	for key, want := range m.Attributes {
+		if got, ok := subject.Attributes[key]; !ok || got != want {
+			return false
+		}
+	}
+	return true
+}
+
+// hold reports whether all conditions hold for the input at now.
+func (c Conditions) hold(input Input, now time.Time) (bool, error) {
+	if c.TimeOfDay != nil {
+		ok, err := c.TimeOfDay.contains(now)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	if len(c.IPRanges) > 0 {
+		ok, err := inRanges(input.Environment.IP, c.IPRanges)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	if c.ContextEqualsSubject != "" {
+		value, ok := input.Context[c.ContextEqualsSubject].(string)
+		if !ok || value != input.Subject.ID {
+			return false, nil
+		}
+	}
+	for key, want := range c.Context {
+		value, ok := input.Context[key]
+		if !ok || fmt.Sprint(value) != want {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (w TimeWindow) parse() (start, end time.Duration, loc *time.Location, err error) {
+	loc = time.UTC
+	if w.Location != "" {
+		if loc, err = time.LoadLocation(w.Location); err != nil {
+			return 0, 0, nil, fmt.Errorf("invalid time zone %q", w.Location)
+		}
+	}
+	s, err := time.Parse("15:04", w.Start)
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("invalid window start %q", w.Start)
+	}
+	e, err := time.Parse("15:04", w.End)
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("invalid window end %q", w.End)
+	}
+	return sinceMidnight(s), sinceMidnight(e), loc, nil
+}
+
+func (w TimeWindow) contains(t time.Time) (bool, error) {
+	start, end, loc, err := w.parse()
+	if err != nil {
+		return false, err
+	}
+	now := sinceMidnight(t.In(loc))
+	if start <= end {
+		return now >= start && now < end, nil
+	}
+	return now >= start || now < end, nil
+}
+
+func sinceMidnight(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+}
+
+// inRanges reports whether ip is in any of the CIDR ranges. A missing or
+// unparsable client address is in none.
+func inRanges(ip string, ranges []string) (bool, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false, nil
+	}
+	for _, cidr := range ranges {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return false, fmt.Errorf("invalid ip range %q", cidr)
+		}
+		if network.Contains(addr) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func matchAny(allowed []string, value string) bool {
+	return len(allowed) == 0 || slices.Contains(allowed, value)
+}
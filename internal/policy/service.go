@@ -1,19 +1,122 @@
 package policy
 
-import "context"
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
 
 // Service defines the interface for the policy service.
 type Service interface {
 	HealthCheck(ctx context.Context) (bool, error)
+	// Evaluate decides the input against the rules of input.TenantID.
+	Evaluate(ctx context.Context, input Input) (Decision, error)
+
+	CreateRule(ctx context.Context, tenantID string, rule Rule) (Rule, error)
+	GetRule(ctx context.Context, tenantID, id string) (Rule, error)
+	ListRules(ctx context.Context, tenantID string) ([]Rule, error)
+	UpdateRule(ctx context.Context, tenantID string, rule Rule) (Rule, error)
+	DeleteRule(ctx context.Context, tenantID, id string) error
+
+	ListRuleVersions(ctx context.Context, tenantID, id string) ([]RuleVersion, error)
+	// RollbackRule restores a prior revision as a new revision by author.
+	RollbackRule(ctx context.Context, tenantID, id string, version int, author string) (Rule, error)
+
+	// Simulate compares the decisions of the active and proposed rules for
+	// sample inputs without changing any rule.
+	Simulate(ctx context.Context, tenantID string, proposed []Rule, inputs []Input) ([]SimulationResult, error)
 }
 
-type policyService struct{}
+type policyService struct {
+	store  Store
+	engine Engine
+}
 
-// NewService creates a new policy service.
-func NewService() Service {
-	return &policyService{}
+// NewService creates a new policy service evaluating the rules in store.
+func NewService(store Store) Service {
+	return &policyService{store: store, engine: NewEngine(store)}
 }
 
 func (s *policyService) HealthCheck(ctx context.Context) (bool, error) {
 	return true, nil
 }
+
+func (s *policyService) Evaluate(ctx context.Context, input Input) (Decision, error) {
+	return s.engine.Evaluate(ctx, input)
+}
+
+func (s *policyService) CreateRule(ctx context.Context, tenantID string, rule Rule) (Rule, error) {
+	if err := rule.Validate(); err != nil {
+		return Rule{}, err
+	}
+	rule.TenantID = tenantID
+	return s.store.CreateRule(ctx, rule)
+}
+
+func (s *policyService) GetRule(ctx context.Context, tenantID, id string) (Rule, error) {
+	if !validID(id) {
+		return Rule{}, ErrRuleNotFound
+	}
+	return s.store.GetRule(ctx, tenantID, id)
+}
+
+// ListRules returns only the tenant's own rules, not the shared ones.
+func (s *policyService) ListRules(ctx context.Context, tenantID string) ([]Rule, error) {
+	rules, err := s.store.ListRules(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	own := make([]Rule, 0, len(rules))
+	for _, rule := range rules {
+		if rule.TenantID == tenantID {
+			own = append(own, rule)
+		}
+	}
+	return own, nil
+}
+
+func (s *policyService) UpdateRule(ctx context.Context, tenantID string, rule Rule) (Rule, error) {
+	if !validID(rule.ID) {
+		return Rule{}, ErrRuleNotFound
+	}
+	if err := rule.Validate(); err != nil {
+		return Rule{}, err
+	}
+	rule.TenantID = tenantID
+	return s.store.UpdateRule(ctx, rule)
+}
+
+func (s *policyService) DeleteRule(ctx context.Context, tenantID, id string) error {
+	if !validID(id) {
+		return ErrRuleNotFound
+	}
+	return s.store.DeleteRule(ctx, tenantID, id)
+}
+
+func (s *policyService) ListRuleVersions(ctx context.Context, tenantID, id string) ([]RuleVersion, error) {
+	if !validID(id) {
+		return nil, ErrRuleNotFound
+	}
+	return s.store.ListRuleVersions(ctx, tenantID, id)
+}
+
+func (s *policyService) RollbackRule(ctx context.Context, tenantID, id string, version int, author string) (Rule, error) {
+	if !validID(id) {
+		return Rule{}, ErrRuleNotFound
+	}
+	prior, err := s.store.GetRuleVersion(ctx, tenantID, id, version)
+	if err != nil {
+		return Rule{}, err
+	}
+	rule := prior.Rule
+	rule.TenantID = tenantID
+	rule.UpdatedBy = author
+	return s.store.UpdateRule(ctx, rule)
+}
+
+// validID rejects IDs that cannot name a stored rule before they reach the
+// database's uuid column.
+func validID(id string) bool {
+	_, err := uuid.Parse(id)
+	return err == nil
+}
@@ -0,0 +1,79 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// MaxSimulationInputs caps the sample inputs of one simulation.
+const MaxSimulationInputs = 100
+
+// SimulationResult compares the decisions for one sample input.
+type SimulationResult struct {
+	Input    Input    `json:"input"`
+	Active   Decision `json:"active"`
+	Proposed Decision `json:"proposed"`
+	// Changed is set when the proposed rules would allow what the active
+	// rules deny, or the other way round.
+	Changed bool `json:"changed"`
+}
+
+// Simulate evaluates inputs against the tenant's active rules and against
+// proposed, which stands in for the tenant's own rules; shared rules apply to
+// both. Nothing is stored.
+func (s *policyService) Simulate(ctx context.Context, tenantID string, proposed []Rule, inputs []Input) ([]SimulationResult, error) {
+	if len(inputs) > MaxSimulationInputs {
+		return nil, fmt.Errorf("%w: at most %d inputs can be simulated", ErrInvalidRule, MaxSimulationInputs)
+	}
+	active, err := s.store.ListRules(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("list policy rules: %w", err)
+	}
+
+	candidate := &MemoryRuleStore{}
+	for _, rule := range active {
+		if rule.TenantID == "" {
+			candidate.rules = append(candidate.rules, rule)
+		}
+	}
+	for i, rule := range proposed {
+		if err := rule.Validate(); err != nil {
+			return nil, fmt.Errorf("proposed rule %d: %w", i, err)
+		}
+		if rule.ID == "" {
+			rule.ID = "proposed-" + strconv.Itoa(i+1)
+		}
+		rule.TenantID = tenantID
+		candidate.rules = append(candidate.rules, rule)
+	}
+	// Evaluate both sets from memory so the store is read once.
+	activeEngine := NewEngine(&MemoryRuleStore{rules: active})
+	proposedEngine := NewEngine(candidate)
+
+	now := time.Now()
+	results := make([]SimulationResult, len(inputs))
+	for i, input := range inputs {
+		input.TenantID = tenantID
+		// Both rule sets see the same moment.
+		if input.Environment.Time.IsZero() {
+			input.Environment.Time = now
+		}
+		before, err := activeEngine.Evaluate(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("input %d: %w", i, err)
+		}
+		after, err := proposedEngine.Evaluate(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("input %d: %w", i, err)
+		}
+		results[i] = SimulationResult{
+			Input:    input,
+			Active:   before,
+			Proposed: after,
+			Changed:  before.Allowed != after.Allowed,
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,442 @@
+package policy
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrRuleNotFound is returned when a tenant has no rule with the given ID.
+var ErrRuleNotFound = errors.New("policy rule not found")
+
+// ErrVersionNotFound is returned when a rule has no revision with the given
+// number.
+var ErrVersionNotFound = errors.New("policy rule version not found")
+
+// RuleStore provides the rules evaluated for a tenant.
+type RuleStore interface {
+	// ListRules returns the tenant's rules and the rules shared by all
+	// tenants, which have an empty TenantID.
+	ListRules(ctx context.Context, tenantID string) ([]Rule, error)
+}
+
+// Store manages a tenant's rules. Shared rules cannot be read or changed
+// through the single-rule methods. Creating or updating a rule records a
+// revision authored by rule.UpdatedBy.
+type Store interface {
+	RuleStore
+	CreateRule(ctx context.Context, rule Rule) (Rule, error)
+	GetRule(ctx context.Context, tenantID, id string) (Rule, error)
+	UpdateRule(ctx context.Context, rule Rule) (Rule, error)
+	DeleteRule(ctx context.Context, tenantID, id string) error
+
+	// ListRuleVersions returns a rule's revisions, newest first.
+	ListRuleVersions(ctx context.Context, tenantID, id string) ([]RuleVersion, error)
+	GetRuleVersion(ctx context.Context, tenantID, id string, version int) (RuleVersion, error)
+}
+
+// ruleDefinition is the part of a Rule stored as JSON.
+type ruleDefinition struct {
+	Actions       []string     `json:"actions,omitempty"`
+	ResourceTypes []string     `json:"resource_types,omitempty"`
+	ResourceIDs   []string     `json:"resource_ids,omitempty"`
+	Subject       SubjectMatch `json:"subject"`
+	Conditions    Conditions   `json:"conditions"`
+	Obligations   []Obligation `json:"obligations,omitempty"`
+}
+
+type ruleRow struct {
+	ID          string          `db:"id"`
+	TenantID    sql.NullString  `db:"tenant_id"`
+	Description string          `db:"description"`
+	Effect      string          `db:"effect"`
+	Definition  json.RawMessage `db:"definition"`
+	Version     int             `db:"version"`
+	UpdatedBy   string          `db:"updated_by"`
+	CreatedAt   time.Time       `db:"created_at"`
+	UpdatedAt   time.Time       `db:"updated_at"`
+}
+
+func (r ruleRow) rule() (Rule, error) {
+	var def ruleDefinition
+	if err := json.Unmarshal(r.Definition, &def); err != nil {
+		return Rule{}, fmt.Errorf("decode policy rule %s: %w", r.ID, err)
+	}
+	return Rule{
+		ID:            r.ID,
+		TenantID:      r.TenantID.String,
+		Description:   r.Description,
+		Effect:        Effect(r.Effect),
+		Actions:       def.Actions,
+		ResourceTypes: def.ResourceTypes,
+		ResourceIDs:   def.ResourceIDs,
+		Subject:       def.Subject,
+		Conditions:    def.Conditions,
+		Obligations:   def.Obligations,
+		Version:       r.Version,
+		UpdatedBy:     r.UpdatedBy,
+		CreatedAt:     r.CreatedAt,
+		UpdatedAt:     r.UpdatedAt,
+	}, nil
+}
+
+type ruleVersionRow struct {
+	RuleID      string          `db:"rule_id"`
+	TenantID    sql.NullString  `db:"tenant_id"`
+	Version     int             `db:"version"`
+	Description string          `db:"description"`
+	Effect      string          `db:"effect"`
+	Definition  json.RawMessage `db:"definition"`
+	Author      string          `db:"author"`
+	CreatedAt   time.Time       `db:"created_at"`
+}
+
+func (r ruleVersionRow) ruleVersion() (RuleVersion, error) {
+	rule, err := ruleRow{
+		ID:          r.RuleID,
+		TenantID:    r.TenantID,
+		Description: r.Description,
+		Effect:      r.Effect,
+		Definition:  r.Definition,
+		Version:     r.Version,
+		UpdatedBy:   r.Author,
+		UpdatedAt:   r.CreatedAt,
+	}.rule()
+	if err != nil {
+		return RuleVersion{}, err
+	}
+	return RuleVersion{RuleID: r.RuleID, Version: r.Version, Rule: rule, Author: r.Author, CreatedAt: r.CreatedAt}, nil
+}
+
+func definitionOf(rule Rule) ([]byte, error) {
+	return json.Marshal(ruleDefinition{
+		Actions:       rule.Actions,
+		ResourceTypes: rule.ResourceTypes,
+		ResourceIDs:   rule.ResourceIDs,
+		Subject:       rule.Subject,
+		Conditions:    rule.Conditions,
+		Obligations:   rule.Obligations,
+	})
+}
+
+const (
+	ruleColumns        = `id, tenant_id, description, effect, definition, version, updated_by, created_at, updated_at`
+	ruleVersionColumns = `rule_id, tenant_id, version, description, effect, definition, author, created_at`
+)
+
+type store struct {
+	db *sqlx.DB
+}
+
+// NewStore creates a Store backed by the policy_rules table.
+func NewStore(db *sqlx.DB) Store {
+	return &store{db: db}
+}
+
+func (s *store) ListRules(ctx context.Context, tenantID string) ([]Rule, error) {
+	var rows []ruleRow
+	if err := s.db.SelectContext(ctx, &rows,
+		`SELECT `+ruleColumns+` FROM policy_rules
+		WHERE tenant_id = $1 OR tenant_id IS NULL
+		ORDER BY created_at, id`, tenantID); err != nil {
+		return nil, err
+	}
+	rules := make([]Rule, 0, len(rows))
+	for _, row := range rows {
+		rule, err := row.rule()
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (s *store) CreateRule(ctx context.Context, rule Rule) (Rule, error) {
+	def, err := definitionOf(rule)
+	if err != nil {
+		return Rule{}, err
+	}
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return Rule{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var row ruleRow
+	err = tx.GetContext(ctx, &row,
+		`INSERT INTO policy_rules (tenant_id, description, effect, definition, updated_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+ruleColumns,
+		rule.TenantID, rule.Description, rule.Effect, def, rule.UpdatedBy)
+	if err != nil {
+		return Rule{}, err
+	}
+	if err := insertVersion(ctx, tx, row); err != nil {
+		return Rule{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Rule{}, err
+	}
+	return row.rule()
+}
+
+// insertVersion records row as a revision of its rule.
+func insertVersion(ctx context.Context, tx *sqlx.Tx, row ruleRow) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO policy_rule_versions (`+ruleVersionColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		row.ID, row.TenantID, row.Version, row.Description, row.Effect, row.Definition, row.UpdatedBy, row.UpdatedAt)
+	return err
+}
+
+func (s *store) GetRule(ctx context.Context, tenantID, id string) (Rule, error) {
+	var row ruleRow
+	err := s.db.GetContext(ctx, &row,
+		`SELECT `+ruleColumns+` FROM policy_rules WHERE id = $1 AND tenant_id = $2`, id, tenantID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Rule{}, ErrRuleNotFound
+	}
+	if err != nil {
+		return Rule{}, err
+	}
+	return row.rule()
+}
+
+func (s *store) UpdateRule(ctx context.Context, rule Rule) (Rule, error) {
+	def, err := definitionOf(rule)
+	if err != nil {
+		return Rule{}, err
+	}
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return Rule{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var row ruleRow
+	err = tx.GetContext(ctx, &row,
+		`UPDATE policy_rules SET description = $1, effect = $2, definition = $3, updated_by = $4,
+			version = version + 1, updated_at = NOW()
+		WHERE id = $5 AND tenant_id = $6
+		RETURNING `+ruleColumns,
+		rule.Description, rule.Effect, def, rule.UpdatedBy, rule.ID, rule.TenantID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Rule{}, ErrRuleNotFound
+	}
+	if err != nil {
+		return Rule{}, err
+	}
+	if err := insertVersion(ctx, tx, row); err != nil {
+		return Rule{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Rule{}, err
+	}
+	return row.rule()
+}
+
+func (s *store) DeleteRule(ctx context.Context, tenantID, id string) error {
+	result, err := s.db.ExecContext(ctx,
+		`DELETE FROM policy_rules WHERE id = $1 AND tenant_id = $2`, id, tenantID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrRuleNotFound
+	}
+	return nil
+}
+
+func (s *store) ListRuleVersions(ctx context.Context, tenantID, id string) ([]RuleVersion, error) {
+	var rows []ruleVersionRow
+	if err := s.db.SelectContext(ctx, &rows,
+		`SELECT `+ruleVersionColumns+` FROM policy_rule_versions
+		WHERE rule_id = $1 AND tenant_id = $2
+		ORDER BY version DESC`, id, tenantID); err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrRuleNotFound
+	}
+	versions := make([]RuleVersion, 0, len(rows))
+	for _, row := range rows {
+		v, err := row.ruleVersion()
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, nil
+}
+
+func (s *store) GetRuleVersion(ctx context.Context, tenantID, id string, version int) (RuleVersion, error) {
+	var row ruleVersionRow
+	err := s.db.GetContext(ctx, &row,
+		`SELECT `+ruleVersionColumns+` FROM policy_rule_versions
+		WHERE rule_id = $1 AND tenant_id = $2 AND version = $3`, id, tenantID, version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return RuleVersion{}, ErrVersionNotFound
+	}
+	if err != nil {
+		return RuleVersion{}, err
+	}
+	return row.ruleVersion()
+}
+
+// MemoryRuleStore is an in-process Store.
+type MemoryRuleStore struct {
+	mu       sync.RWMutex
+	rules    []Rule
+	versions map[string][]RuleVersion // by rule ID, oldest first
+}
+
+// NewMemoryRuleStore creates a store holding rules, which must be valid.
+func NewMemoryRuleStore(rules ...Rule) (*MemoryRuleStore, error) {
+	s := &MemoryRuleStore{}
+	for _, rule := range rules {
+		if err := s.Add(rule); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Add validates rule and stores it with the ID it has.
+func (s *MemoryRuleStore) Add(rule Rule) error {
+	if rule.ID == "" {
+		return fmt.Errorf("%w: id is required", ErrInvalidRule)
+	}
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = append(s.rules, rule)
+	return nil
+}
+
+// ListRules implements RuleStore.
+func (s *MemoryRuleStore) ListRules(ctx context.Context, tenantID string) ([]Rule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var rules []Rule
+	for _, rule := range s.rules {
+		if rule.TenantID == "" || rule.TenantID == tenantID {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+// CreateRule implements Store.
+func (s *MemoryRuleStore) CreateRule(ctx context.Context, rule Rule) (Rule, error) {
+	now := time.Now().UTC()
+	rule.ID = uuid.NewString()
+	rule.Version = 1
+	rule.CreatedAt, rule.UpdatedAt = now, now
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = append(s.rules, rule)
+	s.recordVersion(rule)
+	return rule, nil
+}
+
+// GetRule implements Store.
+func (s *MemoryRuleStore) GetRule(ctx context.Context, tenantID, id string) (Rule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if i := s.index(tenantID, id); i >= 0 {
+		return s.rules[i], nil
+	}
+	return Rule{}, ErrRuleNotFound
+}
+
+// UpdateRule implements Store.
+func (s *MemoryRuleStore) UpdateRule(ctx context.Context, rule Rule) (Rule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.index(rule.TenantID, rule.ID)
+	if i < 0 {
+		return Rule{}, ErrRuleNotFound
+	}
+	rule.CreatedAt = s.rules[i].CreatedAt
+	rule.Version = s.rules[i].Version + 1
+	rule.UpdatedAt = time.Now().UTC()
+	s.rules[i] = rule
+	s.recordVersion(rule)
+	return rule, nil
+}
+
+// DeleteRule implements Store.
+func (s *MemoryRuleStore) DeleteRule(ctx context.Context, tenantID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.index(tenantID, id)
+	if i < 0 {
+		return ErrRuleNotFound
+	}
+	s.rules = append(s.rules[:i], s.rules[i+1:]...)
+	delete(s.versions, id)
+	return nil
+}
+
+// ListRuleVersions implements Store.
+func (s *MemoryRuleStore) ListRuleVersions(ctx context.Context, tenantID, id string) ([]RuleVersion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.index(tenantID, id) < 0 {
+		return nil, ErrRuleNotFound
+	}
+	history := s.versions[id]
+	versions := make([]RuleVersion, len(history))
+	for i, v := range history {
+		versions[len(history)-1-i] = v
+	}
+	return versions, nil
+}
+
+// GetRuleVersion implements Store.
+func (s *MemoryRuleStore) GetRuleVersion(ctx context.Context, tenantID, id string, version int) (RuleVersion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.index(tenantID, id) < 0 {
+		return RuleVersion{}, ErrRuleNotFound
+	}
+	for _, v := range s.versions[id] {
+		if v.Version == version {
+			return v, nil
+		}
+	}
+	return RuleVersion{}, ErrVersionNotFound
+}
+
+func (s *MemoryRuleStore) recordVersion(rule Rule) {
+	if s.versions == nil {
+		s.versions = make(map[string][]RuleVersion)
+	}
+	s.versions[rule.ID] = append(s.versions[rule.ID], RuleVersion{
+		RuleID:    rule.ID,
+		Version:   rule.Version,
+		Rule:      rule,
+		Author:    rule.UpdatedBy,
+		CreatedAt: rule.UpdatedAt,
+	})
+}
+
+// index finds a tenant's own rule; shared rules are never matched.
+func (s *MemoryRuleStore) index(tenantID, id string) int {
+	for i, rule := range s.rules {
+		if tenantID != "" && rule.TenantID == tenantID && rule.ID == id {
+			return i
+		}
+	}
+	return -1
+}
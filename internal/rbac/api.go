@@ -1,8 +1,11 @@
 package rbac
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
 
+	"github.com/dhawalhost/wardseal/pkg/apierr"
 	"github.com/dhawalhost/wardseal/pkg/middleware"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -25,6 +28,7 @@ func (h *HTTPHandler) RegisterRoutes(rg *gin.RouterGroup) {
 	roles := rg.Group("/roles")
 	{
 		roles.POST("", h.createRole)
+		roles.POST("/defaults", h.seedDefaultRoles)
 		roles.GET("", h.listRoles)
 		roles.GET("/:id", h.getRole)
 		roles.PUT("/:id", h.updateRole)
@@ -32,6 +36,7 @@ func (h *HTTPHandler) RegisterRoutes(rg *gin.RouterGroup) {
 		roles.GET("/:id/permissions", h.getRolePermissions)
 		roles.POST("/:id/permissions/:permId", h.assignPermissionToRole)
 		roles.DELETE("/:id/permissions/:permId", h.removePermissionFromRole)
+		roles.POST("/:id/assign", h.assignRoleToUsers)
 	}
 
 	// Permissions
@@ -49,6 +54,14 @@ func (h *HTTPHandler) RegisterRoutes(rg *gin.RouterGroup) {
 		users.DELETE("/:userId/roles/:roleId", h.removeRoleFromUser)
 		users.GET("/:userId/permissions", h.getUserPermissions)
 	}
+
+	// Group roles, inherited by the group's members
+	groups := rg.Group("/groups")
+	{
+		groups.GET("/:groupId/roles", h.getGroupRoles)
+		groups.POST("/:groupId/roles/:roleId", h.assignRoleToGroup)
+		groups.DELETE("/:groupId/roles/:roleId", h.removeRoleFromGroup)
+	}
 }
 
 func (h *HTTPHandler) tenantID(c *gin.Context) (string, bool) {
@@ -84,6 +97,28 @@ func (h *HTTPHandler) createRole(c *gin.Context) {
 	c.JSON(http.StatusCreated, role)
 }
 
+// seedDefaultRoles creates the default roles in the caller's tenant and
+// returns the tenant's roles.
+func (h *HTTPHandler) seedDefaultRoles(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.SeedDefaultRoles(c.Request.Context(), tenantID); err != nil {
+		h.logger.Error("Failed to seed default roles", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	roles, err := h.svc.ListRoles(c.Request.Context(), tenantID)
+	if err != nil {
+		h.logger.Error("Failed to list roles", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
 func (h *HTTPHandler) listRoles(c *gin.Context) {
 	tenantID, ok := h.tenantID(c)
 	if !ok {
@@ -262,6 +297,34 @@ func (h *HTTPHandler) assignRoleToUser(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "assigned"})
 }
 
+func (h *HTTPHandler) assignRoleToUsers(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	// At most 1000 users per call, so one statement stays reasonably sized.
+	var body struct {
+		UserIDs []string `json:"user_ids" binding:"required,min=1,max=1000,dive,uuid"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		apierr.Abort(c, apierr.Validation(err))
+		return
+	}
+
+	result, err := h.svc.AssignRoleToUsers(c.Request.Context(), tenantID, c.Param("id"), body.UserIDs, nil)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "role not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to assign role to users", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
 func (h *HTTPHandler) removeRoleFromUser(c *gin.Context) {
 	userID := c.Param("userId")
 	roleID := c.Param("roleId")
@@ -289,3 +352,53 @@ func (h *HTTPHandler) getUserPermissions(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{"permissions": perms})
 }
+
+func (h *HTTPHandler) getGroupRoles(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	groupID := c.Param("groupId")
+	roles, err := h.svc.GetGroupRoles(c.Request.Context(), tenantID, groupID)
+	if err != nil {
+		h.logger.Error("Failed to get group roles", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+func (h *HTTPHandler) assignRoleToGroup(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	groupID := c.Param("groupId")
+	roleID := c.Param("roleId")
+
+	if err := h.svc.AssignRoleToGroup(c.Request.Context(), tenantID, groupID, roleID, nil); err != nil {
+		h.logger.Error("Failed to assign role to group", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "assigned"})
+}
+
+func (h *HTTPHandler) removeRoleFromGroup(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	groupID := c.Param("groupId")
+	roleID := c.Param("roleId")
+
+	if err := h.svc.RemoveRoleFromGroup(c.Request.Context(), tenantID, groupID, roleID); err != nil {
+		h.logger.Error("Failed to remove role from group", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,116 @@
+package rbac
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dhawalhost/wardseal/pkg/apierr"
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	testTenant = "11111111-1111-1111-1111-111111111111"
+	testRole   = "22222222-2222-2222-2222-222222222222"
+	userA      = "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa"
+	userB      = "bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb"
+	userC      = "cccccccc-cccc-cccc-cccc-cccccccccccc"
+)
+
+// memStore keeps user role assignments in memory.
+type memStore struct {
+	Store
+	assignments map[string]bool // user ID + role ID
+}
+
+func (m *memStore) GetRole(_ context.Context, tenantID, id string) (Role, error) {
+	if tenantID != testTenant || id != testRole {
+		return Role{}, sql.ErrNoRows
+	}
+	return Role{ID: id, TenantID: tenantID}, nil
+}
+
+func (m *memStore) AssignRoleToUsers(_ context.Context, _, roleID string, userIDs []string, _ *string) (int, error) {
+	added := 0
+	for _, userID := range userIDs {
+		if !m.assignments[userID+roleID] {
+			m.assignments[userID+roleID] = true
+			added++
+		}
+	}
+	return added, nil
+}
+
+func newTestRouter(store Store) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(apierr.Handler(zap.NewNop()))
+	api := router.Group("/api/v1")
+	api.Use(middleware.TenantExtractor(middleware.TenantConfig{}))
+	NewHTTPHandler(NewService(store, ServiceConfig{}), zap.NewNop()).RegisterRoutes(api)
+	return router
+}
+
+func postAssign(t *testing.T, router http.Handler, roleID string, userIDs ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	body, _ := json.Marshal(map[string][]string{"user_ids": userIDs})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/roles/"+roleID+"/assign", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(middleware.DefaultTenantHeader, testTenant)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAssignRoleToUsersIsIdempotent(t *testing.T) {
+	router := newTestRouter(&memStore{assignments: map[string]bool{}})
+
+	steps := []struct {
+		users []string
+		want  BulkAssignment
+	}{
+		{[]string{userA, userB}, BulkAssignment{Added: 2}},
+		// Repeating the call changes nothing.
+		{[]string{userA, userB}, BulkAssignment{Skipped: 2}},
+		{[]string{userB, userC}, BulkAssignment{Added: 1, Skipped: 1}},
+	}
+	for i, step := range steps {
+		w := postAssign(t, router, testRole, step.users...)
+		if w.Code != http.StatusOK {
+			t.Fatalf("step %d: status = %d, body %s", i, w.Code, w.Body)
+		}
+		var got BulkAssignment
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got != step.want {
+			t.Fatalf("step %d: got %+v, want %+v", i, got, step.want)
+		}
+	}
+}
+
+func TestAssignRoleToUsersRejectsBadInput(t *testing.T) {
+	router := newTestRouter(&memStore{assignments: map[string]bool{}})
+
+	if w := postAssign(t, router, testRole); w.Code != http.StatusBadRequest {
+		t.Fatalf("empty list: status = %d, want 400", w.Code)
+	}
+	w := postAssign(t, router, testRole, userA, "not-a-uuid")
+	var invalid struct {
+		Fields []apierr.FieldError `json:"fields"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &invalid)
+	want := apierr.FieldError{Field: "user_ids[1]", Rule: "uuid", Message: "user_ids[1] must be a valid UUID"}
+	if w.Code != http.StatusBadRequest || len(invalid.Fields) != 1 || invalid.Fields[0] != want {
+		t.Fatalf("invalid user ID: expected 400 with %+v, got %d %s", want, w.Code, w.Body)
+	}
+	if w := postAssign(t, router, "33333333-3333-3333-3333-333333333333", userA); w.Code != http.StatusNotFound {
+		t.Fatalf("unknown role: status = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,70 @@
+package rbac
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// RoleTemplate describes a role SeedDefaultRoles creates in every tenant.
+type RoleTemplate struct {
+	Name        string               `json:"name"`
+	Description string               `json:"description"`
+	Permissions []PermissionTemplate `json:"permissions"`
+}
+
+// PermissionTemplate is a permission granted by a RoleTemplate.
+type PermissionTemplate struct {
+	Resource string `json:"resource"`
+	Action   string `json:"action"`
+}
+
+// DefaultRoles returns the standard role set: admin may do anything, member
+// may read everything and raise access requests, and viewer may only read.
+// Resource "*" and action "admin" are wildcards for HasPermission.
+func DefaultRoles() []RoleTemplate {
+	return []RoleTemplate{
+		{
+			Name:        "admin",
+			Description: "Full access to every resource",
+			Permissions: []PermissionTemplate{{Resource: "*", Action: "admin"}},
+		},
+		{
+			Name:        "member",
+			Description: "Read access and self-service access requests",
+			Permissions: []PermissionTemplate{
+				{Resource: "*", Action: "read"},
+				{Resource: "access_requests", Action: "create"},
+			},
+		},
+		{
+			Name:        "viewer",
+			Description: "Read-only access",
+			Permissions: []PermissionTemplate{{Resource: "*", Action: "read"}},
+		},
+	}
+}
+
+// LoadRoleTemplates reads a JSON array of RoleTemplates from path, for
+// operators replacing the default role set.
+func LoadRoleTemplates(path string) ([]RoleTemplate, error) {
+	raw, err := os.ReadFile(path) //nolint:gosec // G304: path is chosen by the operator
+	if err != nil {
+		return nil, fmt.Errorf("read role templates: %w", err)
+	}
+	var roles []RoleTemplate
+	if err := json.Unmarshal(raw, &roles); err != nil {
+		return nil, fmt.Errorf("parse role templates %s: %w", path, err)
+	}
+	for _, r := range roles {
+		if r.Name == "" {
+			return nil, fmt.Errorf("role template in %s has no name", path)
+		}
+		for _, p := range r.Permissions {
+			if p.Resource == "" || p.Action == "" {
+				return nil, fmt.Errorf("role template %q has a permission without resource or action", r.Name)
+			}
+		}
+	}
+	return roles, nil
+}
@@ -0,0 +1,106 @@
+package rbac
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// templateStore answers GetUserPermissions with one role template's
+// permissions and records the roles it is asked to seed.
+type templateStore struct {
+	Store
+	role   RoleTemplate
+	seeded [][]RoleTemplate
+}
+
+func (s *templateStore) GetUserPermissions(context.Context, string, string) ([]Permission, error) {
+	perms := make([]Permission, len(s.role.Permissions))
+	for i, p := range s.role.Permissions {
+		perms[i] = Permission{Resource: p.Resource, Action: p.Action}
+	}
+	return perms, nil
+}
+
+func (s *templateStore) SeedRoles(_ context.Context, _ string, roles []RoleTemplate) error {
+	s.seeded = append(s.seeded, roles)
+	return nil
+}
+
+func TestDefaultRolesGrantBaselinePermissions(t *testing.T) {
+	roles := map[string]RoleTemplate{}
+	for _, r := range DefaultRoles() {
+		roles[r.Name] = r
+	}
+	cases := []struct {
+		role, resource, action string
+		want                   bool
+	}{
+		{"admin", "users", "delete", true},
+		{"admin", "campaigns", "write", true},
+		{"member", "users", "read", true},
+		{"member", "access_requests", "create", true},
+		{"member", "users", "write", false},
+		{"viewer", "groups", "read", true},
+		{"viewer", "access_requests", "create", false},
+	}
+	for _, tc := range cases {
+		role, ok := roles[tc.role]
+		if !ok {
+			t.Fatalf("default roles lack %q", tc.role)
+		}
+		svc := NewService(&templateStore{role: role}, ServiceConfig{})
+		got, err := svc.HasPermission(context.Background(), testTenant, userA, tc.resource, tc.action)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("%s %s:%s = %v, want %v", tc.role, tc.resource, tc.action, got, tc.want)
+		}
+	}
+}
+
+func TestSeedDefaultRolesUsesConfiguredSet(t *testing.T) {
+	custom := []RoleTemplate{{Name: "auditor", Permissions: []PermissionTemplate{{Resource: "audit_logs", Action: "read"}}}}
+	store := &templateStore{}
+	svc := NewService(store, ServiceConfig{DefaultRoles: custom})
+	if err := svc.SeedDefaultRoles(context.Background(), testTenant); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.seeded) != 1 || !reflect.DeepEqual(store.seeded[0], custom) {
+		t.Fatalf("seeded %+v, want the configured set", store.seeded)
+	}
+
+	store = &templateStore{}
+	if err := NewService(store, ServiceConfig{}).SeedDefaultRoles(context.Background(), testTenant); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(store.seeded[0], DefaultRoles()) {
+		t.Fatalf("seeded %+v, want DefaultRoles", store.seeded[0])
+	}
+}
+
+func TestLoadRoleTemplates(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "roles.json")
+	if err := os.WriteFile(valid, []byte(`[{"name":"auditor","permissions":[{"resource":"audit_logs","action":"read"}]}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	roles, err := LoadRoleTemplates(valid)
+	if err != nil {
+		t.Fatalf("LoadRoleTemplates: %v", err)
+	}
+	if len(roles) != 1 || roles[0].Permissions[0].Resource != "audit_logs" {
+		t.Fatalf("roles = %+v", roles)
+	}
+
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(invalid, []byte(`[{"name":"auditor","permissions":[{"resource":"audit_logs"}]}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadRoleTemplates(invalid); err == nil {
+		t.Fatal("expected an error for a permission without an action")
+	}
+}
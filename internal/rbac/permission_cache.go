@@ -0,0 +1,204 @@
+package rbac
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPermissionCacheSize bounds the number of users a PermissionCache
+// holds when PermissionCacheConfig.MaxEntries is zero.
+const DefaultPermissionCacheSize = 10000
+
+// PermissionCacheConfig controls NewPermissionCache.
+type PermissionCacheConfig struct {
+	// TTL is how long a user's effective permissions are served from memory.
+	TTL time.Duration
+	// MaxEntries caps the cached users; 0 uses DefaultPermissionCacheSize.
+	MaxEntries int
+	// OnLookup, when set, is called for every GetUserPermissions with whether
+	// it was served from the cache, e.g. to feed a hit-rate metric.
+	OnLookup func(hit bool)
+}
+
+type permissionEntry struct {
+	perms   []Permission
+	expires time.Time
+}
+
+// PermissionCache is a Store that keeps each user's effective permissions in
+// memory, sparing authorization checks the permissions→role_permissions→
+// user_roles join. Every mutation that can change a user's permissions
+// invalidates the affected entries: role assignments drop the user, and
+// role-permission changes, group role assignments and role deletion, which
+// reach users only through roles or groups, drop the whole cache. Other
+// methods pass through to the wrapped store.
+//
+// The cache is per process, so with several replicas a change made on one is
+// seen by the others only once their entries expire; the same holds for group
+// membership, which the directory service changes. Keep TTL short.
+type PermissionCache struct {
+	Store
+
+	ttl        time.Duration
+	maxEntries int
+	onLookup   func(hit bool)
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]permissionEntry
+	// generation increases on every invalidation so a load that raced with
+	// one does not store what it read before the change.
+	generation uint64
+}
+
+// NewPermissionCache wraps store with a permission cache.
+func NewPermissionCache(store Store, cfg PermissionCacheConfig) *PermissionCache {
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = DefaultPermissionCacheSize
+	}
+	return &PermissionCache{
+		Store:      store,
+		ttl:        cfg.TTL,
+		maxEntries: cfg.MaxEntries,
+		onLookup:   cfg.OnLookup,
+		now:        time.Now,
+		entries:    make(map[string]permissionEntry),
+	}
+}
+
+func permissionKey(tenantID, userID string) string {
+	return tenantID + "\x00" + userID
+}
+
+// GetUserPermissions serves the user's permissions from the cache, loading
+// them from the store on a miss.
+func (c *PermissionCache) GetUserPermissions(ctx context.Context, tenantID, userID string) ([]Permission, error) {
+	key := permissionKey(tenantID, userID)
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if ok && c.now().Before(entry.expires) {
+		c.mu.Unlock()
+		c.recordLookup(true)
+		return slices.Clone(entry.perms), nil
+	}
+	generation := c.generation
+	c.mu.Unlock()
+	c.recordLookup(false)
+
+	perms, err := c.Store.GetUserPermissions(ctx, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation == generation {
+		if len(c.entries) >= c.maxEntries {
+			c.evictLocked()
+		}
+		c.entries[key] = permissionEntry{perms: slices.Clone(perms), expires: c.now().Add(c.ttl)}
+	}
+	return perms, nil
+}
+
+func (c *PermissionCache) AssignRoleToUser(ctx context.Context, tenantID, userID, roleID string, assignedBy *string) error {
+	err := c.Store.AssignRoleToUser(ctx, tenantID, userID, roleID, assignedBy)
+	c.InvalidateUser(userID)
+	return err
+}
+
+func (c *PermissionCache) AssignRoleToUsers(ctx context.Context, tenantID, roleID string, userIDs []string, assignedBy *string) (int, error) {
+	added, err := c.Store.AssignRoleToUsers(ctx, tenantID, roleID, userIDs, assignedBy)
+	for _, userID := range userIDs {
+		c.InvalidateUser(userID)
+	}
+	return added, err
+}
+
+func (c *PermissionCache) RemoveRoleFromUser(ctx context.Context, userID, roleID string) error {
+	err := c.Store.RemoveRoleFromUser(ctx, userID, roleID)
+	c.InvalidateUser(userID)
+	return err
+}
+
+func (c *PermissionCache) AssignPermissionToRole(ctx context.Context, roleID, permissionID string) error {
+	err := c.Store.AssignPermissionToRole(ctx, roleID, permissionID)
+	c.InvalidateAll()
+	return err
+}
+
+func (c *PermissionCache) RemovePermissionFromRole(ctx context.Context, roleID, permissionID string) error {
+	err := c.Store.RemovePermissionFromRole(ctx, roleID, permissionID)
+	c.InvalidateAll()
+	return err
+}
+
+func (c *PermissionCache) AssignRoleToGroup(ctx context.Context, tenantID, groupID, roleID string, assignedBy *string) error {
+	err := c.Store.AssignRoleToGroup(ctx, tenantID, groupID, roleID, assignedBy)
+	c.InvalidateAll()
+	return err
+}
+
+func (c *PermissionCache) RemoveRoleFromGroup(ctx context.Context, tenantID, groupID, roleID string) error {
+	err := c.Store.RemoveRoleFromGroup(ctx, tenantID, groupID, roleID)
+	c.InvalidateAll()
+	return err
+}
+
+func (c *PermissionCache) SeedRoles(ctx context.Context, tenantID string, roles []RoleTemplate) error {
+	err := c.Store.SeedRoles(ctx, tenantID, roles)
+	c.InvalidateAll()
+	return err
+}
+
+func (c *PermissionCache) DeleteRole(ctx context.Context, tenantID, id string) error {
+	err := c.Store.DeleteRole(ctx, tenantID, id)
+	c.InvalidateAll()
+	return err
+}
+
+// InvalidateUser drops the user's cached permissions in every tenant.
+// Mutations invalidate even when the store reports an error, since the
+// change may have been applied anyway.
+func (c *PermissionCache) InvalidateUser(userID string) {
+	suffix := "\x00" + userID
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	for key := range c.entries {
+		if strings.HasSuffix(key, suffix) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// InvalidateAll empties the cache.
+func (c *PermissionCache) InvalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	clear(c.entries)
+}
+
+// evictLocked makes room for an entry: it drops expired entries and, if the
+// cache is still full, everything.
+func (c *PermissionCache) evictLocked() {
+	now := c.now()
+	for key, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+	if len(c.entries) >= c.maxEntries {
+		clear(c.entries)
+	}
+}
+
+func (c *PermissionCache) recordLookup(hit bool) {
+	if c.onLookup != nil {
+		c.onLookup(hit)
+	}
+}
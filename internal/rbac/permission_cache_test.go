@@ -0,0 +1,140 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeStore serves permissions from memory and counts permission queries.
+type fakeStore struct {
+	Store
+	perms   map[string][]Permission // by user ID
+	queries int
+}
+
+func (f *fakeStore) GetUserPermissions(_ context.Context, _, userID string) ([]Permission, error) {
+	f.queries++
+	return f.perms[userID], nil
+}
+
+func (f *fakeStore) AssignRoleToUser(_ context.Context, _, userID, _ string, _ *string) error {
+	f.perms[userID] = append(f.perms[userID], Permission{Resource: "reports", Action: "read"})
+	return nil
+}
+
+func (f *fakeStore) AssignPermissionToRole(context.Context, string, string) error { return nil }
+
+func (f *fakeStore) AssignRoleToGroup(context.Context, string, string, string, *string) error {
+	return nil
+}
+
+func newCachedStore(t *testing.T) (*PermissionCache, *fakeStore, *time.Time, *[2]int) {
+	t.Helper()
+	store := &fakeStore{perms: map[string][]Permission{
+		"alice": {{Resource: "users", Action: "read"}},
+	}}
+	var lookups [2]int // misses, hits
+	cache := NewPermissionCache(store, PermissionCacheConfig{
+		TTL: time.Minute,
+		OnLookup: func(hit bool) {
+			if hit {
+				lookups[1]++
+			} else {
+				lookups[0]++
+			}
+		},
+	})
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+	return cache, store, &now, &lookups
+}
+
+func TestPermissionCacheServesRepeatLookups(t *testing.T) {
+	cache, store, _, lookups := newCachedStore(t)
+	ctx := context.Background()
+
+	for range 3 {
+		perms, err := cache.GetUserPermissions(ctx, "tenant-1", "alice")
+		if err != nil || len(perms) != 1 {
+			t.Fatalf("GetUserPermissions = %v, %v", perms, err)
+		}
+	}
+	if store.queries != 1 {
+		t.Fatalf("store queried %d times, want 1", store.queries)
+	}
+	if *lookups != [2]int{1, 2} {
+		t.Fatalf("misses, hits = %v, want [1 2]", *lookups)
+	}
+	// Another tenant has its own entry.
+	if _, err := cache.GetUserPermissions(ctx, "tenant-2", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if store.queries != 2 {
+		t.Fatalf("store queried %d times, want 2", store.queries)
+	}
+}
+
+func TestPermissionCacheInvalidatedByRoleAssignment(t *testing.T) {
+	cache, store, _, _ := newCachedStore(t)
+	ctx := context.Background()
+
+	if _, err := cache.GetUserPermissions(ctx, "tenant-1", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.AssignRoleToUser(ctx, "tenant-1", "alice", "role-1", nil); err != nil {
+		t.Fatal(err)
+	}
+	perms, err := cache.GetUserPermissions(ctx, "tenant-1", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(perms) != 2 || store.queries != 2 {
+		t.Fatalf("after assignment got %d permissions from %d queries, want 2 from 2", len(perms), store.queries)
+	}
+
+	// Role-permission changes may affect any user, so they clear everything.
+	if err := cache.AssignPermissionToRole(ctx, "role-1", "perm-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.GetUserPermissions(ctx, "tenant-1", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if store.queries != 3 {
+		t.Fatalf("store queried %d times after role change, want 3", store.queries)
+	}
+
+	// So do group role assignments, since members are not known here.
+	if err := cache.AssignRoleToGroup(ctx, "tenant-1", "group-1", "role-1", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.GetUserPermissions(ctx, "tenant-1", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if store.queries != 4 {
+		t.Fatalf("store queried %d times after group role change, want 4", store.queries)
+	}
+}
+
+func TestPermissionCacheExpires(t *testing.T) {
+	cache, store, now, _ := newCachedStore(t)
+	ctx := context.Background()
+
+	if _, err := cache.GetUserPermissions(ctx, "tenant-1", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	*now = now.Add(59 * time.Second)
+	if _, err := cache.GetUserPermissions(ctx, "tenant-1", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if store.queries != 1 {
+		t.Fatalf("store queried %d times within TTL, want 1", store.queries)
+	}
+	*now = now.Add(time.Second)
+	if _, err := cache.GetUserPermissions(ctx, "tenant-1", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if store.queries != 2 {
+		t.Fatalf("store queried %d times after TTL, want 2", store.queries)
+	}
+}
@@ -3,6 +3,8 @@ package rbac
 import (
 	"context"
 	"fmt"
+
+	"github.com/dhawalhost/wardseal/pkg/middleware"
 )
 
 // Service defines RBAC service operations.
@@ -25,21 +27,52 @@ type Service interface {
 
 	// User-Role
 	AssignRoleToUser(ctx context.Context, tenantID, userID, roleID string, assignedBy *string) error
+	AssignRoleToUsers(ctx context.Context, tenantID, roleID string, userIDs []string, assignedBy *string) (BulkAssignment, error)
 	RemoveRoleFromUser(ctx context.Context, userID, roleID string) error
 	GetUserRoles(ctx context.Context, tenantID, userID string) ([]Role, error)
 	GetUserPermissions(ctx context.Context, tenantID, userID string) ([]Permission, error)
 
+	// Group-Role
+	AssignRoleToGroup(ctx context.Context, tenantID, groupID, roleID string, assignedBy *string) error
+	RemoveRoleFromGroup(ctx context.Context, tenantID, groupID, roleID string) error
+	GetGroupRoles(ctx context.Context, tenantID, groupID string) ([]Role, error)
+
+	// SeedDefaultRoles creates the configured default roles in the tenant.
+	// It is idempotent; call it when a tenant is provisioned.
+	SeedDefaultRoles(ctx context.Context, tenantID string) error
+
 	// Authorization check
 	HasPermission(ctx context.Context, tenantID, userID, resource, action string) (bool, error)
 }
 
+// BulkAssignment counts the outcome of assigning a role to many users.
+// Skipped users already held the role or were listed more than once.
+type BulkAssignment struct {
+	Added   int `json:"added"`
+	Skipped int `json:"skipped"`
+}
+
+// ServiceConfig configures NewService.
+type ServiceConfig struct {
+	// DefaultRoles is the role set SeedDefaultRoles creates; nil uses
+	// DefaultRoles().
+	DefaultRoles []RoleTemplate
+}
+
+// The service can back middleware.Authorizer route guards.
+var _ middleware.PermissionChecker = (*service)(nil)
+
 type service struct {
-	store Store
+	store        Store
+	defaultRoles []RoleTemplate
 }
 
 // NewService creates a new RBAC service.
-func NewService(store Store) Service {
-	return &service{store: store}
+func NewService(store Store, cfg ServiceConfig) Service {
+	if cfg.DefaultRoles == nil {
+		cfg.DefaultRoles = DefaultRoles()
+	}
+	return &service{store: store, defaultRoles: cfg.DefaultRoles}
 }
 
 func (s *service) CreateRole(ctx context.Context, tenantID, name, description string) (Role, error) {
@@ -109,6 +142,19 @@ func (s *service) AssignRoleToUser(ctx context.Context, tenantID, userID, roleID
 	return s.store.AssignRoleToUser(ctx, tenantID, userID, roleID, assignedBy)
 }
 
+// AssignRoleToUsers assigns a role of the tenant to each of userIDs at once.
+// It is idempotent: users that already hold the role are counted as skipped.
+func (s *service) AssignRoleToUsers(ctx context.Context, tenantID, roleID string, userIDs []string, assignedBy *string) (BulkAssignment, error) {
+	if _, err := s.store.GetRole(ctx, tenantID, roleID); err != nil {
+		return BulkAssignment{}, err
+	}
+	added, err := s.store.AssignRoleToUsers(ctx, tenantID, roleID, userIDs, assignedBy)
+	if err != nil {
+		return BulkAssignment{}, fmt.Errorf("failed to assign role: %w", err)
+	}
+	return BulkAssignment{Added: added, Skipped: len(userIDs) - added}, nil
+}
+
 func (s *service) RemoveRoleFromUser(ctx context.Context, userID, roleID string) error {
 	return s.store.RemoveRoleFromUser(ctx, userID, roleID)
 }
@@ -121,6 +167,25 @@ func (s *service) GetUserPermissions(ctx context.Context, tenantID, userID strin
 	return s.store.GetUserPermissions(ctx, tenantID, userID)
 }
 
+func (s *service) AssignRoleToGroup(ctx context.Context, tenantID, groupID, roleID string, assignedBy *string) error {
+	return s.store.AssignRoleToGroup(ctx, tenantID, groupID, roleID, assignedBy)
+}
+
+func (s *service) RemoveRoleFromGroup(ctx context.Context, tenantID, groupID, roleID string) error {
+	return s.store.RemoveRoleFromGroup(ctx, tenantID, groupID, roleID)
+}
+
+func (s *service) GetGroupRoles(ctx context.Context, tenantID, groupID string) ([]Role, error) {
+	return s.store.GetGroupRoles(ctx, tenantID, groupID)
+}
+
+func (s *service) SeedDefaultRoles(ctx context.Context, tenantID string) error {
+	if err := s.store.SeedRoles(ctx, tenantID, s.defaultRoles); err != nil {
+		return fmt.Errorf("failed to seed default roles: %w", err)
+	}
+	return nil
+}
+
 // HasPermission checks if a user has a specific permission.
 func (s *service) HasPermission(ctx context.Context, tenantID, userID, resource, action string) (bool, error) {
 	perms, err := s.store.GetUserPermissions(ctx, tenantID, userID)
@@ -2,9 +2,14 @@ package rbac
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"time"
 
+	"github.com/dhawalhost/wardseal/pkg/database"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 // Role represents an RBAC role.
@@ -57,79 +62,117 @@ type Store interface {
 
 	// User-Role mapping
 	AssignRoleToUser(ctx context.Context, tenantID, userID, roleID string, assignedBy *string) error
+	// AssignRoleToUsers assigns the role to every user in one statement and
+	// returns how many assignments were new.
+	AssignRoleToUsers(ctx context.Context, tenantID, roleID string, userIDs []string, assignedBy *string) (int, error)
 	RemoveRoleFromUser(ctx context.Context, userID, roleID string) error
+	// GetUserRoles and GetUserPermissions include roles the user inherits
+	// through group membership.
 	GetUserRoles(ctx context.Context, tenantID, userID string) ([]Role, error)
 	GetUserPermissions(ctx context.Context, tenantID, userID string) ([]Permission, error)
+
+	// SeedRoles creates the roles that do not exist in the tenant yet, with
+	// their permissions, in one transaction. Existing roles are left as they
+	// are, so re-running it is harmless and keeps admins' edits.
+	SeedRoles(ctx context.Context, tenantID string, roles []RoleTemplate) error
+
+	// Group-Role mapping
+	AssignRoleToGroup(ctx context.Context, tenantID, groupID, roleID string, assignedBy *string) error
+	RemoveRoleFromGroup(ctx context.Context, tenantID, groupID, roleID string) error
+	GetGroupRoles(ctx context.Context, tenantID, groupID string) ([]Role, error)
 }
 
 type store struct {
-	db *sqlx.DB
+	stmts *database.StmtCache
+}
+
+// NewStore creates a new RBAC store. Its queries are prepared once through
+// stmts, which the caller closes on shutdown.
+func NewStore(stmts *database.StmtCache) Store {
+	return &store{stmts: stmts}
 }
 
-// NewStore creates a new RBAC store.
-func NewStore(db *sqlx.DB) Store {
-	return &store{db: db}
+// get, selectAll and exec run query through the statement cache, which bounds
+// it and logs it as "rbac.<name>" when slow.
+func (s *store) get(ctx context.Context, name string, dest any, query string, args ...any) error {
+	return s.stmts.Query(ctx, "rbac."+name, query, func(ctx context.Context, stmt *sqlx.Stmt) error {
+		return stmt.GetContext(ctx, dest, args...)
+	})
+}
+
+func (s *store) selectAll(ctx context.Context, name string, dest any, query string, args ...any) error {
+	return s.stmts.Query(ctx, "rbac."+name, query, func(ctx context.Context, stmt *sqlx.Stmt) error {
+		return stmt.SelectContext(ctx, dest, args...)
+	})
+}
+
+func (s *store) exec(ctx context.Context, name, query string, args ...any) (sql.Result, error) {
+	var res sql.Result
+	err := s.stmts.Query(ctx, "rbac."+name, query, func(ctx context.Context, stmt *sqlx.Stmt) error {
+		var err error
+		res, err = stmt.ExecContext(ctx, args...)
+		return err
+	})
+	return res, err
 }
 
 func (s *store) CreateRole(ctx context.Context, r Role) (string, error) {
 	var id string
-	err := s.db.QueryRowxContext(ctx,
+	err := s.get(ctx, "CreateRole", &id,
 		`INSERT INTO roles (tenant_id, name, description) VALUES ($1, $2, $3) RETURNING id`,
-		r.TenantID, r.Name, r.Description,
-	).Scan(&id)
+		r.TenantID, r.Name, r.Description)
 	return id, err
 }
 
 func (s *store) GetRole(ctx context.Context, tenantID, id string) (Role, error) {
 	var r Role
-	err := s.db.GetContext(ctx, &r, `SELECT * FROM roles WHERE id = $1 AND tenant_id = $2`, id, tenantID)
+	err := s.get(ctx, "GetRole", &r, `SELECT * FROM roles WHERE id = $1 AND tenant_id = $2`, id, tenantID)
 	return r, err
 }
 
 func (s *store) GetRoleByName(ctx context.Context, tenantID, name string) (Role, error) {
 	var r Role
-	err := s.db.GetContext(ctx, &r, `SELECT * FROM roles WHERE name = $1 AND tenant_id = $2`, name, tenantID)
+	err := s.get(ctx, "GetRoleByName", &r, `SELECT * FROM roles WHERE name = $1 AND tenant_id = $2`, name, tenantID)
 	return r, err
 }
 
 func (s *store) ListRoles(ctx context.Context, tenantID string) ([]Role, error) {
 	var roles []Role
-	err := s.db.SelectContext(ctx, &roles, `SELECT * FROM roles WHERE tenant_id = $1 ORDER BY name`, tenantID)
+	err := s.selectAll(ctx, "ListRoles", &roles, `SELECT * FROM roles WHERE tenant_id = $1 ORDER BY name`, tenantID)
 	return roles, err
 }
 
 func (s *store) UpdateRole(ctx context.Context, id string, r Role) error {
-	_, err := s.db.ExecContext(ctx,
+	_, err := s.exec(ctx, "UpdateRole",
 		`UPDATE roles SET name = $1, description = $2, updated_at = NOW() WHERE id = $3`,
 		r.Name, r.Description, id)
 	return err
 }
 
 func (s *store) DeleteRole(ctx context.Context, tenantID, id string) error {
-	_, err := s.db.ExecContext(ctx, `DELETE FROM roles WHERE id = $1 AND tenant_id = $2`, id, tenantID)
+	_, err := s.exec(ctx, "DeleteRole", `DELETE FROM roles WHERE id = $1 AND tenant_id = $2`, id, tenantID)
 	return err
 }
 
 func (s *store) CreatePermission(ctx context.Context, p Permission) (string, error) {
 	var id string
-	err := s.db.QueryRowxContext(ctx,
+	err := s.get(ctx, "CreatePermission", &id,
 		`INSERT INTO permissions (tenant_id, resource, action, description) 
 		 VALUES ($1, $2, $3, $4) RETURNING id`,
-		p.TenantID, p.Resource, p.Action, p.Description,
-	).Scan(&id)
+		p.TenantID, p.Resource, p.Action, p.Description)
 	return id, err
 }
 
 func (s *store) ListPermissions(ctx context.Context, tenantID string) ([]Permission, error) {
 	var perms []Permission
-	err := s.db.SelectContext(ctx, &perms,
+	err := s.selectAll(ctx, "ListPermissions", &perms,
 		`SELECT * FROM permissions WHERE tenant_id = $1 ORDER BY resource, action`, tenantID)
 	return perms, err
 }
 
 func (s *store) GetPermissionsByRole(ctx context.Context, roleID string) ([]Permission, error) {
 	var perms []Permission
-	err := s.db.SelectContext(ctx, &perms,
+	err := s.selectAll(ctx, "GetPermissionsByRole", &perms,
 		`SELECT p.* FROM permissions p 
 		 JOIN role_permissions rp ON p.id = rp.permission_id 
 		 WHERE rp.role_id = $1`, roleID)
@@ -137,49 +180,136 @@ func (s *store) GetPermissionsByRole(ctx context.Context, roleID string) ([]Perm
 }
 
 func (s *store) AssignPermissionToRole(ctx context.Context, roleID, permissionID string) error {
-	_, err := s.db.ExecContext(ctx,
+	_, err := s.exec(ctx, "AssignPermissionToRole",
 		`INSERT INTO role_permissions (role_id, permission_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
 		roleID, permissionID)
 	return err
 }
 
 func (s *store) RemovePermissionFromRole(ctx context.Context, roleID, permissionID string) error {
-	_, err := s.db.ExecContext(ctx,
+	_, err := s.exec(ctx, "RemovePermissionFromRole",
 		`DELETE FROM role_permissions WHERE role_id = $1 AND permission_id = $2`,
 		roleID, permissionID)
 	return err
 }
 
 func (s *store) AssignRoleToUser(ctx context.Context, tenantID, userID, roleID string, assignedBy *string) error {
-	_, err := s.db.ExecContext(ctx,
+	_, err := s.exec(ctx, "AssignRoleToUser",
 		`INSERT INTO user_roles (user_id, role_id, tenant_id, assigned_by) 
 		 VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING`,
 		userID, roleID, tenantID, assignedBy)
 	return err
 }
 
+func (s *store) AssignRoleToUsers(ctx context.Context, tenantID, roleID string, userIDs []string, assignedBy *string) (int, error) {
+	res, err := s.exec(ctx, "AssignRoleToUsers",
+		`INSERT INTO user_roles (user_id, role_id, tenant_id, assigned_by)
+		 SELECT DISTINCT u, $2::uuid, $3::uuid, $4::uuid FROM unnest($1::uuid[]) AS u
+		 ON CONFLICT DO NOTHING`,
+		pq.Array(userIDs), roleID, tenantID, assignedBy)
+	if err != nil {
+		return 0, err
+	}
+	added, err := res.RowsAffected()
+	return int(added), err
+}
+
 func (s *store) RemoveRoleFromUser(ctx context.Context, userID, roleID string) error {
-	_, err := s.db.ExecContext(ctx,
+	_, err := s.exec(ctx, "RemoveRoleFromUser",
 		`DELETE FROM user_roles WHERE user_id = $1 AND role_id = $2`,
 		userID, roleID)
 	return err
 }
 
+// userRoleIDs selects the IDs of the roles user $1 holds in tenant $2, directly
+// or through the groups they belong to.
+const userRoleIDs = `SELECT role_id FROM user_roles WHERE user_id = $1 AND tenant_id = $2
+		 UNION
+		 SELECT gr.role_id FROM group_roles gr
+		 JOIN identity_groups ig ON ig.group_id = gr.group_id
+		 WHERE ig.identity_id = $1 AND ig.tenant_id = $2 AND gr.tenant_id = $2`
+
 func (s *store) GetUserRoles(ctx context.Context, tenantID, userID string) ([]Role, error) {
 	var roles []Role
-	err := s.db.SelectContext(ctx, &roles,
-		`SELECT r.* FROM roles r 
-		 JOIN user_roles ur ON r.id = ur.role_id 
-		 WHERE ur.user_id = $1 AND ur.tenant_id = $2`, userID, tenantID)
+	err := s.selectAll(ctx, "GetUserRoles", &roles,
+		`SELECT r.* FROM roles r
+		 WHERE r.tenant_id = $2 AND r.id IN (`+userRoleIDs+`)
+		 ORDER BY r.name`, userID, tenantID)
 	return roles, err
 }
 
 func (s *store) GetUserPermissions(ctx context.Context, tenantID, userID string) ([]Permission, error) {
 	var perms []Permission
-	err := s.db.SelectContext(ctx, &perms,
+	err := s.selectAll(ctx, "GetUserPermissions", &perms,
 		`SELECT DISTINCT p.* FROM permissions p
 		 JOIN role_permissions rp ON p.id = rp.permission_id
-		 JOIN user_roles ur ON rp.role_id = ur.role_id
-		 WHERE ur.user_id = $1 AND ur.tenant_id = $2`, userID, tenantID)
+		 WHERE rp.role_id IN (`+userRoleIDs+`)`, userID, tenantID)
 	return perms, err
 }
+
+func (s *store) AssignRoleToGroup(ctx context.Context, tenantID, groupID, roleID string, assignedBy *string) error {
+	_, err := s.exec(ctx, "AssignRoleToGroup",
+		`INSERT INTO group_roles (group_id, role_id, tenant_id, assigned_by)
+		 VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING`,
+		groupID, roleID, tenantID, assignedBy)
+	return err
+}
+
+func (s *store) RemoveRoleFromGroup(ctx context.Context, tenantID, groupID, roleID string) error {
+	_, err := s.exec(ctx, "RemoveRoleFromGroup",
+		`DELETE FROM group_roles WHERE group_id = $1 AND role_id = $2 AND tenant_id = $3`,
+		groupID, roleID, tenantID)
+	return err
+}
+
+func (s *store) GetGroupRoles(ctx context.Context, tenantID, groupID string) ([]Role, error) {
+	var roles []Role
+	err := s.selectAll(ctx, "GetGroupRoles", &roles,
+		`SELECT r.* FROM roles r
+		 JOIN group_roles gr ON r.id = gr.role_id
+		 WHERE gr.group_id = $1 AND gr.tenant_id = $2
+		 ORDER BY r.name`, groupID, tenantID)
+	return roles, err
+}
+
+func (s *store) SeedRoles(ctx context.Context, tenantID string, roles []RoleTemplate) error {
+	return s.stmts.Run(ctx, "rbac.SeedRoles", func(ctx context.Context) error {
+		tx, err := s.stmts.DB().BeginTxx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = tx.Rollback() }()
+
+		for _, r := range roles {
+			var roleID string
+			err := tx.GetContext(ctx, &roleID,
+				`INSERT INTO roles (tenant_id, name, description) VALUES ($1, $2, $3)
+				 ON CONFLICT (tenant_id, name) DO NOTHING RETURNING id`,
+				tenantID, r.Name, r.Description)
+			if errors.Is(err, sql.ErrNoRows) {
+				continue // already seeded or created by an admin
+			}
+			if err != nil {
+				return fmt.Errorf("seed role %s: %w", r.Name, err)
+			}
+			for _, p := range r.Permissions {
+				var permID string
+				// The no-op update makes RETURNING yield the existing row too.
+				err := tx.GetContext(ctx, &permID,
+					`INSERT INTO permissions (tenant_id, resource, action) VALUES ($1, $2, $3)
+					 ON CONFLICT (tenant_id, resource, action) DO UPDATE SET resource = EXCLUDED.resource
+					 RETURNING id`,
+					tenantID, p.Resource, p.Action)
+				if err != nil {
+					return fmt.Errorf("seed permission %s:%s: %w", p.Resource, p.Action, err)
+				}
+				if _, err := tx.ExecContext(ctx,
+					`INSERT INTO role_permissions (role_id, permission_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+					roleID, permID); err != nil {
+					return fmt.Errorf("seed role %s: %w", r.Name, err)
+				}
+			}
+		}
+		return tx.Commit()
+	})
+}
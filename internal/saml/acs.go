@@ -0,0 +1,288 @@
+package saml
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	saml2 "github.com/crewjam/saml"
+)
+
+// DefaultRequestTTL bounds how long an outstanding AuthnRequest can be answered.
+const DefaultRequestTTL = 10 * time.Minute
+
+var (
+	// ErrUnknownRequest is returned when a response does not answer a request we issued.
+	ErrUnknownRequest = errors.New("saml response does not match an outstanding request")
+	// ErrRequestNotBound is returned when a response answers a request started by another browser.
+	ErrRequestNotBound = errors.New("saml response does not answer a request started by this browser")
+	// ErrAssertionReplayed is returned when a response answers a request that was already consumed.
+	ErrAssertionReplayed = errors.New("saml assertion has already been consumed")
+	// ErrIdentityProviderNotFound is returned when no enabled SAML IdP matches the lookup.
+	ErrIdentityProviderNotFound = errors.New("saml identity provider not found")
+)
+
+// IdentityProvider is an external SAML IdP trusted by a tenant when we act as SP.
+type IdentityProvider struct {
+	TenantID    string `db:"tenant_id"`
+	Name        string `db:"name"`
+	EntityID    string `db:"saml_entity_id"`
+	SSOURL      string `db:"saml_sso_url"`
+	Certificate string `db:"saml_certificate"` // PEM
+}
+
+// AuthnRequestRecord tracks an AuthnRequest we sent so its response can be matched.
+type AuthnRequestRecord struct {
+	ID         string     `db:"id"`
+	TenantID   string     `db:"tenant_id"`
+	Provider   string     `db:"provider"`
+	CreatedAt  time.Time  `db:"created_at"`
+	ExpiresAt  time.Time  `db:"expires_at"`
+	ConsumedAt *time.Time `db:"consumed_at"`
+}
+
+// RequestStore persists outstanding AuthnRequest IDs.
+type RequestStore interface {
+	SaveRequest(ctx context.Context, record AuthnRequestRecord) error
+	// GetRequest returns nil when the request ID is unknown.
+	GetRequest(ctx context.Context, id string) (*AuthnRequestRecord, error)
+	// ConsumeRequest marks the request as used, returning false if it was already consumed or expired.
+	ConsumeRequest(ctx context.Context, id string) (bool, error)
+}
+
+// IdentityProviderStore resolves the IdP configuration used to validate responses.
+type IdentityProviderStore interface {
+	GetIdentityProvider(ctx context.Context, tenantID, name string) (*IdentityProvider, error)
+}
+
+// ConsumerConfig holds the settings for the Assertion Consumer Service.
+type ConsumerConfig struct {
+	BaseURL           string
+	Certificate       *x509.Certificate
+	PrivateKey        *rsa.PrivateKey
+	Requests          RequestStore
+	IdentityProviders IdentityProviderStore
+	RequestTTL        time.Duration
+}
+
+// AssertionConsumer issues AuthnRequests to external IdPs and validates their responses.
+type AssertionConsumer struct {
+	entityID    url.URL
+	acsURL      url.URL
+	certificate *x509.Certificate
+	privateKey  *rsa.PrivateKey
+	requests    RequestStore
+	idps        IdentityProviderStore
+	requestTTL  time.Duration
+}
+
+// AssertionResult carries the verified identity extracted from an assertion.
+type AssertionResult struct {
+	TenantID   string
+	Provider   string
+	NameID     string
+	Attributes map[string][]string
+}
+
+// Attribute returns the first value of the named attribute, or "".
+func (r *AssertionResult) Attribute(name string) string {
+	if values := r.Attributes[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// NewAssertionConsumer creates a new AssertionConsumer.
+func NewAssertionConsumer(cfg ConsumerConfig) (*AssertionConsumer, error) {
+	baseURL, err := url.Parse(strings.TrimRight(cfg.BaseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	ttl := cfg.RequestTTL
+	if ttl == 0 {
+		ttl = DefaultRequestTTL
+	}
+	return &AssertionConsumer{
+		entityID:    *baseURL.JoinPath("saml", "metadata"),
+		acsURL:      *baseURL.JoinPath("saml", "acs"),
+		certificate: cfg.Certificate,
+		privateKey:  cfg.PrivateKey,
+		requests:    cfg.Requests,
+		idps:        cfg.IdentityProviders,
+		requestTTL:  ttl,
+	}, nil
+}
+
+// ACSURL returns the Assertion Consumer Service URL advertised to IdPs.
+func (c *AssertionConsumer) ACSURL() url.URL {
+	return c.acsURL
+}
+
+// BeginLogin creates an AuthnRequest for the named IdP, records its ID and
+// returns the HTTP-Redirect binding URL the browser should be sent to, along
+// with the request ID the browser must present again to Consume.
+func (c *AssertionConsumer) BeginLogin(ctx context.Context, tenantID, provider, relayState string) (*url.URL, string, error) {
+	idp, err := c.idps.GetIdentityProvider(ctx, tenantID, provider)
+	if err != nil {
+		return nil, "", err
+	}
+	sp, err := c.serviceProvider(idp)
+	if err != nil {
+		return nil, "", err
+	}
+	req, err := sp.MakeAuthenticationRequest(idp.SSOURL, saml2.HTTPRedirectBinding, saml2.HTTPPostBinding)
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	if err := c.requests.SaveRequest(ctx, AuthnRequestRecord{
+		ID:        req.ID,
+		TenantID:  tenantID,
+		Provider:  provider,
+		CreatedAt: now,
+		ExpiresAt: now.Add(c.requestTTL),
+	}); err != nil {
+		return nil, "", err
+	}
+	redirectURL, err := req.Redirect(relayState, sp)
+	if err != nil {
+		return nil, "", err
+	}
+	return redirectURL, req.ID, nil
+}
+
+// Consume validates a base64-encoded SAMLResponse posted to the ACS. The
+// signature is checked against the IdP certificate, InResponseTo must match an
+// outstanding request, and the request is consumed so the response cannot be replayed.
+// requestID is the ID BeginLogin returned to the browser posting the response;
+// a response to any other request is rejected, so one user's response cannot
+// sign in another browser.
+func (c *AssertionConsumer) Consume(ctx context.Context, samlResponse, requestID string) (*AssertionResult, error) {
+	raw, err := base64.StdEncoding.DecodeString(samlResponse)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SAMLResponse encoding: %w", err)
+	}
+
+	// InResponseTo is read before the signature check only to locate the
+	// request; it is verified again by ParseXMLResponse below.
+	var envelope struct {
+		InResponseTo string `xml:"InResponseTo,attr"`
+	}
+	if err := xml.Unmarshal(raw, &envelope); err != nil {
+		return nil, fmt.Errorf("invalid SAMLResponse: %w", err)
+	}
+	if envelope.InResponseTo == "" {
+		return nil, ErrUnknownRequest
+	}
+	if requestID == "" || subtle.ConstantTimeCompare([]byte(requestID), []byte(envelope.InResponseTo)) != 1 {
+		return nil, ErrRequestNotBound
+	}
+
+	record, err := c.requests.GetRequest(ctx, envelope.InResponseTo)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, ErrUnknownRequest
+	}
+	if record.ConsumedAt != nil {
+		return nil, ErrAssertionReplayed
+	}
+	if time.Now().After(record.ExpiresAt) {
+		return nil, ErrUnknownRequest
+	}
+
+	idp, err := c.idps.GetIdentityProvider(ctx, record.TenantID, record.Provider)
+	if err != nil {
+		return nil, err
+	}
+	sp, err := c.serviceProvider(idp)
+	if err != nil {
+		return nil, err
+	}
+
+	assertion, err := sp.ParseXMLResponse(raw, []string{record.ID}, c.acsURL)
+	if err != nil {
+		var invalid *saml2.InvalidResponseError
+		if errors.As(err, &invalid) && invalid.PrivateErr != nil {
+			return nil, fmt.Errorf("invalid saml response: %w", invalid.PrivateErr)
+		}
+		return nil, err
+	}
+
+	consumed, err := c.requests.ConsumeRequest(ctx, record.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !consumed {
+		return nil, ErrAssertionReplayed
+	}
+
+	result := &AssertionResult{
+		TenantID:   record.TenantID,
+		Provider:   record.Provider,
+		Attributes: make(map[string][]string),
+	}
+	if assertion.Subject != nil && assertion.Subject.NameID != nil {
+		result.NameID = assertion.Subject.NameID.Value
+	}
+	for _, statement := range assertion.AttributeStatements {
+		for _, attr := range statement.Attributes {
+			for _, value := range attr.Values {
+				result.Attributes[attr.Name] = append(result.Attributes[attr.Name], value.Value)
+			}
+		}
+	}
+	if result.NameID == "" {
+		return nil, errors.New("saml assertion has no NameID")
+	}
+	return result, nil
+}
+
+// serviceProvider builds the crewjam SP used to talk to a single IdP.
+func (c *AssertionConsumer) serviceProvider(idp *IdentityProvider) (*saml2.ServiceProvider, error) {
+	certData, err := pemToBase64DER(idp.Certificate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate for identity provider %s: %w", idp.Name, err)
+	}
+	sp := &saml2.ServiceProvider{
+		EntityID:    c.entityID.String(),
+		Certificate: c.certificate,
+		MetadataURL: c.entityID,
+		AcsURL:      c.acsURL,
+		IDPMetadata: &saml2.EntityDescriptor{
+			EntityID: idp.EntityID,
+			IDPSSODescriptors: []saml2.IDPSSODescriptor{{
+				SingleSignOnServices: []saml2.Endpoint{{
+					Binding:  saml2.HTTPRedirectBinding,
+					Location: idp.SSOURL,
+				}},
+			}},
+		},
+		IDPCertificate: &certData,
+	}
+	if c.privateKey != nil {
+		sp.Key = c.privateKey
+	}
+	return sp, nil
+}
+
+func pemToBase64DER(pemStr string) (string, error) {
+	block, _ := pem.Decode([]byte(pemStr))
+	if block == nil {
+		return "", errors.New("failed to decode PEM block")
+	}
+	if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(block.Bytes), nil
+}
@@ -0,0 +1,297 @@
+package saml
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	saml2 "github.com/crewjam/saml"
+)
+
+const (
+	testTenantID = "11111111-1111-1111-1111-111111111111"
+	testIdPURL   = "https://idp.example.com/metadata"
+)
+
+func TestConsumeValidAssertion(t *testing.T) {
+	consumer, idp, store := newTestConsumer(t)
+	requestID := beginTestLogin(t, consumer, store)
+
+	response := signedResponse(t, consumer, idp, requestID, time.Now())
+	result, err := consumer.Consume(context.Background(), response, requestID)
+	if err != nil {
+		t.Fatalf("consume error: %v", err)
+	}
+	if result.TenantID != testTenantID || result.Provider != "okta" {
+		t.Fatalf("unexpected tenant/provider: %s/%s", result.TenantID, result.Provider)
+	}
+	if result.NameID != "alice@example.com" {
+		t.Fatalf("expected NameID alice@example.com, got %q", result.NameID)
+	}
+	if got := result.Attribute("displayName"); got != "Alice" {
+		t.Fatalf("expected displayName attribute Alice, got %q", got)
+	}
+}
+
+func TestConsumeRejectsExpiredAssertion(t *testing.T) {
+	consumer, idp, store := newTestConsumer(t)
+	requestID := beginTestLogin(t, consumer, store)
+
+	response := signedResponse(t, consumer, idp, requestID, time.Now().Add(-time.Hour))
+	if _, err := consumer.Consume(context.Background(), response, requestID); err == nil {
+		t.Fatal("expected expired assertion to be rejected")
+	}
+}
+
+func TestConsumeRejectsReplayedAssertion(t *testing.T) {
+	consumer, idp, store := newTestConsumer(t)
+	requestID := beginTestLogin(t, consumer, store)
+
+	response := signedResponse(t, consumer, idp, requestID, time.Now())
+	if _, err := consumer.Consume(context.Background(), response, requestID); err != nil {
+		t.Fatalf("first consume error: %v", err)
+	}
+	if _, err := consumer.Consume(context.Background(), response, requestID); !errors.Is(err, ErrAssertionReplayed) {
+		t.Fatalf("expected ErrAssertionReplayed, got %v", err)
+	}
+}
+
+func TestConsumeRejectsUnsolicitedResponse(t *testing.T) {
+	consumer, idp, _ := newTestConsumer(t)
+
+	response := signedResponse(t, consumer, idp, "id-unknown", time.Now())
+	if _, err := consumer.Consume(context.Background(), response, "id-unknown"); !errors.Is(err, ErrUnknownRequest) {
+		t.Fatalf("expected ErrUnknownRequest, got %v", err)
+	}
+}
+
+func TestConsumeRejectsResponseForAnotherBrowser(t *testing.T) {
+	consumer, idp, store := newTestConsumer(t)
+	attackerRequest := beginTestLogin(t, consumer, store)
+	victimRequest := beginTestLogin(t, consumer, store)
+
+	// The attacker's own response, posted from a browser that started a
+	// different login or none at all.
+	response := signedResponse(t, consumer, idp, attackerRequest, time.Now())
+	for _, bound := range []string{victimRequest, ""} {
+		if _, err := consumer.Consume(context.Background(), response, bound); !errors.Is(err, ErrRequestNotBound) {
+			t.Fatalf("expected ErrRequestNotBound for browser bound to %q, got %v", bound, err)
+		}
+	}
+	if _, err := consumer.Consume(context.Background(), response, attackerRequest); err != nil {
+		t.Fatalf("expected the response to be accepted from the browser that started it, got %v", err)
+	}
+}
+
+func TestMetadataContainsACSAndCertificate(t *testing.T) {
+	_, cert := newTestKeyPair(t)
+	consumer, err := NewAssertionConsumer(ConsumerConfig{
+		BaseURL:     "https://wardseal.example.com",
+		Certificate: cert,
+	})
+	if err != nil {
+		t.Fatalf("failed to create consumer: %v", err)
+	}
+
+	metadata, err := consumer.MetadataXML()
+	if err != nil {
+		t.Fatalf("metadata error: %v", err)
+	}
+	doc := string(metadata)
+	for _, want := range []string{
+		`entityID="https://wardseal.example.com/saml/metadata"`,
+		`Binding="` + saml2.HTTPPostBinding + `" Location="https://wardseal.example.com/saml/acs"`,
+		`use="signing"`,
+		base64.StdEncoding.EncodeToString(cert.Raw),
+	} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("metadata missing %q:\n%s", want, doc)
+		}
+	}
+	if strings.Contains(doc, saml2.HTTPArtifactBinding) {
+		t.Errorf("metadata advertises unsupported artifact binding")
+	}
+}
+
+func newTestConsumer(t *testing.T) (*AssertionConsumer, *saml2.IdentityProvider, *memoryStore) {
+	t.Helper()
+	key, cert := newTestKeyPair(t)
+	metadataURL, _ := url.Parse(testIdPURL)
+	idp := &saml2.IdentityProvider{
+		Key:         key,
+		Certificate: cert,
+		MetadataURL: *metadataURL,
+	}
+
+	store := newMemoryStore()
+	store.idps[testTenantID+"/okta"] = &IdentityProvider{
+		TenantID:    testTenantID,
+		Name:        "okta",
+		EntityID:    testIdPURL,
+		SSOURL:      "https://idp.example.com/sso",
+		Certificate: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})),
+	}
+
+	consumer, err := NewAssertionConsumer(ConsumerConfig{
+		BaseURL:           "https://wardseal.example.com",
+		Requests:          store,
+		IdentityProviders: store,
+	})
+	if err != nil {
+		t.Fatalf("failed to create consumer: %v", err)
+	}
+	return consumer, idp, store
+}
+
+func beginTestLogin(t *testing.T, consumer *AssertionConsumer, store *memoryStore) string {
+	t.Helper()
+	_, requestID, err := consumer.BeginLogin(context.Background(), testTenantID, "okta", "")
+	if err != nil {
+		t.Fatalf("begin login error: %v", err)
+	}
+	if requestID != store.lastID {
+		t.Fatalf("expected the returned request ID %q to be the one stored, got %q", store.lastID, requestID)
+	}
+	return requestID
+}
+
+func signedResponse(t *testing.T, consumer *AssertionConsumer, idp *saml2.IdentityProvider, requestID string, now time.Time) string {
+	t.Helper()
+	acsURL := consumer.ACSURL()
+	req := &saml2.IdpAuthnRequest{
+		IDP:             idp,
+		Request:         saml2.AuthnRequest{ID: requestID},
+		ACSEndpoint:     &saml2.IndexedEndpoint{Binding: saml2.HTTPPostBinding, Location: acsURL.String()},
+		SPSSODescriptor: &saml2.SPSSODescriptor{},
+		Now:             now,
+		Assertion: &saml2.Assertion{
+			ID:           "id-assertion",
+			IssueInstant: now,
+			Version:      "2.0",
+			Issuer:       saml2.Issuer{Value: testIdPURL},
+			Subject: &saml2.Subject{
+				NameID: &saml2.NameID{Value: "alice@example.com"},
+				SubjectConfirmations: []saml2.SubjectConfirmation{{
+					Method: "urn:oasis:names:tc:SAML:2.0:cm:bearer",
+					SubjectConfirmationData: &saml2.SubjectConfirmationData{
+						InResponseTo: requestID,
+						NotOnOrAfter: now.Add(5 * time.Minute),
+						Recipient:    acsURL.String(),
+					},
+				}},
+			},
+			Conditions: &saml2.Conditions{
+				NotBefore:    now.Add(-time.Minute),
+				NotOnOrAfter: now.Add(5 * time.Minute),
+				AudienceRestrictions: []saml2.AudienceRestriction{{
+					Audience: saml2.Audience{Value: consumer.entityID.String()},
+				}},
+			},
+			AttributeStatements: []saml2.AttributeStatement{{
+				Attributes: []saml2.Attribute{{
+					Name:   "displayName",
+					Values: []saml2.AttributeValue{{Type: "xs:string", Value: "Alice"}},
+				}},
+			}},
+		},
+	}
+	if err := req.MakeResponse(); err != nil {
+		t.Fatalf("failed to build response: %v", err)
+	}
+
+	doc := etree.NewDocument()
+	doc.SetRoot(req.ResponseEl)
+	raw, err := doc.WriteToBytes()
+	if err != nil {
+		t.Fatalf("failed to serialize response: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(raw)
+}
+
+func newTestKeyPair(t *testing.T) (*rsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test-idp"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	return key, cert
+}
+
+type memoryStore struct {
+	mu       sync.Mutex
+	requests map[string]*AuthnRequestRecord
+	idps     map[string]*IdentityProvider
+	lastID   string
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		requests: make(map[string]*AuthnRequestRecord),
+		idps:     make(map[string]*IdentityProvider),
+	}
+}
+
+func (m *memoryStore) SaveRequest(ctx context.Context, record AuthnRequestRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[record.ID] = &record
+	m.lastID = record.ID
+	return nil
+}
+
+func (m *memoryStore) GetRequest(ctx context.Context, id string) (*AuthnRequestRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	record, ok := m.requests[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *record
+	return &copied, nil
+}
+
+func (m *memoryStore) ConsumeRequest(ctx context.Context, id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	record, ok := m.requests[id]
+	if !ok || record.ConsumedAt != nil || time.Now().After(record.ExpiresAt) {
+		return false, nil
+	}
+	now := time.Now()
+	record.ConsumedAt = &now
+	return true, nil
+}
+
+func (m *memoryStore) GetIdentityProvider(ctx context.Context, tenantID, name string) (*IdentityProvider, error) {
+	idp, ok := m.idps[tenantID+"/"+name]
+	if !ok {
+		return nil, ErrIdentityProviderNotFound
+	}
+	return idp, nil
+}
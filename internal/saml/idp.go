@@ -81,7 +81,8 @@ func (p *Provider) RegisterRoutes(rg *gin.RouterGroup) {
 	// But samlidp.Server expects to handle the requests itself.
 	// For now, let's mount specific paths that we know samlidp handles.
 
-	rg.GET("/saml/metadata", gin.WrapH(p.idp))
+	// "/saml/metadata" belongs to our Service Provider role, so IdP metadata lives under "/saml/idp".
+	rg.GET("/saml/idp/metadata", gin.WrapH(http.StripPrefix("/saml/idp", p.idp)))
 	rg.POST("/saml/sso", gin.WrapH(p.idp))
 	rg.GET("/saml/sso", gin.WrapH(p.idp)) // Support Redirect binding
 	rg.GET("/saml/idp-init", func(c *gin.Context) {
@@ -0,0 +1,28 @@
+package saml
+
+import (
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+)
+
+// LoadKeyPair reads the PEM certificate and RSA private key the platform signs
+// SAML messages with and publishes in its metadata. IdPs trust this
+// certificate, so it must be the same on every instance and across restarts.
+func LoadKeyPair(certFile, keyFile string) (*x509.Certificate, *rsa.PrivateKey, error) {
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("load SAML key pair: %w", err)
+	}
+	key, ok := pair.PrivateKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, nil, errors.New("SAML private key must be an RSA key")
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse SAML certificate: %w", err)
+	}
+	return cert, key, nil
+}
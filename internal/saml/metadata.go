@@ -0,0 +1,57 @@
+package saml
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"time"
+
+	saml2 "github.com/crewjam/saml"
+)
+
+// metadataValidity is how long IdPs may cache our SP metadata.
+const metadataValidity = 48 * time.Hour
+
+// Metadata returns the SP metadata describing our entity ID, ACS endpoint and
+// certificates. The entity ID is shared by every tenant (tenants are told apart
+// by the outstanding AuthnRequest), so the document is the same for all of them.
+func (c *AssertionConsumer) Metadata() *saml2.EntityDescriptor {
+	sp := saml2.ServiceProvider{
+		EntityID:              c.entityID.String(),
+		Certificate:           c.certificate,
+		MetadataURL:           c.entityID,
+		AcsURL:                c.acsURL,
+		MetadataValidDuration: metadataValidity,
+		AuthnNameIDFormat:     saml2.UnspecifiedNameIDFormat,
+	}
+	descriptor := sp.Metadata()
+
+	spDescriptor := &descriptor.SPSSODescriptors[0]
+	// Only the HTTP-POST binding is implemented by the ACS handler.
+	spDescriptor.AssertionConsumerServices = []saml2.IndexedEndpoint{{
+		Binding:  saml2.HTTPPostBinding,
+		Location: c.acsURL.String(),
+		Index:    1,
+	}}
+	if c.certificate != nil {
+		spDescriptor.KeyDescriptors = append(spDescriptor.KeyDescriptors, saml2.KeyDescriptor{
+			Use: "signing",
+			KeyInfo: saml2.KeyInfo{
+				X509Data: saml2.X509Data{
+					X509Certificates: []saml2.X509Certificate{
+						{Data: base64.StdEncoding.EncodeToString(c.certificate.Raw)},
+					},
+				},
+			},
+		})
+	}
+	return descriptor
+}
+
+// MetadataXML renders Metadata as an XML document.
+func (c *AssertionConsumer) MetadataXML() ([]byte, error) {
+	body, err := xml.MarshalIndent(c.Metadata(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"crypto/rsa"
 	"crypto/x509"
+	"database/sql"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"net/url"
 	"time"
@@ -85,3 +87,59 @@ func parseURL(u string) url.URL {
 	}
 	return *parser
 }
+
+// GetIdentityProvider loads an enabled SAML IdP configured for the tenant.
+func (s *Store) GetIdentityProvider(ctx context.Context, tenantID, name string) (*IdentityProvider, error) {
+	var idp IdentityProvider
+	err := s.db.GetContext(ctx, &idp, `
+		SELECT tenant_id, name, COALESCE(saml_entity_id, '') AS saml_entity_id,
+		       COALESCE(saml_sso_url, '') AS saml_sso_url, COALESCE(saml_certificate, '') AS saml_certificate
+		FROM sso_providers
+		WHERE tenant_id = $1 AND name = $2 AND type = 'saml' AND enabled = true`, tenantID, name)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrIdentityProviderNotFound
+		}
+		return nil, err
+	}
+	return &idp, nil
+}
+
+// SaveRequest records an outstanding AuthnRequest.
+func (s *Store) SaveRequest(ctx context.Context, record AuthnRequestRecord) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO saml_requests (id, tenant_id, provider, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		record.ID, record.TenantID, record.Provider, record.CreatedAt, record.ExpiresAt)
+	return err
+}
+
+// GetRequest fetches an AuthnRequest record by ID, returning nil if unknown.
+func (s *Store) GetRequest(ctx context.Context, id string) (*AuthnRequestRecord, error) {
+	var record AuthnRequestRecord
+	err := s.db.GetContext(ctx, &record, `
+		SELECT id, tenant_id, provider, created_at, expires_at, consumed_at
+		FROM saml_requests WHERE id = $1`, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &record, nil
+}
+
+// ConsumeRequest atomically marks an outstanding request as used.
+func (s *Store) ConsumeRequest(ctx context.Context, id string) (bool, error) {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE saml_requests SET consumed_at = NOW()
+		WHERE id = $1 AND consumed_at IS NULL AND expires_at > NOW()`, id)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
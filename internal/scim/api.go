@@ -1,10 +1,14 @@
 package scim
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 
+	"github.com/dhawalhost/wardseal/internal/directory"
+	"github.com/dhawalhost/wardseal/internal/scim/filter"
+	"github.com/dhawalhost/wardseal/pkg/apierr"
 	"github.com/dhawalhost/wardseal/pkg/middleware"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -41,6 +45,10 @@ func (h *HTTPHandler) RegisterRoutes(router *gin.Engine) {
 	group.PUT("/Groups/:id", h.replaceGroup)
 	group.PATCH("/Groups/:id", h.patchGroup)
 	group.DELETE("/Groups/:id", h.deleteGroup)
+
+	// Discovery endpoints
+	group.GET("/Schemas", h.listSchemas)
+	group.GET("/Schemas/:id", h.getSchema)
 }
 
 func scimContentType() gin.HandlerFunc {
@@ -57,11 +65,22 @@ func (h *HTTPHandler) listUsers(c *gin.Context) {
 		return
 	}
 
-	filter := c.Query("filter")
+	filterExpr := c.Query("filter")
 	startIndex, _ := strconv.Atoi(c.DefaultQuery("startIndex", "1"))
 	count, _ := strconv.Atoi(c.DefaultQuery("count", "100"))
 
-	resp, err := h.svc.ListUsers(c.Request.Context(), tenantID, filter, startIndex, count)
+	sort := SortParams{By: c.Query("sortBy"), Order: c.Query("sortOrder")}
+
+	resp, err := h.svc.ListUsers(c.Request.Context(), tenantID, filterExpr, sort, startIndex, count)
+	var syntaxErr *filter.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		h.respondError(c, http.StatusBadRequest, syntaxErr.Error(), "invalidFilter")
+		return
+	}
+	if errors.Is(err, ErrInvalidSort) {
+		h.respondError(c, http.StatusBadRequest, err.Error(), "invalidValue")
+		return
+	}
 	if err != nil {
 		h.logger.Error("Failed to list SCIM users", zap.Error(err))
 		h.respondError(c, http.StatusInternalServerError, "Internal server error", "")
@@ -95,12 +114,16 @@ func (h *HTTPHandler) createUser(c *gin.Context) {
 
 	var req User
 	if err := c.ShouldBindJSON(&req); err != nil {
-		h.respondError(c, http.StatusBadRequest, "Invalid syntax", "scimType invalidSyntax")
+		h.respondError(c, http.StatusBadRequest, apierr.Validation(err).Message, "invalidSyntax")
 		return
 	}
 
 	user, err := h.svc.CreateUser(c.Request.Context(), tenantID, req)
 	if err != nil {
+		if errors.Is(err, directory.ErrWeakPassword) {
+			h.respondError(c, http.StatusBadRequest, err.Error(), "invalidValue")
+			return
+		}
 		h.logger.Error("Failed to create SCIM user", zap.Error(err))
 		h.respondError(c, http.StatusInternalServerError, "Internal server error", "")
 		return
@@ -109,6 +132,40 @@ func (h *HTTPHandler) createUser(c *gin.Context) {
 	c.JSON(http.StatusCreated, user)
 }
 
+// maxPatchValueDepth bounds how deeply a PatchOp value may nest. SCIM values
+// are at most a multi-valued attribute of complex objects, so anything deeper
+// is rejected rather than walked.
+const maxPatchValueDepth = 8
+
+// tooDeep reports whether any operation's value nests objects or arrays
+// more than maxPatchValueDepth levels.
+func tooDeep(ops []PatchOperation) bool {
+	for _, op := range ops {
+		if valueDepth(op.Value, 0) > maxPatchValueDepth {
+			return true
+		}
+	}
+	return false
+}
+
+func valueDepth(value interface{}, depth int) int {
+	if depth > maxPatchValueDepth {
+		return depth
+	}
+	deepest := depth
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, child := range v {
+			deepest = max(deepest, valueDepth(child, depth+1))
+		}
+	case []interface{}:
+		for _, child := range v {
+			deepest = max(deepest, valueDepth(child, depth+1))
+		}
+	}
+	return deepest
+}
+
 func (h *HTTPHandler) respondError(c *gin.Context, status int, detail, scimType string) {
 	resp := Error{
 		Schemas:  []string{ErrorSchema},
@@ -129,7 +186,7 @@ func (h *HTTPHandler) replaceUser(c *gin.Context) {
 
 	var req User
 	if err := c.ShouldBindJSON(&req); err != nil {
-		h.respondError(c, http.StatusBadRequest, "Invalid syntax", "invalidSyntax")
+		h.respondError(c, http.StatusBadRequest, apierr.Validation(err).Message, "invalidSyntax")
 		return
 	}
 
@@ -152,7 +209,11 @@ func (h *HTTPHandler) patchUser(c *gin.Context) {
 
 	var req PatchRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		h.respondError(c, http.StatusBadRequest, "Invalid syntax", "invalidSyntax")
+		h.respondError(c, http.StatusBadRequest, apierr.Validation(err).Message, "invalidSyntax")
+		return
+	}
+	if tooDeep(req.Operations) {
+		h.respondError(c, http.StatusBadRequest, "PatchOp value is nested too deeply", "invalidValue")
 		return
 	}
 
@@ -193,7 +254,13 @@ func (h *HTTPHandler) listGroups(c *gin.Context) {
 	startIndex, _ := strconv.Atoi(c.DefaultQuery("startIndex", "1"))
 	count, _ := strconv.Atoi(c.DefaultQuery("count", "100"))
 
-	resp, err := h.svc.ListGroups(c.Request.Context(), tenantID, startIndex, count)
+	sort := SortParams{By: c.Query("sortBy"), Order: c.Query("sortOrder")}
+
+	resp, err := h.svc.ListGroups(c.Request.Context(), tenantID, sort, startIndex, count)
+	if errors.Is(err, ErrInvalidSort) {
+		h.respondError(c, http.StatusBadRequest, err.Error(), "invalidValue")
+		return
+	}
 	if err != nil {
 		h.logger.Error("Failed to list SCIM groups", zap.Error(err))
 		h.respondError(c, http.StatusInternalServerError, "Internal server error", "")
@@ -227,7 +294,7 @@ func (h *HTTPHandler) createGroup(c *gin.Context) {
 
 	var req Group
 	if err := c.ShouldBindJSON(&req); err != nil {
-		h.respondError(c, http.StatusBadRequest, "Invalid syntax", "invalidSyntax")
+		h.respondError(c, http.StatusBadRequest, apierr.Validation(err).Message, "invalidSyntax")
 		return
 	}
 
@@ -250,7 +317,7 @@ func (h *HTTPHandler) replaceGroup(c *gin.Context) {
 
 	var req Group
 	if err := c.ShouldBindJSON(&req); err != nil {
-		h.respondError(c, http.StatusBadRequest, "Invalid syntax", "invalidSyntax")
+		h.respondError(c, http.StatusBadRequest, apierr.Validation(err).Message, "invalidSyntax")
 		return
 	}
 
@@ -273,7 +340,11 @@ func (h *HTTPHandler) patchGroup(c *gin.Context) {
 
 	var req PatchRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		h.respondError(c, http.StatusBadRequest, "Invalid syntax", "invalidSyntax")
+		h.respondError(c, http.StatusBadRequest, apierr.Validation(err).Message, "invalidSyntax")
+		return
+	}
+	if tooDeep(req.Operations) {
+		h.respondError(c, http.StatusBadRequest, "PatchOp value is nested too deeply", "invalidValue")
 		return
 	}
 
@@ -301,3 +372,28 @@ func (h *HTTPHandler) deleteGroup(c *gin.Context) {
 	}
 	c.Status(http.StatusNoContent)
 }
+
+func (h *HTTPHandler) listSchemas(c *gin.Context) {
+	schemas := supportedSchemas()
+	resources := make([]interface{}, 0, len(schemas))
+	for _, schema := range schemas {
+		resources = append(resources, schema)
+	}
+	c.JSON(http.StatusOK, ListResponse{
+		Schemas:      []string{ListSchema},
+		TotalResults: len(resources),
+		StartIndex:   1,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+func (h *HTTPHandler) getSchema(c *gin.Context) {
+	for _, schema := range supportedSchemas() {
+		if schema.ID == c.Param("id") {
+			c.JSON(http.StatusOK, schema)
+			return
+		}
+	}
+	h.respondError(c, http.StatusNotFound, "Resource not found", "")
+}
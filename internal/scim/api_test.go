@@ -0,0 +1,496 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dhawalhost/wardseal/internal/directory"
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func TestPatchRejectsDeeplyNestedValue(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	// The service is never reached: the request is rejected while parsing.
+	NewHTTPHandler(nil, zap.NewNop()).RegisterRoutes(router)
+
+	value := strings.Repeat(`{"a":`, maxPatchValueDepth+2) + `"x"` + strings.Repeat(`}`, maxPatchValueDepth+2)
+	body := `{"schemas":["` + PatchSchema + `"],"Operations":[{"op":"replace","path":"name","value":` + value + `}]}`
+
+	for _, path := range []string{"/scim/v2/Users/u1", "/scim/v2/Groups/g1"} {
+		req := httptest.NewRequest(http.MethodPatch, path, strings.NewReader(body))
+		req.Header.Set(middleware.DefaultTenantHeader, "11111111-1111-1111-1111-111111111111")
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", path, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), "invalidValue") {
+			t.Errorf("%s: expected invalidValue scimType, got %s", path, w.Body.String())
+		}
+	}
+}
+
+func TestValueDepthAllowsComplexMultiValuedAttributes(t *testing.T) {
+	ops := []PatchOperation{{
+		Op: "add",
+		Value: map[string]interface{}{
+			"emails": []interface{}{
+				map[string]interface{}{"value": "a@example.com", "primary": true},
+			},
+		},
+	}}
+	if tooDeep(ops) {
+		t.Fatal("expected a multi-valued complex attribute to be accepted")
+	}
+}
+
+// fakeDirectory serves users for listing; other directory calls are not
+// expected.
+type fakeDirectory struct {
+	directory.Service
+	users       []directory.User
+	exportCalls int
+	lastSort    directory.Sort
+}
+
+func (d *fakeDirectory) ListUsers(ctx context.Context, tenantID string, limit, offset int, sort directory.Sort) ([]directory.User, int, error) {
+	d.lastSort = sort
+	users := append([]directory.User(nil), d.users...)
+	sortUsers(users, sort)
+	return users, len(users), nil
+}
+
+func (d *fakeDirectory) ListGroups(ctx context.Context, tenantID string, limit, offset int, sort directory.Sort) ([]directory.Group, int, error) {
+	d.lastSort = sort
+	return nil, 0, nil
+}
+
+func (d *fakeDirectory) GetUserByEmail(ctx context.Context, tenantID, email string) (directory.User, error) {
+	for _, u := range d.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return directory.User{}, directory.ErrUserNotFound
+}
+
+func (d *fakeDirectory) ExportUsers(ctx context.Context, tenantID, status string, fn func(directory.User) error) error {
+	d.exportCalls++
+	for _, u := range d.users {
+		if err := fn(u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestListUsersAppliesFilter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := &fakeDirectory{users: []directory.User{
+		{ID: "u1", Email: "alice@example.com", Status: "active"},
+		{ID: "u2", Email: "bob@example.com", Status: "inactive"},
+		{ID: "u3", Email: "carol@example.org", Status: "active"},
+	}}
+	router := newSCIMRouter(dir)
+
+	list := func(filter string) (int, ListResponse, string) {
+		return listResources(router, "/scim/v2/Users?filter="+url.QueryEscape(filter))
+	}
+
+	code, resp, body := list(`userName eq "bob@example.com"`)
+	if code != http.StatusOK || resp.TotalResults != 1 {
+		t.Fatalf("expected one user for userName eq, got %d %s", code, body)
+	}
+	if dir.exportCalls != 0 {
+		t.Fatal("expected an exact userName match to skip the tenant scan")
+	}
+
+	code, resp, body = list(`active eq true and emails.value ew "example.com"`)
+	if code != http.StatusOK || resp.TotalResults != 1 || !strings.Contains(body, `"id":"u1"`) {
+		t.Fatalf("expected only u1, got %d %s", code, body)
+	}
+
+	code, _, body = list(`userName eq "ALICE@example.com"`)
+	if code != http.StatusOK || !strings.Contains(body, `"id":"u1"`) {
+		t.Fatalf("expected userName eq to ignore case, got %d %s", code, body)
+	}
+
+	code, _, body = list(`userName eq`)
+	if code != http.StatusBadRequest || !strings.Contains(body, "invalidFilter") {
+		t.Fatalf("expected 400 invalidFilter, got %d %s", code, body)
+	}
+}
+
+func TestListUsersSorts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	dir := &fakeDirectory{users: []directory.User{
+		{ID: "u1", Email: "bob@example.com", Status: "active", CreatedAt: created},
+		{ID: "u2", Email: "alice@example.com", Status: "active", CreatedAt: created.Add(time.Hour)},
+		{ID: "u3", Email: "carol@example.com", Status: "inactive", CreatedAt: created.Add(2 * time.Hour)},
+	}}
+	router := newSCIMRouter(dir)
+
+	tests := []struct {
+		name     string
+		query    string
+		wantSort directory.Sort
+		wantIDs  string
+	}{
+		{"default", "", directory.Sort{}, "u1,u2,u3"},
+		{"ascending", "?sortBy=userName&sortOrder=ascending", directory.Sort{Field: directory.SortByLogin}, "u2,u1,u3"},
+		{"order defaults to ascending", "?sortBy=username", directory.Sort{Field: directory.SortByLogin}, "u2,u1,u3"},
+		{"descending", "?sortBy=meta.created&sortOrder=descending", directory.Sort{Field: directory.SortByCreated, Descending: true}, "u3,u2,u1"},
+		{"filtered descending", "?filter=" + url.QueryEscape(`active eq true`) + "&sortBy=userName&sortOrder=descending", directory.Sort{}, "u1,u2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir.lastSort = directory.Sort{}
+			code, resp, body := listResources(router, "/scim/v2/Users"+tt.query)
+			if code != http.StatusOK {
+				t.Fatalf("expected 200, got %d %s", code, body)
+			}
+			if dir.lastSort != tt.wantSort {
+				t.Fatalf("expected directory sort %+v, got %+v", tt.wantSort, dir.lastSort)
+			}
+			if got := resourceIDs(resp); got != tt.wantIDs {
+				t.Fatalf("expected order %s, got %s", tt.wantIDs, got)
+			}
+		})
+	}
+}
+
+func TestListGroupsSorts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := &fakeDirectory{}
+	router := newSCIMRouter(dir)
+
+	code, _, body := listResources(router, "/scim/v2/Groups?sortBy=displayName&sortOrder=descending")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", code, body)
+	}
+	if want := (directory.Sort{Field: directory.SortByName, Descending: true}); dir.lastSort != want {
+		t.Fatalf("expected directory sort %+v, got %+v", want, dir.lastSort)
+	}
+}
+
+func TestListRejectsUnknownSort(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := newSCIMRouter(&fakeDirectory{})
+
+	for _, path := range []string{
+		"/scim/v2/Users?sortBy=displayName",
+		"/scim/v2/Users?sortBy=password",
+		"/scim/v2/Users?sortBy=userName&sortOrder=sideways",
+		"/scim/v2/Groups?sortBy=userName",
+	} {
+		code, _, body := listResources(router, path)
+		if code != http.StatusBadRequest || !strings.Contains(body, "invalidValue") {
+			t.Errorf("%s: expected 400 invalidValue, got %d %s", path, code, body)
+		}
+	}
+}
+
+func newSCIMRouter(dir directory.Service) *gin.Engine {
+	router := gin.New()
+	NewHTTPHandler(NewService(dir, nil), zap.NewNop()).RegisterRoutes(router)
+	return router
+}
+
+func listResources(router *gin.Engine, path string) (int, ListResponse, string) {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set(middleware.DefaultTenantHeader, "11111111-1111-1111-1111-111111111111")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var resp ListResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	return w.Code, resp, w.Body.String()
+}
+
+// resourceIDs joins the IDs of the listed resources in order.
+func resourceIDs(resp ListResponse) string {
+	ids := make([]string, 0, len(resp.Resources))
+	for _, r := range resp.Resources {
+		if m, ok := r.(map[string]interface{}); ok {
+			id, _ := m["id"].(string)
+			ids = append(ids, id)
+		}
+	}
+	return strings.Join(ids, ",")
+}
+
+func (d *fakeDirectory) CreateUser(ctx context.Context, tenantID string, user directory.User) (string, error) {
+	user.ID = fmt.Sprintf("u%d", len(d.users)+1)
+	d.users = append(d.users, user)
+	return user.ID, nil
+}
+
+func (d *fakeDirectory) GetUserByID(ctx context.Context, tenantID, id string) (directory.User, error) {
+	for _, u := range d.users {
+		if u.ID == id {
+			return u, nil
+		}
+	}
+	return directory.User{}, directory.ErrUserNotFound
+}
+
+// UpdateUser merges the non-empty fields, as the directory does.
+func (d *fakeDirectory) UpdateUser(ctx context.Context, tenantID, id string, user directory.User) error {
+	for i, u := range d.users {
+		if u.ID != id {
+			continue
+		}
+		for dst, src := range map[*string]string{
+			&u.Email: user.Email, &u.Status: user.Status, &u.Phone: user.Phone, &u.Title: user.Title,
+			&u.Department: user.Department, &u.ManagerID: user.ManagerID, &u.Location: user.Location,
+		} {
+			if src != "" {
+				*dst = src
+			}
+		}
+		d.users[i] = u
+		return nil
+	}
+	return directory.ErrUserNotFound
+}
+
+// ReplaceProfile rejects managers that are not known users, as the directory
+// does.
+func (d *fakeDirectory) ReplaceProfile(ctx context.Context, tenantID, id string, profile directory.Profile) error {
+	if profile.ManagerID != "" {
+		if _, err := d.GetUserByID(ctx, tenantID, profile.ManagerID); err != nil || profile.ManagerID == id {
+			return directory.ErrInvalidManager
+		}
+	}
+	for i, u := range d.users {
+		if u.ID == id {
+			d.users[i].Profile = profile
+			return nil
+		}
+	}
+	return directory.ErrUserNotFound
+}
+
+// memExtensions is an in-memory ExtensionStore.
+type memExtensions map[string]EnterpriseUser
+
+func (m memExtensions) GetEnterprise(ctx context.Context, tenantID string, userIDs []string) (map[string]EnterpriseUser, error) {
+	out := map[string]EnterpriseUser{}
+	for _, id := range userIDs {
+		if ext, ok := m[tenantID+"/"+id]; ok {
+			out[id] = ext
+		}
+	}
+	return out, nil
+}
+
+func (m memExtensions) PutEnterprise(ctx context.Context, tenantID, userID string, ext *EnterpriseUser) error {
+	if ext == nil {
+		delete(m, tenantID+"/"+userID)
+		return nil
+	}
+	m[tenantID+"/"+userID] = *ext
+	return nil
+}
+
+func scimRequest(router *gin.Engine, method, path, body string) (int, string) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(middleware.DefaultTenantHeader, "11111111-1111-1111-1111-111111111111")
+	req.Header.Set("Content-Type", "application/scim+json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Code, w.Body.String()
+}
+
+func TestEnterpriseExtensionRoundTrips(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := &fakeDirectory{}
+	exts := memExtensions{}
+	router := gin.New()
+	NewHTTPHandler(NewService(dir, exts), zap.NewNop()).RegisterRoutes(router)
+
+	code, body := scimRequest(router, http.MethodPost, "/scim/v2/Users", `{
+		"schemas": ["`+UserSchema+`", "`+EnterpriseUserSchema+`"],
+		"userName": "ada@example.com",
+		"active": true,
+		"`+EnterpriseUserSchema+`": {
+			"employeeNumber": "701984",
+			"department": "Engineering",
+			"manager": {"value": "u9", "displayName": "Grace"}
+		}
+	}`)
+	if code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d %s", code, body)
+	}
+
+	readBack := func(path string) User {
+		t.Helper()
+		code, body := scimRequest(router, http.MethodGet, path, "")
+		if code != http.StatusOK {
+			t.Fatalf("GET %s: expected 200, got %d %s", path, code, body)
+		}
+		var u User
+		if err := json.Unmarshal([]byte(body), &u); err != nil {
+			t.Fatalf("decode %s: %v", body, err)
+		}
+		return u
+	}
+
+	got := readBack("/scim/v2/Users/u1")
+	if got.Enterprise == nil || got.Enterprise.EmployeeNumber != "701984" || got.Enterprise.Department != "Engineering" ||
+		got.Enterprise.Manager == nil || got.Enterprise.Manager.Value != "u9" {
+		t.Fatalf("expected the enterprise extension back, got %+v", got.Enterprise)
+	}
+	if len(got.Schemas) != 2 || got.Schemas[1] != EnterpriseUserSchema {
+		t.Fatalf("expected the extension schema to be listed, got %v", got.Schemas)
+	}
+
+	_, list, body := listResources(router, "/scim/v2/Users")
+	if list.TotalResults != 1 || !strings.Contains(body, `"employeeNumber":"701984"`) {
+		t.Fatalf("expected the listed user to carry the extension, got %s", body)
+	}
+
+	code, body = scimRequest(router, http.MethodPatch, "/scim/v2/Users/u1", `{
+		"schemas": ["`+PatchSchema+`"],
+		"Operations": [
+			{"op": "replace", "path": "`+EnterpriseUserSchema+`:department", "value": "Research"},
+			{"op": "remove", "path": "`+EnterpriseUserSchema+`:manager"}
+		]
+	}`)
+	if code != http.StatusOK {
+		t.Fatalf("PATCH: expected 200, got %d %s", code, body)
+	}
+	got = readBack("/scim/v2/Users/u1")
+	if got.Enterprise == nil || got.Enterprise.Department != "Research" || got.Enterprise.Manager != nil ||
+		got.Enterprise.EmployeeNumber != "701984" {
+		t.Fatalf("expected the patched extension, got %+v", got.Enterprise)
+	}
+
+	// PUT replaces the resource; leaving the extension out removes it.
+	code, body = scimRequest(router, http.MethodPut, "/scim/v2/Users/u1",
+		`{"schemas": ["`+UserSchema+`"], "userName": "ada@example.com", "active": true}`)
+	if code != http.StatusOK {
+		t.Fatalf("PUT: expected 200, got %d %s", code, body)
+	}
+	if got = readBack("/scim/v2/Users/u1"); got.Enterprise != nil || len(got.Schemas) != 1 {
+		t.Fatalf("expected the extension to be removed, got %+v %v", got.Enterprise, got.Schemas)
+	}
+}
+
+func TestProfileAttributesRoundTripThroughDirectory(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := &fakeDirectory{users: []directory.User{{ID: "mgr", Email: "grace@example.com", Status: "active"}}}
+	router := gin.New()
+	NewHTTPHandler(NewService(dir, memExtensions{}), zap.NewNop()).RegisterRoutes(router)
+
+	code, body := scimRequest(router, http.MethodPost, "/scim/v2/Users", `{
+		"schemas": ["`+UserSchema+`", "`+EnterpriseUserSchema+`"],
+		"userName": "ada@example.com",
+		"active": true,
+		"title": "Staff Engineer",
+		"phoneNumbers": [{"value": "+1 555 0100", "type": "mobile"}, {"value": "+1 555 0199", "type": "work", "primary": true}],
+		"addresses": [{"locality": "London", "type": "work"}],
+		"`+EnterpriseUserSchema+`": {"department": "Engineering", "manager": {"value": "mgr"}}
+	}`)
+	if code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d %s", code, body)
+	}
+	want := directory.Profile{Phone: "+1 555 0199", Title: "Staff Engineer", Department: "Engineering", ManagerID: "mgr", Location: "London"}
+	if got := dir.users[1].Profile; got != want {
+		t.Fatalf("expected directory profile %+v, got %+v", want, got)
+	}
+
+	// Users created outside SCIM report their profile too.
+	dir.users[0].Profile = directory.Profile{Title: "Director", Department: "Engineering"}
+	code, body = scimRequest(router, http.MethodGet, "/scim/v2/Users/mgr", "")
+	if code != http.StatusOK {
+		t.Fatalf("GET: expected 200, got %d %s", code, body)
+	}
+	var got User
+	if err := json.Unmarshal([]byte(body), &got); err != nil {
+		t.Fatalf("decode %s: %v", body, err)
+	}
+	if got.Title != "Director" || got.Enterprise == nil || got.Enterprise.Department != "Engineering" || len(got.Schemas) != 2 {
+		t.Fatalf("expected the profile in the SCIM user, got %s", body)
+	}
+	if code, body = scimRequest(router, http.MethodGet, "/scim/v2/Users/u2", ""); !strings.Contains(body, `"phoneNumbers":[{"value":"+1 555 0199"`) ||
+		!strings.Contains(body, `"locality":"London"`) {
+		t.Fatalf("expected phone and location back, got %d %s", code, body)
+	}
+
+	_, list, body := listResources(router, `/scim/v2/Users?filter=title%20eq%20%22Staff%20Engineer%22`)
+	if list.TotalResults != 1 || resourceIDs(list) != "u2" {
+		t.Fatalf("expected to filter on title, got %s", body)
+	}
+
+	// A manager not provisioned yet does not fail the create.
+	code, body = scimRequest(router, http.MethodPost, "/scim/v2/Users", `{
+		"userName": "alan@example.com",
+		"active": true,
+		"`+EnterpriseUserSchema+`": {"manager": {"value": "not-yet"}}
+	}`)
+	if code != http.StatusCreated || dir.users[2].ManagerID != "" {
+		t.Fatalf("expected the user without a manager, got %d %s %+v", code, body, dir.users[2].Profile)
+	}
+
+	code, body = scimRequest(router, http.MethodPatch, "/scim/v2/Users/u2", `{
+		"schemas": ["`+PatchSchema+`"],
+		"Operations": [
+			{"op": "replace", "path": "title", "value": "Principal Engineer"},
+			{"op": "replace", "path": "addresses", "value": [{"formatted": "1 Main St, Leeds"}]},
+			{"op": "remove", "path": "`+EnterpriseUserSchema+`:manager"}
+		]
+	}`)
+	if code != http.StatusOK {
+		t.Fatalf("PATCH: expected 200, got %d %s", code, body)
+	}
+	want = directory.Profile{Phone: "+1 555 0199", Title: "Principal Engineer", Department: "Engineering", Location: "1 Main St, Leeds"}
+	if got := dir.users[1].Profile; got != want {
+		t.Fatalf("expected patched profile %+v, got %+v", want, got)
+	}
+
+	// PUT replaces the resource, clearing attributes it leaves out.
+	code, body = scimRequest(router, http.MethodPut, "/scim/v2/Users/u2",
+		`{"schemas": ["`+UserSchema+`"], "userName": "ada@example.com", "active": true, "title": "Fellow"}`)
+	if code != http.StatusOK {
+		t.Fatalf("PUT: expected 200, got %d %s", code, body)
+	}
+	if got := dir.users[1].Profile; got != (directory.Profile{Title: "Fellow"}) {
+		t.Fatalf("expected only the title to remain, got %+v", got)
+	}
+}
+
+func TestSchemasAdvertiseEnterpriseExtension(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := newSCIMRouter(&fakeDirectory{})
+
+	code, resp, body := listResources(router, "/scim/v2/Schemas")
+	if code != http.StatusOK || resp.TotalResults != 3 {
+		t.Fatalf("expected three schemas, got %d %s", code, body)
+	}
+	if got := resourceIDs(resp); got != UserSchema+","+GroupSchema+","+EnterpriseUserSchema {
+		t.Fatalf("unexpected schemas %s", got)
+	}
+
+	code, body = scimRequest(router, http.MethodGet, "/scim/v2/Schemas/"+EnterpriseUserSchema, "")
+	var schema SchemaResource
+	_ = json.Unmarshal([]byte(body), &schema)
+	if code != http.StatusOK || schema.Name != "EnterpriseUser" || len(schema.Attributes) != 6 {
+		t.Fatalf("expected the enterprise schema, got %d %s", code, body)
+	}
+
+	if code, _ = scimRequest(router, http.MethodGet, "/scim/v2/Schemas/urn:example:unknown", ""); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown schema, got %d", code)
+	}
+}
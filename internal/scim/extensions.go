@@ -0,0 +1,90 @@
+package scim
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ErrUnknownUser is returned by ExtensionStore.PutEnterprise when the user
+// does not exist in the tenant.
+var ErrUnknownUser = errors.New("scim: user not found")
+
+// ExtensionStore persists schema extensions of users, which the directory
+// does not model.
+type ExtensionStore interface {
+	// GetEnterprise returns the enterprise extensions of the listed users,
+	// keyed by user ID; users without one are absent.
+	GetEnterprise(ctx context.Context, tenantID string, userIDs []string) (map[string]EnterpriseUser, error)
+	// PutEnterprise stores the user's enterprise extension, replacing any
+	// previous one; nil removes it.
+	PutEnterprise(ctx context.Context, tenantID, userID string, ext *EnterpriseUser) error
+}
+
+type sqlExtensionStore struct {
+	db *sqlx.DB
+}
+
+// NewExtensionStore returns an ExtensionStore that keeps each extension in
+// the identity's attributes profile, under its schema URN.
+func NewExtensionStore(db *sqlx.DB) ExtensionStore {
+	return &sqlExtensionStore{db: db}
+}
+
+func (s *sqlExtensionStore) GetEnterprise(ctx context.Context, tenantID string, userIDs []string) (map[string]EnterpriseUser, error) {
+	out := make(map[string]EnterpriseUser, len(userIDs))
+	if len(userIDs) == 0 {
+		return out, nil
+	}
+	var rows []struct {
+		ID  string `db:"id"`
+		Ext []byte `db:"ext"`
+	}
+	err := s.db.SelectContext(ctx, &rows,
+		`SELECT id, attributes -> $3 AS ext FROM identities
+		 WHERE tenant_id = $1 AND id = ANY($2::uuid[]) AND attributes ? $3`,
+		tenantID, pq.Array(userIDs), EnterpriseUserSchema)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		var ext EnterpriseUser
+		if err := json.Unmarshal(row.Ext, &ext); err != nil {
+			return nil, err
+		}
+		out[row.ID] = ext
+	}
+	return out, nil
+}
+
+func (s *sqlExtensionStore) PutEnterprise(ctx context.Context, tenantID, userID string, ext *EnterpriseUser) error {
+	var (
+		res sql.Result
+		err error
+	)
+	if ext == nil {
+		res, err = s.db.ExecContext(ctx,
+			`UPDATE identities SET attributes = attributes - $3 WHERE id = $1 AND tenant_id = $2`,
+			userID, tenantID, EnterpriseUserSchema)
+	} else {
+		var data []byte
+		if data, err = json.Marshal(ext); err != nil {
+			return err
+		}
+		res, err = s.db.ExecContext(ctx,
+			`UPDATE identities SET attributes = jsonb_set(COALESCE(attributes, '{}'::jsonb), ARRAY[$3::text], $4::jsonb)
+			 WHERE id = $1 AND tenant_id = $2`,
+			userID, tenantID, EnterpriseUserSchema, data)
+	}
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrUnknownUser
+	}
+	return nil
+}
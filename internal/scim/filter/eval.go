@@ -0,0 +1,212 @@
+package filter
+
+import (
+	"strings"
+)
+
+// Getter returns the values of the attribute at path, one per element for
+// multi-valued attributes and none when the attribute is absent. Elements of
+// multi-valued complex attributes are map[string]any so value filters can
+// match them.
+type Getter func(path string) []any
+
+// Match reports whether the resource behind get matches e. String
+// comparisons ignore case, as for SCIM attributes that are not caseExact; a
+// multi-valued attribute matches when any of its values does.
+func Match(e Expr, get Getter) bool {
+	switch e := e.(type) {
+	case *And:
+		return Match(e.Left, get) && Match(e.Right, get)
+	case *Or:
+		return Match(e.Left, get) || Match(e.Right, get)
+	case *Not:
+		return !Match(e.Expr, get)
+	case *ValuePath:
+		for _, v := range get(e.Path) {
+			element, ok := v.(map[string]any)
+			if !ok {
+				// Simple multi-valued attributes expose each element as "value".
+				element = map[string]any{"value": v}
+			}
+			if Match(e.Filter, Map(element)) {
+				return true
+			}
+		}
+		return false
+	case *Compare:
+		return compare(e, get(e.Path))
+	}
+	return false
+}
+
+func compare(e *Compare, values []any) bool {
+	switch e.Op {
+	case Pr:
+		for _, v := range values {
+			if present(v) {
+				return true
+			}
+		}
+		return false
+	case Eq:
+		return equalsAny(values, e.Value)
+	case Ne:
+		return !equalsAny(values, e.Value)
+	}
+	for _, v := range values {
+		if test(e.Op, normalize(v), e.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+func present(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case string:
+		return v != ""
+	case []any:
+		return len(v) > 0
+	case map[string]any:
+		return len(v) > 0
+	}
+	return true
+}
+
+// equalsAny reports whether any value equals want; "eq null" matches an
+// attribute with no values.
+func equalsAny(values []any, want any) bool {
+	if want == nil {
+		for _, v := range values {
+			if present(v) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, v := range values {
+		switch v := normalize(v).(type) {
+		case string:
+			if w, ok := want.(string); ok && strings.EqualFold(v, w) {
+				return true
+			}
+		case float64, bool:
+			if v == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// test applies a substring or ordering operator to one value.
+func test(op Operator, v, want any) bool {
+	if s, ok := v.(string); ok {
+		w, ok := want.(string)
+		if !ok {
+			return false
+		}
+		s, w = strings.ToLower(s), strings.ToLower(w)
+		switch op {
+		case Co:
+			return strings.Contains(s, w)
+		case Sw:
+			return strings.HasPrefix(s, w)
+		case Ew:
+			return strings.HasSuffix(s, w)
+		}
+		return ordered(op, strings.Compare(s, w))
+	}
+	n, ok := v.(float64)
+	w, wok := want.(float64)
+	if !ok || !wok {
+		return false
+	}
+	switch {
+	case n < w:
+		return ordered(op, -1)
+	case n > w:
+		return ordered(op, 1)
+	}
+	return ordered(op, 0)
+}
+
+func ordered(op Operator, cmp int) bool {
+	switch op {
+	case Gt:
+		return cmp > 0
+	case Ge:
+		return cmp >= 0
+	case Lt:
+		return cmp < 0
+	case Le:
+		return cmp <= 0
+	}
+	return false
+}
+
+// normalize turns Go integer types into float64 so values from structs
+// compare with parsed numbers.
+func normalize(v any) any {
+	switch v := v.(type) {
+	case int:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	}
+	return v
+}
+
+// Map returns a Getter over a decoded JSON document, such as a resource
+// unmarshalled into map[string]any. Attribute names match case
+// insensitively and arrays are flattened, so "emails.value" yields every
+// email. A path qualified with a schema URN is looked up under that schema's
+// extension object, falling back to the top level for the core schema.
+func Map(doc map[string]any) Getter {
+	return func(path string) []any {
+		root := doc
+		if i := strings.LastIndexByte(path, ':'); i >= 0 {
+			if ext, ok := lookup(doc, path[:i]).(map[string]any); ok {
+				root = ext
+			}
+			path = path[i+1:]
+		}
+		values := []any{root}
+		for _, name := range strings.Split(path, ".") {
+			var next []any
+			for _, v := range values {
+				m, ok := v.(map[string]any)
+				if !ok {
+					continue
+				}
+				switch child := lookup(m, name).(type) {
+				case nil:
+				case []any:
+					next = append(next, child...)
+				default:
+					next = append(next, child)
+				}
+			}
+			values = next
+		}
+		return values
+	}
+}
+
+func lookup(m map[string]any, name string) any {
+	if v, ok := m[name]; ok {
+		return v
+	}
+	for k, v := range m {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package filter
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const testUser = `{
+	"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+	"id": "2819c223",
+	"userName": "bjensen@example.com",
+	"name": {"givenName": "Barbara", "familyName": "Jensen"},
+	"title": "",
+	"active": true,
+	"loginCount": 7,
+	"emails": [
+		{"value": "bjensen@example.com", "type": "work", "primary": true},
+		{"value": "babs@jensen.org", "type": "home"}
+	],
+	"nicknames": ["Babs", "BJ"],
+	"meta": {"lastModified": "2024-05-13T04:42:34Z"},
+	"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": {"employeeNumber": "701984"}
+}`
+
+func TestMatch(t *testing.T) {
+	var doc map[string]any
+	if err := json.Unmarshal([]byte(testUser), &doc); err != nil {
+		t.Fatal(err)
+	}
+	get := Map(doc)
+
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{`userName eq "BJensen@example.com"`, true},
+		{`username eq "bjensen@example.com"`, true},
+		{`userName eq "other@example.com"`, false},
+		{`userName ne "other@example.com"`, true},
+		{`userName sw "bjen"`, true},
+		{`userName ew "@EXAMPLE.COM"`, true},
+		{`name.familyName co "ens"`, true},
+		{`emails.value eq "babs@jensen.org"`, true},
+		{`emails co "jensen.org"`, false},
+		{`emails[type eq "work" and value co "jensen.org"]`, false},
+		{`emails[type eq "home" and value co "jensen.org"]`, true},
+		{`nicknames[value eq "bj"]`, true},
+		{`title pr`, false},
+		{`name pr`, true},
+		{`phoneNumbers pr`, false},
+		{`phoneNumbers eq null`, true},
+		{`active eq true`, true},
+		{`active eq "true"`, false},
+		{`loginCount gt 5`, true},
+		{`loginCount le 6`, false},
+		{`meta.lastModified gt "2024-01-01T00:00:00Z"`, true},
+		{`meta.lastModified lt "2024-01-01T00:00:00Z"`, false},
+		{`urn:ietf:params:scim:schemas:core:2.0:User:userName sw "b"`, true},
+		{`urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber eq "701984"`, true},
+		{`active eq true and not (emails.value ew "example.com")`, false},
+		{`userName sw "x" or loginCount ge 7`, true},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.filter)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.filter, err)
+		}
+		if got := Match(e, get); got != tt.want {
+			t.Errorf("Match(%q) = %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+}
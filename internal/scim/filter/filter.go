@@ -0,0 +1,136 @@
+// Package filter parses and evaluates SCIM filter expressions (RFC 7644
+// section 3.4.2.2), such as
+//
+//	userName sw "j" and (emails.value co "@example.com" or not (active eq true))
+//
+// Parse returns an AST which Match evaluates against any resource through a
+// Getter resolving attribute paths.
+package filter
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// Operator is an attribute comparison operator.
+type Operator string
+
+const (
+	Eq Operator = "eq"
+	Ne Operator = "ne"
+	Co Operator = "co"
+	Sw Operator = "sw"
+	Ew Operator = "ew"
+	Pr Operator = "pr"
+	Gt Operator = "gt"
+	Ge Operator = "ge"
+	Lt Operator = "lt"
+	Le Operator = "le"
+)
+
+var operators = map[string]Operator{
+	"eq": Eq, "ne": Ne, "co": Co, "sw": Sw, "ew": Ew,
+	"pr": Pr, "gt": Gt, "ge": Ge, "lt": Lt, "le": Le,
+}
+
+// Expr is a node of a parsed filter: *Compare, *And, *Or, *Not or *ValuePath.
+type Expr interface {
+	String() string
+	expr()
+}
+
+// Compare tests an attribute against a value. Value is a string, float64,
+// bool or nil (for null); it is unused by Pr.
+type Compare struct {
+	Path  string
+	Op    Operator
+	Value any
+}
+
+// And matches when both operands match.
+type And struct {
+	Left, Right Expr
+}
+
+// Or matches when either operand matches.
+type Or struct {
+	Left, Right Expr
+}
+
+// Not matches when its operand does not.
+type Not struct {
+	Expr Expr
+}
+
+// ValuePath matches when any element of the multi-valued complex attribute
+// Path matches Filter, as in emails[type eq "work" and value co "@example.com"].
+type ValuePath struct {
+	Path   string
+	Filter Expr
+}
+
+func (*Compare) expr()   {}
+func (*And) expr()       {}
+func (*Or) expr()        {}
+func (*Not) expr()       {}
+func (*ValuePath) expr() {}
+
+func (e *Compare) String() string {
+	if e.Op == Pr {
+		return e.Path + " pr"
+	}
+	return e.Path + " " + string(e.Op) + " " + formatValue(e.Value)
+}
+
+func (e *And) String() string {
+	return "(" + e.Left.String() + " and " + e.Right.String() + ")"
+}
+
+func (e *Or) String() string {
+	return "(" + e.Left.String() + " or " + e.Right.String() + ")"
+}
+
+func (e *Not) String() string {
+	return "not (" + e.Expr.String() + ")"
+}
+
+func (e *ValuePath) String() string {
+	return e.Path + "[" + e.Filter.String() + "]"
+}
+
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+	return "?"
+}
+
+// SyntaxError reports a malformed filter and the byte offset of the problem.
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return "invalid filter at position " + strconv.Itoa(e.Pos) + ": " + e.Msg
+}
+
+// Equals reports whether e is a plain equality test on path (compared case
+// insensitively, as SCIM attribute names are) and returns its value. Callers
+// use it to serve common filters from an index instead of a scan.
+func Equals(e Expr, path string) (any, bool) {
+	cmp, ok := e.(*Compare)
+	if !ok || cmp.Op != Eq || !strings.EqualFold(cmp.Path, path) {
+		return nil, false
+	}
+	return cmp.Value, true
+}
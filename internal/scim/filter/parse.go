@@ -0,0 +1,349 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maxDepth bounds how deeply groups may nest, so hostile filters cannot
+// exhaust the stack.
+const maxDepth = 32
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokString
+	tokNumber
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+)
+
+type token struct {
+	kind tokenKind
+	pos  int
+	text string
+	// value holds the decoded literal of string and number tokens.
+	value any
+}
+
+func (t token) describe() string {
+	if t.kind == tokEOF {
+		return "end of filter"
+	}
+	return strconv.Quote(t.text)
+}
+
+// lex splits a filter into tokens. Keywords are not told apart from
+// attribute names here; the parser does that by position.
+func lex(s string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')' || c == '[' || c == ']':
+			toks = append(toks, token{kind: bracketKind(c), pos: i, text: string(c)})
+			i++
+		case c == '"':
+			end := i + 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, &SyntaxError{Pos: i, Msg: "unterminated string"}
+			}
+			var value string
+			if err := json.Unmarshal([]byte(s[i:end+1]), &value); err != nil {
+				return nil, &SyntaxError{Pos: i, Msg: "invalid string literal"}
+			}
+			toks = append(toks, token{kind: tokString, pos: i, text: s[i : end+1], value: value})
+			i = end + 1
+		case c == '-' || (c >= '0' && c <= '9'):
+			end := i + 1
+			for end < len(s) && strings.IndexByte("0123456789.eE+-", s[end]) >= 0 {
+				end++
+			}
+			n, err := strconv.ParseFloat(s[i:end], 64)
+			if err != nil {
+				return nil, &SyntaxError{Pos: i, Msg: fmt.Sprintf("invalid number %q", s[i:end])}
+			}
+			toks = append(toks, token{kind: tokNumber, pos: i, text: s[i:end], value: n})
+			i = end
+		case isNameStart(rune(c)):
+			end := i + 1
+			for end < len(s) && isWordChar(rune(s[end])) {
+				end++
+			}
+			toks = append(toks, token{kind: tokWord, pos: i, text: s[i:end]})
+			i = end
+		default:
+			return nil, &SyntaxError{Pos: i, Msg: fmt.Sprintf("unexpected character %q", c)}
+		}
+	}
+	return append(toks, token{kind: tokEOF, pos: len(s)}), nil
+}
+
+func bracketKind(c byte) tokenKind {
+	switch c {
+	case '(':
+		return tokLParen
+	case ')':
+		return tokRParen
+	case '[':
+		return tokLBracket
+	}
+	return tokRBracket
+}
+
+func isNameStart(r rune) bool {
+	return r < unicode.MaxASCII && unicode.IsLetter(r)
+}
+
+func isNameChar(r rune) bool {
+	return isNameStart(r) || (r >= '0' && r <= '9') || r == '-' || r == '_'
+}
+
+// isWordChar also admits the separators of schema URNs and sub-attributes.
+func isWordChar(r rune) bool {
+	return isNameChar(r) || r == ':' || r == '.'
+}
+
+// Parse parses a SCIM filter. Operators and keywords are case insensitive;
+// "not" binds tighter than "and", which binds tighter than "or".
+func Parse(s string) (Expr, error) {
+	toks, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.unexpected(t)
+	}
+	return e, nil
+}
+
+type parser struct {
+	toks []token
+	next int
+	// depth counts the open groups, inValuePath whether one is a value filter.
+	depth       int
+	inValuePath bool
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.next]
+}
+
+func (p *parser) take() token {
+	t := p.toks[p.next]
+	if t.kind != tokEOF {
+		p.next++
+	}
+	return t
+}
+
+func (p *parser) unexpected(t token) error {
+	return &SyntaxError{Pos: t.pos, Msg: "unexpected " + t.describe()}
+}
+
+// keyword reports whether the next token is the keyword kw, and consumes it
+// when it is.
+func (p *parser) keyword(kw string) bool {
+	t := p.peek()
+	if t.kind == tokWord && strings.EqualFold(t.text, kw) {
+		p.next++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(kind tokenKind, what string) error {
+	t := p.take()
+	if t.kind != kind {
+		return &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("expected %s, found %s", what, t.describe())}
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &Or{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &And{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokLParen:
+		p.next++
+		return p.parseGroup(tokRParen, "\")\"")
+	case t.kind == tokWord && strings.EqualFold(t.text, "not"):
+		p.next++
+		if err := p.expect(tokLParen, "\"(\" after not"); err != nil {
+			return nil, err
+		}
+		e, err := p.parseGroup(tokRParen, "\")\"")
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Expr: e}, nil
+	case t.kind == tokWord:
+		return p.parseAttrExpr()
+	}
+	return nil, p.unexpected(t)
+}
+
+// parseGroup parses the filter after an opening bracket up to its close.
+func (p *parser) parseGroup(close tokenKind, what string) (Expr, error) {
+	if p.depth++; p.depth > maxDepth {
+		return nil, &SyntaxError{Pos: p.peek().pos, Msg: "filter nests too deeply"}
+	}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(close, what); err != nil {
+		return nil, err
+	}
+	p.depth--
+	return e, nil
+}
+
+func (p *parser) parseAttrExpr() (Expr, error) {
+	t := p.take()
+	if err := validatePath(t); err != nil {
+		return nil, err
+	}
+	if p.peek().kind == tokLBracket {
+		if p.inValuePath {
+			return nil, &SyntaxError{Pos: p.peek().pos, Msg: "value filters cannot nest"}
+		}
+		p.next++
+		p.inValuePath = true
+		sub, err := p.parseGroup(tokRBracket, "\"]\"")
+		p.inValuePath = false
+		if err != nil {
+			return nil, err
+		}
+		return &ValuePath{Path: t.text, Filter: sub}, nil
+	}
+
+	opTok := p.take()
+	op, ok := operators[strings.ToLower(opTok.text)]
+	if opTok.kind != tokWord || !ok {
+		return nil, &SyntaxError{Pos: opTok.pos, Msg: fmt.Sprintf("expected an operator after %s, found %s", t.text, opTok.describe())}
+	}
+	if op == Pr {
+		return &Compare{Path: t.text, Op: Pr}, nil
+	}
+
+	valTok := p.take()
+	var value any
+	switch {
+	case valTok.kind == tokString || valTok.kind == tokNumber:
+		value = valTok.value
+	case valTok.kind == tokWord && strings.EqualFold(valTok.text, "true"):
+		value = true
+	case valTok.kind == tokWord && strings.EqualFold(valTok.text, "false"):
+		value = false
+	case valTok.kind == tokWord && strings.EqualFold(valTok.text, "null"):
+		value = nil
+	default:
+		return nil, &SyntaxError{Pos: valTok.pos, Msg: fmt.Sprintf("expected a value after %s, found %s", op, valTok.describe())}
+	}
+	if err := checkOperand(op, value); err != nil {
+		return nil, &SyntaxError{Pos: valTok.pos, Msg: err.Error()}
+	}
+	return &Compare{Path: t.text, Op: op, Value: value}, nil
+}
+
+// checkOperand rejects values an operator cannot compare against.
+func checkOperand(op Operator, value any) error {
+	switch op {
+	case Co, Sw, Ew:
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("%s needs a string value", op)
+		}
+	case Gt, Ge, Lt, Le:
+		switch value.(type) {
+		case string, float64:
+		default:
+			return fmt.Errorf("%s needs a string or number value", op)
+		}
+	}
+	return nil
+}
+
+// validatePath checks an attribute path: an attribute name, optionally
+// followed by ".subAttribute" and preceded by a "urn:...:" schema.
+func validatePath(t token) error {
+	invalid := &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("invalid attribute path %q", t.text)}
+	if _, isOp := operators[strings.ToLower(t.text)]; isOp {
+		return invalid
+	}
+	switch strings.ToLower(t.text) {
+	case "and", "or", "not", "true", "false", "null":
+		return invalid
+	}
+	path := t.text
+	if i := strings.LastIndexByte(path, ':'); i >= 0 {
+		if !strings.HasPrefix(strings.ToLower(path), "urn:") {
+			return invalid
+		}
+		path = path[i+1:]
+	}
+	names := strings.Split(path, ".")
+	if len(names) > 2 {
+		return invalid
+	}
+	for _, name := range names {
+		if name == "" || !isNameStart(rune(name[0])) {
+			return invalid
+		}
+		for _, r := range name {
+			if !isNameChar(r) {
+				return invalid
+			}
+		}
+	}
+	return nil
+}
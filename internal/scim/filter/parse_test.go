@@ -0,0 +1,116 @@
+package filter
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter string
+		want   string
+	}{
+		{"eq string", `userName eq "bjensen"`, `userName eq "bjensen"`},
+		{"ne", `userName ne "bjensen"`, `userName ne "bjensen"`},
+		{"co", `name.familyName co "O'Malley"`, `name.familyName co "O'Malley"`},
+		{"sw", `userName sw "J"`, `userName sw "J"`},
+		{"ew", `emails.value ew "@example.com"`, `emails.value ew "@example.com"`},
+		{"pr", `title pr`, `title pr`},
+		{"gt date", `meta.lastModified gt "2011-05-13T04:42:34Z"`, `meta.lastModified gt "2011-05-13T04:42:34Z"`},
+		{"ge", `meta.lastModified ge "2011-05-13T04:42:34Z"`, `meta.lastModified ge "2011-05-13T04:42:34Z"`},
+		{"lt", `meta.lastModified lt "2011-05-13T04:42:34Z"`, `meta.lastModified lt "2011-05-13T04:42:34Z"`},
+		{"le number", `loginCount le 10`, `loginCount le 10`},
+		{"negative decimal", `score gt -1.5`, `score gt -1.5`},
+		{"exponent", `score lt 2e3`, `score lt 2000`},
+		{"bool", `active eq true`, `active eq true`},
+		{"false", `active eq FALSE`, `active eq false`},
+		{"null", `title eq null`, `title eq null`},
+		{"escaped string", `displayName eq "say \"hi\"\n"`, `displayName eq "say \"hi\"\n"`},
+		{"case insensitive operators", `userName EQ "a" AND title Pr`, `(userName eq "a" and title pr)`},
+		{"schema urn", `urn:ietf:params:scim:schemas:core:2.0:User:userName sw "J"`, `urn:ietf:params:scim:schemas:core:2.0:User:userName sw "J"`},
+		{"extension urn", `urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber eq "701984"`, `urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber eq "701984"`},
+		{"and", `title pr and userType eq "Employee"`, `(title pr and userType eq "Employee")`},
+		{"or", `title pr or userType eq "Intern"`, `(title pr or userType eq "Intern")`},
+		{"and binds tighter than or", `a pr or b pr and c pr`, `(a pr or (b pr and c pr))`},
+		{"left associative", `a pr and b pr and c pr`, `((a pr and b pr) and c pr)`},
+		{"grouping", `userType eq "Employee" and (emails co "example.com" or emails.value co "example.org")`, `(userType eq "Employee" and (emails co "example.com" or emails.value co "example.org"))`},
+		{"redundant parens", `((title pr))`, `title pr`},
+		{"not", `userType ne "Employee" and not (emails co "example.com" or emails.value co "example.org")`, `(userType ne "Employee" and not ((emails co "example.com" or emails.value co "example.org")))`},
+		{"not binds tighter than and", `not (a pr) and b pr`, `(not (a pr) and b pr)`},
+		{"value path", `emails[type eq "work" and value co "@example.com"]`, `emails[(type eq "work" and value co "@example.com")]`},
+		{"value path in expression", `userType eq "Employee" and emails[type eq "work" and value co "@example.com"]`, `(userType eq "Employee" and emails[(type eq "work" and value co "@example.com")])`},
+		{"value paths or", `emails[type eq "work" and value co "@example.com"] or ims[type eq "xmpp" and value co "@foo.com"]`, `(emails[(type eq "work" and value co "@example.com")] or ims[(type eq "xmpp" and value co "@foo.com")])`},
+		{"whitespace", "  title\tpr\n", `title pr`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.filter)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.filter, err)
+			}
+			if got.String() != tt.want {
+				t.Fatalf("Parse(%q) = %s, want %s", tt.filter, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRejectsInvalidFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  string
+		wantPos int
+		wantMsg string
+	}{
+		{"empty", ``, 0, "unexpected end of filter"},
+		{"missing operator", `userName`, 8, "expected an operator"},
+		{"unknown operator", `userName is "x"`, 9, "expected an operator"},
+		{"missing value", `userName eq`, 11, "expected a value"},
+		{"bare word value", `userName eq bjensen`, 12, "expected a value"},
+		{"unterminated string", `userName eq "bjensen`, 12, "unterminated string"},
+		{"bad escape", `userName eq "\q"`, 12, "invalid string literal"},
+		{"bad number", `count gt 1.2.3`, 9, "invalid number"},
+		{"dangling and", `title pr and`, 12, "unexpected end of filter"},
+		{"unclosed group", `(title pr`, 9, `expected ")"`},
+		{"unopened group", `title pr)`, 8, `unexpected ")"`},
+		{"not without parens", `not title pr`, 4, `expected "(" after not`},
+		{"unclosed value path", `emails[type eq "work"`, 21, `expected "]"`},
+		{"nested value path", `emails[type[value pr]]`, 11, "value filters cannot nest"},
+		{"keyword as attribute", `and eq "x"`, 0, "invalid attribute path"},
+		{"too many sub-attributes", `name.given.first pr`, 0, "invalid attribute path"},
+		{"non urn schema", `core:userName pr`, 0, "invalid attribute path"},
+		{"co needs string", `count co 1`, 9, "co needs a string value"},
+		{"gt needs ordered value", `active gt true`, 10, "gt needs a string or number value"},
+		{"unexpected character", `userName eq "a" & title pr`, 16, "unexpected character"},
+		{"trailing tokens", `title pr title pr`, 9, `unexpected "title"`},
+		{"too deep", strings.Repeat("(", maxDepth+1) + "a pr" + strings.Repeat(")", maxDepth+1), maxDepth + 1, "nests too deeply"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.filter)
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("Parse(%q) error = %v, want a *SyntaxError", tt.filter, err)
+			}
+			if syntaxErr.Pos != tt.wantPos || !strings.Contains(syntaxErr.Msg, tt.wantMsg) {
+				t.Fatalf("Parse(%q) error = %v, want %q at %d", tt.filter, err, tt.wantMsg, tt.wantPos)
+			}
+		})
+	}
+}
+
+func TestEquals(t *testing.T) {
+	e, err := Parse(`UserName eq "bjensen@example.com"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := Equals(e, "userName"); !ok || v != "bjensen@example.com" {
+		t.Fatalf("Equals = %v, %v", v, ok)
+	}
+	e, _ = Parse(`userName sw "b"`)
+	if _, ok := Equals(e, "userName"); ok {
+		t.Fatal("expected sw not to count as an equality test")
+	}
+}
@@ -0,0 +1,88 @@
+package scim
+
+import "fmt"
+
+// SchemaResource describes a resource schema for discovery through
+// /Schemas (RFC 7643 section 7).
+type SchemaResource struct {
+	Schemas     []string          `json:"schemas"`
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Attributes  []SchemaAttribute `json:"attributes"`
+	Meta        Meta              `json:"meta"`
+}
+
+// SchemaAttribute describes one attribute of a schema.
+type SchemaAttribute struct {
+	Name          string            `json:"name"`
+	Type          string            `json:"type"`
+	MultiValued   bool              `json:"multiValued"`
+	Required      bool              `json:"required"`
+	CaseExact     bool              `json:"caseExact"`
+	Mutability    string            `json:"mutability"`
+	Returned      string            `json:"returned"`
+	Uniqueness    string            `json:"uniqueness"`
+	SubAttributes []SchemaAttribute `json:"subAttributes,omitempty"`
+}
+
+// stringAttr is a single-valued, optional, read-write string attribute.
+func stringAttr(name string) SchemaAttribute {
+	return SchemaAttribute{Name: name, Type: "string", Mutability: "readWrite", Returned: "default", Uniqueness: "none"}
+}
+
+func schemaResource(id, name, description string, attrs ...SchemaAttribute) SchemaResource {
+	return SchemaResource{
+		Schemas:     []string{SchemaSchema},
+		ID:          id,
+		Name:        name,
+		Description: description,
+		Attributes:  attrs,
+		Meta: Meta{
+			ResourceType: "Schema",
+			Location:     fmt.Sprintf("/scim/v2/Schemas/%s", id),
+		},
+	}
+}
+
+// supportedSchemas lists the schemas the service accepts and returns, with
+// the attributes it persists.
+func supportedSchemas() []SchemaResource {
+	userName := stringAttr("userName")
+	userName.Required, userName.Uniqueness = true, "server"
+	name := SchemaAttribute{Name: "name", Type: "complex", Mutability: "readWrite", Returned: "default", Uniqueness: "none",
+		SubAttributes: []SchemaAttribute{stringAttr("givenName"), stringAttr("familyName")}}
+	primary := SchemaAttribute{Name: "primary", Type: "boolean", Mutability: "readWrite", Returned: "default", Uniqueness: "none"}
+	emails := SchemaAttribute{Name: "emails", Type: "complex", MultiValued: true, Mutability: "readWrite", Returned: "default", Uniqueness: "none",
+		SubAttributes: []SchemaAttribute{stringAttr("value"), stringAttr("type"), primary}}
+	phoneNumbers := SchemaAttribute{Name: "phoneNumbers", Type: "complex", MultiValued: true, Mutability: "readWrite", Returned: "default", Uniqueness: "none",
+		SubAttributes: []SchemaAttribute{stringAttr("value"), stringAttr("type"), primary}}
+	addresses := SchemaAttribute{Name: "addresses", Type: "complex", MultiValued: true, Mutability: "readWrite", Returned: "default", Uniqueness: "none",
+		SubAttributes: []SchemaAttribute{stringAttr("formatted"), stringAttr("locality"), stringAttr("type"), primary}}
+	active := SchemaAttribute{Name: "active", Type: "boolean", Mutability: "readWrite", Returned: "default", Uniqueness: "none"}
+	password := stringAttr("password")
+	password.Mutability, password.Returned = "writeOnly", "never"
+
+	displayName := stringAttr("displayName")
+	displayName.Required = true
+	memberValue := stringAttr("value")
+	memberValue.Mutability = "immutable"
+	members := SchemaAttribute{Name: "members", Type: "complex", MultiValued: true, Mutability: "readWrite", Returned: "default", Uniqueness: "none",
+		SubAttributes: []SchemaAttribute{memberValue, stringAttr("display"), stringAttr("type")}}
+
+	managerRef := SchemaAttribute{Name: "$ref", Type: "reference", Mutability: "readWrite", Returned: "default", Uniqueness: "none"}
+	managerName := stringAttr("displayName")
+	managerName.Mutability = "readOnly"
+	manager := SchemaAttribute{Name: "manager", Type: "complex", Mutability: "readWrite", Returned: "default", Uniqueness: "none",
+		SubAttributes: []SchemaAttribute{stringAttr("value"), managerRef, managerName}}
+
+	return []SchemaResource{
+		schemaResource(UserSchema, "User", "User Account",
+			userName, name, stringAttr("title"), emails, phoneNumbers, addresses, active, password),
+		schemaResource(GroupSchema, "Group", "Group",
+			displayName, members),
+		schemaResource(EnterpriseUserSchema, "EnterpriseUser", "Enterprise User",
+			stringAttr("employeeNumber"), stringAttr("costCenter"), stringAttr("organization"),
+			stringAttr("division"), stringAttr("department"), manager),
+	}
+}
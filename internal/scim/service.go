@@ -2,22 +2,30 @@ package scim
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/dhawalhost/wardseal/internal/directory"
+	"github.com/dhawalhost/wardseal/internal/scim/filter"
 )
 
 // Service defines the business logic for SCIM operations.
 type Service struct {
-	dirSvc directory.Service
+	dirSvc     directory.Service
+	extensions ExtensionStore
 }
 
-// NewService creates a new SCIM service.
-func NewService(dirSvc directory.Service) *Service {
+// NewService creates a new SCIM service. extensions persists the enterprise
+// user extension; when nil the extension is accepted but not stored.
+func NewService(dirSvc directory.Service, extensions ExtensionStore) *Service {
 	return &Service{
-		dirSvc: dirSvc,
+		dirSvc:     dirSvc,
+		extensions: extensions,
 	}
 }
 
@@ -37,21 +45,43 @@ func (s *Service) CreateUser(ctx context.Context, tenantID string, req User) (Us
 		}
 	}
 
+	password := req.Password
+	if password == "" {
+		generated, err := generatePassword()
+		if err != nil {
+			return User{}, err
+		}
+		password = generated
+	}
 	dirUser := directory.User{
 		Email:    email,
 		Status:   "active",
-		Password: "ChangeMe123!", // Dummy password for now, or generated
+		Password: password,
+		Profile:  profileOf(req),
 	}
 	if !req.Active {
 		dirUser.Status = "inactive"
 	}
+	// The manager is set once the user exists, so that one not provisioned
+	// yet does not fail the create.
+	dirUser.ManagerID = ""
 
 	id, err := s.dirSvc.CreateUser(ctx, tenantID, dirUser)
 	if err != nil {
 		return User{}, fmt.Errorf("failed to create user: %w", err)
 	}
+	if profile := profileOf(req); profile.ManagerID != "" {
+		if err := s.replaceProfile(ctx, tenantID, id, profile); err != nil {
+			return User{}, fmt.Errorf("failed to store profile: %w", err)
+		}
+	}
+	if err := s.putEnterprise(ctx, tenantID, id, req.Enterprise); err != nil {
+		return User{}, fmt.Errorf("failed to store enterprise extension: %w", err)
+	}
 
 	req.ID = id
+	req.Password = ""
+	req.Schemas = userSchemas(req)
 	req.Meta = Meta{
 		ResourceType: "User",
 		Created:      time.Now().Format(time.RFC3339),
@@ -68,25 +98,140 @@ func (s *Service) GetUser(ctx context.Context, tenantID, id string) (User, error
 		return User{}, fmt.Errorf("failed to get user: %w", err)
 	}
 
-	return User{
-		Schemas:  []string{UserSchema},
+	users, err := s.withExtensions(ctx, tenantID, []directory.User{u})
+	if err != nil {
+		return User{}, err
+	}
+	return users[0], nil
+}
+
+// withExtensions converts users to SCIM, adding their stored extensions.
+func (s *Service) withExtensions(ctx context.Context, tenantID string, users []directory.User) ([]User, error) {
+	out := make([]User, len(users))
+	for i, u := range users {
+		out[i] = toSCIMUser(u)
+	}
+	if s.extensions == nil || len(users) == 0 {
+		return out, nil
+	}
+	ids := make([]string, len(users))
+	for i, u := range users {
+		ids[i] = u.ID
+	}
+	enterprise, err := s.extensions.GetEnterprise(ctx, tenantID, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load enterprise extensions: %w", err)
+	}
+	for i := range out {
+		if ext, ok := enterprise[out[i].ID]; ok {
+			out[i].Enterprise = &ext
+			out[i].Schemas = userSchemas(out[i])
+		}
+	}
+	return out, nil
+}
+
+// profileOf maps the SCIM attributes the directory keeps in its profile: the
+// title, the primary (or first) phone number and address, and the enterprise
+// department and manager.
+func profileOf(u User) directory.Profile {
+	p := directory.Profile{Title: u.Title}
+	for i, n := range u.PhoneNumbers {
+		if n.Primary || i == 0 {
+			p.Phone = n.Value
+		}
+	}
+	for i, a := range u.Addresses {
+		if a.Primary || i == 0 {
+			p.Location = a.Locality
+			if p.Location == "" {
+				p.Location = a.Formatted
+			}
+		}
+	}
+	if u.Enterprise != nil {
+		p.Department = u.Enterprise.Department
+		if u.Enterprise.Manager != nil {
+			p.ManagerID = u.Enterprise.Manager.Value
+		}
+	}
+	return p
+}
+
+// profileEnterprise reports the directory profile's department and manager
+// as an enterprise extension, for users that have no stored extension, e.g.
+// because they were created through the directory API. It returns nil when
+// neither is set.
+func profileEnterprise(p directory.Profile) *EnterpriseUser {
+	if p.Department == "" && p.ManagerID == "" {
+		return nil
+	}
+	ext := &EnterpriseUser{Department: p.Department}
+	if p.ManagerID != "" {
+		ext.Manager = &Manager{Value: p.ManagerID}
+	}
+	return ext
+}
+
+// replaceProfile stores the user's directory profile. HR systems often
+// provision a user before their manager, so a manager the directory does not
+// know yet is left out of the profile and kept only in the enterprise
+// extension.
+func (s *Service) replaceProfile(ctx context.Context, tenantID, id string, profile directory.Profile) error {
+	err := s.dirSvc.ReplaceProfile(ctx, tenantID, id, profile)
+	if errors.Is(err, directory.ErrInvalidManager) {
+		profile.ManagerID = ""
+		err = s.dirSvc.ReplaceProfile(ctx, tenantID, id, profile)
+	}
+	return err
+}
+
+func (s *Service) putEnterprise(ctx context.Context, tenantID, userID string, ext *EnterpriseUser) error {
+	if s.extensions == nil {
+		return nil
+	}
+	return s.extensions.PutEnterprise(ctx, tenantID, userID, ext)
+}
+
+// userSchemas lists the schemas u carries.
+func userSchemas(u User) []string {
+	if u.Enterprise != nil {
+		return []string{UserSchema, EnterpriseUserSchema}
+	}
+	return []string{UserSchema}
+}
+
+func toSCIMUser(u directory.User) User {
+	out := User{
 		ID:       u.ID,
 		UserName: u.Email,
+		Title:    u.Title,
 		Active:   u.Status == "active",
 		Emails: []Email{
 			{Value: u.Email, Type: "work", Primary: true},
 		},
+		Enterprise: profileEnterprise(u.Profile),
 		Meta: Meta{
 			ResourceType: "User",
 			Created:      u.CreatedAt.Format(time.RFC3339),
 			LastModified: u.UpdatedAt.Format(time.RFC3339),
 			Location:     fmt.Sprintf("/scim/v2/Users/%s", u.ID),
 		},
-	}, nil
+	}
+	if u.Phone != "" {
+		out.PhoneNumbers = []PhoneNumber{{Value: u.Phone, Type: "work", Primary: true}}
+	}
+	if u.Location != "" {
+		out.Addresses = []Address{{Locality: u.Location, Type: "work", Primary: true}}
+	}
+	out.Schemas = userSchemas(out)
+	return out
 }
 
-// ListUsers handles GET /scim/v2/Users with optional filtering and pagination.
-func (s *Service) ListUsers(ctx context.Context, tenantID, filter string, startIndex, count int) (ListResponse, error) {
+// ListUsers handles GET /scim/v2/Users with optional filtering, sorting and
+// pagination. An invalid filter returns a *filter.SyntaxError and an
+// unsupported sort ErrInvalidSort.
+func (s *Service) ListUsers(ctx context.Context, tenantID, filterExpr string, sortParams SortParams, startIndex, count int) (ListResponse, error) {
 	if startIndex < 1 {
 		startIndex = 1
 	}
@@ -95,32 +240,44 @@ func (s *Service) ListUsers(ctx context.Context, tenantID, filter string, startI
 	}
 	offset := startIndex - 1 // SCIM is 1-indexed
 
-	users, total, err := s.dirSvc.ListUsers(ctx, tenantID, count, offset)
+	sort, err := sortParams.resolve(userSortAttributes)
 	if err != nil {
-		return ListResponse{}, fmt.Errorf("failed to list users: %w", err)
-	}
-
-	// Convert to SCIM Users
-	resources := make([]interface{}, 0, len(users))
-	for _, u := range users {
-		scimUser := User{
-			Schemas:  []string{UserSchema},
-			ID:       u.ID,
-			UserName: u.Email,
-			Active:   u.Status == "active",
-			Emails: []Email{
-				{Value: u.Email, Type: "work", Primary: true},
-			},
-			Meta: Meta{
-				ResourceType: "User",
-				Created:      u.CreatedAt.Format(time.RFC3339),
-				LastModified: u.UpdatedAt.Format(time.RFC3339),
-				Location:     fmt.Sprintf("/scim/v2/Users/%s", u.ID),
-			},
+		return ListResponse{}, err
+	}
+
+	var (
+		users []directory.User
+		total int
+	)
+	if filterExpr == "" {
+		users, total, err = s.dirSvc.ListUsers(ctx, tenantID, count, offset, sort)
+		if err != nil {
+			return ListResponse{}, fmt.Errorf("failed to list users: %w", err)
+		}
+	} else {
+		expr, err := filter.Parse(filterExpr)
+		if err != nil {
+			return ListResponse{}, err
+		}
+		matches, err := s.filterUsers(ctx, tenantID, expr)
+		if err != nil {
+			return ListResponse{}, fmt.Errorf("failed to list users: %w", err)
+		}
+		sortUsers(matches, sort)
+		total = len(matches)
+		if offset < total {
+			users = matches[offset:min(offset+count, total)]
 		}
-		resources = append(resources, scimUser)
 	}
 
+	scimUsers, err := s.withExtensions(ctx, tenantID, users)
+	if err != nil {
+		return ListResponse{}, err
+	}
+	resources := make([]interface{}, 0, len(scimUsers))
+	for _, u := range scimUsers {
+		resources = append(resources, u)
+	}
 	return ListResponse{
 		Schemas:      []string{ListSchema},
 		TotalResults: total,
@@ -130,6 +287,53 @@ func (s *Service) ListUsers(ctx context.Context, tenantID, filter string, startI
 	}, nil
 }
 
+// filterUsers returns the tenant's users matching expr. An equality test on
+// userName, the directory login, is tried against the login index first;
+// everything else, including logins differing only in case, scans the tenant.
+func (s *Service) filterUsers(ctx context.Context, tenantID string, expr filter.Expr) ([]directory.User, error) {
+	for _, path := range []string{"userName", UserSchema + ":userName"} {
+		value, ok := filter.Equals(expr, path)
+		if !ok {
+			continue
+		}
+		login, _ := value.(string)
+		u, err := s.dirSvc.GetUserByEmail(ctx, tenantID, login)
+		if err == nil {
+			return []directory.User{u}, nil
+		}
+		if !errors.Is(err, directory.ErrUserNotFound) {
+			return nil, err
+		}
+		break
+	}
+
+	var matches []directory.User
+	err := s.dirSvc.ExportUsers(ctx, tenantID, "", func(u directory.User) error {
+		attrs, err := attributes(toSCIMUser(u))
+		if err != nil {
+			return err
+		}
+		if filter.Match(expr, attrs) {
+			matches = append(matches, u)
+		}
+		return nil
+	})
+	return matches, err
+}
+
+// attributes exposes a resource's JSON attributes to filter evaluation.
+func attributes(resource any) (filter.Getter, error) {
+	b, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	return filter.Map(doc), nil
+}
+
 // ReplaceUser handles PUT /scim/v2/Users/{id} - full replacement.
 func (s *Service) ReplaceUser(ctx context.Context, tenantID, id string, req User) (User, error) {
 	// Map SCIM User to directory User
@@ -156,6 +360,14 @@ func (s *Service) ReplaceUser(ctx context.Context, tenantID, id string, req User
 	if err := s.dirSvc.UpdateUser(ctx, tenantID, id, dirUser); err != nil {
 		return User{}, fmt.Errorf("failed to update user: %w", err)
 	}
+	// Attributes missing from the replacement are cleared.
+	if err := s.replaceProfile(ctx, tenantID, id, profileOf(req)); err != nil {
+		return User{}, fmt.Errorf("failed to store profile: %w", err)
+	}
+	// PUT replaces the whole resource, so a missing extension is removed.
+	if err := s.putEnterprise(ctx, tenantID, id, req.Enterprise); err != nil {
+		return User{}, fmt.Errorf("failed to store enterprise extension: %w", err)
+	}
 
 	// Return updated user
 	return s.GetUser(ctx, tenantID, id)
@@ -169,9 +381,37 @@ func (s *Service) PatchUser(ctx context.Context, tenantID, id string, ops []Patc
 	if err != nil {
 		return User{}, fmt.Errorf("failed to get user: %w", err)
 	}
+	storedProfile := current.Profile
+
+	var (
+		enterprise        *EnterpriseUser
+		enterpriseChanged bool
+	)
+	if s.extensions != nil {
+		stored, err := s.extensions.GetEnterprise(ctx, tenantID, []string{id})
+		if err != nil {
+			return User{}, fmt.Errorf("failed to load enterprise extension: %w", err)
+		}
+		if ext, ok := stored[id]; ok {
+			enterprise = &ext
+		}
+	}
 
 	// Apply operations
 	for _, op := range ops {
+		if attr, ok := strings.CutPrefix(op.Path, EnterpriseUserSchema+":"); ok {
+			if enterprise == nil {
+				enterprise = &EnterpriseUser{}
+			}
+			value := op.Value
+			if op.Op == "remove" {
+				value = nil
+			}
+			if patchEnterprise(enterprise, attr, value) {
+				enterpriseChanged = true
+			}
+			continue
+		}
 		switch op.Op {
 		case "replace":
 			switch op.Path {
@@ -187,18 +427,94 @@ func (s *Service) PatchUser(ctx context.Context, tenantID, id string, ops []Patc
 				if userName, ok := op.Value.(string); ok {
 					current.Email = userName
 				}
+			case "title":
+				if title, ok := op.Value.(string); ok {
+					current.Title = title
+				}
+			case "phoneNumbers", "addresses":
+				var patched User
+				if decodeValue(map[string]interface{}{op.Path: op.Value}, &patched) == nil {
+					p := profileOf(patched)
+					if op.Path == "phoneNumbers" {
+						current.Phone = p.Phone
+					} else {
+						current.Location = p.Location
+					}
+				}
 			}
 		}
 	}
 
+	// The directory profile keeps the department and manager too.
+	profile := current.Profile
+	if enterpriseChanged {
+		p := profileOf(User{Enterprise: enterprise})
+		profile.Department, profile.ManagerID = p.Department, p.ManagerID
+	}
+	current.Profile = directory.Profile{}
+
 	// Persist changes
 	if err := s.dirSvc.UpdateUser(ctx, tenantID, id, current); err != nil {
 		return User{}, fmt.Errorf("failed to patch user: %w", err)
 	}
+	if profile != storedProfile {
+		if err := s.replaceProfile(ctx, tenantID, id, profile); err != nil {
+			return User{}, fmt.Errorf("failed to store profile: %w", err)
+		}
+	}
+	if enterpriseChanged {
+		if *enterprise == (EnterpriseUser{}) {
+			enterprise = nil
+		}
+		if err := s.putEnterprise(ctx, tenantID, id, enterprise); err != nil {
+			return User{}, fmt.Errorf("failed to store enterprise extension: %w", err)
+		}
+	}
 
 	return s.GetUser(ctx, tenantID, id)
 }
 
+// patchEnterprise sets, or with a nil value clears, one enterprise extension
+// attribute named by its path after the schema URN, and reports whether the
+// attribute is known.
+func patchEnterprise(ext *EnterpriseUser, attr string, value interface{}) bool {
+	str, _ := value.(string)
+	switch attr {
+	case "employeeNumber":
+		ext.EmployeeNumber = str
+	case "costCenter":
+		ext.CostCenter = str
+	case "organization":
+		ext.Organization = str
+	case "division":
+		ext.Division = str
+	case "department":
+		ext.Department = str
+	case "manager", "manager.value":
+		// The manager is set from its ID, given either directly or as the
+		// value of a complex manager object.
+		if m, ok := value.(map[string]interface{}); ok {
+			str, _ = m["value"].(string)
+		}
+		ext.Manager = nil
+		if str != "" {
+			ext.Manager = &Manager{Value: str}
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// decodeValue converts a decoded JSON patch value into v.
+func decodeValue(value interface{}, v interface{}) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
 // DeleteUser handles DELETE /scim/v2/Users/{id}.
 func (s *Service) DeleteUser(ctx context.Context, tenantID, id string) error {
 	if err := s.dirSvc.DeleteUser(ctx, tenantID, id); err != nil {
@@ -247,8 +563,9 @@ func (s *Service) GetGroup(ctx context.Context, tenantID, id string) (Group, err
 	}, nil
 }
 
-// ListGroups handles GET /scim/v2/Groups with pagination.
-func (s *Service) ListGroups(ctx context.Context, tenantID string, startIndex, count int) (ListResponse, error) {
+// ListGroups handles GET /scim/v2/Groups with sorting and pagination. An
+// unsupported sort returns ErrInvalidSort.
+func (s *Service) ListGroups(ctx context.Context, tenantID string, sortParams SortParams, startIndex, count int) (ListResponse, error) {
 	if startIndex < 1 {
 		startIndex = 1
 	}
@@ -257,7 +574,11 @@ func (s *Service) ListGroups(ctx context.Context, tenantID string, startIndex, c
 	}
 	offset := startIndex - 1
 
-	groups, total, err := s.dirSvc.ListGroups(ctx, tenantID, count, offset)
+	sort, err := sortParams.resolve(groupSortAttributes)
+	if err != nil {
+		return ListResponse{}, err
+	}
+	groups, total, err := s.dirSvc.ListGroups(ctx, tenantID, count, offset, sort)
 	if err != nil {
 		return ListResponse{}, fmt.Errorf("failed to list groups: %w", err)
 	}
@@ -329,3 +650,14 @@ func (s *Service) DeleteGroup(ctx context.Context, tenantID, id string) error {
 	}
 	return nil
 }
+
+// generatePassword returns a random password for users provisioned without
+// one. The fixed suffix covers every character class so it satisfies any
+// directory password policy.
+func generatePassword() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b) + "Aa1!", nil
+}
@@ -0,0 +1,78 @@
+package scim
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/dhawalhost/wardseal/internal/directory"
+)
+
+// ErrInvalidSort is returned for a sortBy attribute or sortOrder the
+// resource cannot be listed by.
+var ErrInvalidSort = errors.New("invalid sort")
+
+// SortParams holds the sortBy and sortOrder parameters of a list request
+// (RFC 7644 section 3.4.2.3).
+type SortParams struct {
+	By    string
+	Order string
+}
+
+// Sortable attributes by lower-cased name, and the directory field each maps to.
+var (
+	userSortAttributes = map[string]directory.SortField{
+		"username":     directory.SortByLogin,
+		"meta.created": directory.SortByCreated,
+	}
+	groupSortAttributes = map[string]directory.SortField{
+		"displayname":  directory.SortByName,
+		"meta.created": directory.SortByCreated,
+	}
+)
+
+// resolve maps the parameters onto a directory sort. Without sortBy the
+// directory's default order applies; sortOrder defaults to ascending.
+func (p SortParams) resolve(allowed map[string]directory.SortField) (directory.Sort, error) {
+	var sort directory.Sort
+	switch strings.ToLower(p.Order) {
+	case "", "ascending":
+	case "descending":
+		sort.Descending = true
+	default:
+		return directory.Sort{}, fmt.Errorf("%w: sortOrder must be ascending or descending", ErrInvalidSort)
+	}
+	if p.By == "" {
+		return directory.Sort{Descending: sort.Descending}, nil
+	}
+	attr := strings.ToLower(p.By)
+	attr = strings.TrimPrefix(attr, strings.ToLower(UserSchema)+":")
+	attr = strings.TrimPrefix(attr, strings.ToLower(GroupSchema)+":")
+	field, ok := allowed[attr]
+	if !ok {
+		return directory.Sort{}, fmt.Errorf("%w: cannot sort by %q", ErrInvalidSort, p.By)
+	}
+	sort.Field = field
+	return sort, nil
+}
+
+// sortUsers orders users in memory the way the directory orders a listing.
+func sortUsers(users []directory.User, sort directory.Sort) {
+	slices.SortStableFunc(users, func(a, b directory.User) int {
+		var c int
+		if sort.Field == directory.SortByLogin {
+			c = cmp.Compare(a.Email, b.Email)
+		} else {
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		}
+		if c == 0 {
+			c = cmp.Compare(a.ID, b.ID)
+		}
+		if sort.Descending {
+			return -c
+		}
+		return c
+	})
+}
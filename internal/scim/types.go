@@ -9,9 +9,34 @@ type User struct {
 		GivenName  string `json:"givenName,omitempty"`
 		FamilyName string `json:"familyName,omitempty"`
 	} `json:"name,omitempty"`
-	Emails []Email `json:"emails,omitempty"`
-	Active bool    `json:"active"`
-	Meta   Meta    `json:"meta,omitempty"`
+	Title        string        `json:"title,omitempty"`
+	Emails       []Email       `json:"emails,omitempty"`
+	PhoneNumbers []PhoneNumber `json:"phoneNumbers,omitempty"`
+	Addresses    []Address     `json:"addresses,omitempty"`
+	Active       bool          `json:"active"`
+	// Password is write-only (RFC 7643 section 4.1.1) and never returned.
+	Password string `json:"password,omitempty"`
+	// Enterprise holds the enterprise user extension, when the user has one.
+	Enterprise *EnterpriseUser `json:"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User,omitempty"`
+	Meta       Meta            `json:"meta,omitempty"`
+}
+
+// EnterpriseUser is the enterprise user extension (RFC 7643 section 4.3),
+// sent by HR-driven provisioning.
+type EnterpriseUser struct {
+	EmployeeNumber string   `json:"employeeNumber,omitempty"`
+	CostCenter     string   `json:"costCenter,omitempty"`
+	Organization   string   `json:"organization,omitempty"`
+	Division       string   `json:"division,omitempty"`
+	Department     string   `json:"department,omitempty"`
+	Manager        *Manager `json:"manager,omitempty"`
+}
+
+// Manager references a user's manager by SCIM ID.
+type Manager struct {
+	Value       string `json:"value,omitempty"`
+	Ref         string `json:"$ref,omitempty"`
+	DisplayName string `json:"displayName,omitempty"`
 }
 
 // Group represents a SCIM 2.0 Group resource.
@@ -29,6 +54,21 @@ type Email struct {
 	Primary bool   `json:"primary,omitempty"`
 }
 
+type PhoneNumber struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// Address is a postal address. Only its locality, or failing that the
+// formatted address, is kept, as the user's location.
+type Address struct {
+	Formatted string `json:"formatted,omitempty"`
+	Locality  string `json:"locality,omitempty"`
+	Type      string `json:"type,omitempty"`
+	Primary   bool   `json:"primary,omitempty"`
+}
+
 type Member struct {
 	Value   string `json:"value"`
 	Display string `json:"display,omitempty"`
@@ -73,9 +113,11 @@ type PatchOperation struct {
 }
 
 const (
-	UserSchema  = "urn:ietf:params:scim:schemas:core:2.0:User"
-	GroupSchema = "urn:ietf:params:scim:schemas:core:2.0:Group"
-	ListSchema  = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
-	ErrorSchema = "urn:ietf:params:scim:api:messages:2.0:Error"
-	PatchSchema = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	UserSchema           = "urn:ietf:params:scim:schemas:core:2.0:User"
+	GroupSchema          = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	EnterpriseUserSchema = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+	SchemaSchema         = "urn:ietf:params:scim:schemas:core:2.0:Schema"
+	ListSchema           = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	ErrorSchema          = "urn:ietf:params:scim:api:messages:2.0:Error"
+	PatchSchema          = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
 )
@@ -0,0 +1,126 @@
+package scopecatalog
+
+import (
+	"net/http"
+
+	"github.com/dhawalhost/wardseal/pkg/apierr"
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// HTTPHandler handles scope catalog HTTP requests.
+type HTTPHandler struct {
+	svc    Service
+	logger *zap.Logger
+}
+
+// NewHTTPHandler creates a new scope catalog HTTP handler.
+func NewHTTPHandler(svc Service, logger *zap.Logger) *HTTPHandler {
+	return &HTTPHandler{svc: svc, logger: logger}
+}
+
+// RegisterRoutes registers the scope catalog routes under /oauth/scopes. rg
+// must be tenant-scoped.
+func (h *HTTPHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	scopes := rg.Group("/oauth/scopes")
+	{
+		scopes.GET("", h.listScopes)
+		scopes.POST("", h.createScope)
+		scopes.GET("/:id", h.getScope)
+		scopes.PUT("/:id", h.updateScope)
+		scopes.DELETE("/:id", h.deleteScope)
+	}
+}
+
+func (h *HTTPHandler) tenantID(c *gin.Context) (string, bool) {
+	tenantID, err := middleware.TenantIDFromGinContext(c)
+	if err != nil {
+		apierr.Abort(c, apierr.Invalid("tenant id required"))
+		return "", false
+	}
+	return tenantID, true
+}
+
+func (h *HTTPHandler) listScopes(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+	scopes, err := h.svc.ListScopes(c.Request.Context(), tenantID)
+	if err != nil {
+		apierr.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"scopes": scopes})
+}
+
+func (h *HTTPHandler) getScope(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+	scope, err := h.svc.GetScope(c.Request.Context(), tenantID, c.Param("id"))
+	if err != nil {
+		apierr.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, scope)
+}
+
+func (h *HTTPHandler) createScope(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+	var req CreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierr.Abort(c, apierr.Validation(err))
+		return
+	}
+
+	scope, err := h.svc.CreateScope(c.Request.Context(), tenantID, req)
+	if err != nil {
+		apierr.Abort(c, err)
+		return
+	}
+
+	h.logger.Info("Scope created", zap.String("tenant_id", tenantID), zap.String("scope", scope.ID))
+	c.JSON(http.StatusCreated, scope)
+}
+
+func (h *HTTPHandler) updateScope(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+	var req UpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierr.Abort(c, apierr.Validation(err))
+		return
+	}
+
+	scope, err := h.svc.UpdateScope(c.Request.Context(), tenantID, c.Param("id"), req)
+	if err != nil {
+		apierr.Abort(c, err)
+		return
+	}
+
+	h.logger.Info("Scope updated", zap.String("tenant_id", tenantID), zap.String("scope", scope.ID))
+	c.JSON(http.StatusOK, scope)
+}
+
+func (h *HTTPHandler) deleteScope(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+	id := c.Param("id")
+	if err := h.svc.DeleteScope(c.Request.Context(), tenantID, id); err != nil {
+		apierr.Abort(c, err)
+		return
+	}
+
+	h.logger.Info("Scope deleted", zap.String("tenant_id", tenantID), zap.String("scope", id))
+	c.Status(http.StatusNoContent)
+}
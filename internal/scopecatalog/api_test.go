@@ -0,0 +1,230 @@
+package scopecatalog_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/dhawalhost/wardseal/internal/scopecatalog"
+	"github.com/dhawalhost/wardseal/pkg/apierr"
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const testTenant = "11111111-1111-1111-1111-111111111111"
+
+// memoryStore is an in-memory scopecatalog.Store seeded with the defaults.
+type memoryStore struct {
+	defaults map[string]scopecatalog.Scope
+	tenant   map[string]scopecatalog.Scope
+	// used are the scopes the tenant's OAuth clients are registered with.
+	used map[string]bool
+}
+
+func newMemoryStore() *memoryStore {
+	s := &memoryStore{
+		defaults: make(map[string]scopecatalog.Scope),
+		tenant:   make(map[string]scopecatalog.Scope),
+		used:     make(map[string]bool),
+	}
+	for _, scope := range scopecatalog.Defaults {
+		s.defaults[scope.ID] = scope
+	}
+	return s
+}
+
+func (s *memoryStore) List(ctx context.Context, tenantID string) ([]scopecatalog.Scope, error) {
+	merged := make(map[string]scopecatalog.Scope)
+	for id, scope := range s.defaults {
+		merged[id] = scope
+	}
+	for id, scope := range s.tenant {
+		merged[id] = scope
+	}
+	out := make([]scopecatalog.Scope, 0, len(merged))
+	for _, scope := range merged {
+		out = append(out, scope)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func (s *memoryStore) Get(ctx context.Context, tenantID, id string) (scopecatalog.Scope, error) {
+	if scope, ok := s.tenant[id]; ok {
+		return scope, nil
+	}
+	return s.GetDefault(ctx, id)
+}
+
+func (s *memoryStore) GetDefault(ctx context.Context, id string) (scopecatalog.Scope, error) {
+	scope, ok := s.defaults[id]
+	if !ok {
+		return scopecatalog.Scope{}, sql.ErrNoRows
+	}
+	return scope, nil
+}
+
+func (s *memoryStore) Create(ctx context.Context, tenantID string, scope scopecatalog.Scope) (scopecatalog.Scope, error) {
+	scope.Default = false
+	scope.UpdatedAt = time.Now()
+	s.tenant[scope.ID] = scope
+	return scope, nil
+}
+
+func (s *memoryStore) Update(ctx context.Context, tenantID string, scope scopecatalog.Scope) (scopecatalog.Scope, error) {
+	if _, ok := s.tenant[scope.ID]; !ok {
+		return scopecatalog.Scope{}, sql.ErrNoRows
+	}
+	return s.Create(ctx, tenantID, scope)
+}
+
+func (s *memoryStore) Delete(ctx context.Context, tenantID, id string) error {
+	if _, ok := s.tenant[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(s.tenant, id)
+	return nil
+}
+
+func (s *memoryStore) InUse(ctx context.Context, tenantID, id string) (bool, error) {
+	return s.used[id], nil
+}
+
+func newScopeRouter(t *testing.T) (*gin.Engine, *memoryStore) {
+	t.Helper()
+	store := newMemoryStore()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(apierr.Handler(zap.NewNop()))
+	api := r.Group("/api/v1")
+	api.Use(middleware.TenantExtractor(middleware.TenantConfig{}))
+	scopecatalog.NewHTTPHandler(scopecatalog.NewService(store), zap.NewNop()).RegisterRoutes(api)
+	return r, store
+}
+
+func doScopeRequest(r *gin.Engine, method, path string, body any) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		_ = json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(middleware.DefaultTenantHeader, testTenant)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func decodeScope(t *testing.T, w *httptest.ResponseRecorder) scopecatalog.Scope {
+	t.Helper()
+	var scope scopecatalog.Scope
+	if err := json.Unmarshal(w.Body.Bytes(), &scope); err != nil {
+		t.Fatalf("failed to decode scope: %v", err)
+	}
+	return scope
+}
+
+func TestScopeCatalogDefaultsAreDescribed(t *testing.T) {
+	r, _ := newScopeRouter(t)
+
+	w := doScopeRequest(r, http.MethodGet, "/api/v1/oauth/scopes", nil)
+	var list struct {
+		Scopes []scopecatalog.Scope `json:"scopes"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("list: expected 200, got %d %s", w.Code, w.Body)
+	}
+	if len(list.Scopes) != len(scopecatalog.Defaults) {
+		t.Fatalf("expected the %d default scopes, got %+v", len(scopecatalog.Defaults), list.Scopes)
+	}
+	for _, scope := range list.Scopes {
+		if !scope.Default || scope.DisplayName == "" || scope.Description == "" {
+			t.Fatalf("expected a described default scope, got %+v", scope)
+		}
+	}
+
+	w = doScopeRequest(r, http.MethodGet, "/api/v1/oauth/scopes/offline_access", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("get: expected 200, got %d", w.Code)
+	}
+	if scope := decodeScope(t, w); scope.DisplayName != "Offline access" || !scope.Sensitive {
+		t.Fatalf("unexpected offline_access entry %+v", scope)
+	}
+	if w := doScopeRequest(r, http.MethodGet, "/api/v1/oauth/scopes/unknown", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("unknown scope: expected 404, got %d", w.Code)
+	}
+}
+
+func TestScopeCatalogCRUD(t *testing.T) {
+	r, store := newScopeRouter(t)
+
+	w := doScopeRequest(r, http.MethodPost, "/api/v1/oauth/scopes", map[string]any{
+		"id": "orders:read", "display_name": "Read orders", "description": "See your order history",
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d: %s", w.Code, w.Body)
+	}
+	if scope := decodeScope(t, w); scope.ID != "orders:read" || scope.DisplayName != "Read orders" || scope.Default {
+		t.Fatalf("unexpected created scope %+v", scope)
+	}
+	if w := doScopeRequest(r, http.MethodPost, "/api/v1/oauth/scopes", map[string]any{"id": "orders:read"}); w.Code != http.StatusConflict {
+		t.Fatalf("duplicate: expected 409, got %d", w.Code)
+	}
+	if w := doScopeRequest(r, http.MethodPost, "/api/v1/oauth/scopes", map[string]any{"id": "orders read"}); w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid id: expected 400, got %d", w.Code)
+	}
+	w = doScopeRequest(r, http.MethodPut, "/api/v1/oauth/scopes/orders:read", map[string]any{"sensitive": "yes"})
+	var invalid struct {
+		Fields []apierr.FieldError `json:"fields"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &invalid)
+	want := apierr.FieldError{Field: "sensitive", Rule: "type", Message: "sensitive must be a boolean"}
+	if w.Code != http.StatusBadRequest || len(invalid.Fields) != 1 || invalid.Fields[0] != want {
+		t.Fatalf("mistyped update: expected 400 with %+v, got %d %s", want, w.Code, w.Body)
+	}
+
+	w = doScopeRequest(r, http.MethodPut, "/api/v1/oauth/scopes/orders:read", map[string]any{"sensitive": true})
+	if w.Code != http.StatusOK {
+		t.Fatalf("update: expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if scope := decodeScope(t, w); !scope.Sensitive || scope.DisplayName != "Read orders" {
+		t.Fatalf("expected only sensitive to change, got %+v", scope)
+	}
+
+	// Updating a default scope overrides it for the tenant; deleting the
+	// override restores the default.
+	w = doScopeRequest(r, http.MethodPut, "/api/v1/oauth/scopes/profile", map[string]any{"description": "Read your HR record"})
+	if scope := decodeScope(t, w); w.Code != http.StatusOK || scope.Default || scope.Description != "Read your HR record" || scope.DisplayName != "Profile" {
+		t.Fatalf("override: expected 200 and a tenant entry, got %d %+v", w.Code, scope)
+	}
+	store.used["profile"] = true
+	if w := doScopeRequest(r, http.MethodDelete, "/api/v1/oauth/scopes/profile", nil); w.Code != http.StatusNoContent {
+		t.Fatalf("delete override: expected 204, got %d: %s", w.Code, w.Body)
+	}
+	if scope := decodeScope(t, doScopeRequest(r, http.MethodGet, "/api/v1/oauth/scopes/profile", nil)); !scope.Default {
+		t.Fatalf("expected the default profile scope back, got %+v", scope)
+	}
+	if w := doScopeRequest(r, http.MethodDelete, "/api/v1/oauth/scopes/profile", nil); w.Code != http.StatusForbidden {
+		t.Fatalf("delete default: expected 403, got %d", w.Code)
+	}
+
+	// A scope clients are registered with stays in the catalog.
+	store.used["orders:read"] = true
+	if w := doScopeRequest(r, http.MethodDelete, "/api/v1/oauth/scopes/orders:read", nil); w.Code != http.StatusConflict {
+		t.Fatalf("delete in use: expected 409, got %d", w.Code)
+	}
+	store.used["orders:read"] = false
+	if w := doScopeRequest(r, http.MethodDelete, "/api/v1/oauth/scopes/orders:read", nil); w.Code != http.StatusNoContent {
+		t.Fatalf("delete: expected 204, got %d: %s", w.Code, w.Body)
+	}
+	if w := doScopeRequest(r, http.MethodGet, "/api/v1/oauth/scopes/orders:read", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("deleted: expected 404, got %d", w.Code)
+	}
+}
@@ -0,0 +1,172 @@
+package scopecatalog
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dhawalhost/wardseal/pkg/apierr"
+)
+
+// Defaults is the catalog shared by every tenant, matching the rows seeded by
+// the migrations.
+var Defaults = []Scope{
+	{ID: "address", DisplayName: "Postal address", Description: "Read your postal address", Sensitive: true, Default: true},
+	{ID: "email", DisplayName: "Email address", Description: "Read your email address", Default: true},
+	{ID: "offline_access", DisplayName: "Offline access", Description: "Keep access to your account while you are signed out", Sensitive: true, Default: true},
+	{ID: "openid", DisplayName: "OpenID", Description: "Sign you in with your account", Default: true},
+	{ID: "phone", DisplayName: "Phone number", Description: "Read your phone number", Sensitive: true, Default: true},
+	{ID: "profile", DisplayName: "Profile", Description: "Read your name and basic profile information", Default: true},
+}
+
+type defaultCatalog struct{}
+
+// DefaultCatalog returns a Catalog holding only Defaults, for every tenant.
+func DefaultCatalog() Catalog {
+	return defaultCatalog{}
+}
+
+func (defaultCatalog) List(ctx context.Context, tenantID string) ([]Scope, error) {
+	return append([]Scope(nil), Defaults...), nil
+}
+
+// CreateRequest adds a scope to the tenant's catalog. Creating a scope that
+// is in the default catalog overrides its details for the tenant.
+type CreateRequest struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"display_name"`
+	Description string `json:"description"`
+	Sensitive   bool   `json:"sensitive"`
+}
+
+// UpdateRequest changes a scope. Nil fields are left unchanged.
+type UpdateRequest struct {
+	DisplayName *string `json:"display_name"`
+	Description *string `json:"description"`
+	Sensitive   *bool   `json:"sensitive"`
+}
+
+// Service defines scope catalog operations.
+type Service interface {
+	ListScopes(ctx context.Context, tenantID string) ([]Scope, error)
+	GetScope(ctx context.Context, tenantID, id string) (Scope, error)
+	CreateScope(ctx context.Context, tenantID string, req CreateRequest) (Scope, error)
+	// UpdateScope changes a tenant's scope. Updating a default scope creates
+	// a tenant override of it.
+	UpdateScope(ctx context.Context, tenantID, id string, req UpdateRequest) (Scope, error)
+	// DeleteScope removes a tenant's scope, or its override of a default
+	// scope. Default scopes cannot be deleted, and scopes that OAuth clients
+	// are registered with cannot be removed from the catalog.
+	DeleteScope(ctx context.Context, tenantID, id string) error
+}
+
+type service struct {
+	store Store
+}
+
+// NewService creates a new scope catalog service.
+func NewService(store Store) Service {
+	return &service{store: store}
+}
+
+func (s *service) ListScopes(ctx context.Context, tenantID string) ([]Scope, error) {
+	return s.store.List(ctx, tenantID)
+}
+
+func (s *service) GetScope(ctx context.Context, tenantID, id string) (Scope, error) {
+	scope, err := s.store.Get(ctx, tenantID, id)
+	if err != nil {
+		return Scope{}, notFound(err)
+	}
+	return scope, nil
+}
+
+func (s *service) CreateScope(ctx context.Context, tenantID string, req CreateRequest) (Scope, error) {
+	if !validScopeToken(req.ID) {
+		return Scope{}, apierr.Invalid("scope id must be a non-empty OAuth scope token")
+	}
+	existing, err := s.store.Get(ctx, tenantID, req.ID)
+	switch {
+	case err == nil && !existing.Default:
+		return Scope{}, apierr.Conflict(fmt.Sprintf("scope %s already exists", req.ID))
+	case err != nil && !errors.Is(err, sql.ErrNoRows):
+		return Scope{}, err
+	}
+	return s.store.Create(ctx, tenantID, Scope{
+		ID:          req.ID,
+		DisplayName: strings.TrimSpace(req.DisplayName),
+		Description: strings.TrimSpace(req.Description),
+		Sensitive:   req.Sensitive,
+	})
+}
+
+func (s *service) UpdateScope(ctx context.Context, tenantID, id string, req UpdateRequest) (Scope, error) {
+	scope, err := s.GetScope(ctx, tenantID, id)
+	if err != nil {
+		return Scope{}, err
+	}
+	if req.DisplayName != nil {
+		scope.DisplayName = strings.TrimSpace(*req.DisplayName)
+	}
+	if req.Description != nil {
+		scope.Description = strings.TrimSpace(*req.Description)
+	}
+	if req.Sensitive != nil {
+		scope.Sensitive = *req.Sensitive
+	}
+	if scope.Default {
+		return s.store.Create(ctx, tenantID, scope)
+	}
+	updated, err := s.store.Update(ctx, tenantID, scope)
+	if err != nil {
+		return Scope{}, notFound(err)
+	}
+	return updated, nil
+}
+
+func (s *service) DeleteScope(ctx context.Context, tenantID, id string) error {
+	scope, err := s.GetScope(ctx, tenantID, id)
+	if err != nil {
+		return err
+	}
+	if scope.Default {
+		return apierr.Forbidden(fmt.Sprintf("default scope %s cannot be deleted", id))
+	}
+	// Removing an override leaves the default in the catalog, so clients
+	// registered with the scope stay valid.
+	if _, err := s.store.GetDefault(ctx, id); errors.Is(err, sql.ErrNoRows) {
+		inUse, err := s.store.InUse(ctx, tenantID, id)
+		if err != nil {
+			return err
+		}
+		if inUse {
+			return apierr.Conflict(fmt.Sprintf("scope %s is used by OAuth clients", id))
+		}
+	} else if err != nil {
+		return err
+	}
+	return notFound(s.store.Delete(ctx, tenantID, id))
+}
+
+// validScopeToken reports whether id is a scope-token as defined by RFC 6749
+// section 3.3.
+func validScopeToken(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, r := range id {
+		if r < 0x21 || r > 0x7e || r == '"' || r == '\\' {
+			return false
+		}
+	}
+	return true
+}
+
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return apierr.NotFound("scope not found")
+	}
+	return err
+}
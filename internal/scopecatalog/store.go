@@ -0,0 +1,129 @@
+package scopecatalog
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Scope is an entry in a tenant's scope catalog. OAuth clients may only be
+// registered with, and granted, scopes in the catalog.
+type Scope struct {
+	// ID is the scope value requested by clients, such as "email".
+	ID          string `json:"id" db:"scope"`
+	DisplayName string `json:"display_name" db:"display_name"`
+	Description string `json:"description" db:"description"`
+	// Sensitive scopes are highlighted on the consent screen.
+	Sensitive bool `json:"sensitive" db:"sensitive"`
+	// Default reports whether the entry comes from the catalog shared by
+	// every tenant rather than the tenant's own entries.
+	Default   bool      `json:"default" db:"is_default"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Name returns the display name, or the scope value when none is set.
+func (s Scope) Name() string {
+	if s.DisplayName != "" {
+		return s.DisplayName
+	}
+	return s.ID
+}
+
+// Catalog looks up a tenant's scope catalog. Store implements it.
+type Catalog interface {
+	// List returns the tenant's own entries and the defaults it has not
+	// overridden, ordered by ID.
+	List(ctx context.Context, tenantID string) ([]Scope, error)
+}
+
+// Store defines scope catalog storage operations. Get, GetDefault, Update and
+// Delete return sql.ErrNoRows for unknown scopes.
+type Store interface {
+	Catalog
+	// Get returns the tenant's entry for id, falling back to the default.
+	Get(ctx context.Context, tenantID, id string) (Scope, error)
+	// GetDefault returns the default entry for id.
+	GetDefault(ctx context.Context, id string) (Scope, error)
+	Create(ctx context.Context, tenantID string, scope Scope) (Scope, error)
+	// Update and Delete only change the tenant's own entries.
+	Update(ctx context.Context, tenantID string, scope Scope) (Scope, error)
+	Delete(ctx context.Context, tenantID, id string) error
+	// InUse reports whether any of the tenant's OAuth clients is registered
+	// with the scope.
+	InUse(ctx context.Context, tenantID, id string) (bool, error)
+}
+
+type store struct {
+	db *sqlx.DB
+}
+
+// NewStore creates a new scope catalog store.
+func NewStore(db *sqlx.DB) Store {
+	return &store{db: db}
+}
+
+const scopeColumns = `scope, display_name, description, sensitive, tenant_id IS NULL AS is_default, updated_at`
+
+func (s *store) List(ctx context.Context, tenantID string) ([]Scope, error) {
+	scopes := []Scope{}
+	err := s.db.SelectContext(ctx, &scopes,
+		`SELECT DISTINCT ON (scope) `+scopeColumns+` FROM oauth_scope_catalog
+		WHERE tenant_id = $1 OR tenant_id IS NULL
+		ORDER BY scope, tenant_id NULLS LAST`,
+		tenantID)
+	return scopes, err
+}
+
+func (s *store) Get(ctx context.Context, tenantID, id string) (Scope, error) {
+	var scope Scope
+	err := s.db.GetContext(ctx, &scope,
+		`SELECT `+scopeColumns+` FROM oauth_scope_catalog
+		WHERE (tenant_id = $1 OR tenant_id IS NULL) AND scope = $2
+		ORDER BY tenant_id NULLS LAST LIMIT 1`,
+		tenantID, id)
+	return scope, err
+}
+
+func (s *store) GetDefault(ctx context.Context, id string) (Scope, error) {
+	var scope Scope
+	err := s.db.GetContext(ctx, &scope,
+		`SELECT `+scopeColumns+` FROM oauth_scope_catalog WHERE tenant_id IS NULL AND scope = $1`, id)
+	return scope, err
+}
+
+func (s *store) Create(ctx context.Context, tenantID string, scope Scope) (Scope, error) {
+	var created Scope
+	err := s.db.GetContext(ctx, &created,
+		`INSERT INTO oauth_scope_catalog (tenant_id, scope, display_name, description, sensitive)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+scopeColumns,
+		tenantID, scope.ID, scope.DisplayName, scope.Description, scope.Sensitive)
+	return created, err
+}
+
+func (s *store) Update(ctx context.Context, tenantID string, scope Scope) (Scope, error) {
+	var updated Scope
+	err := s.db.GetContext(ctx, &updated,
+		`UPDATE oauth_scope_catalog
+		SET display_name = $3, description = $4, sensitive = $5, updated_at = NOW()
+		WHERE tenant_id = $1 AND scope = $2
+		RETURNING `+scopeColumns,
+		tenantID, scope.ID, scope.DisplayName, scope.Description, scope.Sensitive)
+	return updated, err
+}
+
+func (s *store) Delete(ctx context.Context, tenantID, id string) error {
+	var deleted string
+	return s.db.GetContext(ctx, &deleted,
+		`DELETE FROM oauth_scope_catalog WHERE tenant_id = $1 AND scope = $2 RETURNING scope`,
+		tenantID, id)
+}
+
+func (s *store) InUse(ctx context.Context, tenantID, id string) (bool, error) {
+	var inUse bool
+	err := s.db.GetContext(ctx, &inUse,
+		`SELECT EXISTS (SELECT 1 FROM oauth_clients WHERE tenant_id = $1 AND $2 = ANY(allowed_scopes))`,
+		tenantID, id)
+	return inUse, err
+}
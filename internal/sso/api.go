@@ -3,6 +3,7 @@ package sso
 import (
 	"net/http"
 
+	"github.com/dhawalhost/wardseal/pkg/apierr"
 	"github.com/dhawalhost/wardseal/pkg/middleware"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -19,28 +20,57 @@ func NewHTTPHandler(svc Service, logger *zap.Logger) *HTTPHandler {
 	return &HTTPHandler{svc: svc, logger: logger}
 }
 
-// RegisterRoutes registers SSO routes.
+// RegisterRoutes registers SSO routes. The providers are served under
+// /sso-providers and, for existing clients, /sso/providers.
 func (h *HTTPHandler) RegisterRoutes(rg *gin.RouterGroup) {
-	sso := rg.Group("/sso/providers")
-	{
-		sso.GET("", h.listProviders)
-		sso.POST("", h.createProvider)
-		sso.GET("/:id", h.getProvider)
-		sso.PUT("/:id", h.updateProvider)
-		sso.DELETE("/:id", h.deleteProvider)
-		sso.POST("/:id/toggle", h.toggleProvider)
+	for _, path := range []string{"/sso-providers", "/sso/providers"} {
+		sso := rg.Group(path)
+		{
+			sso.GET("", h.listProviders)
+			sso.POST("", h.createProvider)
+			sso.GET("/:id", h.getProvider)
+			sso.PUT("/:id", h.updateProvider)
+			sso.DELETE("/:id", h.deleteProvider)
+			sso.POST("/:id/toggle", h.toggleProvider)
+		}
 	}
 }
 
+// providerRequest is a provider as written by clients. Unlike Provider it
+// accepts the client secret, which is never read back.
+type providerRequest struct {
+	Provider
+	OIDCClientSecret *string `json:"oidc_client_secret"`
+}
+
+func (r providerRequest) provider() Provider {
+	p := r.Provider
+	p.OIDCClientSecret = r.OIDCClientSecret
+	return p
+}
+
 func (h *HTTPHandler) tenantID(c *gin.Context) (string, bool) {
 	tenantID, err := middleware.TenantIDFromGinContext(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "tenant id required"})
+		apierr.Abort(c, apierr.Invalid("tenant id required"))
 		return "", false
 	}
 	return tenantID, true
 }
 
+// verifyIssuer checks an OIDC issuer when the request asks for it with
+// ?verify_issuer=true.
+func (h *HTTPHandler) verifyIssuer(c *gin.Context, p Provider) bool {
+	if c.Query("verify_issuer") != "true" || p.Type != ProviderTypeOIDC || p.OIDCIssuerURL == nil {
+		return true
+	}
+	if err := h.svc.VerifyIssuer(c.Request.Context(), *p.OIDCIssuerURL); err != nil {
+		apierr.Abort(c, err)
+		return false
+	}
+	return true
+}
+
 func (h *HTTPHandler) listProviders(c *gin.Context) {
 	tenantID, ok := h.tenantID(c)
 	if !ok {
@@ -55,8 +85,7 @@ func (h *HTTPHandler) listProviders(c *gin.Context) {
 
 	providers, err := h.svc.ListProviders(c.Request.Context(), tenantID, providerType)
 	if err != nil {
-		h.logger.Error("Failed to list providers", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierr.Abort(c, err)
 		return
 	}
 
@@ -69,19 +98,23 @@ func (h *HTTPHandler) createProvider(c *gin.Context) {
 		return
 	}
 
-	var p Provider
-	if err := c.ShouldBindJSON(&p); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var req providerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierr.Abort(c, apierr.Invalid(err.Error()))
+		return
+	}
+	p := req.provider()
+	if !h.verifyIssuer(c, p) {
 		return
 	}
 
 	id, err := h.svc.CreateProvider(c.Request.Context(), tenantID, p)
 	if err != nil {
-		h.logger.Error("Failed to create provider", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierr.Abort(c, err)
 		return
 	}
 
+	h.logger.Info("SSO provider created", zap.String("tenant_id", tenantID), zap.String("provider_id", id))
 	c.JSON(http.StatusCreated, gin.H{"id": id})
 }
 
@@ -94,7 +127,7 @@ func (h *HTTPHandler) getProvider(c *gin.Context) {
 	id := c.Param("id")
 	p, err := h.svc.GetProvider(c.Request.Context(), tenantID, id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "provider not found"})
+		apierr.Abort(c, err)
 		return
 	}
 
@@ -107,16 +140,19 @@ func (h *HTTPHandler) updateProvider(c *gin.Context) {
 		return
 	}
 
-	var p Provider
-	if err := c.ShouldBindJSON(&p); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var req providerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierr.Abort(c, apierr.Invalid(err.Error()))
 		return
 	}
+	p := req.provider()
 	p.ID = c.Param("id")
+	if !h.verifyIssuer(c, p) {
+		return
+	}
 
 	if err := h.svc.UpdateProvider(c.Request.Context(), tenantID, p); err != nil {
-		h.logger.Error("Failed to update provider", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierr.Abort(c, err)
 		return
 	}
 
@@ -131,8 +167,7 @@ func (h *HTTPHandler) deleteProvider(c *gin.Context) {
 
 	id := c.Param("id")
 	if err := h.svc.DeleteProvider(c.Request.Context(), tenantID, id); err != nil {
-		h.logger.Error("Failed to delete provider", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierr.Abort(c, err)
 		return
 	}
 
@@ -149,14 +184,13 @@ func (h *HTTPHandler) toggleProvider(c *gin.Context) {
 		Enabled bool `json:"enabled"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierr.Abort(c, apierr.Invalid(err.Error()))
 		return
 	}
 
 	id := c.Param("id")
 	if err := h.svc.ToggleProvider(c.Request.Context(), tenantID, id, req.Enabled); err != nil {
-		h.logger.Error("Failed to toggle provider", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierr.Abort(c, err)
 		return
 	}
 
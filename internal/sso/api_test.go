@@ -0,0 +1,237 @@
+package sso_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dhawalhost/wardseal/internal/sso"
+	"github.com/dhawalhost/wardseal/pkg/apierr"
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/dhawalhost/wardseal/pkg/secretbox"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const ssoTenant = "33333333-3333-3333-3333-333333333333"
+
+// memoryStore is an in-memory sso.Store.
+type memoryStore struct {
+	providers map[string]sso.Provider
+	nextID    int
+}
+
+func (s *memoryStore) Create(ctx context.Context, p sso.Provider) (string, error) {
+	s.nextID++
+	p.ID = fmt.Sprintf("provider-%d", s.nextID)
+	s.providers[p.ID] = p
+	return p.ID, nil
+}
+
+func (s *memoryStore) Get(ctx context.Context, tenantID, id string) (sso.Provider, error) {
+	p, ok := s.providers[id]
+	if !ok || p.TenantID != tenantID {
+		return sso.Provider{}, sql.ErrNoRows
+	}
+	return p, nil
+}
+
+func (s *memoryStore) GetByName(ctx context.Context, tenantID, name string) (sso.Provider, error) {
+	for _, p := range s.providers {
+		if p.TenantID == tenantID && p.Name == name {
+			return p, nil
+		}
+	}
+	return sso.Provider{}, sql.ErrNoRows
+}
+
+func (s *memoryStore) List(ctx context.Context, tenantID string, providerType *sso.ProviderType) ([]sso.Provider, error) {
+	var out []sso.Provider
+	for _, p := range s.providers {
+		if p.TenantID == tenantID && (providerType == nil || p.Type == *providerType) {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+func (s *memoryStore) Update(ctx context.Context, p sso.Provider) error {
+	if _, ok := s.providers[p.ID]; !ok {
+		return sql.ErrNoRows
+	}
+	s.providers[p.ID] = p
+	return nil
+}
+
+func (s *memoryStore) Delete(ctx context.Context, tenantID, id string) error {
+	delete(s.providers, id)
+	return nil
+}
+
+func newSSORouter(t *testing.T) (*gin.Engine, *memoryStore, *secretbox.Cipher) {
+	t.Helper()
+	cipher, err := secretbox.New(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &memoryStore{providers: make(map[string]sso.Provider)}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(apierr.Handler(zap.NewNop()))
+	api := r.Group("/api/v1")
+	api.Use(middleware.TenantExtractor(middleware.TenantConfig{}))
+	sso.NewHTTPHandler(sso.NewService(store, cipher), zap.NewNop()).RegisterRoutes(api)
+	return r, store, cipher
+}
+
+func doSSORequest(r *gin.Engine, method, path string, body any) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		_ = json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(middleware.DefaultTenantHeader, ssoTenant)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestSSOProviderCRUD(t *testing.T) {
+	r, store, cipher := newSSORouter(t)
+
+	w := doSSORequest(r, http.MethodPost, "/api/v1/sso-providers", map[string]any{
+		"name":               "github",
+		"type":               "oidc",
+		"oidc_issuer_url":    "https://github.example",
+		"oidc_client_id":     "client-1",
+		"oidc_client_secret": "s3cret",
+		"attribute_mappings": map[string]string{"external_id_claim": "id"},
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d: %s", w.Code, w.Body)
+	}
+	var created struct {
+		ID string `json:"id"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &created)
+
+	stored := store.providers[created.ID]
+	if stored.LinkPolicy != sso.LinkPolicyEmail {
+		t.Fatalf("expected default link policy %q, got %q", sso.LinkPolicyEmail, stored.LinkPolicy)
+	}
+	if stored.OIDCClientSecret == nil || *stored.OIDCClientSecret == "s3cret" {
+		t.Fatal("expected the client secret to be stored encrypted")
+	}
+	secret, err := cipher.Decrypt(*stored.OIDCClientSecret, sso.SecretAdditionalData(ssoTenant))
+	if err != nil || secret != "s3cret" {
+		t.Fatalf("expected stored secret to decrypt, got %q, %v", secret, err)
+	}
+
+	w = doSSORequest(r, http.MethodPut, "/api/v1/sso-providers/"+created.ID, map[string]any{
+		"name":            "github",
+		"type":            "oidc",
+		"enabled":         true,
+		"oidc_issuer_url": "https://github.example",
+		"oidc_client_id":  "client-2",
+		"link_policy":     sso.LinkPolicyNone,
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("update: expected 200, got %d: %s", w.Code, w.Body)
+	}
+	stored = store.providers[created.ID]
+	if *stored.OIDCClientID != "client-2" || stored.LinkPolicy != sso.LinkPolicyNone {
+		t.Fatalf("update not applied: %+v", stored)
+	}
+	if secret, _ := cipher.Decrypt(*stored.OIDCClientSecret, sso.SecretAdditionalData(ssoTenant)); secret != "s3cret" {
+		t.Fatalf("expected update without a secret to keep it, got %q", secret)
+	}
+
+	w = doSSORequest(r, http.MethodPut, "/api/v1/sso-providers/"+created.ID, map[string]any{
+		"name": "github", "type": "oidc", "link_policy": "always",
+	})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid link policy: expected 400, got %d", w.Code)
+	}
+
+	w = doSSORequest(r, http.MethodPut, "/api/v1/sso-providers/"+created.ID, map[string]any{
+		"name": "github", "type": "oidc", "oidc_redirect_uris": []string{"/relative/callback"},
+	})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid redirect URI: expected 400, got %d", w.Code)
+	}
+
+	if w = doSSORequest(r, http.MethodDelete, "/api/v1/sso-providers/"+created.ID, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("delete: expected 204, got %d", w.Code)
+	}
+	if w = doSSORequest(r, http.MethodGet, "/api/v1/sso-providers/"+created.ID, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("get after delete: expected 404, got %d", w.Code)
+	}
+}
+
+func TestSSOProviderSecretIsRedacted(t *testing.T) {
+	r, _, _ := newSSORouter(t)
+
+	w := doSSORequest(r, http.MethodPost, "/api/v1/sso-providers", map[string]any{
+		"name":               "okta",
+		"type":               "oidc",
+		"oidc_issuer_url":    "https://okta.example",
+		"oidc_client_id":     "client-1",
+		"oidc_client_secret": "s3cret",
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d: %s", w.Code, w.Body)
+	}
+	var created struct {
+		ID string `json:"id"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &created)
+
+	for _, path := range []string{"/api/v1/sso-providers/" + created.ID, "/api/v1/sso-providers", "/api/v1/sso/providers"} {
+		w = doSSORequest(r, http.MethodGet, path, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: expected 200, got %d", path, w.Code)
+		}
+		body := w.Body.String()
+		if strings.Contains(body, "s3cret") || strings.Contains(body, "enc:v1:") || strings.Contains(body, `"oidc_client_secret"`) {
+			t.Fatalf("GET %s leaked the client secret: %s", path, body)
+		}
+		if !strings.Contains(body, `"oidc_client_secret_set":true`) {
+			t.Fatalf("GET %s: expected oidc_client_secret_set, got %s", path, body)
+		}
+	}
+}
+
+func TestSSOProviderVerifyIssuer(t *testing.T) {
+	issuer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/good/.well-known/openid-configuration" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"issuer":"ok"}`))
+	}))
+	defer issuer.Close()
+
+	r, _, _ := newSSORouter(t)
+	create := func(issuerURL string) int {
+		return doSSORequest(r, http.MethodPost, "/api/v1/sso-providers?verify_issuer=true", map[string]any{
+			"name":            "corp-" + issuerURL[len(issuerURL)-3:],
+			"type":            "oidc",
+			"oidc_issuer_url": issuerURL,
+			"oidc_client_id":  "client-1",
+		}).Code
+	}
+	if code := create(issuer.URL + "/good"); code != http.StatusCreated {
+		t.Fatalf("reachable issuer: expected 201, got %d", code)
+	}
+	if code := create(issuer.URL + "/bad"); code != http.StatusBadRequest {
+		t.Fatalf("issuer without discovery: expected 400, got %d", code)
+	}
+}
@@ -2,7 +2,17 @@ package sso
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dhawalhost/wardseal/pkg/apierr"
+	"github.com/dhawalhost/wardseal/pkg/secretbox"
 )
 
 // Service defines SSO provider service operations.
@@ -13,68 +23,104 @@ type Service interface {
 	UpdateProvider(ctx context.Context, tenantID string, p Provider) error
 	DeleteProvider(ctx context.Context, tenantID, id string) error
 	ToggleProvider(ctx context.Context, tenantID, id string, enabled bool) error
+	// VerifyIssuer checks that an OIDC issuer serves its discovery document.
+	VerifyIssuer(ctx context.Context, issuerURL string) error
 }
 
 type service struct {
-	store Store
+	store      Store
+	secrets    *secretbox.Cipher
+	httpClient *http.Client
 }
 
-// NewService creates a new SSO service.
-func NewService(store Store) Service {
-	return &service{store: store}
+// NewService creates a new SSO service. Client secrets are encrypted with
+// secrets; a nil cipher stores them as plaintext.
+func NewService(store Store, secrets *secretbox.Cipher) Service {
+	return &service{
+		store:      store,
+		secrets:    secrets,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
 }
 
 func (s *service) CreateProvider(ctx context.Context, tenantID string, p Provider) (string, error) {
 	if p.Name == "" {
-		return "", fmt.Errorf("provider name is required")
+		return "", apierr.Invalid("provider name is required")
 	}
 	if p.Type != ProviderTypeOIDC && p.Type != ProviderTypeSAML {
-		return "", fmt.Errorf("invalid provider type: %s", p.Type)
+		return "", apierr.Invalid(fmt.Sprintf("invalid provider type: %s", p.Type))
 	}
 
 	// Validate type-specific fields
 	if p.Type == ProviderTypeOIDC {
 		if p.OIDCIssuerURL == nil || *p.OIDCIssuerURL == "" {
-			return "", fmt.Errorf("OIDC issuer URL is required")
+			return "", apierr.Invalid("OIDC issuer URL is required")
 		}
 		if p.OIDCClientID == nil || *p.OIDCClientID == "" {
-			return "", fmt.Errorf("OIDC client ID is required")
+			return "", apierr.Invalid("OIDC client ID is required")
 		}
 	}
 	if p.Type == ProviderTypeSAML {
 		if p.SAMLEntityID == nil || *p.SAMLEntityID == "" {
-			return "", fmt.Errorf("SAML entity ID is required")
+			return "", apierr.Invalid("SAML entity ID is required")
 		}
 		if p.SAMLSSOURL == nil || *p.SAMLSSOURL == "" {
-			return "", fmt.Errorf("SAML SSO URL is required")
+			return "", apierr.Invalid("SAML SSO URL is required")
 		}
 	}
+	if err := validateCommon(&p); err != nil {
+		return "", err
+	}
 
 	p.TenantID = tenantID
 	p.Enabled = true
+	if err := s.sealSecret(&p); err != nil {
+		return "", err
+	}
 	return s.store.Create(ctx, p)
 }
 
 func (s *service) GetProvider(ctx context.Context, tenantID, id string) (Provider, error) {
-	return s.store.Get(ctx, tenantID, id)
+	p, err := s.store.Get(ctx, tenantID, id)
+	if err != nil {
+		return Provider{}, notFound(err)
+	}
+	return redact(p), nil
 }
 
 func (s *service) ListProviders(ctx context.Context, tenantID string, providerType *ProviderType) ([]Provider, error) {
-	return s.store.List(ctx, tenantID, providerType)
+	providers, err := s.store.List(ctx, tenantID, providerType)
+	if err != nil {
+		return nil, err
+	}
+	for i := range providers {
+		providers[i] = redact(providers[i])
+	}
+	return providers, nil
 }
 
+// UpdateProvider replaces the provider's settings. A request without a
+// client secret, or with an empty one, keeps the stored one.
 func (s *service) UpdateProvider(ctx context.Context, tenantID string, p Provider) error {
 	existing, err := s.store.Get(ctx, tenantID, p.ID)
 	if err != nil {
-		return fmt.Errorf("provider not found: %w", err)
+		return notFound(err)
 	}
 
 	// Can't change type
 	if p.Type != existing.Type {
-		return fmt.Errorf("cannot change provider type")
+		return apierr.Invalid("cannot change provider type")
+	}
+	if err := validateCommon(&p); err != nil {
+		return err
 	}
 
 	p.TenantID = tenantID
+	if p.OIDCClientSecret == nil || *p.OIDCClientSecret == "" {
+		p.OIDCClientSecret = existing.OIDCClientSecret
+	} else if err := s.sealSecret(&p); err != nil {
+		return err
+	}
 	return s.store.Update(ctx, p)
 }
 
@@ -85,8 +131,97 @@ func (s *service) DeleteProvider(ctx context.Context, tenantID, id string) error
 func (s *service) ToggleProvider(ctx context.Context, tenantID, id string, enabled bool) error {
 	p, err := s.store.Get(ctx, tenantID, id)
 	if err != nil {
-		return err
+		return notFound(err)
 	}
 	p.Enabled = enabled
 	return s.store.Update(ctx, p)
 }
+
+func (s *service) VerifyIssuer(ctx context.Context, issuerURL string) error {
+	u, err := url.Parse(issuerURL)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		return apierr.Invalid("OIDC issuer URL must be an http(s) URL")
+	}
+	discovery := strings.TrimRight(issuerURL, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discovery, nil)
+	if err != nil {
+		return apierr.Invalid("OIDC issuer URL is invalid")
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return apierr.Invalid("OIDC issuer is unreachable: " + err.Error())
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return apierr.Invalid(fmt.Sprintf("OIDC issuer discovery returned status %d", resp.StatusCode))
+	}
+	return nil
+}
+
+// sealSecret encrypts the provider's client secret in place.
+func (s *service) sealSecret(p *Provider) error {
+	if p.OIDCClientSecret == nil || *p.OIDCClientSecret == "" {
+		return nil
+	}
+	sealed, err := s.secrets.Encrypt(*p.OIDCClientSecret, SecretAdditionalData(p.TenantID))
+	if err != nil {
+		return fmt.Errorf("encrypt client secret: %w", err)
+	}
+	p.OIDCClientSecret = &sealed
+	return nil
+}
+
+// validateCommon checks the settings shared by all provider types and fills
+// in the default link policy.
+func validateCommon(p *Provider) error {
+	switch p.LinkPolicy {
+	case "":
+		p.LinkPolicy = LinkPolicyEmail
+	case LinkPolicyEmail, LinkPolicyNone:
+	default:
+		return apierr.Invalid(fmt.Sprintf("invalid link policy %q: must be %q or %q", p.LinkPolicy, LinkPolicyEmail, LinkPolicyNone))
+	}
+	if err := validateRedirectURIs(p.OIDCRedirectURIs); err != nil {
+		return err
+	}
+	return validateAttributeMappings(p.AttributeMappings)
+}
+
+// validateRedirectURIs checks that each redirect URI is an absolute http(s)
+// URL without a fragment, as OAuth 2.0 requires.
+func validateRedirectURIs(uris []string) error {
+	for _, raw := range uris {
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || u.Fragment != "" {
+			return apierr.Invalid(fmt.Sprintf("invalid redirect URI %q: must be an absolute http(s) URL without a fragment", raw))
+		}
+	}
+	return nil
+}
+
+// validateAttributeMappings checks that mappings, if set, map names to claim
+// names, such as {"external_id_claim": "id"} for a GitHub-style provider.
+func validateAttributeMappings(mappings json.RawMessage) error {
+	if len(mappings) == 0 || string(mappings) == "null" {
+		return nil
+	}
+	var m map[string]string
+	if err := json.Unmarshal(mappings, &m); err != nil {
+		return apierr.Invalid("attribute mappings must be an object of claim names")
+	}
+	return nil
+}
+
+// redact drops the client secret, recording only whether one is set.
+func redact(p Provider) Provider {
+	p.ClientSecretSet = p.OIDCClientSecret != nil && *p.OIDCClientSecret != ""
+	p.OIDCClientSecret = nil
+	return p
+}
+
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return apierr.NotFound("provider not found")
+	}
+	return err
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 // ProviderType represents the SSO protocol type.
@@ -16,6 +17,23 @@ const (
 	ProviderTypeSAML ProviderType = "saml"
 )
 
+// Link policies decide what happens when a first-time federated login
+// carries the email of an existing local account.
+const (
+	// LinkPolicyEmail links the external identity to that account if the
+	// provider verified the email. It is the default.
+	LinkPolicyEmail = "email"
+	// LinkPolicyNone refuses the login, so an external account cannot take
+	// over a local one by claiming its email.
+	LinkPolicyNone = "none"
+)
+
+// SecretAdditionalData binds an encrypted client secret to its tenant. Auth
+// uses the same value to decrypt it.
+func SecretAdditionalData(tenantID string) string {
+	return "sso_provider:" + tenantID
+}
+
 // Provider represents an SSO identity provider configuration.
 type Provider struct {
 	ID       string       `json:"id" db:"id"`
@@ -28,7 +46,13 @@ type Provider struct {
 	OIDCIssuerURL    *string `json:"oidc_issuer_url,omitempty" db:"oidc_issuer_url"`
 	OIDCClientID     *string `json:"oidc_client_id,omitempty" db:"oidc_client_id"`
 	OIDCClientSecret *string `json:"-" db:"oidc_client_secret"` // Never serialized
-	OIDCScopes       *string `json:"oidc_scopes,omitempty" db:"oidc_scopes"`
+	// ClientSecretSet reports whether a client secret is stored, as the
+	// secret itself is never returned.
+	ClientSecretSet bool    `json:"oidc_client_secret_set" db:"-"`
+	OIDCScopes      *string `json:"oidc_scopes,omitempty" db:"oidc_scopes"`
+	// OIDCRedirectURIs are the redirect URIs, besides this service's own
+	// callback, a social login may ask the provider to send the code to.
+	OIDCRedirectURIs pq.StringArray `json:"oidc_redirect_uris,omitempty" db:"oidc_redirect_uris"`
 
 	// SAML Configuration
 	SAMLEntityID       *string `json:"saml_entity_id,omitempty" db:"saml_entity_id"`
@@ -42,6 +66,7 @@ type Provider struct {
 	AutoCreateUsers   bool            `json:"auto_create_users" db:"auto_create_users"`
 	DefaultRoleID     *string         `json:"default_role_id,omitempty" db:"default_role_id"`
 	AttributeMappings json.RawMessage `json:"attribute_mappings,omitempty" db:"attribute_mappings"`
+	LinkPolicy        string          `json:"link_policy" db:"link_policy"`
 
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
@@ -72,12 +97,12 @@ func (s *store) Create(ctx context.Context, p Provider) (string, error) {
 		`INSERT INTO sso_providers (tenant_id, name, type, enabled, 
 			oidc_issuer_url, oidc_client_id, oidc_client_secret, oidc_scopes,
 			saml_entity_id, saml_sso_url, saml_slo_url, saml_certificate, saml_sign_requests, saml_sign_assertions,
-			auto_create_users, default_role_id, attribute_mappings)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17) RETURNING id`,
+			auto_create_users, default_role_id, attribute_mappings, link_policy, oidc_redirect_uris)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19) RETURNING id`,
 		p.TenantID, p.Name, p.Type, p.Enabled,
 		p.OIDCIssuerURL, p.OIDCClientID, p.OIDCClientSecret, p.OIDCScopes,
 		p.SAMLEntityID, p.SAMLSSOURL, p.SAMLSLOURL, p.SAMLCertificate, p.SAMLSignRequests, p.SAMLSignAssertions,
-		p.AutoCreateUsers, p.DefaultRoleID, p.AttributeMappings,
+		p.AutoCreateUsers, p.DefaultRoleID, p.AttributeMappings, p.LinkPolicy, p.OIDCRedirectURIs,
 	).Scan(&id)
 	return id, err
 }
@@ -118,14 +143,14 @@ func (s *store) Update(ctx context.Context, p Provider) error {
 			oidc_issuer_url = $3, oidc_client_id = $4, oidc_client_secret = $5, oidc_scopes = $6,
 			saml_entity_id = $7, saml_sso_url = $8, saml_slo_url = $9, saml_certificate = $10, 
 			saml_sign_requests = $11, saml_sign_assertions = $12,
-			auto_create_users = $13, default_role_id = $14, attribute_mappings = $15,
-			updated_at = NOW()
-		WHERE id = $16 AND tenant_id = $17`,
+			auto_create_users = $13, default_role_id = $14, attribute_mappings = $15, link_policy = $16,
+			oidc_redirect_uris = $17, updated_at = NOW()
+		WHERE id = $18 AND tenant_id = $19`,
 		p.Name, p.Enabled,
 		p.OIDCIssuerURL, p.OIDCClientID, p.OIDCClientSecret, p.OIDCScopes,
 		p.SAMLEntityID, p.SAMLSSOURL, p.SAMLSLOURL, p.SAMLCertificate, p.SAMLSignRequests, p.SAMLSignAssertions,
-		p.AutoCreateUsers, p.DefaultRoleID, p.AttributeMappings,
-		p.ID, p.TenantID)
+		p.AutoCreateUsers, p.DefaultRoleID, p.AttributeMappings, p.LinkPolicy,
+		p.OIDCRedirectURIs, p.ID, p.TenantID)
 	return err
 }
 
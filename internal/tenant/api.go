@@ -0,0 +1,89 @@
+package tenant
+
+import (
+	"net/http"
+
+	"github.com/dhawalhost/wardseal/pkg/apierr"
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// HTTPHandler handles tenant management HTTP requests.
+type HTTPHandler struct {
+	svc    Service
+	auth   middleware.ServiceAuthConfig
+	logger *zap.Logger
+}
+
+// NewHTTPHandler creates a new tenant HTTP handler. Tenants sit above tenant
+// scoping, so callers authenticate as platform administrators with a service
+// token checked against auth.
+func NewHTTPHandler(svc Service, auth middleware.ServiceAuthConfig, logger *zap.Logger) *HTTPHandler {
+	return &HTTPHandler{svc: svc, auth: auth, logger: logger}
+}
+
+// RegisterRoutes registers the tenant routes under /tenants. rg must not
+// require a tenant header.
+func (h *HTTPHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	tenants := rg.Group("/tenants")
+	tenants.Use(middleware.ServiceAuth(h.auth))
+	{
+		tenants.GET("", h.listTenants)
+		tenants.POST("", h.createTenant)
+		tenants.GET("/:id", h.getTenant)
+		tenants.PUT("/:id", h.updateTenant)
+	}
+}
+
+func (h *HTTPHandler) listTenants(c *gin.Context) {
+	tenants, err := h.svc.ListTenants(c.Request.Context())
+	if err != nil {
+		apierr.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tenants": tenants})
+}
+
+func (h *HTTPHandler) createTenant(c *gin.Context) {
+	var req CreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierr.Abort(c, apierr.Validation(err))
+		return
+	}
+
+	t, err := h.svc.CreateTenant(c.Request.Context(), req)
+	if err != nil {
+		apierr.Abort(c, err)
+		return
+	}
+
+	h.logger.Info("Tenant created", zap.String("tenant_id", t.ID))
+	c.JSON(http.StatusCreated, t)
+}
+
+func (h *HTTPHandler) getTenant(c *gin.Context) {
+	t, err := h.svc.GetTenant(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		apierr.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+func (h *HTTPHandler) updateTenant(c *gin.Context) {
+	var req UpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierr.Abort(c, apierr.Validation(err))
+		return
+	}
+
+	t, err := h.svc.UpdateTenant(c.Request.Context(), c.Param("id"), req)
+	if err != nil {
+		apierr.Abort(c, err)
+		return
+	}
+
+	h.logger.Info("Tenant updated", zap.String("tenant_id", t.ID), zap.String("status", t.Status))
+	c.JSON(http.StatusOK, t)
+}
@@ -0,0 +1,233 @@
+package tenant_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dhawalhost/wardseal/internal/tenant"
+	"github.com/dhawalhost/wardseal/pkg/apierr"
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const adminToken = "platform-admin-token"
+
+// memoryStore is an in-memory tenant.Store.
+type memoryStore struct {
+	tenants map[string]tenant.Tenant
+	nextID  int
+}
+
+func (s *memoryStore) Create(ctx context.Context, t tenant.Tenant) (tenant.Tenant, error) {
+	if t.ID == "" {
+		s.nextID++
+		t.ID = fmt.Sprintf("00000000-0000-0000-0000-%012d", s.nextID)
+	}
+	t.CreatedAt = time.Now()
+	t.UpdatedAt = t.CreatedAt
+	s.tenants[t.ID] = t
+	return t, nil
+}
+
+func (s *memoryStore) Get(ctx context.Context, id string) (tenant.Tenant, error) {
+	t, ok := s.tenants[id]
+	if !ok {
+		return tenant.Tenant{}, sql.ErrNoRows
+	}
+	return t, nil
+}
+
+func (s *memoryStore) List(ctx context.Context) ([]tenant.Tenant, error) {
+	var out []tenant.Tenant
+	for _, t := range s.tenants {
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+func (s *memoryStore) Update(ctx context.Context, t tenant.Tenant) (tenant.Tenant, error) {
+	if _, ok := s.tenants[t.ID]; !ok {
+		return tenant.Tenant{}, sql.ErrNoRows
+	}
+	t.UpdatedAt = time.Now()
+	s.tenants[t.ID] = t
+	return t, nil
+}
+
+// recordingSeeder records the tenants whose default roles were seeded.
+type recordingSeeder struct {
+	seeded []string
+}
+
+func (s *recordingSeeder) SeedDefaultRoles(ctx context.Context, tenantID string) error {
+	s.seeded = append(s.seeded, tenantID)
+	return nil
+}
+
+// newTenantRouter serves the tenant API and a tenant-scoped /api/v1/ping
+// route guarded by the tenant lookup.
+func newTenantRouter(t *testing.T) (*gin.Engine, *memoryStore, *recordingSeeder) {
+	t.Helper()
+	store := &memoryStore{tenants: make(map[string]tenant.Tenant)}
+	seeder := &recordingSeeder{}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(apierr.Handler(zap.NewNop()))
+	svc := tenant.NewService(store, seeder)
+	tenant.NewHTTPHandler(svc, middleware.ServiceAuthConfig{Token: adminToken}, zap.NewNop()).RegisterRoutes(r.Group("/api/v1"))
+
+	scoped := r.Group("/api/v1")
+	scoped.Use(middleware.TenantExtractor(middleware.TenantConfig{
+		Lookup:    tenant.Lookup(store),
+		LookupTTL: time.Nanosecond,
+	}))
+	scoped.GET("/ping", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	return r, store, seeder
+}
+
+func doTenantRequest(r *gin.Engine, method, path, token string, body any) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		_ = json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set(middleware.DefaultServiceAuthHeader, token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCreateTenant(t *testing.T) {
+	r, store, seeder := newTenantRouter(t)
+
+	if w := doTenantRequest(r, http.MethodPost, "/api/v1/tenants", "", map[string]any{"name": "Acme"}); w.Code != http.StatusUnauthorized {
+		t.Fatalf("create without admin token: expected 401, got %d", w.Code)
+	}
+	if w := doTenantRequest(r, http.MethodPost, "/api/v1/tenants", "wrong", map[string]any{"name": "Acme"}); w.Code != http.StatusUnauthorized {
+		t.Fatalf("create with a wrong token: expected 401, got %d", w.Code)
+	}
+	if w := doTenantRequest(r, http.MethodPost, "/api/v1/tenants", adminToken, map[string]any{"name": " "}); w.Code != http.StatusBadRequest {
+		t.Fatalf("create without a name: expected 400, got %d", w.Code)
+	}
+	w := doTenantRequest(r, http.MethodPost, "/api/v1/tenants", adminToken, map[string]any{"name": 5})
+	var invalid struct {
+		Fields []apierr.FieldError `json:"fields"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &invalid)
+	want := apierr.FieldError{Field: "name", Rule: "type", Message: "name must be a string"}
+	if w.Code != http.StatusBadRequest || len(invalid.Fields) != 1 || invalid.Fields[0] != want {
+		t.Fatalf("create with a numeric name: expected 400 with %+v, got %d %s", want, w.Code, w.Body)
+	}
+
+	w = doTenantRequest(r, http.MethodPost, "/api/v1/tenants", adminToken, map[string]any{"name": "Acme"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d: %s", w.Code, w.Body)
+	}
+	var created tenant.Tenant
+	_ = json.Unmarshal(w.Body.Bytes(), &created)
+	if created.ID == "" || created.Name != "Acme" || created.Status != tenant.StatusActive {
+		t.Fatalf("unexpected tenant %+v", created)
+	}
+	if _, ok := store.tenants[created.ID]; !ok {
+		t.Fatalf("expected the tenant to be stored")
+	}
+	if len(seeder.seeded) != 1 || seeder.seeded[0] != created.ID {
+		t.Fatalf("expected default roles to be seeded for %s, got %v", created.ID, seeder.seeded)
+	}
+
+	// An existing tenant can be registered under its ID, but only once.
+	existing := map[string]any{"id": "11111111-1111-1111-1111-111111111111", "name": "Signed up"}
+	if w := doTenantRequest(r, http.MethodPost, "/api/v1/tenants", adminToken, existing); w.Code != http.StatusCreated {
+		t.Fatalf("create with id: expected 201, got %d: %s", w.Code, w.Body)
+	}
+	if w := doTenantRequest(r, http.MethodPost, "/api/v1/tenants", adminToken, existing); w.Code != http.StatusConflict {
+		t.Fatalf("create with a taken id: expected 409, got %d", w.Code)
+	}
+}
+
+func TestSuspendedTenantIsRejected(t *testing.T) {
+	r, _, _ := newTenantRouter(t)
+
+	w := doTenantRequest(r, http.MethodPost, "/api/v1/tenants", adminToken, map[string]any{"name": "Acme"})
+	var created tenant.Tenant
+	_ = json.Unmarshal(w.Body.Bytes(), &created)
+
+	ping := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/ping", nil)
+		req.Header.Set(middleware.DefaultTenantHeader, created.ID)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := ping(); code != http.StatusNoContent {
+		t.Fatalf("active tenant: expected 204, got %d", code)
+	}
+
+	w = doTenantRequest(r, http.MethodPut, "/api/v1/tenants/"+created.ID, adminToken, map[string]any{"status": tenant.StatusSuspended})
+	if w.Code != http.StatusOK {
+		t.Fatalf("suspend: expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if code := ping(); code != http.StatusForbidden {
+		t.Fatalf("suspended tenant: expected 403, got %d", code)
+	}
+
+	w = doTenantRequest(r, http.MethodPut, "/api/v1/tenants/"+created.ID, adminToken, map[string]any{"status": tenant.StatusActive})
+	if w.Code != http.StatusOK {
+		t.Fatalf("reactivate: expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if code := ping(); code != http.StatusNoContent {
+		t.Fatalf("reactivated tenant: expected 204, got %d", code)
+	}
+
+	if w := doTenantRequest(r, http.MethodPut, "/api/v1/tenants/"+created.ID, adminToken, map[string]any{"status": "deleted"}); w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid status: expected 400, got %d", w.Code)
+	}
+}
+
+func TestGetTenant(t *testing.T) {
+	r, _, _ := newTenantRouter(t)
+
+	w := doTenantRequest(r, http.MethodPost, "/api/v1/tenants", adminToken, map[string]any{"name": "Acme"})
+	var created tenant.Tenant
+	_ = json.Unmarshal(w.Body.Bytes(), &created)
+
+	w = doTenantRequest(r, http.MethodGet, "/api/v1/tenants/"+created.ID, adminToken, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("get: expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var got tenant.Tenant
+	_ = json.Unmarshal(w.Body.Bytes(), &got)
+	if got.ID != created.ID || got.Name != "Acme" || got.Status != tenant.StatusActive {
+		t.Fatalf("unexpected tenant %+v", got)
+	}
+
+	if w := doTenantRequest(r, http.MethodGet, "/api/v1/tenants/"+created.ID, "", nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("get without admin token: expected 401, got %d", w.Code)
+	}
+	if w := doTenantRequest(r, http.MethodGet, "/api/v1/tenants/22222222-2222-2222-2222-222222222222", adminToken, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("get unknown tenant: expected 404, got %d", w.Code)
+	}
+	if w := doTenantRequest(r, http.MethodGet, "/api/v1/tenants/not-a-uuid", adminToken, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("get malformed id: expected 404, got %d", w.Code)
+	}
+
+	w = doTenantRequest(r, http.MethodGet, "/api/v1/tenants", adminToken, nil)
+	var list struct {
+		Tenants []tenant.Tenant `json:"tenants"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || w.Code != http.StatusOK || len(list.Tenants) != 1 {
+		t.Fatalf("list: expected one tenant, got %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,153 @@
+package tenant
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dhawalhost/wardseal/pkg/apierr"
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/google/uuid"
+)
+
+// RoleSeeder creates a new tenant's default roles. rbac.Service implements it.
+type RoleSeeder interface {
+	SeedDefaultRoles(ctx context.Context, tenantID string) error
+}
+
+// CreateRequest creates a tenant. ID is generated when empty, and may be set
+// to register a tenant that already has data, such as one created by sign-up.
+type CreateRequest struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// UpdateRequest changes a tenant. Nil fields are left unchanged.
+type UpdateRequest struct {
+	Name   *string `json:"name"`
+	Status *string `json:"status"`
+}
+
+// Service defines tenant management operations.
+type Service interface {
+	// CreateTenant creates an active tenant and seeds its default roles.
+	CreateTenant(ctx context.Context, req CreateRequest) (Tenant, error)
+	GetTenant(ctx context.Context, id string) (Tenant, error)
+	ListTenants(ctx context.Context) ([]Tenant, error)
+	// UpdateTenant renames, suspends or reactivates a tenant.
+	UpdateTenant(ctx context.Context, id string, req UpdateRequest) (Tenant, error)
+}
+
+type service struct {
+	store Store
+	roles RoleSeeder
+}
+
+// NewService creates a new tenant service. A nil roles skips role seeding.
+func NewService(store Store, roles RoleSeeder) Service {
+	return &service{store: store, roles: roles}
+}
+
+func (s *service) CreateTenant(ctx context.Context, req CreateRequest) (Tenant, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return Tenant{}, apierr.Invalid("tenant name is required")
+	}
+	if req.ID != "" {
+		if _, err := uuid.Parse(req.ID); err != nil {
+			return Tenant{}, apierr.Invalid("tenant id must be a UUID")
+		}
+		if _, err := s.store.Get(ctx, req.ID); err == nil {
+			return Tenant{}, apierr.Conflict("tenant already exists")
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return Tenant{}, err
+		}
+	}
+
+	t, err := s.store.Create(ctx, Tenant{ID: req.ID, Name: name, Status: StatusActive})
+	if err != nil {
+		return Tenant{}, err
+	}
+	// The tenant is kept when seeding fails; seeding is idempotent and can be
+	// rerun with POST /api/v1/roles/defaults.
+	if s.roles != nil {
+		if err := s.roles.SeedDefaultRoles(ctx, t.ID); err != nil {
+			return Tenant{}, fmt.Errorf("seed default roles for tenant %s: %w", t.ID, err)
+		}
+	}
+	return t, nil
+}
+
+func (s *service) GetTenant(ctx context.Context, id string) (Tenant, error) {
+	return s.get(ctx, id)
+}
+
+// get loads a tenant, reporting malformed IDs as not found rather than
+// passing them to the database.
+func (s *service) get(ctx context.Context, id string) (Tenant, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return Tenant{}, apierr.NotFound("tenant not found")
+	}
+	t, err := s.store.Get(ctx, id)
+	if err != nil {
+		return Tenant{}, notFound(err)
+	}
+	return t, nil
+}
+
+func (s *service) ListTenants(ctx context.Context) ([]Tenant, error) {
+	return s.store.List(ctx)
+}
+
+func (s *service) UpdateTenant(ctx context.Context, id string, req UpdateRequest) (Tenant, error) {
+	t, err := s.get(ctx, id)
+	if err != nil {
+		return Tenant{}, err
+	}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return Tenant{}, apierr.Invalid("tenant name is required")
+		}
+		t.Name = name
+	}
+	if req.Status != nil {
+		if *req.Status != StatusActive && *req.Status != StatusSuspended {
+			return Tenant{}, apierr.Invalid(fmt.Sprintf("invalid tenant status: %s", *req.Status))
+		}
+		t.Status = *req.Status
+	}
+	updated, err := s.store.Update(ctx, t)
+	if err != nil {
+		return Tenant{}, notFound(err)
+	}
+	return updated, nil
+}
+
+// Lookup reports tenant status to middleware.TenantExtractor so suspended
+// tenants are rejected. Tenants without a record, which predate the tenant
+// API, are reported active so enabling the lookup does not lock them out.
+func Lookup(store Store) middleware.TenantLookup {
+	return func(ctx context.Context, tenantID string) (middleware.TenantStatus, error) {
+		t, err := store.Get(ctx, tenantID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return middleware.TenantActive, nil
+		}
+		if err != nil {
+			return middleware.TenantNotFound, err
+		}
+		if t.Status != StatusActive {
+			return middleware.TenantInactive, nil
+		}
+		return middleware.TenantActive, nil
+	}
+}
+
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return apierr.NotFound("tenant not found")
+	}
+	return err
+}
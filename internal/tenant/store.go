@@ -0,0 +1,76 @@
+package tenant
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Tenant statuses.
+const (
+	StatusActive    = "active"
+	StatusSuspended = "suspended"
+)
+
+// Tenant is an isolated customer of the platform.
+type Tenant struct {
+	ID        string    `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	Status    string    `json:"status" db:"status"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Store defines tenant storage operations. Get and Update return
+// sql.ErrNoRows for unknown tenants.
+type Store interface {
+	// Create inserts the tenant, generating its ID when empty, and returns
+	// it as stored.
+	Create(ctx context.Context, t Tenant) (Tenant, error)
+	Get(ctx context.Context, id string) (Tenant, error)
+	List(ctx context.Context) ([]Tenant, error)
+	Update(ctx context.Context, t Tenant) (Tenant, error)
+}
+
+type store struct {
+	db *sqlx.DB
+}
+
+// NewStore creates a new tenant store.
+func NewStore(db *sqlx.DB) Store {
+	return &store{db: db}
+}
+
+func (s *store) Create(ctx context.Context, t Tenant) (Tenant, error) {
+	var created Tenant
+	err := s.db.GetContext(ctx, &created,
+		`INSERT INTO tenants (id, name, status)
+		VALUES (COALESCE(NULLIF($1, '')::uuid, gen_random_uuid()), $2, $3)
+		RETURNING id, name, status, created_at, updated_at`,
+		t.ID, t.Name, t.Status)
+	return created, err
+}
+
+func (s *store) Get(ctx context.Context, id string) (Tenant, error) {
+	var t Tenant
+	err := s.db.GetContext(ctx, &t,
+		`SELECT id, name, status, created_at, updated_at FROM tenants WHERE id = $1`, id)
+	return t, err
+}
+
+func (s *store) List(ctx context.Context) ([]Tenant, error) {
+	tenants := []Tenant{}
+	err := s.db.SelectContext(ctx, &tenants,
+		`SELECT id, name, status, created_at, updated_at FROM tenants ORDER BY created_at, id`)
+	return tenants, err
+}
+
+func (s *store) Update(ctx context.Context, t Tenant) (Tenant, error) {
+	var updated Tenant
+	err := s.db.GetContext(ctx, &updated,
+		`UPDATE tenants SET name = $2, status = $3, updated_at = NOW() WHERE id = $1
+		RETURNING id, name, status, created_at, updated_at`,
+		t.ID, t.Name, t.Status)
+	return updated, err
+}
@@ -0,0 +1,12 @@
+-- Outstanding SAML AuthnRequests issued when acting as a service provider.
+-- Responses posted to the ACS must answer one of these, and each may be consumed once.
+CREATE TABLE IF NOT EXISTS saml_requests (
+    id TEXT PRIMARY KEY,
+    tenant_id UUID NOT NULL,
+    provider VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    consumed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_saml_requests_expires ON saml_requests(expires_at);
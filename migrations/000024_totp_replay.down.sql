@@ -0,0 +1,2 @@
+ALTER TABLE totp_secrets DROP COLUMN IF EXISTS last_used_step;
+ALTER TABLE totp_secrets ALTER COLUMN secret TYPE VARCHAR(64);
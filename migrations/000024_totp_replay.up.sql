@@ -0,0 +1,4 @@
+-- Encrypted TOTP secrets no longer fit in 64 characters, and the last accepted
+-- time step is tracked so a code cannot be used twice.
+ALTER TABLE totp_secrets ALTER COLUMN secret TYPE TEXT;
+ALTER TABLE totp_secrets ADD COLUMN IF NOT EXISTS last_used_step BIGINT;
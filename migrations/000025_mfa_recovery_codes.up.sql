@@ -0,0 +1,11 @@
+-- One-time MFA recovery codes. Only bcrypt hashes are stored.
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL,
+    identity_id VARCHAR(255) NOT NULL,
+    code_hash TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    used_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_mfa_recovery_codes_identity ON mfa_recovery_codes(tenant_id, identity_id);
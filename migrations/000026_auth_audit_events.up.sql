@@ -0,0 +1,17 @@
+-- Authentication audit trail (logins, token issuance, introspection, revocation)
+-- for SIEM ingestion. Tokens and credentials are never stored.
+CREATE TABLE IF NOT EXISTS auth_audit_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    subject VARCHAR(255),
+    client_id VARCHAR(255),
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    outcome VARCHAR(20) NOT NULL,
+    reason VARCHAR(100),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL
+);
+
+CREATE INDEX idx_auth_audit_events_tenant_time ON auth_audit_events(tenant_id, created_at DESC);
+CREATE INDEX idx_auth_audit_events_subject ON auth_audit_events(tenant_id, subject, created_at DESC);
@@ -0,0 +1,2 @@
+-- Opt-in binding of issued access tokens to the caller's IP subnet and user agent.
+ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS bind_tokens BOOLEAN NOT NULL DEFAULT FALSE;
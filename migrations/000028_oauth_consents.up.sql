@@ -0,0 +1,14 @@
+-- First-party clients skip the consent screen.
+ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS first_party BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Scopes a user has granted to a third-party client.
+CREATE TABLE IF NOT EXISTS oauth_consents (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    client_id VARCHAR(255) NOT NULL,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE (tenant_id, subject, client_id)
+);
@@ -0,0 +1,17 @@
+-- Pending RFC 8628 device authorizations, polled by devices until the user
+-- approves or denies the user code or it expires.
+CREATE TABLE IF NOT EXISTS device_authorizations (
+    device_code VARCHAR(255) PRIMARY KEY,
+    user_code VARCHAR(16) NOT NULL UNIQUE,
+    tenant_id UUID NOT NULL,
+    client_id VARCHAR(255) NOT NULL,
+    scope TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    subject VARCHAR(255),
+    interval_seconds INT NOT NULL DEFAULT 5,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_polled_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL
+);
+
+CREATE INDEX idx_device_authorizations_expires ON device_authorizations(expires_at);
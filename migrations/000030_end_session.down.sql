@@ -0,0 +1,3 @@
+ALTER TABLE oauth_clients DROP COLUMN IF EXISTS post_logout_redirect_uris;
+DROP INDEX IF EXISTS idx_refresh_tokens_family;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS family_id;
@@ -0,0 +1,6 @@
+-- RP-initiated logout: refresh tokens are grouped into families that share a
+-- session id (sid), and clients register where logout may redirect.
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS family_id VARCHAR(64);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family ON refresh_tokens(family_id);
+
+ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS post_logout_redirect_uris TEXT[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS email_verification_policies;
+DROP TABLE IF EXISTS email_verifications;
+DROP TABLE IF EXISTS email_verification_tokens;
@@ -0,0 +1,28 @@
+-- Single-use email verification links. The id is the "jti" of the signed
+-- token sent to the user; used_at is set when the link is redeemed.
+CREATE TABLE IF NOT EXISTS email_verification_tokens (
+    id VARCHAR(64) PRIMARY KEY,
+    tenant_id UUID NOT NULL,
+    user_id VARCHAR(255) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL
+);
+
+CREATE INDEX idx_email_verification_tokens_expires ON email_verification_tokens(expires_at);
+
+-- Accounts whose email address has been verified.
+CREATE TABLE IF NOT EXISTS email_verifications (
+    tenant_id UUID NOT NULL,
+    user_id VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    verified_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+    PRIMARY KEY (tenant_id, user_id)
+);
+
+-- Per-tenant policy blocking password login until the email is verified.
+CREATE TABLE IF NOT EXISTS email_verification_policies (
+    tenant_id UUID PRIMARY KEY,
+    require_verified_login BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
@@ -0,0 +1,27 @@
+-- Single-use password reset links. The id is the "jti" of the signed token
+-- sent to the user; used_at is set when the link is redeemed.
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id VARCHAR(64) PRIMARY KEY,
+    tenant_id UUID NOT NULL,
+    user_id VARCHAR(255) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL
+);
+
+CREATE INDEX idx_password_reset_tokens_expires ON password_reset_tokens(expires_at);
+
+-- Security events (CAE signals). A password reset records a
+-- "password-changed" event that deactivates tokens issued before it.
+CREATE TABLE IF NOT EXISTS security_events (
+    id UUID PRIMARY KEY,
+    tenant_id UUID NOT NULL,
+    subject_id VARCHAR(255) NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    event_time TIMESTAMP WITH TIME ZONE NOT NULL,
+    jti VARCHAR(255),
+    reason TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_security_events_subject_time ON security_events(subject_id, event_time);
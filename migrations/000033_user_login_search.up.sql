@@ -0,0 +1,4 @@
+-- Trigram index so substring searches on login (SearchUsers) avoid a full scan.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_accounts_login_trgm ON accounts USING GIN (lower(login) gin_trgm_ops);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS connector_sync_links;
+DROP TABLE IF EXISTS connector_sync_state;
@@ -0,0 +1,18 @@
+-- Inbound sync watermarks, one row per source connector.
+CREATE TABLE IF NOT EXISTS connector_sync_state (
+    connector_id UUID PRIMARY KEY REFERENCES connectors(id) ON DELETE CASCADE,
+    tenant_id UUID NOT NULL,
+    user_cursor TEXT NOT NULL DEFAULT '',
+    group_cursor TEXT NOT NULL DEFAULT '',
+    last_synced_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_error TEXT NOT NULL DEFAULT ''
+);
+
+-- Which directory user or group each external object was synced to.
+CREATE TABLE IF NOT EXISTS connector_sync_links (
+    connector_id UUID NOT NULL REFERENCES connectors(id) ON DELETE CASCADE,
+    resource_type VARCHAR(20) NOT NULL, -- user, group
+    external_id VARCHAR(255) NOT NULL,
+    internal_id UUID NOT NULL,
+    PRIMARY KEY (connector_id, resource_type, external_id)
+);
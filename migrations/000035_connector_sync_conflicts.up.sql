@@ -0,0 +1,17 @@
+-- Attributes that differed between a source connector and the directory
+-- during inbound sync, and how the connector's conflict policy resolved them.
+CREATE TABLE IF NOT EXISTS connector_sync_conflicts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    connector_id UUID NOT NULL REFERENCES connectors(id) ON DELETE CASCADE,
+    tenant_id UUID NOT NULL,
+    external_id VARCHAR(255) NOT NULL,
+    internal_id UUID NOT NULL,
+    attribute VARCHAR(50) NOT NULL,
+    source_value TEXT NOT NULL,
+    directory_value TEXT NOT NULL,
+    policy VARCHAR(20) NOT NULL,
+    resolution VARCHAR(20) NOT NULL, -- source, directory
+    detected_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_connector_sync_conflicts_connector ON connector_sync_conflicts(connector_id, detected_at DESC);
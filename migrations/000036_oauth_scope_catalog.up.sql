@@ -0,0 +1,23 @@
+-- Scopes an OAuth client may be registered with. Rows with a NULL tenant_id
+-- form the default catalog shared by every tenant.
+CREATE TABLE IF NOT EXISTS oauth_scope_catalog (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID,
+    scope VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_oauth_scope_catalog_tenant_scope
+    ON oauth_scope_catalog(COALESCE(tenant_id, '00000000-0000-0000-0000-000000000000'::uuid), scope);
+
+INSERT INTO oauth_scope_catalog (tenant_id, scope, description) VALUES
+    (NULL, 'openid', 'Sign in with OpenID Connect'),
+    (NULL, 'profile', 'Basic profile information'),
+    (NULL, 'email', 'Email address')
+ON CONFLICT DO NOTHING;
+
+-- Keep existing registrations valid by cataloguing the scopes they already use.
+INSERT INTO oauth_scope_catalog (tenant_id, scope)
+SELECT DISTINCT tenant_id, unnest(allowed_scopes) FROM oauth_clients
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,2 @@
+-- Incremented on every update so concurrent edits can be detected.
+ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,14 @@
+-- Attribute-based rules evaluated by the policy service. Rows with a NULL
+-- tenant_id apply to every tenant. The target, conditions and obligations
+-- are stored as JSON in definition.
+CREATE TABLE IF NOT EXISTS policy_rules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID,
+    description TEXT NOT NULL DEFAULT '',
+    effect VARCHAR(10) NOT NULL CHECK (effect IN ('allow', 'deny')),
+    definition JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_policy_rules_tenant ON policy_rules(tenant_id);
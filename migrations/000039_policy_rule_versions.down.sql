@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS policy_rule_versions;
+ALTER TABLE policy_rules DROP COLUMN IF EXISTS updated_by;
+ALTER TABLE policy_rules DROP COLUMN IF EXISTS version;
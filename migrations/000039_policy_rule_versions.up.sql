@@ -0,0 +1,20 @@
+-- Every revision of a policy rule. policy_rules holds the latest revision,
+-- which is the one evaluated; rollbacks add a new revision.
+ALTER TABLE policy_rules ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE policy_rules ADD COLUMN IF NOT EXISTS updated_by TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS policy_rule_versions (
+    rule_id UUID NOT NULL REFERENCES policy_rules(id) ON DELETE CASCADE,
+    tenant_id UUID,
+    version INTEGER NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    effect VARCHAR(10) NOT NULL,
+    definition JSONB NOT NULL DEFAULT '{}',
+    author TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (rule_id, version)
+);
+
+INSERT INTO policy_rule_versions (rule_id, tenant_id, version, description, effect, definition, author, created_at)
+SELECT id, tenant_id, version, description, effect, definition, updated_by, updated_at FROM policy_rules
+ON CONFLICT DO NOTHING;
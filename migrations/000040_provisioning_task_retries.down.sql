@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_tasks_failed;
+ALTER TABLE provisioning_tasks DROP COLUMN IF EXISTS manual_retries;
@@ -0,0 +1,5 @@
+-- Manual retries of failed provisioning tasks. retry_count is reset on each
+-- manual retry so automatic retries apply again; this counts the resets.
+ALTER TABLE provisioning_tasks ADD COLUMN IF NOT EXISTS manual_retries INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_tasks_failed ON provisioning_tasks(tenant_id, processed_at) WHERE status = 'failed';
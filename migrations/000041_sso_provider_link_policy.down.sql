@@ -0,0 +1 @@
+ALTER TABLE sso_providers DROP COLUMN IF EXISTS link_policy;
@@ -0,0 +1,3 @@
+-- How a first-time federated login is linked to an existing local account
+-- with the same email: 'email' links it, 'none' refuses the login.
+ALTER TABLE sso_providers ADD COLUMN IF NOT EXISTS link_policy VARCHAR(20) NOT NULL DEFAULT 'email';
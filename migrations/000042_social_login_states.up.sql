@@ -0,0 +1,15 @@
+-- Outstanding social login starts. Each state is consumed by the callback
+-- that presents it, binding the upstream response to the browser that began
+-- the login.
+CREATE TABLE IF NOT EXISTS social_login_states (
+    state VARCHAR(128) PRIMARY KEY,
+    tenant_id UUID NOT NULL,
+    provider VARCHAR(255) NOT NULL,
+    nonce VARCHAR(128) NOT NULL,
+    code_verifier VARCHAR(128) NOT NULL,
+    redirect_uri TEXT NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL
+);
+
+CREATE INDEX idx_social_login_states_expires ON social_login_states(expires_at);
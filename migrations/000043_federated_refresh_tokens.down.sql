@@ -0,0 +1 @@
+ALTER TABLE federated_identities DROP COLUMN IF EXISTS refresh_token;
@@ -0,0 +1,3 @@
+-- The provider's refresh token, encrypted, kept when the user granted
+-- offline_access so the provider's API can be called after login.
+ALTER TABLE federated_identities ADD COLUMN IF NOT EXISTS refresh_token TEXT;
@@ -0,0 +1,12 @@
+-- Roles granted to every member of a group. Members inherit them alongside
+-- the roles assigned to them directly in user_roles.
+CREATE TABLE IF NOT EXISTS group_roles (
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    role_id UUID NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    tenant_id UUID NOT NULL,
+    assigned_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    assigned_by UUID,
+    PRIMARY KEY (group_id, role_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_group_roles_role ON group_roles(role_id);
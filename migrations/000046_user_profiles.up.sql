@@ -0,0 +1,14 @@
+-- Directory profile attributes, one row per identity. Users created before
+-- this migration have no row; reads treat a missing row as an empty profile.
+CREATE TABLE IF NOT EXISTS user_profiles (
+    identity_id UUID PRIMARY KEY REFERENCES identities(id) ON DELETE CASCADE,
+    tenant_id UUID NOT NULL,
+    phone VARCHAR(64),
+    title VARCHAR(255),
+    department VARCHAR(255),
+    manager_id UUID REFERENCES identities(id) ON DELETE SET NULL,
+    location VARCHAR(255),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_profiles_manager ON user_profiles(manager_id);
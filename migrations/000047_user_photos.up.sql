@@ -0,0 +1,9 @@
+-- Profile photos, one per identity. The bytes live in the database; the
+-- directory service caps their size.
+CREATE TABLE IF NOT EXISTS user_photos (
+    identity_id UUID PRIMARY KEY REFERENCES identities(id) ON DELETE CASCADE,
+    tenant_id UUID NOT NULL,
+    content_type VARCHAR(64) NOT NULL,
+    data BYTEA NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_accounts_tenant_last_login;
+ALTER TABLE accounts DROP COLUMN IF EXISTS last_login_at;
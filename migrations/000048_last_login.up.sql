@@ -0,0 +1,5 @@
+-- When each account last signed in, to find dormant accounts. Writes are
+-- throttled, so the value may lag by up to the directory's record interval.
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS last_login_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_accounts_tenant_last_login ON accounts(tenant_id, last_login_at);
@@ -0,0 +1,2 @@
+ALTER TABLE authorization_codes DROP COLUMN IF EXISTS subject;
+DROP TABLE IF EXISTS auth_sessions;
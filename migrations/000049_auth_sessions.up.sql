@@ -0,0 +1,18 @@
+-- Signed-in sessions, one per refresh token family. The id is the family_id
+-- of the family's refresh tokens and the sid of its ID tokens.
+CREATE TABLE IF NOT EXISTS auth_sessions (
+    id VARCHAR(64) PRIMARY KEY,
+    tenant_id UUID NOT NULL,
+    subject VARCHAR(255) NOT NULL DEFAULT '',
+    client_id VARCHAR(255) NOT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip_address VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_auth_sessions_tenant_subject ON auth_sessions(tenant_id, subject, last_seen_at);
+
+-- The user who approved an authorization code, so the session it starts can
+-- be attributed to them.
+ALTER TABLE authorization_codes ADD COLUMN IF NOT EXISTS subject VARCHAR(255) NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+ALTER TABLE auth_audit_events DROP COLUMN IF EXISTS actor;
+DROP TABLE IF EXISTS impersonation_policies;
@@ -0,0 +1,11 @@
+-- Per-tenant impersonation policy. Tenants without a row allow administrators
+-- to impersonate users with the default token lifetime.
+CREATE TABLE IF NOT EXISTS impersonation_policies (
+    tenant_id UUID PRIMARY KEY,
+    disabled BOOLEAN NOT NULL DEFAULT FALSE,
+    max_minutes INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- The administrator acting as the subject, recorded on impersonation events.
+ALTER TABLE auth_audit_events ADD COLUMN IF NOT EXISTS actor VARCHAR(255);
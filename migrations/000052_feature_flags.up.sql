@@ -0,0 +1,9 @@
+-- Per-tenant feature flags. Features without a row follow the license: on
+-- when the license includes them, or when no license is required.
+CREATE TABLE IF NOT EXISTS feature_flags (
+    tenant_id UUID NOT NULL,
+    feature VARCHAR(64) NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+    PRIMARY KEY (tenant_id, feature)
+);
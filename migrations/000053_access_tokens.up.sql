@@ -0,0 +1,12 @@
+-- Opaque access tokens, issued when AUTH_ACCESS_TOKEN_FORMAT is "opaque".
+-- Only a SHA-256 hash of each token is kept, with the claims a JWT access
+-- token would carry.
+CREATE TABLE IF NOT EXISTS access_tokens (
+    token_hash VARCHAR(64) PRIMARY KEY,
+    tenant_id UUID NOT NULL,
+    claims JSONB NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_access_tokens_expires_at ON access_tokens(expires_at);
@@ -0,0 +1,3 @@
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS resource;
+ALTER TABLE authorization_codes DROP COLUMN IF EXISTS resource;
+ALTER TABLE oauth_clients DROP COLUMN IF EXISTS allowed_resources;
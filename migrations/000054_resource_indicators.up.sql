@@ -0,0 +1,6 @@
+-- Resource indicators (RFC 8707): the resource servers a client may request
+-- tokens for, and the resource an authorization code or refresh token was
+-- issued for. An empty resource means the default "client-app" audience.
+ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS allowed_resources TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE authorization_codes ADD COLUMN IF NOT EXISTS resource TEXT NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS resource TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+DELETE FROM oauth_scope_catalog WHERE tenant_id IS NULL AND scope IN ('address', 'phone', 'offline_access');
+ALTER TABLE oauth_scope_catalog DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE oauth_scope_catalog DROP COLUMN IF EXISTS sensitive;
+ALTER TABLE oauth_scope_catalog DROP COLUMN IF EXISTS display_name;
@@ -0,0 +1,18 @@
+-- Human-readable scope details for the consent screen. Sensitive scopes are
+-- highlighted when the user is asked to approve them.
+ALTER TABLE oauth_scope_catalog ADD COLUMN IF NOT EXISTS display_name VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE oauth_scope_catalog ADD COLUMN IF NOT EXISTS sensitive BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE oauth_scope_catalog ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW();
+
+UPDATE oauth_scope_catalog SET display_name = 'OpenID', description = 'Sign you in with your account'
+    WHERE tenant_id IS NULL AND scope = 'openid';
+UPDATE oauth_scope_catalog SET display_name = 'Profile', description = 'Read your name and basic profile information'
+    WHERE tenant_id IS NULL AND scope = 'profile';
+UPDATE oauth_scope_catalog SET display_name = 'Email address', description = 'Read your email address'
+    WHERE tenant_id IS NULL AND scope = 'email';
+
+INSERT INTO oauth_scope_catalog (tenant_id, scope, display_name, description, sensitive) VALUES
+    (NULL, 'address', 'Postal address', 'Read your postal address', TRUE),
+    (NULL, 'phone', 'Phone number', 'Read your phone number', TRUE),
+    (NULL, 'offline_access', 'Offline access', 'Keep access to your account while you are signed out', TRUE)
+ON CONFLICT DO NOTHING;
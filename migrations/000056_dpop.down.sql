@@ -0,0 +1,2 @@
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS dpop_jkt;
+DROP TABLE IF EXISTS dpop_proofs;
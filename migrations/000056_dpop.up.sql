@@ -0,0 +1,11 @@
+-- DPoP (RFC 9449): proofs already accepted, kept until they could no longer
+-- be replayed, and the key thumbprint a refresh token is bound to. An empty
+-- dpop_jkt means the refresh token is not bound.
+CREATE TABLE IF NOT EXISTS dpop_proofs (
+    proof_hash VARCHAR(64) PRIMARY KEY,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_dpop_proofs_expires_at ON dpop_proofs(expires_at);
+
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS dpop_jkt TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+-- Redirect URIs a social login may send the provider's code to besides the
+-- auth service's own callback.
+ALTER TABLE sso_providers ADD COLUMN IF NOT EXISTS oidc_redirect_uris TEXT[];
@@ -0,0 +1,7 @@
+-- JWT signing keys shared by the auth service instances. private_key is the
+-- PKCS#1 PEM key, encrypted when SIGNING_KEY_ENCRYPTION_KEY is set.
+CREATE TABLE IF NOT EXISTS jwt_signing_keys (
+    id TEXT PRIMARY KEY,
+    private_key TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
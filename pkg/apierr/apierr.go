@@ -0,0 +1,136 @@
+// Package apierr defines the errors HTTP handlers return to clients. An
+// Error carries a machine-readable code, the HTTP status to answer with and a
+// message safe to show; Handler renders any Error a handler attaches to the
+// gin context with c.Error.
+package apierr
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Code is a stable, machine-readable error identifier clients can branch on.
+type Code string
+
+// Codes shared by every service. Packages may define their own codes for
+// errors that need a more specific one.
+const (
+	CodeInvalid      Code = "invalid_request"
+	CodeUnauthorized Code = "unauthorized"
+	CodeForbidden    Code = "forbidden"
+	CodeNotFound     Code = "not_found"
+	CodeConflict     Code = "conflict"
+	CodeInternal     Code = "internal"
+
+	CodeMethodNotAllowed Code = "method_not_allowed"
+)
+
+// internalMessage replaces the message of internal errors so causes never
+// reach clients.
+const internalMessage = "internal server error"
+
+// Error is an error with the code, status and message of an API response.
+type Error struct {
+	Code    Code
+	Status  int
+	Message string
+	// Details are extra fields rendered next to the error and code.
+	Details map[string]any
+	// Err is the underlying cause. It is logged, never rendered.
+	Err error
+}
+
+// New returns an Error answering with status.
+func New(status int, code Code, message string) *Error {
+	return &Error{Code: code, Status: status, Message: message}
+}
+
+// Invalid returns a 400 for a request that failed validation.
+func Invalid(message string) *Error {
+	return New(http.StatusBadRequest, CodeInvalid, message)
+}
+
+// Unauthorized returns a 401.
+func Unauthorized(message string) *Error {
+	return New(http.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+// Forbidden returns a 403.
+func Forbidden(message string) *Error {
+	return New(http.StatusForbidden, CodeForbidden, message)
+}
+
+// NotFound returns a 404.
+func NotFound(message string) *Error {
+	return New(http.StatusNotFound, CodeNotFound, message)
+}
+
+// Conflict returns a 409.
+func Conflict(message string) *Error {
+	return New(http.StatusConflict, CodeConflict, message)
+}
+
+// MethodNotAllowed returns a 405.
+func MethodNotAllowed(message string) *Error {
+	return New(http.StatusMethodNotAllowed, CodeMethodNotAllowed, message)
+}
+
+// Internal returns a 500 wrapping cause, which is logged but not rendered.
+func Internal(cause error) *Error {
+	return &Error{Code: CodeInternal, Status: http.StatusInternalServerError, Message: internalMessage, Err: cause}
+}
+
+// Error returns the message, followed by the cause when there is one.
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+// Unwrap returns the cause.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Wrap returns a copy of e with cause attached.
+func (e *Error) Wrap(cause error) *Error {
+	out := *e
+	out.Err = cause
+	return &out
+}
+
+// WithDetail returns a copy of e rendering value under key.
+func (e *Error) WithDetail(key string, value any) *Error {
+	out := *e
+	out.Details = make(map[string]any, len(e.Details)+1)
+	for k, v := range e.Details {
+		out.Details[k] = v
+	}
+	out.Details[key] = value
+	return &out
+}
+
+// As returns the first Error in err's chain.
+func As(err error) (*Error, bool) {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr, true
+	}
+	return nil, false
+}
+
+// From returns the Error in err's chain, or an internal error wrapping err
+// when there is none.
+func From(err error) *Error {
+	if apiErr, ok := As(err); ok {
+		return apiErr
+	}
+	return Internal(err)
+}
+
+// IsCode reports whether err's chain holds an Error with code.
+func IsCode(err error, code Code) bool {
+	apiErr, ok := As(err)
+	return ok && apiErr.Code == code
+}
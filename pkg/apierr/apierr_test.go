@@ -0,0 +1,149 @@
+package apierr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func TestHandlerRendersErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cause := errors.New("connection refused")
+	cases := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   Code
+		wantError  string
+	}{
+		{"validation", Invalid("name is required"), http.StatusBadRequest, CodeInvalid, "name is required"},
+		{"not found", NotFound("user not found"), http.StatusNotFound, CodeNotFound, "user not found"},
+		{"conflict", Conflict("version changed"), http.StatusConflict, CodeConflict, "version changed"},
+		{"wrapped", fmt.Errorf("get user: %w", NotFound("user not found")), http.StatusNotFound, CodeNotFound, "user not found"},
+		{"internal", Internal(cause), http.StatusInternalServerError, CodeInternal, "internal server error"},
+		{"plain error", cause, http.StatusInternalServerError, CodeInternal, "internal server error"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := serve(func(c *gin.Context) { Abort(c, tc.err) })
+
+			if resp.Code != tc.wantStatus {
+				t.Fatalf("expected %d, got %d", tc.wantStatus, resp.Code)
+			}
+			var body struct {
+				Error string `json:"error"`
+				Code  Code   `json:"code"`
+			}
+			if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+			if body.Code != tc.wantCode || body.Error != tc.wantError {
+				t.Fatalf("expected %s %q, got %s %q", tc.wantCode, tc.wantError, body.Code, body.Error)
+			}
+		})
+	}
+}
+
+func TestHandlerRendersDetails(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	resp := serve(func(c *gin.Context) {
+		Abort(c, New(http.StatusBadRequest, "weak_password", "password too short").WithDetail("rule", "min_length"))
+	})
+
+	var body map[string]string
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body["code"] != "weak_password" || body["rule"] != "min_length" {
+		t.Fatalf("unexpected body: %v", body)
+	}
+}
+
+func TestHandlerKeepsWrittenResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	resp := serve(func(c *gin.Context) {
+		c.JSON(http.StatusAccepted, gin.H{"status": "queued"})
+		_ = c.Error(errors.New("audit log unavailable"))
+	})
+
+	if resp.Code != http.StatusAccepted {
+		t.Fatalf("expected the handler's 202, got %d", resp.Code)
+	}
+}
+
+func TestFromAndUnwrap(t *testing.T) {
+	cause := errors.New("duplicate key")
+	err := fmt.Errorf("create group: %w", Conflict("group exists").Wrap(cause))
+
+	apiErr := From(err)
+	if apiErr.Code != CodeConflict {
+		t.Fatalf("expected conflict, got %s", apiErr.Code)
+	}
+	if !errors.Is(err, cause) {
+		t.Fatal("expected the cause to stay reachable through errors.Is")
+	}
+	if !IsCode(err, CodeConflict) || IsCode(cause, CodeConflict) {
+		t.Fatal("IsCode reported the wrong code")
+	}
+	if got := From(cause); got.Status != http.StatusInternalServerError || got.Err != cause {
+		t.Fatalf("expected an internal error wrapping the cause, got %+v", got)
+	}
+}
+
+func serve(handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.Use(Handler(zap.NewNop()))
+	router.GET("/", handler)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/", nil))
+	return resp
+}
+
+func TestRegisterFallbacks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	RegisterFallbacks(r)
+	r.GET("/users", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.POST("/users", func(c *gin.Context) { c.Status(http.StatusCreated) })
+
+	cases := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantCode   Code
+		wantAllow  string
+	}{
+		{"unknown path", http.MethodGet, "/nope", http.StatusNotFound, CodeNotFound, ""},
+		{"wrong method", http.MethodDelete, "/users", http.StatusMethodNotAllowed, CodeMethodNotAllowed, "GET, POST"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := httptest.NewRecorder()
+			r.ServeHTTP(resp, httptest.NewRequest(tc.method, tc.path, nil))
+
+			if resp.Code != tc.wantStatus {
+				t.Fatalf("expected %d, got %d", tc.wantStatus, resp.Code)
+			}
+			var body struct {
+				Error string `json:"error"`
+				Code  Code   `json:"code"`
+			}
+			if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+			if body.Code != tc.wantCode || body.Error == "" {
+				t.Fatalf("expected code %s with a message, got %+v", tc.wantCode, body)
+			}
+			if got := resp.Header().Get("Allow"); got != tc.wantAllow {
+				t.Fatalf("expected Allow %q, got %q", tc.wantAllow, got)
+			}
+		})
+	}
+}
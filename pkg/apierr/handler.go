@@ -0,0 +1,62 @@
+package apierr
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Handler returns a middleware rendering the last error a handler attached
+// with c.Error when the handler wrote no response itself. The body is
+// {"error": message, "code": code} plus the error's details; errors other
+// than *Error render as internal errors. Server errors are logged with their
+// cause.
+func Handler(logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		last := c.Errors.Last()
+		if last == nil || c.Writer.Written() {
+			return
+		}
+		apiErr := From(last.Err)
+		if apiErr.Status >= 500 {
+			logger.Error("Request failed",
+				zap.String("method", c.Request.Method),
+				zap.String("path", c.FullPath()),
+				zap.String("code", string(apiErr.Code)),
+				zap.Error(last.Err))
+		}
+		render(c, apiErr)
+	}
+}
+
+// RegisterFallbacks answers requests for unknown routes with a 404 and
+// requests for a known route with an unsupported method with a 405, both in
+// the body Handler renders. Gin lists the supported methods in the 405's
+// Allow header.
+func RegisterFallbacks(router *gin.Engine) {
+	router.HandleMethodNotAllowed = true
+	router.NoRoute(func(c *gin.Context) {
+		render(c, NotFound("route not found"))
+	})
+	router.NoMethod(func(c *gin.Context) {
+		render(c, MethodNotAllowed("method not allowed"))
+	})
+}
+
+// render writes apiErr as the response and stops the handler chain.
+func render(c *gin.Context, apiErr *Error) {
+	body := gin.H{}
+	for k, v := range apiErr.Details {
+		body[k] = v
+	}
+	body["error"] = apiErr.Message
+	body["code"] = apiErr.Code
+	c.AbortWithStatusJSON(apiErr.Status, body)
+}
+
+// Abort attaches err to c for Handler to render and stops the handler chain.
+func Abort(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
@@ -0,0 +1,150 @@
+package apierr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError describes one request field that failed validation. Field is
+// the JSON path of the field, e.g. "user.email".
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func init() {
+	// Report binding:"..." failures from ShouldBindJSON by JSON name too.
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(jsonFieldName)
+	}
+}
+
+// NewValidator returns a validator that names fields by their JSON tags, so
+// Validation can report them as clients send them.
+func NewValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(jsonFieldName)
+	return v
+}
+
+// Validation returns a 400 for an error from binding or validating a request.
+// Validator and JSON type errors are listed under "fields" as FieldErrors and
+// their messages joined into the error message; other decoding errors get a
+// generic message so Go type and field names do not reach clients.
+func Validation(err error) *Error {
+	fields := FieldErrors(err)
+	if len(fields) > 0 {
+		messages := make([]string, len(fields))
+		for i, f := range fields {
+			messages[i] = f.Message
+		}
+		return Invalid(strings.Join(messages, "; ")).WithDetail("fields", fields).Wrap(err)
+	}
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.Is(err, io.EOF):
+		return Invalid("request body is required").Wrap(err)
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return Invalid("request body is not valid JSON").Wrap(err)
+	}
+	return Invalid("invalid request").Wrap(err)
+}
+
+// FieldErrors lists the fields err reports as invalid. It returns nil when
+// err is not a validator or JSON type error.
+func FieldErrors(err error) []FieldError {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		fields := make([]FieldError, len(validationErrs))
+		for i, fe := range validationErrs {
+			field := fieldPath(fe)
+			fields[i] = FieldError{Field: field, Rule: fe.Tag(), Message: ruleMessage(field, fe)}
+		}
+		return fields
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return []FieldError{{
+			Field:   typeErr.Field,
+			Rule:    "type",
+			Message: fmt.Sprintf("%s must be a %s", typeErr.Field, jsonTypeName(typeErr.Type)),
+		}}
+	}
+	return nil
+}
+
+// fieldPath drops the top-level struct name from the error's namespace.
+func fieldPath(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if i := strings.Index(ns, "."); i >= 0 {
+		return ns[i+1:]
+	}
+	return fe.Field()
+}
+
+func ruleMessage(field string, fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return field + " is required"
+	case "email":
+		return field + " must be a valid email address"
+	case "uuid":
+		return field + " must be a valid UUID"
+	case "url":
+		return field + " must be a valid URL"
+	case "oneof":
+		return field + " must be one of: " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "min", "max":
+		bound := "at least"
+		if fe.Tag() == "max" {
+			bound = "at most"
+		}
+		switch fe.Kind() {
+		case reflect.String:
+			return fmt.Sprintf("%s must be %s %s characters", field, bound, fe.Param())
+		case reflect.Slice, reflect.Array, reflect.Map:
+			unit := "items"
+			if fe.Param() == "1" {
+				unit = "item"
+			}
+			return fmt.Sprintf("%s must contain %s %s %s", field, bound, fe.Param(), unit)
+		}
+		return fmt.Sprintf("%s must be %s %s", field, bound, fe.Param())
+	}
+	return fmt.Sprintf("%s failed the %s rule", field, fe.Tag())
+}
+
+// jsonTypeName names a Go type the way a JSON client would think of it.
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	}
+	return "object"
+}
+
+// jsonFieldName names a struct field by its JSON tag. Returning "" makes the
+// validator keep the Go name, as for untagged fields and fields tagged "-".
+func jsonFieldName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
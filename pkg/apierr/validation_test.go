@@ -0,0 +1,61 @@
+package apierr
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+)
+
+type validationAddress struct {
+	City string `json:"city" validate:"required"`
+}
+
+type validationRequest struct {
+	Email   string            `json:"email" validate:"required,email"`
+	Tags    []string          `json:"tags" validate:"min=1"`
+	Address validationAddress `json:"address"`
+}
+
+func TestValidationListsFieldsByJSONName(t *testing.T) {
+	err := NewValidator().Struct(validationRequest{Email: "not-an-email"})
+
+	apiErr := Validation(err)
+	if apiErr.Status != http.StatusBadRequest || apiErr.Code != CodeInvalid {
+		t.Fatalf("expected a 400 invalid_request, got %d %s", apiErr.Status, apiErr.Code)
+	}
+	want := []FieldError{
+		{Field: "email", Rule: "email", Message: "email must be a valid email address"},
+		{Field: "tags", Rule: "min", Message: "tags must contain at least 1 item"},
+		{Field: "address.city", Rule: "required", Message: "address.city is required"},
+	}
+	if got := apiErr.Details["fields"]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected fields %+v, got %+v", want, got)
+	}
+	var cause validator.ValidationErrors
+	if !errors.As(apiErr, &cause) {
+		t.Fatal("expected the validator error as the cause")
+	}
+}
+
+func TestValidationReportsJSONTypeErrors(t *testing.T) {
+	var req validationRequest
+	err := json.Unmarshal([]byte(`{"email": 42}`), &req)
+
+	want := []FieldError{{Field: "email", Rule: "type", Message: "email must be a string"}}
+	if got := FieldErrors(err); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestValidationHidesDecodeErrors(t *testing.T) {
+	var req validationRequest
+	err := json.Unmarshal([]byte(`{"email":`), &req)
+
+	if got := Validation(err).Message; got != "request body is not valid JSON" {
+		t.Fatalf("unexpected message %q", got)
+	}
+}
@@ -0,0 +1,636 @@
+// Package config loads the settings shared by the platform's services from
+// defaults, an optional YAML file and the environment, in increasing order of
+// precedence.
+package config
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dhawalhost/wardseal/pkg/database"
+	"github.com/dhawalhost/wardseal/pkg/observability"
+	"github.com/dhawalhost/wardseal/pkg/server"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv names the environment variable holding the path of a YAML
+// configuration file.
+const FileEnv = "CONFIG_FILE"
+
+// Config holds the settings shared by the services.
+type Config struct {
+	// Environment is e.g. "development" or "production".
+	Environment string            `yaml:"environment"`
+	HTTP        HTTPConfig        `yaml:"http"`
+	DB          DBConfig          `yaml:"db"`
+	Services    ServiceURLs       `yaml:"services"`
+	ServiceAuth ServiceAuthConfig `yaml:"service_auth"`
+	Metrics     MetricsConfig     `yaml:"metrics"`
+	Mail        MailConfig        `yaml:"mail"`
+	Keys        KeysConfig        `yaml:"keys"`
+	Auth        AuthConfig        `yaml:"auth"`
+	Directory   DirectoryConfig   `yaml:"directory"`
+	RBAC        RBACConfig        `yaml:"rbac"`
+	Connectors  ConnectorsConfig  `yaml:"connectors"`
+	SAML        SAMLConfig        `yaml:"saml"`
+}
+
+// HTTPConfig configures a service's HTTP listener.
+type HTTPConfig struct {
+	Addr               string   `yaml:"addr"`
+	CORSAllowedOrigins []string `yaml:"cors_allowed_origins"`
+	HSTSEnabled        bool     `yaml:"hsts_enabled"`
+	// MaxBodyBytes caps request bodies; 0 uses the middleware default.
+	MaxBodyBytes int64     `yaml:"max_body_bytes"`
+	TLS          TLSConfig `yaml:"tls"`
+}
+
+// TLSConfig enables HTTPS. TLS is off unless a certificate and key are given
+// or SelfSigned is set.
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// SelfSigned generates a certificate at startup; not allowed in production.
+	SelfSigned bool `yaml:"self_signed"`
+	// MinVersion is "1.2" (the default) or "1.3".
+	MinVersion string `yaml:"min_version"`
+}
+
+// Options returns the settings as server.TLSOptions.
+func (c TLSConfig) Options() server.TLSOptions {
+	version, _ := tlsVersion(c.MinVersion)
+	return server.TLSOptions{
+		CertFile:   c.CertFile,
+		KeyFile:    c.KeyFile,
+		SelfSigned: c.SelfSigned,
+		MinVersion: version,
+	}
+}
+
+func tlsVersion(name string) (uint16, bool) {
+	switch name {
+	case "", "1.2":
+		return tls.VersionTLS12, true
+	case "1.3":
+		return tls.VersionTLS13, true
+	}
+	return 0, false
+}
+
+// MetricsConfig bounds the tenant label on HTTP request metrics.
+type MetricsConfig struct {
+	// TenantAllowlist are the tenants labelled with their own ID.
+	TenantAllowlist []string `yaml:"tenant_allowlist"`
+	// TenantBuckets hashes other tenants into this many label values; 0
+	// labels them all "other".
+	TenantBuckets int `yaml:"tenant_buckets"`
+}
+
+// Options returns the settings as observability.PrometheusConfig.
+func (c MetricsConfig) Options() observability.PrometheusConfig {
+	return observability.PrometheusConfig{
+		TenantAllowlist: c.TenantAllowlist,
+		TenantBuckets:   c.TenantBuckets,
+	}
+}
+
+// MailConfig locates the SMTP relay account emails are sent through.
+type MailConfig struct {
+	SMTPHost string `yaml:"smtp_host"`
+	// SMTPPort defaults to 587.
+	SMTPPort     int    `yaml:"smtp_port"`
+	SMTPUsername string `yaml:"smtp_username"`
+	SMTPPassword string `yaml:"smtp_password"`
+	// From is the sender address, e.g. "WardSeal <no-reply@wardseal.com>".
+	From string `yaml:"from"`
+}
+
+// Key is a secret given base64 encoded.
+type Key []byte
+
+// UnmarshalYAML decodes the base64 text of the key.
+func (k *Key) UnmarshalYAML(value *yaml.Node) error {
+	decoded, err := base64.StdEncoding.DecodeString(value.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: key must be base64 encoded", value.Line)
+	}
+	*k = decoded
+	return nil
+}
+
+// KeysConfig holds the keys encrypting secrets at rest. The services sharing
+// a store must share its key.
+type KeysConfig struct {
+	// MFAEncryption encrypts TOTP secrets.
+	MFAEncryption Key `yaml:"mfa_encryption"`
+	// SSOEncryption encrypts SSO provider client secrets; unset stores them
+	// in plaintext.
+	SSOEncryption Key `yaml:"sso_encryption"`
+	// SigningKeyEncryption encrypts the stored JWT signing keys; unset stores
+	// them in plaintext.
+	SigningKeyEncryption Key `yaml:"signing_key_encryption"`
+	// DPoPNonce, when set, makes DPoP proofs carry a nonce derived from it.
+	DPoPNonce Key `yaml:"dpop_nonce"`
+}
+
+// AuthConfig holds the auth service's token and login settings.
+type AuthConfig struct {
+	// ScopePolicy is "reject" (the default) or "drop".
+	ScopePolicy string `yaml:"scope_policy"`
+	// AccessTokenFormat is "jwt" (the default) or "opaque".
+	AccessTokenFormat string `yaml:"access_token_format"`
+	// SigningKeyRotationInterval rotates the JWT signing key once it is this
+	// old; 0 never rotates.
+	SigningKeyRotationInterval time.Duration `yaml:"signing_key_rotation_interval"`
+	Login                      LoginConfig   `yaml:"login"`
+	// CredentialRateLimit and CredentialRateBurst limit the login, token and
+	// introspection endpoints, in requests per second.
+	CredentialRateLimit int `yaml:"credential_rate_limit"`
+	CredentialRateBurst int `yaml:"credential_rate_burst"`
+}
+
+// LoginConfig locks accounts and source IPs after repeated failed logins.
+type LoginConfig struct {
+	MaxFailedAttempts      int           `yaml:"max_failed_attempts"`
+	MaxFailedAttemptsPerIP int           `yaml:"max_failed_attempts_per_ip"`
+	LockoutDuration        time.Duration `yaml:"lockout_duration"`
+}
+
+// DirectoryConfig holds the directory service's password settings.
+type DirectoryConfig struct {
+	// BcryptCost hashes new passwords; 0 uses the bcrypt default.
+	BcryptCost int `yaml:"bcrypt_cost"`
+	// PasswordBreachCheck rejects passwords found by Have I Been Pwned.
+	PasswordBreachCheck bool `yaml:"password_breach_check"`
+}
+
+// RBACConfig tunes permission checks and the seeded default roles.
+type RBACConfig struct {
+	// PermissionCacheTTL caches effective permissions in memory; 0 disables
+	// the cache.
+	PermissionCacheTTL time.Duration `yaml:"permission_cache_ttl"`
+	// DefaultRolesFile replaces the built-in default roles.
+	DefaultRolesFile string `yaml:"default_roles_file"`
+}
+
+// ConnectorsConfig schedules the inbound connector sync.
+type ConnectorsConfig struct {
+	// SyncInterval is how often connectors are synced; 0 disables the sync.
+	SyncInterval time.Duration `yaml:"sync_interval"`
+}
+
+// SAMLConfig locates the PEM key pair the auth service signs SAML messages
+// with and publishes in its SP metadata. Every instance must use the same
+// pair, which IdPs are configured to trust.
+type SAMLConfig struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
+// DBConfig holds the Postgres connection settings.
+type DBConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Name     string `yaml:"name"`
+	SSLMode  string `yaml:"sslmode"`
+	// AutoMigrate applies pending migrations from MigrationsDir at startup.
+	// Without it the service stays unready until the schema is migrated.
+	AutoMigrate   bool   `yaml:"auto_migrate"`
+	MigrationsDir string `yaml:"migrations_dir"`
+	// QueryTimeout cancels store queries running longer; 0 leaves them
+	// unbounded.
+	QueryTimeout time.Duration `yaml:"query_timeout"`
+	// SlowQueryThreshold logs a warning for store queries slower than it; 0
+	// disables the log.
+	SlowQueryThreshold time.Duration `yaml:"slow_query_threshold"`
+}
+
+// MigrationSource returns the directory to migrate from at startup, or ""
+// when AutoMigrate is off.
+func (c DBConfig) MigrationSource() string {
+	if !c.AutoMigrate {
+		return ""
+	}
+	return c.MigrationsDir
+}
+
+// QueryLimits returns the query bounds for database.NewStmtCache, logging
+// slow queries to log.
+func (c DBConfig) QueryLimits(log *zap.Logger) database.QueryLimits {
+	return database.QueryLimits{
+		Timeout:       c.QueryTimeout,
+		SlowThreshold: c.SlowQueryThreshold,
+		Logger:        log,
+	}
+}
+
+// Connection returns the settings as a database.Config.
+func (c DBConfig) Connection() database.Config {
+	return database.Config{
+		Host:     c.Host,
+		Port:     c.Port,
+		User:     c.User,
+		Password: c.Password,
+		DBName:   c.Name,
+		SSLMode:  c.SSLMode,
+	}
+}
+
+// ServiceURLs locates the services a service calls.
+type ServiceURLs struct {
+	Auth      string `yaml:"auth"`
+	Directory string `yaml:"directory"`
+}
+
+// ServiceAuthConfig holds the credentials for service-to-service calls.
+type ServiceAuthConfig struct {
+	// Token is the shared static token, accepted alongside signed tokens
+	// while services migrate.
+	Token  string `yaml:"token"`
+	Header string `yaml:"header"`
+	// SigningKey signs the tokens this service presents to others.
+	SigningKey string `yaml:"signing_key"`
+	// TrustedKeys maps calling service names to the keys they sign with.
+	TrustedKeys map[string]string `yaml:"trusted_keys"`
+}
+
+// Keys returns TrustedKeys in the form middleware.ServiceAuthConfig takes.
+func (c ServiceAuthConfig) Keys() map[string][]byte {
+	if len(c.TrustedKeys) == 0 {
+		return nil
+	}
+	keys := make(map[string][]byte, len(c.TrustedKeys))
+	for service, key := range c.TrustedKeys {
+		keys[service] = []byte(key)
+	}
+	return keys
+}
+
+// Options controls Load.
+type Options struct {
+	// File is read before the environment. Defaults to $CONFIG_FILE; no file
+	// is read when both are empty.
+	File string
+	// EnvPrefix is prepended to every environment variable name, e.g. "TEST_".
+	EnvPrefix string
+	// RequireDB makes the database settings required.
+	RequireDB bool
+}
+
+// ValidationError lists the settings that are missing or invalid.
+type ValidationError struct {
+	Fields []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration: " + strings.Join(e.Fields, ", ")
+}
+
+// Defaults returns the settings used for local development.
+func Defaults() Config {
+	return Config{
+		Environment: "development",
+		HTTP: HTTPConfig{
+			Addr:               ":8080",
+			CORSAllowedOrigins: []string{"http://localhost:5173", "http://127.0.0.1:5173"},
+		},
+		DB: DBConfig{
+			Host:               "localhost",
+			Port:               5432,
+			User:               "user",
+			Password:           "password",
+			Name:               "identity_platform",
+			SSLMode:            "disable",
+			MigrationsDir:      "migrations",
+			QueryTimeout:       30 * time.Second,
+			SlowQueryThreshold: 500 * time.Millisecond,
+		},
+		Services: ServiceURLs{
+			Auth:      "http://localhost:8080",
+			Directory: "http://localhost:8081",
+		},
+		Auth: AuthConfig{
+			ScopePolicy:       "reject",
+			AccessTokenFormat: "jwt",
+			Login: LoginConfig{
+				MaxFailedAttempts:      5,
+				MaxFailedAttemptsPerIP: 20,
+				LockoutDuration:        15 * time.Minute,
+			},
+			CredentialRateLimit: 5,
+			CredentialRateBurst: 10,
+		},
+		Connectors: ConnectorsConfig{
+			SyncInterval: 15 * time.Minute,
+		},
+	}
+}
+
+// Load returns defaults overridden by the configuration file and then by
+// environment variables, and validates the result.
+func Load(defaults Config, opts Options) (Config, error) {
+	cfg := defaults
+	file := opts.File
+	if file == "" {
+		file = os.Getenv(opts.EnvPrefix + FileEnv)
+	}
+	if file != "" {
+		raw, err := os.ReadFile(file) //nolint:gosec // G304: path is chosen by the operator
+		if err != nil {
+			return Config{}, fmt.Errorf("read config file: %w", err)
+		}
+		if err := yaml.Unmarshal(raw, &cfg); err != nil {
+			return Config{}, fmt.Errorf("parse config file %s: %w", file, err)
+		}
+	}
+	if err := applyEnv(&cfg, opts.EnvPrefix); err != nil {
+		return Config{}, err
+	}
+	if err := cfg.Validate(opts.RequireDB); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// Validate checks that required settings are present and valid. The service
+// auth token is only required in production, where the development default
+// and self-signed certificates must not be used.
+func (c Config) Validate(requireDB bool) error {
+	var missing []string
+	if c.HTTP.Addr == "" {
+		missing = append(missing, "http.addr")
+	}
+	if (c.HTTP.TLS.CertFile == "") != (c.HTTP.TLS.KeyFile == "") {
+		if c.HTTP.TLS.CertFile == "" {
+			missing = append(missing, "http.tls.cert_file")
+		} else {
+			missing = append(missing, "http.tls.key_file")
+		}
+	}
+	if (c.SAML.CertFile == "") != (c.SAML.KeyFile == "") {
+		if c.SAML.CertFile == "" {
+			missing = append(missing, "saml.cert_file")
+		} else {
+			missing = append(missing, "saml.key_file")
+		}
+	}
+	if _, ok := tlsVersion(c.HTTP.TLS.MinVersion); !ok {
+		missing = append(missing, "http.tls.min_version")
+	}
+	if c.DB.QueryTimeout < 0 {
+		missing = append(missing, "db.query_timeout")
+	}
+	if c.DB.SlowQueryThreshold < 0 {
+		missing = append(missing, "db.slow_query_threshold")
+	}
+	if c.Metrics.TenantBuckets < 0 {
+		missing = append(missing, "metrics.tenant_buckets")
+	}
+	if c.Mail.SMTPHost != "" && c.Mail.From == "" {
+		missing = append(missing, "mail.from")
+	}
+	if c.Auth.ScopePolicy != "reject" && c.Auth.ScopePolicy != "drop" {
+		missing = append(missing, "auth.scope_policy")
+	}
+	if c.Auth.AccessTokenFormat != "jwt" && c.Auth.AccessTokenFormat != "opaque" {
+		missing = append(missing, "auth.access_token_format")
+	}
+	if c.Auth.SigningKeyRotationInterval < 0 {
+		missing = append(missing, "auth.signing_key_rotation_interval")
+	}
+	if c.Auth.Login.MaxFailedAttempts <= 0 {
+		missing = append(missing, "auth.login.max_failed_attempts")
+	}
+	if c.Auth.Login.MaxFailedAttemptsPerIP <= 0 {
+		missing = append(missing, "auth.login.max_failed_attempts_per_ip")
+	}
+	if c.Auth.Login.LockoutDuration <= 0 {
+		missing = append(missing, "auth.login.lockout_duration")
+	}
+	if c.Auth.CredentialRateLimit <= 0 {
+		missing = append(missing, "auth.credential_rate_limit")
+	}
+	if c.Auth.CredentialRateBurst <= 0 {
+		missing = append(missing, "auth.credential_rate_burst")
+	}
+	// bcrypt accepts costs 4 to 31.
+	if c.Directory.BcryptCost != 0 && (c.Directory.BcryptCost < 4 || c.Directory.BcryptCost > 31) {
+		missing = append(missing, "directory.bcrypt_cost")
+	}
+	if c.RBAC.PermissionCacheTTL < 0 {
+		missing = append(missing, "rbac.permission_cache_ttl")
+	}
+	if c.Connectors.SyncInterval < 0 {
+		missing = append(missing, "connectors.sync_interval")
+	}
+	if requireDB {
+		if c.DB.Host == "" {
+			missing = append(missing, "db.host")
+		}
+		if c.DB.Port <= 0 || c.DB.Port > 65535 {
+			missing = append(missing, "db.port")
+		}
+		if c.DB.User == "" {
+			missing = append(missing, "db.user")
+		}
+		if c.DB.Name == "" {
+			missing = append(missing, "db.name")
+		}
+	}
+	if c.Environment == "production" {
+		if c.ServiceAuth.Token == "" {
+			missing = append(missing, "service_auth.token")
+		}
+		if c.HTTP.TLS.SelfSigned {
+			missing = append(missing, "http.tls.self_signed")
+		}
+	}
+	if len(missing) > 0 {
+		return &ValidationError{Fields: missing}
+	}
+	return nil
+}
+
+func applyEnv(cfg *Config, prefix string) error {
+	str := func(key string, dst *string) {
+		if v := os.Getenv(prefix + key); v != "" {
+			*dst = v
+		}
+	}
+	str("ENVIRONMENT", &cfg.Environment)
+	str("HTTP_ADDR", &cfg.HTTP.Addr)
+	if v := os.Getenv(prefix + "CORS_ALLOWED_ORIGINS"); v != "" {
+		cfg.HTTP.CORSAllowedOrigins = splitCSV(v)
+	}
+	if v := os.Getenv(prefix + "HSTS_ENABLED"); v != "" {
+		cfg.HTTP.HSTSEnabled = v == "true"
+	}
+	if v := os.Getenv(prefix + "MAX_REQUEST_BODY_BYTES"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%sMAX_REQUEST_BODY_BYTES must be an integer", prefix)
+		}
+		cfg.HTTP.MaxBodyBytes = n
+	}
+	str("TLS_CERT_FILE", &cfg.HTTP.TLS.CertFile)
+	str("TLS_KEY_FILE", &cfg.HTTP.TLS.KeyFile)
+	str("TLS_MIN_VERSION", &cfg.HTTP.TLS.MinVersion)
+	if v := os.Getenv(prefix + "TLS_SELF_SIGNED"); v != "" {
+		cfg.HTTP.TLS.SelfSigned = v == "true"
+	}
+
+	integer := func(key string, dst *int) error {
+		if v := os.Getenv(prefix + key); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("%s%s must be an integer", prefix, key)
+			}
+			*dst = n
+		}
+		return nil
+	}
+	duration := func(key string, dst *time.Duration) error {
+		if v := os.Getenv(prefix + key); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("%s%s must be a duration, e.g. 30s", prefix, key)
+			}
+			*dst = d
+		}
+		return nil
+	}
+	key := func(name string, dst *Key) error {
+		if v := os.Getenv(prefix + name); v != "" {
+			decoded, err := base64.StdEncoding.DecodeString(v)
+			if err != nil {
+				return fmt.Errorf("%s%s must be base64 encoded", prefix, name)
+			}
+			*dst = decoded
+		}
+		return nil
+	}
+
+	str("DB_HOST", &cfg.DB.Host)
+	if err := integer("DB_PORT", &cfg.DB.Port); err != nil {
+		return err
+	}
+	str("DB_USER", &cfg.DB.User)
+	str("DB_PASSWORD", &cfg.DB.Password)
+	str("DB_NAME", &cfg.DB.Name)
+	str("DB_SSLMODE", &cfg.DB.SSLMode)
+	if v := os.Getenv(prefix + "DB_AUTO_MIGRATE"); v != "" {
+		cfg.DB.AutoMigrate = v == "true"
+	}
+	str("DB_MIGRATIONS_DIR", &cfg.DB.MigrationsDir)
+	if err := duration("DB_QUERY_TIMEOUT", &cfg.DB.QueryTimeout); err != nil {
+		return err
+	}
+	if err := duration("DB_SLOW_QUERY_THRESHOLD", &cfg.DB.SlowQueryThreshold); err != nil {
+		return err
+	}
+
+	str("AUTH_SERVICE_URL", &cfg.Services.Auth)
+	// DIRSVC_URL is the older name; DIRECTORY_SERVICE_URL wins when both are set.
+	str("DIRSVC_URL", &cfg.Services.Directory)
+	str("DIRECTORY_SERVICE_URL", &cfg.Services.Directory)
+
+	str("SERVICE_AUTH_TOKEN", &cfg.ServiceAuth.Token)
+	str("SERVICE_AUTH_HEADER", &cfg.ServiceAuth.Header)
+	str("SERVICE_AUTH_SIGNING_KEY", &cfg.ServiceAuth.SigningKey)
+	// SERVICE_AUTH_TRUSTED_KEYS is a comma-separated list of service=key pairs.
+	if v := os.Getenv(prefix + "SERVICE_AUTH_TRUSTED_KEYS"); v != "" {
+		cfg.ServiceAuth.TrustedKeys = make(map[string]string)
+		for _, pair := range splitCSV(v) {
+			service, key, ok := strings.Cut(pair, "=")
+			if !ok || service == "" || key == "" {
+				return fmt.Errorf("%sSERVICE_AUTH_TRUSTED_KEYS entries must be service=key", prefix)
+			}
+			cfg.ServiceAuth.TrustedKeys[service] = key
+		}
+	}
+
+	if v := os.Getenv(prefix + "METRICS_TENANT_ALLOWLIST"); v != "" {
+		cfg.Metrics.TenantAllowlist = splitCSV(v)
+	}
+	if err := integer("METRICS_TENANT_BUCKETS", &cfg.Metrics.TenantBuckets); err != nil {
+		return err
+	}
+
+	str("SMTP_HOST", &cfg.Mail.SMTPHost)
+	if err := integer("SMTP_PORT", &cfg.Mail.SMTPPort); err != nil {
+		return err
+	}
+	str("SMTP_USERNAME", &cfg.Mail.SMTPUsername)
+	str("SMTP_PASSWORD", &cfg.Mail.SMTPPassword)
+	str("MAIL_FROM", &cfg.Mail.From)
+
+	for _, v := range []struct {
+		name string
+		dst  *Key
+	}{
+		{"MFA_ENCRYPTION_KEY", &cfg.Keys.MFAEncryption},
+		{"SSO_ENCRYPTION_KEY", &cfg.Keys.SSOEncryption},
+		{"SIGNING_KEY_ENCRYPTION_KEY", &cfg.Keys.SigningKeyEncryption},
+		{"AUTH_DPOP_NONCE_KEY", &cfg.Keys.DPoPNonce},
+	} {
+		if err := key(v.name, v.dst); err != nil {
+			return err
+		}
+	}
+
+	str("AUTH_SCOPE_POLICY", &cfg.Auth.ScopePolicy)
+	str("AUTH_ACCESS_TOKEN_FORMAT", &cfg.Auth.AccessTokenFormat)
+	for _, v := range []struct {
+		name string
+		dst  *time.Duration
+	}{
+		{"AUTH_SIGNING_KEY_ROTATION_INTERVAL", &cfg.Auth.SigningKeyRotationInterval},
+		{"LOGIN_LOCKOUT_DURATION", &cfg.Auth.Login.LockoutDuration},
+		{"RBAC_PERMISSION_CACHE_TTL", &cfg.RBAC.PermissionCacheTTL},
+		{"CONNECTOR_SYNC_INTERVAL", &cfg.Connectors.SyncInterval},
+	} {
+		if err := duration(v.name, v.dst); err != nil {
+			return err
+		}
+	}
+	for _, v := range []struct {
+		name string
+		dst  *int
+	}{
+		{"LOGIN_MAX_FAILED_ATTEMPTS", &cfg.Auth.Login.MaxFailedAttempts},
+		{"LOGIN_MAX_FAILED_ATTEMPTS_PER_IP", &cfg.Auth.Login.MaxFailedAttemptsPerIP},
+		{"CREDENTIAL_RATE_LIMIT", &cfg.Auth.CredentialRateLimit},
+		{"CREDENTIAL_RATE_BURST", &cfg.Auth.CredentialRateBurst},
+		{"BCRYPT_COST", &cfg.Directory.BcryptCost},
+	} {
+		if err := integer(v.name, v.dst); err != nil {
+			return err
+		}
+	}
+	if v := os.Getenv(prefix + "PASSWORD_BREACH_CHECK"); v != "" {
+		cfg.Directory.PasswordBreachCheck = v == "true"
+	}
+	str("RBAC_DEFAULT_ROLES_FILE", &cfg.RBAC.DefaultRolesFile)
+	str("SAML_CERT_FILE", &cfg.SAML.CertFile)
+	str("SAML_KEY_FILE", &cfg.SAML.KeyFile)
+	return nil
+}
+
+func splitCSV(value string) []string {
+	parts := strings.Split(value, ",")
+	result := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if trimmed := strings.TrimSpace(part); trimmed != "" {
+			result = append(result, trimmed)
+		}
+	}
+	return result
+}
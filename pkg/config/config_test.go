@@ -0,0 +1,287 @@
+package config
+
+import (
+	"crypto/tls"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadEnvOverridesFile(t *testing.T) {
+	path := writeFile(t, `
+http:
+  addr: ":9000"
+  cors_allowed_origins: ["https://file.example.com"]
+db:
+  host: file-db
+  port: 6543
+  user: file-user
+  name: file-name
+services:
+  directory: http://file-dir:8081
+service_auth:
+  token: file-token
+`)
+	t.Setenv(FileEnv, path)
+	t.Setenv("DB_HOST", "env-db")
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://a.example.com, https://b.example.com")
+	t.Setenv("SERVICE_AUTH_TOKEN", "env-token")
+	t.Setenv("SERVICE_AUTH_TRUSTED_KEYS", "authsvc=auth-key, govsvc=gov-key")
+
+	cfg, err := Load(Defaults(), Options{RequireDB: true})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.DB.Host != "env-db" {
+		t.Errorf("db.host = %q, want env value", cfg.DB.Host)
+	}
+	if cfg.ServiceAuth.Token != "env-token" {
+		t.Errorf("service_auth.token = %q, want env value", cfg.ServiceAuth.Token)
+	}
+	if want := map[string]string{"authsvc": "auth-key", "govsvc": "gov-key"}; !reflect.DeepEqual(cfg.ServiceAuth.TrustedKeys, want) {
+		t.Errorf("service_auth.trusted_keys = %v, want %v", cfg.ServiceAuth.TrustedKeys, want)
+	}
+	if want := []string{"https://a.example.com", "https://b.example.com"}; !reflect.DeepEqual(cfg.HTTP.CORSAllowedOrigins, want) {
+		t.Errorf("cors origins = %v, want %v", cfg.HTTP.CORSAllowedOrigins, want)
+	}
+	// Settings without an env override come from the file.
+	if cfg.HTTP.Addr != ":9000" || cfg.DB.Port != 6543 || cfg.DB.User != "file-user" || cfg.Services.Directory != "http://file-dir:8081" {
+		t.Errorf("file values not applied: %+v", cfg)
+	}
+	// Settings in neither keep their defaults.
+	if cfg.DB.SSLMode != "disable" || cfg.Environment != "development" {
+		t.Errorf("defaults not kept: %+v", cfg)
+	}
+}
+
+func TestLoadEnvPrefix(t *testing.T) {
+	t.Setenv("DB_NAME", "unprefixed")
+	t.Setenv("TEST_DB_NAME", "identity_platform_test")
+
+	cfg, err := Load(Defaults(), Options{EnvPrefix: "TEST_", RequireDB: true})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.DB.Name != "identity_platform_test" {
+		t.Errorf("db.name = %q, want prefixed value", cfg.DB.Name)
+	}
+}
+
+func TestLoadMissingRequiredFields(t *testing.T) {
+	path := writeFile(t, `
+environment: production
+http:
+  addr: ""
+db:
+  host: ""
+  user: ""
+`)
+
+	_, err := Load(Defaults(), Options{File: path, RequireDB: true})
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Load error = %v, want *ValidationError", err)
+	}
+	want := []string{"http.addr", "db.host", "db.user", "service_auth.token"}
+	if !reflect.DeepEqual(verr.Fields, want) {
+		t.Errorf("missing fields = %v, want %v", verr.Fields, want)
+	}
+}
+
+func TestLoadDBNotRequired(t *testing.T) {
+	defaults := Defaults()
+	defaults.DB = DBConfig{}
+
+	if _, err := Load(defaults, Options{}); err != nil {
+		t.Fatalf("Load without RequireDB: %v", err)
+	}
+	_, err := Load(defaults, Options{RequireDB: true})
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Load error = %v, want *ValidationError", err)
+	}
+}
+
+func TestLoadRejectsInvalidValues(t *testing.T) {
+	t.Setenv("DB_PORT", "not-a-port")
+	if _, err := Load(Defaults(), Options{}); err == nil {
+		t.Fatal("expected error for non-numeric DB_PORT")
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	_, err := Load(Defaults(), Options{File: filepath.Join(t.TempDir(), "absent.yaml")})
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Load error = %v, want not-exist", err)
+	}
+}
+
+func TestLoadTLS(t *testing.T) {
+	t.Setenv("TLS_CERT_FILE", "/etc/wardseal/tls.crt")
+	t.Setenv("TLS_KEY_FILE", "/etc/wardseal/tls.key")
+	t.Setenv("TLS_MIN_VERSION", "1.3")
+
+	cfg, err := Load(Defaults(), Options{})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	opts := cfg.HTTP.TLS.Options()
+	if opts.CertFile != "/etc/wardseal/tls.crt" || opts.KeyFile != "/etc/wardseal/tls.key" || opts.MinVersion != tls.VersionTLS13 {
+		t.Errorf("unexpected TLS options: %+v", opts)
+	}
+
+	t.Setenv("TLS_KEY_FILE", "")
+	t.Setenv("TLS_MIN_VERSION", "1.1")
+	_, err = Load(Defaults(), Options{})
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Load error = %v, want *ValidationError", err)
+	}
+	if want := []string{"http.tls.key_file", "http.tls.min_version"}; !reflect.DeepEqual(verr.Fields, want) {
+		t.Errorf("invalid fields = %v, want %v", verr.Fields, want)
+	}
+}
+
+func TestLoadMetricsTenantLabels(t *testing.T) {
+	t.Setenv("METRICS_TENANT_ALLOWLIST", "tenant-a, tenant-b")
+	t.Setenv("METRICS_TENANT_BUCKETS", "8")
+
+	cfg, err := Load(Defaults(), Options{})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	opts := cfg.Metrics.Options()
+	if !reflect.DeepEqual(opts.TenantAllowlist, []string{"tenant-a", "tenant-b"}) || opts.TenantBuckets != 8 {
+		t.Errorf("metrics options = %+v", opts)
+	}
+
+	t.Setenv("METRICS_TENANT_BUCKETS", "-1")
+	var verr *ValidationError
+	if _, err := Load(Defaults(), Options{}); !errors.As(err, &verr) || !reflect.DeepEqual(verr.Fields, []string{"metrics.tenant_buckets"}) {
+		t.Errorf("expected metrics.tenant_buckets to be rejected, got %v", err)
+	}
+}
+
+func TestLoadQueryLimits(t *testing.T) {
+	t.Setenv("DB_QUERY_TIMEOUT", "5s")
+	t.Setenv("DB_SLOW_QUERY_THRESHOLD", "250ms")
+
+	cfg, err := Load(Defaults(), Options{})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	limits := cfg.DB.QueryLimits(nil)
+	if limits.Timeout != 5*time.Second || limits.SlowThreshold != 250*time.Millisecond {
+		t.Errorf("query limits = %+v", limits)
+	}
+
+	t.Setenv("DB_QUERY_TIMEOUT", "5")
+	if _, err := Load(Defaults(), Options{}); err == nil {
+		t.Error("expected error for a DB_QUERY_TIMEOUT without a unit")
+	}
+}
+
+func TestLoadMail(t *testing.T) {
+	t.Setenv("SMTP_HOST", "smtp.wardseal.com")
+	t.Setenv("SMTP_PORT", "2525")
+	t.Setenv("MAIL_FROM", "WardSeal <no-reply@wardseal.com>")
+
+	cfg, err := Load(Defaults(), Options{})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Mail.SMTPHost != "smtp.wardseal.com" || cfg.Mail.SMTPPort != 2525 || cfg.Mail.From != "WardSeal <no-reply@wardseal.com>" {
+		t.Errorf("mail = %+v", cfg.Mail)
+	}
+
+	t.Setenv("MAIL_FROM", "")
+	var verr *ValidationError
+	if _, err := Load(Defaults(), Options{}); !errors.As(err, &verr) || !reflect.DeepEqual(verr.Fields, []string{"mail.from"}) {
+		t.Errorf("expected mail.from to be required with a relay, got %v", err)
+	}
+}
+
+func TestLoadServiceSettings(t *testing.T) {
+	path := writeFile(t, `
+keys:
+  mfa_encryption: MDEyMzQ1Njc4OWFiY2RlZg==
+auth:
+  access_token_format: opaque
+  login:
+    max_failed_attempts: 3
+rbac:
+  permission_cache_ttl: 30s
+`)
+	t.Setenv(FileEnv, path)
+	t.Setenv("LOGIN_LOCKOUT_DURATION", "1h")
+	t.Setenv("CREDENTIAL_RATE_BURST", "20")
+	t.Setenv("BCRYPT_COST", "12")
+	t.Setenv("CONNECTOR_SYNC_INTERVAL", "0")
+
+	cfg, err := Load(Defaults(), Options{})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if string(cfg.Keys.MFAEncryption) != "0123456789abcdef" {
+		t.Errorf("keys.mfa_encryption = %q", cfg.Keys.MFAEncryption)
+	}
+	want := AuthConfig{
+		ScopePolicy:       "reject",
+		AccessTokenFormat: "opaque",
+		Login: LoginConfig{
+			MaxFailedAttempts:      3,
+			MaxFailedAttemptsPerIP: 20,
+			LockoutDuration:        time.Hour,
+		},
+		CredentialRateLimit: 5,
+		CredentialRateBurst: 20,
+	}
+	if !reflect.DeepEqual(cfg.Auth, want) {
+		t.Errorf("auth = %+v, want %+v", cfg.Auth, want)
+	}
+	if cfg.Directory.BcryptCost != 12 || cfg.RBAC.PermissionCacheTTL != 30*time.Second || cfg.Connectors.SyncInterval != 0 {
+		t.Errorf("unexpected settings: %+v %+v %+v", cfg.Directory, cfg.RBAC, cfg.Connectors)
+	}
+}
+
+func TestLoadRejectsInvalidServiceSettings(t *testing.T) {
+	for _, key := range []string{"LOGIN_LOCKOUT_DURATION", "CREDENTIAL_RATE_LIMIT", "AUTH_SIGNING_KEY_ROTATION_INTERVAL", "SSO_ENCRYPTION_KEY"} {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, "not-valid!")
+			if _, err := Load(Defaults(), Options{}); err == nil {
+				t.Errorf("expected error for malformed %s", key)
+			}
+		})
+	}
+
+	t.Setenv("AUTH_SCOPE_POLICY", "allow")
+	t.Setenv("BCRYPT_COST", "40")
+	t.Setenv("RBAC_PERMISSION_CACHE_TTL", "-1s")
+	var verr *ValidationError
+	if _, err := Load(Defaults(), Options{}); !errors.As(err, &verr) {
+		t.Fatalf("Load error = %v, want *ValidationError", err)
+	}
+	if want := []string{"auth.scope_policy", "directory.bcrypt_cost", "rbac.permission_cache_ttl"}; !reflect.DeepEqual(verr.Fields, want) {
+		t.Errorf("invalid fields = %v, want %v", verr.Fields, want)
+	}
+}
+
+func TestLoadSAMLKeyPairNeedsBothFiles(t *testing.T) {
+	t.Setenv("SAML_CERT_FILE", "/etc/wardseal/saml.crt")
+	var verr *ValidationError
+	if _, err := Load(Defaults(), Options{}); !errors.As(err, &verr) || !reflect.DeepEqual(verr.Fields, []string{"saml.key_file"}) {
+		t.Errorf("expected saml.key_file to be required with a certificate, got %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// RequiredSchemaVersion is the migration the services in this build expect.
+// Bump it with every new file in migrations/.
+const RequiredSchemaVersion uint = 59
+
+// migrationLockID serialises Migrate across replicas starting together.
+const migrationLockID = 0x77617264 // "ward"
+
+// ErrSchemaDirty means a migration failed partway and needs manual repair.
+var ErrSchemaDirty = errors.New("database schema is dirty: a migration failed partway")
+
+// SchemaOutdatedError reports a schema older than the service requires.
+type SchemaOutdatedError struct {
+	Current  uint
+	Required uint
+}
+
+func (e *SchemaOutdatedError) Error() string {
+	return fmt.Sprintf("database schema is at version %d, need %d", e.Current, e.Required)
+}
+
+// SchemaVersion returns the version recorded in schema_migrations, the table
+// golang-migrate maintains, and whether that migration failed partway. A
+// database that was never migrated is at version 0.
+func SchemaVersion(ctx context.Context, db *sqlx.DB) (version uint, dirty bool, err error) {
+	var exists bool
+	if err := db.GetContext(ctx, &exists, `SELECT to_regclass('schema_migrations') IS NOT NULL`); err != nil {
+		return 0, false, err
+	}
+	if !exists {
+		return 0, false, nil
+	}
+	var row struct {
+		Version int64 `db:"version"`
+		Dirty   bool  `db:"dirty"`
+	}
+	err = db.GetContext(ctx, &row, `SELECT version, dirty FROM schema_migrations LIMIT 1`)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return uint(row.Version), row.Dirty, nil
+}
+
+// CheckSchemaVersion returns an error unless the schema is clean and at
+// least at version required.
+func CheckSchemaVersion(version uint, dirty bool, required uint) error {
+	if dirty {
+		return ErrSchemaDirty
+	}
+	if version < required {
+		return &SchemaOutdatedError{Current: version, Required: required}
+	}
+	return nil
+}
+
+// SchemaCheck returns a readiness check that passes once the schema reaches
+// required. When migrationsDir is set the check first applies any pending
+// migrations from it; otherwise it waits for them to be applied externally.
+func SchemaCheck(db *sqlx.DB, required uint, migrationsDir string) func(context.Context) error {
+	return func(ctx context.Context) error {
+		if migrationsDir != "" {
+			if err := Migrate(ctx, db, migrationsDir); err != nil {
+				return err
+			}
+		}
+		version, dirty, err := SchemaVersion(ctx, db)
+		if err != nil {
+			return fmt.Errorf("read schema version: %w", err)
+		}
+		return CheckSchemaVersion(version, dirty, required)
+	}
+}
+
+// Migration is one up migration file.
+type Migration struct {
+	Version uint
+	Path    string
+}
+
+// Migrations lists the up migrations in dir in version order. Files follow
+// the golang-migrate naming scheme, e.g. 000043_federated_refresh_tokens.up.sql.
+func Migrations(dir string) ([]Migration, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var migrations []Migration
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".up.sql") {
+			continue
+		}
+		prefix, _, ok := strings.Cut(name, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s has no version prefix", name)
+		}
+		version, err := strconv.ParseUint(prefix, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s has no version prefix", name)
+		}
+		migrations = append(migrations, Migration{Version: uint(version), Path: filepath.Join(dir, name)})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrate applies the up migrations in dir that are newer than the schema,
+// each in its own transaction, and records progress in schema_migrations the
+// way golang-migrate does so either tool can be used afterwards. It refuses
+// to run on a dirty schema.
+func Migrate(ctx context.Context, db *sqlx.DB, dir string) error {
+	migrations, err := Migrations(dir)
+	if err != nil {
+		return fmt.Errorf("read migrations: %w", err)
+	}
+
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("lock migrations: %w", err)
+	}
+	defer func() {
+		_, _ = conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID)
+	}()
+
+	if _, err := conn.ExecContext(ctx,
+		`CREATE TABLE IF NOT EXISTS schema_migrations (version bigint NOT NULL PRIMARY KEY, dirty boolean NOT NULL)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+	version, dirty, err := SchemaVersion(ctx, db)
+	if err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+	if dirty {
+		return ErrSchemaDirty
+	}
+
+	for _, m := range migrations {
+		if m.Version <= version {
+			continue
+		}
+		script, err := os.ReadFile(m.Path) //nolint:gosec // G304: path comes from the operator's migrations directory
+		if err != nil {
+			return err
+		}
+		if err := applyMigration(ctx, conn, m.Version, string(script)); err != nil {
+			return fmt.Errorf("apply migration %d: %w", m.Version, err)
+		}
+	}
+	return nil
+}
+
+func applyMigration(ctx context.Context, conn *sqlx.Conn, version uint, script string) error {
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations`); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, false)`, int64(version)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
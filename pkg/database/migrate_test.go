@@ -0,0 +1,32 @@
+package database
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckSchemaVersion(t *testing.T) {
+	var outdated *SchemaOutdatedError
+	if err := CheckSchemaVersion(40, false, 43); !errors.As(err, &outdated) || outdated.Current != 40 {
+		t.Fatalf("outdated schema: got %v", err)
+	}
+	if err := CheckSchemaVersion(43, true, 43); !errors.Is(err, ErrSchemaDirty) {
+		t.Fatalf("dirty schema: got %v, want ErrSchemaDirty", err)
+	}
+	if err := CheckSchemaVersion(44, false, 43); err != nil {
+		t.Fatalf("newer schema: %v", err)
+	}
+}
+
+func TestRequiredSchemaVersionMatchesMigrations(t *testing.T) {
+	migrations, err := Migrations("../../migrations")
+	if err != nil {
+		t.Fatalf("Migrations: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no migrations found")
+	}
+	if latest := migrations[len(migrations)-1].Version; latest != RequiredSchemaVersion {
+		t.Fatalf("RequiredSchemaVersion = %d, latest migration is %d", RequiredSchemaVersion, latest)
+	}
+}
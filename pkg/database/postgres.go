@@ -18,12 +18,15 @@ type Config struct {
 	SSLMode  string
 }
 
+// DSN returns the lib/pq connection string for the configuration.
+func (c Config) DSN() string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		c.Host, c.Port, c.User, c.Password, c.DBName, c.SSLMode)
+}
+
 // NewConnection creates a new database connection.
 func NewConnection(config Config) (*sqlx.DB, error) { // Use sqlx.DB
-	connStr := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		config.Host, config.Port, config.User, config.Password, config.DBName, config.SSLMode)
-
-	db, err := sqlx.Connect("postgres", connStr) // Use sqlx.Connect
+	db, err := sqlx.Connect("postgres", config.DSN()) // Use sqlx.Connect
 	if err != nil {
 		return nil, err
 	}
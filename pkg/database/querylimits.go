@@ -0,0 +1,55 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrQueryTimeout is wrapped by errors of queries cancelled for running past
+// QueryLimits.Timeout.
+var ErrQueryTimeout = errors.New("query timed out")
+
+// QueryLimits bounds store queries: queries still running after Timeout are
+// cancelled, and those slower than SlowThreshold are logged with their name
+// and duration. Zero values disable either limit.
+type QueryLimits struct {
+	Timeout       time.Duration
+	SlowThreshold time.Duration
+	// Logger receives slow-query warnings; nil discards them.
+	Logger *zap.Logger
+}
+
+// Run calls fn with ctx bounded by the limits. name identifies the query in
+// logs and errors, e.g. "rbac.GetUserPermissions". Rows must be read inside
+// fn, since the context is cancelled when Run returns.
+func (l QueryLimits) Run(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	queryCtx := ctx
+	if l.Timeout > 0 {
+		var cancel context.CancelFunc
+		queryCtx, cancel = context.WithTimeout(ctx, l.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := fn(queryCtx)
+	elapsed := time.Since(start)
+
+	if l.SlowThreshold > 0 && elapsed >= l.SlowThreshold && l.Logger != nil {
+		l.Logger.Warn("Slow database query",
+			zap.String("query", name),
+			zap.Duration("duration", elapsed),
+			zap.Error(err),
+		)
+	}
+	// Drivers report cancellation in their own words (lib/pq: "canceling
+	// statement due to user request"), so check the deadline rather than err.
+	// A deadline or cancellation of the caller's own context is not ours.
+	if err != nil && queryCtx.Err() != nil && ctx.Err() == nil {
+		return fmt.Errorf("%w: %s exceeded %s: %w", ErrQueryTimeout, name, l.Timeout, err)
+	}
+	return err
+}
@@ -0,0 +1,114 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// sleepingDriver is a database/sql driver whose statements take delay to
+// run, or until their context is cancelled.
+type sleepingDriver struct{ delay time.Duration }
+
+func (d sleepingDriver) Open(string) (driver.Conn, error) { return sleepingConn(d), nil }
+
+type sleepingConn sleepingDriver
+
+func (c sleepingConn) Prepare(string) (driver.Stmt, error) { return sleepingStmt(c), nil }
+func (c sleepingConn) Close() error                        { return nil }
+func (c sleepingConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+type sleepingStmt sleepingDriver
+
+func (s sleepingStmt) Close() error  { return nil }
+func (s sleepingStmt) NumInput() int { return -1 }
+func (s sleepingStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+func (s sleepingStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func (s sleepingStmt) ExecContext(ctx context.Context, _ []driver.NamedValue) (driver.Result, error) {
+	select {
+	case <-time.After(s.delay):
+		return driver.RowsAffected(1), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func newSleepingCache(t *testing.T, delay time.Duration, limits QueryLimits) *StmtCache {
+	t.Helper()
+	name := fmt.Sprintf("sleeping%d", driverSeq.Add(1))
+	sql.Register(name, sleepingDriver{delay: delay})
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return NewStmtCache(sqlx.NewDb(db, "postgres"), limits)
+}
+
+func execSleep(ctx context.Context, cache *StmtCache) error {
+	return cache.Query(ctx, "test.sleep", `SELECT pg_sleep(1)`, func(ctx context.Context, stmt *sqlx.Stmt) error {
+		_, err := stmt.ExecContext(ctx)
+		return err
+	})
+}
+
+func TestQueryLimitsCancelSlowQuery(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	cache := newSleepingCache(t, time.Second, QueryLimits{
+		Timeout:       50 * time.Millisecond,
+		SlowThreshold: 20 * time.Millisecond,
+		Logger:        zap.New(core),
+	})
+
+	start := time.Now()
+	err := execSleep(context.Background(), cache)
+	if !errors.Is(err, ErrQueryTimeout) {
+		t.Fatalf("expected ErrQueryTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("query ran %s, want it cancelled after the 50ms timeout", elapsed)
+	}
+	entries := logs.FilterMessage("Slow database query").All()
+	if len(entries) != 1 || entries[0].ContextMap()["query"] != "test.sleep" {
+		t.Fatalf("expected one slow-query warning naming test.sleep, got %+v", entries)
+	}
+
+	// The caller's own cancellation is passed through as is.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := execSleep(ctx, cache); errors.Is(err, ErrQueryTimeout) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the caller's context.Canceled, got %v", err)
+	}
+}
+
+func TestQueryLimitsLogOnlySlowQueries(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	limits := QueryLimits{Timeout: time.Second, SlowThreshold: 30 * time.Millisecond, Logger: zap.New(core)}
+
+	if err := execSleep(context.Background(), newSleepingCache(t, 0, limits)); err != nil {
+		t.Fatalf("fast query: %v", err)
+	}
+	if logs.Len() != 0 {
+		t.Fatalf("fast query logged: %+v", logs.All())
+	}
+	if err := execSleep(context.Background(), newSleepingCache(t, 50*time.Millisecond, limits)); err != nil {
+		t.Fatalf("slow query within the timeout: %v", err)
+	}
+	if logs.FilterMessage("Slow database query").Len() != 1 {
+		t.Fatalf("expected the slow query to be logged, got %+v", logs.All())
+	}
+}
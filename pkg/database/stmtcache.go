@@ -0,0 +1,120 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrStmtCacheClosed is returned by StmtCache.Stmt after Close.
+var ErrStmtCacheClosed = errors.New("statement cache is closed")
+
+// StmtCache prepares each query once and hands out the prepared statement on
+// later calls, so hot store methods skip parsing and planning on every request.
+// Statements are keyed by query text; tenant and user IDs are bound as
+// parameters, so one statement serves every tenant. database/sql re-prepares a
+// statement transparently on pool connections that have not seen it yet.
+//
+// Queries run through Query and Run are bounded by the cache's QueryLimits.
+//
+// Close the cache before the database: it is an io.Closer, so pass it to the
+// server runner ahead of the pool.
+type StmtCache struct {
+	db     *sqlx.DB
+	limits QueryLimits
+
+	mu     sync.RWMutex
+	stmts  map[string]*sqlx.Stmt
+	closed bool
+}
+
+// NewStmtCache returns an empty cache of statements prepared on db, whose
+// queries are bounded by limits.
+func NewStmtCache(db *sqlx.DB, limits QueryLimits) *StmtCache {
+	return &StmtCache{db: db, limits: limits, stmts: make(map[string]*sqlx.Stmt)}
+}
+
+// DB returns the database the cache prepares on, for work such as
+// transactions that does not go through cached statements.
+func (c *StmtCache) DB() *sqlx.DB {
+	return c.db
+}
+
+// Stmt returns the prepared statement for query, preparing it on first use.
+func (c *StmtCache) Stmt(ctx context.Context, query string) (*sqlx.Stmt, error) {
+	c.mu.RLock()
+	stmt, ok := c.stmts[query]
+	closed := c.closed
+	c.mu.RUnlock()
+	if ok {
+		return stmt, nil
+	}
+	if closed {
+		return nil, ErrStmtCacheClosed
+	}
+
+	// Prepare outside the lock so a slow round trip does not block lookups
+	// of other queries; a concurrent caller that lost the race discards its
+	// statement.
+	prepared, err := c.db.PreparexContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		_ = prepared.Close()
+		return nil, ErrStmtCacheClosed
+	}
+	if stmt, ok := c.stmts[query]; ok {
+		_ = prepared.Close()
+		return stmt, nil
+	}
+	c.stmts[query] = prepared
+	return prepared, nil
+}
+
+// Query runs fn with the prepared statement for query under the cache's
+// limits; name identifies the query in slow-query logs and timeout errors.
+func (c *StmtCache) Query(ctx context.Context, name, query string, fn func(ctx context.Context, stmt *sqlx.Stmt) error) error {
+	return c.limits.Run(ctx, name, func(ctx context.Context) error {
+		stmt, err := c.Stmt(ctx, query)
+		if err != nil {
+			return err
+		}
+		return fn(ctx, stmt)
+	})
+}
+
+// Run runs fn under the cache's limits, for work such as transactions that
+// does not go through a single cached statement.
+func (c *StmtCache) Run(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	return c.limits.Run(ctx, name, fn)
+}
+
+// Len returns the number of cached statements.
+func (c *StmtCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.stmts)
+}
+
+// Close closes every cached statement. Later calls to Stmt fail.
+func (c *StmtCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	var errs []error
+	for query, stmt := range c.stmts {
+		if err := stmt.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		delete(c.stmts, query)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,136 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// countingDriver is a database/sql driver that counts statement preparations
+// and answers every query with no rows.
+type countingDriver struct {
+	prepares atomic.Int64
+	closes   atomic.Int64
+}
+
+func (d *countingDriver) Open(string) (driver.Conn, error) { return &countingConn{d: d}, nil }
+
+type countingConn struct{ d *countingDriver }
+
+func (c *countingConn) Prepare(string) (driver.Stmt, error) {
+	c.d.prepares.Add(1)
+	return &countingStmt{d: c.d}, nil
+}
+func (c *countingConn) Close() error              { return nil }
+func (c *countingConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+type countingStmt struct{ d *countingDriver }
+
+func (s *countingStmt) Close() error {
+	s.d.closes.Add(1)
+	return nil
+}
+func (s *countingStmt) NumInput() int { return -1 }
+func (s *countingStmt) Exec([]driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+func (s *countingStmt) Query([]driver.Value) (driver.Rows, error) { return emptyRows{}, nil }
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string         { return []string{"id"} }
+func (emptyRows) Close() error              { return nil }
+func (emptyRows) Next([]driver.Value) error { return io.EOF }
+
+var driverSeq atomic.Int64
+
+func newCountingDB(t testing.TB) (*sqlx.DB, *countingDriver) {
+	t.Helper()
+	d := &countingDriver{}
+	name := fmt.Sprintf("counting%d", driverSeq.Add(1))
+	sql.Register(name, d)
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	return sqlx.NewDb(db, "postgres"), d
+}
+
+const permissionsQuery = `SELECT id FROM permissions WHERE tenant_id = $1`
+
+func TestStmtCacheReusesStatements(t *testing.T) {
+	db, d := newCountingDB(t)
+	cache := NewStmtCache(db, QueryLimits{})
+	ctx := context.Background()
+
+	for _, tenant := range []string{"tenant-a", "tenant-b", "tenant-a"} {
+		stmt, err := cache.Stmt(ctx, permissionsQuery)
+		if err != nil {
+			t.Fatalf("Stmt: %v", err)
+		}
+		var ids []string
+		if err := stmt.SelectContext(ctx, &ids, tenant); err != nil {
+			t.Fatalf("select: %v", err)
+		}
+	}
+	if got := d.prepares.Load(); got != 1 {
+		t.Fatalf("prepared %d times, want 1 shared across tenants", got)
+	}
+	if _, err := cache.Stmt(ctx, `DELETE FROM permissions WHERE id = $1`); err != nil {
+		t.Fatalf("Stmt: %v", err)
+	}
+	if cache.Len() != 2 {
+		t.Fatalf("Len = %d, want 2", cache.Len())
+	}
+
+	if err := cache.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if got := d.closes.Load(); got != 2 {
+		t.Fatalf("closed %d statements, want 2", got)
+	}
+	if _, err := cache.Stmt(ctx, permissionsQuery); !errors.Is(err, ErrStmtCacheClosed) {
+		t.Fatalf("Stmt after Close: got %v, want ErrStmtCacheClosed", err)
+	}
+}
+
+func BenchmarkSelectUncached(b *testing.B) {
+	db, d := newCountingDB(b)
+	ctx := context.Background()
+	b.ResetTimer()
+	for range b.N {
+		var ids []string
+		if err := db.SelectContext(ctx, &ids, permissionsQuery, "tenant-a"); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(d.prepares.Load())/float64(b.N), "prepares/op")
+}
+
+func BenchmarkSelectCached(b *testing.B) {
+	db, d := newCountingDB(b)
+	cache := NewStmtCache(db, QueryLimits{})
+	b.Cleanup(func() { _ = cache.Close() })
+	ctx := context.Background()
+	b.ResetTimer()
+	for range b.N {
+		stmt, err := cache.Stmt(ctx, permissionsQuery)
+		if err != nil {
+			b.Fatal(err)
+		}
+		var ids []string
+		if err := stmt.SelectContext(ctx, &ids, "tenant-a"); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(d.prepares.Load())/float64(b.N), "prepares/op")
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// subjectContextKey holds the ID of the authenticated user.
+const subjectContextKey = "subject"
+
+// SetSubject records the authenticated user for later middleware such as
+// RequirePermission. Authentication middleware calls it once the caller's
+// identity is established.
+func SetSubject(c *gin.Context, userID string) {
+	c.Set(subjectContextKey, userID)
+}
+
+// SubjectFromContext returns the user recorded by SetSubject, or "" when the
+// request is not authenticated.
+func SubjectFromContext(c *gin.Context) string {
+	return c.GetString(subjectContextKey)
+}
+
+// PermissionChecker decides whether a user of a tenant may perform action on
+// resource. rbac.Service implements it.
+type PermissionChecker interface {
+	HasPermission(ctx context.Context, tenantID, userID, resource, action string) (bool, error)
+}
+
+// Authorizer guards routes with permission checks.
+type Authorizer struct {
+	checker PermissionChecker
+}
+
+// NewAuthorizer returns an Authorizer that asks checker for every decision.
+func NewAuthorizer(checker PermissionChecker) *Authorizer {
+	return &Authorizer{checker: checker}
+}
+
+// RequirePermission returns a middleware that lets the request through only
+// when the authenticated subject holds resource:action in the request's
+// tenant. It must run after TenantExtractor and the authentication middleware.
+// Requests without a subject get 401, denied ones 403.
+func (a *Authorizer) RequirePermission(resource, action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		subject := SubjectFromContext(c)
+		if subject == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+			return
+		}
+		tenantID, err := TenantIDFromGinContext(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "tenant id required"})
+			return
+		}
+		allowed, err := a.checker.HasPermission(c.Request.Context(), tenantID, subject, resource, action)
+		if err != nil {
+			_ = c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to check permission"})
+			return
+		}
+		if !allowed {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "permission denied"})
+			return
+		}
+		c.Next()
+	}
+}
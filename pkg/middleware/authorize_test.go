@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// checkerFunc adapts a function to PermissionChecker.
+type checkerFunc func(ctx context.Context, tenantID, userID, resource, action string) (bool, error)
+
+func (f checkerFunc) HasPermission(ctx context.Context, tenantID, userID, resource, action string) (bool, error) {
+	return f(ctx, tenantID, userID, resource, action)
+}
+
+const authzTenant = "11111111-1111-1111-1111-111111111111"
+
+func serveWithPermission(checker PermissionChecker, subject string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(TenantExtractor(TenantConfig{}))
+	r.Use(func(c *gin.Context) {
+		if subject != "" {
+			SetSubject(c, subject)
+		}
+	})
+	mw := NewAuthorizer(checker)
+	r.POST("/roles", mw.RequirePermission("rbac.role", "create"), func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/roles", nil)
+	req.Header.Set(DefaultTenantHeader, authzTenant)
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRequirePermissionAllows(t *testing.T) {
+	var got [4]string
+	checker := checkerFunc(func(_ context.Context, tenantID, userID, resource, action string) (bool, error) {
+		got = [4]string{tenantID, userID, resource, action}
+		return true, nil
+	})
+	w := serveWithPermission(checker, "user-1")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 when permitted, got %d", w.Code)
+	}
+	if want := [4]string{authzTenant, "user-1", "rbac.role", "create"}; got != want {
+		t.Errorf("checker asked %v, want %v", got, want)
+	}
+}
+
+func TestRequirePermissionDenies(t *testing.T) {
+	deny := checkerFunc(func(context.Context, string, string, string, string) (bool, error) { return false, nil })
+	if w := serveWithPermission(deny, "user-1"); w.Code != http.StatusForbidden {
+		t.Fatalf("Expected 403 when denied, got %d", w.Code)
+	}
+	if w := serveWithPermission(deny, ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected 401 without a subject, got %d", w.Code)
+	}
+	failing := checkerFunc(func(context.Context, string, string, string, string) (bool, error) {
+		return false, errors.New("database down")
+	})
+	if w := serveWithPermission(failing, "user-1"); w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected 500 when the check fails, got %d", w.Code)
+	}
+}
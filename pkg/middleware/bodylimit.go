@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultMaxBodyBytes is the request body limit used when none is configured.
+const DefaultMaxBodyBytes int64 = 1 << 20
+
+// BodyLimit returns a middleware that rejects request bodies larger than
+// maxBytes with 413. The body is read up front so handlers binding JSON never
+// see an oversized payload; a non-positive maxBytes uses DefaultMaxBodyBytes.
+// Apply it per route group to give groups different limits.
+func BodyLimit(maxBytes int64) gin.HandlerFunc {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBodyBytes
+	}
+	return func(c *gin.Context) {
+		if c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > maxBytes {
+			abortBodyTooLarge(c)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				abortBodyTooLarge(c)
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Next()
+	}
+}
+
+func abortBodyTooLarge(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "request body too large"})
+}
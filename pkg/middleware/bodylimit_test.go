@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newBodyLimitRouter(limit int64) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(BodyLimit(limit))
+	r.POST("/", func(c *gin.Context) {
+		var body map[string]interface{}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, body)
+	})
+	return r
+}
+
+func TestBodyLimitRejectsOversizedBody(t *testing.T) {
+	r := newBodyLimitRouter(64)
+	body := `{"name":"` + strings.Repeat("a", 100) + `"}`
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected 413, got %d", w.Code)
+	}
+
+	// Without a Content-Length the limit is enforced while reading.
+	req := httptest.NewRequest(http.MethodPost, "/", io.NopCloser(strings.NewReader(body)))
+	req.ContentLength = -1
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected 413 for chunked body, got %d", w.Code)
+	}
+}
+
+func TestBodyLimitPassesBodyWithinLimit(t *testing.T) {
+	r := newBodyLimitRouter(64)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"ok"}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `"name":"ok"`) {
+		t.Errorf("Expected handler to read the body, got %s", w.Body.String())
+	}
+}
@@ -0,0 +1,220 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORSConfig captures the knobs for cross-origin resource sharing.
+type CORSConfig struct {
+	// AllowedOrigins lists origins (scheme://host[:port]) allowed for every
+	// tenant. "*" allows any origin but is ignored when AllowCredentials is set.
+	AllowedOrigins []string
+	// TenantOrigins optionally returns extra origins allowed for a tenant. The
+	// tenant is read from the tenant header, or the tenant_id query parameter
+	// for preflights since browsers do not send custom header values on OPTIONS.
+	// As the caller names the tenant, these origins never get credentialed
+	// access, even with AllowCredentials.
+	TenantOrigins func(ctx context.Context, tenantID string) ([]string, error)
+	// TenantOriginsTTL is how long TenantOrigins results are cached. Defaults to one minute.
+	TenantOriginsTTL time.Duration
+	// TenantOriginsCacheSize caps how many tenants' origins are cached.
+	// Defaults to DefaultTenantOriginsCacheSize.
+	TenantOriginsCacheSize int
+	// TenantHeader defaults to DefaultTenantHeader.
+	TenantHeader string
+	// AllowedMethods defaults to GET, POST, PUT, PATCH, DELETE and OPTIONS.
+	AllowedMethods []string
+	// AllowedHeaders defaults to Origin, Content-Type, Authorization and the tenant header.
+	AllowedHeaders []string
+	ExposedHeaders []string
+	// AllowCredentials permits cookies and HTTP auth on cross-origin requests
+	// from AllowedOrigins.
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight result.
+	MaxAge time.Duration
+}
+
+// DefaultTenantOriginsCacheSize is how many tenants' origins CORS caches by
+// default.
+const DefaultTenantOriginsCacheSize = 10000
+
+type tenantOriginsEntry struct {
+	origins map[string]struct{}
+	expires time.Time
+}
+
+// tenantOriginsCache memoizes TenantOrigins. It holds at most size tenants,
+// so callers naming arbitrary tenant IDs cannot grow it without bound.
+// Errors are not cached.
+type tenantOriginsCache struct {
+	lookup  func(ctx context.Context, tenantID string) ([]string, error)
+	ttl     time.Duration
+	size    int
+	now     func() time.Time
+	mu      sync.Mutex
+	entries map[string]tenantOriginsEntry
+}
+
+func (c *tenantOriginsCache) allows(ctx context.Context, tenantID, origin string) bool {
+	now := c.now()
+	c.mu.Lock()
+	entry, ok := c.entries[tenantID]
+	c.mu.Unlock()
+	if !ok || !now.Before(entry.expires) {
+		origins, err := c.lookup(ctx, tenantID)
+		if err != nil {
+			return false
+		}
+		entry = tenantOriginsEntry{origins: make(map[string]struct{}, len(origins)), expires: now.Add(c.ttl)}
+		for _, o := range origins {
+			entry.origins[normalizeOrigin(o)] = struct{}{}
+		}
+		c.store(now, tenantID, entry)
+	}
+	_, allowed := entry.origins[origin]
+	return allowed
+}
+
+func (c *tenantOriginsCache) store(now time.Time, tenantID string, entry tenantOriginsEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[tenantID]; !ok && len(c.entries) >= c.size {
+		for id, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, id)
+			}
+		}
+		// Still full of live entries: make room by dropping any one.
+		for id := range c.entries {
+			if len(c.entries) < c.size {
+				break
+			}
+			delete(c.entries, id)
+		}
+	}
+	c.entries[tenantID] = entry
+}
+
+// CORS returns a middleware that answers preflight requests and sets CORS
+// headers for allowed origins. Disallowed origins are never reflected:
+// preflights are rejected with 403, and simple requests proceed without CORS
+// headers so the browser withholds the response from the calling page.
+func CORS(cfg CORSConfig) gin.HandlerFunc {
+	tenantHeader := cfg.TenantHeader
+	if tenantHeader == "" {
+		tenantHeader = DefaultTenantHeader
+	}
+	methods := cfg.AllowedMethods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions}
+	}
+	headers := cfg.AllowedHeaders
+	if len(headers) == 0 {
+		headers = []string{"Origin", "Content-Type", "Authorization", tenantHeader}
+	}
+	ttl := cfg.TenantOriginsTTL
+	if ttl <= 0 {
+		ttl = time.Minute
+	}
+	cacheSize := cfg.TenantOriginsCacheSize
+	if cacheSize <= 0 {
+		cacheSize = DefaultTenantOriginsCacheSize
+	}
+
+	allowAny := false
+	global := make(map[string]struct{}, len(cfg.AllowedOrigins))
+	for _, origin := range cfg.AllowedOrigins {
+		if origin == "*" {
+			allowAny = !cfg.AllowCredentials
+			continue
+		}
+		global[normalizeOrigin(origin)] = struct{}{}
+	}
+
+	tenantOrigins := &tenantOriginsCache{
+		lookup:  cfg.TenantOrigins,
+		ttl:     ttl,
+		size:    cacheSize,
+		now:     time.Now,
+		entries: make(map[string]tenantOriginsEntry),
+	}
+	tenantAllows := func(ctx context.Context, tenantID, origin string) bool {
+		if cfg.TenantOrigins == nil || tenantID == "" {
+			return false
+		}
+		return tenantOrigins.allows(ctx, tenantID, origin)
+	}
+
+	allowMethods := strings.Join(methods, ", ")
+	allowHeaders := strings.Join(headers, ", ")
+	exposeHeaders := strings.Join(cfg.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge.Seconds()))
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		c.Writer.Header().Add("Vary", "Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		normalized := normalizeOrigin(origin)
+		_, allowed := global[normalized]
+		credentialed := allowed && cfg.AllowCredentials
+		if !allowed && !allowAny {
+			tenantID := c.GetHeader(tenantHeader)
+			if tenantID == "" && preflight {
+				tenantID = c.Query("tenant_id")
+			}
+			allowed = tenantAllows(c.Request.Context(), tenantID, normalized)
+		}
+
+		if !allowed && !allowAny {
+			if preflight {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "origin not allowed"})
+				return
+			}
+			c.Next()
+			return
+		}
+
+		h := c.Writer.Header()
+		if allowed {
+			h.Set("Access-Control-Allow-Origin", origin)
+		} else {
+			h.Set("Access-Control-Allow-Origin", "*")
+		}
+		if credentialed {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if preflight {
+			h.Set("Access-Control-Allow-Methods", allowMethods)
+			h.Set("Access-Control-Allow-Headers", allowHeaders)
+			if cfg.MaxAge > 0 {
+				h.Set("Access-Control-Max-Age", maxAge)
+			}
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		if exposeHeaders != "" {
+			h.Set("Access-Control-Expose-Headers", exposeHeaders)
+		}
+		c.Next()
+	}
+}
+
+// normalizeOrigin lower-cases an origin and strips a trailing slash so
+// configuration and browser values compare equal.
+func normalizeOrigin(origin string) string {
+	return strings.TrimRight(strings.ToLower(strings.TrimSpace(origin)), "/")
+}
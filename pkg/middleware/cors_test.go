@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newCORSRouter(cfg CORSConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(CORS(cfg))
+	r.POST("/oauth2/token", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return r
+}
+
+func TestCORSPreflightAllowedOrigin(t *testing.T) {
+	r := newCORSRouter(CORSConfig{
+		AllowedOrigins:   []string{"https://app.wardseal.com"},
+		AllowCredentials: true,
+	})
+
+	req := httptest.NewRequest(http.MethodOptions, "/oauth2/token", nil)
+	req.Header.Set("Origin", "https://app.wardseal.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204 for preflight, got %d", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://app.wardseal.com" {
+		t.Errorf("Expected allowed origin to be echoed, got %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Errorf("Expected credentials to be allowed, got %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Methods"); got == "" {
+		t.Error("Expected Access-Control-Allow-Methods on preflight")
+	}
+}
+
+func TestCORSRejectsDisallowedOrigin(t *testing.T) {
+	r := newCORSRouter(CORSConfig{AllowedOrigins: []string{"https://app.wardseal.com"}})
+
+	preflight := httptest.NewRequest(http.MethodOptions, "/oauth2/token", nil)
+	preflight.Header.Set("Origin", "https://evil.example.com")
+	preflight.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, preflight)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected 403 for disallowed preflight, got %d", w.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/oauth2/token", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Expected disallowed origin not to be reflected, got %q", got)
+	}
+}
+
+func TestCORSTenantAllowlist(t *testing.T) {
+	tenantID := "11111111-1111-1111-1111-111111111111"
+	r := newCORSRouter(CORSConfig{
+		AllowedOrigins: []string{"https://admin.wardseal.com"},
+		TenantOrigins: func(ctx context.Context, tenant string) ([]string, error) {
+			if tenant == tenantID {
+				return []string{"https://tenant-app.example.com"}, nil
+			}
+			return nil, nil
+		},
+		AllowCredentials: true,
+	})
+
+	request := func(origin, tenant string) http.Header {
+		req := httptest.NewRequest(http.MethodPost, "/oauth2/token", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set(DefaultTenantHeader, tenant)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Header()
+	}
+
+	h := request("https://tenant-app.example.com", tenantID)
+	if got := h.Get("Access-Control-Allow-Origin"); got != "https://tenant-app.example.com" {
+		t.Errorf("Expected tenant origin to be allowed, got %q", got)
+	}
+	// The caller picks the tenant, so its origins never get the user's cookies.
+	if got := h.Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Expected no credentialed access for a tenant origin, got %q", got)
+	}
+	if got := request("https://tenant-app.example.com", "22222222-2222-2222-2222-222222222222").Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Expected origin to be rejected for other tenants, got %q", got)
+	}
+	if got := request("https://admin.wardseal.com", tenantID).Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Errorf("Expected credentialed access for a configured origin, got %q", got)
+	}
+}
+
+func TestCORSTenantOriginsCacheIsBounded(t *testing.T) {
+	lookups := 0
+	cache := &tenantOriginsCache{
+		lookup: func(ctx context.Context, tenant string) ([]string, error) {
+			lookups++
+			return []string{"https://" + tenant + ".example.com"}, nil
+		},
+		ttl:     time.Minute,
+		size:    2,
+		now:     time.Now,
+		entries: make(map[string]tenantOriginsEntry),
+	}
+	ctx := context.Background()
+	for _, tenant := range []string{"a", "b", "c", "d"} {
+		if !cache.allows(ctx, tenant, "https://"+tenant+".example.com") {
+			t.Fatalf("Expected %s's origin to be allowed", tenant)
+		}
+	}
+	if len(cache.entries) != 2 {
+		t.Fatalf("Expected the cache to hold 2 tenants, holds %d", len(cache.entries))
+	}
+	if !cache.allows(ctx, "d", "https://d.example.com") || lookups != 4 {
+		t.Errorf("Expected the latest tenant to be served from the cache, %d lookups", lookups)
+	}
+}
@@ -0,0 +1,241 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IdempotencyKeyHeader carries the client-chosen key that makes a request
+// safe to retry.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayHeader is set on responses replayed from the store.
+const IdempotentReplayHeader = "Idempotent-Replayed"
+
+// DefaultIdempotencyTTL is how long a stored response is replayed.
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// DefaultIdempotencyPendingTTL is how long a key stays reserved for a request
+// that is still running. It bounds how long a key is blocked when the process
+// handling its request dies.
+const DefaultIdempotencyPendingTTL = time.Minute
+
+// IdempotentResponse is a stored response and the request that produced it.
+// A zero Status marks a reservation for a request still in progress.
+type IdempotentResponse struct {
+	// RequestHash identifies the request body the response was produced for.
+	RequestHash string
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// Pending reports whether resp reserves its key for a request in progress.
+func (resp *IdempotentResponse) Pending() bool {
+	return resp.Status == 0
+}
+
+// IdempotencyStore holds responses by idempotency key. Implementations must
+// be safe for concurrent use.
+type IdempotencyStore interface {
+	// SaveIfAbsent atomically stores resp for key unless an unexpired entry
+	// is already stored. It returns that entry, or nil when resp was stored.
+	SaveIfAbsent(ctx context.Context, key string, resp IdempotentResponse, ttl time.Duration) (*IdempotentResponse, error)
+	// Save stores resp for key, replacing any entry.
+	Save(ctx context.Context, key string, resp IdempotentResponse, ttl time.Duration) error
+	// Delete removes the entry for key.
+	Delete(ctx context.Context, key string) error
+}
+
+// IdempotencyConfig configures Idempotency.
+type IdempotencyConfig struct {
+	// TTL is how long responses are replayed. Defaults to DefaultIdempotencyTTL.
+	TTL time.Duration
+	// PendingTTL is how long a key is reserved while its first request runs.
+	// Defaults to DefaultIdempotencyPendingTTL.
+	PendingTTL time.Duration
+	// Store holds the responses. Defaults to a new MemoryIdempotencyStore,
+	// which only deduplicates requests reaching the same instance.
+	Store IdempotencyStore
+}
+
+// Idempotency returns a middleware that makes requests carrying an
+// Idempotency-Key header safe to retry. The first request for a key reserves
+// it per tenant and route; its response is stored and replayed verbatim for
+// later requests with the same key and body, while duplicates arriving before
+// it completes are rejected with 409. A different body for the same key is
+// rejected with 422. Server errors are not stored so the request can be
+// retried, and store errors fail open.
+func Idempotency(cfg IdempotencyConfig) gin.HandlerFunc {
+	ttl := cfg.TTL
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+	pendingTTL := cfg.PendingTTL
+	if pendingTTL <= 0 {
+		pendingTTL = DefaultIdempotencyPendingTTL
+	}
+	store := cfg.Store
+	if store == nil {
+		store = NewMemoryIdempotencyStore()
+	}
+
+	return func(c *gin.Context) {
+		idempotencyKey := c.GetHeader(IdempotencyKeyHeader)
+		if idempotencyKey == "" {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(body)
+		requestHash := hex.EncodeToString(sum[:])
+
+		tenantID, _ := TenantIDFromGinContext(c)
+		key := tenantID + ":" + c.Request.Method + ":" + c.FullPath() + ":" + idempotencyKey
+
+		ctx := c.Request.Context()
+		stored, err := store.SaveIfAbsent(ctx, key, IdempotentResponse{RequestHash: requestHash}, pendingTTL)
+		if err != nil {
+			c.Next()
+			return
+		}
+		if stored != nil {
+			switch {
+			case stored.RequestHash != requestHash:
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+					"error": "Idempotency-Key was already used with a different request body",
+				})
+			case stored.Pending():
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+					"error": "a request with this Idempotency-Key is still in progress",
+				})
+			default:
+				c.Header(IdempotentReplayHeader, "true")
+				c.Data(stored.Status, stored.ContentType, stored.Body)
+				c.Abort()
+			}
+			return
+		}
+
+		// Release the reservation unless a response replaces it, including
+		// when the handler panics, so the request can be retried.
+		saved := false
+		defer func() {
+			if !saved {
+				_ = store.Delete(context.WithoutCancel(ctx), key)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// Errors left for apierr.Handler are rendered after this returns, so
+		// there is no response to keep yet.
+		if len(c.Errors) > 0 && !recorder.Written() {
+			return
+		}
+		if status := recorder.Status(); status < http.StatusInternalServerError {
+			saved = store.Save(ctx, key, IdempotentResponse{
+				RequestHash: requestHash,
+				Status:      status,
+				ContentType: recorder.Header().Get("Content-Type"),
+				Body:        recorder.body.Bytes(),
+			}, ttl) == nil
+		}
+	}
+}
+
+// responseRecorder copies the response body as it is written.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+type idempotencyEntry struct {
+	resp    IdempotentResponse
+	expires time.Time
+}
+
+// MemoryIdempotencyStore is an in-process IdempotencyStore.
+type MemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	entries   map[string]idempotencyEntry
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+// NewMemoryIdempotencyStore creates an empty in-memory response store.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		entries: make(map[string]idempotencyEntry),
+		now:     time.Now,
+	}
+}
+
+// SaveIfAbsent implements IdempotencyStore.
+func (s *MemoryIdempotencyStore) SaveIfAbsent(ctx context.Context, key string, resp IdempotentResponse, ttl time.Duration) (*IdempotentResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.sweep(now)
+	if entry, ok := s.entries[key]; ok && now.Before(entry.expires) {
+		existing := entry.resp
+		return &existing, nil
+	}
+	s.entries[key] = idempotencyEntry{resp: resp, expires: now.Add(ttl)}
+	return nil, nil
+}
+
+// Save implements IdempotencyStore.
+func (s *MemoryIdempotencyStore) Save(ctx context.Context, key string, resp IdempotentResponse, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = idempotencyEntry{resp: resp, expires: s.now().Add(ttl)}
+	return nil
+}
+
+// Delete implements IdempotencyStore.
+func (s *MemoryIdempotencyStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// sweep drops expired entries.
+func (s *MemoryIdempotencyStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < memoryStoreSweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, entry := range s.entries {
+		if !now.Before(entry.expires) {
+			delete(s.entries, key)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newIdempotentRouter(store IdempotencyStore, status *int) (*gin.Engine, *int) {
+	gin.SetMode(gin.TestMode)
+	calls := 0
+	r := gin.New()
+	r.Use(TenantExtractor(TenantConfig{}))
+	r.POST("/things", Idempotency(IdempotencyConfig{Store: store}), func(c *gin.Context) {
+		calls++
+		c.JSON(*status, gin.H{"call": calls})
+	})
+	return r, &calls
+}
+
+func postIdempotent(r *gin.Engine, tenant, key, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/things", strings.NewReader(body))
+	req.Header.Set(DefaultTenantHeader, tenant)
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	r.ServeHTTP(w, req)
+	return w
+}
+
+const idempotencyTenant = "11111111-1111-1111-1111-111111111111"
+
+func TestIdempotencyReplaysStoredResponse(t *testing.T) {
+	status := http.StatusCreated
+	r, calls := newIdempotentRouter(nil, &status)
+
+	first := postIdempotent(r, idempotencyTenant, "key-1", `{"name":"a"}`)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", first.Code)
+	}
+	replay := postIdempotent(r, idempotencyTenant, "key-1", `{"name":"a"}`)
+	if replay.Code != http.StatusCreated {
+		t.Fatalf("Expected replayed 201, got %d", replay.Code)
+	}
+	if replay.Body.String() != first.Body.String() {
+		t.Errorf("Expected replayed body %q, got %q", first.Body.String(), replay.Body.String())
+	}
+	if replay.Header().Get(IdempotentReplayHeader) != "true" {
+		t.Errorf("Expected %s header on replay", IdempotentReplayHeader)
+	}
+	if *calls != 1 {
+		t.Fatalf("Expected handler to run once, ran %d times", *calls)
+	}
+
+	// Other keys, tenants and requests without a key are not replayed.
+	postIdempotent(r, idempotencyTenant, "key-2", `{"name":"a"}`)
+	postIdempotent(r, "22222222-2222-2222-2222-222222222222", "key-1", `{"name":"a"}`)
+	postIdempotent(r, idempotencyTenant, "", `{"name":"a"}`)
+	if *calls != 4 {
+		t.Errorf("Expected handler to run for each distinct request, ran %d times", *calls)
+	}
+}
+
+func TestIdempotencyRejectsDifferentBodyForKey(t *testing.T) {
+	status := http.StatusCreated
+	r, calls := newIdempotentRouter(nil, &status)
+
+	postIdempotent(r, idempotencyTenant, "key-1", `{"name":"a"}`)
+	w := postIdempotent(r, idempotencyTenant, "key-1", `{"name":"b"}`)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected 422, got %d", w.Code)
+	}
+	if *calls != 1 {
+		t.Errorf("Expected mismatched request not to reach the handler, ran %d times", *calls)
+	}
+}
+
+func TestIdempotencyDoesNotStoreServerErrors(t *testing.T) {
+	status := http.StatusInternalServerError
+	r, calls := newIdempotentRouter(nil, &status)
+
+	postIdempotent(r, idempotencyTenant, "key-1", `{}`)
+	status = http.StatusCreated
+	if w := postIdempotent(r, idempotencyTenant, "key-1", `{}`); w.Code != http.StatusCreated {
+		t.Fatalf("Expected retry after a server error to run, got %d", w.Code)
+	}
+	if *calls != 2 {
+		t.Errorf("Expected handler to run twice, ran %d times", *calls)
+	}
+}
+
+func TestIdempotencyDoesNotStoreUnrenderedErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	calls := 0
+	r := gin.New()
+	r.Use(TenantExtractor(TenantConfig{}))
+	r.POST("/things", Idempotency(IdempotencyConfig{}), func(c *gin.Context) {
+		calls++
+		if calls == 1 {
+			// Left for an error-rendering middleware further out.
+			_ = c.Error(errors.New("store unavailable"))
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"call": calls})
+	})
+
+	postIdempotent(r, idempotencyTenant, "key-1", `{}`)
+	if w := postIdempotent(r, idempotencyTenant, "key-1", `{}`); w.Code != http.StatusCreated {
+		t.Fatalf("Expected retry after an unrendered error to run, got %d", w.Code)
+	}
+	if calls != 2 {
+		t.Errorf("Expected handler to run twice, ran %d times", calls)
+	}
+}
+
+func TestIdempotencyRejectsDuplicateInProgress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	calls := 0
+	r := gin.New()
+	r.Use(TenantExtractor(TenantConfig{}))
+	r.POST("/things", Idempotency(IdempotencyConfig{}), func(c *gin.Context) {
+		calls++
+		if calls == 1 {
+			close(entered)
+			<-release
+		}
+		c.JSON(http.StatusCreated, gin.H{"call": calls})
+	})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- postIdempotent(r, idempotencyTenant, "key-1", `{}`) }()
+	<-entered
+	if w := postIdempotent(r, idempotencyTenant, "key-1", `{}`); w.Code != http.StatusConflict {
+		t.Fatalf("Expected 409 while the first request runs, got %d", w.Code)
+	}
+	close(release)
+	first := <-done
+	if first.Code != http.StatusCreated {
+		t.Fatalf("Expected first request to complete with 201, got %d", first.Code)
+	}
+	replay := postIdempotent(r, idempotencyTenant, "key-1", `{}`)
+	if replay.Code != http.StatusCreated || replay.Body.String() != first.Body.String() {
+		t.Fatalf("Expected the completed response to be replayed, got %d %s", replay.Code, replay.Body)
+	}
+	if calls != 1 {
+		t.Errorf("Expected handler to run once, ran %d times", calls)
+	}
+}
+
+func TestIdempotencyReleasesKeyAfterPanic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	calls := 0
+	r := gin.New()
+	r.Use(gin.CustomRecovery(func(c *gin.Context, _ any) { c.AbortWithStatus(http.StatusInternalServerError) }))
+	r.Use(TenantExtractor(TenantConfig{}))
+	r.POST("/things", Idempotency(IdempotencyConfig{}), func(c *gin.Context) {
+		calls++
+		if calls == 1 {
+			panic("handler failed")
+		}
+		c.JSON(http.StatusCreated, gin.H{"call": calls})
+	})
+
+	postIdempotent(r, idempotencyTenant, "key-1", `{}`)
+	if w := postIdempotent(r, idempotencyTenant, "key-1", `{}`); w.Code != http.StatusCreated {
+		t.Fatalf("Expected retry after a panic to run, got %d", w.Code)
+	}
+}
+
+func TestMemoryIdempotencyStoreExpires(t *testing.T) {
+	store := NewMemoryIdempotencyStore()
+	now := time.Unix(1700000000, 0)
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if existing, _ := store.SaveIfAbsent(ctx, "k", IdempotentResponse{Status: http.StatusCreated}, time.Minute); existing != nil {
+		t.Fatalf("Expected an empty store to save, got %+v", existing)
+	}
+	existing, _ := store.SaveIfAbsent(ctx, "k", IdempotentResponse{Status: http.StatusOK}, time.Minute)
+	if existing == nil || existing.Status != http.StatusCreated {
+		t.Fatalf("Expected first stored response to win, got %+v", existing)
+	}
+
+	now = now.Add(time.Minute)
+	if existing, _ := store.SaveIfAbsent(ctx, "k", IdempotentResponse{Status: http.StatusOK}, time.Minute); existing != nil {
+		t.Fatalf("Expected response to expire, got %+v", existing)
+	}
+}
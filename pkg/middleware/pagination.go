@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TotalCountHeader carries the total number of items in a paginated list.
+const TotalCountHeader = "X-Total-Count"
+
+// SetPaginationHeaders describes one page of an offset-paginated list so
+// clients can page without parsing the body. It sets TotalCountHeader and a
+// Link header (RFC 8288) with rel="next" when items remain after this page
+// and rel="prev" when the page does not start at the beginning. The links
+// repeat the request's path and query with limit and offset replaced,
+// prefixed by baseURL when set so they are absolute.
+func SetPaginationHeaders(c *gin.Context, baseURL string, limit, offset, total int) {
+	c.Header(TotalCountHeader, strconv.Itoa(total))
+	if limit <= 0 {
+		return
+	}
+
+	var links []string
+	if offset+limit < total {
+		links = append(links, pageLink(c, baseURL, limit, offset+limit, "next"))
+	}
+	if offset > 0 {
+		links = append(links, pageLink(c, baseURL, limit, max(offset-limit, 0), "prev"))
+	}
+	if len(links) > 0 {
+		c.Header("Link", strings.Join(links, ", "))
+	}
+}
+
+func pageLink(c *gin.Context, baseURL string, limit, offset int, rel string) string {
+	query := c.Request.URL.Query()
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("offset", strconv.Itoa(offset))
+	target := strings.TrimSuffix(baseURL, "/") + c.Request.URL.Path + "?" + query.Encode()
+	return fmt.Sprintf("<%s>; rel=%q", target, rel)
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func servePage(target, baseURL string, limit, offset, total int) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/groups", func(c *gin.Context) {
+		SetPaginationHeaders(c, baseURL, limit, offset, total)
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	return w
+}
+
+func TestSetPaginationHeaders(t *testing.T) {
+	tests := []struct {
+		name          string
+		limit, offset int
+		total         int
+		wantLink      string
+	}{
+		{"first page", 10, 0, 25, `</groups?limit=10&offset=10&q=eng>; rel="next"`},
+		{"middle page", 10, 10, 25, `</groups?limit=10&offset=20&q=eng>; rel="next", </groups?limit=10&offset=0&q=eng>; rel="prev"`},
+		{"last page", 10, 20, 25, `</groups?limit=10&offset=10&q=eng>; rel="prev"`},
+		{"page ending exactly at the total", 10, 10, 20, `</groups?limit=10&offset=0&q=eng>; rel="prev"`},
+		{"only page", 10, 0, 3, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := servePage("/groups?q=eng&offset=99", "", tt.limit, tt.offset, tt.total)
+
+			if got := w.Header().Get("Link"); got != tt.wantLink {
+				t.Errorf("Expected Link %q, got %q", tt.wantLink, got)
+			}
+			if got := w.Header().Get(TotalCountHeader); got != strconv.Itoa(tt.total) {
+				t.Errorf("Expected %s %d, got %q", TotalCountHeader, tt.total, got)
+			}
+		})
+	}
+}
+
+func TestSetPaginationHeadersUsesBaseURL(t *testing.T) {
+	w := servePage("/groups", "https://dir.example.com/", 10, 0, 11)
+
+	want := `<https://dir.example.com/groups?limit=10&offset=10>; rel="next"`
+	if got := w.Header().Get("Link"); got != want {
+		t.Errorf("Expected Link %q, got %q", want, got)
+	}
+	if got := w.Header().Get(TotalCountHeader); got != "11" {
+		t.Errorf("Expected %s 11, got %q", TotalCountHeader, got)
+	}
+}
@@ -1,7 +1,11 @@
 package middleware
 
 import (
+	"context"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
 	"sync"
 	"time"
 
@@ -9,69 +13,192 @@ import (
 	"golang.org/x/time/rate"
 )
 
-// IPRateLimiter manages rate limiters for each IP address.
-type IPRateLimiter struct {
-	ips map[string]*rate.Limiter
-	mu  *sync.RWMutex
-	r   rate.Limit
-	b   int
+// RateLimitKeyFunc derives the bucket key for a request. Returning an empty
+// key exempts the request from the limiter.
+type RateLimitKeyFunc func(c *gin.Context) string
+
+// KeyByIP buckets requests by client IP.
+func KeyByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
 }
 
-// NewIPRateLimiter creates a new rate limiter.
-// r is the rate of events (requests per second).
-// b is the burst size (max concurrent requests).
-func NewIPRateLimiter(r rate.Limit, b int) *IPRateLimiter {
-	i := &IPRateLimiter{
-		ips: make(map[string]*rate.Limiter),
-		mu:  &sync.RWMutex{},
-		r:   r,
-		b:   b,
+// KeyByTenant buckets requests by tenant, as set by TenantExtractor or sent in
+// the tenant header. Requests without a tenant fall back to the client IP.
+func KeyByTenant(c *gin.Context) string {
+	if tenantID, err := TenantIDFromGinContext(c); err == nil {
+		return "tenant:" + tenantID
+	}
+	if tenantID := c.GetHeader(DefaultTenantHeader); tenantID != "" {
+		return "tenant:" + tenantID
 	}
+	return KeyByIP(c)
+}
 
-	// Simple cleanup routine (in production use a better cache like Redis)
-	go func() {
-		for {
-			time.Sleep(1 * time.Minute)
-			i.mu.Lock()
-			// This is a naive cleanup. A better approach tracks last seen time.
-			// For now, we just reset the map to prevent unbounded growth in dev.
-			// In production, use Redis with TTL.
-			if len(i.ips) > 10000 {
-				i.ips = make(map[string]*rate.Limiter)
-			}
-			i.mu.Unlock()
+// KeyByClient buckets requests by the OAuth client_id they name, in HTTP
+// Basic credentials or the client_id form parameter, within the client IP's
+// bucket. The client is not authenticated here, which would cost a lookup
+// and a secret hash before any request is turned away; nesting in the IP
+// keeps a caller from draining the bucket of a client it merely names.
+// Requests without a client_id are bucketed by client IP.
+func KeyByClient(c *gin.Context) string {
+	clientID, _, ok := c.Request.BasicAuth()
+	if ok {
+		// RFC 6749 section 2.3.1 form-encodes Basic credentials.
+		if unescaped, err := url.QueryUnescape(clientID); err == nil {
+			clientID = unescaped
 		}
-	}()
+	} else {
+		clientID = c.PostForm("client_id")
+	}
+	if clientID == "" {
+		return KeyByIP(c)
+	}
+	return KeyByIP(c) + ":client:" + clientID
+}
 
-	return i
+// RateLimitStore holds token buckets. Implementations must be safe for
+// concurrent use; the in-memory store suits a single instance, while a shared
+// backend (e.g. Redis) is needed when running several replicas.
+type RateLimitStore interface {
+	// Take removes one token from the bucket for key. When the bucket is empty
+	// it returns false and how long until a token will be available.
+	Take(ctx context.Context, key string, limit rate.Limit, burst int) (bool, time.Duration, error)
 }
 
-// GetLimiter returns the rate limiter for the given IP.
-func (i *IPRateLimiter) GetLimiter(ip string) *rate.Limiter {
-	i.mu.Lock()
-	defer i.mu.Unlock()
+// RateLimiterConfig configures RateLimiter.
+type RateLimiterConfig struct {
+	// Name namespaces the buckets so limiters on different route groups sharing
+	// a store do not consume each other's tokens.
+	Name string
+	// Limit is the refill rate in requests per second.
+	Limit rate.Limit
+	// Burst is the bucket capacity.
+	Burst int
+	// KeyFunc selects the bucket. Defaults to KeyByIP.
+	KeyFunc RateLimitKeyFunc
+	// Store holds the buckets. Defaults to a new MemoryRateLimitStore.
+	Store RateLimitStore
+}
 
-	limiter, exists := i.ips[ip]
-	if !exists {
-		limiter = rate.NewLimiter(i.r, i.b)
-		i.ips[ip] = limiter
+// RateLimiter returns a token-bucket rate limiting middleware. Requests over
+// the limit are rejected with 429 and a Retry-After header. Store errors fail
+// open so a backend outage does not take the API down.
+func RateLimiter(cfg RateLimiterConfig) gin.HandlerFunc {
+	keyFunc := cfg.KeyFunc
+	if keyFunc == nil {
+		keyFunc = KeyByIP
+	}
+	store := cfg.Store
+	if store == nil {
+		store = NewMemoryRateLimitStore()
 	}
 
-	return limiter
+	return func(c *gin.Context) {
+		key := keyFunc(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if cfg.Name != "" {
+			key = cfg.Name + ":" + key
+		}
+
+		allowed, retryAfter, err := store.Take(c.Request.Context(), key, cfg.Limit, cfg.Burst)
+		if err != nil || allowed {
+			c.Next()
+			return
+		}
+
+		seconds := int(math.Ceil(retryAfter.Seconds()))
+		if seconds < 1 {
+			seconds = 1
+		}
+		c.Header("Retry-After", strconv.Itoa(seconds))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+			"error": "Too many requests",
+		})
+	}
 }
 
-// RateLimitMiddleware creates a Gin middleware for rate limiting.
+// RateLimitMiddleware creates a Gin middleware for per-IP rate limiting.
 func RateLimitMiddleware(limit rate.Limit, burst int) gin.HandlerFunc {
-	limiter := NewIPRateLimiter(limit, burst)
+	return RateLimiter(RateLimiterConfig{Limit: limit, Burst: burst, KeyFunc: KeyByIP})
+}
 
-	return func(c *gin.Context) {
-		ip := c.ClientIP()
-		if !limiter.GetLimiter(ip).Allow() {
-			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
-				"error": "Too many requests",
-			})
-			return
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	// full is when the bucket will have refilled completely if left idle.
+	full time.Time
+}
+
+// MemoryRateLimitStore is an in-process RateLimitStore.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+// NewMemoryRateLimitStore creates an empty in-memory bucket store.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// memoryStoreSweepInterval is how often idle buckets are dropped.
+const memoryStoreSweepInterval = time.Minute
+
+// Take implements RateLimitStore.
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, limit rate.Limit, burst int) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(burst), last: now}
+		s.buckets[key] = bucket
+	}
+
+	// Refill for the time elapsed since the last request.
+	elapsed := now.Sub(bucket.last).Seconds()
+	bucket.tokens = math.Min(float64(burst), bucket.tokens+elapsed*float64(limit))
+	bucket.last = now
+
+	allowed := bucket.tokens >= 1
+	if allowed {
+		bucket.tokens--
+	}
+	if limit <= 0 {
+		bucket.full = now.Add(memoryStoreSweepInterval)
+		return allowed, memoryStoreSweepInterval, nil
+	}
+	bucket.full = now.Add(tokensToDuration(float64(burst)-bucket.tokens, limit))
+	if allowed {
+		return true, 0, nil
+	}
+	return false, tokensToDuration(1-bucket.tokens, limit), nil
+}
+
+func tokensToDuration(tokens float64, limit rate.Limit) time.Duration {
+	return time.Duration(tokens / float64(limit) * float64(time.Second))
+}
+
+// sweep drops buckets that have been idle long enough to be full again, which
+// is indistinguishable from having no bucket at all.
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < memoryStoreSweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, bucket := range s.buckets {
+		if now.After(bucket.full) {
+			delete(s.buckets, key)
 		}
-		c.Next()
 	}
 }
@@ -1,9 +1,12 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/time/rate"
@@ -50,3 +53,144 @@ func TestRateLimitMiddleware(t *testing.T) {
 		t.Errorf("Expected 429 Too Many Requests eventually, but got all OK")
 	}
 }
+
+func TestRateLimiterSetsRetryAfter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RateLimiter(RateLimiterConfig{Limit: rate.Limit(0.5), Burst: 1}))
+	r.GET("/", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 OK, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429, got %d", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Expected Retry-After 2, got %q", got)
+	}
+}
+
+func TestMemoryRateLimitStoreRefillsOverTime(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	now := time.Unix(1700000000, 0)
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if ok, _, _ := store.Take(ctx, "k", rate.Limit(1), 2); !ok {
+			t.Fatalf("Expected request %d within burst to be allowed", i+1)
+		}
+	}
+	ok, retryAfter, _ := store.Take(ctx, "k", rate.Limit(1), 2)
+	if ok {
+		t.Fatal("Expected empty bucket to reject")
+	}
+	if retryAfter != time.Second {
+		t.Errorf("Expected retry after 1s, got %v", retryAfter)
+	}
+
+	now = now.Add(time.Second)
+	if ok, _, _ := store.Take(ctx, "k", rate.Limit(1), 2); !ok {
+		t.Fatal("Expected bucket to refill one token after 1s")
+	}
+	if ok, _, _ := store.Take(ctx, "k", rate.Limit(1), 2); ok {
+		t.Fatal("Expected only one token to have refilled")
+	}
+
+	now = now.Add(time.Hour)
+	for i := 0; i < 2; i++ {
+		if ok, _, _ := store.Take(ctx, "k", rate.Limit(1), 2); !ok {
+			t.Fatalf("Expected refill to be capped at burst, request %d rejected", i+1)
+		}
+	}
+	if ok, _, _ := store.Take(ctx, "k", rate.Limit(1), 2); ok {
+		t.Fatal("Expected refill to be capped at burst")
+	}
+}
+
+func TestRateLimiterIsolatesKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RateLimiter(RateLimiterConfig{Limit: rate.Limit(0.001), Burst: 1, KeyFunc: KeyByTenant}))
+	r.GET("/", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	request := func(tenant string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(DefaultTenantHeader, tenant)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := request("11111111-1111-1111-1111-111111111111"); code != http.StatusOK {
+		t.Fatalf("Expected first tenant to be allowed, got %d", code)
+	}
+	if code := request("11111111-1111-1111-1111-111111111111"); code != http.StatusTooManyRequests {
+		t.Fatalf("Expected first tenant to be limited, got %d", code)
+	}
+	if code := request("22222222-2222-2222-2222-222222222222"); code != http.StatusOK {
+		t.Fatalf("Expected second tenant to have its own bucket, got %d", code)
+	}
+}
+
+func TestRateLimiterNamesSeparateSharedStore(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := NewMemoryRateLimitStore()
+	r := gin.New()
+	r.GET("/a", RateLimiter(RateLimiterConfig{Name: "a", Limit: rate.Limit(0.001), Burst: 1, Store: store}), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	r.GET("/b", RateLimiter(RateLimiterConfig{Name: "b", Limit: rate.Limit(0.001), Burst: 1, Store: store}), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for _, path := range []string{"/a", "/b"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected %s to have its own bucket, got %d", path, w.Code)
+		}
+	}
+}
+
+func TestKeyByClientNestsClientInIP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RateLimiter(RateLimiterConfig{Limit: rate.Limit(0.001), Burst: 1, KeyFunc: KeyByClient}))
+	r.POST("/token", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	request := func(ip, form string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/token", strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = ip + ":1234"
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := request("10.0.0.1", "client_id=orders&client_secret=wrong"); code != http.StatusOK {
+		t.Fatalf("Expected first request to be allowed, got %d", code)
+	}
+	if code := request("10.0.0.1", "client_id=orders&client_secret=s3cret"); code != http.StatusTooManyRequests {
+		t.Fatalf("Expected the client's bucket on this IP to be empty, got %d", code)
+	}
+	// Other clients on the same IP, and the same client elsewhere, keep their buckets.
+	if code := request("10.0.0.1", "client_id=billing"); code != http.StatusOK {
+		t.Fatalf("Expected another client to have its own bucket, got %d", code)
+	}
+	if code := request("10.0.0.2", "client_id=orders&client_secret=s3cret"); code != http.StatusOK {
+		t.Fatalf("Expected a caller elsewhere to be unaffected, got %d", code)
+	}
+}
@@ -1,25 +1,94 @@
 package middleware
 
-import "github.com/gin-gonic/gin"
+import (
+	"strconv"
+	"strings"
+	"time"
 
-// SecurityHeadersMiddleware adds common security headers to every response.
-func SecurityHeadersMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// Prevent MIME-sniffing
-		c.Writer.Header().Set("X-Content-Type-Options", "nosniff")
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// DefaultContentSecurityPolicy is a strict starting point for the HTML pages we serve
+	// (consent, login, SAML POST forms). Inline scripts/styles are allowed for the
+	// auto-submitting forms; tighten per deployment as needed.
+	DefaultContentSecurityPolicy = "default-src 'self'; script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline'; img-src 'self' data:; font-src 'self' data:; frame-ancestors 'none'"
+	// DefaultReferrerPolicy avoids leaking paths and query strings (codes, state) cross-origin.
+	DefaultReferrerPolicy = "strict-origin-when-cross-origin"
+	// DefaultFrameOptions denies framing to prevent clickjacking.
+	DefaultFrameOptions = "DENY"
+	// DefaultHSTSMaxAge is the Strict-Transport-Security max-age used when HSTS is enabled.
+	DefaultHSTSMaxAge = 365 * 24 * time.Hour
+)
 
-		// Deny framing to prevent clickjacking
-		c.Writer.Header().Set("X-Frame-Options", "DENY")
+// SecurityHeadersConfig configures the SecurityHeaders middleware. Empty string
+// fields fall back to the package defaults.
+type SecurityHeadersConfig struct {
+	// FrameOptions is the X-Frame-Options value.
+	FrameOptions string
+	// ReferrerPolicy is the Referrer-Policy value.
+	ReferrerPolicy string
+	// ContentSecurityPolicy is the Content-Security-Policy value.
+	ContentSecurityPolicy string
 
-		// Enable XSS protection (for older browsers)
-		c.Writer.Header().Set("X-XSS-Protection", "1; mode=block")
+	// HSTS enables Strict-Transport-Security. It is opt-in because browsers pin the
+	// host to HTTPS, which breaks plain-HTTP development setups.
+	HSTS bool
+	// HSTSMaxAge defaults to DefaultHSTSMaxAge.
+	HSTSMaxAge time.Duration
+	// HSTSIncludeSubdomains adds the includeSubDomains directive.
+	HSTSIncludeSubdomains bool
+	// HSTSPreload adds the preload directive.
+	HSTSPreload bool
+}
 
-		// Basic Content Security Policy (CSP)
-		// This is a strict starting point; adjust as needed for React/Vite.
-		// We allow 'self' and inline styles/scripts often needed by dev tools.
-		// In production, this should be tighter.
-		c.Writer.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline'; img-src 'self' data:; font-src 'self' data:")
+// SecurityHeaders returns a middleware that sets standard security headers on every response.
+func SecurityHeaders(cfg SecurityHeadersConfig) gin.HandlerFunc {
+	frameOptions := cfg.FrameOptions
+	if frameOptions == "" {
+		frameOptions = DefaultFrameOptions
+	}
+	referrerPolicy := cfg.ReferrerPolicy
+	if referrerPolicy == "" {
+		referrerPolicy = DefaultReferrerPolicy
+	}
+	csp := cfg.ContentSecurityPolicy
+	if csp == "" {
+		csp = DefaultContentSecurityPolicy
+	}
 
+	var hsts string
+	if cfg.HSTS {
+		maxAge := cfg.HSTSMaxAge
+		if maxAge <= 0 {
+			maxAge = DefaultHSTSMaxAge
+		}
+		directives := []string{"max-age=" + strconv.FormatInt(int64(maxAge/time.Second), 10)}
+		if cfg.HSTSIncludeSubdomains {
+			directives = append(directives, "includeSubDomains")
+		}
+		if cfg.HSTSPreload {
+			directives = append(directives, "preload")
+		}
+		hsts = strings.Join(directives, "; ")
+	}
+
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		// Prevent MIME-sniffing
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("X-Frame-Options", frameOptions)
+		header.Set("Referrer-Policy", referrerPolicy)
+		header.Set("Content-Security-Policy", csp)
+		if hsts != "" {
+			header.Set("Strict-Transport-Security", hsts)
+		}
 		c.Next()
 	}
 }
+
+// SecurityHeadersMiddleware adds common security headers to every response using
+// the default configuration (HSTS disabled).
+func SecurityHeadersMiddleware() gin.HandlerFunc {
+	return SecurityHeaders(SecurityHeadersConfig{})
+}
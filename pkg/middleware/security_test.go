@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func serveWithSecurityHeaders(cfg SecurityHeadersConfig) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(SecurityHeaders(cfg))
+	r.GET("/consent", func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte("<html></html>"))
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/consent", nil))
+	return w
+}
+
+func TestSecurityHeadersDefaults(t *testing.T) {
+	w := serveWithSecurityHeaders(SecurityHeadersConfig{})
+
+	expected := map[string]string{
+		"X-Content-Type-Options":  "nosniff",
+		"X-Frame-Options":         DefaultFrameOptions,
+		"Referrer-Policy":         DefaultReferrerPolicy,
+		"Content-Security-Policy": DefaultContentSecurityPolicy,
+	}
+	for name, value := range expected {
+		if got := w.Header().Get(name); got != value {
+			t.Errorf("Expected %s %q, got %q", name, value, got)
+		}
+	}
+	if got := w.Header().Get("Strict-Transport-Security"); got != "" {
+		t.Errorf("Expected no HSTS header when disabled, got %q", got)
+	}
+}
+
+func TestSecurityHeadersHSTSEnabled(t *testing.T) {
+	w := serveWithSecurityHeaders(SecurityHeadersConfig{
+		HSTS:                  true,
+		HSTSMaxAge:            24 * time.Hour,
+		HSTSIncludeSubdomains: true,
+		HSTSPreload:           true,
+	})
+
+	if got := w.Header().Get("Strict-Transport-Security"); got != "max-age=86400; includeSubDomains; preload" {
+		t.Errorf("Unexpected HSTS header %q", got)
+	}
+}
+
+func TestSecurityHeadersOverrides(t *testing.T) {
+	w := serveWithSecurityHeaders(SecurityHeadersConfig{
+		FrameOptions:          "SAMEORIGIN",
+		ReferrerPolicy:        "no-referrer",
+		ContentSecurityPolicy: "default-src 'none'",
+	})
+
+	if got := w.Header().Get("X-Frame-Options"); got != "SAMEORIGIN" {
+		t.Errorf("Expected X-Frame-Options override, got %q", got)
+	}
+	if got := w.Header().Get("Referrer-Policy"); got != "no-referrer" {
+		t.Errorf("Expected Referrer-Policy override, got %q", got)
+	}
+	if got := w.Header().Get("Content-Security-Policy"); got != "default-src 'none'" {
+		t.Errorf("Expected CSP override, got %q", got)
+	}
+}
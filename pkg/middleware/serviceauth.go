@@ -2,22 +2,47 @@ package middleware
 
 import (
 	"crypto/subtle"
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 )
 
 // DefaultServiceAuthHeader is the header name used to transmit the internal service token.
 const DefaultServiceAuthHeader = "X-Service-Token"
 
+const (
+	// DefaultServiceTokenTTL is the lifetime of tokens minted by SignServiceToken
+	// when no TTL is given.
+	DefaultServiceTokenTTL = time.Minute
+	// MaxServiceTokenTTL is the longest lifetime ServiceAuth accepts for a
+	// signed service token.
+	MaxServiceTokenTTL = 5 * time.Minute
+)
+
+// serviceNameContextKey holds the name of the authenticated calling service.
+const serviceNameContextKey = "serviceName"
+
 // ServiceAuthConfig controls how the service authentication middleware behaves.
 type ServiceAuthConfig struct {
 	HeaderName string
-	Token      string
+	// Token is the shared static token. It is still accepted while services
+	// move to signed tokens; leave it empty to require them.
+	Token string
+	// Audience is the name of the protected service. Signed tokens must name it
+	// in their aud claim.
+	Audience string
+	// Keys maps each calling service, the token's iss claim, to the HMAC key
+	// it signs with.
+	Keys map[string][]byte
 }
 
-// ServiceAuthenticator ensures that only trusted services can access protected routes.
-func ServiceAuthenticator(cfg ServiceAuthConfig) gin.HandlerFunc {
+// ServiceAuth ensures that only trusted services can access protected routes.
+// A caller presents either the static token or a short-lived HS256 JWT signed
+// with its own key and addressed to this service; see SignServiceToken.
+func ServiceAuth(cfg ServiceAuthConfig) gin.HandlerFunc {
 	headerName := cfg.HeaderName
 	if headerName == "" {
 		headerName = DefaultServiceAuthHeader
@@ -27,10 +52,72 @@ func ServiceAuthenticator(cfg ServiceAuthConfig) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 		provided := c.GetHeader(headerName)
-		if len(expected) == 0 || provided == "" || subtle.ConstantTimeCompare([]byte(provided), expected) != 1 {
+		if provided == "" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid service token"})
 			return
 		}
+		if len(expected) > 0 && subtle.ConstantTimeCompare([]byte(provided), expected) == 1 {
+			c.Next()
+			return
+		}
+		service, err := verifyServiceToken(provided, cfg.Audience, cfg.Keys)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid service token"})
+			return
+		}
+		c.Set(serviceNameContextKey, service)
 		c.Next()
 	}
 }
+
+// ServiceNameFromContext returns the calling service named by a signed service
+// token, or "" when the static token was used.
+func ServiceNameFromContext(c *gin.Context) string {
+	return c.GetString(serviceNameContextKey)
+}
+
+// SignServiceToken mints a service token from issuer to audience, signed with
+// issuer's key. A ttl of zero uses DefaultServiceTokenTTL.
+func SignServiceToken(issuer, audience string, key []byte, ttl time.Duration) (string, error) {
+	if len(key) == 0 {
+		return "", errors.New("service signing key is required")
+	}
+	if ttl <= 0 {
+		ttl = DefaultServiceTokenTTL
+	}
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		Issuer:    issuer,
+		Audience:  jwt.ClaimStrings{audience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
+}
+
+// verifyServiceToken checks a signed service token and returns its issuer.
+func verifyServiceToken(raw, audience string, keys map[string][]byte) (string, error) {
+	if audience == "" || len(keys) == 0 {
+		return "", errors.New("signed service tokens are not configured")
+	}
+	var claims jwt.RegisteredClaims
+	_, err := jwt.ParseWithClaims(raw, &claims, func(token *jwt.Token) (interface{}, error) {
+		key, ok := keys[claims.Issuer]
+		if !ok || len(key) == 0 {
+			return nil, errors.New("unknown service")
+		}
+		return key, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return "", err
+	}
+	if claims.IssuedAt == nil || claims.ExpiresAt.Sub(claims.IssuedAt.Time) > MaxServiceTokenTTL {
+		return "", errors.New("service token lifetime is too long")
+	}
+	return claims.Issuer, nil
+}
@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var testServiceKey = []byte("authsvc-signing-key-0123456789abcdef")
+
+func serveWithServiceAuth(token string) (*httptest.ResponseRecorder, string) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(ServiceAuth(ServiceAuthConfig{
+		Token:    "static-token",
+		Audience: "dirsvc",
+		Keys:     map[string][]byte{"authsvc": testServiceKey},
+	}))
+	var caller string
+	r.GET("/internal", func(c *gin.Context) {
+		caller = ServiceNameFromContext(c)
+		c.Status(http.StatusNoContent)
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/internal", nil)
+	if token != "" {
+		req.Header.Set(DefaultServiceAuthHeader, token)
+	}
+	r.ServeHTTP(w, req)
+	return w, caller
+}
+
+// signTestServiceToken signs claims with the authsvc key.
+func signTestServiceToken(t *testing.T, claims jwt.RegisteredClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(testServiceKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestServiceAuthAcceptsSignedToken(t *testing.T) {
+	token, err := SignServiceToken("authsvc", "dirsvc", testServiceKey, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, caller := serveWithServiceAuth(token)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204 for a valid service token, got %d", w.Code)
+	}
+	if caller != "authsvc" {
+		t.Errorf("Expected caller authsvc, got %q", caller)
+	}
+}
+
+func TestServiceAuthAcceptsStaticToken(t *testing.T) {
+	w, caller := serveWithServiceAuth("static-token")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204 for the static token, got %d", w.Code)
+	}
+	if caller != "" {
+		t.Errorf("Expected no caller name for the static token, got %q", caller)
+	}
+}
+
+func TestServiceAuthRejectsInvalidTokens(t *testing.T) {
+	now := time.Now()
+	valid := func() jwt.RegisteredClaims {
+		return jwt.RegisteredClaims{
+			Issuer:    "authsvc",
+			Audience:  jwt.ClaimStrings{"dirsvc"},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+		}
+	}
+	otherKey, err := SignServiceToken("authsvc", "dirsvc", []byte("not-the-authsvc-key"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"missing", ""},
+		{"wrong static token", "guess"},
+		{"expired", signTestServiceToken(t, func() jwt.RegisteredClaims {
+			c := valid()
+			c.IssuedAt = jwt.NewNumericDate(now.Add(-2 * time.Minute))
+			c.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Minute))
+			return c
+		}())},
+		{"wrong audience", signTestServiceToken(t, func() jwt.RegisteredClaims {
+			c := valid()
+			c.Audience = jwt.ClaimStrings{"govsvc"}
+			return c
+		}())},
+		{"unknown issuer", signTestServiceToken(t, func() jwt.RegisteredClaims {
+			c := valid()
+			c.Issuer = "govsvc"
+			return c
+		}())},
+		{"lifetime too long", signTestServiceToken(t, func() jwt.RegisteredClaims {
+			c := valid()
+			c.ExpiresAt = jwt.NewNumericDate(now.Add(time.Hour))
+			return c
+		}())},
+		{"wrong key", otherKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w, _ := serveWithServiceAuth(tt.token); w.Code != http.StatusUnauthorized {
+				t.Errorf("Expected 401, got %d", w.Code)
+			}
+		})
+	}
+}
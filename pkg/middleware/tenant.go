@@ -3,10 +3,15 @@ package middleware
 import (
 	"context"
 	"errors"
+	"net"
 	"net/http"
 	"regexp"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 )
 
 // DefaultTenantHeader is the HTTP header used to carry the tenant identifier when no
@@ -21,16 +26,45 @@ const tenantIDContextKey tenantContextKey = "tenantID"
 // uuidRegex is the regular expression for validating UUIDs.
 var uuidRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
 
+// DefaultTenantLookupTTL is how long TenantExtractor caches tenant lookups.
+const DefaultTenantLookupTTL = 30 * time.Second
+
+// TenantStatus is the result of a tenant lookup.
+type TenantStatus int
+
+const (
+	// TenantNotFound means no tenant exists with the given ID.
+	TenantNotFound TenantStatus = iota
+	// TenantActive means the tenant exists and may serve requests.
+	TenantActive
+	// TenantInactive means the tenant exists but is suspended or disabled.
+	TenantInactive
+)
+
+// TenantLookup reports whether a tenant exists and is active.
+type TenantLookup func(ctx context.Context, tenantID string) (TenantStatus, error)
+
+// TenantSource resolves the tenant identifier carried by a request. It returns ""
+// when the request has no tenant for this source so the next source can be tried.
+type TenantSource func(c *gin.Context) (string, error)
+
 // TenantConfig captures the knobs for tenant extraction.
 type TenantConfig struct {
 	// HeaderName is the HTTP header inspected for the tenant identifier. Defaults
-	// to DefaultTenantHeader when empty.
+	// to DefaultTenantHeader when empty. Ignored when Sources is set.
 	HeaderName string
+	// Sources are consulted in order and the first non-empty tenant wins. Defaults
+	// to the HeaderName header.
+	Sources []TenantSource
 	// AllowFallback allows requests without the header to use DefaultTenantID instead
 	// of being rejected.
 	AllowFallback bool
 	// DefaultTenantID is used when AllowFallback is true and no header value is set.
 	DefaultTenantID string
+	// Lookup, when set, rejects unknown tenants with 404 and inactive tenants with 403.
+	Lookup TenantLookup
+	// LookupTTL bounds how long lookup results are cached. Defaults to DefaultTenantLookupTTL.
+	LookupTTL time.Duration
 }
 
 // TenantExtractor returns a Gin middleware that reads the tenant identifier from
@@ -41,8 +75,35 @@ func TenantExtractor(cfg TenantConfig) gin.HandlerFunc {
 		headerName = DefaultTenantHeader
 	}
 
+	sources := cfg.Sources
+	if len(sources) == 0 {
+		sources = []TenantSource{TenantFromHeader(headerName)}
+	}
+
+	var lookup *tenantLookupCache
+	if cfg.Lookup != nil {
+		ttl := cfg.LookupTTL
+		if ttl <= 0 {
+			ttl = DefaultTenantLookupTTL
+		}
+		lookup = newTenantLookupCache(cfg.Lookup, ttl)
+	}
+
 	return func(c *gin.Context) {
-		tenantID := c.GetHeader(headerName)
+		var tenantID string
+		for _, source := range sources {
+			id, err := source(c)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+					"error": "failed to resolve tenant",
+				})
+				return
+			}
+			if id != "" {
+				tenantID = id
+				break
+			}
+		}
 		if tenantID == "" {
 			if cfg.AllowFallback && cfg.DefaultTenantID != "" {
 				tenantID = cfg.DefaultTenantID
@@ -62,6 +123,29 @@ func TenantExtractor(cfg TenantConfig) gin.HandlerFunc {
 			return
 		}
 
+		if lookup != nil {
+			status, err := lookup.status(c.Request.Context(), tenantID)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+					"error": "failed to resolve tenant",
+				})
+				return
+			}
+			switch status {
+			case TenantActive:
+			case TenantInactive:
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+					"error": "tenant is not active",
+				})
+				return
+			default:
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+					"error": "tenant not found",
+				})
+				return
+			}
+		}
+
 		c.Set(string(tenantIDContextKey), tenantID)
 		ctx := context.WithValue(c.Request.Context(), tenantIDContextKey, tenantID)
 		c.Request = c.Request.WithContext(ctx)
@@ -69,6 +153,102 @@ func TenantExtractor(cfg TenantConfig) gin.HandlerFunc {
 	}
 }
 
+type tenantLookupEntry struct {
+	status    TenantStatus
+	expiresAt time.Time
+}
+
+// tenantLookupCache memoizes tenant lookups so validation does not cost a
+// database round trip per request. Errors are not cached.
+type tenantLookupCache struct {
+	lookup  TenantLookup
+	ttl     time.Duration
+	now     func() time.Time
+	mu      sync.Mutex
+	entries map[string]tenantLookupEntry
+}
+
+func newTenantLookupCache(lookup TenantLookup, ttl time.Duration) *tenantLookupCache {
+	return &tenantLookupCache{
+		lookup:  lookup,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]tenantLookupEntry),
+	}
+}
+
+func (c *tenantLookupCache) status(ctx context.Context, tenantID string) (TenantStatus, error) {
+	now := c.now()
+	c.mu.Lock()
+	entry, ok := c.entries[tenantID]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry.status, nil
+	}
+
+	status, err := c.lookup(ctx, tenantID)
+	if err != nil {
+		return TenantNotFound, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Sweep expired entries so IDs probed once do not accumulate.
+	for id, e := range c.entries {
+		if !now.Before(e.expiresAt) {
+			delete(c.entries, id)
+		}
+	}
+	c.entries[tenantID] = tenantLookupEntry{status: status, expiresAt: now.Add(c.ttl)}
+	return status, nil
+}
+
+// TenantFromHeader reads the tenant identifier from the named request header.
+func TenantFromHeader(name string) TenantSource {
+	return func(c *gin.Context) (string, error) {
+		return c.GetHeader(name), nil
+	}
+}
+
+// TenantFromSubdomain matches the request host against pattern and uses its first
+// capture group, e.g. `^([a-z0-9-]+)\.wardseal\.com$` for acme.wardseal.com. When
+// resolve is non-nil the captured label (typically a tenant slug) is mapped to the
+// tenant ID; resolve returns "" for unknown labels.
+func TenantFromSubdomain(pattern *regexp.Regexp, resolve func(ctx context.Context, label string) (string, error)) TenantSource {
+	return func(c *gin.Context) (string, error) {
+		host := c.Request.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		matches := pattern.FindStringSubmatch(strings.ToLower(host))
+		if len(matches) < 2 || matches[1] == "" {
+			return "", nil
+		}
+		if resolve == nil {
+			return matches[1], nil
+		}
+		return resolve(c.Request.Context(), matches[1])
+	}
+}
+
+// TenantFromJWTClaim reads the tenant identifier from a claim of the bearer token.
+// The token signature is verified with keyFunc; requests with a missing or invalid
+// token fall through to the next source rather than being rejected here.
+func TenantFromJWTClaim(claim string, keyFunc jwt.Keyfunc) TenantSource {
+	return func(c *gin.Context) (string, error) {
+		tokenString, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || tokenString == "" {
+			return "", nil
+		}
+		claims := jwt.MapClaims{}
+		if _, err := jwt.ParseWithClaims(tokenString, claims, keyFunc); err != nil {
+			return "", nil
+		}
+		tenantID, _ := claims[claim].(string)
+		return tenantID, nil
+	}
+}
+
 // TenantIDFromGinContext extracts the tenant identifier previously stored by TenantExtractor.
 func TenantIDFromGinContext(c *gin.Context) (string, error) {
 	if value, ok := c.Get(string(tenantIDContextKey)); ok {
@@ -4,9 +4,11 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 )
 
 const testTenantUUID = "11111111-1111-1111-1111-111111111111"
@@ -74,6 +76,135 @@ func TestTenantExtractorInvalidUUID(t *testing.T) {
 	}
 }
 
+func newTenantSourceRouter(cfg TenantConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(TenantExtractor(cfg))
+	r.GET("/ping", func(c *gin.Context) {
+		tenantID, _ := TenantIDFromGinContext(c)
+		c.String(http.StatusOK, tenantID)
+	})
+	return r
+}
+
+var testTenantJWTKey = []byte("tenant-test-key")
+
+func signedTenantToken(t *testing.T, tenantID string) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"tenant": tenantID}).SignedString(testTenantJWTKey)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return token
+}
+
+func testTenantKeyFunc(*jwt.Token) (interface{}, error) {
+	return testTenantJWTKey, nil
+}
+
+func TestTenantExtractorSubdomainSource(t *testing.T) {
+	const otherTenant = "22222222-2222-2222-2222-222222222222"
+	r := newTenantSourceRouter(TenantConfig{
+		Sources: []TenantSource{
+			TenantFromSubdomain(regexp.MustCompile(`^([a-z0-9-]+)\.wardseal\.com$`), func(_ context.Context, label string) (string, error) {
+				if label == "acme" {
+					return otherTenant, nil
+				}
+				return "", nil
+			}),
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.Host = "acme.wardseal.com:8443"
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+
+	if res.Code != http.StatusOK || res.Body.String() != otherTenant {
+		t.Fatalf("expected tenant %s from subdomain, got %d %q", otherTenant, res.Code, res.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.Host = "unknown.wardseal.com"
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+
+	if res.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown subdomain, got %d", res.Code)
+	}
+}
+
+func TestTenantExtractorJWTClaimSource(t *testing.T) {
+	r := newTenantSourceRouter(TenantConfig{
+		Sources: []TenantSource{TenantFromJWTClaim("tenant", testTenantKeyFunc)},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.Header.Set("Authorization", "Bearer "+signedTenantToken(t, testTenantUUID))
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+
+	if res.Code != http.StatusOK || res.Body.String() != testTenantUUID {
+		t.Fatalf("expected tenant %s from JWT claim, got %d %q", testTenantUUID, res.Code, res.Body.String())
+	}
+
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"tenant": testTenantUUID}).SignedString([]byte("other-key"))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.Header.Set("Authorization", "Bearer "+forged)
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+
+	if res.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for token with invalid signature, got %d", res.Code)
+	}
+}
+
+func TestTenantExtractorSourcePriority(t *testing.T) {
+	const claimTenant = "33333333-3333-3333-3333-333333333333"
+	r := newTenantSourceRouter(TenantConfig{
+		Sources: []TenantSource{
+			TenantFromHeader(DefaultTenantHeader),
+			TenantFromJWTClaim("tenant", testTenantKeyFunc),
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.Header.Set(DefaultTenantHeader, testTenantUUID)
+	req.Header.Set("Authorization", "Bearer "+signedTenantToken(t, claimTenant))
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+
+	if res.Body.String() != testTenantUUID {
+		t.Fatalf("expected header tenant to take priority, got %q", res.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.Header.Set("Authorization", "Bearer "+signedTenantToken(t, claimTenant))
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+
+	if res.Body.String() != claimTenant {
+		t.Fatalf("expected fallback to JWT claim tenant, got %q", res.Body.String())
+	}
+}
+
+func TestTenantExtractorRejectsMalformedClaim(t *testing.T) {
+	r := newTenantSourceRouter(TenantConfig{
+		Sources: []TenantSource{TenantFromJWTClaim("tenant", testTenantKeyFunc)},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.Header.Set("Authorization", "Bearer "+signedTenantToken(t, "acme"))
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+
+	if res.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for malformed tenant claim, got %d", res.Code)
+	}
+}
+
 func TestTenantIDFromContext(t *testing.T) {
 	ctx := context.WithValue(context.Background(), tenantIDContextKey, testTenantUUID)
 	tenantID, err := TenantIDFromContext(ctx)
@@ -84,3 +215,58 @@ func TestTenantIDFromContext(t *testing.T) {
 		t.Fatalf("unexpected tenant id: %s", tenantID)
 	}
 }
+
+func TestTenantExtractorLookupRejectsUnknownAndInactive(t *testing.T) {
+	const inactiveTenant = "44444444-4444-4444-4444-444444444444"
+	r := newTenantSourceRouter(TenantConfig{
+		Lookup: func(_ context.Context, tenantID string) (TenantStatus, error) {
+			switch tenantID {
+			case testTenantUUID:
+				return TenantActive, nil
+			case inactiveTenant:
+				return TenantInactive, nil
+			}
+			return TenantNotFound, nil
+		},
+	})
+
+	cases := map[string]int{
+		testTenantUUID:                         http.StatusOK,
+		inactiveTenant:                         http.StatusForbidden,
+		"55555555-5555-5555-5555-555555555555": http.StatusNotFound,
+	}
+	for tenantID, want := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		req.Header.Set(DefaultTenantHeader, tenantID)
+		res := httptest.NewRecorder()
+		r.ServeHTTP(res, req)
+
+		if res.Code != want {
+			t.Errorf("tenant %s: expected %d, got %d", tenantID, want, res.Code)
+		}
+	}
+}
+
+func TestTenantExtractorLookupIsCached(t *testing.T) {
+	lookups := 0
+	r := newTenantSourceRouter(TenantConfig{
+		Lookup: func(context.Context, string) (TenantStatus, error) {
+			lookups++
+			return TenantActive, nil
+		},
+	})
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		req.Header.Set(DefaultTenantHeader, testTenantUUID)
+		res := httptest.NewRecorder()
+		r.ServeHTTP(res, req)
+
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", res.Code)
+		}
+	}
+	if lookups != 1 {
+		t.Fatalf("expected a single lookup for repeated requests, got %d", lookups)
+	}
+}
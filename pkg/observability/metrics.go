@@ -1,10 +1,12 @@
 package observability
 
 import (
+	"hash/fnv"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/dhawalhost/wardseal/pkg/middleware"
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -18,7 +20,7 @@ func NewMetrics() *Metrics {
 				Name: "http_requests_total",
 				Help: "Total number of HTTP requests.",
 			},
-			[]string{"code", "method", "path"},
+			[]string{"code", "method", "route", "tenant"},
 		),
 		RequestDuration: prometheus.NewHistogramVec(
 			prometheus.HistogramOpts{
@@ -26,7 +28,7 @@ func NewMetrics() *Metrics {
 				Help:    "Histogram of latencies for HTTP requests.",
 				Buckets: prometheus.DefBuckets,
 			},
-			[]string{"code", "method", "path"},
+			[]string{"code", "method", "route", "tenant"},
 		),
 		TokensIssued: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
@@ -55,6 +57,21 @@ func NewMetrics() *Metrics {
 				Help: "Total number of token revocation requests.",
 			},
 		),
+		PermissionCacheLookups: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "rbac_permission_cache_lookups_total",
+				Help: "Total number of effective-permission cache lookups.",
+			},
+			[]string{"result"},
+		),
+		GovernanceOperationDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "governance_operation_duration_seconds",
+				Help:    "Histogram of latencies for governance service operations.",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"operation", "result"},
+		),
 	}
 	prometheus.MustRegister(m.RequestsTotal)
 	prometheus.MustRegister(m.RequestDuration)
@@ -62,6 +79,8 @@ func NewMetrics() *Metrics {
 	prometheus.MustRegister(m.TokenErrors)
 	prometheus.MustRegister(m.TokenIntrospections)
 	prometheus.MustRegister(m.TokenRevocations)
+	prometheus.MustRegister(m.PermissionCacheLookups)
+	prometheus.MustRegister(m.GovernanceOperationDuration)
 	return m
 }
 
@@ -73,6 +92,12 @@ type Metrics struct {
 	TokenErrors         *prometheus.CounterVec
 	TokenIntrospections *prometheus.CounterVec
 	TokenRevocations    prometheus.Counter
+	// PermissionCacheLookups is labelled result="hit" or "miss"; the hit rate
+	// is hits over the sum.
+	PermissionCacheLookups *prometheus.CounterVec
+	// GovernanceOperationDuration is labelled with the service method and
+	// result="success" or "error"; its _count is the number of calls.
+	GovernanceOperationDuration *prometheus.HistogramVec
 }
 
 // RecordTokenIssued records a successful token issuance.
@@ -99,18 +124,84 @@ func (m *Metrics) RecordTokenRevocation() {
 	m.TokenRevocations.Inc()
 }
 
+// RecordPermissionCacheLookup records an effective-permission cache lookup.
+func (m *Metrics) RecordPermissionCacheLookup(hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.PermissionCacheLookups.WithLabelValues(result).Inc()
+}
+
+// RecordGovernanceOperation records a governance service call.
+func (m *Metrics) RecordGovernanceOperation(operation string, duration time.Duration, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	m.GovernanceOperationDuration.WithLabelValues(operation, result).Observe(duration.Seconds())
+}
+
+// Label values PrometheusMiddleware uses in place of a route or tenant ID.
+const (
+	// UnmatchedRoute labels requests that matched no route, so scans of
+	// unknown paths do not each add a series.
+	UnmatchedRoute = "unmatched"
+	// NoTenant labels requests without a tenant.
+	NoTenant = "none"
+	// OtherTenant labels tenants outside the allowlist when TenantBuckets is 0.
+	OtherTenant = "other"
+)
+
+// PrometheusConfig bounds the cardinality of the tenant label recorded by
+// PrometheusMiddleware.
+type PrometheusConfig struct {
+	// TenantAllowlist are the tenants labelled with their own ID.
+	TenantAllowlist []string
+	// TenantBuckets, when positive, labels every other tenant "bucket-N" by a
+	// hash of its ID, N < TenantBuckets; otherwise they are all OtherTenant.
+	TenantBuckets int
+}
+
+// tenantLabel returns the label value for tenantID.
+func (cfg PrometheusConfig) tenantLabel(allowed map[string]bool, tenantID string) string {
+	switch {
+	case tenantID == "":
+		return NoTenant
+	case allowed[tenantID]:
+		return tenantID
+	case cfg.TenantBuckets > 0:
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(tenantID))
+		return "bucket-" + strconv.Itoa(int(h.Sum32()%uint32(cfg.TenantBuckets)))
+	}
+	return OtherTenant
+}
+
 // PrometheusMiddleware returns a Gin middleware that records Prometheus metrics for HTTP requests.
-func PrometheusMiddleware(metrics *Metrics) gin.HandlerFunc {
+// Requests are labelled by route template, e.g. /api/v1/oauth/clients/:id,
+// rather than raw path, and by tenant as PrometheusConfig allows.
+func PrometheusMiddleware(metrics *Metrics, cfg PrometheusConfig) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(cfg.TenantAllowlist))
+	for _, tenantID := range cfg.TenantAllowlist {
+		allowed[tenantID] = true
+	}
 	return func(c *gin.Context) {
 		start := time.Now()
 		c.Next() // Process request
 
 		statusCode := strconv.Itoa(c.Writer.Status())
-		path := c.Request.URL.Path
+		route := c.FullPath()
+		if route == "" {
+			route = UnmatchedRoute
+		}
 		method := c.Request.Method
+		// The tenant is known once the route's TenantExtractor has run.
+		tenantID, _ := middleware.TenantIDFromGinContext(c)
+		tenant := cfg.tenantLabel(allowed, tenantID)
 
-		metrics.RequestsTotal.WithLabelValues(statusCode, method, path).Inc()
-		metrics.RequestDuration.WithLabelValues(statusCode, method, path).Observe(time.Since(start).Seconds())
+		metrics.RequestsTotal.WithLabelValues(statusCode, method, route, tenant).Inc()
+		metrics.RequestDuration.WithLabelValues(statusCode, method, route, tenant).Observe(time.Since(start).Seconds())
 	}
 }
 
@@ -0,0 +1,77 @@
+package observability
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// NewMetrics registers with the default registry, so tests share one set.
+var testMetrics = NewMetrics()
+
+func newMetricsRouter(cfg PrometheusConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(PrometheusMiddleware(testMetrics, cfg))
+	api := router.Group("/api/v1")
+	api.Use(middleware.TenantExtractor(middleware.TenantConfig{}))
+	api.GET("/clients/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return router
+}
+
+func request(router *gin.Engine, path, tenantID string) {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if tenantID != "" {
+		req.Header.Set(middleware.DefaultTenantHeader, tenantID)
+	}
+	router.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func TestPrometheusMiddlewareLabelsRouteTemplate(t *testing.T) {
+	testMetrics.RequestsTotal.Reset()
+	router := newMetricsRouter(PrometheusConfig{})
+
+	request(router, "/api/v1/clients/a", "11111111-1111-1111-1111-111111111111")
+	request(router, "/api/v1/clients/b", "11111111-1111-1111-1111-111111111111")
+	request(router, "/does-not-exist", "")
+
+	if n := testutil.CollectAndCount(testMetrics.RequestsTotal); n != 2 {
+		t.Fatalf("expected 2 series (one route, one unmatched), got %d", n)
+	}
+	if got := testutil.ToFloat64(testMetrics.RequestsTotal.WithLabelValues("200", http.MethodGet, "/api/v1/clients/:id", OtherTenant)); got != 2 {
+		t.Fatalf("expected /clients/a and /clients/b under one route, got %v", got)
+	}
+	if got := testutil.ToFloat64(testMetrics.RequestsTotal.WithLabelValues("404", http.MethodGet, UnmatchedRoute, NoTenant)); got != 1 {
+		t.Fatalf("expected the unknown path labelled %s, got %v", UnmatchedRoute, got)
+	}
+}
+
+func TestPrometheusMiddlewareBoundsTenantLabel(t *testing.T) {
+	testMetrics.RequestsTotal.Reset()
+	allowed := "11111111-1111-1111-1111-111111111111"
+	router := newMetricsRouter(PrometheusConfig{TenantAllowlist: []string{allowed}, TenantBuckets: 4})
+
+	request(router, "/api/v1/clients/a", allowed)
+	for _, tenantID := range []string{
+		"22222222-2222-2222-2222-222222222222",
+		"33333333-3333-3333-3333-333333333333",
+		"44444444-4444-4444-4444-444444444444",
+		"55555555-5555-5555-5555-555555555555",
+		"66666666-6666-6666-6666-666666666666",
+		"77777777-7777-7777-7777-777777777777",
+	} {
+		request(router, "/api/v1/clients/a", tenantID)
+	}
+
+	if got := testutil.ToFloat64(testMetrics.RequestsTotal.WithLabelValues("200", http.MethodGet, "/api/v1/clients/:id", allowed)); got != 1 {
+		t.Fatalf("expected the allowlisted tenant labelled by ID, got %v", got)
+	}
+	// Six other tenants share at most four bucket labels.
+	if n := testutil.CollectAndCount(testMetrics.RequestsTotal); n > 1+4 {
+		t.Fatalf("expected at most 5 series, got %d", n)
+	}
+}
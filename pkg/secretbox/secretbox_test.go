@@ -0,0 +1,40 @@
+package secretbox
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestCipherRoundTrip(t *testing.T) {
+	c, err := New(bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := c.Encrypt("s3cret", "tenant-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := c.Decrypt(sealed, "tenant-a"); err != nil || got != "s3cret" {
+		t.Fatalf("expected s3cret, got %q, %v", got, err)
+	}
+	if _, err := c.Decrypt(sealed, "tenant-b"); err == nil {
+		t.Fatal("expected decrypting with other additional data to fail")
+	}
+	if got, _ := c.Decrypt("legacy", "tenant-a"); got != "legacy" {
+		t.Fatalf("expected plaintext to pass through, got %q", got)
+	}
+}
+
+func TestNilCipherStoresPlaintext(t *testing.T) {
+	var c *Cipher
+	if got, _ := c.Encrypt("s3cret", "tenant-a"); got != "s3cret" {
+		t.Fatalf("expected plaintext, got %q", got)
+	}
+
+	keyed, _ := New(bytes.Repeat([]byte{1}, 32))
+	sealed, _ := keyed.Encrypt("s3cret", "tenant-a")
+	if _, err := c.Decrypt(sealed, "tenant-a"); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("expected ErrNoKey, got %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ReadinessPath is where a Gate reports whether the service may take traffic.
+const ReadinessPath = "/readyz"
+
+// DefaultReadinessInterval is how often a Gate retries failing checks.
+const DefaultReadinessInterval = 2 * time.Second
+
+// Check reports whether a dependency the service needs before serving, such
+// as the database schema, is in place.
+type Check func(ctx context.Context) error
+
+// Gate answers ReadinessPath with 503 until every check has passed once, and
+// passes all other requests to the wrapped handler. Checks run when the
+// runner starts serving and are retried until they pass; readiness then
+// stays latched so a slow check cannot flap the service out of rotation.
+type Gate struct {
+	next     http.Handler
+	checks   []Check
+	interval time.Duration
+
+	// OnFailure, when set, is called with each failed check's error so the
+	// service can log why it is not ready yet.
+	OnFailure func(error)
+
+	mu    sync.RWMutex
+	ready bool
+}
+
+// NewGate wraps handler with a readiness gate over checks. A gate without
+// checks is ready as soon as it is created.
+func NewGate(handler http.Handler, checks ...Check) *Gate {
+	return &Gate{
+		next:     handler,
+		checks:   checks,
+		interval: DefaultReadinessInterval,
+		ready:    len(checks) == 0,
+	}
+}
+
+// Ready reports whether every check has passed.
+func (g *Gate) Ready() bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.ready
+}
+
+// ServeHTTP implements http.Handler.
+func (g *Gate) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != ReadinessPath {
+		g.next.ServeHTTP(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if g.Ready() {
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(map[string]string{"status": "ready"})
+		return
+	}
+	// The failure itself is not reported; it may name internal hosts.
+	w.WriteHeader(http.StatusServiceUnavailable)
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "not_ready"})
+}
+
+// Run runs the checks every interval until they all pass or ctx is done.
+// The runners call it when handler is a Gate.
+func (g *Gate) Run(ctx context.Context) {
+	ticker := time.NewTicker(g.interval)
+	defer ticker.Stop()
+	for !g.check(ctx) {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check runs the checks in order, stopping at the first failure.
+func (g *Gate) check(ctx context.Context) bool {
+	for _, check := range g.checks {
+		if err := check(ctx); err != nil {
+			if g.OnFailure != nil {
+				g.OnFailure(err)
+			}
+			return false
+		}
+	}
+	g.mu.Lock()
+	g.ready = true
+	g.mu.Unlock()
+	return true
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dhawalhost/wardseal/pkg/database"
+)
+
+func readyzStatus(t *testing.T, h http.Handler) int {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, ReadinessPath, nil))
+	return w.Code
+}
+
+func TestGateStaysUnreadyWhileSchemaIsOutdated(t *testing.T) {
+	var version atomic.Uint32
+	version.Store(40)
+	schemaCheck := func(context.Context) error {
+		return database.CheckSchemaVersion(uint(version.Load()), false, 43)
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusTeapot) })
+	gate := NewGate(next, schemaCheck)
+	gate.interval = 10 * time.Millisecond
+	failures := make(chan error, 100)
+	gate.OnFailure = func(err error) {
+		select {
+		case failures <- err:
+		default:
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go gate.Run(ctx)
+
+	// Wait for a few failed rounds so the check has really run.
+	for range 3 {
+		select {
+		case <-failures:
+		case <-time.After(time.Second):
+			t.Fatal("schema check did not run")
+		}
+	}
+	if code := readyzStatus(t, gate); code != http.StatusServiceUnavailable {
+		t.Fatalf("readyz with outdated schema = %d, want 503", code)
+	}
+	// Other routes are served regardless, so liveness probes keep passing.
+	w := httptest.NewRecorder()
+	gate.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if w.Code != http.StatusTeapot {
+		t.Fatalf("other route = %d, want it passed through", w.Code)
+	}
+
+	version.Store(43)
+	deadline := time.Now().Add(time.Second)
+	for readyzStatus(t, gate) != http.StatusOK {
+		if time.Now().After(deadline) {
+			t.Fatal("gate did not become ready after the schema was migrated")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestGateWithoutChecksIsReady(t *testing.T) {
+	gate := NewGate(http.NotFoundHandler())
+	if code := readyzStatus(t, gate); code != http.StatusOK {
+		t.Fatalf("readyz = %d, want 200", code)
+	}
+}
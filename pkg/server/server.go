@@ -0,0 +1,107 @@
+// Package server runs HTTP services, optionally over TLS, with graceful
+// shutdown.
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// DefaultShutdownTimeout is how long in-flight requests may run after a
+// shutdown signal before their connections are closed.
+const DefaultShutdownTimeout = 30 * time.Second
+
+// Run serves handler on addr until the process receives SIGINT or SIGTERM,
+// then shuts down gracefully: it stops accepting connections, waits up to
+// DefaultShutdownTimeout for in-flight requests and closes closers, such as
+// the database pool, once no request can still use them. When handler is a
+// Gate, its readiness checks start with the server.
+func Run(handler http.Handler, addr string, closers ...io.Closer) error {
+	ctx, stop := ShutdownContext()
+	defer stop()
+	return RunContext(ctx, handler, addr, closers...)
+}
+
+// ShutdownContext returns a context cancelled when the process receives
+// SIGINT or SIGTERM, for starting background work that should stop with the
+// server run by RunContext or RunTLSContext.
+func ShutdownContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+}
+
+// RunContext is Run, shutting down when ctx is done instead of on a signal.
+func RunContext(ctx context.Context, handler http.Handler, addr string, closers ...io.Closer) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return errors.Join(err, closeAll(closers))
+	}
+	return Serve(ctx, ln, handler, DefaultShutdownTimeout, closers...)
+}
+
+// CloserFunc adapts a function to io.Closer, e.g. to wait for background
+// work to stop before later closers release what it uses.
+type CloserFunc func() error
+
+// Close calls f.
+func (f CloserFunc) Close() error {
+	return f()
+}
+
+// Serve serves handler on ln until ctx is done and then shuts down as Run
+// does, waiting at most timeout for in-flight requests.
+func Serve(ctx context.Context, ln net.Listener, handler http.Handler, timeout time.Duration, closers ...io.Closer) error {
+	srv := newServer(handler)
+	return serve(ctx, srv, func() error { return srv.Serve(ln) }, timeout, closers)
+}
+
+func newServer(handler http.Handler) *http.Server {
+	return &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
+
+// serve runs start until ctx is done, then shuts srv down and closes closers.
+func serve(ctx context.Context, srv *http.Server, start func() error, timeout time.Duration, closers []io.Closer) error {
+	if gate, ok := srv.Handler.(*Gate); ok {
+		go gate.Run(ctx)
+	}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- start() }()
+
+	var err error
+	select {
+	case err = <-serveErr:
+		// The server failed on its own; there is nothing to drain.
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		err = srv.Shutdown(shutdownCtx)
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = errors.Join(err, srv.Close())
+		}
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		err = nil
+	}
+	return errors.Join(err, closeAll(closers))
+}
+
+func closeAll(closers []io.Closer) error {
+	var errs []error
+	for _, c := range closers {
+		if c == nil {
+			continue
+		}
+		if err := c.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
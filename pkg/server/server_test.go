@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type closeRecorder struct{ closed chan struct{} }
+
+func (c *closeRecorder) Close() error {
+	close(c.closed)
+	return nil
+}
+
+func TestServeCompletesInFlightRequestOnShutdown(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		_, _ = io.WriteString(w, "done")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	db := &closeRecorder{closed: make(chan struct{})}
+	served := make(chan error, 1)
+	go func() { served <- Serve(ctx, ln, handler, 5*time.Second, db) }()
+
+	type result struct {
+		body string
+		err  error
+	}
+	responses := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			responses <- result{err: err}
+			return
+		}
+		defer func() { _ = resp.Body.Close() }()
+		body, err := io.ReadAll(resp.Body)
+		responses <- result{string(body), err}
+	}()
+
+	<-started
+	cancel()
+
+	// The pool must stay open while the request is still running.
+	select {
+	case <-db.closed:
+		t.Fatal("closers ran before the in-flight request finished")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if _, err := net.DialTimeout("tcp", ln.Addr().String(), time.Second); err == nil {
+		t.Error("expected new connections to be refused during shutdown")
+	}
+
+	close(release)
+	if r := <-responses; r.err != nil || r.body != "done" {
+		t.Fatalf("expected in-flight request to complete, got %q, %v", r.body, r.err)
+	}
+	if err := <-served; err != nil {
+		t.Fatalf("Serve returned %v", err)
+	}
+	select {
+	case <-db.closed:
+	default:
+		t.Fatal("expected closers to run after shutdown")
+	}
+}
+
+func TestServeForcesCloseAfterTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- Serve(ctx, ln, handler, 50*time.Millisecond) }()
+	go func() {
+		if resp, err := http.Get("http://" + ln.Addr().String()); err == nil {
+			_ = resp.Body.Close()
+		}
+	}()
+
+	<-started
+	cancel()
+	select {
+	case err := <-served:
+		if err == nil {
+			t.Fatal("expected a timeout error when requests outlive the shutdown timeout")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after the shutdown timeout")
+	}
+}
+
+func TestServeWaitsForBackgroundWorkBeforeLaterClosers(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	var workerStopped bool
+	go func() {
+		<-ctx.Done()
+		time.Sleep(50 * time.Millisecond) // finishing a task
+		workerStopped = true
+		close(stopped)
+	}()
+
+	db := &closeRecorder{closed: make(chan struct{})}
+	wait := CloserFunc(func() error { <-stopped; return nil })
+	served := make(chan error, 1)
+	go func() { served <- Serve(ctx, ln, http.NotFoundHandler(), time.Second, wait, db) }()
+
+	cancel()
+	<-db.closed
+	if !workerStopped {
+		t.Fatal("expected the pool to close only after the worker stopped")
+	}
+	if err := <-served; err != nil {
+		t.Fatalf("Serve returned %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"time"
+)
+
+// TLSOptions selects the certificate a service presents. TLS is enabled when
+// a certificate and key file are given, or when SelfSigned is set.
+type TLSOptions struct {
+	CertFile string
+	KeyFile  string
+	// SelfSigned generates an ephemeral certificate at startup when no files
+	// are given. It is meant for development only.
+	SelfSigned bool
+	// Hosts are the names and IPs a self-signed certificate is valid for.
+	// Defaults to localhost.
+	Hosts []string
+	// MinVersion defaults to TLS 1.2.
+	MinVersion uint16
+}
+
+// Enabled reports whether the options turn TLS on.
+func (o TLSOptions) Enabled() bool {
+	return o.CertFile != "" || o.KeyFile != "" || o.SelfSigned
+}
+
+// cipherSuites are the TLS 1.2 suites offered: forward-secret AEAD ciphers
+// only. TLS 1.3 suites are not configurable and are all modern.
+var cipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// NewTLSConfig builds the server TLS configuration for opts. It returns nil
+// when TLS is not enabled.
+func NewTLSConfig(opts TLSOptions) (*tls.Config, error) {
+	if !opts.Enabled() {
+		return nil, nil
+	}
+	minVersion := opts.MinVersion
+	if minVersion == 0 {
+		minVersion = tls.VersionTLS12
+	}
+	if minVersion < tls.VersionTLS12 {
+		return nil, errors.New("minimum TLS version must be 1.2 or later")
+	}
+
+	var cert tls.Certificate
+	var err error
+	switch {
+	case opts.CertFile != "" || opts.KeyFile != "":
+		if opts.CertFile == "" || opts.KeyFile == "" {
+			return nil, errors.New("both a TLS certificate and key file are required")
+		}
+		cert, err = tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load TLS key pair: %w", err)
+		}
+	default:
+		cert, err = SelfSignedCertificate(opts.Hosts, 365*24*time.Hour)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &tls.Config{
+		Certificates:     []tls.Certificate{cert},
+		MinVersion:       minVersion,
+		CipherSuites:     cipherSuites,
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
+	}, nil
+}
+
+// SelfSignedCertificate generates an ECDSA P-256 certificate for hosts that
+// is valid for the given duration. Hosts default to localhost.
+func SelfSignedCertificate(hosts []string, validFor time.Duration) (tls.Certificate, error) {
+	if len(hosts) == 0 {
+		hosts = []string{"localhost", "127.0.0.1", "::1"}
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("generate TLS key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("generate certificate serial: %w", err)
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"WardSeal development"}},
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.Add(validFor),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("create certificate: %w", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("parse certificate: %w", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
+
+// RunTLS is Run over TLS. A nil tlsConfig serves plain HTTP, so callers can
+// pass the result of NewTLSConfig unconditionally.
+func RunTLS(handler http.Handler, addr string, tlsConfig *tls.Config, closers ...io.Closer) error {
+	ctx, stop := ShutdownContext()
+	defer stop()
+	return RunTLSContext(ctx, handler, addr, tlsConfig, closers...)
+}
+
+// RunTLSContext is RunTLS, shutting down when ctx is done instead of on a
+// signal.
+func RunTLSContext(ctx context.Context, handler http.Handler, addr string, tlsConfig *tls.Config, closers ...io.Closer) error {
+	if tlsConfig == nil {
+		return RunContext(ctx, handler, addr, closers...)
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return errors.Join(err, closeAll(closers))
+	}
+	return ServeTLS(ctx, ln, handler, tlsConfig, DefaultShutdownTimeout, closers...)
+}
+
+// ServeTLS is Serve over TLS using the certificates in tlsConfig.
+func ServeTLS(ctx context.Context, ln net.Listener, handler http.Handler, tlsConfig *tls.Config, timeout time.Duration, closers ...io.Closer) error {
+	srv := newServer(handler)
+	srv.TLSConfig = tlsConfig
+	return serve(ctx, srv, func() error { return srv.ServeTLS(ln, "", "") }, timeout, closers)
+}
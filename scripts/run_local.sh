@@ -32,6 +32,14 @@ sleep 5 # Wait for dirsvc to be ready
 # AuthService
 echo "Starting Auth Service (port 8080)..."
 export DIRECTORY_SERVICE_URL=http://127.0.0.1:8081
+# The SAML key pair is kept between runs so IdP trust survives restarts.
+mkdir -p .dev
+if [ ! -f .dev/saml.crt ]; then
+  openssl req -x509 -newkey rsa:2048 -nodes -days 730 -subj "/CN=wardseal-saml" \
+    -keyout .dev/saml.key -out .dev/saml.crt 2>/dev/null
+fi
+export SAML_CERT_FILE=.dev/saml.crt
+export SAML_KEY_FILE=.dev/saml.key
 go run ./cmd/authsvc/main.go &
 PID_AUTH=$!
 sleep 2
@@ -3,8 +3,14 @@
 package integration
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"testing"
+	"time"
+
+	"github.com/dhawalhost/wardseal/internal/directory"
+	"github.com/dhawalhost/wardseal/internal/scim"
 )
 
 // TestDirectoryHealthCheck tests the directory service health endpoint.
@@ -292,3 +298,353 @@ func TestCredentialVerification(t *testing.T) {
 	// Cleanup
 	client.Delete(t, "/api/v1/users/"+userID)
 }
+
+// TestSearchUsersByLogin seeds users directly through the store and checks
+// substring search, prefix ordering and tenant isolation.
+func TestSearchUsersByLogin(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	env := SetupTestEnv(t)
+	defer env.Teardown(t)
+
+	ctx := context.Background()
+	tenantID := "33333333-3333-3333-3333-333333333333"
+	otherTenantID := "44444444-4444-4444-4444-444444444444"
+	cleanup := func() {
+		env.DB.ExecContext(ctx, `DELETE FROM identities WHERE tenant_id IN ($1, $2)`, tenantID, otherTenantID)
+	}
+	cleanup()
+	defer cleanup()
+
+	svc := directory.NewService(env.DB, directory.ServiceConfig{PasswordPolicy: directory.DefaultPasswordPolicy()})
+	seed := map[string][]string{
+		tenantID:      {"alice@wardseal.com", "alice.smith@wardseal.com", "malice@wardseal.com", "bob@wardseal.com", "al_ice@wardseal.com"},
+		otherTenantID: {"alice@other.com"},
+	}
+	for tenant, emails := range seed {
+		for _, email := range emails {
+			if _, err := svc.CreateUser(ctx, tenant, directory.User{Email: email, Password: "SeedPass123!", Status: "active"}); err != nil {
+				t.Fatalf("failed to seed %s: %v", email, err)
+			}
+		}
+	}
+
+	users, err := svc.SearchUsers(ctx, tenantID, "ALICE", 10)
+	if err != nil {
+		t.Fatalf("SearchUsers failed: %v", err)
+	}
+	var got []string
+	for _, u := range users {
+		got = append(got, u.Email)
+	}
+	want := []string{"alice.smith@wardseal.com", "alice@wardseal.com", "malice@wardseal.com"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected prefix matches first in login order %v, got %v", want, got)
+		}
+	}
+
+	users, err = svc.SearchUsers(ctx, tenantID, "alice@", 10)
+	if err != nil {
+		t.Fatalf("SearchUsers failed: %v", err)
+	}
+	if len(users) != 2 || users[0].Email != "alice@wardseal.com" {
+		t.Fatalf("expected alice@ to match alice@ and malice@ with the prefix match first, got %+v", users)
+	}
+
+	// Underscore is matched literally rather than as a wildcard.
+	users, err = svc.SearchUsers(ctx, tenantID, "al_", 10)
+	if err != nil {
+		t.Fatalf("SearchUsers failed: %v", err)
+	}
+	if len(users) != 1 || users[0].Email != "al_ice@wardseal.com" {
+		t.Fatalf("expected only al_ice@ to match, got %+v", users)
+	}
+}
+
+// TestUserProfileRoundTrip checks that profile attributes survive create, get,
+// list, update and replace, through the directory and through SCIM.
+func TestUserProfileRoundTrip(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	env := SetupTestEnv(t)
+	defer env.Teardown(t)
+
+	ctx := context.Background()
+	tenantID := "55555555-5555-5555-5555-555555555555"
+	otherTenantID := "66666666-6666-6666-6666-666666666666"
+	cleanup := func() {
+		env.DB.ExecContext(ctx, `DELETE FROM identities WHERE tenant_id IN ($1, $2)`, tenantID, otherTenantID)
+	}
+	cleanup()
+	defer cleanup()
+
+	svc := directory.NewService(env.DB, directory.ServiceConfig{PasswordPolicy: directory.DefaultPasswordPolicy()})
+	managerID, err := svc.CreateUser(ctx, tenantID, directory.User{Email: "grace@wardseal.com", Password: "SeedPass123!", Status: "active"})
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	profile := directory.Profile{Phone: "+1 555 0100", Title: "Engineer", Department: "R&D", ManagerID: managerID, Location: "London"}
+	userID, err := svc.CreateUser(ctx, tenantID, directory.User{Email: "ada@wardseal.com", Password: "SeedPass123!", Status: "active", Profile: profile})
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	got, err := svc.GetUserByID(ctx, tenantID, userID)
+	if err != nil {
+		t.Fatalf("GetUserByID failed: %v", err)
+	}
+	if got.Profile != profile {
+		t.Fatalf("expected profile %+v, got %+v", profile, got.Profile)
+	}
+	users, _, err := svc.ListUsers(ctx, tenantID, 10, 0, directory.Sort{})
+	if err != nil {
+		t.Fatalf("ListUsers failed: %v", err)
+	}
+	for _, u := range users {
+		if u.ID == managerID && u.Profile != (directory.Profile{}) {
+			t.Fatalf("expected the manager to have an empty profile, got %+v", u.Profile)
+		}
+		if u.ID == userID && u.Profile != profile {
+			t.Fatalf("expected the listed profile %+v, got %+v", profile, u.Profile)
+		}
+	}
+
+	// Empty fields keep their value on update.
+	if err := svc.UpdateUser(ctx, tenantID, userID, directory.User{Profile: directory.Profile{Title: "Staff Engineer"}}); err != nil {
+		t.Fatalf("UpdateUser failed: %v", err)
+	}
+	profile.Title = "Staff Engineer"
+	if got, _ = svc.GetUserByEmail(ctx, tenantID, "ada@wardseal.com"); got.Profile != profile {
+		t.Fatalf("expected merged profile %+v, got %+v", profile, got.Profile)
+	}
+
+	// A manager from another tenant is rejected.
+	foreignID, err := svc.CreateUser(ctx, otherTenantID, directory.User{Email: "eve@wardseal.com", Password: "SeedPass123!", Status: "active"})
+	if err != nil {
+		t.Fatalf("failed to create foreign user: %v", err)
+	}
+	err = svc.UpdateUser(ctx, tenantID, userID, directory.User{Profile: directory.Profile{ManagerID: foreignID}})
+	if !errors.Is(err, directory.ErrInvalidManager) {
+		t.Fatalf("expected ErrInvalidManager, got %v", err)
+	}
+
+	// SCIM reads the same attributes and PUT clears the ones it leaves out.
+	scimSvc := scim.NewService(svc, scim.NewExtensionStore(env.DB))
+	scimUser, err := scimSvc.GetUser(ctx, tenantID, userID)
+	if err != nil {
+		t.Fatalf("SCIM GetUser failed: %v", err)
+	}
+	if scimUser.Title != "Staff Engineer" || len(scimUser.PhoneNumbers) != 1 || scimUser.PhoneNumbers[0].Value != "+1 555 0100" ||
+		scimUser.Enterprise == nil || scimUser.Enterprise.Manager == nil || scimUser.Enterprise.Manager.Value != managerID {
+		t.Fatalf("expected the profile in the SCIM user, got %+v", scimUser)
+	}
+	replacement := scim.User{UserName: "ada@wardseal.com", Active: true, Title: "Fellow",
+		Enterprise: &scim.EnterpriseUser{Department: "Research"}}
+	if _, err := scimSvc.ReplaceUser(ctx, tenantID, userID, replacement); err != nil {
+		t.Fatalf("SCIM ReplaceUser failed: %v", err)
+	}
+	want := directory.Profile{Title: "Fellow", Department: "Research"}
+	if got, _ = svc.GetUserByID(ctx, tenantID, userID); got.Profile != want {
+		t.Fatalf("expected replaced profile %+v, got %+v", want, got.Profile)
+	}
+
+	// Deleting the manager unlinks it from their reports.
+	if err := svc.ReplaceProfile(ctx, tenantID, userID, directory.Profile{ManagerID: managerID}); err != nil {
+		t.Fatalf("ReplaceProfile failed: %v", err)
+	}
+	if err := svc.DeleteUser(ctx, tenantID, managerID); err != nil {
+		t.Fatalf("DeleteUser failed: %v", err)
+	}
+	if got, _ = svc.GetUserByID(ctx, tenantID, userID); got.ManagerID != "" {
+		t.Fatalf("expected the deleted manager to be unlinked, got %q", got.ManagerID)
+	}
+}
+
+// TestUserPhotoStore checks that photos are stored per tenant, replaced on
+// upload and removed with their user.
+func TestUserPhotoStore(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	env := SetupTestEnv(t)
+	defer env.Teardown(t)
+
+	ctx := context.Background()
+	tenantID := "77777777-7777-7777-7777-777777777777"
+	otherTenantID := "88888888-8888-8888-8888-888888888888"
+	cleanup := func() {
+		env.DB.ExecContext(ctx, `DELETE FROM identities WHERE tenant_id IN ($1, $2)`, tenantID, otherTenantID)
+	}
+	cleanup()
+	defer cleanup()
+
+	svc := directory.NewService(env.DB, directory.ServiceConfig{PasswordPolicy: directory.DefaultPasswordPolicy()})
+	userID, err := svc.CreateUser(ctx, tenantID, directory.User{Email: "ada@wardseal.com", Password: "SeedPass123!", Status: "active"})
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	photos := directory.NewPhotoStore(env.DB)
+	if _, err := photos.GetPhoto(ctx, tenantID, userID); !errors.Is(err, directory.ErrPhotoNotFound) {
+		t.Fatalf("expected ErrPhotoNotFound, got %v", err)
+	}
+	for _, photo := range []directory.Photo{
+		{ContentType: "image/png", Data: []byte("\x89PNG\r\n\x1a\nfirst")},
+		{ContentType: "image/gif", Data: []byte("GIF89a second")},
+	} {
+		if err := photos.PutPhoto(ctx, tenantID, userID, photo); err != nil {
+			t.Fatalf("PutPhoto failed: %v", err)
+		}
+	}
+	got, err := photos.GetPhoto(ctx, tenantID, userID)
+	if err != nil {
+		t.Fatalf("GetPhoto failed: %v", err)
+	}
+	if got.ContentType != "image/gif" || string(got.Data) != "GIF89a second" || got.UpdatedAt.IsZero() {
+		t.Fatalf("expected the replaced photo, got %s %q", got.ContentType, got.Data)
+	}
+
+	// Another tenant can neither read nor write the user's photo.
+	if _, err := photos.GetPhoto(ctx, otherTenantID, userID); !errors.Is(err, directory.ErrPhotoNotFound) {
+		t.Fatalf("expected ErrPhotoNotFound across tenants, got %v", err)
+	}
+	err = photos.PutPhoto(ctx, otherTenantID, userID, directory.Photo{ContentType: "image/png", Data: []byte("x")})
+	if !errors.Is(err, directory.ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound across tenants, got %v", err)
+	}
+
+	if err := svc.DeleteUser(ctx, tenantID, userID); err != nil {
+		t.Fatalf("DeleteUser failed: %v", err)
+	}
+	if _, err := photos.GetPhoto(ctx, tenantID, userID); !errors.Is(err, directory.ErrPhotoNotFound) {
+		t.Fatalf("expected the photo to be deleted with the user, got %v", err)
+	}
+}
+
+func TestLastLoginAndStaleUsers(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	env := SetupTestEnv(t)
+	defer env.Teardown(t)
+
+	ctx := context.Background()
+	tenantID := "a1a1a1a1-a1a1-a1a1-a1a1-a1a1a1a1a1a1"
+	cleanup := func() {
+		env.DB.ExecContext(ctx, `DELETE FROM identities WHERE tenant_id = $1`, tenantID)
+	}
+	cleanup()
+	defer cleanup()
+
+	svc := directory.NewService(env.DB, directory.ServiceConfig{PasswordPolicy: directory.DefaultPasswordPolicy()})
+	create := func(email, status string) string {
+		id, err := svc.CreateUser(ctx, tenantID, directory.User{Email: email, Password: "SeedPass123!", Status: status})
+		if err != nil {
+			t.Fatalf("failed to create %s: %v", email, err)
+		}
+		return id
+	}
+	activeID := create("active@wardseal.com", "active")
+	idleID := create("idle@wardseal.com", "active")
+	neverID := create("never@wardseal.com", "active")
+	newID := create("new@wardseal.com", "active")
+	disabledID := create("disabled@wardseal.com", "inactive")
+
+	if _, err := svc.VerifyCredentials(ctx, tenantID, "active@wardseal.com", "SeedPass123!"); err != nil {
+		t.Fatalf("VerifyCredentials failed: %v", err)
+	}
+	user, err := svc.GetUserByID(ctx, tenantID, activeID)
+	if err != nil {
+		t.Fatalf("GetUserByID failed: %v", err)
+	}
+	if user.LastLoginAt == nil {
+		t.Fatal("expected the login to set last_login_at")
+	}
+	first := *user.LastLoginAt
+
+	// A second login within the record interval is not written.
+	if _, err := svc.VerifyCredentials(ctx, tenantID, "active@wardseal.com", "SeedPass123!"); err != nil {
+		t.Fatalf("VerifyCredentials failed: %v", err)
+	}
+	if err := svc.RecordLogin(ctx, tenantID, activeID); err != nil {
+		t.Fatalf("RecordLogin failed: %v", err)
+	}
+	user, _ = svc.GetUserByID(ctx, tenantID, activeID)
+	if user.LastLoginAt == nil || !user.LastLoginAt.Equal(first) {
+		t.Fatalf("expected last_login_at to stay %v within the interval, got %v", first, user.LastLoginAt)
+	}
+
+	if err := svc.RecordLogin(ctx, tenantID, idleID); err != nil {
+		t.Fatalf("RecordLogin failed: %v", err)
+	}
+	backdate := func(query, id string) {
+		if _, err := env.DB.ExecContext(ctx, query, id); err != nil {
+			t.Fatalf("failed to backdate %s: %v", id, err)
+		}
+	}
+	backdate(`UPDATE accounts SET last_login_at = NOW() - INTERVAL '60 days' WHERE identity_id = $1`, idleID)
+	backdate(`UPDATE identities SET created_at = NOW() - INTERVAL '90 days' WHERE id = $1`, neverID)
+	backdate(`UPDATE identities SET created_at = NOW() - INTERVAL '90 days' WHERE id = $1`, disabledID)
+
+	stale, err := svc.ListStaleUsers(ctx, tenantID, 30*24*time.Hour)
+	if err != nil {
+		t.Fatalf("ListStaleUsers failed: %v", err)
+	}
+	var got []string
+	for _, u := range stale {
+		got = append(got, u.ID)
+	}
+	// Never-logged-in accounts come first; recent logins, new accounts and
+	// inactive accounts are left out.
+	if len(got) != 2 || got[0] != neverID || got[1] != idleID {
+		t.Fatalf("expected stale users [%s %s], got %v (new user %s)", neverID, idleID, got, newID)
+	}
+	if stale[1].LastLoginAt == nil {
+		t.Fatal("expected the idle user's last login to be returned")
+	}
+}
+
+// TestBatchCreateStoresImportedStatus posts a batch as cmd/tools/userimport
+// does and checks each identity keeps the status of its import row.
+func TestBatchCreateStoresImportedStatus(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	env := SetupTestEnv(t)
+	defer env.Teardown(t)
+
+	client := NewHTTPClient(env.DirServer.URL, env.TestTenantID)
+	resp := client.Post(t, "/users/batch", directory.BatchCreateUsersRequest{Users: []directory.User{
+		{Email: "imported.active@wardseal.com", Password: "ImportPass123!", Status: "active"},
+		{Email: "imported.suspended@wardseal.com", Password: "ImportPass123!", Status: "suspended"},
+	}})
+	AssertStatus(t, resp, http.StatusOK)
+	var result directory.BatchCreateUsersResponse
+	ReadJSON(t, resp, &result)
+	if result.Created != 2 {
+		t.Fatalf("expected both users to be created, got %+v", result)
+	}
+
+	for i, want := range []string{"active", "suspended"} {
+		var status string
+		err := env.DB.GetContext(context.Background(), &status,
+			`SELECT status FROM identities WHERE id = $1 AND tenant_id = $2`, result.Results[i].UserID, env.TestTenantID)
+		if err != nil {
+			t.Fatalf("failed to load imported user %d: %v", i, err)
+		}
+		if status != want {
+			t.Errorf("expected user %d to be stored %s, got %s", i, want, status)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dhawalhost/wardseal/internal/featureflag"
+	"github.com/dhawalhost/wardseal/internal/license"
+)
+
+func TestFeatureFlagStore(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	env := SetupTestEnv(t)
+	defer env.Teardown(t)
+	ctx := context.Background()
+	defer env.DB.ExecContext(ctx, `DELETE FROM feature_flags WHERE tenant_id = $1`, env.TestTenantID)
+
+	svc := featureflag.NewService(featureflag.NewStore(env.DB), &license.License{
+		Features: []string{featureflag.FeatureMFA, featureflag.FeatureSSO},
+	})
+
+	if !svc.IsEnabled(ctx, env.TestTenantID, featureflag.FeatureSSO) {
+		t.Fatalf("expected a licensed feature without a flag to be enabled")
+	}
+	if _, err := svc.SetFeature(ctx, env.TestTenantID, featureflag.FeatureSSO, false); err != nil {
+		t.Fatalf("SetFeature failed: %v", err)
+	}
+	if svc.IsEnabled(ctx, env.TestTenantID, featureflag.FeatureSSO) {
+		t.Fatalf("expected sso to be disabled")
+	}
+	// Setting a flag again updates it in place.
+	if state, err := svc.SetFeature(ctx, env.TestTenantID, featureflag.FeatureSSO, true); err != nil || !state.Enabled {
+		t.Fatalf("expected sso to be re-enabled, got %+v (%v)", state, err)
+	}
+
+	if _, err := svc.SetFeature(ctx, env.TestTenantID, featureflag.FeatureCampaigns, true); err == nil {
+		t.Fatalf("expected enabling an unlicensed feature to fail")
+	}
+	if svc.IsEnabled(ctx, env.TestTenantID, featureflag.FeatureCampaigns) {
+		t.Fatalf("expected an unlicensed feature to be disabled")
+	}
+}
@@ -0,0 +1,49 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/dhawalhost/wardseal/internal/auth"
+)
+
+// TestFederatedProfileRoundTrip stores a federated identity's profile in
+// JSONB and reads it back.
+func TestFederatedProfileRoundTrip(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	env := SetupTestEnv(t)
+	defer env.Teardown(t)
+	ctx := context.Background()
+	defer env.DB.ExecContext(ctx, `DELETE FROM federated_identities WHERE tenant_id = $1`, env.TestTenantID)
+
+	want := auth.FederatedProfile{Subject: "google-1234", Email: env.TestUserEmail, Name: "Test User", Picture: "https://example.com/u.png"}
+	data, _ := json.Marshal(want)
+	store := auth.NewFederationStore(env.DB)
+	if err := store.Create(ctx, auth.FederatedIdentity{
+		IdentityID:  env.TestUserID,
+		TenantID:    env.TestTenantID,
+		Provider:    "google",
+		ExternalID:  want.Subject,
+		ProfileData: auth.JSON(data),
+	}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	got, err := store.Get(ctx, env.TestTenantID, "google", want.Subject)
+	if err != nil || got == nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	profile, err := got.Profile()
+	if err != nil {
+		t.Fatalf("Profile failed: %v", err)
+	}
+	if profile != want {
+		t.Fatalf("Expected %+v, got %+v", want, profile)
+	}
+}
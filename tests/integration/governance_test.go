@@ -3,9 +3,15 @@
 package integration
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"testing"
+
+	"github.com/dhawalhost/wardseal/internal/governance"
+	"github.com/dhawalhost/wardseal/internal/outbox"
+	"github.com/jmoiron/sqlx"
 )
 
 // TestGovernanceHealthCheck tests the governance service health endpoint.
@@ -148,6 +154,21 @@ func TestAccessRequestWorkflow(t *testing.T) {
 			"comment": "Approved for testing",
 		})
 		AssertStatus(t, approveResp, http.StatusOK)
+
+		// The approval queued exactly one provisioning event, which the relay
+		// publishes once.
+		var queued int
+		if err := env.DB.GetContext(context.Background(), &queued,
+			`SELECT COUNT(*) FROM outbox WHERE tenant_id = $1 AND event_type = $2 AND payload->>'request_id' = $3`,
+			env.TestTenantID, governance.EventAccessRequestApproved, requestID); err != nil {
+			t.Fatalf("count outbox events: %v", err)
+		}
+		if queued != 1 {
+			t.Fatalf("Expected 1 outbox event for the approval, got %d", queued)
+		}
+		if n := env.Relay.RunOnce(context.Background()); n < 1 {
+			t.Errorf("Expected the relay to publish the approval event, published %d", n)
+		}
 	})
 
 	// Test creating and rejecting an access request
@@ -227,3 +248,36 @@ func TestOAuthClientValidation(t *testing.T) {
 		AssertStatus(t, resp, http.StatusBadRequest)
 	})
 }
+
+// TestOutboxRollbackDiscardsEvents checks that an event enqueued in a
+// transaction that rolls back is never stored, so it is never published.
+func TestOutboxRollbackDiscardsEvents(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	env := SetupTestEnv(t)
+	defer env.Teardown(t)
+	ctx := context.Background()
+
+	errAbort := errors.New("abort")
+	err := outbox.WithTx(ctx, env.DB, func(tx *sqlx.Tx) error {
+		if err := outbox.Enqueue(ctx, tx, env.TestTenantID, "integration.rolled_back", map[string]string{"k": "v"}); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("Expected WithTx to return the callback error, got %v", err)
+	}
+
+	var stored int
+	if err := env.DB.GetContext(ctx, &stored,
+		`SELECT COUNT(*) FROM outbox WHERE tenant_id = $1 AND event_type = 'integration.rolled_back'`,
+		env.TestTenantID); err != nil {
+		t.Fatalf("count outbox events: %v", err)
+	}
+	if stored != 0 {
+		t.Fatalf("Expected no outbox rows after rollback, got %d", stored)
+	}
+}
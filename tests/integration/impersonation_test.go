@@ -0,0 +1,57 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dhawalhost/wardseal/internal/auth"
+)
+
+func TestImpersonationPolicyAndAuditActor(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	env := SetupTestEnv(t)
+	defer env.Teardown(t)
+	ctx := context.Background()
+	defer env.DB.ExecContext(ctx, `DELETE FROM impersonation_policies WHERE tenant_id = $1`, env.TestTenantID)
+	defer env.DB.ExecContext(ctx, `DELETE FROM auth_audit_events WHERE tenant_id = $1 AND actor IS NOT NULL`, env.TestTenantID)
+
+	store := auth.NewImpersonationStore(env.DB)
+	policy, err := store.Policy(ctx, env.TestTenantID)
+	if err != nil || policy != (auth.ImpersonationPolicy{}) {
+		t.Fatalf("expected the zero policy by default, got %+v (%v)", policy, err)
+	}
+	for _, want := range []auth.ImpersonationPolicy{{MaxMinutes: 5}, {Disabled: true, MaxMinutes: 10}} {
+		if err := store.SetPolicy(ctx, env.TestTenantID, want); err != nil {
+			t.Fatalf("SetPolicy failed: %v", err)
+		}
+		if got, err := store.Policy(ctx, env.TestTenantID); err != nil || got != want {
+			t.Fatalf("expected %+v, got %+v (%v)", want, got, err)
+		}
+	}
+
+	audit := auth.NewAuthAuditStore(env.DB)
+	err = audit.RecordAuthEvent(ctx, auth.AuthEvent{
+		TenantID:  env.TestTenantID,
+		EventType: auth.AuthEventImpersonationStarted,
+		Subject:   env.TestUserID,
+		Actor:     "support-admin",
+		Outcome:   auth.AuthOutcomeSuccess,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		t.Fatalf("RecordAuthEvent failed: %v", err)
+	}
+	events, err := audit.ListAuthEvents(ctx, auth.AuthEventQuery{TenantID: env.TestTenantID, Subject: env.TestUserID, Limit: 1})
+	if err != nil || len(events) != 1 {
+		t.Fatalf("expected one event, got %+v (%v)", events, err)
+	}
+	if events[0].EventType != auth.AuthEventImpersonationStarted || events[0].Actor != "support-admin" {
+		t.Fatalf("expected the impersonation event to keep its actor, got %+v", events[0])
+	}
+}
@@ -5,11 +5,15 @@ package integration
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
 	"io"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"testing"
 	"time"
 
@@ -17,8 +21,11 @@ import (
 	"github.com/dhawalhost/wardseal/internal/directory"
 	"github.com/dhawalhost/wardseal/internal/governance"
 	"github.com/dhawalhost/wardseal/internal/oauthclient"
+	"github.com/dhawalhost/wardseal/internal/outbox"
 	"github.com/dhawalhost/wardseal/internal/policy"
 	"github.com/dhawalhost/wardseal/internal/saml"
+	"github.com/dhawalhost/wardseal/pkg/apierr"
+	"github.com/dhawalhost/wardseal/pkg/config"
 	"github.com/dhawalhost/wardseal/pkg/database"
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
@@ -27,10 +34,12 @@ import (
 
 // TestEnv holds the test environment configuration.
 type TestEnv struct {
-	DB            *sqlx.DB
-	AuthServer    *httptest.Server
-	DirServer     *httptest.Server
-	GovServer     *httptest.Server
+	DB         *sqlx.DB
+	AuthServer *httptest.Server
+	DirServer  *httptest.Server
+	GovServer  *httptest.Server
+	// Relay publishes governance outbox events; tests run it on demand.
+	Relay         *outbox.Relay
 	Logger        *zap.Logger
 	TestTenantID  string
 	TestUserID    string
@@ -44,22 +53,15 @@ func SetupTestEnv(t *testing.T) *TestEnv {
 
 	logger, _ := zap.NewDevelopment()
 
-	// Use environment variable for database connection or default to local
-	dbHost := os.Getenv("TEST_DB_HOST")
-	if dbHost == "" {
-		dbHost = "localhost"
-	}
-
-	dbConfig := database.Config{
-		Host:     dbHost,
-		Port:     5432,
-		User:     envOr("TEST_DB_USER", "user"),
-		Password: envOr("TEST_DB_PASSWORD", "password"),
-		DBName:   envOr("TEST_DB_NAME", "identity_platform_test"),
-		SSLMode:  "disable",
+	// TEST_-prefixed environment variables select the test database.
+	defaults := config.Defaults()
+	defaults.DB.Name = "identity_platform_test"
+	cfg, err := config.Load(defaults, config.Options{EnvPrefix: "TEST_", RequireDB: true})
+	if err != nil {
+		t.Fatalf("Failed to load test configuration: %v", err)
 	}
 
-	db, err := database.NewConnection(dbConfig)
+	db, err := database.NewConnection(cfg.DB.Connection())
 	if err != nil {
 		t.Fatalf("Failed to connect to test database: %v", err)
 	}
@@ -148,7 +150,10 @@ func (env *TestEnv) cleanupTestData(t *testing.T) {
 	env.DB.ExecContext(ctx, `DELETE FROM accounts WHERE tenant_id = $1`, env.TestTenantID)
 	env.DB.ExecContext(ctx, `DELETE FROM identities WHERE tenant_id = $1`, env.TestTenantID)
 	env.DB.ExecContext(ctx, `DELETE FROM oauth_clients WHERE tenant_id = $1`, env.TestTenantID)
+	env.DB.ExecContext(ctx, `DELETE FROM oauth_scope_catalog WHERE tenant_id = $1`, env.TestTenantID)
+	env.DB.ExecContext(ctx, `DELETE FROM policy_rules WHERE tenant_id = $1`, env.TestTenantID)
 	env.DB.ExecContext(ctx, `DELETE FROM access_requests WHERE tenant_id = $1`, env.TestTenantID)
+	env.DB.ExecContext(ctx, `DELETE FROM outbox WHERE tenant_id = $1`, env.TestTenantID)
 	// Don't delete tenant to avoid foreign key issues in other tables
 }
 
@@ -156,29 +161,43 @@ func (env *TestEnv) setupServers(t *testing.T) {
 	t.Helper()
 
 	// Setup Directory Service
-	dirSvc := directory.NewService(env.DB)
+	dirSvc := directory.NewService(env.DB, directory.ServiceConfig{PasswordPolicy: directory.DefaultPasswordPolicy()})
 	dirHandler := directory.NewHTTPHandler(dirSvc, env.Logger, directory.HTTPHandlerConfig{})
 	dirRouter := gin.New()
+	apierr.RegisterFallbacks(dirRouter)
+	dirRouter.Use(apierr.Handler(env.Logger))
 	dirHandler.RegisterRoutes(dirRouter)
 	env.DirServer = httptest.NewServer(dirRouter)
 
 	// Setup Governance Service
 	clientStore := oauthclient.NewRepository(env.DB)
-	reqStore := governance.NewStore(env.DB)
+	reqStore := governance.NewStore(database.NewStmtCache(env.DB, database.QueryLimits{}))
 	dirClient := governance.NewDirectoryClient(env.DirServer.URL)
 	policyEngine := policy.NewSimpleEngine()
-	govSvc := governance.NewService(clientStore, reqStore, dirClient, policyEngine)
+	govSvc := governance.NewService(clientStore, reqStore, policyEngine)
+	env.Relay = outbox.NewRelay(outbox.RelayConfig{
+		Store: outbox.NewStore(env.DB),
+		Handlers: map[string]outbox.Handler{
+			governance.EventAccessRequestApproved: governance.ProvisionApprovedAccess(dirClient),
+		},
+		Logger: env.Logger,
+	})
 	govHandler := governance.NewHTTPHandler(govSvc, env.Logger)
 	govRouter := gin.New()
+	apierr.RegisterFallbacks(govRouter)
+	govRouter.Use(apierr.Handler(env.Logger))
 	govHandler.RegisterRoutes(govRouter)
 	env.GovServer = httptest.NewServer(govRouter)
 
 	// Setup Auth Service
 	samlStore := saml.NewStore(nil)
+	samlCert, samlKey := newSAMLKeyPair(t)
 	authSvc, err := auth.NewService(auth.Config{
 		BaseURL:             "http://localhost:8080",
 		DirectoryServiceURL: env.DirServer.URL,
 		SAMLStore:           samlStore,
+		SAMLCertificate:     samlCert,
+		SAMLPrivateKey:      samlKey,
 		ClientStore:         clientStore,
 		Clients: []auth.ClientConfig{
 			{
@@ -195,10 +214,35 @@ func (env *TestEnv) setupServers(t *testing.T) {
 	}
 	authHandler := auth.NewHTTPHandler(authSvc, env.Logger, nil)
 	authRouter := gin.New()
+	apierr.RegisterFallbacks(authRouter)
 	authHandler.RegisterRoutes(authRouter)
 	env.AuthServer = httptest.NewServer(authRouter)
 }
 
+// newSAMLKeyPair returns a self-signed SP key pair for the auth service.
+func newSAMLKeyPair(t *testing.T) (*x509.Certificate, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate SAML key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "wardseal-sp"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create SAML certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse SAML certificate: %v", err)
+	}
+	return cert, key
+}
+
 // HTTPClient provides helper methods for making HTTP requests in tests.
 type HTTPClient struct {
 	BaseURL  string
@@ -307,13 +351,6 @@ func AssertStatus(t *testing.T, resp *http.Response, expected int) {
 	}
 }
 
-func envOr(key, fallback string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return fallback
-}
-
 // Placeholder test to verify compilation
 func TestIntegrationSetup(t *testing.T) {
 	if testing.Short() {
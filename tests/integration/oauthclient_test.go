@@ -0,0 +1,186 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dhawalhost/wardseal/internal/oauthclient"
+)
+
+// TestOAuthClientScopeCatalog tests that the store only registers clients
+// with scopes from the tenant's scope catalog.
+func TestOAuthClientScopeCatalog(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	env := SetupTestEnv(t)
+	defer env.Teardown(t)
+
+	ctx := context.Background()
+	store := oauthclient.NewRepository(env.DB)
+	params := oauthclient.CreateClientParams{
+		TenantID:     env.TestTenantID,
+		ClientID:     "scope-catalog-client",
+		ClientType:   "public",
+		Name:         "Scope Catalog Client",
+		RedirectURIs: []string{"https://wardseal.com/callback"},
+	}
+
+	t.Run("DefaultCatalogAllowed", func(t *testing.T) {
+		p := params
+		p.AllowedScopes = []string{"openid", "profile", "email"}
+		if _, err := store.CreateClient(ctx, p); err != nil {
+			t.Fatalf("CreateClient with default scopes: %v", err)
+		}
+	})
+
+	t.Run("DisallowedOnUpdate", func(t *testing.T) {
+		_, err := store.UpdateClient(ctx, env.TestTenantID, params.ClientID, oauthclient.UpdateClientParams{
+			AllowedScopes: []string{"openid", "payments:write"},
+		})
+		var scopeErr *oauthclient.ScopeNotAllowedError
+		if !errors.As(err, &scopeErr) {
+			t.Fatalf("Expected ScopeNotAllowedError, got %v", err)
+		}
+		if len(scopeErr.Scopes) != 1 || scopeErr.Scopes[0] != "payments:write" {
+			t.Errorf("Expected payments:write to be reported, got %v", scopeErr.Scopes)
+		}
+	})
+
+	t.Run("DisallowedOnCreate", func(t *testing.T) {
+		p := params
+		p.ClientID = "scope-catalog-client-2"
+		p.AllowedScopes = []string{"openid", "payments:write"}
+		if _, err := store.CreateClient(ctx, p); !oauthclient.IsScopeNotAllowed(err) {
+			t.Fatalf("Expected ScopeNotAllowedError, got %v", err)
+		}
+	})
+
+	t.Run("TenantCatalogAllowed", func(t *testing.T) {
+		if _, err := env.DB.ExecContext(ctx,
+			`INSERT INTO oauth_scope_catalog (tenant_id, scope) VALUES ($1, 'payments:write')`, env.TestTenantID); err != nil {
+			t.Fatalf("Failed to add tenant scope: %v", err)
+		}
+		updated, err := store.UpdateClient(ctx, env.TestTenantID, params.ClientID, oauthclient.UpdateClientParams{
+			AllowedScopes: []string{"openid", "payments:write"},
+		})
+		if err != nil {
+			t.Fatalf("UpdateClient with tenant scope: %v", err)
+		}
+		if len(updated.AllowedScopes) != 2 {
+			t.Errorf("Expected 2 allowed scopes, got %v", updated.AllowedScopes)
+		}
+	})
+}
+
+// TestOAuthClientSecretRotation tests secret validation and rotation for
+// confidential clients.
+func TestOAuthClientSecretRotation(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	env := SetupTestEnv(t)
+	defer env.Teardown(t)
+
+	ctx := context.Background()
+	store := oauthclient.NewRepository(env.DB)
+	hash, err := oauthclient.HashSecret("initial-secret")
+	if err != nil {
+		t.Fatalf("HashSecret: %v", err)
+	}
+	_, err = store.CreateClient(ctx, oauthclient.CreateClientParams{
+		TenantID:         env.TestTenantID,
+		ClientID:         "secret-rotation-client",
+		ClientType:       "confidential",
+		Name:             "Secret Rotation Client",
+		RedirectURIs:     []string{"https://wardseal.com/callback"},
+		AllowedScopes:    []string{"openid"},
+		ClientSecretHash: hash,
+	})
+	if err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+
+	if err := store.ValidateSecret(ctx, env.TestTenantID, "secret-rotation-client", "initial-secret"); err != nil {
+		t.Fatalf("Expected initial secret to validate, got %v", err)
+	}
+	if err := store.ValidateSecret(ctx, env.TestTenantID, "secret-rotation-client", "wrong"); !errors.Is(err, oauthclient.ErrInvalidSecret) {
+		t.Fatalf("Expected ErrInvalidSecret, got %v", err)
+	}
+
+	secret, err := store.RotateSecret(ctx, env.TestTenantID, "secret-rotation-client")
+	if err != nil {
+		t.Fatalf("RotateSecret: %v", err)
+	}
+	if err := store.ValidateSecret(ctx, env.TestTenantID, "secret-rotation-client", secret); err != nil {
+		t.Errorf("Expected rotated secret to validate, got %v", err)
+	}
+	if err := store.ValidateSecret(ctx, env.TestTenantID, "secret-rotation-client", "initial-secret"); !errors.Is(err, oauthclient.ErrInvalidSecret) {
+		t.Errorf("Expected old secret to be rejected, got %v", err)
+	}
+
+	if _, err := store.RotateSecret(ctx, env.TestTenantID, "missing-client"); !errors.Is(err, oauthclient.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound rotating a missing client, got %v", err)
+	}
+}
+
+// TestOAuthClientStaleUpdate tests that an update based on an outdated
+// version is rejected instead of overwriting a concurrent edit.
+func TestOAuthClientStaleUpdate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	env := SetupTestEnv(t)
+	defer env.Teardown(t)
+
+	ctx := context.Background()
+	store := oauthclient.NewRepository(env.DB)
+	created, err := store.CreateClient(ctx, oauthclient.CreateClientParams{
+		TenantID:      env.TestTenantID,
+		ClientID:      "versioned-client",
+		ClientType:    "public",
+		Name:          "Versioned Client",
+		RedirectURIs:  []string{"https://wardseal.com/callback"},
+		AllowedScopes: []string{"openid"},
+	})
+	if err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+
+	// Two editors load the same version; the first save wins.
+	version := created.Version
+	first := "Renamed by admincli"
+	updated, err := store.UpdateClient(ctx, env.TestTenantID, "versioned-client", oauthclient.UpdateClientParams{
+		Name:            &first,
+		ExpectedVersion: &version,
+	})
+	if err != nil {
+		t.Fatalf("First UpdateClient: %v", err)
+	}
+	if updated.Version != version+1 {
+		t.Errorf("Expected version %d after update, got %d", version+1, updated.Version)
+	}
+
+	second := "Renamed by dashboard"
+	_, err = store.UpdateClient(ctx, env.TestTenantID, "versioned-client", oauthclient.UpdateClientParams{
+		Name:            &second,
+		ExpectedVersion: &version,
+	})
+	if !errors.Is(err, oauthclient.ErrConcurrentModification) {
+		t.Fatalf("Expected ErrConcurrentModification for stale update, got %v", err)
+	}
+
+	current, err := store.GetClient(ctx, env.TestTenantID, "versioned-client")
+	if err != nil {
+		t.Fatalf("GetClient: %v", err)
+	}
+	if current.Name != first {
+		t.Errorf("Expected stale update to leave name %q, got %q", first, current.Name)
+	}
+}
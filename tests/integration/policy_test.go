@@ -0,0 +1,76 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dhawalhost/wardseal/internal/policy"
+)
+
+// TestPolicyRuleVersions tests that the rule store records every revision
+// and that rolling back adds a new one evaluated in place of the latest.
+func TestPolicyRuleVersions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	env := SetupTestEnv(t)
+	defer env.Teardown(t)
+
+	ctx := context.Background()
+	svc := policy.NewService(policy.NewStore(env.DB))
+
+	created, err := svc.CreateRule(ctx, env.TestTenantID, policy.Rule{
+		Description: "v1",
+		Effect:      policy.EffectAllow,
+		Actions:     []string{"export"},
+		UpdatedBy:   "alice",
+	})
+	if err != nil {
+		t.Fatalf("CreateRule: %v", err)
+	}
+	updated := created
+	updated.Description = "v2"
+	updated.Effect = policy.EffectDeny
+	updated.UpdatedBy = "bob"
+	if updated, err = svc.UpdateRule(ctx, env.TestTenantID, updated); err != nil {
+		t.Fatalf("UpdateRule: %v", err)
+	}
+	if updated.Version != 2 {
+		t.Fatalf("Expected version 2, got %d", updated.Version)
+	}
+
+	input := policy.Input{TenantID: env.TestTenantID, Action: "export"}
+	if decision, err := svc.Evaluate(ctx, input); err != nil || decision.Allowed {
+		t.Fatalf("Expected the latest revision to deny, got %+v, %v", decision, err)
+	}
+
+	restored, err := svc.RollbackRule(ctx, env.TestTenantID, created.ID, 1, "carol")
+	if err != nil {
+		t.Fatalf("RollbackRule: %v", err)
+	}
+	if restored.Version != 3 || restored.Description != "v1" || restored.UpdatedBy != "carol" {
+		t.Fatalf("Expected v1 restored as version 3, got %+v", restored)
+	}
+	if decision, err := svc.Evaluate(ctx, input); err != nil || !decision.Allowed {
+		t.Fatalf("Expected the restored revision to allow, got %+v, %v", decision, err)
+	}
+
+	versions, err := svc.ListRuleVersions(ctx, env.TestTenantID, created.ID)
+	if err != nil {
+		t.Fatalf("ListRuleVersions: %v", err)
+	}
+	if len(versions) != 3 || versions[0].Version != 3 || versions[2].Author != "alice" {
+		t.Fatalf("Unexpected history: %+v", versions)
+	}
+
+	if _, err := svc.RollbackRule(ctx, env.TestTenantID, created.ID, 7, "carol"); !errors.Is(err, policy.ErrVersionNotFound) {
+		t.Fatalf("Expected ErrVersionNotFound, got %v", err)
+	}
+	if err := svc.DeleteRule(ctx, env.TestTenantID, created.ID); err != nil {
+		t.Fatalf("DeleteRule: %v", err)
+	}
+}
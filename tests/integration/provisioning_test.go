@@ -0,0 +1,74 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dhawalhost/wardseal/internal/connector"
+)
+
+// TestProvisioningTaskRetry tests retrying failed tasks against the database.
+func TestProvisioningTaskRetry(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	env := SetupTestEnv(t)
+	defer env.Teardown(t)
+	ctx := context.Background()
+	defer env.DB.ExecContext(ctx, `DELETE FROM provisioning_tasks WHERE tenant_id = $1`, env.TestTenantID)
+
+	insertTask := func(status string) string {
+		var id string
+		err := env.DB.QueryRowContext(ctx,
+			`INSERT INTO provisioning_tasks (tenant_id, connector_id, operation, resource_type, payload, status, retry_count, error_message, processed_at)
+			 VALUES ($1, '33333333-3333-3333-3333-333333333333', 'delete_user', 'user', '{}', $2, 3, 'target unavailable', NOW())
+			 RETURNING id`, env.TestTenantID, status).Scan(&id)
+		if err != nil {
+			t.Fatalf("Failed to insert %s task: %v", status, err)
+		}
+		return id
+	}
+	failedID := insertTask("failed")
+	completedID := insertTask("completed")
+
+	svc := connector.NewProvisioningService(env.DB, connector.NewRegistry(), env.Logger)
+
+	t.Run("ListFailed", func(t *testing.T) {
+		tasks, err := svc.ListFailedTasks(ctx, env.TestTenantID, 10)
+		if err != nil {
+			t.Fatalf("ListFailedTasks failed: %v", err)
+		}
+		if len(tasks) != 1 || tasks[0].ID != failedID {
+			t.Fatalf("Expected only the failed task, got %+v", tasks)
+		}
+	})
+
+	t.Run("RetryFailed", func(t *testing.T) {
+		task, err := svc.RetryTask(ctx, env.TestTenantID, failedID)
+		if err != nil {
+			t.Fatalf("RetryTask failed: %v", err)
+		}
+		if task.Status != "pending" || task.RetryCount != 0 || task.ManualRetries != 1 || task.ProcessedAt != nil {
+			t.Errorf("Unexpected retried task: %+v", task)
+		}
+		if _, err := svc.RetryTask(ctx, env.TestTenantID, failedID); !errors.Is(err, connector.ErrTaskNotFailed) {
+			t.Errorf("Expected a second retry to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("RejectCompleted", func(t *testing.T) {
+		if _, err := svc.RetryTask(ctx, env.TestTenantID, completedID); !errors.Is(err, connector.ErrTaskNotFailed) {
+			t.Fatalf("Expected ErrTaskNotFailed, got %v", err)
+		}
+	})
+
+	t.Run("OtherTenant", func(t *testing.T) {
+		if _, err := svc.RetryTask(ctx, "22222222-2222-2222-2222-222222222222", completedID); !errors.Is(err, connector.ErrTaskNotFound) {
+			t.Fatalf("Expected ErrTaskNotFound, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,56 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dhawalhost/wardseal/pkg/database"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// TestQueryTimeoutCancelsSlowQuery checks that Postgres cancels a query
+// running past the statement cache's timeout.
+func TestQueryTimeoutCancelsSlowQuery(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	env := SetupTestEnv(t)
+	defer env.Teardown(t)
+
+	core, logs := observer.New(zapcore.WarnLevel)
+	stmts := database.NewStmtCache(env.DB, database.QueryLimits{
+		Timeout:       200 * time.Millisecond,
+		SlowThreshold: 100 * time.Millisecond,
+		Logger:        zap.New(core),
+	})
+	defer func() { _ = stmts.Close() }()
+
+	start := time.Now()
+	err := stmts.Query(context.Background(), "test.pg_sleep", `SELECT pg_sleep(5)`, func(ctx context.Context, stmt *sqlx.Stmt) error {
+		_, err := stmt.ExecContext(ctx)
+		return err
+	})
+	if !errors.Is(err, database.ErrQueryTimeout) {
+		t.Fatalf("Expected ErrQueryTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("pg_sleep ran %s; expected it cancelled after 200ms", elapsed)
+	}
+	if logs.FilterMessage("Slow database query").Len() != 1 {
+		t.Errorf("Expected a slow-query warning, got %+v", logs.All())
+	}
+
+	// The pool connection is usable after the cancellation.
+	var one int
+	if err := env.DB.GetContext(context.Background(), &one, `SELECT 1`); err != nil || one != 1 {
+		t.Fatalf("SELECT 1 after cancellation: %v", err)
+	}
+}
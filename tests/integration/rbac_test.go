@@ -3,8 +3,12 @@
 package integration
 
 import (
+	"context"
 	"net/http"
 	"testing"
+
+	"github.com/dhawalhost/wardseal/internal/rbac"
+	"github.com/dhawalhost/wardseal/pkg/database"
 )
 
 // TestRBACRoleCRUD tests the full CRUD lifecycle for RBAC roles.
@@ -183,3 +187,163 @@ func TestRBACUserPermissions(t *testing.T) {
 		t.Fatal("Expected permissions array in response")
 	}
 }
+
+// TestRBACGroupRoleInheritance checks that a user gains a role's permissions
+// solely through membership of a group the role is assigned to.
+func TestRBACGroupRoleInheritance(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	env := SetupTestEnv(t)
+	defer env.Teardown(t)
+
+	ctx := context.Background()
+	store := rbac.NewStore(database.NewStmtCache(env.DB, database.QueryLimits{}))
+
+	roleID, err := store.CreateRole(ctx, rbac.Role{TenantID: env.TestTenantID, Name: "Group Inherited Role"})
+	if err != nil {
+		t.Fatalf("CreateRole: %v", err)
+	}
+	defer func() { _ = store.DeleteRole(ctx, env.TestTenantID, roleID) }()
+	permID, err := store.CreatePermission(ctx, rbac.Permission{TenantID: env.TestTenantID, Resource: "reports", Action: "export"})
+	if err != nil {
+		t.Fatalf("CreatePermission: %v", err)
+	}
+	defer func() { _, _ = env.DB.ExecContext(ctx, `DELETE FROM permissions WHERE id = $1`, permID) }()
+	if err := store.AssignPermissionToRole(ctx, roleID, permID); err != nil {
+		t.Fatalf("AssignPermissionToRole: %v", err)
+	}
+
+	var groupID string
+	if err := env.DB.GetContext(ctx, &groupID,
+		`INSERT INTO groups (tenant_id, name) VALUES ($1, 'Report Exporters') RETURNING id`, env.TestTenantID); err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+	defer func() { _, _ = env.DB.ExecContext(ctx, `DELETE FROM groups WHERE id = $1`, groupID) }()
+	if _, err := env.DB.ExecContext(ctx,
+		`INSERT INTO identity_groups (identity_id, group_id, tenant_id) VALUES ($1, $2, $3)`,
+		env.TestUserID, groupID, env.TestTenantID); err != nil {
+		t.Fatalf("add group member: %v", err)
+	}
+
+	hasExport := func() bool {
+		perms, err := store.GetUserPermissions(ctx, env.TestTenantID, env.TestUserID)
+		if err != nil {
+			t.Fatalf("GetUserPermissions: %v", err)
+		}
+		for _, p := range perms {
+			if p.ID == permID {
+				return true
+			}
+		}
+		return false
+	}
+	if hasExport() {
+		t.Fatal("user has the permission before the group was granted the role")
+	}
+
+	if err := store.AssignRoleToGroup(ctx, env.TestTenantID, groupID, roleID, nil); err != nil {
+		t.Fatalf("AssignRoleToGroup: %v", err)
+	}
+	if !hasExport() {
+		t.Fatal("user did not inherit the permission through group membership")
+	}
+	roles, err := store.GetUserRoles(ctx, env.TestTenantID, env.TestUserID)
+	if err != nil {
+		t.Fatalf("GetUserRoles: %v", err)
+	}
+	if len(roles) != 1 || roles[0].ID != roleID {
+		t.Fatalf("GetUserRoles = %+v, want only the inherited role", roles)
+	}
+
+	if err := store.RemoveRoleFromGroup(ctx, env.TestTenantID, groupID, roleID); err != nil {
+		t.Fatalf("RemoveRoleFromGroup: %v", err)
+	}
+	if hasExport() {
+		t.Fatal("user kept the permission after the group lost the role")
+	}
+}
+
+// TestRBACBulkRoleAssignment checks the added and skipped counts of the bulk
+// assignment statement against the database.
+func TestRBACBulkRoleAssignment(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	env := SetupTestEnv(t)
+	defer env.Teardown(t)
+
+	ctx := context.Background()
+	store := rbac.NewStore(database.NewStmtCache(env.DB, database.QueryLimits{}))
+	roleID, err := store.CreateRole(ctx, rbac.Role{TenantID: env.TestTenantID, Name: "Bulk Assigned Role"})
+	if err != nil {
+		t.Fatalf("CreateRole: %v", err)
+	}
+	defer func() { _ = store.DeleteRole(ctx, env.TestTenantID, roleID) }()
+
+	other := "99999999-9999-9999-9999-999999999999"
+	added, err := store.AssignRoleToUsers(ctx, env.TestTenantID, roleID, []string{env.TestUserID, other, other}, nil)
+	if err != nil || added != 2 {
+		t.Fatalf("first assignment added %d, %v; want 2", added, err)
+	}
+	added, err = store.AssignRoleToUsers(ctx, env.TestTenantID, roleID, []string{env.TestUserID, other}, nil)
+	if err != nil || added != 0 {
+		t.Fatalf("repeated assignment added %d, %v; want 0", added, err)
+	}
+}
+
+// TestRBACSeedDefaultRoles checks that seeding creates the default roles
+// with their permissions and that running it again changes nothing.
+func TestRBACSeedDefaultRoles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	env := SetupTestEnv(t)
+	defer env.Teardown(t)
+
+	ctx := context.Background()
+	tenantID := "44444444-4444-4444-4444-444444444444"
+	store := rbac.NewStore(database.NewStmtCache(env.DB, database.QueryLimits{}))
+	defer func() {
+		_, _ = env.DB.ExecContext(ctx, `DELETE FROM roles WHERE tenant_id = $1`, tenantID)
+		_, _ = env.DB.ExecContext(ctx, `DELETE FROM permissions WHERE tenant_id = $1`, tenantID)
+	}()
+
+	svc := rbac.NewService(store, rbac.ServiceConfig{})
+	for range 2 {
+		if err := svc.SeedDefaultRoles(ctx, tenantID); err != nil {
+			t.Fatalf("SeedDefaultRoles: %v", err)
+		}
+	}
+
+	roles, err := store.ListRoles(ctx, tenantID)
+	if err != nil {
+		t.Fatalf("ListRoles: %v", err)
+	}
+	want := map[string][]rbac.PermissionTemplate{}
+	for _, r := range rbac.DefaultRoles() {
+		want[r.Name] = r.Permissions
+	}
+	if len(roles) != len(want) {
+		t.Fatalf("got %d roles after seeding twice, want %d", len(roles), len(want))
+	}
+	for _, role := range roles {
+		perms, err := store.GetPermissionsByRole(ctx, role.ID)
+		if err != nil {
+			t.Fatalf("GetPermissionsByRole: %v", err)
+		}
+		if len(perms) != len(want[role.Name]) {
+			t.Fatalf("role %s has %d permissions, want %d", role.Name, len(perms), len(want[role.Name]))
+		}
+	}
+	var permCount int
+	if err := env.DB.GetContext(ctx, &permCount, `SELECT COUNT(*) FROM permissions WHERE tenant_id = $1`, tenantID); err != nil {
+		t.Fatal(err)
+	}
+	if permCount != 3 {
+		t.Fatalf("got %d permissions, want 3 shared between the roles", permCount)
+	}
+}
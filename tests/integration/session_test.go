@@ -0,0 +1,60 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dhawalhost/wardseal/internal/auth"
+)
+
+func TestSessionStoreRoundTrip(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	env := SetupTestEnv(t)
+	defer env.Teardown(t)
+	ctx := context.Background()
+	defer env.DB.ExecContext(ctx, `DELETE FROM auth_sessions WHERE tenant_id = $1`, env.TestTenantID)
+
+	store := auth.NewSessionStore(env.DB)
+	now := time.Now().Truncate(time.Second)
+	for _, session := range []auth.Session{
+		{ID: "family-laptop", Subject: env.TestUserID, UserAgent: "Laptop/1.0", IPAddress: "203.0.113.10", CreatedAt: now.Add(-time.Hour)},
+		{ID: "family-phone", Subject: env.TestUserID, UserAgent: "Phone/2.0", IPAddress: "198.51.100.7", CreatedAt: now.Add(-30 * time.Minute)},
+		{ID: "family-stale", Subject: env.TestUserID, CreatedAt: now.Add(-30 * 24 * time.Hour)},
+	} {
+		session.TenantID = env.TestTenantID
+		session.ClientID = "test-client"
+		if err := store.Create(ctx, session); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+	if err := store.Touch(ctx, env.TestTenantID, "family-laptop", "", "203.0.113.20", now); err != nil {
+		t.Fatalf("Touch failed: %v", err)
+	}
+
+	sessions, err := store.List(ctx, env.TestTenantID, env.TestUserID, now.Add(-7*24*time.Hour))
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].ID != "family-laptop" || sessions[1].ID != "family-phone" {
+		t.Fatalf("expected the two active sessions, most recent first, got %+v", sessions)
+	}
+	if sessions[0].UserAgent != "Laptop/1.0" || sessions[0].IPAddress != "203.0.113.20" || !sessions[0].LastSeenAt.Equal(now) {
+		t.Fatalf("expected Touch to update the IP and last seen time only, got %+v", sessions[0])
+	}
+
+	if err := store.Delete(ctx, env.TestTenantID, "family-laptop"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, found, err := store.Get(ctx, env.TestTenantID, "family-laptop"); err != nil || found {
+		t.Fatalf("expected the deleted session to be gone, found=%v err=%v", found, err)
+	}
+	if _, found, _ := store.Get(ctx, "00000000-0000-0000-0000-000000000001", "family-phone"); found {
+		t.Fatal("expected another tenant not to see the session")
+	}
+}
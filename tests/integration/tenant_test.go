@@ -0,0 +1,54 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/dhawalhost/wardseal/internal/tenant"
+	"github.com/dhawalhost/wardseal/pkg/middleware"
+)
+
+func TestTenantStoreAndLookup(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	env := SetupTestEnv(t)
+	defer env.Teardown(t)
+	ctx := context.Background()
+
+	store := tenant.NewStore(env.DB)
+	created, err := store.Create(ctx, tenant.Tenant{Name: "Integration Tenant", Status: tenant.StatusActive})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer env.DB.ExecContext(ctx, `DELETE FROM tenants WHERE id = $1`, created.ID)
+	if created.ID == "" || created.CreatedAt.IsZero() {
+		t.Fatalf("expected a generated ID and timestamps, got %+v", created)
+	}
+
+	lookup := tenant.Lookup(store)
+	if status, err := lookup(ctx, created.ID); err != nil || status != middleware.TenantActive {
+		t.Fatalf("expected an active tenant, got %v (%v)", status, err)
+	}
+
+	created.Status = tenant.StatusSuspended
+	if _, err := store.Update(ctx, created); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if status, err := lookup(ctx, created.ID); err != nil || status != middleware.TenantInactive {
+		t.Fatalf("expected a suspended tenant to be inactive, got %v (%v)", status, err)
+	}
+
+	// The harness tenant is inserted without a status and defaults to active.
+	if got, err := store.Get(ctx, env.TestTenantID); err != nil || got.Status != tenant.StatusActive {
+		t.Fatalf("expected the test tenant to be active, got %+v (%v)", got, err)
+	}
+	if _, err := store.Get(ctx, "99999999-0000-0000-0000-000000000000"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows for an unknown tenant, got %v", err)
+	}
+}
@@ -228,26 +228,26 @@ export const revokeItem = async (campaignId: string, itemId: string, comment: st
 
 // SSO Providers
 export const getSSOProviders = async () => {
-    const response = await api.get('/api/v1/sso/providers');
+    const response = await api.get('/api/v1/sso-providers');
     return response.data;
 };
 
 export const createSSOProvider = async (provider: Record<string, any>) => {
-    const response = await api.post('/api/v1/sso/providers', provider);
+    const response = await api.post('/api/v1/sso-providers', provider);
     return response.data;
 };
 
 export const updateSSOProvider = async (id: string, provider: Record<string, any>) => {
-    const response = await api.put(`/api/v1/sso/providers/${id}`, provider);
+    const response = await api.put(`/api/v1/sso-providers/${id}`, provider);
     return response.data;
 };
 
 export const deleteSSOProvider = async (id: string) => {
-    await api.delete(`/api/v1/sso/providers/${id}`);
+    await api.delete(`/api/v1/sso-providers/${id}`);
 };
 
 export const toggleSSOProvider = async (id: string, enabled: boolean) => {
-    const response = await api.post(`/api/v1/sso/providers/${id}/toggle`, { enabled });
+    const response = await api.post(`/api/v1/sso-providers/${id}/toggle`, { enabled });
     return response.data;
 };
 
@@ -87,8 +87,12 @@ export default function Connectors() {
         setTesting(true);
         setTestResult(null);
         try {
-            await testConnector(editingConnector);
-            setTestResult({ status: 'success' });
+            const result = await testConnector(editingConnector);
+            if (result.ok) {
+                setTestResult({ status: 'success' });
+            } else {
+                setTestResult({ status: 'failed', error: result.error });
+            }
         } catch (error: any) {
             console.error('Test failed:', error);
             setTestResult({ status: 'failed', error: error.response?.data?.error || error.message });
@@ -16,13 +16,9 @@ const MFASetup: React.FC = () => {
     const [error, setError] = useState('');
     const [loading, setLoading] = useState(true);
 
-    const getUserId = () => {
-        return localStorage.getItem('userId') || 'admin@wardseal.com';
-    };
-
     const fetchStatus = async () => {
         try {
-            const response = await fetch(`/api/v1/mfa/totp/status?user_id=${encodeURIComponent(getUserId())}`, {
+            const response = await fetch('/api/v1/mfa/totp/status', {
                 headers: {
                     'Authorization': `Bearer ${localStorage.getItem('token')}`,
                     'X-Tenant-ID': localStorage.getItem('tenantID') || '',
@@ -55,7 +51,6 @@ const MFASetup: React.FC = () => {
                     'Authorization': `Bearer ${localStorage.getItem('token')}`,
                     'X-Tenant-ID': localStorage.getItem('tenantID') || '',
                 },
-                body: JSON.stringify({ user_id: getUserId() }),
             });
             if (!response.ok) {
                 const err = await response.json();
@@ -82,7 +77,7 @@ const MFASetup: React.FC = () => {
                     'Authorization': `Bearer ${localStorage.getItem('token')}`,
                     'X-Tenant-ID': localStorage.getItem('tenantID') || '',
                 },
-                body: JSON.stringify({ user_id: getUserId(), code: verifyCode }),
+                body: JSON.stringify({ code: verifyCode }),
             });
             if (!response.ok) {
                 const err = await response.json();
@@ -98,13 +93,17 @@ const MFASetup: React.FC = () => {
 
     const handleDisable = async () => {
         if (!window.confirm('Are you sure you want to disable TOTP MFA? This reduces your account security.')) return;
+        const code = verified ? window.prompt('Enter a code from your authenticator app or a recovery code') : '';
+        if (code === null) return;
         try {
-            const response = await fetch(`/api/v1/mfa/totp?user_id=${encodeURIComponent(getUserId())}`, {
+            const response = await fetch('/api/v1/mfa/totp', {
                 method: 'DELETE',
                 headers: {
+                    'Content-Type': 'application/json',
                     'Authorization': `Bearer ${localStorage.getItem('token')}`,
                     'X-Tenant-ID': localStorage.getItem('tenantID') || '',
                 },
+                body: JSON.stringify({ code }),
             });
             if (response.ok) {
                 setEnrolled(false);